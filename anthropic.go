@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// anthropicAPIVersion is the version of the Messages API the requests are written for
+	anthropicAPIVersion = "2023-06-01"
+	// anthropicMaxTokens limits the answer of a request, which the Messages API requires. A dense page
+	// transcribed as markdown stays well below it.
+	anthropicMaxTokens = 4096
+	// anthropicMaxRetries is how often a request is repeated when Anthropic is rate limiting or overloaded
+	anthropicMaxRetries = 3
+	// anthropicMaxRetryWait limits how long a retry waits for the time Anthropic asked for
+	anthropicMaxRetryWait = time.Minute
+)
+
+// anthropicLimiter spaces the requests of all Anthropic models to ANTHROPIC_REQUESTS_PER_MINUTE. Its limit is
+// set by applySettings, so a config reload changes it for running models too.
+var anthropicLimiter = rate.NewLimiter(rate.Inf, 1)
+
+// anthropicRateLimit converts ANTHROPIC_REQUESTS_PER_MINUTE to a limit, 0 does not limit the requests
+func anthropicRateLimit(requestsPerMinute int) rate.Limit {
+	if requestsPerMinute <= 0 {
+		return rate.Inf
+	}
+	return rate.Every(time.Minute / time.Duration(requestsPerMinute))
+}
+
+// anthropicBaseURL returns the Anthropic API URL, which can be changed with ANTHROPIC_BASE_URL, e.g. for a gateway
+func anthropicBaseURL() string {
+	baseURL := anthropicBaseURLOverride
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// withAnthropicLimits wraps the transport of an HTTP client for Anthropic requests, so requests keep to the
+// configured rate and rate limited and overloaded requests are retried
+func withAnthropicLimits(httpClient *http.Client) *http.Client {
+	httpClient.Transport = &anthropicTransport{base: httpClient.Transport, limiter: anthropicLimiter}
+	return httpClient
+}
+
+// anthropicTransport waits for the rate limiter before every request and repeats requests answered with 429
+// (rate limited) or 529 (overloaded) after the time Anthropic asks for
+type anthropicTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter // Never waits if ANTHROPIC_REQUESTS_PER_MINUTE is not set
+}
+
+func (t *anthropicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if t.limiter != nil {
+			if err := t.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != 529) {
+			return resp, err
+		}
+		if attempt == anthropicMaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		wait := anthropicRetryWait(resp.Header.Get("retry-after"), attempt)
+		resp.Body.Close()
+		log.Warnf("Anthropic answered with status %d, retrying in %s", resp.StatusCode, wait)
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// anthropicRetryWait returns how long to wait before repeating a request: the seconds of the retry-after
+// header if present, otherwise an exponential backoff starting at one second
+func anthropicRetryWait(retryAfter string, attempt int) time.Duration {
+	wait := time.Second << attempt
+	if seconds, err := strconv.Atoi(strings.TrimSpace(retryAfter)); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+	return min(wait, anthropicMaxRetryWait)
+}
+
+// anthropicVisionLLM transcribes page images with a Claude model through the Messages API. The Anthropic
+// client of langchaingo only sends text, images have to be sent as base64 content blocks.
+type anthropicVisionLLM struct {
+	model      string
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// anthropicContent is a content block of a Messages API request
+type anthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+// anthropicImageSource is the image of an image content block
+type anthropicImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// anthropicMessage is a message of a Messages API request
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+// anthropicImageContent converts an image part into an image content block
+func anthropicImageContent(part llms.ContentPart) (anthropicContent, error) {
+	switch part := part.(type) {
+	case llms.BinaryContent:
+		return anthropicContent{Type: "image", Source: &anthropicImageSource{
+			Type:      "base64",
+			MediaType: part.MIMEType,
+			Data:      base64.StdEncoding.EncodeToString(part.Data),
+		}}, nil
+	case llms.ImageURLContent:
+		// Data URLs as used for OpenAI are split into media type and data
+		if rest, ok := strings.CutPrefix(part.URL, "data:"); ok {
+			mediaType, data, found := strings.Cut(rest, ";base64,")
+			if !found {
+				return anthropicContent{}, errors.New("anthropic: only base64 data URLs are supported")
+			}
+			return anthropicContent{Type: "image", Source: &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}}, nil
+		}
+		return anthropicContent{Type: "image", Source: &anthropicImageSource{Type: "url", URL: part.URL}}, nil
+	default:
+		return anthropicContent{}, fmt.Errorf("anthropic: unsupported content part %T", part)
+	}
+}
+
+// GenerateContent sends the messages with their text and image parts to the Messages API
+func (m *anthropicVisionLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{MaxTokens: anthropicMaxTokens}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	request := map[string]interface{}{
+		"model":      m.model,
+		"max_tokens": opts.MaxTokens,
+	}
+	if opts.Temperature > 0 {
+		request["temperature"] = opts.Temperature
+	}
+	var system []string
+	var chat []anthropicMessage
+	for _, message := range messages {
+		var content []anthropicContent
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				content = append(content, anthropicContent{Type: "text", Text: text.Text})
+				continue
+			}
+			image, err := anthropicImageContent(part)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, image)
+		}
+
+		switch message.Role {
+		case llms.ChatMessageTypeSystem:
+			for _, block := range content {
+				system = append(system, block.Text)
+			}
+		case llms.ChatMessageTypeAI:
+			chat = append(chat, anthropicMessage{Role: "assistant", Content: content})
+		default:
+			chat = append(chat, anthropicMessage{Role: "user", Content: content})
+		}
+	}
+	request["messages"] = chat
+	if len(system) > 0 {
+		request["system"] = strings.Join(system, "\n\n")
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", m.token)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		statusErr := fmt.Errorf("anthropic: status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+		if json.Unmarshal(raw, &apiError) == nil && apiError.Error.Message != "" {
+			statusErr = fmt.Errorf("anthropic: status %d: %s: %s", resp.StatusCode, apiError.Error.Type, apiError.Error.Message)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			statusErr = fmt.Errorf("%w: %w", errProviderRateLimit, statusErr)
+		}
+		return nil, statusErr
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("anthropic: failed to parse response: %w", err)
+	}
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:    text.String(),
+		StopReason: result.StopReason,
+		GenerationInfo: map[string]any{
+			"InputTokens":  result.Usage.InputTokens,
+			"OutputTokens": result.Usage.OutputTokens,
+		},
+	}}}, nil
+}
+
+// Call sends a single text prompt
+func (m *anthropicVisionLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// listAnthropicModels returns the IDs of the models available through the Anthropic models API
+func listAnthropicModels(ctx context.Context, httpClient *http.Client, baseURL string, token string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", token)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error listing Anthropic models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error listing Anthropic models: status %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error parsing Anthropic models: %w", err)
+	}
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
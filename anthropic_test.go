@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+)
+
+func TestAnthropicVisionLLM(t *testing.T) {
+	image := providerTestImage()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, anthropicAPIVersion, r.Header.Get("anthropic-version"))
+
+		// The first request is rate limited and repeated after the time asked for
+		if requests == 1 {
+			w.Header().Set("retry-after", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		var body struct {
+			Model     string             `json:"model"`
+			MaxTokens int                `json:"max_tokens"`
+			Messages  []anthropicMessage `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "claude-3-5-sonnet-latest", body.Model)
+		assert.Equal(t, anthropicMaxTokens, body.MaxTokens)
+		require.Len(t, body.Messages, 1)
+		assert.Equal(t, "user", body.Messages[0].Role)
+		require.Len(t, body.Messages[0].Content, 2)
+		imageBlock := body.Messages[0].Content[0]
+		assert.Equal(t, "image", imageBlock.Type)
+		assert.Equal(t, "base64", imageBlock.Source.Type)
+		assert.Equal(t, "image/jpeg", imageBlock.Source.MediaType)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(image), imageBlock.Source.Data)
+		assert.Equal(t, anthropicContent{Type: "text", Text: "Transcribe the page"}, body.Messages[0].Content[1])
+
+		w.Write([]byte(`{"content": [{"type": "text", "text": "Dear customer"}], "stop_reason": "end_turn", "usage": {"input_tokens": 1200, "output_tokens": 4}}`))
+	}))
+	defer server.Close()
+
+	model := &anthropicVisionLLM{
+		model:      "claude-3-5-sonnet-latest",
+		token:      "test-key",
+		baseURL:    server.URL + "/v1",
+		httpClient: withAnthropicLimits(&http.Client{Transport: http.DefaultTransport}),
+	}
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.BinaryPart("image/jpeg", image), llms.TextPart("Transcribe the page")},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, "Dear customer", response.Choices[0].Content)
+	assert.Equal(t, 1200, response.Choices[0].GenerationInfo["InputTokens"])
+}
+
+func TestAnthropicImageContentDataURL(t *testing.T) {
+	content, err := anthropicImageContent(llms.ImageURLPart("data:image/png;base64,iVBORw0KGgo="))
+	require.NoError(t, err)
+	assert.Equal(t, &anthropicImageSource{Type: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="}, content.Source)
+
+	content, err = anthropicImageContent(llms.ImageURLPart("https://example.com/page.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, &anthropicImageSource{Type: "url", URL: "https://example.com/page.jpg"}, content.Source)
+}
+
+func TestAnthropicRetryWait(t *testing.T) {
+	assert.Equal(t, 7*time.Second, anthropicRetryWait("7", 0))
+	assert.Equal(t, 4*time.Second, anthropicRetryWait("", 2), "without retry-after the wait doubles")
+	assert.Equal(t, anthropicMaxRetryWait, anthropicRetryWait("3600", 0))
+}
+
+func TestCreateLLM_Anthropic(t *testing.T) {
+	originalKey := anthropicAPIKey
+	defer func() { anthropicAPIKey = originalKey }()
+
+	anthropicAPIKey = ""
+	_, err := createLLM("anthropic", "claude-3-5-haiku-latest")
+	assert.Error(t, err)
+
+	anthropicAPIKey = "test-key"
+	model, err := createLLM("Anthropic", "claude-3-5-haiku-latest")
+	require.NoError(t, err)
+	require.IsType(t, &providerErrorLLM{}, model)
+	assert.IsType(t, &anthropic.LLM{}, model.(*providerErrorLLM).Model)
+
+	vision, err := createVisionLLM("anthropic", "claude-3-5-sonnet-latest")
+	require.NoError(t, err)
+	require.IsType(t, &providerErrorLLM{}, vision)
+	assert.IsType(t, &anthropicVisionLLM{}, vision.(*providerErrorLLM).Model)
+}
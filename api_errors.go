@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrorCode classifies an API error, so clients can react to it without parsing the message
+type ErrorCode string
+
+// Error codes returned as "code" in JSON error bodies
+const (
+	ErrorPaperlessAuth     ErrorCode = "PAPERLESS_AUTH"      // paperless-ngx rejected the token or its permissions
+	ErrorProviderRateLimit ErrorCode = "PROVIDER_RATE_LIMIT" // The LLM provider asked to slow down
+	ErrorTemplateInvalid   ErrorCode = "TEMPLATE_INVALID"    // A prompt template cannot be parsed or rendered
+	ErrorPDFCorrupt        ErrorCode = "PDF_CORRUPT"         // The document's PDF cannot be opened or rendered
+	ErrorTokenLimit        ErrorCode = "TOKEN_LIMIT"         // The prompt does not fit into TOKEN_LIMIT
+)
+
+var (
+	// errTokenLimitExceeded is returned when a prompt template leaves no room for the content within TOKEN_LIMIT
+	errTokenLimitExceeded = errors.New("prompt template exceeds token limit")
+	// errPDFCorrupt is returned when go-fitz cannot open or render a document
+	errPDFCorrupt = errors.New("the PDF cannot be opened or rendered")
+	// errTemplateInvalid is returned when a prompt template cannot be parsed
+	errTemplateInvalid = errors.New("invalid prompt template")
+	// errProviderRateLimit is returned when the LLM provider answered 429 Too Many Requests
+	errProviderRateLimit = errors.New("the LLM provider is rate limiting requests")
+)
+
+// paperlessAuthError is returned for requests paperless-ngx answers with 401 or 403
+type paperlessAuthError struct {
+	StatusCode int
+	Method     string
+	Path       string
+}
+
+func (e *paperlessAuthError) Error() string {
+	return fmt.Sprintf("paperless-ngx rejected %s %s with status %d", e.Method, e.Path, e.StatusCode)
+}
+
+// errorCodeMatchers classify errors by the sentinel errors and types they wrap
+var errorCodeMatchers = []struct {
+	code    ErrorCode
+	matches func(err error) bool
+}{
+	{ErrorPaperlessAuth, func(err error) bool {
+		var authErr *paperlessAuthError
+		return errors.As(err, &authErr)
+	}},
+	{ErrorTokenLimit, func(err error) bool { return errors.Is(err, errTokenLimitExceeded) }},
+	{ErrorPDFCorrupt, func(err error) bool { return errors.Is(err, errPDFCorrupt) }},
+	{ErrorTemplateInvalid, func(err error) bool {
+		var execErr template.ExecError
+		return errors.Is(err, errTemplateInvalid) || errors.As(err, &execErr)
+	}},
+	{ErrorProviderRateLimit, func(err error) bool { return errors.Is(err, errProviderRateLimit) }},
+}
+
+// invalidTemplate marks the error of parsing a prompt template, which text/template does not type
+func invalidTemplate(err error) error {
+	return fmt.Errorf("%w: %w", errTemplateInvalid, err)
+}
+
+// providerRateLimitStatus matches how the provider clients report a 429 answer, e.g. "status code: 429" of
+// OpenAI, "status 429" of Anthropic or "Error 429" of Google AI. The clients return untyped errors.
+var providerRateLimitStatus = regexp.MustCompile(`(?i)\b(status|status code|error):? 429\b|too many requests`)
+
+// providerErrorLLM wraps the client of an LLM provider and marks its rate limit errors with errProviderRateLimit
+type providerErrorLLM struct {
+	llms.Model
+}
+
+// GenerateContent forwards to the provider client and types its rate limit errors
+func (m *providerErrorLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	response, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil && !errors.Is(err, errProviderRateLimit) && providerRateLimitStatus.MatchString(err.Error()) {
+		err = fmt.Errorf("%w: %w", errProviderRateLimit, err)
+	}
+	return response, err
+}
+
+// Call forwards to GenerateContent so errors of both entry points are typed
+func (m *providerErrorLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// withProviderErrors wraps a newly created provider client, keeping a nil model and creation errors as they are
+func withProviderErrors(model llms.Model, err error) (llms.Model, error) {
+	if err != nil || model == nil {
+		return model, err
+	}
+	return &providerErrorLLM{Model: model}, nil
+}
+
+// errorCode classifies an error, or returns an empty code for errors without a known cause
+func errorCode(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	for _, matcher := range errorCodeMatchers {
+		if matcher.matches(err) {
+			return matcher.code
+		}
+	}
+	return ""
+}
+
+// errorResponse builds the JSON body of an error response, including the code of the error if known
+func errorResponse(message string, err error) gin.H {
+	body := gin.H{"error": message}
+	if code := errorCode(err); code != "" {
+		body["code"] = code
+	}
+	return body
+}
+
+// errorLogger returns a logger that adds the code of the error if known
+func errorLogger(err error) *logrus.Entry {
+	if code := errorCode(err); code != "" {
+		return log.WithField("code", code)
+	}
+	return logrus.NewEntry(log)
+}
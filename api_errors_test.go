@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	_, parseErr := template.New("title").Parse("{{.Title")
+	execErr := template.Must(template.New("title").Parse("{{.Title.Missing}}")).Execute(io.Discard, map[string]interface{}{"Title": 1})
+
+	for _, tc := range []struct {
+		err  error
+		code ErrorCode
+	}{
+		{&paperlessAuthError{StatusCode: 401, Method: "GET", Path: "api/tags/"}, ErrorPaperlessAuth},
+		{fmt.Errorf("failed to fetch available tags: %w", &paperlessAuthError{StatusCode: 403, Method: "GET", Path: "api/tags/"}), ErrorPaperlessAuth},
+		{fmt.Errorf("error calculating available tokens: %w", errTokenLimitExceeded), ErrorTokenLimit},
+		{fmt.Errorf("%w: page 3: broken xref", errPDFCorrupt), ErrorPDFCorrupt},
+		{invalidTemplate(parseErr), ErrorTemplateInvalid},
+		{fmt.Errorf("error executing title template: %w", execErr), ErrorTemplateInvalid},
+		{fmt.Errorf("error getting response from LLM: %w", errProviderRateLimit), ErrorProviderRateLimit},
+		// Only the wrapped errors count, not text that happens to look like them
+		{errors.New("paperless-ngx rejected the upload: rate limit of template: status 429"), ""},
+		{fmt.Errorf("failed to fetch available tags: %v", &paperlessAuthError{StatusCode: 403}), ""},
+		{errors.New("connection refused"), ""},
+		{nil, ""},
+	} {
+		assert.Equal(t, tc.code, errorCode(tc.err), "%v", tc.err)
+	}
+}
+
+func TestProviderErrorLLM(t *testing.T) {
+	for message, rateLimited := range map[string]bool{
+		"API returned unexpected status code: 429: Rate limit reached for gpt-4o": true,
+		"googleapi: Error 429: Resource has been exhausted":                       true,
+		"429 Too Many Requests":                    true,
+		"failed to fetch document 1429: not found": false,
+		"API returned unexpected status code: 500": false,
+	} {
+		model := &providerErrorLLM{Model: &failingLLM{err: errors.New(message)}}
+		_, err := model.Call(context.Background(), "prompt")
+		assert.Equal(t, rateLimited, errors.Is(err, errProviderRateLimit), message)
+		assert.Contains(t, err.Error(), message)
+	}
+
+	model, err := withProviderErrors(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, model, "a disabled provider stays nil")
+}
+
+func TestErrorResponse(t *testing.T) {
+	assert.Equal(t, gin.H{"error": "Error fetching tags", "code": ErrorPaperlessAuth},
+		errorResponse("Error fetching tags", &paperlessAuthError{StatusCode: 401}))
+	assert.Equal(t, gin.H{"error": "Document not found"}, errorResponse("Document not found", errors.New("not found")))
+}
+
+func TestPaperlessClientAuthError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := NewPaperlessClient(server.URL, "token").GetAllTags(context.Background())
+	var authErr *paperlessAuthError
+	require.ErrorAs(t, err, &authErr)
+	assert.Equal(t, http.StatusForbidden, authErr.StatusCode)
+	assert.Equal(t, "GET", authErr.Method)
+}
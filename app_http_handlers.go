@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // getPromptsHandler handles the GET /api/prompts endpoint
@@ -53,7 +60,7 @@ func updatePromptsHandler(c *gin.Context) {
 	if req.TitleTemplate != "" {
 		t, err := template.New("title").Parse(req.TitleTemplate)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid title template: %v", err)})
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Invalid title template: %v", err), invalidTemplate(err)))
 			return
 		}
 		titleTemplate = t
@@ -67,7 +74,7 @@ func updatePromptsHandler(c *gin.Context) {
 	if req.TagTemplate != "" {
 		t, err := template.New("tag").Parse(req.TagTemplate)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid tag template: %v", err)})
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Invalid tag template: %v", err), invalidTemplate(err)))
 			return
 		}
 		tagTemplate = t
@@ -84,24 +91,117 @@ func updatePromptsHandler(c *gin.Context) {
 func (app *App) getAllTagsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
+	includeIDs := c.Query("include_ids") == "true"
+	includeCounts := c.Query("include_counts") == "true"
+
+	// Without options, keep returning the name to ID map
+	if !includeIDs && !includeCounts {
+		tags, err := app.Client.GetAllTags(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching tags: %v", err), err))
+			errorLogger(err).Errorf("Error fetching tags: %v", err)
+			return
+		}
+		c.JSON(http.StatusOK, tags)
+		return
+	}
+
+	tags, err := app.Client.GetTags(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching tags: %v", err), err))
+		errorLogger(err).Errorf("Error fetching tags: %v", err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(tags))
+	for _, tag := range tags {
+		item := gin.H{"name": tag.Name, "color": tag.Color}
+		if includeIDs {
+			item["id"] = tag.ID
+		}
+		if includeCounts {
+			item["document_count"] = tag.DocumentCount
+		}
+		response = append(response, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// getTagTreeHandler handles the GET /api/tags/tree endpoint
+func (app *App) getTagTreeHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tags, err := app.Client.GetAllTags(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching tags: %v", err), err))
+		errorLogger(err).Errorf("Error fetching tags: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"separator": tagHierarchySeparator,
+		"tags":      buildTagTree(tags, tagHierarchySeparator),
+	})
+}
+
+// createTagHandler handles the POST /api/tags endpoint
+func (app *App) createTagHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload, name is required"})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+
 	tags, err := app.Client.GetAllTags(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error fetching tags: %v", err)})
-		log.Errorf("Error fetching tags: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching tags: %v", err), err))
+		errorLogger(err).Errorf("Error fetching tags: %v", err)
+		return
+	}
+	for existing, id := range tags {
+		if strings.EqualFold(existing, name) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists", "id": id, "name": existing})
+			return
+		}
+	}
+
+	id, err := app.Client.CreateTag(ctx, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error creating tag: %v", err), err))
+		errorLogger(err).Errorf("Error creating tag %q: %v", name, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "name": name})
+}
+
+// getSavedViewsHandler handles the GET /api/views endpoint
+func (app *App) getSavedViewsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	savedViews, err := app.Client.GetSavedViews(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching saved views: %v", err), err))
+		errorLogger(err).Errorf("Error fetching saved views: %v", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, tags)
+	c.JSON(http.StatusOK, savedViews)
 }
 
 // documentsHandler handles the GET /api/documents endpoint
 func (app *App) documentsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	documents, err := app.Client.GetDocumentsByTags(ctx, []string{manualTag}, 25)
+	documents, err := app.Client.GetDocumentsByTags(ctx, []string{settings().ManualTag}, 25)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error fetching documents: %v", err)})
-		log.Errorf("Error fetching documents: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching documents: %v", err), err))
+		errorLogger(err).Errorf("Error fetching documents: %v", err)
 		return
 	}
 
@@ -114,17 +214,46 @@ func (app *App) generateSuggestionsHandler(c *gin.Context) {
 
 	var suggestionRequest GenerateSuggestionsRequest
 	if err := c.ShouldBindJSON(&suggestionRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request payload: %v", err)})
-		log.Errorf("Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Invalid request payload: %v", err), err))
+		errorLogger(err).Errorf("Invalid request payload: %v", err)
 		return
 	}
 
+	if suggestionRequest.ViewID != 0 && len(suggestionRequest.Documents) == 0 {
+		limit := suggestionRequest.Limit
+		if limit <= 0 {
+			limit = 25
+		}
+		documents, err := app.Client.GetDocumentsBySavedView(ctx, suggestionRequest.ViewID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching documents of saved view: %v", err), err))
+			errorLogger(err).Errorf("Error fetching documents of saved view %d: %v", suggestionRequest.ViewID, err)
+			return
+		}
+		suggestionRequest.Documents = documents
+	}
+
 	results, err := app.generateDocumentSuggestions(ctx, suggestionRequest, log.WithContext(ctx))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error processing documents: %v", err)})
-		log.Errorf("Error processing documents: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error processing documents: %v", err), err))
+		errorLogger(err).Errorf("Error processing documents: %v", err)
+		outcomes := make([]JobWebhookDocument, 0, len(suggestionRequest.Documents))
+		for _, document := range suggestionRequest.Documents {
+			outcomes = append(outcomes, documentOutcome(document.ID, err, nil))
+		}
+		notifyJobFinished(ctx, JobWebhook{Event: jobEventSuggestionsFinished, Source: "api", Documents: outcomes})
 		return
 	}
+	outcomes := make([]JobWebhookDocument, 0, len(results))
+	for _, result := range results {
+		outcomes = append(outcomes, documentOutcome(result.ID, nil, suggestionChanges(result)))
+	}
+	notifyJobFinished(ctx, JobWebhook{Event: jobEventSuggestionsFinished, Source: "api", Documents: outcomes})
+
+	if err := app.recordSuggestions(ctx, results); err != nil {
+		log.Warnf("Failed to record suggestions for the quality statistics: %v", err)
+	}
+	app.startShadowRun(ctx, suggestionRequest, results)
 
 	c.JSON(http.StatusOK, results)
 }
@@ -134,18 +263,22 @@ func (app *App) updateDocumentsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 	var documents []DocumentSuggestion
 	if err := c.ShouldBindJSON(&documents); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request payload: %v", err)})
-		log.Errorf("Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Invalid request payload: %v", err), err))
+		errorLogger(err).Errorf("Invalid request payload: %v", err)
 		return
 	}
 
 	err := app.Client.UpdateDocuments(ctx, documents, app.Database, false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error updating documents: %v", err)})
-		log.Errorf("Error updating documents: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error updating documents: %v", err), err))
+		errorLogger(err).Errorf("Error updating documents: %v", err)
 		return
 	}
 
+	if err := app.recordSuggestionOutcomes(ctx, documents); err != nil {
+		log.Warnf("Failed to record suggestion outcomes for the quality statistics: %v", err)
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -157,6 +290,17 @@ func (app *App) submitOCRJobHandler(c *gin.Context) {
 		return
 	}
 
+	// The optional body overrides the OCR settings of the environment for this job
+	var options OcrJobOptions
+	if err := c.ShouldBindJSON(&options); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if err := options.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		return
+	}
+
 	// Create a new job
 	jobID := generateJobID() // Implement a function to generate unique job IDs
 	job := &Job{
@@ -165,11 +309,16 @@ func (app *App) submitOCRJobHandler(c *gin.Context) {
 		Status:     "pending",
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
+		Username:   tenantUsername(c.Request.Context()),
+		Options:    options,
 	}
 
 	// Add job to store and queue
-	jobStore.addJob(job)
-	jobQueue <- job
+	if err := jobStore.addJob(c.Request.Context(), job); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to queue job", err))
+		errorLogger(err).Errorf("Failed to queue OCR job for document %d: %v", documentID, err)
+		return
+	}
 
 	// Return the job ID to the client
 	c.JSON(http.StatusAccepted, gin.H{"job_id": jobID})
@@ -179,7 +328,7 @@ func (app *App) getJobStatusHandler(c *gin.Context) {
 	jobID := c.Param("job_id")
 
 	job, exists := jobStore.getJob(jobID)
-	if !exists {
+	if !exists || job.Username != tenantUsername(c.Request.Context()) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
 		return
 	}
@@ -190,10 +339,21 @@ func (app *App) getJobStatusHandler(c *gin.Context) {
 		"created_at": job.CreatedAt,
 		"updated_at": job.UpdatedAt,
 		"pages_done": job.PagesDone,
+
+		"peak_render_memory_bytes": job.PeakRenderBytes,
+	}
+	if job.Options.overridesVisionModel() {
+		response["provider"], response["model"] = job.Options.visionModel()
 	}
 
 	if job.Status == "completed" {
-		response["result"] = job.Result
+		result, err := app.jobResult(job)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve job result", err))
+			errorLogger(err).Errorf("Failed to retrieve result of job %s: %v", job.ID, err)
+			return
+		}
+		response["result"] = result
 	} else if job.Status == "failed" {
 		response["error"] = job.Result
 	}
@@ -201,57 +361,257 @@ func (app *App) getJobStatusHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (app *App) getAllJobsHandler(c *gin.Context) {
-	jobs := jobStore.GetAllJobs()
+// getJobResultHandler handles the GET /api/jobs/ocr/:job_id/result endpoint and returns the OCR text as plain text
+func (app *App) getJobResultHandler(c *gin.Context) {
+	jobID := c.Param("job_id")
 
-	jobList := make([]gin.H, 0, len(jobs))
-	for _, job := range jobs {
-		response := gin.H{
-			"job_id":     job.ID,
-			"status":     job.Status,
-			"created_at": job.CreatedAt,
-			"updated_at": job.UpdatedAt,
-			"pages_done": job.PagesDone,
-		}
+	job, exists := jobStore.getJob(jobID)
+	if exists && job.Username != tenantUsername(c.Request.Context()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job result not found"})
+		return
+	}
+	if exists && job.Status != "completed" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Job is %s", job.Status)})
+		return
+	}
+	if !exists {
+		// The result outlives a job removed from Redis until the retention expires
+		job = &Job{ID: jobID, Username: tenantUsername(c.Request.Context())}
+	}
 
-		if job.Status == "completed" {
-			response["result"] = job.Result
-		} else if job.Status == "failed" {
-			response["error"] = job.Result
+	result, err := app.jobResult(job)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job result not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve job result", err))
+		errorLogger(err).Errorf("Failed to retrieve result of job %s: %v", jobID, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"ocr-%s.txt\"", jobID))
+	c.String(http.StatusOK, result)
+}
+
+// getJobArtifactsHandler handles the GET /api/jobs/ocr/:job_id/artifacts endpoint and returns a zip with
+// the OCR text, the text of every page and the job metadata
+func (app *App) getJobArtifactsHandler(c *gin.Context) {
+	jobID := c.Param("job_id")
+
+	job, exists := jobStore.getJob(jobID)
+	if exists && job.Username != tenantUsername(c.Request.Context()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if exists && job.Status != "completed" && job.Status != "failed" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Job is %s", job.Status)})
+		return
+	}
+
+	var result string
+	if !exists {
+		// The result outlives a job removed from Redis until the retention expires
+		record, err := GetOcrJobResult(app.Database, jobID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && record.Username != tenantUsername(c.Request.Context())) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve job result", err))
+			errorLogger(err).Errorf("Failed to retrieve result of job %s: %v", jobID, err)
+			return
+		}
+		job = &Job{ID: jobID, DocumentID: int(record.DocumentID), Username: record.Username, Status: "completed"}
+		result = record.Text
+	} else if job.Status == "completed" {
+		var err error
+		if result, err = app.jobResult(job); err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve job result", err))
+			errorLogger(err).Errorf("Failed to retrieve result of job %s: %v", jobID, err)
+			return
 		}
+	}
 
-		jobList = append(jobList, response)
+	pages, err := GetJobOcrPageResults(app.Database, job.DocumentID, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve OCR pages", err))
+		errorLogger(err).Errorf("Failed to retrieve OCR pages for document %d: %v", job.DocumentID, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, jobList)
+	var buffer bytes.Buffer
+	if err := writeJobArtifacts(&buffer, job, result, pages); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to create the artifacts bundle", err))
+		errorLogger(err).Errorf("Failed to create the artifacts of job %s: %v", jobID, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"ocr-%s.zip\"", jobID))
+	c.Data(http.StatusOK, "application/zip", buffer.Bytes())
 }
 
-// getDocumentHandler handles the retrieval of a document by its ID
-func (app *App) getDocumentHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id := c.Param("id")
-		parsedID, err := strconv.Atoi(id)
+// jobResult returns the OCR text of a completed job, from memory or from the database
+func (app *App) jobResult(job *Job) (string, error) {
+	if job.Result != "" {
+		return job.Result, nil
+	}
+	record, err := GetOcrJobResult(app.Database, job.ID)
+	if err != nil {
+		return "", err
+	}
+	if record.Username != job.Username {
+		return "", gorm.ErrRecordNotFound
+	}
+	return record.Text, nil
+}
+
+// getQueuesHandler handles the GET /api/queues endpoint and reports the documents waiting for background processing
+func (app *App) getQueuesHandler(c *gin.Context) {
+	current := settings()
+	ctx := c.Request.Context()
+	queues := gin.H{}
+	for name, queue := range map[string]struct {
+		enabled  bool
+		tag      string
+		perCycle int
+	}{
+		"ocr":     {isOcrEnabled(), current.AutoOcrTag, current.OcrDocumentsPerCycle},
+		"tagging": {isLLMEnabled(), current.AutoTag, current.TaggingDocumentsPerCycle},
+	} {
+		if !queue.enabled {
+			continue
+		}
+		backlog, err := app.countBacklog(ctx, queue.tag)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to count queued documents", err))
+			errorLogger(err).Errorf("Failed to count documents tagged %s: %v", queue.tag, err)
 			return
 		}
-		document, err := app.Client.GetDocument(c, parsedID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			log.Errorf("Error fetching document: %v", err)
+		queues[name] = gin.H{"tag": queue.tag, "backlog": backlog, "per_cycle": queue.perCycle}
+	}
+
+	var pausedUntil *time.Time
+	if resumeAt := autoChanges.resumeTime(); !resumeAt.IsZero() {
+		pausedUntil = &resumeAt
+	}
+	c.JSON(http.StatusOK, gin.H{"background_processing": backgroundProcessing, "leader": isLeader(), "paused_until": pausedUntil, "queues": queues})
+}
+
+// getBacklogStatsHandler handles the GET /api/stats/backlog endpoint. The optional "hours" parameter
+// selects the covered period (default 24, at most the 30 day retention).
+func (app *App) getBacklogStatsHandler(c *gin.Context) {
+	hours := 24
+	if raw := c.Query("hours"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > int(backlogRetention.Hours()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("hours must be between 1 and %d", int(backlogRetention.Hours()))})
 			return
 		}
-		c.JSON(http.StatusOK, document)
+		hours = parsed
+	}
+
+	samples, err := GetBacklogSamples(app.Database, tenantUsername(c.Request.Context()), time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve backlog samples", err))
+		errorLogger(err).Errorf("Failed to retrieve backlog samples: %v", err)
+		return
 	}
+
+	points := backlogPoints(samples)
+	c.JSON(http.StatusOK, gin.H{
+		"hours":           hours,
+		"sample_interval": backlogSampleInterval.String(),
+		"samples":         points,
+		"ocr_change":      backlogChange(points, func(p BacklogPoint) *int { return p.OCR }),
+		"tagging_change":  backlogChange(points, func(p BacklogPoint) *int { return p.Tagging }),
+	})
 }
 
-// Section for local-db actions
+// getQualityStatsHandler handles the GET /api/stats/quality endpoint. The optional "days" parameter
+// selects the covered period (default 30, at most the one year retention).
+func (app *App) getQualityStatsHandler(c *gin.Context) {
+	maxDays := int(suggestionRecordRetention.Hours() / 24)
+	days := 30
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxDays)})
+			return
+		}
+		days = parsed
+	}
 
-func (app *App) getModificationHistoryHandler(c *gin.Context) {
-	// Parse pagination parameters
+	records, err := GetSuggestionRecords(app.Database, tenantUsername(c.Request.Context()), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve suggestion records", err))
+		errorLogger(err).Errorf("Failed to retrieve suggestion records: %v", err)
+		return
+	}
+
+	stats := qualityStats(records)
+	c.JSON(http.StatusOK, gin.H{
+		"days":   days,
+		"fields": stats.Fields,
+		"models": stats.Models,
+		"daily":  stats.Daily,
+	})
+}
+
+// getShadowReportHandler handles the GET /api/shadow/report endpoint. The optional "days" parameter selects
+// the covered period (default 7).
+func (app *App) getShadowReportHandler(c *gin.Context) {
+	maxDays := int(shadowResultRetention.Hours() / 24)
+	days := 7
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxDays)})
+			return
+		}
+		days = parsed
+	}
+
+	results, err := GetShadowResults(app.Database, tenantUsername(c.Request.Context()), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve shadow results", err))
+		errorLogger(err).Errorf("Failed to retrieve shadow results: %v", err)
+		return
+	}
+
+	report := shadowReport(results)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":     app.ShadowLLM != nil,
+		"model":       shadowModel(),
+		"days":        days,
+		"documents":   report.Documents,
+		"fields":      report.Fields,
+		"differences": report.Differences,
+	})
+}
+
+// getJobMetricsHandler handles the GET /api/jobs/metrics endpoint
+func (app *App) getJobMetricsHandler(c *gin.Context) {
+	counts := jobStore.statusCounts()
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	metrics := gin.H{
+		"jobs":      total,
+		"by_status": counts,
+		"queued":    jobStore.queuedJobs(),
+		"backend":   "database",
+		"retention": jobRetention.String(),
+	}
+	if jobStore.shared != nil {
+		metrics["backend"] = "redis"
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
+// getAllJobsHandler handles the GET /api/jobs/ocr endpoint and returns a page of the job history, newest first
+func (app *App) getAllJobsHandler(c *gin.Context) {
 	page := 1
 	pageSize := 20
-
 	if p, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && p > 0 {
 		page = p
 	}
@@ -259,94 +619,1381 @@ func (app *App) getModificationHistoryHandler(c *gin.Context) {
 		pageSize = ps
 	}
 
-	// Get paginated modifications and total count
-	modifications, total, err := GetPaginatedModifications(app.Database, page, pageSize)
+	jobs, total, err := jobStore.listJobs(tenantUsername(c.Request.Context()), page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve modification history"})
-		log.Errorf("Failed to retrieve modification history: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve jobs", err))
+		errorLogger(err).Errorf("Failed to retrieve jobs: %v", err)
 		return
 	}
 
-	totalPages := (int(total) + pageSize - 1) / pageSize
+	jobList := make([]gin.H, 0, len(jobs))
+	for _, job := range jobs {
+		response := gin.H{
+			"job_id":      job.ID,
+			"document_id": job.DocumentID,
+			"status":      job.Status,
+			"created_at":  job.CreatedAt,
+			"updated_at":  job.UpdatedAt,
+			"pages_done":  job.PagesDone,
+
+			"peak_render_memory_bytes": job.PeakRenderBytes,
+		}
+
+		// The full text is only available from the result endpoint to keep the listing small
+		if job.Status == "completed" {
+			response["result_preview"] = job.ResultPreview
+			response["result_size"] = job.ResultSize
+			response["result_url"] = fmt.Sprintf("/api/jobs/ocr/%s/result", job.ID)
+		} else if job.Status == "failed" {
+			response["error"] = job.Result
+		}
+
+		jobList = append(jobList, response)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"items":       modifications,
+		"items":       jobList,
 		"totalItems":  total,
-		"totalPages":  totalPages,
+		"totalPages":  (int(total) + pageSize - 1) / pageSize,
 		"currentPage": page,
 		"pageSize":    pageSize,
 	})
 }
 
-func (app *App) undoModificationHandler(c *gin.Context) {
-	id := c.Param("id")
-	modID, err := strconv.Atoi(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid modification ID"})
-		log.Errorf("Invalid modification ID: %v", err)
-		return
+// getDocumentHandler handles the retrieval of a document by its ID
+func (app *App) getDocumentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		parsedID, err := strconv.Atoi(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+			return
+		}
+		document, err := app.Client.GetDocument(c.Request.Context(), parsedID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err.Error(), err))
+			errorLogger(err).Errorf("Error fetching document: %v", err)
+			return
+		}
+		c.JSON(http.StatusOK, document)
 	}
+}
 
-	modification, err := GetModification(app.Database, uint(modID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve modification"})
-		log.Errorf("Failed to retrieve modification: %v", err)
+// startBackfillHandler handles the POST /api/backfill endpoint
+func (app *App) startBackfillHandler(c *gin.Context) {
+	var req struct {
+		BackfillOptions
+		DelaySeconds float64 `json:"delay_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 		return
 	}
 
-	if modification.Undone {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Modification has already been undone"})
-		log.Errorf("Modification has already been undone: %v", id)
+	options := req.BackfillOptions
+	options.Delay = time.Duration(req.DelaySeconds * float64(time.Second))
+	if err := app.startBackfill(c.Request.Context(), options); err != nil {
+		c.JSON(http.StatusConflict, errorResponse(err.Error(), err))
 		return
 	}
 
-	// Ok, we're actually doing the update:
-	ctx := c.Request.Context()
+	c.JSON(http.StatusAccepted, getBackfillStatus(c.Request.Context()))
+}
 
-	// Make the document suggestions for UpdateDocuments
-	var suggestion DocumentSuggestion
-	suggestion.ID = int(modification.DocumentID)
-	suggestion.OriginalDocument, err = app.Client.GetDocument(ctx, int(modification.DocumentID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve original document"})
-		log.Errorf("Failed to retrieve original document: %v", err)
+// estimateBackfillHandler handles the POST /api/backfill/estimate endpoint
+func (app *App) estimateBackfillHandler(c *gin.Context) {
+	var options BackfillOptions
+	if err := c.ShouldBindJSON(&options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 		return
 	}
-	switch modification.ModField {
-	case "title":
-		suggestion.SuggestedTitle = modification.PreviousValue
-	case "tags":
-		var tags []string
-		err := json.Unmarshal([]byte(modification.PreviousValue), &tags)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmarshal previous tags"})
-			log.Errorf("Failed to unmarshal previous tags: %v", err)
-			return
-		}
-		suggestion.SuggestedTags = tags
-	case "content":
-		suggestion.SuggestedContent = modification.PreviousValue
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid modification field"})
-		log.Errorf("Invalid modification field: %v", modification.ModField)
+
+	estimate, err := app.estimateBackfill(c.Request.Context(), options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error estimating backfill: %v", err), err))
+		errorLogger(err).Errorf("Error estimating backfill: %v", err)
 		return
 	}
 
-	// Update the document
-	err = app.Client.UpdateDocuments(ctx, []DocumentSuggestion{suggestion}, app.Database, true)
+	c.JSON(http.StatusOK, estimate)
+}
+
+// estimateOcrHandler handles the GET /api/jobs/ocr/estimate endpoint
+func (app *App) estimateOcrHandler(c *gin.Context) {
+	estimate, err := app.estimateOcr(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document"})
-		log.Errorf("Failed to update document: %v", err)
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error estimating OCR: %v", err), err))
+		errorLogger(err).Errorf("Error estimating OCR: %v", err)
 		return
 	}
 
-	// Successful, so set modification as undone
+	c.JSON(http.StatusOK, estimate)
+}
+
+// getBackfillStatusHandler handles the GET /api/backfill endpoint
+func (app *App) getBackfillStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, getBackfillStatus(c.Request.Context()))
+}
+
+// getCapabilitiesHandler handles the GET /api/capabilities endpoint
+func getCapabilitiesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, paperlessCapabilities)
+}
+
+// getVersionHandler handles the GET /api/version endpoint. With UPDATE_CHECK the latest GitHub release is included.
+func getVersionHandler(c *gin.Context) {
+	info := versionInfo()
+	if updateCheck {
+		info.Update = checkForUpdate(c.Request.Context())
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// reloadConfigHandler handles the POST /api/config/reload endpoint and returns the changed settings
+func reloadConfigHandler(c *gin.Context) {
+	changes, err := reloadConfig()
+	if errors.Is(err, errNoConfigFile) {
+		c.JSON(http.StatusConflict, errorResponse(err.Error(), err))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		errorLogger(err).Errorf("Config reload failed: %v", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+// getUsageStatisticsHandler handles the GET /api/usage-statistics endpoint. It shows exactly what USAGE_STATISTICS_URL receives.
+func getUsageStatisticsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, usageReport())
+}
+
+// getPaperlessDiagnosticsHandler handles the GET /api/diagnostics/paperless endpoint
+func (app *App) getPaperlessDiagnosticsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, app.Client.diagnose(c.Request.Context()))
+}
+
+// getProviderModelsHandler handles the GET /api/providers/:name/models endpoint
+func getProviderModelsHandler(c *gin.Context) {
+	provider := strings.ToLower(c.Param("name"))
+	if !slices.Contains(configuredProviders(), provider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Provider %q is not configured", provider)})
+		return
+	}
+
+	models, err := listProviderModels(c.Request.Context(), provider)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse(err.Error(), err))
+		errorLogger(err).Errorf("Failed to list the models of %s: %v", provider, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"provider": provider, "models": models})
+}
+
+// testProvidersHandler handles the POST /api/providers/test endpoint
+func (app *App) testProvidersHandler(c *gin.Context) {
+	health := app.checkProviders(c.Request.Context())
+	status := http.StatusOK
+	if !health.LLM.OK || (health.OCR != nil && !health.OCR.OK) {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+}
+
+// getReaskMetricsHandler handles the GET /api/metrics/reasks endpoint
+func getReaskMetricsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, getReaskMetrics())
+}
+
+// stopBackfillHandler handles the DELETE /api/backfill endpoint
+func (app *App) stopBackfillHandler(c *gin.Context) {
+	stopBackfill(c.Request.Context())
+	c.JSON(http.StatusOK, getBackfillStatus(c.Request.Context()))
+}
+
+// checkDueDatesHandler handles the POST /api/due-dates/check endpoint and runs the due date check immediately
+func (app *App) checkDueDatesHandler(c *gin.Context) {
+	if dueDateCustomField == "" || dueSoonTag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Due date reminders require DUE_DATE_CUSTOM_FIELD and DUE_SOON_TAG"})
+		return
+	}
+
+	result, err := app.checkDueDates(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error checking due dates: %v", err), err))
+		errorLogger(err).Errorf("Error checking due dates: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getReportsHandler handles the GET /api/reports endpoint
+func (app *App) getReportsHandler(c *gin.Context) {
+	records, err := GetReports(app.Database, tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve reports", err))
+		errorLogger(err).Errorf("Failed to retrieve reports: %v", err)
+		return
+	}
+
+	reports := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		reports = append(reports, record.toResponse())
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// getReportHandler handles the GET /api/reports/:id endpoint
+func (app *App) getReportHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	record, err := GetReport(app.Database, uint(id), tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, record.toResponse())
+}
+
+// createReportShareHandler handles the POST /api/reports/:id/shares endpoint. The optional "expires_in"
+// duration (e.g. "72h") defaults to 7 days.
+func (app *App) createReportShareHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	var req struct {
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	validFor := defaultReportShareDuration
+	if req.ExpiresIn != "" {
+		if validFor, err = time.ParseDuration(req.ExpiresIn); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_in, expected a duration such as 72h"})
+			return
+		}
+	}
+
+	if _, err := GetReport(app.Database, uint(id), tenantUsername(c.Request.Context())); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	share, err := CreateReportShare(app.Database, uint(id), validFor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		return
+	}
+	log.Infof("Created share link %d for report %d, expiring %s", share.ID, id, share.ExpiresAt)
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// getReportSharesHandler handles the GET /api/reports/:id/shares endpoint
+func (app *App) getReportSharesHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	if _, err := GetReport(app.Database, uint(id), tenantUsername(c.Request.Context())); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	shares, err := GetReportShares(app.Database, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching share links: %v", err), err))
+		errorLogger(err).Errorf("Error fetching share links of report %d: %v", id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// deleteReportShareHandler handles the DELETE /api/reports/:id/shares/:share_id endpoint
+func (app *App) deleteReportShareHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return
+	}
+
+	if _, err := GetReport(app.Database, uint(id), tenantUsername(c.Request.Context())); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err := DeleteReportShare(app.Database, uint(id), uint(shareID)); err != nil {
+		if errors.Is(err, errReportShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error revoking share link: %v", err), err))
+		errorLogger(err).Errorf("Error revoking share link %d: %v", shareID, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sharedReportHandler handles the public GET /share/reports/:token page. It needs no login, the token is
+// the only credential, so unknown and expired tokens are answered alike.
+func (app *App) sharedReportHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+
+	report, share, err := GetSharedReport(app.Database, c.Param("token"))
+	if err != nil {
+		c.String(http.StatusNotFound, "This share link does not exist or has expired.")
+		return
+	}
+
+	var page bytes.Buffer
+	if err := writeReportSharePage(&page, report, share.ExpiresAt); err != nil {
+		c.String(http.StatusInternalServerError, "The report cannot be displayed.")
+		log.Errorf("Error rendering shared report %d: %v", report.ID, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// createReportHandler handles the POST /api/reports endpoint and generates a report immediately.
+// The optional "since" date (YYYY-MM-DD) defaults to the end of the previous report.
+func (app *App) createReportHandler(c *gin.Context) {
+	var req struct {
+		Since string `json:"since"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	since := app.lastReportEnd(c.Request.Context())
+	if req.Since != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.Since, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since date, expected YYYY-MM-DD"})
+			return
+		}
+		since = parsed
+	}
+
+	report, err := app.generateReport(c.Request.Context(), since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error generating report: %v", err), err))
+		errorLogger(err).Errorf("Error generating report: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report.toResponse())
+}
+
+// canAccessDocument fetches a document with the tenant's token before its local data is touched. It answers
+// 404 and returns false if the document does not exist or the tenant cannot see it.
+func (app *App) canAccessDocument(c *gin.Context, documentID int) bool {
+	if _, err := app.Client.GetDocument(c.Request.Context(), documentID); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse("Document not found", err))
+		log.Debugf("Document %d is not accessible: %v", documentID, err)
+		return false
+	}
+	return true
+}
+
+// getOcrPagesHandler handles the GET /api/documents/:id/ocr/pages endpoint
+func (app *App) getOcrPagesHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	if !app.canAccessDocument(c, documentID) {
+		return
+	}
+
+	records, err := GetOcrPageResults(app.Database, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve OCR pages", err))
+		errorLogger(err).Errorf("Failed to retrieve OCR pages for document %d: %v", documentID, err)
+		return
+	}
+
+	pages := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		pages = append(pages, gin.H{
+			"page_index":   record.PageIndex,
+			"text":         record.Text,
+			"blank":        record.Blank,
+			"languages":    splitAndTrim(record.Languages),
+			"needs_review": record.NeedsReview,
+			"confidence":   record.Confidence,
+			"date_added":   record.DateAdded,
+		})
+	}
+
+	c.JSON(http.StatusOK, pages)
+}
+
+// updateOcrPagesHandler handles the PUT /api/documents/:id/ocr/pages endpoint. The body lists the page
+// indexes to keep in their new order, e.g. {"pages": [1, 0]}; unlisted pages are dropped.
+func (app *App) updateOcrPagesHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	if !app.canAccessDocument(c, documentID) {
+		return
+	}
+
+	var req struct {
+		Pages []int `json:"pages" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	records, err := ReorderOcrPageResults(app.Database, documentID, req.Pages)
+	if errors.Is(err, errInvalidPageOrder) {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to update OCR pages", err))
+		errorLogger(err).Errorf("Failed to update OCR pages for document %d: %v", documentID, err)
+		return
+	}
+
+	texts := make([]string, 0, len(records))
+	for _, record := range records {
+		if !record.Blank {
+			texts = append(texts, record.Text)
+		}
+	}
+	// The combined content can be saved to the document through /api/update-documents
+	c.JSON(http.StatusOK, gin.H{"content": strings.Join(texts, "\n\n")})
+}
+
+// getLLMTracesHandler handles the GET /api/documents/:id/llm-traces endpoint
+func (app *App) getLLMTracesHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	if !app.canAccessDocument(c, documentID) {
+		return
+	}
+
+	records, err := GetLLMTraces(app.Database, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve LLM traces", err))
+		errorLogger(err).Errorf("Failed to retrieve LLM traces for document %d: %v", documentID, err)
+		return
+	}
+
+	traces := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		traces = append(traces, gin.H{
+			"id":           record.ID,
+			"model":        record.Model,
+			"prompt":       record.Prompt,
+			"response":     record.Response,
+			"date_created": record.DateCreated,
+		})
+	}
+
+	c.JSON(http.StatusOK, traces)
+}
+
+// getExtractionsHandler handles the GET /api/documents/:id/extractions endpoint
+func (app *App) getExtractionsHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	if !app.canAccessDocument(c, documentID) {
+		return
+	}
+
+	records, err := GetExtractions(app.Database, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve extractions", err))
+		errorLogger(err).Errorf("Failed to retrieve extractions for document %d: %v", documentID, err)
+		return
+	}
+
+	extractions := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		extractions = append(extractions, gin.H{
+			"id":         record.ID,
+			"rows":       json.RawMessage(record.Rows),
+			"date_added": record.DateAdded,
+		})
+	}
+
+	c.JSON(http.StatusOK, extractions)
+}
+
+// createExtractionHandler handles the POST /api/documents/:id/extractions endpoint.
+// The rows are stored locally and, if EXTRACTION_CUSTOM_FIELD is set, written as JSON to that custom field.
+func (app *App) createExtractionHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	docLogger := documentLogger(documentID)
+
+	document, err := app.Client.GetDocument(ctx, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document: %v", err), err))
+		errorLogger(err).Errorf("Error fetching document: %v", err)
+		return
+	}
+
+	documentTypeNames, err := app.documentTypeNames(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document types: %v", err), err))
+		docLogger.Errorf("Error fetching document types: %v", err)
+		return
+	}
+
+	rows, err := app.getExtractedRows(ctx, document, documentTypeNames[document.DocumentTypeID], docLogger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error extracting rows: %v", err), err))
+		docLogger.Errorf("Error extracting rows: %v", err)
+		return
+	}
+
+	record, err := InsertExtraction(app.Database, documentID, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store extraction"})
+		docLogger.Errorf("Failed to store extraction: %v", err)
+		return
+	}
+
+	if extractionCustomField != "" {
+		if err := app.writeExtractionCustomField(ctx, document, record.Rows); err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error writing custom field: %v", err), err))
+			docLogger.Errorf("Error writing extraction custom field: %v", err)
+			return
+		}
+	}
+
+	docLogger.Infof("Extracted %d rows", len(rows))
+	c.JSON(http.StatusOK, gin.H{"id": record.ID, "rows": rows, "date_added": record.DateAdded})
+}
+
+// writeExtractionCustomField stores the JSON encoded rows in the custom field named by EXTRACTION_CUSTOM_FIELD
+func (app *App) writeExtractionCustomField(ctx context.Context, document Document, rowsJSON string) error {
+	customFields, err := app.Client.GetCustomFields(ctx)
+	if err != nil {
+		return err
+	}
+	for _, field := range customFields {
+		if field.Name != extractionCustomField {
+			continue
+		}
+		return app.Client.UpdateDocuments(ctx, []DocumentSuggestion{
+			{
+				ID:                    document.ID,
+				OriginalDocument:      document,
+				SuggestedCustomFields: []CustomFieldValue{{Field: field.ID, Value: rowsJSON}},
+			},
+		}, app.Database, false)
+	}
+	return fmt.Errorf("custom field %s does not exist in paperless-ngx", extractionCustomField)
+}
+
+// Section for local-db actions
+
+func (app *App) getModificationHistoryHandler(c *gin.Context) {
+	// Parse pagination parameters
+	page := 1
+	pageSize := 20
+
+	if p, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(c.DefaultQuery("pageSize", "20")); err == nil && ps > 0 && ps <= 100 {
+		pageSize = ps
+	}
+
+	// Get paginated modifications and total count
+	modifications, total, err := GetPaginatedModifications(app.Database, page, pageSize, tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve modification history", err))
+		errorLogger(err).Errorf("Failed to retrieve modification history: %v", err)
+		return
+	}
+
+	totalPages := (int(total) + pageSize - 1) / pageSize
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       modifications,
+		"totalItems":  total,
+		"totalPages":  totalPages,
+		"currentPage": page,
+		"pageSize":    pageSize,
+	})
+}
+
+func (app *App) undoModificationHandler(c *gin.Context) {
+	id := c.Param("id")
+	modID, err := strconv.Atoi(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse("Invalid modification ID", err))
+		errorLogger(err).Errorf("Invalid modification ID: %v", err)
+		return
+	}
+
+	modification, err := GetModification(app.Database, uint(modID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve modification", err))
+		errorLogger(err).Errorf("Failed to retrieve modification: %v", err)
+		return
+	}
+
+	if modification.Username != tenantUsername(c.Request.Context()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modification not found"})
+		return
+	}
+
+	if modification.Undone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Modification has already been undone"})
+		log.Errorf("Modification has already been undone: %v", id)
+		return
+	}
+
+	// Ok, we're actually doing the update:
+	ctx := c.Request.Context()
+
+	// Make the document suggestions for UpdateDocuments
+	var suggestion DocumentSuggestion
+	suggestion.ID = int(modification.DocumentID)
+	suggestion.OriginalDocument, err = app.Client.GetDocument(ctx, int(modification.DocumentID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve original document", err))
+		errorLogger(err).Errorf("Failed to retrieve original document: %v", err)
+		return
+	}
+	switch modification.ModField {
+	case "title":
+		suggestion.SuggestedTitle = modification.PreviousValue
+	case "tags":
+		var tags []string
+		err := json.Unmarshal([]byte(modification.PreviousValue), &tags)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to unmarshal previous tags", err))
+			errorLogger(err).Errorf("Failed to unmarshal previous tags: %v", err)
+			return
+		}
+		suggestion.SuggestedTags = tags
+	case "content":
+		suggestion.SuggestedContent = modification.PreviousValue
+	case "created", "created_date":
+		suggestion.SuggestedCreatedDate = modification.PreviousValue
+	case "custom_fields":
+		customFields := []CustomFieldValue{}
+		err := json.Unmarshal([]byte(modification.PreviousValue), &customFields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse("Failed to unmarshal previous custom fields", err))
+			errorLogger(err).Errorf("Failed to unmarshal previous custom fields: %v", err)
+			return
+		}
+		if customFields == nil {
+			customFields = []CustomFieldValue{}
+		}
+		suggestion.SuggestedCustomFields = customFields
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid modification field"})
+		log.Errorf("Invalid modification field: %v", modification.ModField)
+		return
+	}
+
+	// Update the document
+	err = app.Client.UpdateDocuments(ctx, []DocumentSuggestion{suggestion}, app.Database, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to update document", err))
+		errorLogger(err).Errorf("Failed to update document: %v", err)
+		return
+	}
+
+	// Successful, so set modification as undone
 	err = SetModificationUndone(app.Database, modification)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark modification as undone"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark modification as undone"})
+		return
+	}
+
+	// Else all was ok
+	c.Status(http.StatusOK)
+}
+
+// Section for the correspondent approval queue
+
+// getPendingCorrespondentsHandler handles the GET /api/pending-correspondents endpoint
+func (app *App) getPendingCorrespondentsHandler(c *gin.Context) {
+	records, err := GetPendingCorrespondents(app.Database, tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve pending correspondents", err))
+		errorLogger(err).Errorf("Failed to retrieve pending correspondents: %v", err)
+		return
+	}
+
+	pending := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		pending = append(pending, gin.H{
+			"id":           record.ID,
+			"name":         record.Name,
+			"document_ids": record.GetDocumentIDs(),
+			"date_added":   record.DateAdded,
+		})
+	}
+
+	c.JSON(http.StatusOK, pending)
+}
+
+// approvePendingCorrespondentHandler handles the POST /api/pending-correspondents/:id/approve endpoint.
+// It creates the correspondent (optionally under a corrected name) and assigns it to all waiting documents.
+func (app *App) approvePendingCorrespondentHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pending correspondent ID"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	// The body is optional; an empty body approves the suggested name as-is
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Invalid request payload: %v", err), err))
+			return
+		}
+	}
+
+	record, err := GetPendingCorrespondent(app.Database, uint(id), tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending correspondent not found"})
+		return
+	}
+
+	name := record.Name
+	if strings.TrimSpace(req.Name) != "" {
+		name = strings.TrimSpace(req.Name)
+	}
+	if isCorrespondentBlacklisted(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Correspondent %q is blacklisted", name)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	correspondents, err := app.Client.GetAllCorrespondents(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching correspondents: %v", err), err))
+		errorLogger(err).Errorf("Error fetching correspondents: %v", err)
+		return
+	}
+	if _, exists := correspondents[name]; !exists {
+		correspondentID, err := app.Client.CreateCorrespondent(ctx, instantiateCorrespondent(name))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error creating correspondent: %v", err), err))
+			errorLogger(err).Errorf("Error creating correspondent with name %s: %v", name, err)
+			return
+		}
+		log.Infof("Created approved correspondent with name %s and ID %d", name, correspondentID)
+	}
+
+	suggestions := []DocumentSuggestion{}
+	for _, documentID := range record.GetDocumentIDs() {
+		document, err := app.Client.GetDocument(ctx, documentID)
+		if err != nil {
+			log.Errorf("Skipping document %d for approved correspondent %s: %v", documentID, name, err)
+			continue
+		}
+		suggestions = append(suggestions, DocumentSuggestion{
+			ID:                     documentID,
+			OriginalDocument:       document,
+			SuggestedCorrespondent: name,
+		})
+	}
+
+	if err := app.Client.UpdateDocuments(ctx, suggestions, app.Database, false); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error updating documents: %v", err), err))
+		errorLogger(err).Errorf("Error updating documents for approved correspondent %s: %v", name, err)
+		return
+	}
+
+	if err := DeletePendingCorrespondent(app.Database, record); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to remove pending correspondent", err))
+		errorLogger(err).Errorf("Failed to remove pending correspondent %d: %v", record.ID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"name": name, "updated_documents": len(suggestions)})
+}
+
+// rejectPendingCorrespondentHandler handles the DELETE /api/pending-correspondents/:id endpoint
+func (app *App) rejectPendingCorrespondentHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pending correspondent ID"})
+		return
+	}
+
+	record, err := GetPendingCorrespondent(app.Database, uint(id), tenantUsername(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending correspondent not found"})
+		return
+	}
+
+	if err := DeletePendingCorrespondent(app.Database, record); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to remove pending correspondent", err))
+		errorLogger(err).Errorf("Failed to remove pending correspondent %d: %v", record.ID, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// suggestFieldHandler handles the POST /api/documents/:id/suggest/:field endpoint
+func (app *App) suggestFieldHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+	field := c.Param("field")
+	if !slices.Contains(suggestionFields, field) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown field %q, expected one of %s", field, strings.Join(suggestionFields, ", "))})
+		return
+	}
+
+	// The body is optional
+	var req struct {
+		Instructions string `json:"instructions"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+	}
+
+	document, err := app.Client.GetDocument(ctx, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document: %v", err), err))
+		errorLogger(err).Errorf("Error fetching document %d: %v", documentID, err)
 		return
 	}
 
-	// Else all was ok
-	c.Status(http.StatusOK)
+	value, err := app.suggestField(ctx, document, field, req.Instructions, documentLogger(documentID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error generating %s: %v", field, err), err))
+		errorLogger(err).Errorf("Error generating %s for document %d: %v", field, documentID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": documentID, "field": field, "value": value})
+}
+
+// refineSuggestionHandler handles the POST /api/documents/:id/refine endpoint
+func (app *App) refineSuggestionHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	var req RefineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		return
+	}
+
+	document, err := app.Client.GetDocument(ctx, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document: %v", err), err))
+		errorLogger(err).Errorf("Error fetching document %d: %v", documentID, err)
+		return
+	}
+
+	suggestion, err := app.refineSuggestion(ctx, document, req, documentLogger(documentID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err.Error(), err))
+		errorLogger(err).Errorf("Error refining the suggestion for document %d: %v", documentID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// getCategoriesHandler handles the GET /api/categories endpoint
+func (app *App) getCategoriesHandler(c *gin.Context) {
+	records, err := GetCategories(app.Database)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve categories", err))
+		errorLogger(err).Errorf("Failed to retrieve categories: %v", err)
+		return
+	}
+
+	categories := make([]map[string]interface{}, 0, len(records))
+	for i := range records {
+		categories = append(categories, records[i].toResponse())
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+// saveCategoryHandler handles the POST /api/categories and PUT /api/categories/:id endpoints
+func (app *App) saveCategoryHandler(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Tag         string `json:"tag"`
+		CustomField string `json:"custom_field"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Description) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload, name and description are required"})
+		return
+	}
+
+	category := Category{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Tag:         strings.TrimSpace(req.Tag),
+		CustomField: strings.TrimSpace(req.CustomField),
+	}
+	status := http.StatusCreated
+	if c.Param("id") != "" {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		category.ID = uint(id)
+		status = http.StatusOK
+	}
+
+	err := SaveCategory(app.Database, &category)
+	if errors.Is(err, errDuplicateCategory) {
+		c.JSON(http.StatusConflict, errorResponse(err.Error(), err))
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to save category", err))
+		errorLogger(err).Errorf("Failed to save category %s: %v", category.Name, err)
+		return
+	}
+
+	c.JSON(status, category.toResponse())
+}
+
+// deleteCategoryHandler handles the DELETE /api/categories/:id endpoint
+func (app *App) deleteCategoryHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	err = DeleteCategory(app.Database, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to delete category", err))
+		errorLogger(err).Errorf("Failed to delete category %d: %v", id, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// classifyDocumentHandler handles the POST /api/documents/:id/classify endpoint.
+// With {"apply": true} the tags and custom fields mapped to the assigned categories are written to paperless-ngx.
+func (app *App) classifyDocumentHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+	var req struct {
+		Apply bool `json:"apply"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	categories, err := GetCategories(app.Database)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve categories", err))
+		errorLogger(err).Errorf("Failed to retrieve categories: %v", err)
+		return
+	}
+	document, err := app.Client.GetDocument(ctx, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document: %v", err), err))
+		errorLogger(err).Errorf("Error fetching document %d: %v", documentID, err)
+		return
+	}
+
+	docLogger := documentLogger(documentID)
+	assigned, err := app.classifyDocument(ctx, document, categories, docLogger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error classifying document: %v", err), err))
+		docLogger.Errorf("Error classifying document: %v", err)
+		return
+	}
+
+	if req.Apply {
+		if err := app.applyCategories(ctx, document, assigned); err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error applying categories: %v", err), err))
+			docLogger.Errorf("Error applying categories: %v", err)
+			return
+		}
+	}
+
+	names := make([]string, 0, len(assigned))
+	for _, category := range assigned {
+		names = append(names, category.Name)
+	}
+	docLogger.Infof("Classified as %v", names)
+	c.JSON(http.StatusOK, gin.H{"id": documentID, "categories": names, "applied": req.Apply})
+}
+
+// compareDocumentsHandler handles the POST /api/compare endpoint and compares the two documents of
+// {"document_ids": [first, second]}, e.g. a contract and its renewal
+func (app *App) compareDocumentsHandler(c *gin.Context) {
+	var req struct {
+		DocumentIDs []int  `json:"document_ids"`
+		Hint        string `json:"hint"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if len(req.DocumentIDs) != 2 || req.DocumentIDs[0] == req.DocumentIDs[1] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected two different document IDs"})
+		return
+	}
+
+	ctx := withPromptHint(c.Request.Context(), req.Hint)
+	documents := make([]Document, 0, len(req.DocumentIDs))
+	for _, documentID := range req.DocumentIDs {
+		document, err := app.Client.GetDocument(ctx, documentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error fetching document %d: %v", documentID, err), err))
+			errorLogger(err).Errorf("Error fetching document %d: %v", documentID, err)
+			return
+		}
+		documents = append(documents, document)
+	}
+
+	comparison, err := app.compareDocuments(ctx, documents[0], documents[1])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error comparing documents: %v", err), err))
+		errorLogger(err).Errorf("Error comparing documents %d and %d: %v", req.DocumentIDs[0], req.DocumentIDs[1], err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// getTenantHandler handles the GET /api/tenant endpoint and tells whether the acting user registered a paperless token
+func (app *App) getTenantHandler(c *gin.Context) {
+	username := tenantUsername(c.Request.Context())
+	user, err := app.tenantUser(c.Request.Context(), username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve user", err))
+		errorLogger(err).Errorf("Failed to retrieve user %s: %v", username, err)
+		return
+	}
+
+	response := gin.H{"username": username, "registered": err == nil}
+	if err == nil {
+		response["date_added"] = user.DateAdded
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// setTenantTokenHandler handles the PUT /api/tenant/token endpoint. The token must belong to the acting user.
+func (app *App) setTenantTokenHandler(c *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A token is required"})
+		return
+	}
+	token := strings.TrimSpace(req.Token)
+	username := tenantUsername(c.Request.Context())
+
+	owner, err := app.Client.currentUsername(withTenant(c.Request.Context(), username, token))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(fmt.Sprintf("Token was rejected by paperless-ngx: %v", err), err))
+		return
+	}
+	if !strings.EqualFold(owner, username) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token belongs to paperless user %q, not %q", owner, username)})
+		return
+	}
+
+	if err := app.saveTenantUser(c.Request.Context(), username, token); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to store token", err))
+		errorLogger(err).Errorf("Failed to store token of user %s: %v", username, err)
+		return
+	}
+	log.Infof("Registered paperless token of user %s", username)
+	c.Status(http.StatusNoContent)
+}
+
+// deleteTenantTokenHandler handles the DELETE /api/tenant/token endpoint
+func (app *App) deleteTenantTokenHandler(c *gin.Context) {
+	username := tenantUsername(c.Request.Context())
+	if err := app.deleteTenantUser(c.Request.Context(), username); errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No token registered"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to remove token", err))
+		errorLogger(err).Errorf("Failed to remove token of user %s: %v", username, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// paperlessProxyHandler handles the GET /api/paperless/*path endpoint. It forwards allowlisted read-only
+// requests to the paperless API with the server-side token, so the browser never needs a paperless token.
+func (app *App) paperlessProxyHandler(c *gin.Context) {
+	apiPath, allowed := paperlessProxyPath(c.Param("path"))
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Path is not allowed by PAPERLESS_PROXY_PATHS"})
+		return
+	}
+
+	target := "api/" + apiPath
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	// Answers like 403 are passed on, so the UI can tell a missing permission from an unreachable paperless-ngx
+	resp, err := app.Client.DoRaw(c.Request.Context(), "GET", target, nil)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse("Failed to reach paperless-ngx", err))
+		errorLogger(err).Errorf("Failed to proxy %s to paperless-ngx: %v", apiPath, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range proxiedResponseHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			c.Header(header, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		log.Warnf("Failed to proxy the response of %s: %v", apiPath, err)
+	}
+}
+
+// getThumbnailHandler handles the GET /api/documents/:id/thumbnail endpoint
+func (app *App) getThumbnailHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	thumb, err := app.documentThumbnail(c.Request.Context(), documentID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse("Failed to fetch thumbnail", err))
+		errorLogger(err).Errorf("Failed to fetch thumbnail for document %d: %v", documentID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, thumb.ContentType, thumb.Data)
+}
+
+// getPromptExamplesHandler handles the GET /api/prompts/examples endpoint and returns the examples of the user
+func getPromptExamplesHandler(c *gin.Context) {
+	templateMutex.RLock()
+	defer templateMutex.RUnlock()
+	examples := tenantPromptExamples(c.Request.Context())
+	if examples == nil {
+		examples = map[string]string{}
+	}
+	c.JSON(http.StatusOK, examples)
+}
+
+// generatePromptExamplesHandler handles the POST /api/prompts/examples endpoint
+func (app *App) generatePromptExamplesHandler(c *gin.Context) {
+	var options PromptExampleOptions
+	if err := c.ShouldBindJSON(&options); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	examples, count, err := app.generatePromptExamples(c.Request.Context(), options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Failed to generate prompt examples: %v", err), err))
+		errorLogger(err).Errorf("Failed to generate prompt examples: %v", err)
+		return
+	}
+	if err := savePromptExamples(c.Request.Context(), promptExamplesDir, examples); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to store prompt examples", err))
+		errorLogger(err).Errorf("Failed to store prompt examples: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"documents": count, "examples": examples})
+}
+
+// getFailuresHandler handles the GET /api/failures endpoint
+func (app *App) getFailuresHandler(c *gin.Context) {
+	failures, err := app.processingFailures(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to retrieve failed documents", err))
+		errorLogger(err).Errorf("Failed to retrieve failed documents: %v", err)
+		return
+	}
+	c.JSON(http.StatusOK, failures)
+}
+
+// retryFailureHandler handles the POST /api/failures/:document_id/retry endpoint
+func (app *App) retryFailureHandler(c *gin.Context) {
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	queues, err := app.retryFailedDocument(c.Request.Context(), documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse("Failed to re-queue document", err))
+		errorLogger(err).Errorf("Failed to re-queue document %d: %v", documentID, err)
+		return
+	}
+	if len(queues) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed processing recorded for this document"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"document_id": documentID, "queues": queues})
+}
+
+// getBackgroundTasksHandler handles the GET /api/background endpoint
+func getBackgroundTasksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, scheduler.statuses())
+}
+
+// pauseBackgroundTasksHandler handles the POST /api/background/pause endpoint
+func pauseBackgroundTasksHandler(c *gin.Context) {
+	setBackgroundTasksPaused(c, true)
+}
+
+// resumeBackgroundTasksHandler handles the POST /api/background/resume endpoint
+func resumeBackgroundTasksHandler(c *gin.Context) {
+	setBackgroundTasksPaused(c, false)
+}
+
+// setBackgroundTasksPaused pauses or resumes the task named in the optional body, or all tasks
+func setBackgroundTasksPaused(c *gin.Context, paused bool) {
+	var req struct {
+		Task string `json:"task"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if len(scheduler.tasks) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Background processing is disabled"})
+		return
+	}
+
+	statuses, err := scheduler.setPaused(req.Task, paused)
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse(err.Error(), err))
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// bulkTagsHandler handles the POST /api/tags/bulk endpoint
+func (app *App) bulkTagsHandler(c *gin.Context) {
+	var req BulkTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error(), err))
+		return
+	}
+
+	documentIDs, err := app.bulkTag(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error editing tags: %v", err), err))
+		errorLogger(err).Errorf("Error bulk editing tags: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"documents": documentIDs})
+}
+
+// suggestTagTaxonomyHandler handles the POST /api/tags/taxonomy endpoint
+func (app *App) suggestTagTaxonomyHandler(c *gin.Context) {
+	var req struct {
+		SampleSize int `json:"sample_size"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	operations, err := app.suggestTagTaxonomy(c.Request.Context(), req.SampleSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(fmt.Sprintf("Error suggesting a tag taxonomy: %v", err), err))
+		errorLogger(err).Errorf("Error suggesting a tag taxonomy: %v", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"operations": operations})
 }
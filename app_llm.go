@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"image"
+	"math"
 	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"unicode"
 
 	_ "image/jpeg"
 
@@ -16,8 +20,22 @@ import (
 	"github.com/tmc/langchaingo/llms"
 )
 
-// getSuggestedCorrespondent generates a suggested correspondent for a document using the LLM
-func (app *App) getSuggestedCorrespondent(ctx context.Context, content string, suggestedTitle string, availableCorrespondents []string, correspondentBlackList []string) (string, error) {
+// promptHintKey is the context key of the user-provided hint for the prompts of a document
+type promptHintKey struct{}
+
+// withPromptHint attaches a user-provided hint to the context, which is passed to all prompt templates as Hint
+func withPromptHint(ctx context.Context, hint string) context.Context {
+	return context.WithValue(ctx, promptHintKey{}, strings.TrimSpace(hint))
+}
+
+// promptHint returns the hint attached to the context, or an empty string
+func promptHint(ctx context.Context) string {
+	hint, _ := ctx.Value(promptHintKey{}).(string)
+	return hint
+}
+
+// renderCorrespondentPrompt renders the correspondent template with the content truncated to the token limit
+func renderCorrespondentPrompt(ctx context.Context, content string, suggestedTitle string, availableCorrespondents []string, correspondentBlackList []string, documentType string) (string, error) {
 	likelyLanguage := getLikelyLanguage()
 
 	templateMutex.RLock()
@@ -29,17 +47,20 @@ func (app *App) getSuggestedCorrespondent(ctx context.Context, content string, s
 		"AvailableCorrespondents": availableCorrespondents,
 		"BlackList":               correspondentBlackList,
 		"Title":                   suggestedTitle,
+		"Hint":                    promptHint(ctx),
+		"Examples":                tenantPromptExamples(ctx)["correspondent"],
 	}
 
+	correspondentTemplate := promptTemplate(ctx, correspondentTemplate, "correspondent_prompt.tmpl", documentType)
 	availableTokens, err := getAvailableTokensForContent(correspondentTemplate, templateData)
 	if err != nil {
-		return "", fmt.Errorf("error calculating available tokens: %v", err)
+		return "", fmt.Errorf("error calculating available tokens: %w", err)
 	}
 
 	// Truncate content if needed
 	truncatedContent, err := truncateContentByTokens(content, availableTokens)
 	if err != nil {
-		return "", fmt.Errorf("error truncating content: %v", err)
+		return "", fmt.Errorf("error truncating content: %w", err)
 	}
 
 	// Execute template with truncated content
@@ -47,10 +68,18 @@ func (app *App) getSuggestedCorrespondent(ctx context.Context, content string, s
 	templateData["Content"] = truncatedContent
 	err = correspondentTemplate.Execute(&promptBuffer, templateData)
 	if err != nil {
-		return "", fmt.Errorf("error executing correspondent template: %v", err)
+		return "", fmt.Errorf("error executing correspondent template: %w", err)
 	}
 
-	prompt := promptBuffer.String()
+	return promptBuffer.String(), nil
+}
+
+// getSuggestedCorrespondent generates a suggested correspondent for a document using the LLM
+func (app *App) getSuggestedCorrespondent(ctx context.Context, content string, suggestedTitle string, availableCorrespondents []string, correspondentBlackList []string, documentType string) (string, error) {
+	prompt, err := renderCorrespondentPrompt(ctx, content, suggestedTitle, availableCorrespondents, correspondentBlackList, documentType)
+	if err != nil {
+		return "", err
+	}
 	log.Debugf("Correspondent suggestion prompt: %s", prompt)
 
 	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
@@ -64,30 +93,137 @@ func (app *App) getSuggestedCorrespondent(ctx context.Context, content string, s
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("error getting response from LLM: %v", err)
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
 	}
 
 	response := stripReasoning(strings.TrimSpace(completion.Choices[0].Content))
 	return response, nil
 }
 
-// getSuggestedTags generates suggested tags for a document using the LLM
-func (app *App) getSuggestedTags(
-	ctx context.Context,
-	content string,
-	suggestedTitle string,
-	availableTags []string,
-	originalTags []string,
-	logger *logrus.Entry) ([]string, error) {
+// getSuggestedCorrespondentCandidates asks the LLM for up to three ranked correspondent candidates with confidences
+func (app *App) getSuggestedCorrespondentCandidates(ctx context.Context, content string, suggestedTitle string, availableCorrespondents []string, correspondentBlackList []string, documentType string) ([]CorrespondentCandidate, error) {
+	prompt, err := renderCorrespondentPrompt(ctx, content, suggestedTitle, availableCorrespondents, correspondentBlackList, documentType)
+	if err != nil {
+		return nil, err
+	}
+	// The user template asks for a single name, so override the answer format explicitly
+	prompt += "\n" + correspondentCandidatesInstruction
+	log.Debugf("Correspondent candidates prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseCorrespondentCandidates(response)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCorrespondentCandidates(response)
+}
+
+// getSuggestedCorrespondentWithRationale generates a suggested correspondent together with a short reason
+func (app *App) getSuggestedCorrespondentWithRationale(ctx context.Context, content string, suggestedTitle string, availableCorrespondents []string, correspondentBlackList []string, documentType string) (string, string, error) {
+	prompt, err := renderCorrespondentPrompt(ctx, content, suggestedTitle, availableCorrespondents, correspondentBlackList, documentType)
+	if err != nil {
+		return "", "", err
+	}
+	// The user template asks for a single name, so override the answer format explicitly
+	prompt += "\n" + correspondentRationaleInstruction
+	log.Debugf("Correspondent suggestion prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseRationaleEntries(response, "correspondents")
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return "", "", err
+	}
+
+	entries, err := parseRationaleEntries(response, "correspondents")
+	if err != nil {
+		return "", "", err
+	}
+	if len(entries) == 0 {
+		return "", "", nil
+	}
+	// Only one correspondent can be applied, the model lists the most likely first
+	return entries[0].Name, entries[0].Reason, nil
+}
+
+// parseCorrespondentCandidates parses the structured LLM answer into candidates sorted by descending confidence
+func parseCorrespondentCandidates(response string) ([]CorrespondentCandidate, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed struct {
+		Candidates []CorrespondentCandidate `json:"candidates"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		// Some models answer with the bare array
+		if arrErr := json.Unmarshal([]byte(response), &parsed.Candidates); arrErr != nil {
+			return nil, fmt.Errorf("error parsing correspondent candidates: %w", err)
+		}
+	}
+
+	candidates := make([]CorrespondentCandidate, 0, len(parsed.Candidates))
+	for _, candidate := range parsed.Candidates {
+		candidate.Name = strings.TrimSpace(candidate.Name)
+		if candidate.Name == "" {
+			continue
+		}
+		candidate.Confidence = math.Max(0, math.Min(1, candidate.Confidence))
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	if len(candidates) > maxCorrespondentCandidates {
+		candidates = candidates[:maxCorrespondentCandidates]
+	}
+	return candidates, nil
+}
+
+// selectCorrespondentCandidate returns the top candidate if it leads the runner-up by at least margin, otherwise an empty string.
+// A missing runner-up counts as confidence 0.
+func selectCorrespondentCandidate(candidates []CorrespondentCandidate, margin float64) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	runnerUp := 0.0
+	if len(candidates) > 1 {
+		runnerUp = candidates[1].Confidence
+	}
+	if candidates[0].Confidence-runnerUp < margin {
+		return ""
+	}
+	return candidates[0].Name
+}
+
+// withoutStatusTags removes the paperless-gpt workflow and status tags from a list of tags
+func withoutStatusTags(tags []string) []string {
+	current := settings()
+	tags = removeTagFromList(tags, current.ManualTag)
+	tags = removeTagFromList(tags, current.AutoTag)
+	tags = removeTagFromList(tags, current.AutoOcrTag)
+	for _, statusTag := range statusTags() {
+		tags = removeTagFromList(tags, statusTag)
+	}
+	return tags
+}
+
+// renderTagPrompt renders the tag template with the content truncated to the token limit.
+// The paperless-gpt workflow and status tags are removed from the available tags, which are returned as offered to the LLM.
+func renderTagPrompt(ctx context.Context, content string, suggestedTitle string, availableTags []string, originalTags []string, documentType string) (string, []string, error) {
 	likelyLanguage := getLikelyLanguage()
 
 	templateMutex.RLock()
 	defer templateMutex.RUnlock()
 
-	// Remove all paperless-gpt related tags from available tags
-	availableTags = removeTagFromList(availableTags, manualTag)
-	availableTags = removeTagFromList(availableTags, autoTag)
-	availableTags = removeTagFromList(availableTags, autoOcrTag)
+	availableTags = withoutStatusTags(availableTags)
 
 	// Get available tokens for content
 	templateData := map[string]interface{}{
@@ -95,19 +231,24 @@ func (app *App) getSuggestedTags(
 		"AvailableTags": availableTags,
 		"OriginalTags":  originalTags,
 		"Title":         suggestedTitle,
+		"TagTree":       "",
+		"Hint":          promptHint(ctx),
+		"Examples":      tenantPromptExamples(ctx)["tags"],
+	}
+	if tagHierarchySeparator != "" {
+		templateData["TagTree"] = renderTagTree(availableTags, tagHierarchySeparator)
 	}
 
+	tagTemplate := promptTemplate(ctx, tagTemplate, "tag_prompt.tmpl", documentType)
 	availableTokens, err := getAvailableTokensForContent(tagTemplate, templateData)
 	if err != nil {
-		logger.Errorf("Error calculating available tokens: %v", err)
-		return nil, fmt.Errorf("error calculating available tokens: %v", err)
+		return "", nil, fmt.Errorf("error calculating available tokens: %w", err)
 	}
 
 	// Truncate content if needed
 	truncatedContent, err := truncateContentByTokens(content, availableTokens)
 	if err != nil {
-		logger.Errorf("Error truncating content: %v", err)
-		return nil, fmt.Errorf("error truncating content: %v", err)
+		return "", nil, fmt.Errorf("error truncating content: %w", err)
 	}
 
 	// Execute template with truncated content
@@ -115,35 +256,97 @@ func (app *App) getSuggestedTags(
 	templateData["Content"] = truncatedContent
 	err = tagTemplate.Execute(&promptBuffer, templateData)
 	if err != nil {
-		logger.Errorf("Error executing tag template: %v", err)
-		return nil, fmt.Errorf("error executing tag template: %v", err)
+		return "", nil, fmt.Errorf("error executing tag template: %w", err)
 	}
 
-	prompt := promptBuffer.String()
+	return promptBuffer.String(), availableTags, nil
+}
+
+// getSuggestedTags generates suggested tags for a document using the LLM
+func (app *App) getSuggestedTags(
+	ctx context.Context,
+	content string,
+	suggestedTitle string,
+	availableTags []string,
+	originalTags []string,
+	documentType string,
+	logger *logrus.Entry) ([]string, error) {
+	prompt, availableTags, err := renderTagPrompt(ctx, content, suggestedTitle, availableTags, originalTags, documentType)
+	if err != nil {
+		logger.Errorf("Error rendering tag prompt: %v", err)
+		return nil, err
+	}
 	logger.Debugf("Tag suggestion prompt: %s", prompt)
 
-	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
-		{
-			Parts: []llms.ContentPart{
-				llms.TextContent{
-					Text: prompt,
-				},
-			},
-			Role: llms.ChatMessageTypeHuman,
-		},
-	})
+	response, err := app.generateValidated(ctx, prompt, validateTagList)
 	if err != nil {
-		logger.Errorf("Error getting response from LLM: %v", err)
-		return nil, fmt.Errorf("error getting response from LLM: %v", err)
+		logger.Errorf("Error getting tags from LLM: %v", err)
+		return nil, err
 	}
 
-	response := stripReasoning(completion.Choices[0].Content)
+	response = stripReasoning(response)
 
 	suggestedTags := strings.Split(response, ",")
 	for i, tag := range suggestedTags {
 		suggestedTags[i] = strings.TrimSpace(tag)
 	}
 
+	return filterSuggestedTags(suggestedTags, availableTags, originalTags), nil
+}
+
+// getSuggestedTagsWithRationale generates suggested tags together with a short reason per tag
+func (app *App) getSuggestedTagsWithRationale(
+	ctx context.Context,
+	content string,
+	suggestedTitle string,
+	availableTags []string,
+	originalTags []string,
+	documentType string,
+	logger *logrus.Entry) ([]string, map[string]string, error) {
+	prompt, availableTags, err := renderTagPrompt(ctx, content, suggestedTitle, availableTags, originalTags, documentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The user template asks for a comma-separated list, so override the answer format explicitly
+	prompt += "\n" + tagRationaleInstruction
+	logger.Debugf("Tag suggestion prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseRationales(response, "tags")
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rationales, err := parseRationales(response, "tags")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	suggestedTags := make([]string, 0, len(rationales))
+	for tag := range rationales {
+		suggestedTags = append(suggestedTags, tag)
+	}
+	filteredTags := filterSuggestedTags(suggestedTags, availableTags, originalTags)
+
+	// Key the reasons by the tag names as they exist in paperless-ngx
+	tagRationales := make(map[string]string, len(filteredTags))
+	for _, tag := range filteredTags {
+		for suggestedTag, reason := range rationales {
+			if strings.EqualFold(tag, suggestedTag) {
+				tagRationales[tag] = reason
+				break
+			}
+		}
+	}
+	return filteredTags, tagRationales, nil
+}
+
+// filterSuggestedTags adds the original tags to the suggestion and keeps only tags that exist in paperless-ngx
+func filterSuggestedTags(suggestedTags []string, availableTags []string, originalTags []string) []string {
+	// Map bare leaf names such as "invoices" to hierarchical tags such as "finance/invoices"
+	suggestedTags = resolveTagPaths(suggestedTags, availableTags, tagHierarchySeparator)
 	// append the original tags to the suggested tags
 	suggestedTags = append(suggestedTags, originalTags...)
 	// Remove duplicates
@@ -153,6 +356,11 @@ func (app *App) getSuggestedTags(
 	// Filter out tags that are not in the available tags list
 	filteredTags := []string{}
 	for _, tag := range suggestedTags {
+		// Status tags are hidden from the LLM but must survive on the document
+		if slices.Contains(statusTags(), tag) && slices.Contains(originalTags, tag) {
+			filteredTags = append(filteredTags, tag)
+			continue
+		}
 		for _, availableTag := range availableTags {
 			if strings.EqualFold(tag, availableTag) {
 				filteredTags = append(filteredTags, availableTag)
@@ -161,35 +369,104 @@ func (app *App) getSuggestedTags(
 		}
 	}
 
-	return filteredTags, nil
+	return filteredTags
+}
+
+// rationaleEntry is a suggested name with the reason the LLM gave for it
+type rationaleEntry struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// parseRationaleEntries parses a structured LLM answer of the form {"<key>": [{"name": ..., "reason": ...}]}
+// into its entries in the order of the answer, without empty names
+func parseRationaleEntries(response string, key string) ([]rationaleEntry, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed map[string][]rationaleEntry
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing %s with rationale: %w", key, err)
+	}
+
+	entries := make([]rationaleEntry, 0, len(parsed[key]))
+	for _, entry := range parsed[key] {
+		if name := strings.TrimSpace(entry.Name); name != "" {
+			entries = append(entries, rationaleEntry{Name: name, Reason: strings.TrimSpace(entry.Reason)})
+		}
+	}
+	return entries, nil
+}
+
+// parseRationales parses a structured LLM answer like parseRationaleEntries into a name to reason map
+func parseRationales(response string, key string) (map[string]string, error) {
+	entries, err := parseRationaleEntries(response, key)
+	if err != nil {
+		return nil, err
+	}
+	rationales := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		rationales[entry.Name] = entry.Reason
+	}
+	return rationales, nil
+}
+
+// doOCRViaLLM transcribes a page image. The OCR prompt is rendered for every page with the document context.
+func (app *App) doOCRViaLLM(ctx context.Context, jpegBytes []byte, page ocrPageContext, logger *logrus.Entry) (string, error) {
+	model, provider, modelName := app.visionModel(ctx)
+	return app.transcribePage(ctx, model, provider, modelName, jpegBytes, page, logger)
 }
 
-func (app *App) doOCRViaLLM(ctx context.Context, jpegBytes []byte, logger *logrus.Entry) (string, error) {
+// transcribePage transcribes a page image with the given vision model
+func (app *App) transcribePage(ctx context.Context, model llms.Model, provider string, modelName string, jpegBytes []byte, page ocrPageContext, logger *logrus.Entry) (string, error) {
 	templateMutex.RLock()
 	defer templateMutex.RUnlock()
 	likelyLanguage := getLikelyLanguage()
 
+	pageTemplate := ocrTemplate
+	if page.Handwritten {
+		pageTemplate = handwritingTemplate
+	}
+
 	var promptBuffer bytes.Buffer
-	err := ocrTemplate.Execute(&promptBuffer, map[string]interface{}{
-		"Language": likelyLanguage,
+	err := pageTemplate.Execute(&promptBuffer, map[string]interface{}{
+		"Language":          likelyLanguage,
+		"Title":             page.Title,
+		"Correspondent":     page.Correspondent,
+		"DocumentType":      page.DocumentType,
+		"PageNumber":        page.PageNumber,
+		"TotalPages":        page.TotalPages,
+		"DocumentLanguages": strings.Join(page.Languages, ", "),
 	})
 	if err != nil {
-		return "", fmt.Errorf("error executing tag template: %v", err)
+		return "", fmt.Errorf("error executing OCR template: %w", err)
 	}
 
 	prompt := promptBuffer.String()
+	if hint := ocrLanguageHint(pageTemplate, page.Languages); hint != "" {
+		prompt += "\n\n" + hint
+	}
+
+	cacheKey := ocrCacheKey(jpegBytes, provider, modelName, prompt)
+	if text, ok := app.cachedOCR(ctx, cacheKey); ok {
+		logger.Debug("Using cached OCR response")
+		return text, nil
+	}
 
 	// Log the image dimensions
 	img, _, err := image.Decode(bytes.NewReader(jpegBytes))
 	if err != nil {
-		return "", fmt.Errorf("error decoding image: %v", err)
+		return "", fmt.Errorf("error decoding image: %w", err)
 	}
 	bounds := img.Bounds()
 	logger.Debugf("Image dimensions: %dx%d", bounds.Dx(), bounds.Dy())
 
 	// If not OpenAI then use binary part for image, otherwise, use the ImageURL part with encoding from https://platform.openai.com/docs/guides/vision
 	var parts []llms.ContentPart
-	if strings.ToLower(visionLlmProvider) != "openai" {
+	if strings.ToLower(provider) != "openai" {
 		// Log image size in kilobytes
 		logger.Debugf("Image size: %d KB", len(jpegBytes)/1024)
 		parts = []llms.ContentPart{
@@ -207,23 +484,24 @@ func (app *App) doOCRViaLLM(ctx context.Context, jpegBytes []byte, logger *logru
 	}
 
 	// Convert the image to text
-	completion, err := app.VisionLLM.GenerateContent(ctx, []llms.MessageContent{
+	completion, err := model.GenerateContent(ctx, []llms.MessageContent{
 		{
 			Parts: parts,
 			Role:  llms.ChatMessageTypeHuman,
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("error getting response from LLM: %v", err)
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
 	}
 
 	result := completion.Choices[0].Content
 	fmt.Println(result)
+	app.cacheOCR(ctx, cacheKey, result)
 	return result, nil
 }
 
 // getSuggestedTitle generates a suggested title for a document using the LLM
-func (app *App) getSuggestedTitle(ctx context.Context, content string, originalTitle string, logger *logrus.Entry) (string, error) {
+func (app *App) getSuggestedTitle(ctx context.Context, content string, originalTitle string, documentType string, logger *logrus.Entry) (string, error) {
 	likelyLanguage := getLikelyLanguage()
 
 	templateMutex.RLock()
@@ -234,19 +512,22 @@ func (app *App) getSuggestedTitle(ctx context.Context, content string, originalT
 		"Language": likelyLanguage,
 		"Content":  content,
 		"Title":    originalTitle,
+		"Hint":     promptHint(ctx),
+		"Examples": tenantPromptExamples(ctx)["title"],
 	}
 
+	titleTemplate := promptTemplate(ctx, titleTemplate, "title_prompt.tmpl", documentType)
 	availableTokens, err := getAvailableTokensForContent(titleTemplate, templateData)
 	if err != nil {
 		logger.Errorf("Error calculating available tokens: %v", err)
-		return "", fmt.Errorf("error calculating available tokens: %v", err)
+		return "", fmt.Errorf("error calculating available tokens: %w", err)
 	}
 
 	// Truncate content if needed
 	truncatedContent, err := truncateContentByTokens(content, availableTokens)
 	if err != nil {
 		logger.Errorf("Error truncating content: %v", err)
-		return "", fmt.Errorf("error truncating content: %v", err)
+		return "", fmt.Errorf("error truncating content: %w", err)
 	}
 
 	// Execute template with truncated content
@@ -255,7 +536,7 @@ func (app *App) getSuggestedTitle(ctx context.Context, content string, originalT
 	err = titleTemplate.Execute(&promptBuffer, templateData)
 
 	if err != nil {
-		return "", fmt.Errorf("error executing title template: %v", err)
+		return "", fmt.Errorf("error executing title template: %w", err)
 	}
 
 	prompt := promptBuffer.String()
@@ -272,24 +553,220 @@ func (app *App) getSuggestedTitle(ctx context.Context, content string, originalT
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("error getting response from LLM: %v", err)
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
 	}
 	result := stripReasoning(completion.Choices[0].Content)
 	return strings.TrimSpace(strings.Trim(result, "\"")), nil
 }
 
+// getSuggestedSelectOption lets the LLM pick one of the options of a select custom field.
+// The answer is fuzzy matched to the closest option; ok is false if the LLM found no fitting option.
+func (app *App) getSuggestedSelectOption(ctx context.Context, field CustomField, content string, title string, documentType string, logger *logrus.Entry) (option SelectOption, ok bool, err error) {
+	likelyLanguage := getLikelyLanguage()
+
+	templateMutex.RLock()
+	defer templateMutex.RUnlock()
+
+	labels := make([]string, 0, len(field.ExtraData.SelectOptions))
+	for _, selectOption := range field.ExtraData.SelectOptions {
+		labels = append(labels, selectOption.Label)
+	}
+
+	templateData := map[string]interface{}{
+		"Language":  likelyLanguage,
+		"FieldName": field.Name,
+		"Options":   labels,
+		"Title":     title,
+		"Hint":      promptHint(ctx),
+	}
+
+	customFieldTemplate := promptTemplate(ctx, customFieldTemplate, "custom_field_prompt.tmpl", documentType)
+	availableTokens, err := getAvailableTokensForContent(customFieldTemplate, templateData)
+	if err != nil {
+		return SelectOption{}, false, fmt.Errorf("error calculating available tokens: %w", err)
+	}
+
+	truncatedContent, err := truncateContentByTokens(content, availableTokens)
+	if err != nil {
+		return SelectOption{}, false, fmt.Errorf("error truncating content: %w", err)
+	}
+
+	var promptBuffer bytes.Buffer
+	templateData["Content"] = truncatedContent
+	err = customFieldTemplate.Execute(&promptBuffer, templateData)
+	if err != nil {
+		return SelectOption{}, false, fmt.Errorf("error executing custom field template: %w", err)
+	}
+
+	prompt := promptBuffer.String()
+	logger.Debugf("Custom field %s suggestion prompt: %s", field.Name, prompt)
+
+	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
+		{
+			Parts: []llms.ContentPart{
+				llms.TextContent{
+					Text: prompt,
+				},
+			},
+			Role: llms.ChatMessageTypeHuman,
+		},
+	})
+	if err != nil {
+		return SelectOption{}, false, fmt.Errorf("error getting response from LLM: %w", err)
+	}
+
+	response := strings.Trim(stripReasoning(completion.Choices[0].Content), "\"'`. ")
+	option, ok = matchSelectOption(response, field.ExtraData.SelectOptions)
+	if !ok {
+		logger.Debugf("LLM answer '%s' for custom field %s matches no option", response, field.Name)
+	}
+	return option, ok, nil
+}
+
+// matchSelectOption finds the select option closest to the given label, tolerating case, punctuation and small typos
+func matchSelectOption(label string, options []SelectOption) (SelectOption, bool) {
+	normalizedLabel := normalizeLabel(label)
+	if normalizedLabel == "" || normalizedLabel == "none" {
+		return SelectOption{}, false
+	}
+
+	for _, option := range options {
+		if strings.EqualFold(option.Label, label) {
+			return option, true
+		}
+	}
+	for _, option := range options {
+		if normalizeLabel(option.Label) == normalizedLabel {
+			return option, true
+		}
+	}
+
+	// Fall back to the option with the smallest edit distance, accepting at most a third of the label length
+	bestIndex, bestDistance := -1, 0
+	for i, option := range options {
+		distance := levenshtein(normalizeLabel(option.Label), normalizedLabel)
+		if bestIndex == -1 || distance < bestDistance {
+			bestIndex, bestDistance = i, distance
+		}
+	}
+	if bestIndex != -1 && bestDistance*3 <= len([]rune(normalizedLabel)) {
+		return options[bestIndex], true
+	}
+	return SelectOption{}, false
+}
+
+// normalizeLabel lowercases a label and strips everything but letters and digits
+func normalizeLabel(label string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(label) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// getExtractedRows asks the LLM to extract each repeated entry of a document (e.g. invoices on a statement) as a row
+func (app *App) getExtractedRows(ctx context.Context, document Document, documentType string, logger *logrus.Entry) ([]map[string]interface{}, error) {
+	ctx = withTraceDocument(ctx, document.ID)
+	likelyLanguage := getLikelyLanguage()
+
+	templateMutex.RLock()
+	defer templateMutex.RUnlock()
+
+	templateData := map[string]interface{}{
+		"Language": likelyLanguage,
+		"Title":    document.Title,
+		"Hint":     promptHint(ctx),
+	}
+
+	extractionTemplate := promptTemplate(ctx, extractionTemplate, "extraction_prompt.tmpl", documentType)
+	availableTokens, err := getAvailableTokensForContent(extractionTemplate, templateData)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating available tokens: %w", err)
+	}
+
+	truncatedContent, err := truncateContentByTokens(normalizeContent(document.Content), availableTokens)
+	if err != nil {
+		return nil, fmt.Errorf("error truncating content: %w", err)
+	}
+
+	var promptBuffer bytes.Buffer
+	templateData["Content"] = truncatedContent
+	err = extractionTemplate.Execute(&promptBuffer, templateData)
+	if err != nil {
+		return nil, fmt.Errorf("error executing extraction template: %w", err)
+	}
+
+	prompt := promptBuffer.String()
+	logger.Debugf("Extraction prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, validateExtractedRows, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExtractedRows(response)
+}
+
+// parseExtractedRows parses the LLM answer into rows, accepting {"rows": [...]} as well as a bare array
+func parseExtractedRows(response string) ([]map[string]interface{}, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		if arrErr := json.Unmarshal([]byte(response), &parsed.Rows); arrErr != nil {
+			return nil, fmt.Errorf("error parsing extracted rows: %w", err)
+		}
+	}
+	if parsed.Rows == nil {
+		parsed.Rows = []map[string]interface{}{}
+	}
+	return parsed.Rows, nil
+}
+
 // generateDocumentSuggestions generates suggestions for a set of documents
 func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionRequest GenerateSuggestionsRequest, logger *logrus.Entry) ([]DocumentSuggestion, error) {
+	ctx = withPromptHint(ctx, suggestionRequest.Hint)
+
 	// Fetch all available tags from paperless-ngx
 	availableTagsMap, err := app.Client.GetAllTags(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch available tags: %v", err)
+		return nil, fmt.Errorf("failed to fetch available tags: %w", err)
 	}
 
 	// Prepare a list of tag names
 	availableTagNames := make([]string, 0, len(availableTagsMap))
 	for tagName := range availableTagsMap {
-		if tagName == manualTag {
+		if tagName == settings().ManualTag {
 			continue
 		}
 		availableTagNames = append(availableTagNames, tagName)
@@ -298,7 +775,7 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 	// Prepare a list of document correspodents
 	availableCorrespondentsMap, err := app.Client.GetAllCorrespondents(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch available correspondents: %v", err)
+		return nil, fmt.Errorf("failed to fetch available correspondents: %w", err)
 	}
 
 	// Prepare a list of correspondent names
@@ -307,9 +784,73 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 		availableCorrespondentNames = append(availableCorrespondentNames, correspondentName)
 	}
 
+	// Prepare the select custom fields the LLM should fill
+	var selectFields []CustomField
+	if suggestionRequest.GenerateCustomFields && len(selectCustomFields) > 0 {
+		customFields, err := app.Client.GetCustomFields(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch custom fields: %w", err)
+		}
+		for _, field := range customFields {
+			if !slices.Contains(selectCustomFields, field.Name) {
+				continue
+			}
+			if field.DataType != "select" {
+				logger.Warnf("Custom field %s is of type %s, only select fields are supported", field.Name, field.DataType)
+				continue
+			}
+			selectFields = append(selectFields, field)
+		}
+	}
+
+	// Prepare the date custom field that receives detected due dates
+	var dueDateField *CustomField
+	if suggestionRequest.GenerateCustomFields && dueDateCustomField != "" {
+		field, err := app.dueDateField(ctx)
+		if err != nil {
+			logger.Warnf("Skipping due date detection: %v", err)
+		} else {
+			dueDateField = &field
+		}
+	}
+
+	// Prepare the custom fields of CUSTOM_FIELD_MAPPING that receive suggestion outputs
+	var mappedFields []mappedField
+	if suggestionRequest.GenerateCustomFields && mapsSuggestionOutputs() {
+		customFields, err := app.Client.GetCustomFields(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch custom fields: %w", err)
+		}
+		mappedFields = resolveFieldMapping(customFields, isSuggestionOutput, logger)
+	}
+
+	// Prepare the custom field that keeps the original title of translated titles
+	var originalTitleField *CustomField
+	if suggestionRequest.GenerateTitles && len(titleTranslationTypes) > 0 {
+		originalTitleField, err = app.originalTitleField(ctx)
+		if err != nil {
+			logger.Warnf("Not keeping original titles: %v", err)
+		}
+	}
+
+	documentTypeNames, err := app.documentTypeNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch document types: %w", err)
+	}
+
 	documents := suggestionRequest.Documents
 	documentSuggestions := []DocumentSuggestion{}
 
+	// The prompts use the local OCR text where it is newer than the content in paperless-ngx,
+	// the original document keeps the paperless-ngx content for the update and its history
+	fresherContents := app.fresherOcrContents(documents, logger)
+	batchRequest := suggestionRequest
+	if len(fresherContents) > 0 {
+		batchRequest.Documents = withContents(documents, fresherContents)
+	}
+	batched := app.generateBatchedSuggestions(ctx, batchRequest, documentTypeNames, availableTagNames, logger)
+	storagePaths := app.newStoragePathPreviewer(ctx, documents, logger)
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errorsList := make([]error, 0)
@@ -318,46 +859,158 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 		wg.Add(1)
 		go func(doc Document) {
 			defer wg.Done()
+			// Every document has its own error, the goroutines must not share the one of the function
+			var err error
 			documentID := doc.ID
 			docLogger := documentLogger(documentID)
 			docLogger.Printf("Processing Document ID %d...", documentID)
+			ctx := withTraceDocument(ctx, documentID)
 
-			content := doc.Content
+			content := normalizeContent(doc.Content)
+			if text, ok := fresherContents[documentID]; ok {
+				content = normalizeContent(text)
+			}
+			documentType := documentTypeNames[doc.DocumentTypeID]
 			suggestedTitle := doc.Title
 			var suggestedTags []string
 			var suggestedCorrespondent string
+			var correspondentCandidateList []CorrespondentCandidate
+			var tagRationales map[string]string
+			var correspondentRationale string
+			withRationale := suggestionRequest.IncludeRationale || suggestionRationale
 
-			if suggestionRequest.GenerateTitles {
-				suggestedTitle, err = app.getSuggestedTitle(ctx, content, suggestedTitle, docLogger)
+			if title, ok := batched.title(documentID); ok {
+				suggestedTitle = title
+			} else if suggestionRequest.GenerateTitles {
+				currentTitle := suggestedTitle
+				if headers, ok := emailHeadersOf(doc); ok && headers.Subject != "" {
+					currentTitle = headers.Subject
+				}
+				suggestedTitle, err = app.getSuggestedTitle(ctx, content, currentTitle, documentType, docLogger)
 				if err != nil {
 					mu.Lock()
-					errorsList = append(errorsList, fmt.Errorf("Document %d: %v", documentID, err))
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
 					mu.Unlock()
 					docLogger.Errorf("Error processing document %d: %v", documentID, err)
 					return
 				}
 			}
 
-			if suggestionRequest.GenerateTags {
-				suggestedTags, err = app.getSuggestedTags(ctx, content, suggestedTitle, availableTagNames, doc.Tags, docLogger)
+			var originalTitle string
+			if suggestionRequest.GenerateTitles && translatesDocumentType(documentType) {
+				translated, err := app.translate(ctx, "title", suggestedTitle)
+				if err != nil {
+					mu.Lock()
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
+					mu.Unlock()
+					docLogger.Errorf("Error translating title for document %d: %v", documentID, err)
+					return
+				}
+				if translated != suggestedTitle {
+					docLogger.Printf("Translated title for document %d: %s -> %s", documentID, suggestedTitle, translated)
+					originalTitle, suggestedTitle = suggestedTitle, translated
+				}
+			}
+
+			if tags, ok := batched.tagList(documentID); ok {
+				suggestedTags = tags
+			} else if suggestionRequest.GenerateTags {
+				if withRationale {
+					suggestedTags, tagRationales, err = app.getSuggestedTagsWithRationale(ctx, content, suggestedTitle, availableTagNames, doc.Tags, documentType, docLogger)
+				} else {
+					suggestedTags, err = app.getSuggestedTags(ctx, content, suggestedTitle, availableTagNames, doc.Tags, documentType, docLogger)
+				}
 				if err != nil {
 					mu.Lock()
-					errorsList = append(errorsList, fmt.Errorf("Document %d: %v", documentID, err))
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
 					mu.Unlock()
 					logger.Errorf("Error generating tags for document %d: %v", documentID, err)
 					return
 				}
 			}
 
-			if suggestionRequest.GenerateCorrespondents {
-				suggestedCorrespondent, err = app.getSuggestedCorrespondent(ctx, content, suggestedTitle, availableCorrespondentNames, correspondentBlackList)
+			headers, fromEmail := emailHeadersOf(doc)
+			if sender := headers.correspondent(availableCorrespondentNames); suggestionRequest.GenerateCorrespondents && fromEmail && sender != "" {
+				docLogger.Debugf("Using the sender of email document %d as correspondent: %s", documentID, sender)
+				suggestedCorrespondent = sender
+				if withRationale {
+					correspondentRationale = "Sender of the email"
+				}
+			} else if suggestionRequest.GenerateCorrespondents && correspondentCandidates {
+				correspondentCandidateList, err = app.getSuggestedCorrespondentCandidates(ctx, content, suggestedTitle, availableCorrespondentNames, correspondentBlackList, documentType)
+				if err != nil {
+					mu.Lock()
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
+					mu.Unlock()
+					log.Errorf("Error generating correspondent candidates for document %d: %v", documentID, err)
+					return
+				}
+				correspondentCandidateList = filterBlacklistedCandidates(correspondentCandidateList, docLogger)
+				suggestedCorrespondent = selectCorrespondentCandidate(correspondentCandidateList, settings().CorrespondentAutoMargin)
+			} else if suggestionRequest.GenerateCorrespondents {
+				if withRationale {
+					suggestedCorrespondent, correspondentRationale, err = app.getSuggestedCorrespondentWithRationale(ctx, content, suggestedTitle, availableCorrespondentNames, correspondentBlackList, documentType)
+				} else {
+					suggestedCorrespondent, err = app.getSuggestedCorrespondent(ctx, content, suggestedTitle, availableCorrespondentNames, correspondentBlackList, documentType)
+				}
 				if err != nil {
 					mu.Lock()
-					errorsList = append(errorsList, fmt.Errorf("Document %d: %v", documentID, err))
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
 					mu.Unlock()
 					log.Errorf("Error generating correspondents for document %d: %v", documentID, err)
 					return
 				}
+				if isCorrespondentBlacklisted(suggestedCorrespondent) {
+					docLogger.Warnf("Suggested correspondent '%s' is blacklisted, discarding it", suggestedCorrespondent)
+					suggestedCorrespondent, correspondentRationale = "", ""
+				}
+			}
+
+			var suggestedCustomFields []CustomFieldValue
+			if originalTitleField != nil && originalTitle != "" {
+				suggestedCustomFields = append(suggestedCustomFields, CustomFieldValue{Field: originalTitleField.ID, Value: originalTitle})
+			}
+			for _, field := range selectFields {
+				option, ok, err := app.getSuggestedSelectOption(ctx, field, content, suggestedTitle, documentType, docLogger)
+				if err != nil {
+					mu.Lock()
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
+					mu.Unlock()
+					docLogger.Errorf("Error generating custom field %s for document %d: %v", field.Name, documentID, err)
+					return
+				}
+				if ok {
+					docLogger.Printf("Suggested %s for document %d: %s", field.Name, documentID, option.Label)
+					suggestedCustomFields = append(suggestedCustomFields, CustomFieldValue{Field: field.ID, Value: option.Value})
+				}
+			}
+
+			if dueDateField != nil {
+				// The due date is optional, the other suggestions are kept when it cannot be detected
+				dueDate, err := app.getSuggestedDueDate(ctx, content, suggestedTitle, documentType, docLogger)
+				if err != nil {
+					docLogger.Warnf("Skipping due date for document %d: %v", documentID, err)
+				} else if dueDate != "" {
+					docLogger.Printf("Suggested %s for document %d: %s", dueDateField.Name, documentID, dueDate)
+					suggestedCustomFields = append(suggestedCustomFields, CustomFieldValue{Field: dueDateField.ID, Value: dueDate})
+				}
+			}
+
+			if len(mappedFields) > 0 {
+				mappedValues, err := app.mappedSuggestionFields(ctx, mappedFields, doc, content, suggestedOutputs{
+					Title:         suggestedTitle,
+					Correspondent: suggestedCorrespondent,
+					Tags:          suggestedTags,
+					DocumentType:  documentType,
+				}, docLogger)
+				if err != nil {
+					mu.Lock()
+					errorsList = append(errorsList, fmt.Errorf("Document %d: %w", documentID, err))
+					mu.Unlock()
+					docLogger.Errorf("Error generating mapped custom fields for document %d: %v", documentID, err)
+					return
+				}
+				suggestedCustomFields = append(suggestedCustomFields, mappedValues...)
 			}
 
 			mu.Lock()
@@ -377,6 +1030,7 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 			if suggestionRequest.GenerateTags {
 				docLogger.Printf("Suggested tags for document %d: %v", documentID, suggestedTags)
 				suggestion.SuggestedTags = suggestedTags
+				suggestion.TagRationales = tagRationales
 			} else {
 				suggestion.SuggestedTags = doc.Tags
 			}
@@ -385,11 +1039,31 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 			if suggestionRequest.GenerateCorrespondents {
 				log.Printf("Suggested correspondent for document %d: %s", documentID, suggestedCorrespondent)
 				suggestion.SuggestedCorrespondent = suggestedCorrespondent
+				suggestion.CorrespondentCandidates = correspondentCandidateList
+				suggestion.CorrespondentRationale = correspondentRationale
 			} else {
 				suggestion.SuggestedCorrespondent = ""
 			}
+			// Custom fields
+			suggestion.SuggestedCustomFields = suggestedCustomFields
+
 			// Remove manual tag from the list of suggested tags
-			suggestion.RemoveTags = []string{manualTag, autoTag}
+			current := settings()
+			suggestion.RemoveTags = []string{current.ManualTag, current.AutoTag}
+
+			// Preview the file path paperless-ngx will produce with the suggestions
+			previewCorrespondent := suggestion.SuggestedCorrespondent
+			if previewCorrespondent == "" {
+				previewCorrespondent = doc.Correspondent
+			}
+			previewTags := slices.DeleteFunc(slices.Clone(suggestion.SuggestedTags), func(tag string) bool {
+				return slices.Contains(suggestion.RemoveTags, tag)
+			})
+			if path, err := storagePaths.preview(doc, suggestion.SuggestedTitle, previewCorrespondent, previewTags); err != nil {
+				docLogger.Debugf("Not previewing the storage path of document %d: %v", documentID, err)
+			} else {
+				suggestion.StoragePathPreview = path
+			}
 
 			documentSuggestions = append(documentSuggestions, suggestion)
 			mu.Unlock()
@@ -406,6 +1080,56 @@ func (app *App) generateDocumentSuggestions(ctx context.Context, suggestionReque
 	return documentSuggestions, nil
 }
 
+// documentTypeNames maps document type IDs to names. Document types are only fetched when prompt overrides
+// exist or titles are translated for specific document types.
+func (app *App) documentTypeNames(ctx context.Context) (map[int]string, error) {
+	templateMutex.RLock()
+	hasOverrides := len(promptOverrides) > 0
+	templateMutex.RUnlock()
+
+	names := map[int]string{}
+	if !hasOverrides && !titleTranslationNeedsDocumentTypes() {
+		return names, nil
+	}
+
+	documentTypes, err := app.Client.GetAllDocumentTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name, id := range documentTypes {
+		names[id] = name
+	}
+	return names, nil
+}
+
+// isCorrespondentBlacklisted reports whether name matches an entry of CORRESPONDENT_BLACK_LIST (case-insensitive)
+func isCorrespondentBlacklisted(name string) bool {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return false
+	}
+	for _, blacklisted := range correspondentBlackList {
+		blacklisted = strings.TrimSpace(blacklisted)
+		if blacklisted != "" && strings.EqualFold(blacklisted, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterBlacklistedCandidates drops blacklisted correspondents from the candidate list
+func filterBlacklistedCandidates(candidates []CorrespondentCandidate, logger *logrus.Entry) []CorrespondentCandidate {
+	filtered := make([]CorrespondentCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if isCorrespondentBlacklisted(candidate.Name) {
+			logger.Warnf("Correspondent candidate '%s' is blacklisted, discarding it", candidate.Name)
+			continue
+		}
+		filtered = append(filtered, candidate)
+	}
+	return filtered
+}
+
 // stripReasoning removes the reasoning from the content indicated by <think> and </think> tags.
 func stripReasoning(content string) string {
 	// Remove reasoning from the content
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"text/template"
 
@@ -128,7 +130,7 @@ Content: {{.Content}}
 
 			// Test with the app's LLM
 			ctx := context.Background()
-			_, err = app.getSuggestedTitle(ctx, truncatedContent, "Test Title", testLogger)
+			_, err = app.getSuggestedTitle(ctx, truncatedContent, "Test Title", "", testLogger)
 			require.NoError(t, err)
 
 			// Verify truncation
@@ -181,7 +183,7 @@ func TestTokenLimitInCorrespondentGeneration(t *testing.T) {
 	availableCorrespondents := []string{"Test Corp", "Example Inc"}
 	correspondentBlackList := []string{"Blocked Corp"}
 
-	_, err := app.getSuggestedCorrespondent(ctx, longContent, "Test Title", availableCorrespondents, correspondentBlackList)
+	_, err := app.getSuggestedCorrespondent(ctx, longContent, "Test Title", availableCorrespondents, correspondentBlackList, "")
 	require.NoError(t, err)
 
 	// Verify the final prompt size
@@ -219,7 +221,7 @@ func TestTokenLimitInTagGeneration(t *testing.T) {
 	availableTags := []string{"test", "example"}
 	originalTags := []string{"original"}
 
-	_, err := app.getSuggestedTags(ctx, longContent, "Test Title", availableTags, originalTags, testLogger)
+	_, err := app.getSuggestedTags(ctx, longContent, "Test Title", availableTags, originalTags, "", testLogger)
 	require.NoError(t, err)
 
 	// Verify the final prompt size
@@ -255,7 +257,7 @@ func TestTokenLimitInTitleGeneration(t *testing.T) {
 	// Call getSuggestedTitle
 	ctx := context.Background()
 
-	_, err := app.getSuggestedTitle(ctx, longContent, "Original Title", testLogger)
+	_, err := app.getSuggestedTitle(ctx, longContent, "Original Title", "", testLogger)
 	require.NoError(t, err)
 
 	// Verify the final prompt size
@@ -291,3 +293,282 @@ func TestStripReasoning(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCorrespondentCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []CorrespondentCandidate
+		wantErr  bool
+	}{
+		{
+			name:  "Object with candidates",
+			input: `{"candidates": [{"name": "Audible", "confidence": 0.2}, {"name": "Amazon", "confidence": 0.7}]}`,
+			expected: []CorrespondentCandidate{
+				{Name: "Amazon", Confidence: 0.7},
+				{Name: "Audible", Confidence: 0.2},
+			},
+		},
+		{
+			name:  "Bare array in code block with reasoning",
+			input: "<think>hmm</think>```json\n[{\"name\": \" Bank \", \"confidence\": 1.4}, {\"name\": \"\", \"confidence\": 0.5}]\n```",
+			expected: []CorrespondentCandidate{
+				{Name: "Bank", Confidence: 1},
+			},
+		},
+		{
+			name: "Truncated to three candidates",
+			input: `{"candidates": [{"name": "A", "confidence": 0.1}, {"name": "B", "confidence": 0.4},
+				{"name": "C", "confidence": 0.3}, {"name": "D", "confidence": 0.2}]}`,
+			expected: []CorrespondentCandidate{
+				{Name: "B", Confidence: 0.4},
+				{Name: "C", Confidence: 0.3},
+				{Name: "D", Confidence: 0.2},
+			},
+		},
+		{
+			name:    "Plain text answer",
+			input:   "Amazon",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseCorrespondentCandidates(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestSelectCorrespondentCandidate(t *testing.T) {
+	candidates := []CorrespondentCandidate{
+		{Name: "Amazon", Confidence: 0.6},
+		{Name: "Audible", Confidence: 0.3},
+	}
+
+	assert.Equal(t, "Amazon", selectCorrespondentCandidate(candidates, 0.2))
+	assert.Equal(t, "", selectCorrespondentCandidate(candidates, 0.5))
+	assert.Equal(t, "Amazon", selectCorrespondentCandidate(candidates[:1], 0.5))
+	assert.Equal(t, "", selectCorrespondentCandidate([]CorrespondentCandidate{{Name: "Amazon", Confidence: 0.05}}, 0.2), "a lone candidate must clear the margin too")
+	assert.Equal(t, "", selectCorrespondentCandidate(nil, 0))
+}
+
+func TestIsCorrespondentBlacklisted(t *testing.T) {
+	originalBlackList := correspondentBlackList
+	defer func() { correspondentBlackList = originalBlackList }()
+
+	correspondentBlackList = []string{"John Doe", " Jane Smith", ""}
+
+	assert.True(t, isCorrespondentBlacklisted("John Doe"))
+	assert.True(t, isCorrespondentBlacklisted("jane smith"))
+	assert.False(t, isCorrespondentBlacklisted("Amazon"))
+	assert.False(t, isCorrespondentBlacklisted(""))
+}
+
+func TestMatchSelectOption(t *testing.T) {
+	options := []SelectOption{
+		{Label: "Health Insurance", Value: "a1"},
+		{Label: "Car", Value: "b2"},
+		{Label: "Household", Value: "c3"},
+	}
+
+	tests := []struct {
+		name     string
+		label    string
+		expected interface{}
+		ok       bool
+	}{
+		{name: "Exact match", label: "Car", expected: "b2", ok: true},
+		{name: "Case and punctuation", label: "health-insurance", expected: "a1", ok: true},
+		{name: "Small typo", label: "Houshold", expected: "c3", ok: true},
+		{name: "None answer", label: "None", ok: false},
+		{name: "Unrelated answer", label: "Vacation", ok: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			option, ok := matchSelectOption(tc.label, options)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.expected, option.Value)
+			}
+		})
+	}
+}
+
+func TestParseExtractedRows(t *testing.T) {
+	rows, err := parseExtractedRows(`{"rows": [{"number": "R-1", "amount": 12.5}, {"number": "R-2", "amount": null}]}`)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "R-1", rows[0]["number"])
+	assert.Equal(t, 12.5, rows[0]["amount"])
+	assert.Nil(t, rows[1]["amount"])
+
+	rows, err = parseExtractedRows("```json\n[{\"number\": \"R-3\"}]\n```")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"number": "R-3"}}, rows)
+
+	rows, err = parseExtractedRows(`{"rows": null}`)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	_, err = parseExtractedRows("There are two invoices.")
+	assert.Error(t, err)
+}
+
+func TestPromptOverrides(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Invoice"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Invoice", "title_prompt.tmpl"), []byte("Invoice title for {{.Content}}"), os.ModePerm))
+
+	overrides, err := loadPromptOverrides(dir)
+	require.NoError(t, err)
+
+	originalOverrides := promptOverrides
+	promptOverrides = overrides
+	defer func() { promptOverrides = originalOverrides }()
+
+	fallback, err := template.New("title").Parse(testTitleTemplate)
+	require.NoError(t, err)
+
+	assert.NotSame(t, fallback, promptTemplate(context.Background(), fallback, "title_prompt.tmpl", "invoice"))
+	assert.Same(t, fallback, promptTemplate(context.Background(), fallback, "tag_prompt.tmpl", "Invoice"))
+	assert.Same(t, fallback, promptTemplate(context.Background(), fallback, "title_prompt.tmpl", "Letter"))
+	assert.Same(t, fallback, promptTemplate(context.Background(), fallback, "title_prompt.tmpl", ""))
+
+	// A missing overrides directory is not an error
+	overrides, err = loadPromptOverrides(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, overrides)
+}
+
+// cannedLLM answers every prompt with a fixed response
+type cannedLLM struct {
+	mockLLM
+	response string
+}
+
+func (m *cannedLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.lastPrompt = messages[0].Parts[0].(llms.TextContent).Text
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.response}}}, nil
+}
+
+func TestGetSuggestedTagsWithRationale(t *testing.T) {
+	var err error
+	tagTemplate, err = template.New("tag").Parse(testTagTemplate)
+	require.NoError(t, err)
+
+	llm := &cannedLLM{response: `{"tags": [
+		{"name": "invoice", "reason": "Mentions an amount due."},
+		{"name": "Unknown", "reason": "Not available in paperless."}
+	]}`}
+	app := &App{LLM: llm}
+
+	tags, rationales, err := app.getSuggestedTagsWithRationale(context.Background(), "Amount due: 10 EUR", "Title",
+		[]string{"Invoice", "Bank"}, []string{"Bank"}, "", logrus.WithField("test", "test"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Bank", "Invoice"}, tags)
+	assert.Equal(t, map[string]string{"Invoice": "Mentions an amount due."}, rationales)
+	assert.Contains(t, llm.lastPrompt, tagRationaleInstruction)
+}
+
+func TestGetSuggestedCorrespondentWithRationale(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+	var err error
+	correspondentTemplate, err = template.New("correspondent").Parse(testCorrespondentTemplate)
+	require.NoError(t, err)
+
+	llm := &cannedLLM{response: `{"correspondents": [
+		{"name": "Zalando", "reason": "Logo in the header."},
+		{"name": "Amazon", "reason": "Mentioned as marketplace."}
+	]}`}
+	app := &App{LLM: llm}
+
+	// The first listed correspondent is applied on every run
+	for range 10 {
+		name, reason, err := app.getSuggestedCorrespondentWithRationale(context.Background(), "Order", "Title", []string{"Amazon", "Zalando"}, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "Zalando", name)
+		assert.Equal(t, "Logo in the header.", reason)
+	}
+}
+
+func TestParseRationales(t *testing.T) {
+	rationales, err := parseRationales("```json\n{\"correspondents\": [{\"name\": \" Amazon \", \"reason\": \"Seller in header\"}, {\"name\": \"\"}]}\n```", "correspondents")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Amazon": "Seller in header"}, rationales)
+
+	_, err = parseRationales("Amazon", "correspondents")
+	assert.Error(t, err)
+
+	entries, err := parseRationaleEntries(`{"correspondents": [{"name": "Zalando", "reason": "Logo"}, {"name": "Amazon", "reason": "Seller"}]}`, "correspondents")
+	require.NoError(t, err)
+	assert.Equal(t, []rationaleEntry{{Name: "Zalando", Reason: "Logo"}, {Name: "Amazon", Reason: "Seller"}}, entries, "the order of the answer is kept")
+}
+
+func TestPromptHint(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+
+	var err error
+	titleTemplate, err = template.New("title").Parse(defaultTitleTemplate)
+	require.NoError(t, err)
+
+	llm := &cannedLLM{response: "Electricity Bill 2021"}
+	app := &App{LLM: llm}
+
+	ctx := withPromptHint(context.Background(), " this is a utility bill from 2021 ")
+	_, err = app.getSuggestedTitle(ctx, "Total due: 80 EUR", "scan_001", "", logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	assert.Contains(t, llm.lastPrompt, "Hint from the user about this document: this is a utility bill from 2021\n")
+
+	_, err = app.getSuggestedTitle(context.Background(), "Total due: 80 EUR", "scan_001", "", logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	assert.NotContains(t, llm.lastPrompt, "Hint")
+}
+
+// staticLLM answers every prompt with a fixed response and keeps no state, so documents can share it
+type staticLLM struct {
+	mockLLM
+	response string
+}
+
+func (m *staticLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.response}}}, nil
+}
+
+func TestGenerateSuggestionsForSeveralDocuments(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalTitleTemplate := titleTemplate
+	defer func() { titleTemplate = originalTitleTemplate }()
+	titleTemplate = template.Must(template.New("title").Parse("Title: {{.Content}}"))
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit, s.SuggestionBatchSize = 0, 0 })
+
+	for _, path := range []string{"/api/tags/", "/api/correspondents/", "/api/document_types/", "/api/custom_fields/"} {
+		env.setMockResponse(path, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results": [], "next": null}`))
+		})
+	}
+
+	// The documents are processed concurrently
+	app := &App{Client: env.client, Database: env.db, LLM: &staticLLM{response: "Suggested title"}}
+	request := GenerateSuggestionsRequest{
+		Documents:      []Document{{ID: 1, Content: "First"}, {ID: 2, Content: "Second"}, {ID: 3, Content: "Third"}},
+		GenerateTitles: true,
+	}
+	suggestions, err := app.generateDocumentSuggestions(context.Background(), request, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	require.Len(t, suggestions, 3)
+	for _, suggestion := range suggestions {
+		assert.Equal(t, "Suggested title", suggestion.SuggestedTitle)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BackfillOptions select which existing documents receive suggestions and how they are handled
+type BackfillOptions struct {
+	TitlePattern         string        `json:"title_pattern"`         // Regular expression matching titles that should be regenerated, e.g. "^(scan|img)_"
+	MissingCorrespondent bool          `json:"missing_correspondent"` // Select documents without correspondent
+	MissingDocumentType  bool          `json:"missing_document_type"` // Select documents without document type
+	Apply                bool          `json:"apply"`                 // Apply suggestions directly instead of queueing documents for review
+	PageSize             int           `json:"page_size"`             // Documents fetched per page. Default: 25
+	Delay                time.Duration `json:"-"`                     // Pause between documents to respect paperless and LLM rate limits
+	Reset                bool          `json:"reset"`                 // Ignore the stored checkpoint and start from the beginning
+	MaxUsageFactor       float64       `json:"max_usage_factor"`      // Abort if the LLM usage exceeds the estimate by this factor. Default: ESTIMATE_ABORT_FACTOR
+}
+
+// BackfillStatus reports the progress of the running or last backfill
+type BackfillStatus struct {
+	Running         bool   `json:"running"`
+	Page            int    `json:"page"`
+	TotalPages      int    `json:"total_pages"`
+	LastDocumentID  int    `json:"last_document_id"`
+	Processed       int    `json:"processed"`
+	Applied         int    `json:"applied"`
+	Queued          int    `json:"queued"`
+	Failed          int    `json:"failed"`
+	EstimatedTokens int    `json:"estimated_tokens,omitempty"`
+	UsedTokens      int    `json:"used_tokens"`
+	Error           string `json:"error,omitempty"`
+}
+
+// backfillRetries is the number of attempts per document before it is counted as failed
+const backfillRetries = 3
+
+// backfillRun holds the progress of a user's backfill and the function to stop it
+type backfillRun struct {
+	status BackfillStatus
+	cancel context.CancelFunc
+}
+
+var (
+	backfillMutex sync.Mutex
+	backfillRuns  = map[string]*backfillRun{} // Keyed by the acting user, empty without MULTI_TENANT
+)
+
+// getBackfillStatus returns a snapshot of the backfill progress of the acting user
+func getBackfillStatus(ctx context.Context) BackfillStatus {
+	backfillMutex.Lock()
+	defer backfillMutex.Unlock()
+	if run, ok := backfillRuns[tenantUsername(ctx)]; ok {
+		return run.status
+	}
+	return BackfillStatus{}
+}
+
+// updateBackfillStatus applies a change to the backfill progress of the acting user under lock
+func updateBackfillStatus(ctx context.Context, update func(status *BackfillStatus)) {
+	backfillMutex.Lock()
+	defer backfillMutex.Unlock()
+	username := tenantUsername(ctx)
+	run, ok := backfillRuns[username]
+	if !ok {
+		run = &backfillRun{}
+		backfillRuns[username] = run
+	}
+	update(&run.status)
+}
+
+// startBackfill runs a backfill in the background; only one backfill per user may run at a time.
+// The backfill keeps the values of the request context, such as the acting user, but not its cancellation.
+func (app *App) startBackfill(ctx context.Context, options BackfillOptions) error {
+	backfillMutex.Lock()
+	defer backfillMutex.Unlock()
+	username := tenantUsername(ctx)
+	if run, ok := backfillRuns[username]; ok && run.status.Running {
+		return errors.New("a backfill is already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	backfillRuns[username] = &backfillRun{status: BackfillStatus{Running: true}, cancel: cancel}
+
+	go func() {
+		defer cancel()
+		if err := app.runBackfill(ctx, options); err != nil {
+			log.Errorf("Backfill stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopBackfill cancels the running backfill of the acting user; progress is kept in the checkpoint
+func stopBackfill(ctx context.Context) {
+	backfillMutex.Lock()
+	defer backfillMutex.Unlock()
+	if run, ok := backfillRuns[tenantUsername(ctx)]; ok && run.cancel != nil {
+		run.cancel()
+	}
+}
+
+// runBackfill walks the whole archive page by page in ID order and generates suggestions for the
+// selected documents. Progress is checkpointed after every document so an interrupted run resumes.
+func (app *App) runBackfill(ctx context.Context, options BackfillOptions) (err error) {
+	defer func() {
+		updateBackfillStatus(ctx, func(status *BackfillStatus) {
+			status.Running = false
+			if err != nil {
+				status.Error = err.Error()
+			}
+		})
+	}()
+
+	if !options.MissingCorrespondent && !options.MissingDocumentType && options.TitlePattern == "" {
+		return errors.New("no selection criteria given")
+	}
+	var titlePattern *regexp.Regexp
+	if options.TitlePattern != "" {
+		if titlePattern, err = regexp.Compile(options.TitlePattern); err != nil {
+			return fmt.Errorf("invalid title pattern: %w", err)
+		}
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = 25
+	}
+	if options.MaxUsageFactor == 0 {
+		options.MaxUsageFactor = estimateAbortFactor
+	}
+
+	// The limit is only meaningful when suggestions are generated by the backfill itself.
+	// Usage is measured globally, so concurrent auto-tagging counts towards it as well.
+	tokenBudget := 0
+	if options.Apply && options.MaxUsageFactor > 0 {
+		estimate, err := app.estimateBackfill(ctx, options)
+		if err != nil {
+			return fmt.Errorf("error estimating backfill usage: %w", err)
+		}
+		tokenBudget = int(float64(estimate.Tokens) * options.MaxUsageFactor)
+		log.Infof("Backfill estimated at %d tokens (cost %.2f), aborting above %d tokens", estimate.Tokens, estimate.Cost, tokenBudget)
+		updateBackfillStatus(ctx, func(status *BackfillStatus) {
+			status.EstimatedTokens = estimate.Tokens
+		})
+	}
+	startTokens := usedTokens.Load()
+
+	username := tenantUsername(ctx)
+	checkpoint := &BackfillCheckpoint{Username: username, Page: 1}
+	if stored, err := GetBackfillCheckpoint(app.Database, username); err == nil {
+		if options.Reset {
+			checkpoint.ID = stored.ID
+		} else {
+			checkpoint = stored
+			log.Infof("Resuming backfill at page %d after document %d", checkpoint.Page, checkpoint.LastDocumentID)
+		}
+	}
+	if checkpoint.PageSize != options.PageSize {
+		// Start on the page of the new size that holds the first document of the stored page; documents up
+		// to the last handled one are skipped anyway
+		page := 1
+		if checkpoint.PageSize > 0 {
+			page = (checkpoint.Page-1)*checkpoint.PageSize/options.PageSize + 1
+		}
+		checkpoint.Page, checkpoint.PageSize = page, options.PageSize
+	}
+
+	query := url.Values{}
+	query.Set("ordering", "id")
+	total, err := app.Client.GetDocumentCount(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error counting documents: %w", err)
+	}
+	totalPages := (total + options.PageSize - 1) / options.PageSize
+
+	for page := checkpoint.Page; page <= totalPages; page++ {
+		updateBackfillStatus(ctx, func(status *BackfillStatus) {
+			status.Page = page
+			status.TotalPages = totalPages
+		})
+
+		query.Set("page", strconv.Itoa(page))
+		documents, err := app.Client.GetDocumentsByQuery(ctx, query, options.PageSize)
+		if err != nil {
+			return fmt.Errorf("error fetching page %d: %w", page, err)
+		}
+
+		for _, document := range documents {
+			if document.ID <= checkpoint.LastDocumentID {
+				continue
+			}
+
+			generateTitle, generateCorrespondent, selected := backfillSelection(document, options, titlePattern)
+			if selected {
+				applied, err := app.backfillDocument(ctx, document, generateTitle, generateCorrespondent, options)
+				updateBackfillStatus(ctx, func(status *BackfillStatus) {
+					status.Processed++
+					switch {
+					case err != nil:
+						status.Failed++
+					case applied:
+						status.Applied++
+					default:
+						status.Queued++
+					}
+				})
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					documentLogger(document.ID).Errorf("Backfill failed: %v", err)
+				}
+			}
+
+			checkpoint.Page = page
+			checkpoint.LastDocumentID = document.ID
+			if err := SaveBackfillCheckpoint(app.Database, checkpoint); err != nil {
+				return fmt.Errorf("error saving checkpoint: %w", err)
+			}
+			used := int(usedTokens.Load() - startTokens)
+			updateBackfillStatus(ctx, func(status *BackfillStatus) {
+				status.LastDocumentID = document.ID
+				status.UsedTokens = used
+			})
+			if tokenBudget > 0 && used > tokenBudget {
+				return fmt.Errorf("LLM usage of %d tokens exceeds the estimate by more than factor %.2f", used, options.MaxUsageFactor)
+			}
+		}
+	}
+
+	log.Infof("Backfill finished: %+v", getBackfillStatus(ctx))
+	return DeleteBackfillCheckpoint(app.Database, username)
+}
+
+// backfillSelection decides whether a document is selected by the backfill options and which fields to generate
+func backfillSelection(document Document, options BackfillOptions, titlePattern *regexp.Regexp) (generateTitle, generateCorrespondent, selected bool) {
+	generateTitle = titlePattern != nil && titlePattern.MatchString(document.Title)
+	generateCorrespondent = options.MissingCorrespondent && document.Correspondent == ""
+	missingDocumentType := options.MissingDocumentType && document.DocumentTypeID == 0
+	return generateTitle, generateCorrespondent, generateTitle || generateCorrespondent || missingDocumentType
+}
+
+// backfillDocument generates and applies suggestions for a document, or tags it with the manual tag
+// for review in the UI. Documents that only lack a document type are always queued for review.
+// It reports whether suggestions were applied.
+func (app *App) backfillDocument(ctx context.Context, document Document, generateTitle, generateCorrespondent bool, options BackfillOptions) (bool, error) {
+	backoff := options.Delay
+	if backoff < time.Second {
+		backoff = time.Second
+	}
+
+	var err error
+	for attempt := 1; attempt <= backfillRetries; attempt++ {
+		if err = sleepContext(ctx, options.Delay); err != nil {
+			return false, err
+		}
+
+		if !options.Apply || (!generateTitle && !generateCorrespondent) {
+			err = app.Client.ModifyDocumentTags(ctx, document.ID, []string{settings().ManualTag}, nil)
+			if err == nil {
+				return false, nil
+			}
+		} else {
+			var suggestions []DocumentSuggestion
+			suggestions, err = app.generateDocumentSuggestions(ctx, GenerateSuggestionsRequest{
+				Documents:              []Document{document},
+				GenerateTitles:         generateTitle,
+				GenerateCorrespondents: generateCorrespondent,
+			}, documentLogger(document.ID))
+			if err == nil {
+				err = app.Client.UpdateDocuments(ctx, suggestions, app.Database, false)
+				if err == nil {
+					return true, nil
+				}
+			}
+		}
+
+		documentLogger(document.ID).Warnf("Backfill attempt %d/%d failed: %v", attempt, backfillRetries, err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return false, err
+		}
+		backoff *= 2
+	}
+	return false, err
+}
+
+// sleepContext pauses for the given duration unless the context is cancelled first
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// runBackfillCommand implements the "backfill" command line mode and blocks until the backfill is done
+func runBackfillCommand(app *App, args []string) error {
+	var options BackfillOptions
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.StringVar(&options.TitlePattern, "title-pattern", "", "regular expression matching titles that should be regenerated")
+	flags.BoolVar(&options.MissingCorrespondent, "missing-correspondent", false, "select documents without correspondent")
+	flags.BoolVar(&options.MissingDocumentType, "missing-document-type", false, "select documents without document type")
+	flags.BoolVar(&options.Apply, "apply", false, "apply suggestions directly instead of tagging documents for review")
+	flags.IntVar(&options.PageSize, "page-size", 25, "documents fetched per page")
+	flags.DurationVar(&options.Delay, "delay", 2*time.Second, "pause between documents")
+	flags.BoolVar(&options.Reset, "reset", false, "ignore the stored checkpoint and start from the beginning")
+	flags.Float64Var(&options.MaxUsageFactor, "max-usage-factor", 0, "abort if the LLM usage exceeds the estimate by this factor")
+	estimateOnly := flags.Bool("estimate", false, "only print the estimated usage and cost")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *estimateOnly {
+		estimate, err := app.estimateBackfill(context.Background(), options)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Documents: %d\nEstimated tokens: %d\nEstimated cost: %.2f\n", estimate.Documents, estimate.Tokens, estimate.Cost)
+		return nil
+	}
+
+	ctx := context.Background()
+	updateBackfillStatus(ctx, func(status *BackfillStatus) {
+		*status = BackfillStatus{Running: true}
+	})
+	if err := app.runBackfill(ctx, options); err != nil {
+		return err
+	}
+	fmt.Printf("Backfill finished: %+v\n", getBackfillStatus(ctx))
+	return nil
+}
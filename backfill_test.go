@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBackfill_QueuesSelectedDocumentsAndResumes(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "paperless-gpt" })
+
+	app := &App{Client: env.client, Database: env.db}
+
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "id", r.URL.Query().Get("ordering"))
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case r.URL.Query().Get("page_size") == "1":
+			w.Write([]byte(`{"count": 4, "results": []}`))
+		case r.URL.Query().Get("page") == "1":
+			w.Write([]byte(`{"results": [
+				{"id": 1, "title": "scan_001", "correspondent": 1, "document_type": 3, "tags": []},
+				{"id": 2, "title": "Invoice", "correspondent": 0, "document_type": null, "tags": []}
+			]}`))
+		case r.URL.Query().Get("page") == "2":
+			w.Write([]byte(`{"results": [
+				{"id": 3, "title": "Contract", "correspondent": 2, "document_type": 3, "tags": []},
+				{"id": 4, "title": "Letter", "correspondent": 0, "document_type": 3, "tags": []}
+			]}`))
+		default:
+			t.Fatalf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 5, "name": "paperless-gpt"}], "next": null}`))
+	})
+
+	tagged := []int{}
+	for _, documentID := range []int{2, 4} {
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", documentID), func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"tags": []}`))
+				return
+			}
+			var updatedFields map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+			assert.Equal(t, []interface{}{float64(5)}, updatedFields["tags"])
+			tagged = append(tagged, documentID)
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+
+	// Resume after document 1 of the first page
+	require.NoError(t, SaveBackfillCheckpoint(env.db, &BackfillCheckpoint{Page: 1, LastDocumentID: 1}))
+
+	err := app.runBackfill(context.Background(), BackfillOptions{
+		TitlePattern:         "^scan_",
+		MissingCorrespondent: true,
+		PageSize:             2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{2, 4}, tagged)
+	status := getBackfillStatus(context.Background())
+	assert.Equal(t, 2, status.Processed)
+	assert.Equal(t, 2, status.Queued)
+	assert.Equal(t, 4, status.LastDocumentID)
+
+	// The checkpoint is removed once the backfill completed
+	_, err = GetBackfillCheckpoint(env.db, "")
+	assert.Error(t, err)
+}
+
+func TestBackfillPerUser(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	alice := withTenant(context.Background(), "alice", "token-a")
+	bob := withTenant(context.Background(), "bob", "token-b")
+
+	require.NoError(t, SaveBackfillCheckpoint(db, &BackfillCheckpoint{Username: "alice", Page: 3, LastDocumentID: 70}))
+	_, err = GetBackfillCheckpoint(db, "bob")
+	assert.Error(t, err, "bob does not resume at alice's checkpoint")
+	checkpoint, err := GetBackfillCheckpoint(db, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 70, checkpoint.LastDocumentID)
+
+	defer func() {
+		backfillMutex.Lock()
+		delete(backfillRuns, "alice")
+		backfillMutex.Unlock()
+	}()
+	ctx, cancel := context.WithCancel(alice)
+	defer cancel()
+	backfillMutex.Lock()
+	backfillRuns["alice"] = &backfillRun{status: BackfillStatus{Running: true, Processed: 5}, cancel: cancel}
+	backfillMutex.Unlock()
+
+	assert.Equal(t, 5, getBackfillStatus(alice).Processed)
+	assert.False(t, getBackfillStatus(bob).Running, "bob does not see alice's backfill")
+	stopBackfill(bob)
+	assert.NoError(t, ctx.Err(), "bob cannot stop alice's backfill")
+	stopBackfill(alice)
+	assert.Error(t, ctx.Err())
+}
+
+func TestRunBackfill_ResumesWithOtherPageSize(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "paperless-gpt" })
+	app := &App{Client: env.client, Database: env.db}
+	ctx := withTenant(context.Background(), "page-size-user", "")
+
+	requestedPages := []string{}
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+		w.WriteHeader(http.StatusOK)
+		if pageSize == 1 {
+			w.Write([]byte(`{"count": 6, "results": []}`))
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		requestedPages = append(requestedPages, r.URL.Query().Get("page"))
+		results := []string{}
+		for id := (page-1)*pageSize + 1; id <= page*pageSize && id <= 6; id++ {
+			results = append(results, fmt.Sprintf(`{"id": %d, "title": "Letter", "correspondent": 0, "document_type": 3, "tags": []}`, id))
+		}
+		w.Write([]byte(`{"results": [` + strings.Join(results, ",") + `]}`))
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 5, "name": "paperless-gpt"}], "next": null}`))
+	})
+	tagged := []int{}
+	for documentID := 1; documentID <= 6; documentID++ {
+		documentID := documentID
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", documentID), func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			if r.Method == "GET" {
+				w.Write([]byte(`{"tags": []}`))
+				return
+			}
+			tagged = append(tagged, documentID)
+		})
+	}
+
+	// The interrupted run used pages of two documents and stopped on page 2 after document 3
+	require.NoError(t, SaveBackfillCheckpoint(env.db, &BackfillCheckpoint{Username: "page-size-user", Page: 2, PageSize: 2, LastDocumentID: 3}))
+
+	err := app.runBackfill(ctx, BackfillOptions{MissingCorrespondent: true, PageSize: 4})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1", "2"}, requestedPages, "page 2 of four documents would skip document 4")
+	assert.Equal(t, []int{4, 5, 6}, tagged)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// backlogSampleInterval is how often the backlogs of the background queues are stored
+const backlogSampleInterval = 5 * time.Minute
+
+// backlogRetention is how long backlog samples are kept
+const backlogRetention = 30 * 24 * time.Hour
+
+// lastBacklogSample holds the time of the last stored sample per user
+var lastBacklogSample = struct {
+	sync.Mutex
+	times map[string]time.Time
+}{times: map[string]time.Time{}}
+
+// BacklogPoint is a backlog sample as returned by GET /api/stats/backlog
+type BacklogPoint struct {
+	Time    string `json:"time"`
+	OCR     *int   `json:"ocr"`     // Nil if OCR was disabled
+	Tagging *int   `json:"tagging"` // Nil if no LLM was configured
+}
+
+// countBacklog returns the number of documents carrying a queue tag
+func (app *App) countBacklog(ctx context.Context, tag string) (int, error) {
+	return app.Client.GetDocumentCount(ctx, url.Values{"tags__name__iexact": {tag}})
+}
+
+// sampleBacklog stores the current backlogs of the user in the context, at most once per backlogSampleInterval
+func (app *App) sampleBacklog(ctx context.Context, now time.Time) error {
+	username := tenantUsername(ctx)
+	lastBacklogSample.Lock()
+	if now.Sub(lastBacklogSample.times[username]) < backlogSampleInterval {
+		lastBacklogSample.Unlock()
+		return nil
+	}
+	lastBacklogSample.times[username] = now
+	lastBacklogSample.Unlock()
+
+	sample := BacklogSample{Username: username}
+	if isOcrEnabled() {
+		count, err := app.countBacklog(ctx, settings().AutoOcrTag)
+		if err != nil {
+			return err
+		}
+		sample.OcrBacklog = &count
+	}
+	if isLLMEnabled() {
+		count, err := app.countBacklog(ctx, settings().AutoTag)
+		if err != nil {
+			return err
+		}
+		sample.TaggingBacklog = &count
+	}
+	if err := InsertBacklogSample(app.Database, &sample, backlogRetention); err != nil {
+		return err
+	}
+	mqttStatus.publishBacklog(ctx, sample)
+	return nil
+}
+
+// backlogPoints converts samples into their API representation
+func backlogPoints(samples []BacklogSample) []BacklogPoint {
+	points := make([]BacklogPoint, 0, len(samples))
+	for _, sample := range samples {
+		points = append(points, BacklogPoint{Time: sample.DateSampled, OCR: sample.OcrBacklog, Tagging: sample.TaggingBacklog})
+	}
+	return points
+}
+
+// backlogChange returns how much a backlog grew (positive) or shrank (negative) between the first and last
+// point that have a value, or nil if there are fewer than two
+func backlogChange(points []BacklogPoint, value func(BacklogPoint) *int) *int {
+	var first, last *int
+	count := 0
+	for _, point := range points {
+		if v := value(point); v != nil {
+			if first == nil {
+				first = v
+			}
+			last = v
+			count++
+		}
+	}
+	if count < 2 {
+		return nil
+	}
+	change := *last - *first
+	return &change
+}
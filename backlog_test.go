@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleBacklog(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalLLM, originalVisionProvider, originalVisionModel := llmProvider, visionLlmProvider, visionLlmModel
+	defer func() {
+		llmProvider, visionLlmProvider, visionLlmModel = originalLLM, originalVisionProvider, originalVisionModel
+	}()
+	llmProvider, visionLlmProvider, visionLlmModel = "ollama", "", ""
+	setTestSettings(t, func(s *runtimeSettings) { s.AutoTag = "paperless-gpt-auto" })
+
+	backlog := 7
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, settings().AutoTag, r.URL.Query().Get("tags__name__iexact"))
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"count": %d, "results": []}`, backlog)
+	})
+
+	app := &App{Client: env.client, Database: env.db}
+	ctx := withTenant(context.Background(), "backlog-user", "")
+	start := time.Now()
+
+	require.NoError(t, app.sampleBacklog(ctx, start))
+	backlog = 4
+	// Samples within the interval are skipped
+	require.NoError(t, app.sampleBacklog(ctx, start.Add(time.Minute)))
+	require.NoError(t, app.sampleBacklog(ctx, start.Add(backlogSampleInterval)))
+
+	samples, err := GetBacklogSamples(env.db, "backlog-user", start.Add(-time.Hour))
+	require.NoError(t, err)
+	points := backlogPoints(samples)
+	require.Len(t, points, 2)
+	assert.Nil(t, points[0].OCR)
+	assert.Equal(t, 7, *points[0].Tagging)
+
+	change := backlogChange(points, func(p BacklogPoint) *int { return p.Tagging })
+	require.NotNil(t, change)
+	assert.Equal(t, -3, *change)
+	assert.Nil(t, backlogChange(points, func(p BacklogPoint) *int { return p.OCR }))
+}
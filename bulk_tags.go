@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// BulkTagRequest adds and removes tags on a set of documents, as sent to POST /api/tags/bulk.
+// The documents are given either by ID or by paperless-ngx document filter parameters.
+type BulkTagRequest struct {
+	AddTags     []string          `json:"add_tags"`
+	RemoveTags  []string          `json:"remove_tags"`
+	DocumentIDs []int             `json:"document_ids"`
+	Filter      map[string]string `json:"filter"` // e.g. {"tags__name__iexact": "inbox", "title__icontains": "scan"}
+}
+
+// validate checks that a request names tags and selects documents
+func (request BulkTagRequest) validate() error {
+	if len(trimTagNames(request.AddTags)) == 0 && len(trimTagNames(request.RemoveTags)) == 0 {
+		return errors.New("no tags to add or remove")
+	}
+	if len(request.DocumentIDs) > 0 && len(request.Filter) > 0 {
+		return errors.New("document_ids and filter cannot be combined")
+	}
+	if len(request.DocumentIDs) == 0 && len(request.Filter) == 0 {
+		// An empty filter would select the whole archive
+		return errors.New("document_ids or filter is required")
+	}
+	return nil
+}
+
+// bulkTag applies a bulk tag request and returns the IDs of the affected documents
+func (app *App) bulkTag(ctx context.Context, request BulkTagRequest) ([]int, error) {
+	if err := request.validate(); err != nil {
+		return nil, err
+	}
+
+	documentIDs := slices.Clone(request.DocumentIDs)
+	if len(request.Filter) > 0 {
+		query := url.Values{}
+		for param, value := range request.Filter {
+			query.Set(param, value)
+		}
+		var err error
+		if documentIDs, err = app.Client.GetDocumentIDs(ctx, query); err != nil {
+			return nil, fmt.Errorf("error resolving filter: %w", err)
+		}
+	}
+	slices.Sort(documentIDs)
+	documentIDs = slices.Compact(documentIDs)
+	if len(documentIDs) == 0 {
+		return []int{}, nil
+	}
+
+	addTags, removeTags := trimTagNames(request.AddTags), trimTagNames(request.RemoveTags)
+	if err := app.Client.BulkEditTags(ctx, documentIDs, addTags, removeTags); err != nil {
+		return nil, err
+	}
+	log.Infof("Bulk tagged %d documents: added %v, removed %v", len(documentIDs), addTags, removeTags)
+	return documentIDs, nil
+}
+
+// trimTagNames trims tag names and drops blank ones
+func trimTagNames(names []string) []string {
+	var trimmed []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			trimmed = append(trimmed, name)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkTagRequestValidate(t *testing.T) {
+	assert.Error(t, BulkTagRequest{AddTags: []string{" "}, DocumentIDs: []int{1}}.validate())
+	assert.Error(t, BulkTagRequest{AddTags: []string{"inbox"}}.validate())
+	assert.Error(t, BulkTagRequest{AddTags: []string{"inbox"}, DocumentIDs: []int{1}, Filter: map[string]string{"title__icontains": "scan"}}.validate())
+	assert.NoError(t, BulkTagRequest{RemoveTags: []string{"inbox"}, Filter: map[string]string{"title__icontains": "scan"}}.validate())
+}
+
+func TestBulkTag(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 9, "name": "archived"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "inbox"}], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "inbox", r.URL.Query().Get("tags__name__iexact"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"count": 3, "all": [7, 3, 5], "results": [], "next": null}`))
+	})
+	var bulkEdit map[string]interface{}
+	env.setMockResponse("/api/documents/bulk_edit/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&bulkEdit))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result": "OK"}`))
+	})
+
+	app := &App{Client: env.client, Database: env.db}
+	documentIDs, err := app.bulkTag(context.Background(), BulkTagRequest{
+		AddTags:    []string{"archived"},
+		RemoveTags: []string{"inbox", "unknown"},
+		Filter:     map[string]string{"tags__name__iexact": "inbox"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 5, 7}, documentIDs)
+	assert.Equal(t, map[string]interface{}{
+		"documents": []interface{}{float64(3), float64(5), float64(7)},
+		"method":    "modify_tags",
+		"parameters": map[string]interface{}{
+			"add_tags":    []interface{}{float64(9)},
+			"remove_tags": []interface{}{float64(1)},
+		},
+	}, bulkEdit)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PaperlessCapabilities lists the paperless-ngx features available for the detected version
+type PaperlessCapabilities struct {
+	Version  string            `json:"version"`  // Empty if the version could not be detected
+	Features map[string]bool   `json:"features"` // Feature name to availability
+	Disabled map[string]string `json:"disabled"` // Configured settings that were turned off, with the reason
+}
+
+// paperlessFeatures maps features to the paperless-ngx version that introduced them
+var paperlessFeatures = map[string]string{
+	"notes":                "1.11.0",
+	"custom_fields":        "1.19.0",
+	"workflows":            "2.0.0",
+	"select_custom_fields": "2.3.0",
+	"trash":                "2.10.0",
+	"created_as_date":      "2.16.0",
+}
+
+// paperlessCapabilities is detected at startup; with an unknown version all features are assumed available
+var paperlessCapabilities = newPaperlessCapabilities("")
+
+// newPaperlessCapabilities derives the available features from a version such as "2.3.1"
+func newPaperlessCapabilities(version string) *PaperlessCapabilities {
+	capabilities := &PaperlessCapabilities{
+		Version:  version,
+		Features: map[string]bool{},
+		Disabled: map[string]string{},
+	}
+	current, known := parseVersion(version)
+	for feature, introduced := range paperlessFeatures {
+		required, _ := parseVersion(introduced)
+		capabilities.Features[feature] = !known || compareVersions(current, required) >= 0
+	}
+	return capabilities
+}
+
+// hasFeature reports whether the connected paperless-ngx supports a feature
+func hasFeature(feature string) bool {
+	return paperlessCapabilities.Features[feature]
+}
+
+// GetVersion reads the paperless-ngx version from the X-Version header of an API response
+func (client *PaperlessClient) GetVersion(ctx context.Context) (string, error) {
+	resp, err := client.Do(ctx, "GET", "api/ui_settings/", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching version: status %d", resp.StatusCode)
+	}
+	return resp.Header.Get("X-Version"), nil
+}
+
+// gateFeatures turns off settings that the connected paperless-ngx version does not support
+func (capabilities *PaperlessCapabilities) gateFeatures() {
+	disable := func(setting string, feature string) {
+		reason := fmt.Sprintf("requires paperless-ngx %s (%s detected)", paperlessFeatures[feature], capabilities.Version)
+		capabilities.Disabled[setting] = reason
+		log.Warnf("Disabling %s: %s", setting, reason)
+	}
+
+	if len(selectCustomFields) > 0 && !capabilities.Features["select_custom_fields"] {
+		disable("SELECT_CUSTOM_FIELDS", "select_custom_fields")
+		selectCustomFields = nil
+	}
+	if extractionCustomField != "" && !capabilities.Features["custom_fields"] {
+		disable("EXTRACTION_CUSTOM_FIELD", "custom_fields")
+		extractionCustomField = ""
+	}
+	if reportAmountField != "" && !capabilities.Features["custom_fields"] {
+		disable("REPORT_AMOUNT_FIELD", "custom_fields")
+		reportAmountField = ""
+	}
+	if dueDateCustomField != "" && !capabilities.Features["custom_fields"] {
+		disable("DUE_DATE_CUSTOM_FIELD", "custom_fields")
+		dueDateCustomField = ""
+	}
+	if ocrLanguageCustomField != "" && !capabilities.Features["custom_fields"] {
+		disable("OCR_LANGUAGE_CUSTOM_FIELD", "custom_fields")
+		ocrLanguageCustomField = ""
+	}
+	if len(customFieldMapping) > 0 && !capabilities.Features["custom_fields"] {
+		disable("CUSTOM_FIELD_MAPPING", "custom_fields")
+		customFieldMapping = nil
+	}
+	if processingNotes && !capabilities.Features["notes"] {
+		disable("PROCESSING_NOTES", "notes")
+		processingNotes = false
+	}
+}
+
+// detectCapabilities reads the paperless-ngx version and disables unsupported settings
+func (app *App) detectCapabilities(ctx context.Context) {
+	version, err := app.Client.GetVersion(ctx)
+	if err != nil {
+		log.Warnf("Could not detect paperless-ngx version, assuming all features are available: %v", err)
+		return
+	}
+	paperlessCapabilities = newPaperlessCapabilities(version)
+	paperlessCapabilities.gateFeatures()
+
+	unavailable := []string{}
+	for feature, available := range paperlessCapabilities.Features {
+		if !available {
+			unavailable = append(unavailable, feature)
+		}
+	}
+	sort.Strings(unavailable)
+	log.Infof("Detected paperless-ngx %s, unavailable features: %v", version, unavailable)
+}
+
+// parseVersion parses "major.minor.patch" (an optional "v" prefix and suffixes like "-beta" are ignored)
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "-")
+	parts := strings.Split(version, ".")
+	if version == "" || len(parts) > 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, false
+		}
+		parsed[i] = number
+	}
+	return parsed, true
+}
+
+// compareVersions returns -1, 0 or 1 if a is older than, equal to or newer than b
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPaperlessCapabilities(t *testing.T) {
+	capabilities := newPaperlessCapabilities("2.2.1")
+	assert.True(t, capabilities.Features["custom_fields"])
+	assert.True(t, capabilities.Features["workflows"])
+	assert.False(t, capabilities.Features["select_custom_fields"])
+	assert.False(t, capabilities.Features["trash"])
+
+	capabilities = newPaperlessCapabilities("v2.10.0-beta.rc1")
+	assert.True(t, capabilities.Features["trash"])
+
+	// An unknown version does not disable anything
+	capabilities = newPaperlessCapabilities("")
+	for feature, available := range capabilities.Features {
+		assert.True(t, available, feature)
+	}
+}
+
+func TestGateFeatures(t *testing.T) {
+	originalSelect, originalExtraction := selectCustomFields, extractionCustomField
+	defer func() { selectCustomFields, extractionCustomField = originalSelect, originalExtraction }()
+
+	selectCustomFields = []string{"Category"}
+	extractionCustomField = "Rows"
+
+	capabilities := newPaperlessCapabilities("2.1.0")
+	capabilities.gateFeatures()
+
+	assert.Nil(t, selectCustomFields)
+	assert.Equal(t, "Rows", extractionCustomField)
+	assert.Contains(t, capabilities.Disabled["SELECT_CUSTOM_FIELDS"], "2.3.0")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// autoChangeLimit is a cap on the documents changed within a sliding window
+type autoChangeLimit struct {
+	Name   string
+	Window time.Duration
+	Max    int
+}
+
+// AutoChangePause is posted to AUTO_CHANGES_WEBHOOK_URL when auto-processing pauses
+type AutoChangePause struct {
+	Event    string    `json:"event"` // Always "auto_processing_paused"
+	Limit    string    `json:"limit"` // "hour" or "day"
+	Max      int       `json:"max"`
+	ResumeAt time.Time `json:"resume_at"`
+}
+
+// autoChangeLimiter counts the documents modified by auto-processing in the last day
+type autoChangeLimiter struct {
+	mu          sync.Mutex
+	changes     []time.Time // Oldest first
+	pausedUntil time.Time
+}
+
+// autoChanges limits the changes of the OCR and tagging queues across all tenants
+var autoChanges = &autoChangeLimiter{}
+
+// autoChangeLimits returns the configured caps
+func autoChangeLimits() []autoChangeLimit {
+	current := settings()
+	var limits []autoChangeLimit
+	if current.AutoChangesPerHour > 0 {
+		limits = append(limits, autoChangeLimit{"hour", time.Hour, current.AutoChangesPerHour})
+	}
+	if current.AutoChangesPerDay > 0 {
+		limits = append(limits, autoChangeLimit{"day", 24 * time.Hour, current.AutoChangesPerDay})
+	}
+	return limits
+}
+
+// record counts a modified document
+func (limiter *autoChangeLimiter) record(now time.Time) {
+	if len(autoChangeLimits()) == 0 {
+		return
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.changes = append(limiter.changes, now)
+}
+
+// check reports whether a cap is reached and when the oldest change leaves its window again.
+// started is true only for the check that begins a pause.
+func (limiter *autoChangeLimiter) check(now time.Time) (exceeded autoChangeLimit, resumeAt time.Time, paused, started bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	// Changes older than the longest window no longer count
+	for len(limiter.changes) > 0 && now.Sub(limiter.changes[0]) >= 24*time.Hour {
+		limiter.changes = limiter.changes[1:]
+	}
+
+	for _, limit := range autoChangeLimits() {
+		first := 0
+		for first < len(limiter.changes) && now.Sub(limiter.changes[first]) >= limit.Window {
+			first++
+		}
+		inWindow := limiter.changes[first:]
+		if len(inWindow) < limit.Max {
+			continue
+		}
+		if resume := inWindow[len(inWindow)-limit.Max].Add(limit.Window); resume.After(resumeAt) {
+			exceeded, resumeAt = limit, resume
+		}
+	}
+
+	if resumeAt.IsZero() {
+		if !limiter.pausedUntil.IsZero() {
+			log.Info("Resuming auto-processing, the cap of automatic changes is no longer reached")
+			limiter.pausedUntil = time.Time{}
+		}
+		return autoChangeLimit{}, time.Time{}, false, false
+	}
+	started = limiter.pausedUntil.IsZero()
+	limiter.pausedUntil = resumeAt
+	return exceeded, resumeAt, true, started
+}
+
+// resumeTime returns when auto-processing resumes, zero if it is not paused
+func (limiter *autoChangeLimiter) resumeTime() time.Time {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.pausedUntil
+}
+
+// allowAutoChange reports whether auto-processing may modify another document. When a cap is reached,
+// processing pauses and the pause is logged and posted to AUTO_CHANGES_WEBHOOK_URL once.
+func (app *App) allowAutoChange(ctx context.Context) bool {
+	limit, resumeAt, paused, started := autoChanges.check(time.Now())
+	if !paused {
+		return true
+	}
+	if started {
+		log.Warnf("Auto-processing modified %d documents in the last %s, pausing until %s", limit.Max, limit.Name, resumeAt.Format(time.RFC3339))
+		if autoChangesWebhookURL != "" {
+			pause := AutoChangePause{Event: "auto_processing_paused", Limit: limit.Name, Max: limit.Max, ResumeAt: resumeAt}
+			if err := postWebhook(ctx, autoChangesWebhookURL, pause); err != nil {
+				log.Errorf("Failed to send the auto-processing pause notification: %v", err)
+			}
+		}
+	}
+	return false
+}
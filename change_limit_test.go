@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoChangeLimiter(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.AutoChangesPerHour, s.AutoChangesPerDay = 2, 3 })
+
+	limiter := &autoChangeLimiter{}
+	start := time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC)
+	limiter.record(start)
+	limiter.record(start.Add(10 * time.Minute))
+
+	limit, resumeAt, paused, started := limiter.check(start.Add(20 * time.Minute))
+	require.True(t, paused)
+	assert.True(t, started)
+	assert.Equal(t, "hour", limit.Name)
+	assert.Equal(t, start.Add(time.Hour), resumeAt, "the oldest change leaves the window after an hour")
+
+	_, _, paused, started = limiter.check(start.Add(30 * time.Minute))
+	assert.True(t, paused)
+	assert.False(t, started, "a pause is only reported once")
+
+	_, _, paused, _ = limiter.check(start.Add(time.Hour))
+	assert.False(t, paused)
+	assert.True(t, limiter.resumeTime().IsZero())
+
+	// The third change of the day reaches the daily cap although the hourly window has room
+	limiter.record(start.Add(2 * time.Hour))
+	limit, resumeAt, paused, _ = limiter.check(start.Add(3 * time.Hour))
+	require.True(t, paused)
+	assert.Equal(t, "day", limit.Name)
+	assert.Equal(t, start.Add(24*time.Hour), resumeAt)
+}
+
+func TestAllowAutoChangeNotifies(t *testing.T) {
+	originalWebhook, originalChanges := autoChangesWebhookURL, autoChanges
+	defer func() {
+		autoChangesWebhookURL, autoChanges = originalWebhook, originalChanges
+	}()
+
+	var notifications []AutoChangePause
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pause AutoChangePause
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&pause))
+		notifications = append(notifications, pause)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	setTestSettings(t, func(s *runtimeSettings) { s.AutoChangesPerHour, s.AutoChangesPerDay = 1, 0 })
+	autoChangesWebhookURL, autoChanges = webhook.URL, &autoChangeLimiter{}
+	app := &App{}
+	assert.True(t, app.allowAutoChange(context.Background()))
+	autoChanges.record(time.Now())
+	assert.False(t, app.allowAutoChange(context.Background()))
+	assert.False(t, app.allowAutoChange(context.Background()))
+
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "auto_processing_paused", notifications[0].Event)
+	assert.Equal(t, "hour", notifications[0].Limit)
+	assert.Equal(t, 1, notifications[0].Max)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// classifyDocument asks the LLM which of the user-defined categories apply to a document
+func (app *App) classifyDocument(ctx context.Context, doc Document, categories []Category, logger *logrus.Entry) ([]Category, error) {
+	if len(categories) == 0 {
+		return []Category{}, nil
+	}
+
+	documentTypeNames, err := app.documentTypeNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch document types: %w", err)
+	}
+	documentType := documentTypeNames[doc.DocumentTypeID]
+
+	templateMutex.RLock()
+	prompt, err := renderContentPrompt(ctx, promptTemplate(ctx, categoryTemplate, "classification_prompt.tmpl", documentType),
+		doc.Title, normalizeContent(doc.Content), map[string]interface{}{"Categories": categories})
+	templateMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Classification prompt: %s", prompt)
+
+	response, err := app.generateValidated(withTraceDocument(ctx, doc.ID), prompt, func(response string) error {
+		_, err := parseCategories(response, categories)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+	return parseCategories(response, categories)
+}
+
+// parseCategories parses an answer of the form {"categories": [...]} and resolves the names to the configured categories
+func parseCategories(response string, categories []Category) ([]Category, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed struct {
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing categories: %w", err)
+	}
+
+	assigned := []Category{}
+	for _, name := range parsed.Categories {
+		found := false
+		for _, category := range categories {
+			if strings.EqualFold(strings.TrimSpace(name), category.Name) {
+				assigned = append(assigned, category)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown category %q, expected only names from the list", name)
+		}
+	}
+	return assigned, nil
+}
+
+// applyCategories adds the tags and sets the boolean custom fields mapped to the assigned categories
+func (app *App) applyCategories(ctx context.Context, doc Document, categories []Category) error {
+	var tags, fieldNames []string
+	for _, category := range categories {
+		if category.Tag != "" {
+			tags = append(tags, category.Tag)
+		}
+		if category.CustomField != "" {
+			fieldNames = append(fieldNames, category.CustomField)
+		}
+	}
+
+	if len(fieldNames) > 0 {
+		if !hasFeature("custom_fields") {
+			return fmt.Errorf("custom fields require paperless-ngx %s", paperlessFeatures["custom_fields"])
+		}
+		customFields, err := app.Client.GetCustomFields(ctx)
+		if err != nil {
+			return err
+		}
+		var values []CustomFieldValue
+		for _, name := range fieldNames {
+			found := false
+			for _, field := range customFields {
+				if field.Name == name {
+					values = append(values, CustomFieldValue{Field: field.ID, Value: true})
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("custom field %s does not exist in paperless-ngx", name)
+			}
+		}
+		err = app.Client.UpdateDocuments(ctx, []DocumentSuggestion{
+			{
+				ID:                    doc.ID,
+				OriginalDocument:      doc,
+				SuggestedCustomFields: values,
+			},
+		}, app.Database, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.Client.ModifyDocumentTags(ctx, doc.ID, tags, nil)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestClassifyDocument(t *testing.T) {
+	originalTemplate := categoryTemplate
+	categoryTemplate = template.Must(template.New("classification").Parse(defaultClassificationTemplate))
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+	defer func() { categoryTemplate = originalTemplate }()
+
+	categories := []Category{
+		{ID: 1, Name: "tax-relevant", Description: "Documents needed for the tax return"},
+		{ID: 2, Name: "warranty", Description: "Proof of purchase for warranty claims", Tag: "warranty"},
+	}
+	llm := &scriptedLLM{responses: []string{
+		`{"categories": ["tax-relevant", "insurance"]}`,
+		`{"categories": ["Warranty"]}`,
+	}}
+	app := &App{LLM: llm}
+
+	doc := Document{ID: 3, Title: "Receipt", Content: "Laptop, 2 years warranty"}
+	assigned, err := app.classifyDocument(context.Background(), doc, categories, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	assert.Equal(t, []Category{categories[1]}, assigned)
+
+	// The unknown category was re-asked; the prompt lists the category descriptions
+	require.Len(t, llm.conversations, 2)
+	assert.Contains(t, llm.conversations[0][0].Parts[0].(llms.TextContent).Text, "- warranty: Proof of purchase for warranty claims")
+}
+
+func TestParseCategories(t *testing.T) {
+	categories := []Category{{Name: "medical"}}
+
+	assigned, err := parseCategories("```json\n{\"categories\": []}\n```", categories)
+	require.NoError(t, err)
+	assert.Empty(t, assigned)
+
+	_, err = parseCategories(`medical`, categories)
+	assert.Error(t, err)
+}
+
+func TestSaveCategory(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	category := Category{Name: "medical", Description: "Doctor's letters and prescriptions"}
+	require.NoError(t, SaveCategory(db, &category))
+	assert.ErrorIs(t, SaveCategory(db, &Category{Name: "Medical", Description: "duplicate"}), errDuplicateCategory)
+
+	category.Description = "Doctor's letters, prescriptions and invoices"
+	require.NoError(t, SaveCategory(db, &category))
+
+	records, err := GetCategories(db)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Doctor's letters, prescriptions and invoices", records[0].Description)
+	assert.NotEmpty(t, records[0].DateAdded)
+
+	require.NoError(t, DeleteCategory(db, category.ID))
+	assert.Error(t, DeleteCategory(db, category.ID))
+}
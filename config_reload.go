@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// configReloadMutex serializes reloads of CONFIG_FILE
+var configReloadMutex sync.Mutex
+
+// errNoConfigFile is returned by a reload without CONFIG_FILE, as the environment of a running process cannot change
+var errNoConfigFile = errors.New("CONFIG_FILE is not set, environment variables only change with a restart")
+
+// ConfigChange is a setting that changed with a reload
+type ConfigChange struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// runtimeSettings are the settings that can change at runtime through CONFIG_FILE. A snapshot is never
+// modified once stored; a reload stores a new one, so readers calling settings() always see consistent values.
+type runtimeSettings struct {
+	ManualTag            string
+	AutoTag              string
+	AutoOcrTag           string
+	OcrInProgressTag     string
+	OcrDoneTag           string
+	TaggingInProgressTag string
+	TaggingDoneTag       string
+	ProcessingFailedTag  string
+	OcrReviewTag         string
+
+	OcrDocumentsPerCycle       int
+	TaggingDocumentsPerCycle   int
+	AutoChangesPerHour         int // 0 disables the cap
+	AutoChangesPerDay          int // 0 disables the cap
+	AnthropicRequestsPerMinute int // 0 does not limit the requests
+	TokenLimit                 int
+	SuggestionBatchSize        int // 0 disables batching
+	CorrespondentAutoMargin    float64
+
+	QuietPeriod     time.Duration
+	AutoOcrInterval time.Duration // Wait of the OCR task after a cycle found no documents
+	AutoTagInterval time.Duration // Wait of the tagging task after a cycle found no documents
+	PollingJitter   time.Duration // Maximum random delay added to every wait of the background tasks
+}
+
+// defaultSettings are used for settings that are neither in the environment nor in CONFIG_FILE
+var defaultSettings = runtimeSettings{
+	ManualTag:                "paperless-gpt",
+	AutoTag:                  "paperless-gpt-auto",
+	AutoOcrTag:               "paperless-gpt-ocr-auto",
+	OcrDocumentsPerCycle:     25,
+	TaggingDocumentsPerCycle: 25,
+	CorrespondentAutoMargin:  0.2,
+	AutoOcrInterval:          10 * time.Second,
+	AutoTagInterval:          10 * time.Second,
+}
+
+// currentSettings holds the snapshot returned by settings()
+var currentSettings atomic.Pointer[runtimeSettings]
+
+// settings returns the current reloadable settings. The snapshot must not be modified.
+func settings() *runtimeSettings {
+	if current := currentSettings.Load(); current != nil {
+		return current
+	}
+	return &defaultSettings
+}
+
+// reloadableSetting is a setting that can be changed at runtime through CONFIG_FILE
+type reloadableSetting struct {
+	name  string
+	get   func(s *runtimeSettings) string
+	parse func(raw string, s *runtimeSettings) error // Validates the value and stores it in s
+}
+
+// stringSetting is a reloadable text setting, e.g. a tag name, that falls back to its default if empty
+func stringSetting(name string, field func(s *runtimeSettings) *string) reloadableSetting {
+	return reloadableSetting{name, func(s *runtimeSettings) string { return *field(s) }, func(raw string, s *runtimeSettings) error {
+		if raw == "" {
+			raw = *field(&defaultSettings)
+		}
+		*field(s) = raw
+		return nil
+	}}
+}
+
+// intSetting is a reloadable integer setting of at least min
+func intSetting(name string, field func(s *runtimeSettings) *int, min int) reloadableSetting {
+	return reloadableSetting{name, func(s *runtimeSettings) string { return strconv.Itoa(*field(s)) }, func(raw string, s *runtimeSettings) error {
+		value := *field(&defaultSettings)
+		if raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < min {
+				return fmt.Errorf("%s must be an integer of at least %d, got: %s", name, min, raw)
+			}
+			value = parsed
+		}
+		*field(s) = value
+		return nil
+	}}
+}
+
+// floatSetting is a reloadable number setting between min and max
+func floatSetting(name string, field func(s *runtimeSettings) *float64, min float64, max float64) reloadableSetting {
+	return reloadableSetting{name, func(s *runtimeSettings) string { return strconv.FormatFloat(*field(s), 'f', -1, 64) }, func(raw string, s *runtimeSettings) error {
+		value := *field(&defaultSettings)
+		if raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil || parsed < min || parsed > max {
+				return fmt.Errorf("%s must be a number between %v and %v, got: %s", name, min, max, raw)
+			}
+			value = parsed
+		}
+		*field(s) = value
+		return nil
+	}}
+}
+
+// durationSetting is a reloadable duration setting. Intervals must be positive, other durations non-negative.
+func durationSetting(name string, field func(s *runtimeSettings) *time.Duration, interval bool) reloadableSetting {
+	return reloadableSetting{name, func(s *runtimeSettings) string { return field(s).String() }, func(raw string, s *runtimeSettings) error {
+		value := *field(&defaultSettings)
+		if raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if interval && (err != nil || parsed <= 0) {
+				return fmt.Errorf("%s must be a positive duration such as 30s, got: %s", name, raw)
+			}
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("%s must be a non-negative duration such as 60s, got: %s", name, raw)
+			}
+			value = parsed
+		}
+		*field(s) = value
+		return nil
+	}}
+}
+
+// reloadableSettings are read from the environment and CONFIG_FILE at startup and on POST /api/config/reload.
+// They only affect the next processing cycle or request; providers, the database and the listen address are
+// not reloadable and need a restart.
+var reloadableSettings = []reloadableSetting{
+	stringSetting("MANUAL_TAG", func(s *runtimeSettings) *string { return &s.ManualTag }),
+	stringSetting("AUTO_TAG", func(s *runtimeSettings) *string { return &s.AutoTag }),
+	stringSetting("AUTO_OCR_TAG", func(s *runtimeSettings) *string { return &s.AutoOcrTag }),
+	stringSetting("OCR_IN_PROGRESS_TAG", func(s *runtimeSettings) *string { return &s.OcrInProgressTag }),
+	stringSetting("OCR_DONE_TAG", func(s *runtimeSettings) *string { return &s.OcrDoneTag }),
+	stringSetting("TAGGING_IN_PROGRESS_TAG", func(s *runtimeSettings) *string { return &s.TaggingInProgressTag }),
+	stringSetting("TAGGING_DONE_TAG", func(s *runtimeSettings) *string { return &s.TaggingDoneTag }),
+	stringSetting("PROCESSING_FAILED_TAG", func(s *runtimeSettings) *string { return &s.ProcessingFailedTag }),
+	stringSetting("OCR_REVIEW_TAG", func(s *runtimeSettings) *string { return &s.OcrReviewTag }),
+	intSetting("OCR_DOCUMENTS_PER_CYCLE", func(s *runtimeSettings) *int { return &s.OcrDocumentsPerCycle }, 1),
+	intSetting("TAGGING_DOCUMENTS_PER_CYCLE", func(s *runtimeSettings) *int { return &s.TaggingDocumentsPerCycle }, 1),
+	intSetting("AUTO_CHANGES_PER_HOUR", func(s *runtimeSettings) *int { return &s.AutoChangesPerHour }, 0),
+	intSetting("AUTO_CHANGES_PER_DAY", func(s *runtimeSettings) *int { return &s.AutoChangesPerDay }, 0),
+	intSetting("ANTHROPIC_REQUESTS_PER_MINUTE", func(s *runtimeSettings) *int { return &s.AnthropicRequestsPerMinute }, 0),
+	intSetting("TOKEN_LIMIT", func(s *runtimeSettings) *int { return &s.TokenLimit }, 0),
+	intSetting("SUGGESTION_BATCH_SIZE", func(s *runtimeSettings) *int { return &s.SuggestionBatchSize }, 0),
+	floatSetting("CORRESPONDENT_AUTO_APPLY_MARGIN", func(s *runtimeSettings) *float64 { return &s.CorrespondentAutoMargin }, 0, 1),
+	durationSetting("QUIET_PERIOD", func(s *runtimeSettings) *time.Duration { return &s.QuietPeriod }, false),
+	durationSetting("AUTO_OCR_INTERVAL", func(s *runtimeSettings) *time.Duration { return &s.AutoOcrInterval }, true),
+	durationSetting("AUTO_TAG_INTERVAL", func(s *runtimeSettings) *time.Duration { return &s.AutoTagInterval }, true),
+	durationSetting("POLLING_JITTER", func(s *runtimeSettings) *time.Duration { return &s.PollingJitter }, false),
+}
+
+// validate checks the settings against each other. The trigger tags must differ, and documents must not be
+// marked as in progress or failed with a tag that queues them again.
+func (s *runtimeSettings) validate() error {
+	type namedTag struct{ name, tag string }
+	triggers := []namedTag{{"MANUAL_TAG", s.ManualTag}, {"AUTO_TAG", s.AutoTag}, {"AUTO_OCR_TAG", s.AutoOcrTag}, {"MANUAL_OCR_TAG", manualOcrTag}}
+	for i, trigger := range triggers {
+		for _, other := range triggers[i+1:] {
+			if trigger.tag != "" && strings.EqualFold(trigger.tag, other.tag) {
+				return fmt.Errorf("%s and %s must be different tags, both are %q", trigger.name, other.name, trigger.tag)
+			}
+		}
+	}
+	for _, status := range []namedTag{{"OCR_IN_PROGRESS_TAG", s.OcrInProgressTag}, {"TAGGING_IN_PROGRESS_TAG", s.TaggingInProgressTag}, {"PROCESSING_FAILED_TAG", s.ProcessingFailedTag}} {
+		for _, trigger := range triggers {
+			if status.tag != "" && strings.EqualFold(status.tag, trigger.tag) {
+				return fmt.Errorf("%s and %s must be different tags, both are %q", status.name, trigger.name, status.tag)
+			}
+		}
+	}
+	return nil
+}
+
+// loadSettings reads the reloadable settings from values, falling back to the environment for settings
+// values does not contain and to the defaults for settings that are not set at all
+func loadSettings(values map[string]string) (*runtimeSettings, error) {
+	loaded := defaultSettings
+	for _, setting := range reloadableSettings {
+		raw, ok := values[setting.name]
+		if !ok {
+			raw = os.Getenv(setting.name)
+		}
+		if err := setting.parse(raw, &loaded); err != nil {
+			return nil, err
+		}
+	}
+	if err := loaded.validate(); err != nil {
+		return nil, err
+	}
+	return &loaded, nil
+}
+
+// applySettings makes new settings current and updates the components that keep state derived from them
+func applySettings(s *runtimeSettings) {
+	currentSettings.Store(s)
+	anthropicLimiter.SetLimit(anthropicRateLimit(s.AnthropicRequestsPerMinute))
+}
+
+// readConfigFile reads KEY=VALUE lines, ignoring empty lines, comments and an "export " prefix
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d of the config file is not KEY=VALUE", number)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
+
+// reloadConfig applies the reloadable settings from CONFIG_FILE, falling back to the environment for settings
+// the file does not contain. Nothing is changed if a value is invalid.
+func reloadConfig() ([]ConfigChange, error) {
+	if configFile == "" {
+		return nil, errNoConfigFile
+	}
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	configReloadMutex.Lock()
+	defer configReloadMutex.Unlock()
+
+	loaded, err := loadSettings(values)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := settings()
+	changes := []ConfigChange{}
+	for _, setting := range reloadableSettings {
+		if old, current := setting.get(previous), setting.get(loaded); current != old {
+			log.Infof("Config reload: %s changed from %q to %q", setting.name, old, current)
+			changes = append(changes, ConfigChange{Setting: setting.name, Old: old, New: current})
+		}
+	}
+	applySettings(loaded)
+	if len(changes) == 0 {
+		log.Info("Config reload: no settings changed")
+		return changes, nil
+	}
+	// Start the next background cycles right away, so they use the new tags and intervals
+	wakeBackgroundProcessing()
+	return changes, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// setTestSettings changes the reloadable settings until the end of the test
+func setTestSettings(t *testing.T, change func(s *runtimeSettings)) {
+	t.Helper()
+	previous := currentSettings.Load()
+	updated := *settings()
+	change(&updated)
+	currentSettings.Store(&updated)
+	t.Cleanup(func() { currentSettings.Store(previous) })
+}
+
+func TestReloadConfig(t *testing.T) {
+	// The reload also applies the defaults of settings that are not configured
+	originalFile, originalSettings := configFile, currentSettings.Load()
+	defer func() {
+		configFile = originalFile
+		applySettings(settingsOrDefault(originalSettings))
+	}()
+
+	configFile = ""
+	_, err := reloadConfig()
+	assert.ErrorIs(t, err, errNoConfigFile)
+
+	configFile = filepath.Join(t.TempDir(), "paperless-gpt.env")
+	applySettings(&defaultSettings)
+	require.NoError(t, os.WriteFile(configFile, []byte("# Tuned for the night\nexport AUTO_TAG=\"inbox-ai\"\nOCR_DOCUMENTS_PER_CYCLE=5\nAUTO_TAG_INTERVAL=1m\nANTHROPIC_REQUESTS_PER_MINUTE=30\n"), 0o600))
+
+	changes, err := reloadConfig()
+	require.NoError(t, err)
+	// Settings in the environment of the test run may show up as further changes
+	assert.Subset(t, changes, []ConfigChange{
+		{Setting: "AUTO_TAG", Old: "paperless-gpt-auto", New: "inbox-ai"},
+		{Setting: "OCR_DOCUMENTS_PER_CYCLE", Old: "25", New: "5"},
+		{Setting: "AUTO_TAG_INTERVAL", Old: "10s", New: "1m0s"},
+		{Setting: "ANTHROPIC_REQUESTS_PER_MINUTE", Old: "0", New: "30"},
+	}, changes)
+	assert.Equal(t, "inbox-ai", settings().AutoTag)
+	assert.Equal(t, 5, settings().OcrDocumentsPerCycle)
+	assert.Equal(t, time.Minute, settings().AutoTagInterval)
+	assert.Equal(t, rate.Every(2*time.Second), anthropicLimiter.Limit(), "the rate limit of running models changes")
+
+	// An invalid value leaves all settings unchanged
+	require.NoError(t, os.WriteFile(configFile, []byte("AUTO_TAG=other\nQUIET_PERIOD=soon\n"), 0o600))
+	_, err = reloadConfig()
+	assert.ErrorContains(t, err, "QUIET_PERIOD")
+	assert.Equal(t, "inbox-ai", settings().AutoTag)
+
+	for _, invalid := range []string{"AUTO_OCR_INTERVAL=0s\n", "AUTO_TAG=paperless-gpt\n", "PROCESSING_FAILED_TAG=paperless-gpt-ocr-auto\n"} {
+		require.NoError(t, os.WriteFile(configFile, []byte(invalid), 0o600))
+		_, err = reloadConfig()
+		assert.Error(t, err, invalid)
+		assert.Equal(t, "inbox-ai", settings().AutoTag)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	valid := defaultSettings
+	valid.OcrDoneTag = valid.AutoTag // Chaining OCR and tagging through the done tag is allowed
+	assert.NoError(t, valid.validate())
+
+	sameTrigger := defaultSettings
+	sameTrigger.AutoOcrTag = "Paperless-GPT-Auto"
+	assert.ErrorContains(t, sameTrigger.validate(), "AUTO_TAG and AUTO_OCR_TAG")
+
+	requeued := defaultSettings
+	requeued.TaggingInProgressTag = requeued.ManualTag
+	assert.ErrorContains(t, requeued.validate(), "TAGGING_IN_PROGRESS_TAG and MANUAL_TAG")
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.env")
+	require.NoError(t, os.WriteFile(path, []byte("AUTO_TAG=a\nnot a setting\n"), 0o600))
+	_, err := readConfigFile(path)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+// settingsOrDefault returns the default settings for a snapshot that was never stored
+func settingsOrDefault(s *runtimeSettings) *runtimeSettings {
+	if s == nil {
+		return &defaultSettings
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DiagnosticCheck is the outcome of a single connectivity check against paperless-ngx
+type DiagnosticCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"` // What to change if the check failed
+}
+
+// PaperlessDiagnostics is the result of GET /api/diagnostics/paperless
+type PaperlessDiagnostics struct {
+	BaseURL string            `json:"base_url"`
+	Version string            `json:"version,omitempty"`
+	OK      bool              `json:"ok"`
+	Checks  []DiagnosticCheck `json:"checks"`
+}
+
+// diagnose runs the connectivity checks in order; later checks are skipped once the connection or token fails
+func (client *PaperlessClient) diagnose(ctx context.Context) PaperlessDiagnostics {
+	diagnostics := PaperlessDiagnostics{BaseURL: client.BaseURL}
+
+	// Connection and token
+	resp, body, err := client.diagnosticRequest(ctx, "GET", "api/ui_settings/", nil)
+	check := DiagnosticCheck{Name: "token"}
+	if err == nil {
+		diagnostics.Version = resp.Header.Get("X-Version")
+		var settings struct {
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+		}
+		json.Unmarshal(body, &settings)
+		check.OK = true
+		check.Message = fmt.Sprintf("Authenticated as %q", settings.User.Username)
+	} else {
+		check.Message, check.Hint = err.Error(), diagnosticHint(err, resp)
+	}
+	diagnostics.Checks = append(diagnostics.Checks, check)
+	if !check.OK {
+		return diagnostics
+	}
+
+	// Version
+	check = DiagnosticCheck{Name: "version", OK: diagnostics.Version != ""}
+	if check.OK {
+		check.Message = "paperless-ngx " + diagnostics.Version
+	} else {
+		check.Message = "The response did not contain an X-Version header"
+		check.Hint = "A reverse proxy may strip response headers; paperless-gpt is tested with paperless-ngx 2.x"
+	}
+	diagnostics.Checks = append(diagnostics.Checks, check)
+
+	// Read access to documents
+	resp, body, err = client.diagnosticRequest(ctx, "GET", "api/documents/?page_size=1", nil)
+	check = DiagnosticCheck{Name: "documents"}
+	if err == nil {
+		var documents struct {
+			Count int `json:"count"`
+		}
+		json.Unmarshal(body, &documents)
+		check.OK = true
+		check.Message = fmt.Sprintf("%d documents visible", documents.Count)
+	} else {
+		check.Message, check.Hint = err.Error(), diagnosticHint(err, resp)
+	}
+	diagnostics.Checks = append(diagnostics.Checks, check)
+
+	// Write access, tested by creating and deleting a temporary tag
+	diagnostics.Checks = append(diagnostics.Checks, client.diagnoseTagWrite(ctx))
+
+	diagnostics.OK = true
+	for _, check := range diagnostics.Checks {
+		// A missing version header alone does not break paperless-gpt
+		if !check.OK && check.Name != "version" {
+			diagnostics.OK = false
+		}
+	}
+	return diagnostics
+}
+
+// diagnoseTagWrite creates a temporary tag and deletes it again
+func (client *PaperlessClient) diagnoseTagWrite(ctx context.Context) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "tag_write"}
+	payload, _ := json.Marshal(map[string]string{"name": fmt.Sprintf("paperless-gpt-diagnostics-%d", time.Now().Unix())})
+	resp, body, err := client.diagnosticRequest(ctx, "POST", "api/tags/", payload)
+	if err != nil {
+		check.Message, check.Hint = err.Error(), diagnosticHint(err, resp)
+		return check
+	}
+
+	var tag struct {
+		ID int `json:"id"`
+	}
+	json.Unmarshal(body, &tag)
+	resp, _, err = client.diagnosticRequest(ctx, "DELETE", fmt.Sprintf("api/tags/%d/", tag.ID), nil)
+	if err != nil {
+		check.Message = fmt.Sprintf("Created tag %d but could not delete it: %v", tag.ID, err)
+		check.Hint = "Delete the paperless-gpt-diagnostics tag manually and grant the user permission to delete tags"
+		return check
+	}
+	check.OK = true
+	check.Message = "Created and deleted a temporary tag"
+	return check
+}
+
+// diagnosticRequest performs a request and turns non-2xx and non-JSON answers into errors
+func (client *PaperlessClient) diagnosticRequest(ctx context.Context, method, path string, payload []byte) (*http.Response, []byte, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	resp, err := client.Do(ctx, method, path, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, body, fmt.Errorf("%s %s returned status %d", method, path, resp.StatusCode)
+	}
+	if method != "DELETE" && !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp, body, fmt.Errorf("%s %s returned %q instead of JSON", method, path, resp.Header.Get("Content-Type"))
+	}
+	return resp, body, nil
+}
+
+// diagnosticHint suggests a fix for the common misconfigurations behind a failed request
+func diagnosticHint(err error, resp *http.Response) string {
+	var certErr *x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var authErr *paperlessAuthError
+	switch {
+	case errors.As(err, &certErr) || errors.As(err, &hostnameErr) || strings.Contains(err.Error(), "certificate"):
+		return "The TLS certificate of paperless-ngx is not trusted. Use a certificate from a trusted CA or mount your CA into the container"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "Nothing is listening at PAPERLESS_BASE_URL. Check host and port, and use the service name instead of localhost inside Docker"
+	case strings.Contains(err.Error(), "no such host"):
+		return "The host of PAPERLESS_BASE_URL cannot be resolved. Check for typos and that both containers share a network"
+	case errors.As(err, &authErr) && authErr.StatusCode == http.StatusUnauthorized:
+		return "PAPERLESS_API_TOKEN is invalid. Create a token in the paperless-ngx profile settings"
+	case errors.As(err, &authErr):
+		return "The token's user lacks permissions. Grant view/change permissions on documents, tags and correspondents"
+	case resp == nil:
+		return ""
+	case resp.StatusCode == http.StatusNotFound:
+		return "PAPERLESS_BASE_URL must be the paperless-ngx root URL without /api, including any path prefix"
+	case strings.Contains(resp.Header.Get("Content-Type"), "html"):
+		return "An HTML page was returned, usually a login page of a reverse proxy or the web UI. Point PAPERLESS_BASE_URL directly at paperless-ngx"
+	}
+	return ""
+}
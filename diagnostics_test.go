@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnose_Healthy(t *testing.T) {
+	sandbox := newSandboxServer()
+	server := httptest.NewServer(sandbox.handler())
+	defer server.Close()
+
+	diagnostics := NewPaperlessClient(server.URL, "sandbox").diagnose(context.Background())
+
+	assert.True(t, diagnostics.OK)
+	assert.Equal(t, "sandbox", diagnostics.Version)
+	require.Len(t, diagnostics.Checks, 4)
+	for _, check := range diagnostics.Checks {
+		assert.True(t, check.OK, check.Name)
+	}
+	// The temporary tag is removed again
+	assert.Len(t, sandbox.tags, 7)
+}
+
+func TestDiagnose_HTMLResponse(t *testing.T) {
+	// A reverse proxy answering with its login page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>Please log in</body></html>"))
+	}))
+	defer server.Close()
+
+	diagnostics := NewPaperlessClient(server.URL, "token").diagnose(context.Background())
+
+	assert.False(t, diagnostics.OK)
+	require.Len(t, diagnostics.Checks, 1)
+	assert.Equal(t, "token", diagnostics.Checks[0].Name)
+	assert.Contains(t, diagnostics.Checks[0].Message, "instead of JSON")
+	assert.Contains(t, diagnostics.Checks[0].Hint, "reverse proxy")
+}
+
+func TestDiagnose_InvalidToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"detail": "Invalid token."}`))
+	}))
+	defer server.Close()
+
+	diagnostics := NewPaperlessClient(server.URL, "wrong").diagnose(context.Background())
+
+	assert.False(t, diagnostics.OK)
+	assert.Contains(t, diagnostics.Checks[0].Hint, "PAPERLESS_API_TOKEN")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ComparisonItem is a difference between two documents
+type ComparisonItem struct {
+	Aspect string `json:"aspect"`
+	First  string `json:"first"`
+	Second string `json:"second"`
+}
+
+// ComparedDocument identifies a document of a comparison
+type ComparedDocument struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// DocumentComparison is the answer of POST /api/compare
+type DocumentComparison struct {
+	First    ComparedDocument `json:"first"`
+	Second   ComparedDocument `json:"second"`
+	Summary  string           `json:"summary"`
+	Parties  []ComparisonItem `json:"parties"`
+	Amounts  []ComparisonItem `json:"amounts"`
+	Dates    []ComparisonItem `json:"dates"`
+	Clauses  []ComparisonItem `json:"clauses"`
+	Markdown string           `json:"markdown"`
+}
+
+// compareDocuments asks the LLM for the differences between two documents
+func (app *App) compareDocuments(ctx context.Context, first Document, second Document) (DocumentComparison, error) {
+	templateMutex.RLock()
+	prompt, err := renderComparisonPrompt(ctx, first, second)
+	templateMutex.RUnlock()
+	if err != nil {
+		return DocumentComparison{}, err
+	}
+	log.Debugf("Comparison prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseComparison(response)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return DocumentComparison{}, err
+	}
+	comparison, err := parseComparison(response)
+	if err != nil {
+		return DocumentComparison{}, err
+	}
+	comparison.First = ComparedDocument{ID: first.ID, Title: first.Title}
+	comparison.Second = ComparedDocument{ID: second.ID, Title: second.Title}
+	comparison.Markdown = comparison.markdown()
+	return comparison, nil
+}
+
+// renderComparisonPrompt renders the comparison template with the content of each document truncated to
+// half of the tokens available for content
+func renderComparisonPrompt(ctx context.Context, first Document, second Document) (string, error) {
+	documents := []map[string]interface{}{{"Title": first.Title, "Content": ""}, {"Title": second.Title, "Content": ""}}
+	templateData := map[string]interface{}{
+		"Language": getLikelyLanguage(),
+		"First":    documents[0],
+		"Second":   documents[1],
+		"Hint":     promptHint(ctx),
+	}
+	availableTokens, err := getAvailableTokensForContent(comparisonTemplate, templateData)
+	if err != nil {
+		return "", fmt.Errorf("error calculating available tokens: %w", err)
+	}
+	if availableTokens > 0 {
+		availableTokens /= 2
+	}
+	for i, doc := range []Document{first, second} {
+		content, err := truncateContentByTokens(normalizeContent(doc.Content), availableTokens)
+		if err != nil {
+			return "", fmt.Errorf("error truncating content: %w", err)
+		}
+		documents[i]["Content"] = content
+	}
+
+	var promptBuffer bytes.Buffer
+	if err := comparisonTemplate.Execute(&promptBuffer, templateData); err != nil {
+		return "", fmt.Errorf("error executing comparison template: %w", err)
+	}
+	return promptBuffer.String(), nil
+}
+
+// parseComparison parses an answer of the form {"summary": ..., "parties": [...], ...}. Items without an
+// aspect or without any difference are dropped.
+func parseComparison(response string) (DocumentComparison, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var comparison DocumentComparison
+	if err := json.Unmarshal([]byte(response), &comparison); err != nil {
+		return DocumentComparison{}, fmt.Errorf("error parsing comparison: %w", err)
+	}
+	comparison.Summary = strings.TrimSpace(comparison.Summary)
+	if comparison.Summary == "" {
+		return DocumentComparison{}, errors.New("the comparison has no summary")
+	}
+
+	differences := func(items []ComparisonItem) []ComparisonItem {
+		kept := []ComparisonItem{}
+		for _, item := range items {
+			item.Aspect, item.First, item.Second = strings.TrimSpace(item.Aspect), strings.TrimSpace(item.First), strings.TrimSpace(item.Second)
+			if item.Aspect != "" && item.First != item.Second {
+				kept = append(kept, item)
+			}
+		}
+		return kept
+	}
+	comparison.Parties = differences(comparison.Parties)
+	comparison.Amounts = differences(comparison.Amounts)
+	comparison.Dates = differences(comparison.Dates)
+	comparison.Clauses = differences(comparison.Clauses)
+	return comparison, nil
+}
+
+// markdown renders the comparison with a table per kind of difference
+func (comparison DocumentComparison) markdown() string {
+	cell := func(text string) string {
+		if text == "" {
+			return "–"
+		}
+		return strings.ReplaceAll(strings.ReplaceAll(text, "|", `\|`), "\n", " ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s ↔ %s\n\n%s\n", cell(comparison.First.Title), cell(comparison.Second.Title), comparison.Summary)
+	for _, section := range []struct {
+		heading string
+		items   []ComparisonItem
+	}{
+		{"Parties", comparison.Parties},
+		{"Amounts", comparison.Amounts},
+		{"Dates", comparison.Dates},
+		{"Clauses", comparison.Clauses},
+	} {
+		fmt.Fprintf(&b, "\n### %s\n\n", section.heading)
+		if len(section.items) == 0 {
+			b.WriteString("No differences.\n")
+			continue
+		}
+		fmt.Fprintf(&b, "| | #%d | #%d |\n|---|---|---|\n", comparison.First.ID, comparison.Second.ID)
+		for _, item := range section.items {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(item.Aspect), cell(item.First), cell(item.Second))
+		}
+	}
+	return b.String()
+}
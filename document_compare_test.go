@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParseComparison(t *testing.T) {
+	comparison, err := parseComparison("```json\n" + `{
+		"summary": "The renewal raises the monthly fee.",
+		"parties": [],
+		"amounts": [{"aspect": "Monthly fee", "first": "39.99 EUR", "second": " 44.99 EUR "}, {"aspect": "Setup fee", "first": "0 EUR", "second": "0 EUR"}],
+		"dates": [{"aspect": "End of term", "first": "2024-12-31", "second": "2026-12-31"}, {"aspect": "", "first": "a", "second": "b"}]
+	}` + "\n```")
+	require.NoError(t, err)
+	assert.Equal(t, "The renewal raises the monthly fee.", comparison.Summary)
+	assert.Empty(t, comparison.Parties)
+	assert.Equal(t, []ComparisonItem{{Aspect: "Monthly fee", First: "39.99 EUR", Second: "44.99 EUR"}}, comparison.Amounts)
+	assert.Equal(t, []ComparisonItem{{Aspect: "End of term", First: "2024-12-31", Second: "2026-12-31"}}, comparison.Dates)
+	assert.NotNil(t, comparison.Clauses, "missing lists are returned empty")
+
+	for _, response := range []string{`not json`, `{"summary": " ", "parties": []}`} {
+		_, err := parseComparison(response)
+		assert.Error(t, err, response)
+	}
+}
+
+func TestCompareDocuments(t *testing.T) {
+	originalTemplate := comparisonTemplate
+	comparisonTemplate = template.Must(template.New("comparison").Funcs(sprig.FuncMap()).Parse(defaultComparisonTemplate))
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+	defer func() { comparisonTemplate = originalTemplate }()
+
+	llm := &scriptedLLM{responses: []string{
+		`{"summary": "Missing lists"`,
+		`{"summary": "The correction fixes the total.", "amounts": [{"aspect": "Total", "first": "120 | 00", "second": "102.00"}], "clauses": [{"aspect": "Payment term", "first": "", "second": "14 days"}]}`,
+	}}
+	app := &App{LLM: llm}
+	first := Document{ID: 12, Title: "Invoice 1001", Content: "Total: 120.00"}
+	second := Document{ID: 34, Title: "Corrected invoice 1001", Content: "Total: 102.00, payable within 14 days"}
+
+	comparison, err := app.compareDocuments(withPromptHint(context.Background(), "The second one is the correction"), first, second)
+	require.NoError(t, err)
+	assert.Len(t, llm.conversations, 2, "an invalid answer is re-asked")
+	prompt := llm.conversations[0][0].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, prompt, "Title: Invoice 1001\nTotal: 120.00")
+	assert.Contains(t, prompt, "Title: Corrected invoice 1001\nTotal: 102.00, payable within 14 days")
+	assert.Contains(t, prompt, "The second one is the correction")
+
+	assert.Equal(t, ComparedDocument{ID: 12, Title: "Invoice 1001"}, comparison.First)
+	assert.Equal(t, ComparedDocument{ID: 34, Title: "Corrected invoice 1001"}, comparison.Second)
+	assert.Equal(t, `## Invoice 1001 ↔ Corrected invoice 1001
+
+The correction fixes the total.
+
+### Parties
+
+No differences.
+
+### Amounts
+
+| | #12 | #34 |
+|---|---|---|
+| Total | 120 \| 00 | 102.00 |
+
+### Dates
+
+No differences.
+
+### Clauses
+
+| | #12 | #34 |
+|---|---|---|
+| Payment term | – | 14 days |
+`, comparison.Markdown)
+}
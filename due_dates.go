@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dueDateCheckInterval is how often documents are checked for coming due dates
+const dueDateCheckInterval = 24 * time.Hour
+
+// DueDocument is a document whose due date lies within DUE_SOON_DAYS
+type DueDocument struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	DueDate string `json:"due_date"` // YYYY-MM-DD
+}
+
+// DueDateCheckResult lists the documents that are due soon and the tag changes made by a check
+type DueDateCheckResult struct {
+	DueSoon  []DueDocument `json:"due_soon"`
+	Tagged   []int         `json:"tagged"`   // Documents that received the due soon tag in this check
+	Untagged []int         `json:"untagged"` // Documents whose due soon tag was removed in this check
+}
+
+// startDueDateChecker checks the due dates right away and then once a day
+func startDueDateChecker(app *App) {
+	go func() {
+		for {
+			if !isLeader() {
+				// Check again soon so a replica taking over does not wait a whole day
+				time.Sleep(leaderLockTTL)
+				continue
+			}
+			if _, err := app.checkDueDates(context.Background(), time.Now()); err != nil {
+				log.Errorf("Error checking due dates: %v", err)
+			}
+			time.Sleep(dueDateCheckInterval)
+		}
+	}()
+}
+
+// dueDateField looks up the date custom field configured in DUE_DATE_CUSTOM_FIELD
+func (app *App) dueDateField(ctx context.Context) (CustomField, error) {
+	fields, err := app.Client.GetCustomFields(ctx)
+	if err != nil {
+		return CustomField{}, fmt.Errorf("error fetching custom fields: %w", err)
+	}
+	for _, field := range fields {
+		if !strings.EqualFold(field.Name, dueDateCustomField) {
+			continue
+		}
+		if field.DataType != "date" {
+			return CustomField{}, fmt.Errorf("custom field %s is of type %s, expected date", field.Name, field.DataType)
+		}
+		return field, nil
+	}
+	return CustomField{}, fmt.Errorf("custom field %s does not exist in paperless-ngx", dueDateCustomField)
+}
+
+// getSuggestedDueDate asks the LLM for the due, expiry or deadline date of a document as YYYY-MM-DD.
+// An empty string means the document has no such date.
+func (app *App) getSuggestedDueDate(ctx context.Context, content string, title string, documentType string, logger *logrus.Entry) (string, error) {
+	templateMutex.RLock()
+	prompt, err := renderContentPrompt(ctx, promptTemplate(ctx, dueDateTemplate, "due_date_prompt.tmpl", documentType), title, content, nil)
+	templateMutex.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	logger.Debugf("Due date prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, validateCreatedDate)
+	if err != nil {
+		return "", err
+	}
+	response = strings.TrimSpace(stripReasoning(response))
+	if strings.EqualFold(response, "unknown") {
+		return "", nil
+	}
+	return response, nil
+}
+
+// dueDateValue returns the date stored in the custom field with the given ID, if the document has one
+func dueDateValue(doc Document, fieldID int) (time.Time, bool) {
+	for _, field := range doc.CustomFields {
+		if field.Field != fieldID {
+			continue
+		}
+		value, ok := field.Value.(string)
+		if !ok {
+			return time.Time{}, false
+		}
+		due, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return due, true
+	}
+	return time.Time{}, false
+}
+
+// isDueSoon reports whether a due date lies between today and the given number of days from now
+func isDueSoon(due time.Time, now time.Time, days int) bool {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return !due.Before(today) && !due.After(today.AddDate(0, 0, days))
+}
+
+// checkDueDates adds the due soon tag to documents whose due date lies within DUE_SOON_DAYS and removes it
+// from documents that are no longer due soon. Newly tagged documents are posted to DUE_SOON_WEBHOOK_URL.
+func (app *App) checkDueDates(ctx context.Context, now time.Time) (*DueDateCheckResult, error) {
+	field, err := app.dueDateField(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("custom_fields__id__all", strconv.Itoa(field.ID))
+	documents, err := app.Client.GetDocumentsByQuery(ctx, query, 10000)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching documents with due dates: %w", err)
+	}
+
+	// Documents whose due date field was removed still need their tag cleaned up
+	taggedDocuments, err := app.Client.GetDocumentsByTags(ctx, []string{dueSoonTag}, 10000)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching documents tagged %s: %w", dueSoonTag, err)
+	}
+	for _, doc := range taggedDocuments {
+		if !slices.ContainsFunc(documents, func(d Document) bool { return d.ID == doc.ID }) {
+			documents = append(documents, doc)
+		}
+	}
+
+	result := &DueDateCheckResult{DueSoon: []DueDocument{}, Tagged: []int{}, Untagged: []int{}}
+	var newlyDue []DueDocument
+	for _, doc := range documents {
+		due, ok := dueDateValue(doc, field.ID)
+		soon := ok && isDueSoon(due, now, dueSoonDays)
+		hasTag := slices.Contains(doc.Tags, dueSoonTag)
+
+		switch {
+		case soon:
+			dueDocument := DueDocument{ID: doc.ID, Title: doc.Title, DueDate: due.Format("2006-01-02")}
+			result.DueSoon = append(result.DueSoon, dueDocument)
+			if hasTag {
+				continue
+			}
+			if err := app.Client.ModifyDocumentTags(ctx, doc.ID, []string{dueSoonTag}, nil); err != nil {
+				documentLogger(doc.ID).Errorf("Error adding tag %s: %v", dueSoonTag, err)
+				continue
+			}
+			result.Tagged = append(result.Tagged, doc.ID)
+			newlyDue = append(newlyDue, dueDocument)
+		case hasTag:
+			if err := app.Client.ModifyDocumentTags(ctx, doc.ID, nil, []string{dueSoonTag}); err != nil {
+				documentLogger(doc.ID).Errorf("Error removing tag %s: %v", dueSoonTag, err)
+				continue
+			}
+			result.Untagged = append(result.Untagged, doc.ID)
+		}
+	}
+	log.Infof("Due date check: %d documents due within %d days, %d newly tagged, %d untagged",
+		len(result.DueSoon), dueSoonDays, len(result.Tagged), len(result.Untagged))
+
+	if dueSoonWebhookURL != "" && len(newlyDue) > 0 {
+		payload := map[string]interface{}{"days": dueSoonDays, "documents": newlyDue}
+		if err := postWebhook(ctx, dueSoonWebhookURL, payload); err != nil {
+			log.Warnf("Failed to deliver due date notification: %v", err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDueSoon(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 18, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+
+	assert.True(t, isDueSoon(day(10), now, 14), "due today")
+	assert.True(t, isDueSoon(day(24), now, 14), "last day of the window")
+	assert.False(t, isDueSoon(day(25), now, 14), "after the window")
+	assert.False(t, isDueSoon(day(9), now, 14), "already past")
+}
+
+func TestCheckDueDates(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalField, originalTag, originalDays, originalWebhook := dueDateCustomField, dueSoonTag, dueSoonDays, dueSoonWebhookURL
+	defer func() {
+		dueDateCustomField, dueSoonTag, dueSoonDays, dueSoonWebhookURL = originalField, originalTag, originalDays, originalWebhook
+	}()
+
+	var notification map[string]interface{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&notification))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	dueDateCustomField, dueSoonTag, dueSoonDays, dueSoonWebhookURL = "Due Date", "due-soon", 14, webhook.URL
+	now := time.Date(2024, time.March, 10, 8, 0, 0, 0, time.Local)
+
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 5, "name": "Due Date", "data_type": "date"}], "next": null}`))
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 7, "name": "due-soon"}], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("custom_fields__id__all") == "5" {
+			w.Write([]byte(`{"results": [
+				{"id": 1, "title": "Invoice", "tags": [], "custom_fields": [{"field": 5, "value": "2024-03-13"}]},
+				{"id": 2, "title": "Warranty", "tags": [7], "custom_fields": [{"field": 5, "value": "2024-05-01"}]},
+				{"id": 3, "title": "Old invoice", "tags": [], "custom_fields": [{"field": 5, "value": "2024-03-01"}]}
+			]}`))
+			return
+		}
+		assert.Equal(t, "due-soon", r.URL.Query().Get("tags__name__iexact"))
+		w.Write([]byte(`{"results": [
+			{"id": 2, "title": "Warranty", "tags": [7], "custom_fields": [{"field": 5, "value": "2024-05-01"}]},
+			{"id": 4, "title": "Contract", "tags": [7], "custom_fields": []}
+		]}`))
+	})
+
+	updatedTags := map[int]interface{}{}
+	tags := map[int]string{1: "[]", 2: "[7]", 4: "[7]"}
+	for id, current := range tags {
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", id), func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(fmt.Sprintf(`{"id": %d, "tags": %s}`, id, current)))
+				return
+			}
+			var updatedFields map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+			updatedTags[id] = updatedFields["tags"]
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+
+	app := &App{Client: env.client}
+	result, err := app.checkDueDates(context.Background(), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, []DueDocument{{ID: 1, Title: "Invoice", DueDate: "2024-03-13"}}, result.DueSoon)
+	assert.Equal(t, []int{1}, result.Tagged)
+	assert.Equal(t, []int{2, 4}, result.Untagged)
+	assert.Equal(t, map[int]interface{}{
+		1: []interface{}{float64(7)},
+		2: []interface{}{},
+		4: []interface{}{},
+	}, updatedTags)
+
+	// Only newly tagged documents are notified
+	require.NotNil(t, notification)
+	assert.Equal(t, float64(14), notification["days"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": float64(1), "title": "Invoice", "due_date": "2024-03-13"},
+	}, notification["documents"])
+}
+
+func TestDueDateErrorKeepsSuggestions(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalField, originalTitleTemplate, originalDueDateTemplate := dueDateCustomField, titleTemplate, dueDateTemplate
+	defer func() {
+		dueDateCustomField, titleTemplate, dueDateTemplate = originalField, originalTitleTemplate, originalDueDateTemplate
+	}()
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+	dueDateCustomField = "Due Date"
+	titleTemplate = template.Must(template.New("title").Parse("Title: {{.Content}}"))
+	dueDateTemplate = template.Must(template.New("due_date").Parse("Due date: {{.Content}}"))
+
+	for _, path := range []string{"/api/tags/", "/api/correspondents/", "/api/document_types/"} {
+		env.setMockResponse(path, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results": [], "next": null}`))
+		})
+	}
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 9, "name": "Due Date", "data_type": "date"}], "next": null}`))
+	})
+
+	// The due date answer stays malformed after the re-ask
+	llm := &scriptedLLM{responses: []string{"ACME invoice", "next month", "soon"}}
+	app := &App{Client: env.client, Database: env.db, LLM: llm}
+	request := GenerateSuggestionsRequest{
+		Documents:            []Document{{ID: 1, Title: "scan_0001", Content: "Invoice from ACME"}},
+		GenerateTitles:       true,
+		GenerateCustomFields: true,
+	}
+
+	suggestions, err := app.generateDocumentSuggestions(context.Background(), request, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "ACME invoice", suggestions[0].SuggestedTitle)
+	assert.Empty(t, suggestions[0].SuggestedCustomFields)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxEmailHeaderLines is how many leading lines of the content are searched for email headers
+const maxEmailHeaderLines = 30
+
+// emailDateLayouts are tried for Date headers that net/mail does not understand, e.g. as rendered by mail clients
+var emailDateLayouts = []string{
+	"Monday, January 2, 2006 3:04 PM",
+	"Monday, 2 January 2006 15:04",
+	"2 January 2006 15:04",
+	"02.01.2006 15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// emailHeaders are the headers of an email found at the start of a document's content
+type emailHeaders struct {
+	From    string // Display name of the sender, or the address if there is none
+	Address string // Address of the sender
+	Subject string
+	Date    string // YYYY-MM-DD
+}
+
+// isEmailDocument reports whether a document was consumed from an email file
+func isEmailDocument(doc Document) bool {
+	switch strings.ToLower(filepath.Ext(doc.OriginalFileName)) {
+	case ".eml", ".msg":
+		return true
+	}
+	return false
+}
+
+// emailHeadersOf returns the email headers of a document if EMAIL_HEADER_METADATA is enabled and the document
+// was consumed from an email
+func emailHeadersOf(doc Document) (emailHeaders, bool) {
+	if !emailHeaderMetadata || !isEmailDocument(doc) {
+		return emailHeaders{}, false
+	}
+	headers := parseEmailHeaders(doc.Content)
+	return headers, headers != emailHeaders{}
+}
+
+// parseEmailHeaders reads the From, Subject and Date headers from the first lines of an email's content.
+// Headers that cannot be parsed are left empty.
+func parseEmailHeaders(content string) emailHeaders {
+	var headers emailHeaders
+	lines := strings.SplitN(content, "\n", maxEmailHeaderLines+1)
+	if len(lines) > maxEmailHeaderLines {
+		lines = lines[:maxEmailHeaderLines]
+	}
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "from", "von":
+			if headers.Address == "" {
+				headers.From, headers.Address = parseEmailSender(value)
+			}
+		case "subject", "betreff":
+			if headers.Subject == "" {
+				headers.Subject = value
+			}
+		case "date", "sent", "datum", "gesendet":
+			if headers.Date == "" {
+				headers.Date = parseEmailDate(value)
+			}
+		}
+	}
+	return headers
+}
+
+// parseEmailSender splits a From header into the display name and the address
+func parseEmailSender(value string) (string, string) {
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", ""
+	}
+	name := strings.Trim(strings.TrimSpace(address.Name), `"'`)
+	if name == "" {
+		name = address.Address
+	}
+	return name, address.Address
+}
+
+// parseEmailDate converts a Date header to YYYY-MM-DD, or returns an empty string
+func parseEmailDate(value string) string {
+	if date, err := mail.ParseDate(value); err == nil {
+		return date.Format("2006-01-02")
+	}
+	for _, layout := range emailDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// correspondent returns the sender as a correspondent, preferring the spelling of an existing correspondent.
+// Senders without a display name and blacklisted senders are not used.
+func (headers emailHeaders) correspondent(availableCorrespondents []string) string {
+	if headers.From == "" || headers.From == headers.Address || isCorrespondentBlacklisted(headers.From) {
+		return ""
+	}
+	for _, name := range availableCorrespondents {
+		if strings.EqualFold(name, headers.From) {
+			return name
+		}
+	}
+	return headers.From
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEmailContent = `From: "ACME Billing" <billing@acme.example>
+To: me@example.com
+Subject: Your invoice 1001
+Date: Tue, 12 Mar 2024 09:15:00 +0100
+
+Dear customer, please find attached invoice 1001 dated 2024-02-28.`
+
+func TestParseEmailHeaders(t *testing.T) {
+	assert.Equal(t, emailHeaders{
+		From:    "ACME Billing",
+		Address: "billing@acme.example",
+		Subject: "Your invoice 1001",
+		Date:    "2024-03-12",
+	}, parseEmailHeaders(testEmailContent))
+
+	// Headers as printed by mail clients
+	assert.Equal(t, emailHeaders{
+		From:    "jane@example.com",
+		Address: "jane@example.com",
+		Subject: "Meeting",
+		Date:    "2024-03-12",
+	}, parseEmailHeaders("Von: jane@example.com\nGesendet: 12.03.2024 09:15\nBetreff: Meeting\n\nHi"))
+
+	assert.Equal(t, emailHeaders{}, parseEmailHeaders("Invoice 1001\nTotal: 120.00"))
+}
+
+func TestEmailHeadersOf(t *testing.T) {
+	original := emailHeaderMetadata
+	defer func() { emailHeaderMetadata = original }()
+	doc := Document{OriginalFileName: "Invoice.EML", Content: testEmailContent}
+
+	emailHeaderMetadata = false
+	_, ok := emailHeadersOf(doc)
+	assert.False(t, ok, "disabled by default")
+
+	emailHeaderMetadata = true
+	_, ok = emailHeadersOf(doc)
+	assert.True(t, ok)
+	_, ok = emailHeadersOf(Document{OriginalFileName: "invoice.pdf", Content: testEmailContent})
+	assert.False(t, ok, "only documents consumed from emails")
+}
+
+func TestEmailHeadersCorrespondent(t *testing.T) {
+	originalBlackList := correspondentBlackList
+	defer func() { correspondentBlackList = originalBlackList }()
+	correspondentBlackList = []string{"Mailer Daemon"}
+
+	headers := emailHeaders{From: "acme billing", Address: "billing@acme.example"}
+	assert.Equal(t, "ACME Billing", headers.correspondent([]string{"ACME Billing", "Other"}))
+	assert.Equal(t, "acme billing", headers.correspondent(nil))
+	assert.Empty(t, emailHeaders{From: "jane@example.com", Address: "jane@example.com"}.correspondent(nil), "no display name")
+	assert.Empty(t, emailHeaders{From: "Mailer Daemon", Address: "daemon@example.com"}.correspondent(nil))
+}
+
+func TestSuggestFieldFromEmailHeaders(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	original := emailHeaderMetadata
+	defer func() { emailHeaderMetadata = original }()
+	emailHeaderMetadata = true
+
+	llm := &scriptedLLM{}
+	app := &App{Client: env.client, LLM: llm}
+	doc := Document{ID: 1, OriginalFileName: "invoice.eml", Content: testEmailContent}
+	logger := logrus.WithField("test", "test")
+
+	date, err := app.suggestField(context.Background(), doc, "created_date", "", logger)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-12", date)
+
+	correspondent, err := app.suggestField(context.Background(), doc, "correspondent", "", logger)
+	require.NoError(t, err)
+	assert.Equal(t, "ACME Billing", correspondent)
+	assert.Empty(t, llm.conversations, "the LLM is not asked")
+
+	// Feedback on the suggestion overrides the headers
+	originalTemplate := createdDateTemplate
+	createdDateTemplate = template.Must(template.New("created_date").Parse("Date of {{.Title}}: {{.Content}}"))
+	defer func() { createdDateTemplate = originalTemplate }()
+	llm.responses = []string{"2024-02-28"}
+	date, err = app.suggestField(context.Background(), doc, "created_date", "Use the date of the attached invoice", logger)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-28", date)
+	assert.Len(t, llm.conversations, 1)
+}
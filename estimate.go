@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// CostEstimate is the projected usage of a batch run
+type CostEstimate struct {
+	Documents int     `json:"documents"`
+	Tokens    int     `json:"tokens"` // LLM tokens of prompts and answers
+	Pages     int     `json:"pages"`  // Pages sent to the vision LLM
+	Cost      float64 `json:"cost"`   // Based on LLM_COST_PER_1K_TOKENS and OCR_COST_PER_PAGE
+}
+
+const (
+	// promptOverheadTokens approximates the tokens of a prompt template without the document content
+	promptOverheadTokens = 400
+	// completionTokens approximates the tokens of a single suggestion answer
+	completionTokens = 50
+)
+
+// usedTokens and usedOcrPages count the LLM usage since startup
+var (
+	usedTokens   atomic.Int64
+	usedOcrPages atomic.Int64
+)
+
+// usageTrackingLLM wraps an LLM and counts the tokens of all prompts and answers
+type usageTrackingLLM struct {
+	llms.Model
+	model string // Name of the wrapped model, used to count its tokens
+}
+
+// GenerateContent forwards to the wrapped LLM and records the text tokens used
+func (m *usageTrackingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				usedTokens.Add(int64(llms.CountTokens(m.model, text.Text)))
+			}
+		}
+	}
+
+	response, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err == nil {
+		for _, choice := range response.Choices {
+			usedTokens.Add(int64(llms.CountTokens(m.model, choice.Content)))
+		}
+	}
+	return response, err
+}
+
+// Call forwards to the wrapped LLM through GenerateContent so the usage is recorded
+func (m *usageTrackingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// price calculates the cost of the estimated tokens and pages
+func (estimate *CostEstimate) price() {
+	estimate.Cost = float64(estimate.Tokens)/1000*llmCostPer1kTokens + float64(estimate.Pages)*ocrCostPerPage
+}
+
+// estimateSuggestionTokens approximates the tokens needed to generate the given number of fields for a document
+func estimateSuggestionTokens(content string, fields int) int {
+	current := settings()
+	contentTokens, _ := getTokenCount(normalizeContent(content))
+	if current.TokenLimit > 0 && contentTokens > current.TokenLimit-promptOverheadTokens {
+		contentTokens = max(current.TokenLimit-promptOverheadTokens, 0)
+	}
+	return fields * (contentTokens + promptOverheadTokens + completionTokens)
+}
+
+// estimateBackfill walks the archive like a backfill and estimates the LLM usage for the selected documents
+func (app *App) estimateBackfill(ctx context.Context, options BackfillOptions) (CostEstimate, error) {
+	estimate := CostEstimate{}
+	var titlePattern *regexp.Regexp
+	if options.TitlePattern != "" {
+		var err error
+		if titlePattern, err = regexp.Compile(options.TitlePattern); err != nil {
+			return estimate, err
+		}
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = 25
+	}
+
+	query := url.Values{}
+	query.Set("ordering", "id")
+	total, err := app.Client.GetDocumentCount(ctx, query)
+	if err != nil {
+		return estimate, err
+	}
+
+	for page := 1; (page-1)*options.PageSize < total; page++ {
+		query.Set("page", strconv.Itoa(page))
+		documents, err := app.Client.GetDocumentsByQuery(ctx, query, options.PageSize)
+		if err != nil {
+			return estimate, err
+		}
+		for _, document := range documents {
+			generateTitle, generateCorrespondent, selected := backfillSelection(document, options, titlePattern)
+			if !selected {
+				continue
+			}
+			estimate.Documents++
+			fields := 0
+			if generateTitle {
+				fields++
+			}
+			if generateCorrespondent {
+				fields++
+			}
+			estimate.Tokens += estimateSuggestionTokens(document.Content, fields)
+		}
+	}
+
+	estimate.price()
+	return estimate, nil
+}
+
+// estimateOcr estimates the pages and cost of OCRing all documents currently tagged for automatic OCR
+func (app *App) estimateOcr(ctx context.Context) (CostEstimate, error) {
+	estimate := CostEstimate{}
+	documents, err := app.Client.GetDocumentsByTags(ctx, []string{settings().AutoOcrTag}, 10000)
+	if err != nil {
+		return estimate, err
+	}
+
+	return estimateOcrPages(documents), nil
+}
+
+// estimateOcrPages estimates the pages and cost of OCRing the given documents
+func estimateOcrPages(documents []Document) CostEstimate {
+	estimate := CostEstimate{}
+	for _, document := range documents {
+		pages := max(document.PageCount, 1)
+		if limitOcrPages > 0 {
+			pages = min(pages, limitOcrPages)
+		}
+		estimate.Documents++
+		estimate.Pages += pages
+	}
+
+	estimate.price()
+	return estimate
+}
+
+// ocrPageBudget aborts an OCR run whose pages exceed the estimate by ESTIMATE_ABORT_FACTOR. Pages are counted
+// globally, so concurrent OCR jobs count towards it as well.
+type ocrPageBudget struct {
+	limit int // 0 disables the budget
+	start int64
+}
+
+// newOcrPageBudget estimates the pages of the documents of a run and starts counting
+func newOcrPageBudget(documents []Document) ocrPageBudget {
+	budget := ocrPageBudget{start: usedOcrPages.Load()}
+	if estimateAbortFactor > 0 {
+		budget.limit = int(float64(estimateOcrPages(documents).Pages) * estimateAbortFactor)
+	}
+	return budget
+}
+
+// exceeded returns an error once more pages were sent to the vision LLM than the budget allows
+func (budget ocrPageBudget) exceeded() error {
+	used := int(usedOcrPages.Load() - budget.start)
+	if budget.limit > 0 && used > budget.limit {
+		return fmt.Errorf("OCR usage of %d pages exceeds the estimate by more than factor %.2f", used, estimateAbortFactor)
+	}
+	return nil
+}
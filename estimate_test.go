@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateOcr(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalLimit, originalCost := limitOcrPages, ocrCostPerPage
+	limitOcrPages, ocrCostPerPage = 5, 0.01
+	defer func() { limitOcrPages, ocrCostPerPage = originalLimit, originalCost }()
+
+	app := &App{Client: env.client, Database: env.db}
+
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 1, "title": "Short", "tags": [], "page_count": 3},
+			{"id": 2, "title": "Long", "tags": [], "page_count": 12},
+			{"id": 3, "title": "Unknown", "tags": []}
+		]}`))
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	estimate, err := app.estimateOcr(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, estimate.Documents)
+	assert.Equal(t, 9, estimate.Pages)
+	assert.InDelta(t, 0.09, estimate.Cost, 1e-9)
+}
+
+func TestOcrPageBudget(t *testing.T) {
+	originalFactor, originalLimit := estimateAbortFactor, limitOcrPages
+	defer func() { estimateAbortFactor, limitOcrPages = originalFactor, originalLimit }()
+	estimateAbortFactor, limitOcrPages = 1.5, 0
+
+	documents := []Document{{ID: 1, PageCount: 2}, {ID: 2, PageCount: 2}}
+	budget := newOcrPageBudget(documents)
+	assert.Equal(t, 6, budget.limit)
+
+	usedOcrPages.Add(6)
+	assert.NoError(t, budget.exceeded())
+	usedOcrPages.Add(1)
+	assert.ErrorContains(t, budget.exceeded(), "OCR usage of 7 pages exceeds the estimate")
+
+	estimateAbortFactor = 0
+	assert.NoError(t, newOcrPageBudget(documents).exceeded(), "the budget is disabled")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Queues of the background processing
+const (
+	queueOcr     = "ocr"
+	queueTagging = "tagging"
+)
+
+// ProcessingFailureResponse describes a failed document, as returned by GET /api/failures
+type ProcessingFailureResponse struct {
+	DocumentID  int    `json:"document_id"`
+	Queue       string `json:"queue"`
+	Error       string `json:"error"`
+	Attempts    int    `json:"attempts"`
+	FirstFailed string `json:"first_failed"`
+	LastFailed  string `json:"last_failed"`
+}
+
+// backgroundWakeup interrupts the wait of the background tasks, e.g. after failed documents were re-queued
+var backgroundWakeup = make(chan struct{}, 1)
+
+// wakeBackgroundProcessing starts the next background cycle right away instead of after the polling
+// interval or backoff
+func wakeBackgroundProcessing() {
+	select {
+	case backgroundWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// queueTag returns the tag that queues a document in a queue
+func queueTag(queue string) string {
+	if queue == queueOcr {
+		return settings().AutoOcrTag
+	}
+	return settings().AutoTag
+}
+
+// recordFailure tracks a failed attempt to process a document. Tracking errors are only logged since they
+// must not hide the processing error.
+func (app *App) recordFailure(ctx context.Context, documentID int, queue string, err error) {
+	if dbErr := RecordProcessingFailure(app.Database, tenantUsername(ctx), documentID, queue, err.Error()); dbErr != nil {
+		documentLogger(documentID).Warnf("Failed to track the processing failure: %v", dbErr)
+	}
+}
+
+// clearFailure forgets the failures of a document that was processed successfully
+func (app *App) clearFailure(ctx context.Context, documentID int, queue string) {
+	if err := ClearProcessingFailure(app.Database, tenantUsername(ctx), documentID, queue); err != nil {
+		documentLogger(documentID).Warnf("Failed to clear the processing failure: %v", err)
+	}
+}
+
+// processingFailures lists the failed documents of the user in the context
+func (app *App) processingFailures(ctx context.Context) ([]ProcessingFailureResponse, error) {
+	records, err := GetProcessingFailures(app.Database, tenantUsername(ctx))
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]ProcessingFailureResponse, 0, len(records))
+	for _, record := range records {
+		failures = append(failures, ProcessingFailureResponse{
+			DocumentID:  int(record.DocumentID),
+			Queue:       record.Queue,
+			Error:       record.Error,
+			Attempts:    record.Attempts,
+			FirstFailed: record.DateFirstFailed,
+			LastFailed:  record.DateLastFailed,
+		})
+	}
+	return failures, nil
+}
+
+// retryFailedDocument puts a failed document back into the queues it failed in and starts the next
+// background cycle. It returns the queues, or none if no failure is tracked for the document.
+func (app *App) retryFailedDocument(ctx context.Context, documentID int) ([]string, error) {
+	records, err := GetDocumentProcessingFailures(app.Database, tenantUsername(ctx), documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var queues []string
+	for _, record := range records {
+		addTags := splitAndTrim(queueTag(record.Queue))
+		removeTags := splitAndTrim(settings().ProcessingFailedTag)
+		if err := app.Client.ModifyDocumentTags(ctx, documentID, addTags, removeTags); err != nil {
+			return nil, fmt.Errorf("error re-queueing document %d: %w", documentID, err)
+		}
+		queues = append(queues, record.Queue)
+	}
+	if len(queues) > 0 {
+		documentLogger(documentID).Infof("Re-queued document for %v", queues)
+		wakeBackgroundProcessing()
+	}
+	return queues, nil
+}
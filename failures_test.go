@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryFailedDocument(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+	env.db.Where("1 = 1").Delete(&ProcessingFailure{})
+
+	setTestSettings(t, func(s *runtimeSettings) {
+		s.AutoTag, s.ProcessingFailedTag, s.TaggingInProgressTag, s.TaggingDoneTag = "paperless-gpt-auto", "paperless-gpt-failed", "", ""
+	})
+
+	// Only titles are generated, which fails with the provider
+	originalTitleTemplate, originalTags, originalCorrespondents, originalCustomFields := titleTemplate, autoGenerateTags, autoGenerateCorrespondents, autoGenerateCustomFields
+	defer func() {
+		titleTemplate, autoGenerateTags, autoGenerateCorrespondents, autoGenerateCustomFields = originalTitleTemplate, originalTags, originalCorrespondents, originalCustomFields
+	}()
+	titleTemplate = template.Must(template.New("title").Parse("Title: {{.Content}}"))
+	autoGenerateTags, autoGenerateCorrespondents, autoGenerateCustomFields = "false", "false", "false"
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "paperless-gpt-auto"}, {"id": 2, "name": "paperless-gpt-failed"}, {"id": 3, "name": "Invoice"}], "next": null}`))
+	})
+
+	// The documents keep their tags between requests like in paperless-ngx
+	var mu sync.Mutex
+	tags := map[int][]int{5: {1, 3}, 7: {1}}
+	document := func(id int) map[string]any {
+		return map[string]any{"id": id, "title": fmt.Sprintf("Document %d", id), "content": "Some content", "tags": tags[id]}
+	}
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		results := []map[string]any{}
+		for _, id := range []int{5, 7} {
+			if strings.Contains(r.URL.RawQuery, "paperless-gpt-auto") && slices.Contains(tags[id], 1) {
+				results = append(results, document(id))
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"results": results, "next": nil})
+	})
+	for _, id := range []int{5, 7} {
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", id), func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Method == "PATCH" {
+				var body struct {
+					Tags []int `json:"tags"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				tags[id] = body.Tags
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(document(id))
+		})
+	}
+
+	app := &App{Client: env.client, Database: env.db, LLM: &failingLLM{err: errors.New("provider unavailable")}}
+	ctx := context.Background()
+
+	// A failed document leaves the queue and does not stop the documents after it
+	processed, err := app.processAutoTagDocuments(ctx)
+	assert.Equal(t, 0, processed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "document 5")
+	assert.Contains(t, err.Error(), "document 7")
+	assert.ElementsMatch(t, []int{2, 3}, tags[5])
+	assert.ElementsMatch(t, []int{2}, tags[7])
+
+	// The next cycle does not pick up the failed documents again
+	processed, err = app.processAutoTagDocuments(ctx)
+	assert.Equal(t, 0, processed)
+	require.NoError(t, err)
+
+	app.recordFailure(withTenant(ctx, "other-user", ""), 6, queueTagging, errors.New("invalid answer"))
+	failures, err := app.processingFailures(ctx)
+	require.NoError(t, err)
+	require.Len(t, failures, 2, "failures are listed per user")
+	failure := failureOf(failures, 5)
+	require.NotNil(t, failure)
+	assert.Equal(t, queueTagging, failure.Queue)
+	assert.Contains(t, failure.Error, "provider unavailable")
+	assert.Equal(t, 1, failure.Attempts)
+
+	queues, err := app.retryFailedDocument(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{queueTagging}, queues)
+	assert.ElementsMatch(t, []int{1, 3}, tags[5], "the document is back in the queue")
+	require.Len(t, backgroundWakeup, 1, "the background loop is woken up")
+	<-backgroundWakeup
+
+	// The re-queued document is processed again in the next cycle
+	_, err = app.processAutoTagDocuments(ctx)
+	require.Error(t, err)
+	failures, err = app.processingFailures(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failureOf(failures, 5).Attempts)
+	assert.Equal(t, 1, failureOf(failures, 7).Attempts)
+
+	queues, err = app.retryFailedDocument(ctx, 6)
+	require.NoError(t, err)
+	assert.Empty(t, queues, "failures of other users are not retried")
+
+	app.clearFailure(ctx, 5, queueTagging)
+	app.clearFailure(ctx, 7, queueTagging)
+	failures, err = app.processingFailures(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+}
+
+// failureOf returns the failure of a document, or nil
+func failureOf(failures []ProcessingFailureResponse, documentID int) *ProcessingFailureResponse {
+	for i := range failures {
+		if failures[i].DocumentID == documentID {
+			return &failures[i]
+		}
+	}
+	return nil
+}
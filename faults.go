@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// faultConfig holds the probabilities (0-1) of faults injected into paperless and LLM calls.
+// It is parsed from FAULT_INJECTION, e.g. "paperless_timeout=0.1,llm_malformed=0.2".
+type faultConfig struct {
+	PaperlessTimeout   float64
+	PaperlessRateLimit float64
+	LLMTimeout         float64
+	LLMRateLimit       float64
+	LLMMalformed       float64
+	OCRPartial         float64
+}
+
+// faultInjector decides randomly whether a fault is triggered
+type faultInjector struct {
+	config faultConfig
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// parseFaultConfig parses a comma-separated list of fault=probability pairs
+func parseFaultConfig(spec string) (faultConfig, error) {
+	var config faultConfig
+	targets := map[string]*float64{
+		"paperless_timeout":    &config.PaperlessTimeout,
+		"paperless_rate_limit": &config.PaperlessRateLimit,
+		"llm_timeout":          &config.LLMTimeout,
+		"llm_rate_limit":       &config.LLMRateLimit,
+		"llm_malformed":        &config.LLMMalformed,
+		"ocr_partial":          &config.OCRPartial,
+	}
+
+	for _, entry := range splitAndTrim(spec) {
+		name, rawProbability, found := strings.Cut(entry, "=")
+		target, known := targets[strings.TrimSpace(name)]
+		if !found || !known {
+			return config, fmt.Errorf("invalid fault %q", entry)
+		}
+		probability, err := strconv.ParseFloat(strings.TrimSpace(rawProbability), 64)
+		if err != nil || probability < 0 || probability > 1 {
+			return config, fmt.Errorf("invalid probability for fault %q", entry)
+		}
+		*target = probability
+	}
+	return config, nil
+}
+
+// newFaultInjector creates an injector; a fixed seed makes the injected faults reproducible
+func newFaultInjector(config faultConfig, seed int64) *faultInjector {
+	return &faultInjector{config: config, random: rand.New(rand.NewSource(seed))}
+}
+
+// trigger reports whether a fault with the given probability occurs
+func (injector *faultInjector) trigger(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	return injector.random.Float64() < probability
+}
+
+// faultInjectingTransport simulates timeouts and rate limiting of the paperless-ngx API
+type faultInjectingTransport struct {
+	injector *faultInjector
+	next     http.RoundTripper
+}
+
+// RoundTrip fails or throttles the request before it reaches paperless-ngx
+func (transport *faultInjectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport.injector.trigger(transport.injector.config.PaperlessTimeout) {
+		log.Debugf("Injecting timeout for %s %s", req.Method, req.URL.Path)
+		return nil, fmt.Errorf("injected fault: %w", context.DeadlineExceeded)
+	}
+	if transport.injector.trigger(transport.injector.config.PaperlessRateLimit) {
+		log.Debugf("Injecting rate limit for %s %s", req.Method, req.URL.Path)
+		return &http.Response{
+			Status:     "429 Too Many Requests",
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"1"}},
+			Body:       io.NopCloser(strings.NewReader(`{"detail": "Request was throttled (injected fault)."}`)),
+			Request:    req,
+		}, nil
+	}
+	return transport.next.RoundTrip(req)
+}
+
+// faultInjectingLLM simulates timeouts, rate limiting, malformed answers and partially transcribed pages
+type faultInjectingLLM struct {
+	llms.Model
+	injector *faultInjector
+	vision   bool // Truncate answers instead of replacing them, like a vision LLM that stopped mid-page
+}
+
+// GenerateContent fails or corrupts the answer of the wrapped LLM
+func (m *faultInjectingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	config := m.injector.config
+	if m.injector.trigger(config.LLMTimeout) {
+		return nil, fmt.Errorf("injected fault: %w", context.DeadlineExceeded)
+	}
+	if m.injector.trigger(config.LLMRateLimit) {
+		return nil, fmt.Errorf("injected fault: 429 Too Many Requests: %w", errProviderRateLimit)
+	}
+
+	response, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil || len(response.Choices) == 0 {
+		return response, err
+	}
+
+	if m.vision {
+		if m.injector.trigger(config.OCRPartial) {
+			// Cut by runes so the truncation does not also break a multi-byte character
+			content := []rune(response.Choices[0].Content)
+			response.Choices[0].Content = string(content[:len(content)/2])
+		}
+	} else if m.injector.trigger(config.LLMMalformed) {
+		response.Choices[0].Content = `Sure! Here is what I found: {"rows": [{"date": "31.02.`
+	}
+	return response, nil
+}
+
+// Call forwards to GenerateContent so faults apply to both entry points
+func (m *faultInjectingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// enableFaultInjection wraps the paperless client and the LLMs of the app with fault injection
+func (app *App) enableFaultInjection(config faultConfig, seed int64) {
+	injector := newFaultInjector(config, seed)
+
+	next := app.Client.HTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	app.Client.HTTPClient.Transport = &faultInjectingTransport{injector: injector, next: next}
+
+	if app.LLM != nil {
+		app.LLM = &faultInjectingLLM{Model: app.LLM, injector: injector}
+	}
+	if app.VisionLLM != nil {
+		app.VisionLLM = &faultInjectingLLM{Model: app.VisionLLM, injector: injector, vision: true}
+	}
+}
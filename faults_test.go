@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParseFaultConfig(t *testing.T) {
+	config, err := parseFaultConfig("paperless_timeout=0.1, llm_malformed=1,ocr_partial=0")
+	require.NoError(t, err)
+	assert.Equal(t, faultConfig{PaperlessTimeout: 0.1, LLMMalformed: 1}, config)
+
+	for _, invalid := range []string{"unknown=0.5", "llm_timeout", "llm_timeout=2", "llm_timeout=x"} {
+		_, err := parseFaultConfig(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	app := &App{Client: env.client, LLM: &mockLLM{}, VisionLLM: &mockLLM{}}
+	app.enableFaultInjection(faultConfig{PaperlessRateLimit: 1, LLMMalformed: 1, OCRPartial: 1}, 1)
+
+	// Rate limited requests never reach the server
+	requests := env.requestCount
+	resp, err := app.Client.Do(context.Background(), "GET", "api/tags/", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, requests, env.requestCount)
+
+	_, err = app.Client.GetAllTags(context.Background())
+	assert.Error(t, err)
+
+	answer, err := llms.GenerateFromSinglePrompt(context.Background(), app.LLM, "prompt")
+	require.NoError(t, err)
+	_, err = parseExtractedRows(answer)
+	assert.Error(t, err)
+
+	page, err := llms.GenerateFromSinglePrompt(context.Background(), app.VisionLLM, "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "test r", page)
+
+	// Multi-byte characters are not split by the truncation
+	app.VisionLLM = &faultInjectingLLM{Model: &scriptedLLM{responses: []string{"Grüße"}}, injector: app.VisionLLM.(*faultInjectingLLM).injector, vision: true}
+	page, err = llms.GenerateFromSinglePrompt(context.Background(), app.VisionLLM, "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "Gr", page)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxStringFieldLength is the length paperless-ngx allows for string custom fields
+const maxStringFieldLength = 128
+
+// customFieldMapping routes generated outputs to custom fields, read from CUSTOM_FIELD_MAPPING, e.g.
+// "summary=Summary,ocr_provider=OCR engine"
+var customFieldMapping []fieldMapping
+
+// fieldMapping routes an output to a custom field, given by name or ID
+type fieldMapping struct {
+	Output string
+	Field  string
+}
+
+// suggestionOutputs are the outputs of suggestions that can be mapped. Summary, created date and language
+// are only generated for documents if mapped.
+var suggestionOutputs = []string{"summary", "created_date", "language", "title", "correspondent", "tags", "llm_provider", "llm_model"}
+
+// mappingOutputs lists all outputs: those of suggestions and the metadata of background OCR with an ocr_ prefix
+func mappingOutputs() []string {
+	outputs := slices.Clone(suggestionOutputs)
+	for _, key := range ocrMetadataKeys {
+		outputs = append(outputs, "ocr_"+key)
+	}
+	return outputs
+}
+
+// parseCustomFieldMapping parses a comma-separated list of output=custom field pairs
+func parseCustomFieldMapping(spec string) ([]fieldMapping, error) {
+	var mapping []fieldMapping
+	outputs := mappingOutputs()
+	for _, entry := range splitAndTrim(spec) {
+		output, field, found := strings.Cut(entry, "=")
+		output, field = strings.ToLower(strings.TrimSpace(output)), strings.TrimSpace(field)
+		if !found || field == "" {
+			return nil, fmt.Errorf("expected output=custom field, got %q", entry)
+		}
+		if !slices.Contains(outputs, output) {
+			return nil, fmt.Errorf("unknown output %q, expected one of %s", output, strings.Join(outputs, ", "))
+		}
+		mapping = append(mapping, fieldMapping{Output: output, Field: field})
+	}
+	return mapping, nil
+}
+
+// mappedField is a mapped output with its custom field in paperless-ngx
+type mappedField struct {
+	output string
+	field  CustomField
+}
+
+// resolveFieldMapping looks up the custom fields of the mapped outputs accepted by include. Fields that do not
+// exist in paperless-ngx are skipped with a warning.
+func resolveFieldMapping(customFields []CustomField, include func(output string) bool, logger *logrus.Entry) []mappedField {
+	var resolved []mappedField
+	for _, mapping := range customFieldMapping {
+		if !include(mapping.Output) {
+			continue
+		}
+		id, err := strconv.Atoi(mapping.Field)
+		index := slices.IndexFunc(customFields, func(field CustomField) bool {
+			return field.Name == mapping.Field || (err == nil && field.ID == id)
+		})
+		if index < 0 {
+			logger.Warnf("Custom field %s for %s does not exist in paperless-ngx", mapping.Field, mapping.Output)
+			continue
+		}
+		resolved = append(resolved, mappedField{output: mapping.Output, field: customFields[index]})
+	}
+	return resolved
+}
+
+// isSuggestionOutput reports whether an output is produced with the suggestions
+func isSuggestionOutput(output string) bool {
+	return slices.Contains(suggestionOutputs, output)
+}
+
+// mapsSuggestionOutputs reports whether any output of suggestions is mapped
+func mapsSuggestionOutputs() bool {
+	return slices.ContainsFunc(customFieldMapping, func(mapping fieldMapping) bool { return isSuggestionOutput(mapping.Output) })
+}
+
+// suggestedOutputs are the outputs of a document's suggestion that are known before the mapped fields are
+// filled, and the document type the prompts are chosen for
+type suggestedOutputs struct {
+	Title         string
+	Correspondent string
+	Tags          []string
+	DocumentType  string
+}
+
+// mappedSuggestionFields returns the values of the mapped custom fields for a document. Outputs that are
+// not part of the suggestion are generated here. Empty outputs and values that do not fit their field are skipped.
+func (app *App) mappedSuggestionFields(ctx context.Context, fields []mappedField, doc Document, content string, suggested suggestedOutputs, logger *logrus.Entry) ([]CustomFieldValue, error) {
+	var values []CustomFieldValue
+	for _, mapped := range fields {
+		var text string
+		switch mapped.output {
+		case "summary":
+			summary, err := app.getSuggestedSummary(ctx, content, suggested.Title, suggested.DocumentType, logger)
+			if err != nil {
+				return nil, fmt.Errorf("error generating summary: %w", err)
+			}
+			if translateSummaries && translatesDocumentType(suggested.DocumentType) {
+				if summary, err = app.translate(ctx, "summary", summary); err != nil {
+					return nil, err
+				}
+			}
+			text = summary
+		case "created_date":
+			if headers, ok := emailHeadersOf(doc); ok && headers.Date != "" {
+				text = headers.Date
+				break
+			}
+			date, err := app.getSuggestedCreatedDate(ctx, content, suggested.Title, suggested.DocumentType, logger)
+			if err != nil {
+				return nil, fmt.Errorf("error generating created date: %w", err)
+			}
+			text = date
+		case "language":
+			languages, err := app.detectPageLanguages(ctx, content)
+			if err != nil {
+				return nil, fmt.Errorf("error detecting languages: %w", err)
+			}
+			text = strings.Join(languages, ", ")
+		case "title":
+			text = suggested.Title
+		case "correspondent":
+			text = suggested.Correspondent
+		case "tags":
+			text = strings.Join(suggested.Tags, ", ")
+		case "llm_provider":
+			text = llmProvider
+		case "llm_model":
+			text = llmModel
+		}
+
+		if text == "" {
+			continue
+		}
+		value, ok := mappedFieldValue(mapped.field.DataType, text)
+		if !ok {
+			logger.Warnf("Custom field %s of type %s cannot hold %s %q", mapped.field.Name, mapped.field.DataType, mapped.output, text)
+			continue
+		}
+		logger.Debugf("Mapped %s of document %d to custom field %s", mapped.output, doc.ID, mapped.field.Name)
+		values = append(values, CustomFieldValue{Field: mapped.field.ID, Value: value})
+	}
+	return values, nil
+}
+
+// mappedFieldValue converts a text output to a custom field data type. Text longer than a string field allows
+// is shortened; dates only fit date fields.
+func mappedFieldValue(dataType string, text string) (interface{}, bool) {
+	switch dataType {
+	case "string":
+		return truncateRunes(text, maxStringFieldLength), true
+	case "longtext":
+		return text, true
+	case "date":
+		_, err := time.Parse("2006-01-02", text)
+		return text, err == nil
+	}
+	return nil, false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCustomFieldMapping(t *testing.T) {
+	mapping, err := parseCustomFieldMapping("summary=Summary, OCR_Provider = 7 ,language=Document language")
+	require.NoError(t, err)
+	assert.Equal(t, []fieldMapping{
+		{Output: "summary", Field: "Summary"},
+		{Output: "ocr_provider", Field: "7"},
+		{Output: "language", Field: "Document language"},
+	}, mapping)
+
+	for _, spec := range []string{"summary", "summary=", "amount=Total", "provider=7"} {
+		_, err := parseCustomFieldMapping(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMappedFieldValue(t *testing.T) {
+	value, ok := mappedFieldValue("string", strings.Repeat("a", 200))
+	assert.True(t, ok)
+	assert.Len(t, value, maxStringFieldLength)
+
+	value, ok = mappedFieldValue("longtext", strings.Repeat("a", 200))
+	assert.True(t, ok)
+	assert.Len(t, value, 200)
+
+	_, ok = mappedFieldValue("date", "2024-03-12")
+	assert.True(t, ok)
+	_, ok = mappedFieldValue("date", "Invoice")
+	assert.False(t, ok)
+	_, ok = mappedFieldValue("integer", "Invoice")
+	assert.False(t, ok)
+}
+
+func TestMappedSuggestionFields(t *testing.T) {
+	originalMapping, originalTemplate, originalProvider := customFieldMapping, summaryTemplate, llmProvider
+	defer func() {
+		customFieldMapping, summaryTemplate, llmProvider = originalMapping, originalTemplate, originalProvider
+	}()
+	summaryTemplate = template.Must(template.New("summary").Parse("Summarize {{.Title}}: {{.Content}}"))
+	llmProvider = "openai"
+
+	customFieldMapping = []fieldMapping{
+		{Output: "summary", Field: "Summary"},
+		{Output: "language", Field: "12"},
+		{Output: "tags", Field: "Missing"},
+		{Output: "llm_provider", Field: "Generated by"},
+		{Output: "ocr_provider", Field: "Summary"},
+	}
+	customFields := []CustomField{
+		{ID: 10, Name: "Summary", DataType: "longtext"},
+		{ID: 11, Name: "Generated by", DataType: "string"},
+		{ID: 12, Name: "Language", DataType: "string"},
+	}
+	logger := logrus.WithField("test", "test")
+
+	// OCR outputs and fields missing in paperless-ngx are skipped
+	fields := resolveFieldMapping(customFields, isSuggestionOutput, logger)
+	require.Len(t, fields, 3)
+
+	llm := &scriptedLLM{responses: []string{"An invoice for a laptop.", "de, en"}}
+	app := &App{LLM: llm}
+	values, err := app.mappedSuggestionFields(context.Background(), fields, Document{ID: 1}, "Rechnung Laptop", suggestedOutputs{Title: "Invoice"}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, []CustomFieldValue{
+		{Field: 10, Value: "An invoice for a laptop."},
+		{Field: 12, Value: "de, en"},
+		{Field: 11, Value: "openai"},
+	}, values)
+	assert.Len(t, llm.conversations, 2)
+}
+
+func TestOcrMetadataFieldValuesMapping(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalMapping := customFieldMapping
+	defer func() { customFieldMapping = originalMapping }()
+	customFieldMapping = []fieldMapping{
+		{Output: "ocr_provider", Field: "OCR engine"},
+		{Output: "ocr_pages", Field: "3"},
+		{Output: "summary", Field: "Summary"},
+	}
+
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 3, "name": "Pages", "data_type": "integer"},
+			{"id": 6, "name": "OCR engine", "data_type": "string"},
+			{"id": 7, "name": "Summary", "data_type": "longtext"}
+		], "next": null}`))
+	})
+
+	app := &App{Client: env.client}
+	values, err := app.ocrMetadataFieldValues(context.Background(), 1, ocrMetadata{Pages: 2, Provider: "mistral_ocr"})
+	require.NoError(t, err)
+	assert.Equal(t, []CustomFieldValue{
+		{Field: 6, Value: "mistral_ocr"},
+		{Field: 3, Value: int64(2)},
+	}, values)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// confidenceLine matches the last line of a handwriting transcription, e.g. "CONFIDENCE: 85" or "Confidence: 85%"
+var confidenceLine = regexp.MustCompile(`(?i)^\**confidence\**:?\**\s*(\d{1,3}(?:\.\d+)?)\s*%?\s*$`)
+
+// ocrHandwrittenPage transcribes a handwritten page with the handwriting prompt and, if configured, the
+// handwriting vision LLM (HANDWRITING_LLM_PROVIDER). Pages with a confidence below HANDWRITING_MIN_CONFIDENCE
+// need review.
+func (app *App) ocrHandwrittenPage(ctx context.Context, jpegBytes []byte, page ocrPageContext, logger *logrus.Entry) (pageTranscription, error) {
+	model, provider, modelName := app.visionModel(ctx)
+	if app.HandwritingVisionLLM != nil {
+		model, provider, modelName = app.HandwritingVisionLLM, handwritingProvider, handwritingModel
+	}
+
+	response, err := app.transcribePage(ctx, model, provider, modelName, jpegBytes, page, logger)
+	if err != nil {
+		return pageTranscription{}, err
+	}
+
+	text, confidence, ok := parseTranscriptionConfidence(response)
+	if !ok {
+		logger.Warn("Handwriting transcription did not report a confidence")
+		return pageTranscription{Text: text}, nil
+	}
+	logger.WithField("confidence", confidence).Debug("Transcribed handwritten page")
+	return pageTranscription{
+		Text:        text,
+		NeedsReview: confidence < handwritingMinConfidence,
+		Confidence:  &confidence,
+	}, nil
+}
+
+// parseTranscriptionConfidence splits the confidence line (0-100 percent) off a transcription and returns it
+// as a value between 0 and 1
+func parseTranscriptionConfidence(response string) (string, float64, bool) {
+	text := strings.TrimSpace(stripReasoning(response))
+	lastBreak := strings.LastIndex(text, "\n")
+	match := confidenceLine.FindStringSubmatch(strings.TrimSpace(text[lastBreak+1:]))
+	if match == nil {
+		return text, 0, false
+	}
+	percent, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || percent > 100 {
+		return text, 0, false
+	}
+	if lastBreak < 0 {
+		return "", percent / 100, true
+	}
+	return strings.TrimSpace(text[:lastBreak]), percent / 100, true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParseTranscriptionConfidence(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		text       string
+		confidence float64
+		ok         bool
+	}{
+		{"plain", "Dear Anna,\nsee you soon\nCONFIDENCE: 85", "Dear Anna,\nsee you soon", 0.85, true},
+		{"percent and markdown", "Dear Anna,\n\n**Confidence:** 40%", "Dear Anna,", 0.4, true},
+		{"missing", "Dear Anna,\nsee you soon", "Dear Anna,\nsee you soon", 0, false},
+		{"out of range", "Dear Anna,\nCONFIDENCE: 850", "Dear Anna,\nCONFIDENCE: 850", 0, false},
+		{"only confidence", "CONFIDENCE: 0", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, confidence, ok := parseTranscriptionConfidence(tt.response)
+			assert.Equal(t, tt.text, text)
+			assert.InDelta(t, tt.confidence, confidence, 0.0001)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestOcrPage_Handwritten(t *testing.T) {
+	originalOcr, originalHandwriting, originalMin := ocrTemplate, handwritingTemplate, handwritingMinConfidence
+	ocrTemplate = template.Must(template.New("ocr").Parse("Transcribe the printed page"))
+	handwritingTemplate = template.Must(template.New("handwriting_ocr").Parse("Transcribe the handwritten page {{.PageNumber}}"))
+	handwritingMinConfidence = 0.6
+	defer func() {
+		ocrTemplate, handwritingTemplate, handwritingMinConfidence = originalOcr, originalHandwriting, originalMin
+	}()
+
+	logger := logrus.WithField("test", "handwriting")
+
+	t.Run("low confidence pages need review", func(t *testing.T) {
+		vision := &scriptedLLM{}
+		handwriting := &scriptedLLM{responses: []string{"Meeting at [illegible] o'clock\nCONFIDENCE: 45"}}
+		app := &App{VisionLLM: vision, HandwritingVisionLLM: handwriting}
+
+		transcription, err := app.ocrPage(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1, Handwritten: true}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Meeting at [illegible] o'clock", transcription.Text)
+		assert.True(t, transcription.NeedsReview)
+		require.NotNil(t, transcription.Confidence)
+		assert.InDelta(t, 0.45, *transcription.Confidence, 0.0001)
+
+		assert.Empty(t, vision.conversations)
+		require.Len(t, handwriting.conversations, 1)
+		assert.Equal(t, "Transcribe the handwritten page 1", handwriting.conversations[0][0].Parts[1].(llms.TextContent).Text)
+	})
+
+	t.Run("vision LLM is used without a handwriting model", func(t *testing.T) {
+		app := &App{VisionLLM: &scriptedLLM{responses: []string{"Shopping list: milk, eggs\nCONFIDENCE: 90"}}}
+
+		transcription, err := app.ocrPage(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1, Handwritten: true}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Shopping list: milk, eggs", transcription.Text)
+		assert.False(t, transcription.NeedsReview)
+	})
+
+	t.Run("printed pages keep the regular prompt", func(t *testing.T) {
+		vision := &scriptedLLM{responses: []string{"Invoice 42"}}
+		app := &App{VisionLLM: vision, HandwritingVisionLLM: &scriptedLLM{}}
+
+		transcription, err := app.ocrPage(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice 42", transcription.Text)
+		assert.Nil(t, transcription.Confidence)
+		assert.Equal(t, "Transcribe the printed page", vision.conversations[0][0].Parts[1].(llms.TextContent).Text)
+	})
+}
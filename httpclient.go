@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Timeouts of the paperless-ngx, LLM and vision LLM clients; 0 means no timeout
+var (
+	paperlessTimeout time.Duration // Will be read from PAPERLESS_TIMEOUT
+	llmTimeout       time.Duration // Will be read from LLM_TIMEOUT
+	visionLlmTimeout time.Duration // Will be read from VISION_LLM_TIMEOUT
+)
+
+// newHTTPClient builds an HTTP client with its own transport, so timeouts and proxies of one provider never
+// affect the others. Without a proxy the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables apply.
+func newHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
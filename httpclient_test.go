@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client, err := newHTTPClient(30*time.Second, "http://proxy.local:3128")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, client.Timeout)
+	assert.NotSame(t, http.DefaultTransport, client.Transport)
+
+	req, _ := http.NewRequest("GET", "https://api.openai.com/v1/models", nil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "proxy.local:3128", proxyURL.Host)
+
+	// Clients do not share their transport
+	other, err := newHTTPClient(0, "")
+	require.NoError(t, err)
+	assert.NotSame(t, client.Transport, other.Transport)
+	assert.Zero(t, other.Timeout)
+
+	_, err = newHTTPClient(0, "not a proxy")
+	assert.Error(t, err)
+}
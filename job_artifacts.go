@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JobArtifactsMetadata is the metadata.json of a job artifacts bundle
+type JobArtifactsMetadata struct {
+	JobID           string             `json:"job_id"`
+	DocumentID      int                `json:"document_id"`
+	Status          string             `json:"status"`
+	Error           string             `json:"error,omitempty"`
+	CreatedAt       *time.Time         `json:"created_at,omitempty"`
+	UpdatedAt       *time.Time         `json:"updated_at,omitempty"`
+	PagesDone       int                `json:"pages_done"`
+	PeakRenderBytes int64              `json:"peak_render_memory_bytes"`
+	Provider        string             `json:"provider,omitempty"`
+	Model           string             `json:"model,omitempty"`
+	Version         string             `json:"version"`
+	Pages           []JobArtifactsPage `json:"pages"`
+}
+
+// JobArtifactsPage describes a stored OCR page in the metadata of a job artifacts bundle
+type JobArtifactsPage struct {
+	PageIndex   int      `json:"page_index"`
+	File        string   `json:"file,omitempty"` // Text file in the bundle, empty for blank pages
+	Blank       bool     `json:"blank"`
+	Languages   []string `json:"languages,omitempty"`
+	NeedsReview bool     `json:"needs_review"`
+	Confidence  *float64 `json:"confidence,omitempty"`
+	DateAdded   string   `json:"date_added"`
+}
+
+// writeJobArtifacts writes a zip with the combined OCR text (result.txt), the text of every stored page
+// (pages/page-001.txt, ...) and metadata.json. The page results are the latest of the job's document.
+func writeJobArtifacts(w io.Writer, job *Job, result string, pages []OcrPageResult) error {
+	archive := zip.NewWriter(w)
+	metadata := JobArtifactsMetadata{
+		JobID:           job.ID,
+		DocumentID:      job.DocumentID,
+		Status:          job.Status,
+		PagesDone:       job.PagesDone,
+		PeakRenderBytes: job.PeakRenderBytes,
+		Provider:        visionLlmProvider,
+		Model:           visionLlmModel,
+		Version:         version,
+		Pages:           []JobArtifactsPage{},
+	}
+	if job.Options.overridesVisionModel() {
+		metadata.Provider, metadata.Model = job.Options.visionModel()
+	}
+	if job.Status == "failed" {
+		metadata.Error = job.Result
+	}
+	if !job.CreatedAt.IsZero() {
+		metadata.CreatedAt, metadata.UpdatedAt = &job.CreatedAt, &job.UpdatedAt
+	}
+
+	if result != "" {
+		if err := writeZipFile(archive, "result.txt", []byte(result)); err != nil {
+			return err
+		}
+	}
+	for _, page := range pages {
+		entry := JobArtifactsPage{
+			PageIndex:   page.PageIndex,
+			Blank:       page.Blank,
+			Languages:   splitAndTrim(page.Languages),
+			NeedsReview: page.NeedsReview,
+			Confidence:  page.Confidence,
+			DateAdded:   page.DateAdded,
+		}
+		if !page.Blank {
+			entry.File = fmt.Sprintf("pages/page-%03d.txt", page.PageIndex+1)
+			if err := writeZipFile(archive, entry.File, []byte(page.Text)); err != nil {
+				return err
+			}
+		}
+		metadata.Pages = append(metadata.Pages, entry)
+	}
+
+	encoded, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipFile(archive, "metadata.json", encoded); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeZipFile adds a file to a zip archive
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	_, err = file.Write(content)
+	return err
+}
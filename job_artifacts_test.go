@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJobArtifacts(t *testing.T) {
+	job := &Job{ID: "job-1", DocumentID: 4, Status: "completed", PagesDone: 3}
+	pages := []OcrPageResult{
+		{PageIndex: 0, Text: "First page", Languages: "de,en", DateAdded: "2024-06-01T13:00:00Z"},
+		{PageIndex: 1, Blank: true, DateAdded: "2024-06-01T13:00:05Z"},
+		{PageIndex: 2, Text: "Third page", NeedsReview: true, DateAdded: "2024-06-01T13:00:10Z"},
+	}
+
+	var buffer bytes.Buffer
+	require.NoError(t, writeJobArtifacts(&buffer, job, "First page\n\nThird page", pages))
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[file.Name] = string(content)
+	}
+	assert.Equal(t, "First page\n\nThird page", files["result.txt"])
+	assert.Equal(t, "First page", files["pages/page-001.txt"])
+	assert.Equal(t, "Third page", files["pages/page-003.txt"])
+	assert.NotContains(t, files, "pages/page-002.txt")
+
+	var metadata JobArtifactsMetadata
+	require.NoError(t, json.Unmarshal([]byte(files["metadata.json"]), &metadata))
+	assert.Equal(t, "job-1", metadata.JobID)
+	assert.Equal(t, 4, metadata.DocumentID)
+	require.Len(t, metadata.Pages, 3)
+	assert.Equal(t, []string{"de", "en"}, metadata.Pages[0].Languages)
+	assert.True(t, metadata.Pages[1].Blank)
+	assert.Empty(t, metadata.Pages[1].File)
+	assert.True(t, metadata.Pages[2].NeedsReview)
+}
+
+func TestStoredJobResultOwner(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	app := &App{Database: db}
+	original := jobStore
+	defer func() { jobStore = original }()
+	jobStore = newJobStore(db)
+	require.NoError(t, SaveOcrJobResult(db, "stored-job", 4, "alice", "secret text"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/jobs/ocr/:job_id/result", app.getJobResultHandler)
+	router.GET("/api/jobs/ocr/:job_id/artifacts", app.getJobArtifactsHandler)
+
+	for _, path := range []string{"/api/jobs/ocr/stored-job/result", "/api/jobs/ocr/stored-job/artifacts"} {
+		for username, status := range map[string]int{"alice": http.StatusOK, "bob": http.StatusNotFound} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req = req.WithContext(withTenant(context.Background(), username, ""))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			assert.Equal(t, status, recorder.Code, "%s as %s", path, username)
+			if status == http.StatusNotFound {
+				assert.NotContains(t, recorder.Body.String(), "secret text")
+			}
+		}
+	}
+}
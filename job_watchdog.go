@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// jobStallTimeout is how long an OCR job may run without finishing a page before the watchdog cancels it and
+// restarts its worker, read from JOB_STALL_TIMEOUT. 0 disables the watchdog.
+var jobStallTimeout = 30 * time.Minute
+
+// errJobStalled is the cause of jobs cancelled by the watchdog
+var errJobStalled = errors.New("the job made no progress within JOB_STALL_TIMEOUT and was cancelled")
+
+// jobWatchdog tracks the job each worker is processing. A job that finishes no page within the timeout is
+// cancelled and its worker replaced, so a single pathological document cannot halt the queue even if the
+// worker never returns from it.
+type jobWatchdog struct {
+	sync.Mutex
+	store   *JobStore
+	timeout time.Duration
+	workers map[int]*watchedJob // Running job per worker ID
+	run     func(workerID int)  // Worker loop, also used to start replacements
+	nextID  int
+}
+
+// watchedJob is a job being processed by a worker
+type watchedJob struct {
+	jobID        string
+	lastProgress time.Time
+	cancel       context.CancelCauseFunc
+	stalled      bool
+}
+
+// newJobWatchdog creates a watchdog for the jobs of the store
+func newJobWatchdog(store *JobStore, timeout time.Duration) *jobWatchdog {
+	return &jobWatchdog{store: store, timeout: timeout, workers: map[int]*watchedJob{}}
+}
+
+// start starts the workers and, unless disabled, the loop checking them for stalled jobs
+func (w *jobWatchdog) start(numWorkers int, run func(workerID int)) {
+	w.Lock()
+	w.run = run
+	w.nextID = numWorkers
+	w.Unlock()
+	for i := 0; i < numWorkers; i++ {
+		go run(i)
+	}
+
+	if w.timeout == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(max(min(w.timeout/4, time.Minute), time.Second))
+		defer ticker.Stop()
+		for now := range ticker.C {
+			w.check(now)
+		}
+	}()
+}
+
+// processJob processes a job on a worker and reports whether the worker was replaced meanwhile and has to stop
+func (w *jobWatchdog) processJob(app *App, workerID int, job *Job) (replaced bool) {
+	ctx, finish := w.watch(context.Background(), workerID, job.ID)
+	processJob(ctx, app, job)
+	return finish()
+}
+
+// watch registers the job of a worker. Every page the job finishes resets its timeout. The returned function
+// unregisters it and reports whether the job stalled.
+func (w *jobWatchdog) watch(ctx context.Context, workerID int, jobID string) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	watched := &watchedJob{jobID: jobID, lastProgress: time.Now(), cancel: cancel}
+
+	w.Lock()
+	w.workers[workerID] = watched
+	w.Unlock()
+
+	ctx = withJobProgress(ctx, func(int) {
+		w.Lock()
+		watched.lastProgress = time.Now()
+		w.Unlock()
+	})
+	return ctx, func() bool {
+		w.Lock()
+		defer w.Unlock()
+		if w.workers[workerID] == watched {
+			delete(w.workers, workerID)
+		}
+		cancel(nil)
+		return watched.stalled
+	}
+}
+
+// check cancels the jobs that made no progress since the timeout, marks them as failed and starts a
+// replacement for each of their workers
+func (w *jobWatchdog) check(now time.Time) {
+	type stalledJob struct {
+		workerID int
+		job      *watchedJob
+	}
+	var stalled []stalledJob
+	w.Lock()
+	for workerID, watched := range w.workers {
+		if !watched.stalled && now.Sub(watched.lastProgress) >= w.timeout {
+			watched.stalled = true
+			delete(w.workers, workerID)
+			stalled = append(stalled, stalledJob{workerID: workerID, job: watched})
+		}
+	}
+	w.Unlock()
+
+	for _, s := range stalled {
+		logger.Errorf("Job %s on worker %d made no progress for %s, cancelling it and restarting the worker", s.job.jobID, s.workerID, w.timeout)
+		var dump bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&dump, 2); err == nil {
+			logger.Errorf("Goroutines while job %s stalled:\n%s", s.job.jobID, dump.String())
+		}
+		s.job.cancel(errJobStalled)
+		if err := w.store.updateJobStatus(s.job.jobID, "failed", errJobStalled.Error()); err != nil {
+			logger.Errorf("%v", err)
+		}
+		w.startReplacement(s.workerID)
+	}
+}
+
+// startReplacement starts a new worker for one stuck on a stalled job
+func (w *jobWatchdog) startReplacement(stuckWorkerID int) {
+	w.Lock()
+	workerID := w.nextID
+	w.nextID++
+	run := w.run
+	w.Unlock()
+	if run == nil {
+		return
+	}
+	logger.Warnf("Starting worker %d to replace worker %d", workerID, stuckWorkerID)
+	go run(workerID)
+}
+
+// jobProgressKey is the context key of the function notified about the pages an OCR job finished
+type jobProgressKey struct{}
+
+// withJobProgress returns a context in which finished pages are reported to fn, in addition to the
+// functions of the parent context
+func withJobProgress(ctx context.Context, fn func(pagesDone int)) context.Context {
+	parent, _ := ctx.Value(jobProgressKey{}).(func(int))
+	return context.WithValue(ctx, jobProgressKey{}, func(pagesDone int) {
+		fn(pagesDone)
+		if parent != nil {
+			parent(pagesDone)
+		}
+	})
+}
+
+// reportPagesDone reports the number of pages an OCR job finished so far
+func reportPagesDone(ctx context.Context, pagesDone int) {
+	if fn, ok := ctx.Value(jobProgressKey{}).(func(int)); ok {
+		fn(pagesDone)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobWatchdogReplacesStalledWorker(t *testing.T) {
+	store := newTestJobStore(t, &Job{ID: "stuck", Status: "in_progress"})
+	watchdog := newJobWatchdog(store, time.Minute)
+	started := make(chan int, 2)
+	watchdog.start(0, func(workerID int) { started <- workerID })
+	watchdog.nextID = 1
+
+	ctx, finish := watchdog.watch(context.Background(), 0, "stuck")
+
+	// Within the timeout nothing happens
+	watchdog.check(time.Now().Add(30 * time.Second))
+	assert.NoError(t, ctx.Err())
+
+	watchdog.check(time.Now().Add(2 * time.Minute))
+	assert.ErrorIs(t, context.Cause(ctx), errJobStalled)
+	job, _ := store.getJob("stuck")
+	assert.Equal(t, "failed", job.Status)
+	assert.Equal(t, errJobStalled.Error(), job.Result)
+
+	select {
+	case workerID := <-started:
+		assert.Equal(t, 1, workerID)
+	case <-time.After(time.Second):
+		t.Fatal("no replacement worker started")
+	}
+
+	// The stuck worker stops once its job returns, and the job is not cancelled twice
+	assert.True(t, finish())
+	watchdog.check(time.Now().Add(time.Hour))
+	assert.Empty(t, started)
+}
+
+func TestJobWatchdogProgressResetsTimeout(t *testing.T) {
+	store := newTestJobStore(t, &Job{ID: "busy", Status: "in_progress"})
+	watchdog := newJobWatchdog(store, time.Minute)
+
+	ctx, finish := watchdog.watch(context.Background(), 0, "busy")
+	var reported []int
+	ctx = withJobProgress(ctx, func(pagesDone int) { reported = append(reported, pagesDone) })
+
+	watchdog.Lock()
+	watchdog.workers[0].lastProgress = time.Now().Add(-50 * time.Second)
+	watchdog.Unlock()
+	reportPagesDone(ctx, 1)
+
+	watchdog.check(time.Now().Add(30 * time.Second))
+	require.NoError(t, ctx.Err())
+	assert.Equal(t, []int{1}, reported)
+	assert.False(t, finish())
+	assert.Error(t, ctx.Err(), "the context is released when the job finishes")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// jobWebhookTimeout bounds the delivery of a job webhook, which runs in the background
+const jobWebhookTimeout = 30 * time.Second
+
+// Events posted to JOB_WEBHOOK_URL
+const (
+	jobEventOcrFinished         = "ocr_job_finished"
+	jobEventSuggestionsFinished = "suggestions_finished"
+)
+
+// JobWebhookDocument is the outcome of a job for one document
+type JobWebhookDocument struct {
+	DocumentID int                    `json:"document_id"`
+	Status     string                 `json:"status"` // "completed" or "failed"
+	Error      string                 `json:"error,omitempty"`
+	Changes    map[string]interface{} `json:"changes,omitempty"` // Changed or suggested fields with their new values
+}
+
+// JobWebhook is posted to JOB_WEBHOOK_URL when an OCR job or a suggestion batch finishes
+type JobWebhook struct {
+	Event     string               `json:"event"`            // "ocr_job_finished" or "suggestions_finished"
+	Source    string               `json:"source"`           // "api" for requests, "auto" for background processing
+	JobID     string               `json:"job_id,omitempty"` // Set for OCR jobs submitted through the API
+	Applied   bool                 `json:"applied"`          // Whether the changes were written to paperless-ngx
+	Documents []JobWebhookDocument `json:"documents"`
+	Date      time.Time            `json:"date"`
+}
+
+// documentOutcome describes the result of a job for one document
+func documentOutcome(documentID int, err error, changes map[string]interface{}) JobWebhookDocument {
+	if err != nil {
+		return JobWebhookDocument{DocumentID: documentID, Status: "failed", Error: err.Error()}
+	}
+	return JobWebhookDocument{DocumentID: documentID, Status: "completed", Changes: changes}
+}
+
+// suggestionChanges summarizes the fields a suggestion changes
+func suggestionChanges(suggestion DocumentSuggestion) map[string]interface{} {
+	changes := map[string]interface{}{}
+	original := suggestion.OriginalDocument
+	if suggestion.SuggestedTitle != "" && suggestion.SuggestedTitle != original.Title {
+		changes["title"] = suggestion.SuggestedTitle
+	}
+	if len(suggestion.SuggestedTags) > 0 && !hasSameTags(original.Tags, suggestion.SuggestedTags) {
+		changes["tags"] = suggestion.SuggestedTags
+	}
+	if suggestion.SuggestedCorrespondent != "" && suggestion.SuggestedCorrespondent != original.Correspondent {
+		changes["correspondent"] = suggestion.SuggestedCorrespondent
+	}
+	if len(suggestion.SuggestedCustomFields) > 0 {
+		changes["custom_fields"] = suggestion.SuggestedCustomFields
+	}
+	if suggestion.SuggestedCreatedDate != "" && suggestion.SuggestedCreatedDate != original.CreatedDate {
+		changes["created_date"] = suggestion.SuggestedCreatedDate
+	}
+	return changes
+}
+
+// ocrChanges summarizes the text an OCR run produced
+func ocrChanges(pages int, text string) map[string]interface{} {
+	return map[string]interface{}{"pages": pages, "characters": len([]rune(text))}
+}
+
+// notifyJobFinished posts a job webhook to JOB_WEBHOOK_URL and publishes it to the MQTT events topic in the
+// background, so a slow receiver does not hold up processing. Delivery failures are only logged.
+// The documents are also counted for the usage statistics.
+func notifyJobFinished(ctx context.Context, webhook JobWebhook) {
+	recordUsage(webhook)
+	if jobWebhookURL == "" && mqttStatus == nil {
+		return
+	}
+	webhook.Date = time.Now()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobWebhookTimeout)
+		defer cancel()
+		if jobWebhookURL != "" {
+			if err := postWebhook(ctx, jobWebhookURL, webhook); err != nil {
+				log.Warnf("Failed to deliver %s webhook: %v", webhook.Event, err)
+			}
+		}
+		mqttStatus.publishJSON(ctx, "events", webhook, false)
+	}()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionChanges(t *testing.T) {
+	suggestion := DocumentSuggestion{
+		ID:                     1,
+		OriginalDocument:       Document{ID: 1, Title: "scan_0001", Tags: []string{"inbox"}, Correspondent: "ACME"},
+		SuggestedTitle:         "Invoice ACME",
+		SuggestedTags:          []string{"inbox"},
+		SuggestedCorrespondent: "ACME",
+	}
+	assert.Equal(t, map[string]interface{}{"title": "Invoice ACME"}, suggestionChanges(suggestion))
+
+	suggestion.SuggestedTags = []string{"invoice"}
+	assert.Equal(t, []string{"invoice"}, suggestionChanges(suggestion)["tags"])
+}
+
+func TestNotifyJobFinished(t *testing.T) {
+	originalURL := jobWebhookURL
+	defer func() { jobWebhookURL = originalURL }()
+
+	received := make(chan JobWebhook, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload JobWebhook
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+	jobWebhookURL = webhook.URL
+
+	notifyJobFinished(context.Background(), JobWebhook{
+		Event:  jobEventOcrFinished,
+		Source: "api",
+		JobID:  "job-1",
+		Documents: []JobWebhookDocument{
+			documentOutcome(4, nil, ocrChanges(2, "Käse")),
+			documentOutcome(5, errors.New("vision model unavailable"), nil),
+		},
+	})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "ocr_job_finished", payload.Event)
+		assert.Equal(t, "job-1", payload.JobID)
+		assert.False(t, payload.Date.IsZero())
+		require.Len(t, payload.Documents, 2)
+		assert.Equal(t, JobWebhookDocument{
+			DocumentID: 4,
+			Status:     "completed",
+			Changes:    map[string]interface{}{"pages": float64(2), "characters": float64(4)},
+		}, payload.Documents[0])
+		assert.Equal(t, JobWebhookDocument{DocumentID: 5, Status: "failed", Error: "vision model unavailable"}, payload.Documents[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
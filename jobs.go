@@ -2,41 +2,73 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
-	"sort"
-	"sync"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
-// Job represents an OCR job
+// Job represents an OCR job. Jobs are stored in the local database, or in Redis with REDIS_URL.
 type Job struct {
-	ID         string
-	DocumentID int
-	Status     string // "pending", "in_progress", "completed", "failed"
-	Result     string // OCR result or error message
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	PagesDone  int // Number of pages processed
+	ID         string    `gorm:"primaryKey"`
+	DocumentID int       `gorm:"not null;index"`
+	Status     string    `gorm:"not null;index"` // "pending", "in_progress", "completed", "failed"
+	Result     string    `gorm:"size:1048576"`   // Error message, or the OCR result if it could not be stored as OcrJobResult
+	CreatedAt  time.Time `gorm:"not null;index;autoCreateTime:false"`
+	UpdatedAt  time.Time `gorm:"not null;autoUpdateTime:false"`
+	PagesDone  int       // Number of pages processed
+
+	PeakRenderBytes int64 // Most memory held by rendered page images at the same time
+
+	ResultPreview string // Beginning of the OCR result, the full text is stored as OcrJobResult
+	ResultSize    int    // Length of the OCR result in bytes
+
+	Username string `gorm:"index"` // User the job was submitted by in multi-tenant mode
+
+	Options OcrJobOptions `gorm:"serializer:json"` // Per-job options sent with the request
 }
 
-// JobStore manages jobs and their statuses
+// TableName keeps the jobs apart from other job-like records of the local database
+func (Job) TableName() string {
+	return "ocr_jobs"
+}
+
+// JobStore manages jobs and their statuses. Jobs are kept in the local database, so queued jobs survive a
+// restart and interrupted jobs are resumed.
 type JobStore struct {
-	sync.RWMutex
-	jobs map[string]*Job
+	db     *gorm.DB
+	wakeup chan struct{} // Signals a waiting worker that a job was queued
+
+	shared *redisJobQueue // Set with REDIS_URL, jobs are then kept in Redis and shared between replicas
 }
 
 var (
 	logger = logrus.New()
 
-	jobStore = &JobStore{
-		jobs: make(map[string]*Job),
-	}
-	jobQueue = make(chan *Job, 100) // Buffered channel with capacity of 100 jobs
+	// jobStore is created with the local database on startup
+	jobStore *JobStore
+
+	// jobRetention is how long completed and failed jobs are kept, read from JOB_RETENTION. 0 keeps them forever.
+	jobRetention = 24 * time.Hour
 )
 
+// jobResultPreviewLength is the number of characters of an OCR result included in job listings
+const jobResultPreviewLength = 500
+
+// jobCleanupInterval is how often finished jobs are checked against jobRetention
+const jobCleanupInterval = 10 * time.Minute
+
+// jobStatusRetries is how often a failed status change of a job is retried
+const jobStatusRetries = 3
+
+// jobStatusRetryWait is the wait before the first retry of a status change, doubled with every further retry
+const jobStatusRetryWait = 500 * time.Millisecond
+
 func init() {
 
 	// Initialize logger
@@ -48,88 +80,436 @@ func init() {
 	logger.WithField("prefix", "OCR_JOB")
 }
 
+// newJobStore creates a job store keeping its jobs in the given database
+func newJobStore(db *gorm.DB) *JobStore {
+	return &JobStore{db: db, wakeup: make(chan struct{}, 1)}
+}
+
 func generateJobID() string {
 	return uuid.New().String()
 }
 
-func (store *JobStore) addJob(job *Job) {
-	store.Lock()
-	defer store.Unlock()
+// addJob stores a new job and hands it to the workers
+func (store *JobStore) addJob(ctx context.Context, job *Job) error {
 	job.PagesDone = 0 // Initialize PagesDone to 0
-	store.jobs[job.ID] = job
+	if store.shared != nil {
+		if err := store.shared.save(ctx, job); err != nil {
+			return err
+		}
+		if err := store.shared.push(ctx, job.ID); err != nil {
+			return err
+		}
+		logger.Infof("Job added to Redis: %v", job)
+		return nil
+	}
+
+	if err := store.db.WithContext(ctx).Create(job).Error; err != nil {
+		return err
+	}
 	logger.Infof("Job added: %v", job)
+	store.wake()
+	return nil
 }
 
 func (store *JobStore) getJob(jobID string) (*Job, bool) {
-	store.RLock()
-	defer store.RUnlock()
-	job, exists := store.jobs[jobID]
-	return job, exists
+	if store.shared != nil {
+		job, exists, err := store.shared.load(context.Background(), jobID)
+		if err != nil {
+			logger.Errorf("Failed to load job %s from Redis: %v", jobID, err)
+		}
+		return job, exists
+	}
+
+	var job Job
+	if err := store.db.First(&job, "id = ?", jobID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Errorf("Failed to load job %s: %v", jobID, err)
+		}
+		return nil, false
+	}
+	return &job, true
 }
 
+// GetAllJobs returns all jobs, newest first
 func (store *JobStore) GetAllJobs() []*Job {
-	store.RLock()
-	defer store.RUnlock()
+	if store.shared != nil {
+		jobs, err := store.shared.list(context.Background())
+		if err != nil {
+			logger.Errorf("Failed to list jobs from Redis: %v", err)
+		}
+		return jobs
+	}
+
+	jobs := []*Job{}
+	if err := store.db.Order("created_at DESC").Find(&jobs).Error; err != nil {
+		logger.Errorf("Failed to list jobs: %v", err)
+	}
+	return jobs
+}
+
+// listJobs returns a page of the jobs of a user, newest first, and the total number of their jobs
+func (store *JobStore) listJobs(username string, page int, pageSize int) ([]*Job, int64, error) {
+	offset := (page - 1) * pageSize
+	if store.shared != nil {
+		jobs, err := store.shared.list(context.Background())
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = slices.DeleteFunc(jobs, func(job *Job) bool { return job.Username != username })
+		total := int64(len(jobs))
+		jobs = jobs[min(offset, len(jobs)):min(offset+pageSize, len(jobs))]
+		return jobs, total, nil
+	}
 
-	jobs := make([]*Job, 0, len(store.jobs))
-	for _, job := range store.jobs {
-		jobs = append(jobs, job)
+	var total int64
+	query := store.db.Model(&Job{}).Where("username = ?", username)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	jobs := []*Job{}
+	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// update applies fn to a job, if it exists
+func (store *JobStore) update(jobID string, fn func(job *Job)) error {
+	if store.shared != nil {
+		return store.shared.update(context.Background(), jobID, fn)
 	}
 
-	sort.Slice(jobs, func(i, j int) bool {
-		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	return store.db.Transaction(func(tx *gorm.DB) error {
+		var job Job
+		if err := tx.First(&job, "id = ?", jobID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		fn(&job)
+		return tx.Save(&job).Error
 	})
+}
 
-	return jobs
+// updateStatus applies a status change to a job. Failed writes are retried, since a lost status change would
+// leave the job in progress until the next restart runs it again.
+func (store *JobStore) updateStatus(jobID string, fn func(job *Job)) error {
+	wait := jobStatusRetryWait
+	err := store.update(jobID, fn)
+	for attempt := 0; err != nil && attempt < jobStatusRetries; attempt++ {
+		logger.Warnf("Failed to update the status of job %s, retrying in %s: %v", jobID, wait, err)
+		time.Sleep(wait)
+		wait *= 2
+		err = store.update(jobID, fn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update the status of job %s: %w", jobID, err)
+	}
+	return nil
 }
 
-func (store *JobStore) updateJobStatus(jobID, status, result string) {
-	store.Lock()
-	defer store.Unlock()
-	if job, exists := store.jobs[jobID]; exists {
+func (store *JobStore) updateJobStatus(jobID, status, result string) error {
+	return store.updateStatus(jobID, func(job *Job) {
 		job.Status = status
 		if result != "" {
 			job.Result = result
 		}
 		job.UpdatedAt = time.Now()
 		logger.Infof("Job status updated: %v", job)
-	}
+	})
 }
 
 func (store *JobStore) updatePagesDone(jobID string, pagesDone int) {
-	store.Lock()
-	defer store.Unlock()
-	if job, exists := store.jobs[jobID]; exists {
+	err := store.update(jobID, func(job *Job) {
 		job.PagesDone = pagesDone
 		job.UpdatedAt = time.Now()
 		logger.Infof("Job pages done updated: %v", job)
+	})
+	if err != nil {
+		logger.Errorf("Failed to update the progress of job %s: %v", jobID, err)
 	}
 }
 
-func startWorkerPool(app *App, numWorkers int) {
-	for i := 0; i < numWorkers; i++ {
-		go func(workerID int) {
-			logger.Infof("Worker %d started", workerID)
-			for job := range jobQueue {
-				logger.Infof("Worker %d processing job: %s", workerID, job.ID)
-				processJob(app, job)
+// updatePeakRenderBytes stores the page image memory a job held at most
+func (store *JobStore) updatePeakRenderBytes(jobID string, peak int64) {
+	err := store.update(jobID, func(job *Job) {
+		job.PeakRenderBytes = peak
+	})
+	if err != nil {
+		logger.Errorf("Failed to update the render memory of job %s: %v", jobID, err)
+	}
+}
+
+// completeJob marks a job as completed with a preview of its result. The full text is only kept in the job
+// if it could not be stored as OcrJobResult.
+func (store *JobStore) completeJob(jobID string, result string, stored bool) error {
+	return store.updateStatus(jobID, func(job *Job) {
+		job.Status = "completed"
+		job.ResultPreview = truncateRunes(result, jobResultPreviewLength)
+		job.ResultSize = len(result)
+		if !stored {
+			job.Result = result
+		}
+		job.UpdatedAt = time.Now()
+		logger.Infof("Job completed: %s (%d bytes)", jobID, job.ResultSize)
+	})
+}
+
+// removeFinishedJobs removes completed and failed jobs last updated before the cutoff and returns their number
+func (store *JobStore) removeFinishedJobs(cutoff time.Time) int {
+	if store.shared != nil {
+		removed := 0
+		for _, job := range store.GetAllJobs() {
+			if (job.Status == "completed" || job.Status == "failed") && job.UpdatedAt.Before(cutoff) {
+				if err := store.shared.remove(context.Background(), job.ID); err != nil {
+					logger.Errorf("Failed to remove job %s from Redis: %v", job.ID, err)
+					continue
+				}
+				removed++
+			}
+		}
+		return removed
+	}
+
+	result := store.db.Where("status IN ? AND updated_at < ?", []string{"completed", "failed"}, cutoff).Delete(&Job{})
+	if result.Error != nil {
+		logger.Errorf("Failed to remove finished jobs: %v", result.Error)
+	}
+	return int(result.RowsAffected)
+}
+
+// statusCounts returns the number of jobs per status
+func (store *JobStore) statusCounts() map[string]int {
+	counts := map[string]int{}
+	if store.shared != nil {
+		for _, job := range store.GetAllJobs() {
+			counts[job.Status]++
+		}
+		return counts
+	}
+
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := store.db.Model(&Job{}).Select("status, count(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		logger.Errorf("Failed to count jobs: %v", err)
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts
+}
+
+// queuedJobs returns the number of jobs waiting for a worker
+func (store *JobStore) queuedJobs() int {
+	if store.shared != nil {
+		count, err := store.shared.queued(context.Background())
+		if err != nil {
+			logger.Errorf("Failed to count queued jobs in Redis: %v", err)
+		}
+		return count
+	}
+	return store.statusCounts()["pending"]
+}
+
+// next takes the oldest pending job for a worker. It returns nil if no job is waiting.
+func (store *JobStore) next() (*Job, error) {
+	for {
+		var job Job
+		err := store.db.Where("status = ?", "pending").Order("created_at").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Another worker may have taken the job in the meantime
+		now := time.Now()
+		claimed := store.db.Model(&Job{}).Where("id = ? AND status = ?", job.ID, "pending").
+			Updates(map[string]interface{}{"status": "in_progress", "updated_at": now})
+		if claimed.Error != nil {
+			return nil, claimed.Error
+		}
+		if claimed.RowsAffected == 1 {
+			job.Status, job.UpdatedAt = "in_progress", now
+			return &job, nil
+		}
+	}
+}
+
+// wake signals a waiting worker that a job was queued
+func (store *JobStore) wake() {
+	select {
+	case store.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// waitForJob waits until a job is queued or the timeout passed
+func (store *JobStore) waitForJob(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-store.wakeup:
+	case <-timer.C:
+	}
+}
+
+// requeueInterrupted puts jobs that were in progress when paperless-gpt stopped back into the queue and
+// returns their number. They keep their pages done and resume after the last completed page.
+func (store *JobStore) requeueInterrupted() (int, error) {
+	result := store.db.Model(&Job{}).Where("status = ?", "in_progress").
+		Updates(map[string]interface{}{"status": "pending", "updated_at": time.Now()})
+	return int(result.RowsAffected), result.Error
+}
+
+// ocrJobKey is the context key for the job an OCR run belongs to
+type ocrJobKey struct{}
+
+// ocrJobRun is the job an OCR run belongs to. Page results are stored with the job ID, and a resumed run only
+// reuses the pages its own job completed before it was interrupted.
+type ocrJobRun struct {
+	ID     string
+	Resume bool
+}
+
+// withOcrJob marks OCR with the returned context as part of a job
+func withOcrJob(ctx context.Context, jobID string, resume bool) context.Context {
+	return context.WithValue(ctx, ocrJobKey{}, ocrJobRun{ID: jobID, Resume: resume})
+}
+
+// ocrJobOf returns the job set by withOcrJob. OCR outside a job, e.g. by the background task, has an empty ID.
+func ocrJobOf(ctx context.Context) ocrJobRun {
+	run, _ := ctx.Value(ocrJobKey{}).(ocrJobRun)
+	return run
+}
+
+// startJobCleanup periodically removes finished jobs and their stored results once they are older than the retention
+func startJobCleanup(db *gorm.DB, retention time.Duration) {
+	if retention == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(min(retention, jobCleanupInterval))
+		defer ticker.Stop()
+		for range ticker.C {
+			cutoff := time.Now().Add(-retention)
+			if removed := jobStore.removeFinishedJobs(cutoff); removed > 0 {
+				logger.Infof("Removed %d finished jobs older than %s", removed, retention)
+			}
+			if err := DeleteOcrJobResultsBefore(db, cutoff); err != nil {
+				logger.Errorf("Failed to remove old OCR job results: %v", err)
 			}
-		}(i)
+		}
+	}()
+}
+
+func startWorkerPool(app *App, numWorkers int) {
+	watchdog := newJobWatchdog(jobStore, jobStallTimeout)
+	if jobStore.shared != nil {
+		jobStore.shared.startWorkers(app, watchdog, numWorkers)
+		return
+	}
+
+	if requeued, err := jobStore.requeueInterrupted(); err != nil {
+		logger.Errorf("Failed to requeue interrupted jobs: %v", err)
+	} else if requeued > 0 {
+		logger.Warnf("Requeued %d jobs interrupted by a restart", requeued)
 	}
+	watchdog.start(numWorkers, func(workerID int) {
+		logger.Infof("Worker %d started", workerID)
+		for {
+			job, err := jobStore.next()
+			if err != nil {
+				logger.Errorf("Worker %d failed to fetch a job: %v", workerID, err)
+				time.Sleep(jobPollTimeout)
+				continue
+			}
+			if job == nil {
+				jobStore.waitForJob(jobPollTimeout)
+				continue
+			}
+
+			logger.Infof("Worker %d processing job: %s", workerID, job.ID)
+			if watchdog.processJob(app, workerID, job) {
+				logger.Warnf("Worker %d stopped, it was replaced while job %s stalled", workerID, job.ID)
+				return
+			}
+		}
+	})
 }
 
-func processJob(app *App, job *Job) {
-	jobStore.updateJobStatus(job.ID, "in_progress", "")
+// processJob processes an OCR job. The context is cancelled by the watchdog if the job stalls.
+func processJob(ctx context.Context, app *App, job *Job) {
+	if err := jobStore.updateJobStatus(job.ID, "in_progress", ""); err != nil {
+		logger.Errorf("%v", err)
+	}
 
-	ctx := context.Background()
+	// Other replicas cannot read the page results of the local database, so only local jobs are resumed
+	resume := job.PagesDone > 0 && jobStore.shared == nil
+	if resume {
+		logger.Infof("Resuming job %s after %d pages", job.ID, job.PagesDone)
+	}
+	ctx = withOcrJob(ctx, job.ID, resume)
+	ctx = withJobProgress(ctx, func(pagesDone int) {
+		jobStore.updatePagesDone(job.ID, pagesDone)
+	})
+	ctx, err := app.tenantContext(ctx, job.Username)
+	if err != nil {
+		logger.Errorf("Error loading paperless token for job %s: %v", job.ID, err)
+		if err := jobStore.updateJobStatus(job.ID, "failed", err.Error()); err != nil {
+			logger.Errorf("%v", err)
+		}
+		notifyJobFinished(context.Background(), ocrJobWebhook(job, documentOutcome(job.DocumentID, err, nil)))
+		return
+	}
 
-	fullOcrText, err := app.ProcessDocumentOCR(ctx, job.DocumentID)
+	stats := &renderStats{}
+	fullOcrText, err := app.ProcessDocumentOCR(withRenderStats(ctx, stats), job.DocumentID, job.Options)
+	jobStore.updatePeakRenderBytes(job.ID, stats.peakBytes())
+	if errors.Is(context.Cause(ctx), errJobStalled) {
+		err = errJobStalled
+	}
 	if err != nil {
 		logger.Errorf("Error processing document OCR for job %s: %v", job.ID, err)
-		jobStore.updateJobStatus(job.ID, "failed", err.Error())
+		if err := jobStore.updateJobStatus(job.ID, "failed", err.Error()); err != nil {
+			logger.Errorf("%v", err)
+		}
+		notifyJobFinished(ctx, ocrJobWebhook(job, documentOutcome(job.DocumentID, err, nil)))
 		return
 	}
 
-	jobStore.updateJobStatus(job.ID, "completed", fullOcrText)
-	logger.Infof("Job completed: %s", job.ID)
+	// Other replicas cannot read the local database, so with Redis the result is kept in the job
+	stored := false
+	if jobStore.shared == nil {
+		stored = true
+		if err := SaveOcrJobResult(app.Database, job.ID, job.DocumentID, job.Username, fullOcrText); err != nil {
+			logger.Errorf("Error storing OCR result for job %s, keeping it in the job: %v", job.ID, err)
+			stored = false
+		}
+	}
+	if err := jobStore.completeJob(job.ID, fullOcrText, stored); err != nil {
+		logger.Errorf("%v", err)
+	}
+
+	pages := 0
+	if finished, exists := jobStore.getJob(job.ID); exists {
+		pages = finished.PagesDone
+	}
+	notifyJobFinished(ctx, ocrJobWebhook(job, documentOutcome(job.DocumentID, nil, ocrChanges(pages, fullOcrText))))
+}
+
+// ocrJobWebhook is the webhook of an OCR job submitted through the API. The text is only returned by the
+// job, it is not written to paperless-ngx.
+func ocrJobWebhook(job *Job, outcome JobWebhookDocument) JobWebhook {
+	return JobWebhook{Event: jobEventOcrFinished, Source: "api", JobID: job.ID, Documents: []JobWebhookDocument{outcome}}
+}
+
+// truncateRunes shortens text to at most n characters
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n])
 }
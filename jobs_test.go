@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJobStore returns a job store on the shared test database holding only the given jobs
+func newTestJobStore(t *testing.T, jobs ...*Job) *JobStore {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	require.NoError(t, db.Where("1 = 1").Delete(&Job{}).Error)
+	for _, job := range jobs {
+		require.NoError(t, db.Create(job).Error)
+	}
+	return newJobStore(db)
+}
+
+func TestRemoveFinishedJobs(t *testing.T) {
+	now := time.Now()
+	store := newTestJobStore(t,
+		&Job{ID: "old-completed", Status: "completed", UpdatedAt: now.Add(-48 * time.Hour)},
+		&Job{ID: "old-failed", Status: "failed", UpdatedAt: now.Add(-48 * time.Hour)},
+		&Job{ID: "old-running", Status: "in_progress", UpdatedAt: now.Add(-48 * time.Hour)},
+		&Job{ID: "new-completed", Status: "completed", UpdatedAt: now},
+	)
+
+	removed := store.removeFinishedJobs(now.Add(-24 * time.Hour))
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, map[string]int{"in_progress": 1, "completed": 1}, store.statusCounts())
+}
+
+func TestCompleteJob(t *testing.T) {
+	store := newTestJobStore(t,
+		&Job{ID: "stored", Status: "in_progress"},
+		&Job{ID: "unstored", Status: "in_progress"},
+	)
+	text := strings.Repeat("ä", jobResultPreviewLength+10)
+
+	store.completeJob("stored", text, true)
+	job, _ := store.getJob("stored")
+	assert.Equal(t, "completed", job.Status)
+	assert.Equal(t, strings.Repeat("ä", jobResultPreviewLength), job.ResultPreview)
+	assert.Equal(t, len(text), job.ResultSize)
+	assert.Empty(t, job.Result)
+
+	// Results that could not be stored stay available from memory
+	store.completeJob("unstored", text, false)
+	job, _ = store.getJob("unstored")
+	assert.Equal(t, text, job.Result)
+}
+
+func TestJobStoreQueue(t *testing.T) {
+	now := time.Now()
+	store := newTestJobStore(t,
+		&Job{ID: "interrupted", DocumentID: 1, Status: "in_progress", PagesDone: 2, CreatedAt: now.Add(-time.Hour), UpdatedAt: now},
+		&Job{ID: "done", DocumentID: 2, Status: "completed", CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now},
+	)
+	require.NoError(t, store.addJob(context.Background(), &Job{
+		ID: "queued", DocumentID: 3, Status: "pending", CreatedAt: now, UpdatedAt: now,
+		Options: OcrJobOptions{Pages: "1-3"},
+	}))
+
+	// Jobs interrupted by a restart are queued again and keep their progress
+	requeued, err := store.requeueInterrupted()
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	assert.Equal(t, 2, store.queuedJobs())
+
+	job, err := store.next()
+	require.NoError(t, err)
+	assert.Equal(t, "interrupted", job.ID)
+	assert.Equal(t, "in_progress", job.Status)
+	assert.Equal(t, 2, job.PagesDone)
+
+	job, err = store.next()
+	require.NoError(t, err)
+	assert.Equal(t, "queued", job.ID)
+	assert.Equal(t, OcrJobOptions{Pages: "1-3"}, job.Options)
+
+	job, err = store.next()
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	assert.Equal(t, map[string]int{"in_progress": 2, "completed": 1}, store.statusCounts())
+}
+
+func TestJobStoreListJobs(t *testing.T) {
+	now := time.Now()
+	var jobs []*Job
+	for i := 0; i < 5; i++ {
+		jobs = append(jobs, &Job{ID: fmt.Sprintf("job-%d", i), Status: "completed", CreatedAt: now.Add(time.Duration(i) * time.Minute), UpdatedAt: now})
+	}
+	jobs = append(jobs, &Job{ID: "other-user", Status: "completed", Username: "bob", CreatedAt: now, UpdatedAt: now})
+	store := newTestJobStore(t, jobs...)
+
+	page, total, err := store.listJobs("", 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 2)
+	assert.Equal(t, "job-2", page[0].ID)
+	assert.Equal(t, "job-1", page[1].ID)
+
+	page, total, err = store.listJobs("bob", 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "other-user", page[0].ID)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// leaderLockTTL is how long leadership lasts without renewal. Another replica takes over within this time
+// after the leader stops.
+const leaderLockTTL = 30 * time.Second
+
+// renewLeaderScript extends the lock only if this replica still holds it
+var renewLeaderScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`)
+
+// leader is the lock deciding which replica runs the background tasks, nil if every replica runs them
+var leader *leaderLock
+
+// leaderLock is a lock in Redis naming the replica that runs the polling loops and scheduled tasks.
+// The HTTP API stays active on all replicas.
+type leaderLock struct {
+	client *redis.Client
+	key    string
+	id     string
+
+	mu     sync.Mutex
+	leader bool
+}
+
+// newLeaderLock creates a lock stored at the given key
+func newLeaderLock(client *redis.Client, key string) *leaderLock {
+	hostname, _ := os.Hostname()
+	return &leaderLock{
+		client: client,
+		key:    key,
+		id:     fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// isLeader reports whether this replica should run the background tasks
+func isLeader() bool {
+	if leader == nil {
+		return true
+	}
+	leader.mu.Lock()
+	defer leader.mu.Unlock()
+	return leader.leader
+}
+
+// refresh renews the lock if this replica holds it, or tries to take it over otherwise
+func (l *leaderLock) refresh(ctx context.Context) error {
+	l.mu.Lock()
+	wasLeader := l.leader
+	l.mu.Unlock()
+
+	var leading bool
+	var err error
+	if wasLeader {
+		var renewed int64
+		renewed, err = renewLeaderScript.Run(ctx, l.client, []string{l.key}, l.id, leaderLockTTL.Milliseconds()).Int64()
+		leading = renewed == 1
+	} else {
+		leading, err = l.client.SetNX(ctx, l.key, l.id, leaderLockTTL).Result()
+	}
+	if err != nil {
+		// Without Redis this replica cannot know whether another one took over, so it steps down
+		leading = false
+	}
+
+	l.mu.Lock()
+	l.leader = leading
+	l.mu.Unlock()
+
+	if leading && !wasLeader {
+		log.Info("This replica is now the leader and runs the background tasks")
+	} else if !leading && wasLeader {
+		log.Warn("This replica lost the leadership and pauses the background tasks")
+	}
+	return err
+}
+
+// start acquires or renews the lock every third of its TTL
+func (l *leaderLock) start() {
+	go func() {
+		for {
+			if err := l.refresh(context.Background()); err != nil {
+				log.Errorf("Failed to refresh the leader lock: %v", err)
+			}
+			time.Sleep(leaderLockTTL / 3)
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderLock(t *testing.T) {
+	server, client := newTestRedis(t)
+	ctx := context.Background()
+
+	first := newLeaderLock(client, "test:leader")
+	second := newLeaderLock(client, "test:leader")
+
+	require.NoError(t, first.refresh(ctx))
+	require.NoError(t, second.refresh(ctx))
+	assert.True(t, first.leader)
+	assert.False(t, second.leader, "only one replica leads")
+
+	// The leader keeps the lock while renewing it
+	require.NoError(t, first.refresh(ctx))
+	assert.True(t, first.leader)
+
+	// Once the lock expired, the other replica takes over and the former leader steps down
+	server.FastForward(leaderLockTTL)
+	require.NoError(t, second.refresh(ctx))
+	require.NoError(t, first.refresh(ctx))
+	assert.True(t, second.leader)
+	assert.False(t, first.leader)
+
+	original := leader
+	defer func() { leader = original }()
+	leader = nil
+	assert.True(t, isLeader(), "without leader election every replica runs the background tasks")
+	leader = first
+	assert.False(t, isLeader())
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -19,6 +23,161 @@ type ModificationHistory struct {
 	NewValue      string `gorm:"size:1048576"`           // New value of the field
 	Undone        bool   `gorm:"not null;default:false"` // Whether the modification has been undone
 	UndoneDate    string `gorm:"default:null"`           // Date and time of undoing the modification
+	Username      string `gorm:"size:255;index"`         // User the modification was made for in multi-tenant mode
+}
+
+// PendingCorrespondent represents a new correspondent suggested by the LLM that awaits approval before it is created
+type PendingCorrespondent struct {
+	ID          uint   `gorm:"primaryKey"`                                                              // Auto-incrementing primary key
+	Name        string `gorm:"size:255;not null;uniqueIndex:idx_pending_correspondent_user"`            // Suggested correspondent name
+	Username    string `gorm:"size:255;not null;default:'';uniqueIndex:idx_pending_correspondent_user"` // User whose documents wait for the correspondent in multi-tenant mode
+	DocumentIDs string `gorm:"size:1048576"`                                                            // JSON encoded list of documents waiting for this correspondent
+	DateAdded   string `gorm:"not null"`                                                                // Date and time the correspondent was first suggested
+}
+
+// DocumentExtraction stores the structured rows extracted from a document with repeated entries
+type DocumentExtraction struct {
+	ID         uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	DocumentID uint   `gorm:"not null;index"` // Document the rows were extracted from
+	Rows       string `gorm:"size:1048576"`   // JSON encoded list of extracted rows
+	DateAdded  string `gorm:"not null"`       // Date and time of the extraction
+}
+
+// OcrPageResult stores the OCR text of a single page so the result can be reviewed page by page
+type OcrPageResult struct {
+	ID          uint     `gorm:"primaryKey"`             // Auto-incrementing primary key
+	DocumentID  uint     `gorm:"not null;index"`         // Document the page belongs to
+	JobID       string   `gorm:"size:64;index"`          // OCR job that processed the page, empty outside of jobs
+	PageIndex   int      `gorm:"not null"`               // Zero-based index of the page
+	Text        string   `gorm:"size:1048576"`           // OCR text of the page
+	Blank       bool     `gorm:"not null"`               // Page was detected as blank and skipped
+	Languages   string   `gorm:"size:64"`                // Comma-separated ISO 639-1 codes (OCR_LANGUAGE_DETECTION)
+	NeedsReview bool     `gorm:"not null;default:false"` // Transcriptions of the consensus OCR mode diverged or the handwriting confidence is low
+	Confidence  *float64 // Recognition confidence (0 to 1) reported for handwritten pages, nil if unknown
+	DateAdded   string   `gorm:"not null"` // Date and time the page was processed
+}
+
+// OcrJobResult stores the text of a finished OCR job, which is too large to keep in every job listing
+type OcrJobResult struct {
+	JobID      string `gorm:"primaryKey"`     // ID of the OCR job
+	DocumentID uint   `gorm:"not null;index"` // Document the job processed
+	Username   string `gorm:"index"`          // User who started the job, empty without MULTI_TENANT
+	Text       string `gorm:"size:10485760"`  // Combined OCR text of all pages
+	DateAdded  string `gorm:"not null;index"` // Date and time the job finished
+}
+
+// OcrCacheEntry stores the response of the OCR provider for a page image (OCR_CACHE_TTL)
+type OcrCacheEntry struct {
+	Hash      string `gorm:"primaryKey;size:64"` // SHA-256 of the page image, provider, model and prompt
+	Text      string `gorm:"size:1048576"`       // OCR text of the page
+	DateAdded string `gorm:"not null;index"`     // Date and time the response was stored
+	DateUsed  string `gorm:"not null;index"`     // Date and time the response was last used
+}
+
+// LLMTrace stores the prompt and raw answer of an LLM request made for a document (LLM_TRACES)
+type LLMTrace struct {
+	ID          uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	DocumentID  uint   `gorm:"not null;index"` // Document the request was made for
+	Model       string `gorm:"not null"`       // LLM model that answered
+	Prompt      string `gorm:"size:1048576"`   // Rendered prompt, including re-asks
+	Response    string `gorm:"size:1048576"`   // Raw answer or error of the LLM
+	DateCreated string `gorm:"not null;index"` // Date and time of the request
+}
+
+// BacklogSample stores how many documents waited in the background queues at a point in time
+type BacklogSample struct {
+	ID             uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	OcrBacklog     *int   `gorm:"default:null"`   // Documents tagged AUTO_OCR_TAG, nil if OCR is disabled
+	TaggingBacklog *int   `gorm:"default:null"`   // Documents tagged AUTO_TAG, nil if no LLM is configured
+	Username       string `gorm:"size:255;index"` // User the queues belong to in multi-tenant mode
+	DateSampled    string `gorm:"not null;index"` // Date and time of the sample
+}
+
+// ProcessingFailure tracks a document whose background processing failed until it succeeds
+type ProcessingFailure struct {
+	ID              uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	DocumentID      uint   `gorm:"not null;index"` // Document that failed
+	Queue           string `gorm:"size:16"`        // ocr or tagging
+	Error           string `gorm:"size:65536"`     // Error of the last attempt
+	Attempts        int    // Number of failed attempts
+	Username        string `gorm:"size:255;index"` // User the document was processed for in multi-tenant mode
+	DateFirstFailed string `gorm:"not null"`       // Date and time of the first failed attempt
+	DateLastFailed  string `gorm:"not null"`       // Date and time of the last failed attempt
+}
+
+// SuggestionRecord stores a suggestion shown for review and what the user did with it
+type SuggestionRecord struct {
+	ID          uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	DocumentID  uint   `gorm:"not null;index"` // Document the suggestion was made for
+	Field       string `gorm:"size:32"`        // title, tags or correspondent
+	Model       string `gorm:"size:255"`       // LLM model that made the suggestion
+	Suggested   string `gorm:"size:65536"`     // Suggested value, tags sorted and comma-separated
+	Original    string `gorm:"size:65536"`     // Value of the document when the suggestion was made
+	Outcome     string `gorm:"size:16;index"`  // accepted, edited or rejected, empty while pending
+	Username    string `gorm:"size:255;index"` // User the suggestion was made for in multi-tenant mode
+	DateCreated string `gorm:"not null;index"` // Date and time of the suggestion
+	DateDecided string // Date and time the suggestion was applied, edited or rejected
+}
+
+// ShadowResult compares a production suggestion with the one of the shadow prompt or model
+type ShadowResult struct {
+	ID          uint   `gorm:"primaryKey"`     // Auto-incrementing primary key
+	DocumentID  uint   `gorm:"not null;index"` // Document both suggestions were made for
+	Field       string `gorm:"size:32"`        // title, tags or correspondent
+	Model       string `gorm:"size:255"`       // LLM model of the shadow run
+	Production  string `gorm:"size:65536"`     // Suggestion of the live configuration, tags sorted and comma-separated
+	Candidate   string `gorm:"size:65536"`     // Suggestion of the shadow configuration
+	Matches     bool   // Whether both suggestions are equal
+	Username    string `gorm:"size:255;index"` // User the suggestions were made for in multi-tenant mode
+	DateCreated string `gorm:"not null;index"` // Date and time of the comparison
+}
+
+// Report stores a generated archive report
+type Report struct {
+	ID          uint   `gorm:"primaryKey"`                         // Auto-incrementing primary key
+	PeriodStart string `gorm:"not null"`                           // Start of the covered period
+	PeriodEnd   string `gorm:"not null"`                           // End of the covered period
+	Statistics  string `gorm:"size:1048576"`                       // JSON encoded ReportStatistics
+	Summary     string `gorm:"size:1048576"`                       // LLM-written narrative summary
+	Username    string `gorm:"size:255;not null;default:'';index"` // User the report was generated for in multi-tenant mode
+	DateCreated string `gorm:"not null"`                           // Date and time the report was generated
+}
+
+// ReportShare is a time-limited link to a read-only page of a report. Only the hash of its token is stored.
+type ReportShare struct {
+	ID          uint   `gorm:"primaryKey"`                   // Auto-incrementing primary key
+	ReportID    uint   `gorm:"not null;index"`               // Report that is shared
+	TokenHash   string `gorm:"size:64;not null;uniqueIndex"` // Hex SHA-256 of the share token
+	ExpiresAt   string `gorm:"not null"`                     // Date and time the link stops working
+	DateCreated string `gorm:"not null"`                     // Date and time the link was created
+}
+
+// Category is a user-defined document category assigned by the LLM during classification
+type Category struct {
+	ID          uint   `gorm:"primaryKey"`                    // Auto-incrementing primary key
+	Name        string `gorm:"size:255;not null;uniqueIndex"` // Name the LLM answers with, e.g. "tax-relevant"
+	Description string `gorm:"size:2048"`                     // Tells the LLM which documents belong to the category
+	Tag         string `gorm:"size:255"`                      // Tag added to documents of this category
+	CustomField string `gorm:"size:255"`                      // Boolean custom field set to true for documents of this category
+	DateAdded   string `gorm:"not null"`                      // Date and time the category was created
+}
+
+// TenantUser is a paperless-ngx user paperless-gpt acts on behalf of in multi-tenant mode
+type TenantUser struct {
+	ID             uint   `gorm:"primaryKey"`                    // Auto-incrementing primary key
+	Username       string `gorm:"size:255;not null;uniqueIndex"` // Username passed by the authenticating proxy
+	EncryptedToken string `gorm:"size:4096;not null"`            // Paperless API token, encrypted with SECRETS_KEY
+	DateAdded      string `gorm:"not null"`                      // Date and time the token was registered
+}
+
+// BackfillCheckpoint stores the progress of a backfill so it can be resumed
+type BackfillCheckpoint struct {
+	ID             uint   `gorm:"primaryKey"`                               // Auto-incrementing primary key
+	Username       string `gorm:"size:255;not null;default:'';uniqueIndex"` // User the backfill runs for, one checkpoint per user
+	Page           int    `gorm:"not null"`                                 // Page of the document listing the backfill is on
+	PageSize       int    `gorm:"not null;default:0"`                       // Documents per page the page number refers to, 0 if unknown
+	LastDocumentID int    `gorm:"not null"`                                 // Last document that was handled
+	DateUpdated    string `gorm:"not null"`                                 // Date and time of the last update
 }
 
 // InitializeDB initializes the SQLite database and migrates the schema
@@ -37,8 +196,15 @@ func InitializeDB() *gorm.DB {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Pending correspondents were unique by name before they were queued per user
+	if db.Migrator().HasIndex(&PendingCorrespondent{}, "idx_pending_correspondents_name") {
+		if err := db.Migrator().DropIndex(&PendingCorrespondent{}, "idx_pending_correspondents_name"); err != nil {
+			log.Fatalf("Failed to migrate pending correspondents: %v", err)
+		}
+	}
+
 	// Migrate the schema (create the table if it doesn't exist)
-	err = db.AutoMigrate(&ModificationHistory{})
+	err = db.AutoMigrate(&ModificationHistory{}, &PendingCorrespondent{}, &DocumentExtraction{}, &Report{}, &BackfillCheckpoint{}, &OcrPageResult{}, &LLMTrace{}, &OcrJobResult{}, &Category{}, &TenantUser{}, &BacklogSample{}, &OcrCacheEntry{}, &SuggestionRecord{}, &ShadowResult{}, &ProcessingFailure{}, &ReportShare{}, &Job{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database schema: %v", err)
 	}
@@ -70,11 +236,16 @@ func GetAllModifications(db *gorm.DB) ([]ModificationHistory, error) {
 	return records, result.Error
 }
 
-// GetPaginatedModifications retrieves a page of modification records with total count
-func GetPaginatedModifications(db *gorm.DB, page int, pageSize int) ([]ModificationHistory, int64, error) {
+// GetPaginatedModifications retrieves a page of modification records with total count.
+// A non-empty username limits the records to those made for that user.
+func GetPaginatedModifications(db *gorm.DB, page int, pageSize int, username string) ([]ModificationHistory, int64, error) {
 	var records []ModificationHistory
 	var total int64
 
+	if username != "" {
+		db = db.Where("username = ?", username).Session(&gorm.Session{})
+	}
+
 	// Get total count
 	if err := db.Model(&ModificationHistory{}).Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -99,3 +270,578 @@ func SetModificationUndone(db *gorm.DB, record *ModificationHistory) error {
 	result := db.Save(&record) // GORM's Save method
 	return result.Error
 }
+
+// QueuePendingCorrespondent adds a document of a user to the approval queue of a suggested correspondent,
+// creating the entry if needed
+func QueuePendingCorrespondent(db *gorm.DB, name string, username string, documentID int) error {
+	var record PendingCorrespondent
+	result := db.Where("name = ? AND username = ?", name, username).Limit(1).Find(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		record = PendingCorrespondent{
+			Name:      name,
+			Username:  username,
+			DateAdded: time.Now().Format(time.RFC3339),
+		}
+	}
+
+	documentIDs := record.GetDocumentIDs()
+	for _, id := range documentIDs {
+		if id == documentID {
+			return nil // Document is already queued
+		}
+	}
+	documentIDs = append(documentIDs, documentID)
+
+	encoded, err := json.Marshal(documentIDs)
+	if err != nil {
+		return err
+	}
+	record.DocumentIDs = string(encoded)
+	return db.Save(&record).Error
+}
+
+// GetDocumentIDs decodes the list of documents waiting for the pending correspondent
+func (record *PendingCorrespondent) GetDocumentIDs() []int {
+	var documentIDs []int
+	if record.DocumentIDs == "" {
+		return documentIDs
+	}
+	if err := json.Unmarshal([]byte(record.DocumentIDs), &documentIDs); err != nil {
+		log.Errorf("Invalid document IDs for pending correspondent %d: %v", record.ID, err)
+	}
+	return documentIDs
+}
+
+// GetPendingCorrespondents retrieves all correspondents awaiting approval by a user
+func GetPendingCorrespondents(db *gorm.DB, username string) ([]PendingCorrespondent, error) {
+	var records []PendingCorrespondent
+	result := db.Where("username = ?", username).Order("date_added ASC").Find(&records)
+	return records, result.Error
+}
+
+// GetPendingCorrespondent retrieves a pending correspondent of a user by its ID
+func GetPendingCorrespondent(db *gorm.DB, id uint, username string) (*PendingCorrespondent, error) {
+	var record PendingCorrespondent
+	result := db.Where("username = ?", username).First(&record, id)
+	return &record, result.Error
+}
+
+// DeletePendingCorrespondent removes a pending correspondent from the approval queue
+func DeletePendingCorrespondent(db *gorm.DB, record *PendingCorrespondent) error {
+	return db.Delete(record).Error
+}
+
+// InsertExtraction stores the extracted rows of a document
+func InsertExtraction(db *gorm.DB, documentID int, rows []map[string]interface{}) (*DocumentExtraction, error) {
+	encoded, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	record := &DocumentExtraction{
+		DocumentID: uint(documentID),
+		Rows:       string(encoded),
+		DateAdded:  time.Now().Format(time.RFC3339),
+	}
+	return record, db.Create(record).Error
+}
+
+// GetExtractions retrieves all extractions of a document, newest first
+func GetExtractions(db *gorm.DB, documentID int) ([]DocumentExtraction, error) {
+	var records []DocumentExtraction
+	result := db.Where("document_id = ?", documentID).Order("id DESC").Find(&records)
+	return records, result.Error
+}
+
+// SaveOcrPageResult stores the OCR text of a page processed by a job, replacing an earlier result for the same page
+func SaveOcrPageResult(db *gorm.DB, jobID string, documentID int, pageIndex int, text string, blank bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ? AND page_index = ?", documentID, pageIndex).Delete(&OcrPageResult{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OcrPageResult{
+			DocumentID: uint(documentID),
+			JobID:      jobID,
+			PageIndex:  pageIndex,
+			Text:       text,
+			Blank:      blank,
+			DateAdded:  time.Now().Format(time.RFC3339),
+		}).Error
+	})
+}
+
+// SetOcrPageNeedsReview flags a page whose transcriptions diverged for human review
+func SetOcrPageNeedsReview(db *gorm.DB, documentID int, pageIndex int) error {
+	return db.Model(&OcrPageResult{}).
+		Where("document_id = ? AND page_index = ?", documentID, pageIndex).
+		Update("needs_review", true).Error
+}
+
+// SetOcrPageConfidence stores the recognition confidence of a handwritten page
+func SetOcrPageConfidence(db *gorm.DB, documentID int, pageIndex int, confidence float64) error {
+	return db.Model(&OcrPageResult{}).
+		Where("document_id = ? AND page_index = ?", documentID, pageIndex).
+		Update("confidence", confidence).Error
+}
+
+// SetOcrPageLanguages stores the detected languages of a page
+func SetOcrPageLanguages(db *gorm.DB, documentID int, pageIndex int, languages []string) error {
+	return db.Model(&OcrPageResult{}).
+		Where("document_id = ? AND page_index = ?", documentID, pageIndex).
+		Update("languages", strings.Join(languages, ",")).Error
+}
+
+// DeleteOcrPageResults removes the stored page results of a document
+func DeleteOcrPageResults(db *gorm.DB, documentID int) error {
+	return db.Where("document_id = ?", documentID).Delete(&OcrPageResult{}).Error
+}
+
+// KeepJobOcrPageResults removes the page results of a document that other runs stored and returns the pages
+// the job stored, ordered by page
+func KeepJobOcrPageResults(db *gorm.DB, documentID int, jobID string) ([]OcrPageResult, error) {
+	if err := db.Where("document_id = ? AND job_id <> ?", documentID, jobID).Delete(&OcrPageResult{}).Error; err != nil {
+		return nil, err
+	}
+	return GetOcrPageResults(db, documentID)
+}
+
+// GetOcrPageResults retrieves the stored page results of a document ordered by page
+func GetOcrPageResults(db *gorm.DB, documentID int) ([]OcrPageResult, error) {
+	var records []OcrPageResult
+	result := db.Where("document_id = ?", documentID).Order("page_index ASC").Find(&records)
+	return records, result.Error
+}
+
+// GetJobOcrPageResults retrieves the page results a job stored for a document ordered by page. Pages that a
+// later run of the document replaced are not included.
+func GetJobOcrPageResults(db *gorm.DB, documentID int, jobID string) ([]OcrPageResult, error) {
+	var records []OcrPageResult
+	result := db.Where("document_id = ? AND job_id = ?", documentID, jobID).Order("page_index ASC").Find(&records)
+	return records, result.Error
+}
+
+// SaveOcrJobResult stores the text of a finished OCR job
+func SaveOcrJobResult(db *gorm.DB, jobID string, documentID int, username string, text string) error {
+	return db.Save(&OcrJobResult{
+		JobID:      jobID,
+		DocumentID: uint(documentID),
+		Username:   username,
+		Text:       text,
+		DateAdded:  time.Now().Format(time.RFC3339),
+	}).Error
+}
+
+// GetOcrJobResult retrieves the text of a finished OCR job
+func GetOcrJobResult(db *gorm.DB, jobID string) (*OcrJobResult, error) {
+	var record OcrJobResult
+	if err := db.Where("job_id = ?", jobID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// DeleteOcrJobResultsBefore removes the results of OCR jobs that finished before the cutoff
+func DeleteOcrJobResultsBefore(db *gorm.DB, cutoff time.Time) error {
+	// RFC 3339 timestamps of the same zone sort lexically
+	return db.Where("date_added < ?", cutoff.Format(time.RFC3339)).Delete(&OcrJobResult{}).Error
+}
+
+// errInvalidPageOrder is returned when a page list references unknown or duplicate pages
+var errInvalidPageOrder = errors.New("invalid page order")
+
+// ReorderOcrPageResults keeps only the listed pages of a document in the given order and renumbers them.
+// order holds the current page indexes; pages that are not listed are deleted.
+func ReorderOcrPageResults(db *gorm.DB, documentID int, order []int) ([]OcrPageResult, error) {
+	var pages []OcrPageResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		records, err := GetOcrPageResults(tx, documentID)
+		if err != nil {
+			return err
+		}
+		byIndex := make(map[int]OcrPageResult, len(records))
+		for _, record := range records {
+			byIndex[record.PageIndex] = record
+		}
+
+		for position, pageIndex := range order {
+			record, ok := byIndex[pageIndex]
+			if !ok {
+				return fmt.Errorf("%w: page %d does not exist or is listed twice", errInvalidPageOrder, pageIndex)
+			}
+			delete(byIndex, pageIndex)
+			record.PageIndex = position
+			pages = append(pages, record)
+		}
+
+		for _, dropped := range byIndex {
+			if err := tx.Delete(&dropped).Error; err != nil {
+				return err
+			}
+		}
+		for i := range pages {
+			if err := tx.Model(&pages[i]).Update("page_index", pages[i].PageIndex).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return pages, err
+}
+
+// InsertLLMTrace stores a trace and removes traces older than the retention period (if positive)
+func InsertLLMTrace(db *gorm.DB, trace *LLMTrace, retention time.Duration) error {
+	now := time.Now()
+	trace.DateCreated = now.Format(time.RFC3339)
+	if err := db.Create(trace).Error; err != nil {
+		return err
+	}
+	if retention <= 0 {
+		return nil
+	}
+	// RFC 3339 timestamps of the same zone sort lexically
+	return db.Where("date_created < ?", now.Add(-retention).Format(time.RFC3339)).Delete(&LLMTrace{}).Error
+}
+
+// InsertBacklogSample stores a backlog sample and removes samples older than the retention
+func InsertBacklogSample(db *gorm.DB, sample *BacklogSample, retention time.Duration) error {
+	now := time.Now()
+	sample.DateSampled = now.Format(time.RFC3339)
+	if err := db.Create(sample).Error; err != nil {
+		return err
+	}
+	return db.Where("date_sampled < ?", now.Add(-retention).Format(time.RFC3339)).Delete(&BacklogSample{}).Error
+}
+
+// GetBacklogSamples retrieves the backlog samples of a user taken since the given time, oldest first
+func GetBacklogSamples(db *gorm.DB, username string, since time.Time) ([]BacklogSample, error) {
+	var records []BacklogSample
+	result := db.Where("username = ? AND date_sampled >= ?", username, since.Format(time.RFC3339)).Order("date_sampled").Find(&records)
+	return records, result.Error
+}
+
+// RecordProcessingFailure counts a failed attempt to process a document in a queue
+func RecordProcessingFailure(db *gorm.DB, username string, documentID int, queue string, message string) error {
+	now := time.Now().Format(time.RFC3339)
+	var record ProcessingFailure
+	result := db.Where("document_id = ? AND queue = ? AND username = ?", documentID, queue, username).First(&record)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return db.Create(&ProcessingFailure{
+			DocumentID:      uint(documentID),
+			Queue:           queue,
+			Error:           message,
+			Attempts:        1,
+			Username:        username,
+			DateFirstFailed: now,
+			DateLastFailed:  now,
+		}).Error
+	} else if result.Error != nil {
+		return result.Error
+	}
+	return db.Model(&record).Updates(map[string]interface{}{
+		"error":            message,
+		"attempts":         record.Attempts + 1,
+		"date_last_failed": now,
+	}).Error
+}
+
+// ClearProcessingFailure removes the failure of a document in a queue once it was processed
+func ClearProcessingFailure(db *gorm.DB, username string, documentID int, queue string) error {
+	return db.Where("document_id = ? AND queue = ? AND username = ?", documentID, queue, username).Delete(&ProcessingFailure{}).Error
+}
+
+// GetProcessingFailures retrieves the failed documents of a user, most recently failed first
+func GetProcessingFailures(db *gorm.DB, username string) ([]ProcessingFailure, error) {
+	var records []ProcessingFailure
+	result := db.Where("username = ?", username).Order("date_last_failed DESC, id DESC").Find(&records)
+	return records, result.Error
+}
+
+// GetDocumentProcessingFailures retrieves the failures of a document of a user
+func GetDocumentProcessingFailures(db *gorm.DB, username string, documentID int) ([]ProcessingFailure, error) {
+	var records []ProcessingFailure
+	result := db.Where("document_id = ? AND username = ?", documentID, username).Find(&records)
+	return records, result.Error
+}
+
+// InsertSuggestionRecords stores new suggestions, replacing pending ones for the same document and field,
+// and removes records older than the retention
+func InsertSuggestionRecords(db *gorm.DB, records []SuggestionRecord, retention time.Duration) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range records {
+			records[i].DateCreated = now.Format(time.RFC3339)
+			if err := tx.Where("document_id = ? AND field = ? AND username = ? AND outcome = ?", records[i].DocumentID, records[i].Field, records[i].Username, "").
+				Delete(&SuggestionRecord{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&records[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("date_created < ?", now.Add(-retention).Format(time.RFC3339)).Delete(&SuggestionRecord{}).Error
+	})
+}
+
+// GetPendingSuggestionRecords retrieves the undecided suggestions of a user for a document
+func GetPendingSuggestionRecords(db *gorm.DB, username string, documentID int) ([]SuggestionRecord, error) {
+	var records []SuggestionRecord
+	result := db.Where("document_id = ? AND username = ? AND outcome = ?", documentID, username, "").Find(&records)
+	return records, result.Error
+}
+
+// SetSuggestionOutcome stores what the user did with a suggestion
+func SetSuggestionOutcome(db *gorm.DB, id uint, outcome string) error {
+	return db.Model(&SuggestionRecord{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"outcome":      outcome,
+		"date_decided": time.Now().Format(time.RFC3339),
+	}).Error
+}
+
+// GetSuggestionRecords retrieves the suggestions of a user made since the given time, oldest first
+func GetSuggestionRecords(db *gorm.DB, username string, since time.Time) ([]SuggestionRecord, error) {
+	var records []SuggestionRecord
+	result := db.Where("username = ? AND date_created >= ?", username, since.Format(time.RFC3339)).Order("date_created").Find(&records)
+	return records, result.Error
+}
+
+// InsertShadowResults stores shadow comparisons and removes the ones older than the retention
+func InsertShadowResults(db *gorm.DB, results []ShadowResult, retention time.Duration) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range results {
+			results[i].DateCreated = now.Format(time.RFC3339)
+			if err := tx.Create(&results[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("date_created < ?", now.Add(-retention).Format(time.RFC3339)).Delete(&ShadowResult{}).Error
+	})
+}
+
+// GetShadowResults retrieves the shadow comparisons of a user since the given time, newest first
+func GetShadowResults(db *gorm.DB, username string, since time.Time) ([]ShadowResult, error) {
+	var results []ShadowResult
+	result := db.Where("username = ? AND date_created >= ?", username, since.Format(time.RFC3339)).Order("date_created DESC, id DESC").Find(&results)
+	return results, result.Error
+}
+
+// GetOcrCacheEntry retrieves a cached OCR response stored since the given time and marks it as used
+func GetOcrCacheEntry(db *gorm.DB, hash string, since time.Time) (*OcrCacheEntry, error) {
+	var record OcrCacheEntry
+	result := db.Where("hash = ? AND date_added >= ?", hash, since.Format(time.RFC3339)).First(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	record.DateUsed = time.Now().Format(time.RFC3339)
+	return &record, db.Model(&record).Update("date_used", record.DateUsed).Error
+}
+
+// SaveOcrCacheEntry stores an OCR response and removes the least recently used entries beyond maxEntries
+func SaveOcrCacheEntry(db *gorm.DB, hash string, text string, maxEntries int) error {
+	now := time.Now().Format(time.RFC3339)
+	if err := db.Save(&OcrCacheEntry{Hash: hash, Text: text, DateAdded: now, DateUsed: now}).Error; err != nil {
+		return err
+	}
+	keep := db.Model(&OcrCacheEntry{}).Select("hash").Order("date_used DESC").Limit(maxEntries)
+	return db.Where("hash NOT IN (?)", keep).Delete(&OcrCacheEntry{}).Error
+}
+
+// DeleteOcrCacheEntriesBefore removes cached OCR responses stored before the cutoff and returns their number
+func DeleteOcrCacheEntriesBefore(db *gorm.DB, cutoff time.Time) (int64, error) {
+	result := db.Where("date_added < ?", cutoff.Format(time.RFC3339)).Delete(&OcrCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// GetLLMTraces retrieves the traces of a document, newest first
+func GetLLMTraces(db *gorm.DB, documentID int) ([]LLMTrace, error) {
+	var records []LLMTrace
+	result := db.Where("document_id = ?", documentID).Order("id DESC").Find(&records)
+	return records, result.Error
+}
+
+// errDuplicateCategory is returned when a category name is already in use
+var errDuplicateCategory = errors.New("a category with this name already exists")
+
+// GetCategories retrieves all categories ordered by name
+func GetCategories(db *gorm.DB) ([]Category, error) {
+	var records []Category
+	result := db.Order("name ASC").Find(&records)
+	return records, result.Error
+}
+
+// SaveCategory creates a category, or updates it if the ID is set
+func SaveCategory(db *gorm.DB, category *Category) error {
+	var count int64
+	if err := db.Model(&Category{}).Where("LOWER(name) = LOWER(?) AND id <> ?", category.Name, category.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errDuplicateCategory
+	}
+	if category.ID == 0 {
+		category.DateAdded = time.Now().Format(time.RFC3339)
+		return db.Create(category).Error
+	}
+	var existing Category
+	if err := db.First(&existing, category.ID).Error; err != nil {
+		return err
+	}
+	category.DateAdded = existing.DateAdded
+	return db.Save(category).Error
+}
+
+// DeleteCategory removes a category
+func DeleteCategory(db *gorm.DB, id uint) error {
+	result := db.Delete(&Category{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// toResponse converts a category into its API representation
+func (category *Category) toResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           category.ID,
+		"name":         category.Name,
+		"description":  category.Description,
+		"tag":          category.Tag,
+		"custom_field": category.CustomField,
+		"date_added":   category.DateAdded,
+	}
+}
+
+// InsertReport stores a report generated for a user
+func InsertReport(db *gorm.DB, username string, statistics ReportStatistics, summary string) (*Report, error) {
+	encoded, err := json.Marshal(statistics)
+	if err != nil {
+		return nil, err
+	}
+	record := &Report{
+		PeriodStart: statistics.PeriodStart,
+		PeriodEnd:   statistics.PeriodEnd,
+		Statistics:  string(encoded),
+		Summary:     summary,
+		Username:    username,
+		DateCreated: time.Now().Format(time.RFC3339),
+	}
+	return record, db.Create(record).Error
+}
+
+// GetReports retrieves all reports of a user, newest first
+func GetReports(db *gorm.DB, username string) ([]Report, error) {
+	var records []Report
+	result := db.Where("username = ?", username).Order("id DESC").Find(&records)
+	return records, result.Error
+}
+
+// GetReport retrieves a single report of a user by ID
+func GetReport(db *gorm.DB, id uint, username string) (*Report, error) {
+	var record Report
+	result := db.Where("username = ?", username).First(&record, id)
+	return &record, result.Error
+}
+
+// toResponse converts a report into its API representation with decoded statistics
+func (record *Report) toResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           record.ID,
+		"period_start": record.PeriodStart,
+		"period_end":   record.PeriodEnd,
+		"statistics":   json.RawMessage(record.Statistics),
+		"summary":      record.Summary,
+		"date_created": record.DateCreated,
+	}
+}
+
+// GetTenantUsers retrieves all users with a registered paperless token, ordered by name
+func GetTenantUsers(db *gorm.DB) ([]TenantUser, error) {
+	var records []TenantUser
+	result := db.Order("username").Find(&records)
+	return records, result.Error
+}
+
+// GetTenantUser retrieves the registered user with the given name
+func GetTenantUser(db *gorm.DB, username string) (*TenantUser, error) {
+	var record TenantUser
+	result := db.Where("username = ?", username).First(&record)
+	return &record, result.Error
+}
+
+// SaveTenantUser encrypts and stores the paperless token of a user, replacing a previously registered token
+func SaveTenantUser(db *gorm.DB, username string, token string) error {
+	encrypted, err := encryptSecret(token)
+	if err != nil {
+		return err
+	}
+
+	var record TenantUser
+	if err := db.Where("username = ?", username).Limit(1).Find(&record).Error; err != nil {
+		return err
+	}
+	if record.ID == 0 {
+		record = TenantUser{Username: username, DateAdded: time.Now().Format(time.RFC3339)}
+	}
+	record.EncryptedToken = encrypted
+	return db.Save(&record).Error
+}
+
+// RotateTenantTokens re-encrypts all stored tokens that are not encrypted with the current SECRETS_KEY
+// and returns how many were changed
+func RotateTenantTokens(db *gorm.DB) (int, error) {
+	users, err := GetTenantUsers(db)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, user := range users {
+		token, err := decryptSecret(user.EncryptedToken)
+		if err != nil {
+			return rotated, fmt.Errorf("user %s: %w", user.Username, err)
+		}
+		if !secretNeedsRotation(user.EncryptedToken) {
+			continue
+		}
+		if user.EncryptedToken, err = encryptSecret(token); err != nil {
+			return rotated, err
+		}
+		if err := db.Save(&user).Error; err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// DeleteTenantUser removes the registered token of a user
+func DeleteTenantUser(db *gorm.DB, username string) error {
+	result := db.Where("username = ?", username).Delete(&TenantUser{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetBackfillCheckpoint retrieves the stored backfill checkpoint of a user
+func GetBackfillCheckpoint(db *gorm.DB, username string) (*BackfillCheckpoint, error) {
+	var record BackfillCheckpoint
+	result := db.Where("username = ?", username).First(&record)
+	return &record, result.Error
+}
+
+// SaveBackfillCheckpoint creates or updates the backfill checkpoint of record.Username
+func SaveBackfillCheckpoint(db *gorm.DB, record *BackfillCheckpoint) error {
+	record.DateUpdated = time.Now().Format(time.RFC3339)
+	return db.Save(record).Error
+}
+
+// DeleteBackfillCheckpoint removes the backfill checkpoint of a user once the backfill has finished
+func DeleteBackfillCheckpoint(db *gorm.DB, username string) error {
+	return db.Where("username = ?", username).Delete(&BackfillCheckpoint{}).Error
+}
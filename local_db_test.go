@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestOcrPageResults(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer DeleteOcrPageResults(db, 7)
+
+	require.NoError(t, SaveOcrPageResult(db, "", 7, 1, "second page", false))
+	require.NoError(t, SaveOcrPageResult(db, "", 7, 0, "first page", false))
+	require.NoError(t, SaveOcrPageResult(db, "", 7, 1, "second page, processed again", false))
+	require.NoError(t, SaveOcrPageResult(db, "", 8, 0, "other document", false))
+	defer DeleteOcrPageResults(db, 8)
+
+	pages, err := GetOcrPageResults(db, 7)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	assert.Equal(t, 0, pages[0].PageIndex)
+	assert.Equal(t, "first page", pages[0].Text)
+	assert.Equal(t, "second page, processed again", pages[1].Text)
+
+	require.NoError(t, DeleteOcrPageResults(db, 7))
+	pages, err = GetOcrPageResults(db, 7)
+	require.NoError(t, err)
+	assert.Empty(t, pages)
+}
+
+func TestKeepJobOcrPageResults(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer DeleteOcrPageResults(db, 10)
+
+	// The interrupted job completed page 0, another job for the same document then stored page 1
+	require.NoError(t, SaveOcrPageResult(db, "interrupted", 10, 0, "first page", false))
+	require.NoError(t, SaveOcrPageResult(db, "other", 10, 1, "second page of another run", false))
+
+	pages, err := KeepJobOcrPageResults(db, 10, "interrupted")
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, "first page", pages[0].Text)
+
+	stored, err := GetOcrPageResults(db, 10)
+	require.NoError(t, err)
+	assert.Len(t, stored, 1, "pages of other runs are removed")
+}
+
+func TestGetJobOcrPageResults(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer DeleteOcrPageResults(db, 11)
+
+	require.NoError(t, SaveOcrPageResult(db, "first-run", 11, 0, "first page", false))
+	require.NoError(t, SaveOcrPageResult(db, "first-run", 11, 1, "second page", false))
+	// A later run of the document replaced the second page
+	require.NoError(t, SaveOcrPageResult(db, "second-run", 11, 1, "second page again", false))
+
+	pages, err := GetJobOcrPageResults(db, 11, "first-run")
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, "first page", pages[0].Text)
+
+	pages, err = GetJobOcrPageResults(db, 11, "second-run")
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, "second page again", pages[0].Text)
+}
+
+func TestReorderOcrPageResults(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer DeleteOcrPageResults(db, 9)
+
+	for i, text := range []string{"cover", "blank", "content"} {
+		require.NoError(t, SaveOcrPageResult(db, "", 9, i, text, false))
+	}
+
+	_, err = ReorderOcrPageResults(db, 9, []int{2, 2})
+	assert.ErrorIs(t, err, errInvalidPageOrder)
+
+	// Drop the blank page and move the content before the cover
+	pages, err := ReorderOcrPageResults(db, 9, []int{2, 0})
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+
+	stored, err := GetOcrPageResults(db, 9)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, 0, stored[0].PageIndex)
+	assert.Equal(t, "content", stored[0].Text)
+	assert.Equal(t, 1, stored[1].PageIndex)
+	assert.Equal(t, "cover", stored[1].Text)
+}
+
+func TestOcrJobResults(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	require.NoError(t, SaveOcrJobResult(db, "job-1", 7, "alice", "full text"))
+	record, err := GetOcrJobResult(db, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "full text", record.Text)
+	assert.Equal(t, uint(7), record.DocumentID)
+	assert.Equal(t, "alice", record.Username)
+
+	require.NoError(t, DeleteOcrJobResultsBefore(db, time.Now().Add(time.Minute)))
+	_, err = GetOcrJobResult(db, "job-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
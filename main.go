@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +20,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/googleai"
 	"github.com/tmc/langchaingo/llms/ollama"
 	"github.com/tmc/langchaingo/llms/openai"
 	"gorm.io/gorm"
@@ -35,27 +39,107 @@ var (
 	paperlessBaseURL           = os.Getenv("PAPERLESS_BASE_URL")
 	paperlessAPIToken          = os.Getenv("PAPERLESS_API_TOKEN")
 	openaiAPIKey               = os.Getenv("OPENAI_API_KEY")
-	manualTag                  = os.Getenv("MANUAL_TAG")
-	autoTag                    = os.Getenv("AUTO_TAG")
+	googleaiAPIKey             = os.Getenv("GOOGLEAI_API_KEY")
+	anthropicAPIKey            = os.Getenv("ANTHROPIC_API_KEY")
+	anthropicBaseURLOverride   = os.Getenv("ANTHROPIC_BASE_URL")
 	manualOcrTag               = os.Getenv("MANUAL_OCR_TAG") // Not used yet
-	autoOcrTag                 = os.Getenv("AUTO_OCR_TAG")
 	llmProvider                = os.Getenv("LLM_PROVIDER")
 	llmModel                   = os.Getenv("LLM_MODEL")
 	visionLlmProvider          = os.Getenv("VISION_LLM_PROVIDER")
 	visionLlmModel             = os.Getenv("VISION_LLM_MODEL")
+	consensusVisionProvider    = os.Getenv("OCR_CONSENSUS_PROVIDER")
+	consensusVisionModel       = os.Getenv("OCR_CONSENSUS_MODEL")
+	handwritingTag             = os.Getenv("HANDWRITING_TAG")
+	handwritingProvider        = os.Getenv("HANDWRITING_LLM_PROVIDER")
+	handwritingModel           = os.Getenv("HANDWRITING_LLM_MODEL")
+	shadowLlmProvider          = os.Getenv("SHADOW_LLM_PROVIDER")
+	shadowLlmModel             = os.Getenv("SHADOW_LLM_MODEL")
 	logLevel                   = strings.ToLower(os.Getenv("LOG_LEVEL"))
 	listenInterface            = os.Getenv("LISTEN_INTERFACE")
 	autoGenerateTitle          = os.Getenv("AUTO_GENERATE_TITLE")
 	autoGenerateTags           = os.Getenv("AUTO_GENERATE_TAGS")
 	autoGenerateCorrespondents = os.Getenv("AUTO_GENERATE_CORRESPONDENTS")
-	limitOcrPages              int // Will be read from OCR_LIMIT_PAGES
-	tokenLimit                 = 0 // Will be read from TOKEN_LIMIT
+	autoGenerateCustomFields   = os.Getenv("AUTO_GENERATE_CUSTOM_FIELDS")
+	selectCustomFields         = splitAndTrim(os.Getenv("SELECT_CUSTOM_FIELDS"))
+	extractionCustomField      = os.Getenv("EXTRACTION_CUSTOM_FIELD")
+	correspondentCandidates    = strings.ToLower(os.Getenv("CORRESPONDENT_CANDIDATES")) == "true"
+	correspondentApproval      = strings.ToLower(os.Getenv("CORRESPONDENT_APPROVAL")) == "true"
+	suggestionRationale        = strings.ToLower(os.Getenv("SUGGESTION_RATIONALE")) == "true"
+	emailHeaderMetadata        = strings.ToLower(os.Getenv("EMAIL_HEADER_METADATA")) == "true"
+	sandboxMode                = strings.ToLower(os.Getenv("SANDBOX_MODE")) == "true"
+	shadowMode                 = strings.ToLower(os.Getenv("SHADOW_MODE")) == "true"
+	startupProviderCheck       = strings.ToLower(os.Getenv("STARTUP_PROVIDER_CHECK")) != "false"
+	ollamaAutoPull             = strings.ToLower(os.Getenv("OLLAMA_AUTO_PULL")) == "true"
+	llmTraces                  = strings.ToLower(os.Getenv("LLM_TRACES")) == "true"
+	providerLogFile            = os.Getenv("PROVIDER_LOG_FILE")
+	providerLogRedactText      = strings.ToLower(os.Getenv("PROVIDER_LOG_REDACT_TEXT")) == "true"
+	titleDedupe                = strings.ToLower(os.Getenv("TITLE_DEDUPE"))
+	tagHierarchySeparator      = os.Getenv("TAG_HIERARCHY_SEPARATOR")
+	titleTranslationTypes      = splitAndTrim(os.Getenv("TITLE_TRANSLATION_DOCUMENT_TYPES"))
+	translateSummaries         = strings.ToLower(os.Getenv("TRANSLATE_SUMMARIES")) == "true"
+	originalTitleCustomField   = os.Getenv("ORIGINAL_TITLE_CUSTOM_FIELD")
+	ocrStripHeaders            = strings.ToLower(os.Getenv("OCR_STRIP_HEADERS")) == "true"
+	ocrLanguageDetection       = strings.ToLower(os.Getenv("OCR_LANGUAGE_DETECTION")) == "true"
+	ocrLanguageTagPrefix       = os.Getenv("OCR_LANGUAGE_TAG_PREFIX")
+	ocrLanguageCustomField     = os.Getenv("OCR_LANGUAGE_CUSTOM_FIELD")
+	faultInjection             = os.Getenv("FAULT_INJECTION")
+	reportSchedule             = os.Getenv("REPORT_SCHEDULE")
+	reportAmountField          = os.Getenv("REPORT_AMOUNT_FIELD")
+	reportWebhookURL           = os.Getenv("REPORT_WEBHOOK_URL")
+	dueDateCustomField         = os.Getenv("DUE_DATE_CUSTOM_FIELD")
+	dueSoonTag                 = os.Getenv("DUE_SOON_TAG")
+	dueSoonWebhookURL          = os.Getenv("DUE_SOON_WEBHOOK_URL")
+	autoChangesWebhookURL      = os.Getenv("AUTO_CHANGES_WEBHOOK_URL")
+	jobWebhookURL              = os.Getenv("JOB_WEBHOOK_URL")
+	mqttURL                    = os.Getenv("MQTT_URL")
+	mqttTopicPrefix            = os.Getenv("MQTT_TOPIC_PREFIX")
+	mqttDiscoveryPrefix        = os.Getenv("MQTT_DISCOVERY_PREFIX")
+	usageStatistics            = strings.ToLower(os.Getenv("USAGE_STATISTICS")) == "true"
+	usageStatisticsURL         = os.Getenv("USAGE_STATISTICS_URL")
+	multiTenant                = strings.ToLower(os.Getenv("MULTI_TENANT")) == "true"
+	authUserHeader             = os.Getenv("AUTH_USER_HEADER")
+	adminUsers                 = splitAndTrim(os.Getenv("ADMIN_USERS"))
+	secretsKey                 = os.Getenv("SECRETS_KEY")
+	secretsPreviousKeys        = splitAndTrim(os.Getenv("SECRETS_PREVIOUS_KEYS"))
+	updateCheck                = strings.ToLower(os.Getenv("UPDATE_CHECK")) == "true"
+	configFile                 = os.Getenv("CONFIG_FILE")
+	redisURL                   = os.Getenv("REDIS_URL")
+	leaderElection             = strings.ToLower(os.Getenv("LEADER_ELECTION")) == "true"
+	processingNotes            = strings.ToLower(os.Getenv("PROCESSING_NOTES")) == "true"
+	preferFresherOcrText       = strings.ToLower(os.Getenv("PREFER_FRESHER_OCR_TEXT")) == "true"
+	paperlessProxyPaths        = splitAndTrim(os.Getenv("PAPERLESS_PROXY_PATHS"))
+	backgroundProcessing       = strings.ToLower(os.Getenv("ENABLE_BACKGROUND_PROCESSING")) != "false"
+	limitOcrPages              int     // Will be read from OCR_LIMIT_PAGES
+	ocrConsensusThreshold      = 0.9   // Will be read from OCR_CONSENSUS_THRESHOLD
+	handwritingMinConfidence   = 0.6   // Will be read from HANDWRITING_MIN_CONFIDENCE
+	llmCostPer1kTokens         float64 // Will be read from LLM_COST_PER_1K_TOKENS
+	ocrCostPerPage             float64 // Will be read from OCR_COST_PER_PAGE
+	estimateAbortFactor        float64 // Will be read from ESTIMATE_ABORT_FACTOR
+	blankPageVariance          float64 // Will be read from BLANK_PAGE_VARIANCE
+	llmTraceMaxBytes           = 65536 // Will be read from LLM_TRACE_MAX_BYTES
+	llmTraceRetentionDays      = 7     // Will be read from LLM_TRACE_RETENTION_DAYS
+	providerLogMaxMB           = 10    // Will be read from PROVIDER_LOG_MAX_MB, 0 disables the rotation
+	dueSoonDays                = 14    // Will be read from DUE_SOON_DAYS
 
 	// Templates
 	titleTemplate         *template.Template
 	tagTemplate           *template.Template
 	correspondentTemplate *template.Template
+	customFieldTemplate   *template.Template
+	extractionTemplate    *template.Template
+	reportTemplate        *template.Template
+	createdDateTemplate   *template.Template
+	summaryTemplate       *template.Template
+	categoryTemplate      *template.Template
+	dueDateTemplate       *template.Template
+	batchTitleTemplate    *template.Template
+	batchTagTemplate      *template.Template
 	ocrTemplate           *template.Template
+	handwritingTemplate   *template.Template
+	translationTemplate   *template.Template
+	taxonomyTemplate      *template.Template
+	comparisonTemplate    *template.Template
+	promptOverrides       map[string]map[string]*template.Template // document type (lower case) -> template file -> template
 	templateMutex         sync.RWMutex
 
 	// Default templates
@@ -63,19 +147,29 @@ var (
 Your task is to find a suitable document title that I can use as the title in the paperless-ngx program.
 Respond only with the title, without any additional information. The content is likely in {{.Language}}.
 
-Content:
+{{if .Examples}}Examples of documents in this archive:
+{{.Examples}}
+
+{{end}}{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
 {{.Content}}
 `
 
 	defaultTagTemplate = `I will provide you with the content and the title of a document. Your task is to select appropriate tags for the document from the list of available tags I will provide. Only select tags from the provided list. Respond only with the selected tags as a comma-separated list, without any additional information. The content is likely in {{.Language}}.
 
 Available Tags:
-{{.AvailableTags | join ", "}}
+{{if .TagTree}}{{.TagTree}}{{else}}{{.AvailableTags | join ", "}}{{end}}
 
 Title:
 {{.Title}}
 
-Content:
+{{if .Examples}}Examples of documents in this archive:
+{{.Examples}}
+
+{{end}}{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
 {{.Content}}
 
 Please concisely select the {{.Language}} tags from the list above that best describe the document.
@@ -105,9 +199,190 @@ Title of the document:
 
 The content is likely in {{.Language}}.
 
-Document Content:
+{{if .Examples}}Examples of documents in this archive:
+{{.Examples}}
+
+{{end}}{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Document Content:
+{{.Content}}
+`
+	defaultCustomFieldTemplate = `I will provide you with the content and the title of a document. Your task is to choose the value of the field "{{.FieldName}}" for this document.
+
+Only choose one of the following options:
+{{.Options | join "\n"}}
+
+Respond only with the chosen option exactly as written above, without any additional information. If none of the options fits the document, respond with "None". The content is likely in {{.Language}}.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
+{{.Content}}
+`
+	defaultExtractionTemplate = `I will provide you with the content of a document that lists several similar entries, for example a statement listing multiple invoices.
+Your task is to extract every listed entry as a separate row.
+
+Respond with a JSON object of the form {"rows": [...]} where each row is an object with the keys "number", "date" (YYYY-MM-DD), "amount" (number), "currency" and "description". Use null for values that are not present.
+Respond only with the JSON object, without any additional information. The content is likely in {{.Language}}.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
+{{.Content}}
+`
+	defaultCreatedDateTemplate = `I will provide you with the content and the title of a document. Your task is to find the date the document was issued, for example the invoice or letter date.
+
+Respond only with the date in the format YYYY-MM-DD, without any additional information. If the document does not state when it was issued, respond with "Unknown". The content is likely in {{.Language}}.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
+{{.Content}}
+`
+	defaultSummaryTemplate = `I will provide you with the content and the title of a document. Your task is to summarize the document in two or three sentences, naming the sender, the purpose and any amounts or deadlines.
+
+Respond only with the summary in {{.Language}}, without any additional information.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
+{{.Content}}
+`
+	defaultTranslationTemplate = `I will provide you with the {{.Kind}} of a document. Your task is to translate it into {{.Language}}.
+If it is already in {{.Language}}, repeat it unchanged. Keep names, numbers, dates and reference codes as they are.
+Respond only with the translation, without any additional information.
+
+{{.Text}}
+`
+	defaultTaxonomyTemplate = `I will provide you with the tags of a document archive, with the number of documents carrying each tag, and a sample of recent documents with their tags. Your task is to propose a cleaned-up tag taxonomy.
+
+Look for:
+- Duplicates and near-duplicates, such as spelling variants, singular and plural or the same tag in different languages, that should be merged into one tag
+- Unclear or inconsistent names that should be renamed
+{{if .Separator}}- Tags that belong below a parent tag. Tags are nested by their full name with "{{.Separator}}" between the levels, e.g. "Finance{{.Separator}}Invoices". Rename a tag to its full name to move it into the hierarchy.
+{{end}}
+Only propose changes that clearly improve the taxonomy, and keep the tag names in {{.Language}}.
+
+Respond with a JSON object of the form {"operations": [{"action": "merge", "from": ["<tag>", ...], "to": "<tag>", "reason": "<short reason>"}, {"action": "rename", "from": ["<tag>"], "to": "<new name>", "reason": "<short reason>"}]}. "from" may only contain tags from the list below, "to" may be an existing or a new tag. Respond only with the JSON object, or with {"operations": []} if the taxonomy needs no changes.
+
+Tags:
+{{range .Tags}}- {{.Name}} ({{.DocumentCount}} documents)
+{{end}}
+Sample documents:
+{{range .Documents}}- {{.Title}}: {{join ", " .Tags}}
+{{end}}`
+	defaultComparisonTemplate = `I will provide you with the content and the title of two documents, for example two versions of a contract or an invoice and its correction. Your task is to compare them.
+
+List the differences in:
+- parties: people and organizations, their names, addresses and roles
+- amounts: prices, totals, fees, rates and other sums
+- dates: issue, start, end, due and notice dates and periods
+- clauses: terms, conditions, obligations and other provisions
+
+Respond with a JSON object of the form {"summary": "<one or two sentences on how the documents relate and what changed>", "parties": [...], "amounts": [...], "dates": [...], "clauses": [...]}, where every list holds objects of the form {"aspect": "<what differs>", "first": "<value in the first document>", "second": "<value in the second document>"}. Use an empty string for a value missing in one document, and an empty list if nothing differs. Respond only with the JSON object, written in {{.Language}}.
+
+First document:
+Title: {{.First.Title}}
+{{.First.Content}}
+
+Second document:
+Title: {{.Second.Title}}
+{{.Second.Content}}
+{{if .Hint}}
+Hint from the user about these documents: {{.Hint}}
+{{end}}`
+	defaultClassificationTemplate = `I will provide you with the content and the title of a document. Your task is to assign the document to the categories below that apply to it. A document may belong to several categories or to none.
+
+Categories:
+{{range .Categories}}- {{.Name}}: {{.Description}}
+{{end}}
+Respond with a JSON object of the form {"categories": ["<name>", ...]} using only the category names listed above, or {"categories": []} if none applies. Respond only with the JSON object. The content is likely in {{.Language}}.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
+{{.Content}}
+`
+	defaultDueDateTemplate = `I will provide you with the content and the title of a document. Your task is to find the date by which the document requires action, for example the due date of an invoice, the expiry date of a warranty or the end or notice date of a contract.
+
+Respond only with the date in the format YYYY-MM-DD, without any additional information. If the document has no such date, respond with "Unknown". The content is likely in {{.Language}}.
+
+Title:
+{{.Title}}
+
+{{if .Hint}}Hint from the user about this document: {{.Hint}}
+
+{{end}}Content:
 {{.Content}}
 `
+	defaultBatchTitleTemplate = `I will provide you with the contents of several documents that have been partially read by OCR (so they may contain errors).
+Your task is to find a suitable document title for each of them that I can use as the title in the paperless-ngx program.
+If the original title is already adding value and not just a technical filename you can use it as extra information to enhance your suggestion.
+Respond with a JSON object of the form {"documents": [{"id": <id>, "title": "<title>"}, ...]} containing one entry for every document, without any additional information. The content is likely in {{.Language}}.
+
+{{if .Hint}}Hint from the user about these documents: {{.Hint}}
+
+{{end}}{{range .Documents}}Document {{.ID}}
+Original title: {{.Title}}
+Content:
+{{.Content}}
+
+{{end}}`
+	defaultBatchTagTemplate = `I will provide you with the contents and the titles of several documents. Your task is to select appropriate tags for each of them from the list of available tags I will provide. Only select tags from the provided list.
+Respond with a JSON object of the form {"documents": [{"id": <id>, "tags": ["<tag>", ...]}, ...]} containing one entry for every document, without any additional information. The content is likely in {{.Language}}.
+
+Available Tags:
+{{if .TagTree}}{{.TagTree}}{{else}}{{.AvailableTags | join ", "}}{{end}}
+
+{{if .Hint}}Hint from the user about these documents: {{.Hint}}
+
+{{end}}{{range .Documents}}Document {{.ID}}
+Title: {{.Title}}
+Content:
+{{.Content}}
+
+{{end}}`
+	defaultReportTemplate = `I will provide you with statistics about the documents that were added to a document archive between {{.PeriodStart}} and {{.PeriodEnd}}.
+Your task is to write a short, friendly summary of this period for the owner of the archive. Mention notable correspondents and amounts, but do not invent any numbers.
+Respond only with the summary in {{.Language}}, without any additional information.
+
+New documents: {{.NewDocuments}}
+
+Top correspondents:
+{{range .TopCorrespondents}}- {{.Name}}: {{.Count}} documents
+{{end}}
+Invoice totals:
+{{range $currency, $amount := .InvoiceTotals}}- {{$currency}} {{printf "%.2f" $amount}}
+{{end}}`
+	// correspondentCandidatesInstruction is appended to the correspondent prompt when CORRESPONDENT_CANDIDATES is enabled
+	correspondentCandidatesInstruction = `Instead of a single name, respond with a JSON object listing up to 3 correspondent candidates ordered from most to least likely, each with a confidence between 0 and 1, for example:
+{"candidates": [{"name": "Amazon", "confidence": 0.8}, {"name": "Audible", "confidence": 0.15}]}
+Respond only with the JSON object.`
+	// tagRationaleInstruction is appended to the tag prompt when a rationale is requested
+	tagRationaleInstruction = `Instead of a comma-separated list, respond with a JSON object listing each selected tag with a short reason (one sentence) why it fits the document, for example:
+{"tags": [{"name": "Invoice", "reason": "The document states an amount due and an invoice number."}]}
+Respond only with the JSON object.`
+	// correspondentRationaleInstruction is appended to the correspondent prompt when a rationale is requested
+	correspondentRationaleInstruction = `Instead of a single name, respond with a JSON object containing the correspondent and a short reason (one sentence) why it fits the document, for example:
+{"correspondents": [{"name": "Amazon", "reason": "The invoice header names Amazon EU S.a.r.l. as the seller."}]}
+Respond only with the JSON object.`
+	defaultHandwritingOcrPrompt = `This image is a page of a handwritten document. Transcribe ALL the handwriting and any printed text on it, line by line, and preserve the layout. Take your time with hard to read words and use the context of the sentence to decide between similar looking letters and digits. Do not guess words you cannot read, write [illegible] instead. Use markdown format but without a code block.
+After the transcription, add a last line "CONFIDENCE: <0-100>" stating how sure you are that the transcription is correct, in percent.`
 	defaultOcrPrompt = `Just transcribe the text in this image and preserve the formatting and layout (high quality OCR). Do that for ALL the text in the image. Be thorough and pay attention. This is very important. The image is from a text document so be sure to continue until the bottom of the page. Thanks a lot! You tend to forget about some text in the image so please focus! Use markdown format but without a code block.`
 )
 
@@ -117,6 +392,18 @@ type App struct {
 	Database  *gorm.DB
 	LLM       llms.Model
 	VisionLLM llms.Model
+
+	// LLMModel is the name of the model behind LLM for the per-model metrics, LLM_MODEL if empty
+	LLMModel string
+
+	// ConsensusVisionLLM transcribes every page a second time (OCR_CONSENSUS_PROVIDER), nil if disabled
+	ConsensusVisionLLM llms.Model
+
+	// HandwritingVisionLLM transcribes documents tagged with HANDWRITING_TAG, nil to use VisionLLM
+	HandwritingVisionLLM llms.Model
+
+	// ShadowLLM generates the suggestions of the shadow mode (SHADOW_MODE), which are compared but never applied
+	ShadowLLM llms.Model
 }
 
 func main() {
@@ -129,101 +416,299 @@ func main() {
 	// Print version
 	printVersion()
 
+	// Settings in CONFIG_FILE override the environment and can be reloaded through POST /api/config/reload
+	if configFile != "" {
+		if _, err := reloadConfig(); err != nil {
+			log.Fatalf("Invalid CONFIG_FILE: %v", err)
+		}
+	}
+
+	// Bound the memory of concurrently rendered PDF pages
+	renderMemory = newRenderBudget(int64(pdfRenderMemoryMB) << 20)
+
+	// Serve a fake paperless-ngx API instead of talking to a real instance
+	if sandboxMode {
+		sandboxURL, err := startSandboxServer()
+		if err != nil {
+			log.Fatalf("Failed to start sandbox paperless API: %v", err)
+		}
+		log.Warnf("Sandbox mode: using fake paperless-ngx API at %s, no real documents are touched", sandboxURL)
+		paperlessBaseURL, paperlessAPIToken = sandboxURL, "sandbox"
+	}
+
 	// Initialize PaperlessClient
 	client := NewPaperlessClient(paperlessBaseURL, paperlessAPIToken)
+	paperlessHTTPClient, err := newHTTPClient(paperlessTimeout, os.Getenv("PAPERLESS_PROXY"))
+	if err != nil {
+		log.Fatalf("Failed to create paperless HTTP client: %v", err)
+	}
+	client.HTTPClient = paperlessHTTPClient
 
 	// Initialize Database
 	database := InitializeDB()
+	jobStore = newJobStore(database)
+
+	// Re-encrypt stored secrets that still use a previous SECRETS_KEY
+	if secretsKey != "" {
+		rotated, err := RotateTenantTokens(database)
+		if err != nil {
+			log.Fatalf("Failed to re-encrypt stored tokens: %v", err)
+		}
+		if rotated > 0 {
+			log.Infof("Re-encrypted %d stored tokens with the current SECRETS_KEY", rotated)
+		}
+	}
+
+	// Share OCR jobs with other replicas through Redis
+	if redisURL != "" {
+		redisClient, err := newRedisClient(redisURL)
+		if err != nil {
+			log.Fatalf("Failed to configure Redis: %v", err)
+		}
+		jobStore.shared = newRedisJobQueue(redisClient, "paperless-gpt:")
+		if err := jobStore.shared.ping(context.Background()); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		log.Infof("Sharing OCR jobs through Redis at %s", redisClient.Options().Addr)
+
+		// Every replica has to act for the users registered on any of them
+		if multiTenant {
+			sharedTenants = newRedisTenantStore(redisClient, "paperless-gpt:tenants")
+			moved, err := sharedTenants.moveLocal(context.Background(), database)
+			if err != nil {
+				log.Fatalf("Failed to share the registered tokens through Redis: %v", err)
+			}
+			if moved > 0 {
+				log.Infof("Moved %d tokens registered on this replica to Redis", moved)
+			}
+			if secretsKey != "" {
+				rotated, err := sharedTenants.rotate(context.Background())
+				if err != nil {
+					log.Fatalf("Failed to re-encrypt the tokens in Redis: %v", err)
+				}
+				if rotated > 0 {
+					log.Infof("Re-encrypted %d tokens in Redis with the current SECRETS_KEY", rotated)
+				}
+			}
+		}
+
+		// Only the replica holding the leader lock runs the background tasks
+		if leaderElection {
+			leader = newLeaderLock(redisClient, "paperless-gpt:leader")
+			leader.start()
+		}
+	}
+
+	// Publish processing events and backlogs for home automation
+	if mqttURL != "" {
+		client, err := newMQTTClient(mqttURL, mqttClientID())
+		if err != nil {
+			log.Fatalf("Failed to configure MQTT: %v", err)
+		}
+		prefix := mqttTopicPrefix
+		if prefix == "" {
+			prefix = "paperless-gpt"
+		}
+		// An unreachable broker does not delay the start, the connection is retried in the background
+		mqttStatus = newMQTTPublisher(client, prefix, mqttDiscoveryPrefix)
+	}
+
+	// Anonymous usage statistics are strictly opt-in, and only sent anywhere with a configured URL
+	if usageStatistics {
+		log.Info("Usage statistics are enabled, see GET /api/usage-statistics for the collected data")
+		if usageStatisticsURL != "" {
+			go sendUsageReports(context.Background())
+		}
+	}
 
 	// Load Templates
 	loadTemplates()
 
-	// Initialize LLM
-	llm, err := createLLM()
-	if err != nil {
-		log.Fatalf("Failed to create LLM client: %v", err)
+	// Initialize LLM, unless running in OCR-only mode
+	var llm llms.Model
+	if isLLMEnabled() {
+		llm, err = createLLM(llmProvider, llmModel)
+		if err != nil {
+			log.Fatalf("Failed to create LLM client: %v", err)
+		}
+		llm = &usageTrackingLLM{Model: llm, model: llmModel}
+	}
+
+	// Initialize the candidate LLM of the shadow mode, by default the production model with the shadow prompts
+	var shadowLlm llms.Model
+	if shadowMode {
+		if shadowLlmProvider == "" && len(shadowPrompts) == 0 {
+			log.Warn("SHADOW_MODE is enabled without SHADOW_LLM_PROVIDER or prompts in prompts/shadow/, the shadow runs repeat the production configuration")
+		}
+		shadowLlm = llm
+		if shadowLlmProvider != "" {
+			shadowLlm, err = createLLM(shadowLlmProvider, shadowLlmModel)
+			if err != nil {
+				log.Fatalf("Failed to create the shadow LLM client: %v", err)
+			}
+			shadowLlm = &usageTrackingLLM{Model: shadowLlm, model: shadowLlmModel}
+		}
 	}
 
 	// Initialize Vision LLM
-	visionLlm, err := createVisionLLM()
+	visionLlm, err := createVisionLLM(visionLlmProvider, visionLlmModel)
 	if err != nil {
 		log.Fatalf("Failed to create Vision LLM client: %v", err)
 	}
 
+	// Initialize the second vision LLM of the consensus OCR mode
+	var consensusVisionLlm llms.Model
+	if consensusVisionProvider != "" {
+		consensusVisionLlm, err = createVisionLLM(consensusVisionProvider, consensusVisionModel)
+		if err != nil {
+			log.Fatalf("Failed to create the consensus Vision LLM client: %v", err)
+		}
+	}
+
+	// Initialize the Vision LLM for handwritten documents
+	var handwritingVisionLlm llms.Model
+	if handwritingProvider != "" {
+		handwritingVisionLlm, err = createVisionLLM(handwritingProvider, handwritingModel)
+		if err != nil {
+			log.Fatalf("Failed to create the handwriting Vision LLM client: %v", err)
+		}
+	}
+
 	// Initialize App with dependencies
 	app := &App{
 		Client:    client,
 		Database:  database,
 		LLM:       llm,
 		VisionLLM: visionLlm,
+
+		ConsensusVisionLLM:   consensusVisionLlm,
+		HandwritingVisionLLM: handwritingVisionLlm,
+		ShadowLLM:            shadowLlm,
 	}
 
-	// Start background process for auto-tagging
-	go func() {
-		minBackoffDuration := 10 * time.Second
-		maxBackoffDuration := time.Hour
-		pollingInterval := 10 * time.Second
-
-		backoffDuration := minBackoffDuration
-		for {
-			processedCount, err := func() (int, error) {
-				count := 0
-				if isOcrEnabled() {
-					ocrCount, err := app.processAutoOcrTagDocuments()
-					if err != nil {
-						return 0, fmt.Errorf("error in processAutoOcrTagDocuments: %w", err)
-					}
-					count += ocrCount
-				}
-				autoCount, err := app.processAutoTagDocuments()
-				if err != nil {
-					return 0, fmt.Errorf("error in processAutoTagDocuments: %w", err)
-				}
-				count += autoCount
-				return count, nil
-			}()
+	// Turn off settings the connected paperless-ngx version does not support
+	versionCtx, cancelVersion := context.WithTimeout(context.Background(), 10*time.Second)
+	app.detectCapabilities(versionCtx)
+	cancelVersion()
 
-			if err != nil {
-				log.Errorf("Error in processAutoTagDocuments: %v", err)
-				time.Sleep(backoffDuration)
-				backoffDuration *= 2 // Exponential backoff
-				if backoffDuration > maxBackoffDuration {
-					log.Warnf("Repeated errors in processAutoTagDocuments detected. Setting backoff to %v", maxBackoffDuration)
-					backoffDuration = maxBackoffDuration
-				}
-			} else {
-				backoffDuration = minBackoffDuration
-			}
+	// Log every provider request with latency and token usage, without images and credentials
+	if providerLogFile != "" {
+		providerLog, err := newProviderLog(providerLogFile, int64(providerLogMaxMB)<<20, providerLogRedactText)
+		if err != nil {
+			log.Fatalf("Invalid PROVIDER_LOG_FILE: %v", err)
+		}
+		log.Infof("Logging provider requests to %s", providerLogFile)
+		app.enableProviderLog(providerLog)
+	}
 
-			if processedCount == 0 {
-				time.Sleep(pollingInterval)
+	// Simulate provider failures for testing retry and backoff behavior
+	if faultInjection != "" {
+		config, err := parseFaultConfig(faultInjection)
+		if err != nil {
+			log.Fatalf("Invalid FAULT_INJECTION: %v", err)
+		}
+		seed := time.Now().UnixNano()
+		if rawSeed := os.Getenv("FAULT_INJECTION_SEED"); rawSeed != "" {
+			if seed, err = strconv.ParseInt(rawSeed, 10, 64); err != nil {
+				log.Fatalf("Invalid FAULT_INJECTION_SEED: %v", err)
 			}
 		}
-	}()
+		log.Warnf("Fault injection enabled: %+v", config)
+		app.enableFaultInjection(config, seed)
+	}
+
+	// Record prompts and answers per document for debugging suggestions, including injected faults
+	if llmTraces && app.LLM != nil {
+		app.LLM = &tracingLLM{Model: app.LLM, db: database}
+	}
+
+	// One-shot backfill of existing documents, e.g. "paperless-gpt backfill -missing-correspondent"
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if !isLLMEnabled() {
+			log.Fatal("Backfill needs an LLM, set LLM_PROVIDER")
+		}
+		if err := runBackfillCommand(app, os.Args[2:]); err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
+	}
+
+	// Generate few-shot prompt examples from well-labeled documents, e.g. "paperless-gpt examples -count 5 -tag reviewed"
+	if len(os.Args) > 1 && os.Args[1] == "examples" {
+		if err := runExamplesCommand(app, os.Args[2:]); err != nil {
+			log.Fatalf("Generating prompt examples failed: %v", err)
+		}
+		return
+	}
+
+	// Verify the LLM providers and warm up local models without delaying the startup
+	if startupProviderCheck {
+		go app.logProviderHealth()
+	}
+
+	// Background loops poll paperless for tagged documents and run scheduled tasks
+	if backgroundProcessing {
+		app.startBackgroundTasks()
+	} else {
+		log.Info("ENABLE_BACKGROUND_PROCESSING is false, documents are only processed on request")
+	}
 
 	// Create a Gin router with default middleware (logger and recovery)
 	router := gin.Default()
 
 	// API routes
-	api := router.Group("/api")
+	api := router.Group("/api", redactResponses())
+	if multiTenant {
+		// Users register their own paperless token before they can use the other endpoints
+		tenantAPI := router.Group("/api/tenant", redactResponses(), app.tenantMiddleware(false))
+		{
+			tenantAPI.GET("", app.getTenantHandler)
+			tenantAPI.PUT("/token", app.setTenantTokenHandler)
+			tenantAPI.DELETE("/token", app.deleteTenantTokenHandler)
+		}
+		api.Use(app.tenantMiddleware(true))
+	}
 	{
 		api.GET("/documents", app.documentsHandler)
 		// http://localhost:8080/api/documents/544
 		api.GET("/documents/:id", app.getDocumentHandler())
-		api.POST("/generate-suggestions", app.generateSuggestionsHandler)
+		api.POST("/generate-suggestions", requireLLM(), app.generateSuggestionsHandler)
 		api.PATCH("/update-documents", app.updateDocumentsHandler)
 		api.GET("/filter-tag", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"tag": manualTag})
+			c.JSON(http.StatusOK, gin.H{"tag": settings().ManualTag})
 		})
 		// Get all tags
 		api.GET("/tags", app.getAllTagsHandler)
+		api.POST("/tags", app.createTagHandler)
+		api.GET("/tags/tree", app.getTagTreeHandler)
+		api.POST("/tags/bulk", app.bulkTagsHandler)
+		api.POST("/tags/taxonomy", requireLLM(), app.suggestTagTaxonomyHandler)
+		// Get paperless saved views
+		api.GET("/views", app.getSavedViewsHandler)
 		api.GET("/prompts", getPromptsHandler)
-		api.POST("/prompts", updatePromptsHandler)
+		api.POST("/prompts", requireAdmin(), updatePromptsHandler)
+		api.GET("/prompts/examples", getPromptExamplesHandler)
+		api.POST("/prompts/examples", app.generatePromptExamplesHandler)
 
 		// OCR endpoints
 		api.POST("/documents/:id/ocr", app.submitOCRJobHandler)
+		api.GET("/documents/:id/ocr/pages", app.getOcrPagesHandler)
+		api.GET("/documents/:id/thumbnail", app.getThumbnailHandler)
+		api.PUT("/documents/:id/ocr/pages", app.updateOcrPagesHandler)
 		api.GET("/jobs/ocr/:job_id", app.getJobStatusHandler)
+		api.GET("/jobs/ocr/:job_id/result", app.getJobResultHandler)
+		api.GET("/jobs/ocr/:job_id/artifacts", app.getJobArtifactsHandler)
 		api.GET("/jobs/ocr", app.getAllJobsHandler)
+		api.GET("/jobs/metrics", app.getJobMetricsHandler)
+		api.GET("/queues", app.getQueuesHandler)
+		api.GET("/stats/backlog", app.getBacklogStatsHandler)
+		api.GET("/stats/quality", app.getQualityStatsHandler)
+		api.GET("/shadow/report", app.getShadowReportHandler)
+		api.GET("/failures", app.getFailuresHandler)
+		api.POST("/failures/:document_id/retry", app.retryFailureHandler)
+		api.GET("/background", getBackgroundTasksHandler)
+		api.POST("/background/pause", requireAdmin(), pauseBackgroundTasksHandler)
+		api.POST("/background/resume", requireAdmin(), resumeBackgroundTasksHandler)
 
 		// Endpoint to see if user enabled OCR
 		api.GET("/experimental/ocr", func(c *gin.Context) {
@@ -231,10 +716,61 @@ func main() {
 			c.JSON(http.StatusOK, gin.H{"enabled": enabled})
 		})
 
+		// Structured extraction of repeated entries
+		api.GET("/documents/:id/extractions", app.getExtractionsHandler)
+		api.POST("/documents/:id/extractions", requireLLM(), app.createExtractionHandler)
+		api.GET("/documents/:id/llm-traces", app.getLLMTracesHandler)
+		api.POST("/documents/:id/suggest/:field", requireLLM(), app.suggestFieldHandler)
+		api.POST("/documents/:id/refine", requireLLM(), app.refineSuggestionHandler)
+		api.POST("/documents/:id/classify", requireLLM(), app.classifyDocumentHandler)
+		api.POST("/compare", requireLLM(), app.compareDocumentsHandler)
+		api.GET("/categories", app.getCategoriesHandler)
+		api.POST("/categories", requireAdmin(), app.saveCategoryHandler)
+		api.PUT("/categories/:id", requireAdmin(), app.saveCategoryHandler)
+		api.DELETE("/categories/:id", requireAdmin(), app.deleteCategoryHandler)
+
+		// Backfill of existing documents
+		api.POST("/backfill", requireLLM(), app.startBackfillHandler)
+		api.GET("/backfill", app.getBackfillStatusHandler)
+		api.DELETE("/backfill", app.stopBackfillHandler)
+		api.POST("/backfill/estimate", requireLLM(), app.estimateBackfillHandler)
+		api.GET("/jobs/ocr/estimate", app.estimateOcrHandler)
+
+		// Archive reports
+		api.GET("/reports", app.getReportsHandler)
+		api.GET("/reports/:id", app.getReportHandler)
+		api.POST("/reports", requireLLM(), app.createReportHandler)
+		api.GET("/reports/:id/shares", app.getReportSharesHandler)
+		api.POST("/reports/:id/shares", app.createReportShareHandler)
+		api.DELETE("/reports/:id/shares/:share_id", app.deleteReportShareHandler)
+
+		// Due date reminders
+		api.POST("/due-dates/check", app.checkDueDatesHandler)
+
+		// Test requests against the configured LLM providers
+		api.POST("/providers/test", app.testProvidersHandler)
+		api.GET("/providers/:name/models", getProviderModelsHandler)
+		api.GET("/diagnostics/paperless", app.getPaperlessDiagnosticsHandler)
+		api.GET("/capabilities", getCapabilitiesHandler)
+		api.GET("/version", getVersionHandler)
+		api.POST("/config/reload", requireAdmin(), reloadConfigHandler)
+		api.GET("/usage-statistics", getUsageStatisticsHandler)
+
+		// How often malformed LLM answers had to be re-asked, per model
+		api.GET("/metrics/reasks", getReaskMetricsHandler)
+
 		// Local db actions
 		api.GET("/modifications", app.getModificationHistoryHandler)
 		api.POST("/undo-modification/:id", app.undoModificationHandler)
 
+		// Correspondent approval queue
+		api.GET("/pending-correspondents", app.getPendingCorrespondentsHandler)
+		api.POST("/pending-correspondents/:id/approve", app.approvePendingCorrespondentHandler)
+		api.DELETE("/pending-correspondents/:id", app.rejectPendingCorrespondentHandler)
+
+		// Read-only paperless API requests of the web app, e.g. thumbnails
+		api.GET("/paperless/*path", app.paperlessProxyHandler)
+
 		// Get public Paperless environment (as set in environment variables)
 		api.GET("/paperless-url", func(c *gin.Context) {
 			baseUrl := os.Getenv("PAPERLESS_PUBLIC_URL")
@@ -246,6 +782,9 @@ func main() {
 		})
 	}
 
+	// Read-only report pages for share links, outside of /api as they need no login
+	router.GET("/share/reports/:token", app.sharedReportHandler)
+
 	// Serve embedded web-app files
 	// router.GET("/*filepath", func(c *gin.Context) {
 	// 	filepath := c.Param("filepath")
@@ -278,10 +817,16 @@ func main() {
 	router.GET("/experimental-ocr", func(c *gin.Context) {
 		serveEmbeddedFile(c, "", "index.html")
 	})
+	// pending-correspondents route
+	router.GET("/pending-correspondents", func(c *gin.Context) {
+		serveEmbeddedFile(c, "", "index.html")
+	})
 
 	// Start OCR worker pool
 	numWorkers := 1 // Number of workers to start
 	startWorkerPool(app, numWorkers)
+	startJobCleanup(app.Database, jobRetention)
+	startOcrCacheCleanup(app.Database)
 
 	if listenInterface == "" {
 		listenInterface = ":8080"
@@ -292,6 +837,28 @@ func main() {
 	}
 }
 
+// startBackgroundTasks starts the auto-tagging and OCR tasks, the archive report scheduler and the due date checker
+func (app *App) startBackgroundTasks() {
+	// Poll for documents of the OCR and tagging queues, each with its own interval
+	scheduler.start(app.newBackgroundTasks())
+
+	// Start scheduled archive reports
+	if reportSchedule != "" && !isLLMEnabled() {
+		log.Warn("REPORT_SCHEDULE is ignored in OCR-only mode, reports need an LLM for their summary")
+	} else if reportSchedule != "" {
+		schedule, err := parseCronSchedule(reportSchedule)
+		if err != nil {
+			log.Fatalf("Invalid REPORT_SCHEDULE: %v", err)
+		}
+		startReportScheduler(app, schedule)
+	}
+
+	// Tag documents whose due date custom field is coming up
+	if dueDateCustomField != "" && dueSoonTag != "" {
+		startDueDateChecker(app)
+	}
+}
+
 func printVersion() {
 	cyan := color.New(color.FgCyan).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
@@ -335,62 +902,166 @@ func initLogger() {
 	log.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
 	})
+	log.AddHook(redactionHook{})
+	logger.AddHook(redactionHook{})
 }
 
 func isOcrEnabled() bool {
 	return visionLlmModel != "" && visionLlmProvider != ""
 }
 
-// validateOrDefaultEnvVars ensures all necessary environment variables are set
-func validateOrDefaultEnvVars() {
-	if manualTag == "" {
-		manualTag = "paperless-gpt"
+// isLLMEnabled reports whether an LLM for suggestions is configured; without one paperless-gpt runs in OCR-only mode
+func isLLMEnabled() bool {
+	return llmProvider != ""
+}
+
+// requireLLM answers 501 Not Implemented for endpoints that need an LLM when running in OCR-only mode
+func requireLLM() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isLLMEnabled() {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "No LLM is configured (OCR-only mode), set LLM_PROVIDER to use this endpoint"})
+			return
+		}
+		c.Next()
 	}
-	fmt.Printf("Using %s as manual tag\n", manualTag)
+}
 
-	if autoTag == "" {
-		autoTag = "paperless-gpt-auto"
+// validateLLMEnvVars checks that an LLM or, in OCR-only mode, at least a vision LLM is configured
+func validateLLMEnvVars() error {
+	if llmProvider == "" && visionLlmProvider == "" {
+		return errors.New("Please set the LLM_PROVIDER environment variable, or VISION_LLM_PROVIDER to run in OCR-only mode.")
+	}
+	if llmProvider != "" && llmModel == "" {
+		return errors.New("Please set the LLM_MODEL environment variable.")
 	}
-	fmt.Printf("Using %s as auto tag\n", autoTag)
+	return nil
+}
 
+// validateOrDefaultEnvVars ensures all necessary environment variables are set
+func validateOrDefaultEnvVars() {
 	if manualOcrTag == "" {
 		manualOcrTag = "paperless-gpt-ocr"
 	}
+
+	// Tag names, limits and intervals can change at runtime, see CONFIG_FILE
+	loaded, err := loadSettings(nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	applySettings(loaded)
+	fmt.Printf("Using %s as manual tag\n", loaded.ManualTag)
+	fmt.Printf("Using %s as auto tag\n", loaded.AutoTag)
 	if isOcrEnabled() {
 		fmt.Printf("Using %s as manual OCR tag\n", manualOcrTag)
+		fmt.Printf("Using %s as auto OCR tag\n", loaded.AutoOcrTag)
 	}
-
-	if autoOcrTag == "" {
-		autoOcrTag = "paperless-gpt-ocr-auto"
+	if loaded.TokenLimit > 0 {
+		log.Infof("Using token limit: %d", loaded.TokenLimit)
 	}
-	if isOcrEnabled() {
-		fmt.Printf("Using %s as auto OCR tag\n", autoOcrTag)
+
+	if sandboxMode {
+		fmt.Println("SANDBOX_MODE is enabled, using a built-in fake paperless-ngx API with sample documents")
 	}
 
-	if paperlessBaseURL == "" {
+	if paperlessBaseURL == "" && !sandboxMode {
 		log.Fatal("Please set the PAPERLESS_BASE_URL environment variable.")
 	}
 
-	if paperlessAPIToken == "" {
+	if paperlessAPIToken == "" && !sandboxMode {
 		log.Fatal("Please set the PAPERLESS_API_TOKEN environment variable.")
 	}
 
+	if err := validateLLMEnvVars(); err != nil {
+		log.Fatal(err)
+	}
+
 	if llmProvider == "" {
-		log.Fatal("Please set the LLM_PROVIDER environment variable.")
+		log.Warn("LLM_PROVIDER is not set, running in OCR-only mode: suggestions are disabled and only OCR is processed")
+		if ocrLanguageDetection {
+			log.Warn("OCR_LANGUAGE_DETECTION needs an LLM and is disabled in OCR-only mode")
+			ocrLanguageDetection = false
+		}
 	}
 
-	if visionLlmProvider != "" && visionLlmProvider != "openai" && visionLlmProvider != "ollama" {
-		log.Fatal("Please set the LLM_PROVIDER environment variable to 'openai' or 'ollama'.")
+	if keyFile := os.Getenv("SECRETS_KEY_FILE"); keyFile != "" && secretsKey == "" {
+		key, err := readSecretsKeyFile(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to read SECRETS_KEY_FILE: %v", err)
+		}
+		secretsKey = key
 	}
 
-	if llmModel == "" {
-		log.Fatal("Please set the LLM_MODEL environment variable.")
+	// Keep configured secrets out of logs and API responses
+	for _, secret := range append([]string{paperlessAPIToken, openaiAPIKey, googleaiAPIKey, anthropicAPIKey, secretsKey}, secretsPreviousKeys...) {
+		registerSecret(secret)
 	}
 
-	if (llmProvider == "openai" || visionLlmProvider == "openai") && openaiAPIKey == "" {
+	if multiTenant {
+		if secretsKey == "" {
+			log.Fatal("MULTI_TENANT requires SECRETS_KEY to encrypt the stored paperless tokens.")
+		}
+		if authUserHeader == "" {
+			authUserHeader = "Remote-User"
+		}
+	}
+
+	if leaderElection && redisURL == "" {
+		log.Fatal("LEADER_ELECTION requires REDIS_URL to store the leader lock.")
+	}
+
+	if visionLlmProvider != "" && !slices.Contains(ocrProviderNames, visionLlmProvider) {
+		log.Fatal("Please set the VISION_LLM_PROVIDER environment variable to 'openai', 'ollama', 'googleai' or 'anthropic'.")
+	}
+
+	if consensusVisionProvider != "" {
+		if visionLlmProvider == "" {
+			log.Fatal("OCR_CONSENSUS_PROVIDER requires VISION_LLM_PROVIDER, the consensus mode compares two OCR models.")
+		}
+		if !slices.Contains(ocrProviderNames, consensusVisionProvider) {
+			log.Fatal("Please set the OCR_CONSENSUS_PROVIDER environment variable to 'openai', 'ollama', 'googleai' or 'anthropic'.")
+		}
+		if consensusVisionModel == "" {
+			log.Fatal("Please set the OCR_CONSENSUS_MODEL environment variable.")
+		}
+	}
+
+	if handwritingProvider != "" {
+		if visionLlmProvider == "" {
+			log.Fatal("HANDWRITING_LLM_PROVIDER requires VISION_LLM_PROVIDER to be set, handwritten documents are part of the OCR processing.")
+		}
+		if !slices.Contains(ocrProviderNames, handwritingProvider) {
+			log.Fatal("Please set the HANDWRITING_LLM_PROVIDER environment variable to 'openai', 'ollama', 'googleai' or 'anthropic'.")
+		}
+		if handwritingModel == "" {
+			log.Fatal("Please set the HANDWRITING_LLM_MODEL environment variable.")
+		}
+	}
+
+	if shadowMode && llmProvider == "" {
+		log.Fatal("SHADOW_MODE requires LLM_PROVIDER, the shadow mode compares suggestions.")
+	}
+
+	if shadowLlmProvider != "" {
+		if !slices.Contains(llmProviderNames, shadowLlmProvider) {
+			log.Fatal("Please set the SHADOW_LLM_PROVIDER environment variable to 'openai', 'ollama' or 'anthropic'.")
+		}
+		if shadowLlmModel == "" {
+			log.Fatal("Please set the SHADOW_LLM_MODEL environment variable.")
+		}
+	}
+
+	if (llmProvider == "openai" || visionLlmProvider == "openai" || consensusVisionProvider == "openai" || handwritingProvider == "openai" || shadowLlmProvider == "openai") && openaiAPIKey == "" {
 		log.Fatal("Please set the OPENAI_API_KEY environment variable for OpenAI provider.")
 	}
 
+	if (visionLlmProvider == "googleai" || consensusVisionProvider == "googleai" || handwritingProvider == "googleai") && googleaiAPIKey == "" {
+		log.Fatal("Please set the GOOGLEAI_API_KEY environment variable for the Google AI provider.")
+	}
+
+	if slices.Contains(configuredProviders(), "anthropic") && anthropicAPIKey == "" {
+		log.Fatal("Please set the ANTHROPIC_API_KEY environment variable for the Anthropic provider.")
+	}
+
 	if isOcrEnabled() {
 		rawLimitOcrPages := os.Getenv("OCR_LIMIT_PAGES")
 		if rawLimitOcrPages == "" {
@@ -404,14 +1075,109 @@ func validateOrDefaultEnvVars() {
 		}
 	}
 
-	// Initialize token limit from environment variable
-	if limit := os.Getenv("TOKEN_LIMIT"); limit != "" {
-		if parsed, err := strconv.Atoi(limit); err == nil {
-			if parsed < 0 {
-				log.Fatalf("TOKEN_LIMIT must be non-negative, got: %d", parsed)
+	if rawThreshold := os.Getenv("OCR_CONSENSUS_THRESHOLD"); rawThreshold != "" {
+		parsed, err := strconv.ParseFloat(rawThreshold, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Fatalf("OCR_CONSENSUS_THRESHOLD must be a number between 0 and 1, got: %s", rawThreshold)
+		}
+		ocrConsensusThreshold = parsed
+	}
+
+	if rawConfidence := os.Getenv("HANDWRITING_MIN_CONFIDENCE"); rawConfidence != "" {
+		parsed, err := strconv.ParseFloat(rawConfidence, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Fatalf("HANDWRITING_MIN_CONFIDENCE must be a number between 0 and 1, got: %s", rawConfidence)
+		}
+		handwritingMinConfidence = parsed
+	}
+
+	for name, target := range map[string]*float64{
+		"LLM_COST_PER_1K_TOKENS": &llmCostPer1kTokens,
+		"OCR_COST_PER_PAGE":      &ocrCostPerPage,
+		"ESTIMATE_ABORT_FACTOR":  &estimateAbortFactor,
+		"BLANK_PAGE_VARIANCE":    &blankPageVariance,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil || parsed < 0 {
+				log.Fatalf("%s must be a non-negative number, got: %s", name, raw)
+			}
+			*target = parsed
+		}
+	}
+
+	if titleDedupe != "" && titleDedupe != "counter" && titleDedupe != "date" {
+		log.Fatalf("TITLE_DEDUPE must be empty, \"counter\" or \"date\", got: %s", titleDedupe)
+	}
+
+	if spec := os.Getenv("OCR_METADATA_FIELDS"); spec != "" {
+		parsed, err := parseOcrMetadataFields(spec)
+		if err != nil {
+			log.Fatalf("Invalid OCR_METADATA_FIELDS: %v", err)
+		}
+		ocrMetadataFields = parsed
+	}
+
+	if spec := os.Getenv("CUSTOM_FIELD_MAPPING"); spec != "" {
+		parsed, err := parseCustomFieldMapping(spec)
+		if err != nil {
+			log.Fatalf("Invalid CUSTOM_FIELD_MAPPING: %v", err)
+		}
+		customFieldMapping = parsed
+	}
+
+	if spec := os.Getenv("CONTENT_NORMALIZATION"); spec != "" {
+		parsed, err := parseContentNormalization(spec)
+		if err != nil {
+			log.Fatalf("Invalid CONTENT_NORMALIZATION: %v", err)
+		}
+		contentNormalizationSteps = parsed
+	}
+
+	for name, target := range map[string]*int{
+		"LLM_TRACE_MAX_BYTES":      &llmTraceMaxBytes,
+		"LLM_TRACE_RETENTION_DAYS": &llmTraceRetentionDays,
+		"PROVIDER_LOG_MAX_MB":      &providerLogMaxMB,
+		"DUE_SOON_DAYS":            &dueSoonDays,
+		"THUMBNAIL_CACHE_SIZE":     &thumbnailCacheSize,
+		"PDF_RENDER_MEMORY_MB":     &pdfRenderMemoryMB,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				log.Fatalf("%s must be a non-negative integer, got: %s", name, raw)
+			}
+			*target = parsed
+		}
+	}
+
+	for name, target := range map[string]*int{
+		"OCR_CACHE_MAX_ENTRIES": &ocrCacheMaxEntries,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				log.Fatalf("%s must be a positive integer, got: %s", name, raw)
+			}
+			*target = parsed
+		}
+	}
+
+	for name, target := range map[string]*time.Duration{
+		"PAPERLESS_TIMEOUT":   &paperlessTimeout,
+		"LLM_TIMEOUT":         &llmTimeout,
+		"VISION_LLM_TIMEOUT":  &visionLlmTimeout,
+		"JOB_RETENTION":       &jobRetention,
+		"JOB_STALL_TIMEOUT":   &jobStallTimeout,
+		"OCR_CACHE_TTL":       &ocrCacheTTL,
+		"THUMBNAIL_CACHE_TTL": &thumbnailCacheTTL,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed < 0 {
+				log.Fatalf("%s must be a non-negative duration such as 60s, got: %s", name, raw)
 			}
-			tokenLimit = parsed
-			log.Infof("Using token limit: %d", tokenLimit)
+			*target = parsed
 		}
 	}
 }
@@ -422,88 +1188,207 @@ func documentLogger(documentID int) *logrus.Entry {
 }
 
 // processAutoTagDocuments handles the background auto-tagging of documents
-func (app *App) processAutoTagDocuments() (int, error) {
-	ctx := context.Background()
-
-	documents, err := app.Client.GetDocumentsByTags(ctx, []string{autoTag}, 25)
+func (app *App) processAutoTagDocuments(ctx context.Context) (int, error) {
+	current := settings()
+	documents, err := app.Client.GetDocumentsByTags(ctx, []string{current.AutoTag}, current.TaggingDocumentsPerCycle)
 	if err != nil {
 		return 0, fmt.Errorf("error fetching documents with autoTag: %w", err)
 	}
 
 	if len(documents) == 0 {
-		log.Debugf("No documents with tag %s found", autoTag)
+		log.Debugf("No documents with tag %s found", current.AutoTag)
 		return 0, nil // No documents to process
 	}
 
-	log.Debugf("Found at least %d remaining documents with tag %s", len(documents), autoTag)
+	log.Debugf("Found at least %d remaining documents with tag %s", len(documents), current.AutoTag)
 
+	// Failed documents leave the queue, so they do not block the documents after them
+	processed := 0
+	var errs []error
 	for _, document := range documents {
 		docLogger := documentLogger(document.ID)
+		if wait, quiet := inQuietPeriod(document, time.Now()); quiet {
+			docLogger.Infof("Document was modified recently, postponing auto-tagging for %s", wait.Round(time.Second))
+			continue
+		}
+		if !app.allowAutoChange(ctx) {
+			break
+		}
 		docLogger.Info("Processing document for auto-tagging")
+		app.markStage(ctx, document.ID, []string{current.TaggingInProgressTag}, nil)
 
 		suggestionRequest := GenerateSuggestionsRequest{
 			Documents:              []Document{document},
 			GenerateTitles:         strings.ToLower(autoGenerateTitle) != "false",
 			GenerateTags:           strings.ToLower(autoGenerateTags) != "false",
 			GenerateCorrespondents: strings.ToLower(autoGenerateCorrespondents) != "false",
+			GenerateCustomFields:   strings.ToLower(autoGenerateCustomFields) != "false",
 		}
 
 		suggestions, err := app.generateDocumentSuggestions(ctx, suggestionRequest, docLogger)
 		if err != nil {
-			return 0, fmt.Errorf("error generating suggestions for document %d: %w", document.ID, err)
+			app.markStage(ctx, document.ID, []string{current.ProcessingFailedTag}, []string{current.TaggingInProgressTag, current.AutoTag})
+			app.recordFailure(ctx, document.ID, queueTagging, err)
+			notifyJobFinished(ctx, JobWebhook{Event: jobEventSuggestionsFinished, Source: "auto", Documents: []JobWebhookDocument{documentOutcome(document.ID, err, nil)}})
+			errs = append(errs, fmt.Errorf("error generating suggestions for document %d: %w", document.ID, err))
+			continue
 		}
 
+		app.startShadowRun(ctx, suggestionRequest, suggestions)
+
 		err = app.Client.UpdateDocuments(ctx, suggestions, app.Database, false)
 		if err != nil {
-			return 0, fmt.Errorf("error updating document %d: %w", document.ID, err)
+			app.markStage(ctx, document.ID, []string{current.ProcessingFailedTag}, []string{current.TaggingInProgressTag, current.AutoTag})
+			app.recordFailure(ctx, document.ID, queueTagging, err)
+			notifyJobFinished(ctx, JobWebhook{Event: jobEventSuggestionsFinished, Source: "auto", Documents: []JobWebhookDocument{documentOutcome(document.ID, err, nil)}})
+			errs = append(errs, fmt.Errorf("error updating document %d: %w", document.ID, err))
+			continue
 		}
+		autoChanges.record(time.Now())
 
+		app.markStage(ctx, document.ID, []string{current.TaggingDoneTag}, []string{current.TaggingInProgressTag, current.ProcessingFailedTag})
+		app.clearFailure(ctx, document.ID, queueTagging)
+		outcomes := make([]JobWebhookDocument, 0, len(suggestions))
+		for _, suggestion := range suggestions {
+			outcomes = append(outcomes, documentOutcome(suggestion.ID, nil, suggestionChanges(suggestion)))
+		}
+		notifyJobFinished(ctx, JobWebhook{Event: jobEventSuggestionsFinished, Source: "auto", Applied: true, Documents: outcomes})
 		docLogger.Info("Successfully processed document")
+		processed++
 	}
-	return len(documents), nil
+	return processed, errors.Join(errs...)
 }
 
 // processAutoOcrTagDocuments handles the background auto-tagging of OCR documents
-func (app *App) processAutoOcrTagDocuments() (int, error) {
-	ctx := context.Background()
-
-	documents, err := app.Client.GetDocumentsByTags(ctx, []string{autoOcrTag}, 25)
+func (app *App) processAutoOcrTagDocuments(ctx context.Context) (int, error) {
+	current := settings()
+	documents, err := app.Client.GetDocumentsByTags(ctx, []string{current.AutoOcrTag}, current.OcrDocumentsPerCycle)
 	if err != nil {
 		return 0, fmt.Errorf("error fetching documents with autoOcrTag: %w", err)
 	}
 
 	if len(documents) == 0 {
-		log.Debugf("No documents with tag %s found", autoOcrTag)
+		log.Debugf("No documents with tag %s found", current.AutoOcrTag)
 		return 0, nil // No documents to process
 	}
 
-	log.Debugf("Found at least %d remaining documents with tag %s", len(documents), autoOcrTag)
+	log.Debugf("Found at least %d remaining documents with tag %s", len(documents), current.AutoOcrTag)
 
+	budget := newOcrPageBudget(documents)
+	processed := 0
+	var errs []error
 	for _, document := range documents {
 		docLogger := documentLogger(document.ID)
+		if wait, quiet := inQuietPeriod(document, time.Now()); quiet {
+			docLogger.Infof("Document was modified recently, postponing OCR for %s", wait.Round(time.Second))
+			continue
+		}
+		if !app.allowAutoChange(ctx) {
+			break
+		}
 		docLogger.Info("Processing document for OCR")
+		app.markStage(ctx, document.ID, []string{current.OcrInProgressTag}, nil)
 
-		ocrContent, err := app.ProcessDocumentOCR(ctx, document.ID)
+		ocrStart := time.Now()
+		ocrContent, err := app.ProcessDocumentOCR(ctx, document.ID, OcrJobOptions{})
 		if err != nil {
-			return 0, fmt.Errorf("error processing OCR for document %d: %w", document.ID, err)
+			app.markStage(ctx, document.ID, []string{current.ProcessingFailedTag}, []string{current.OcrInProgressTag, current.AutoOcrTag})
+			app.recordFailure(ctx, document.ID, queueOcr, err)
+			notifyJobFinished(ctx, JobWebhook{Event: jobEventOcrFinished, Source: "auto", Documents: []JobWebhookDocument{documentOutcome(document.ID, err, nil)}})
+			errs = append(errs, fmt.Errorf("error processing OCR for document %d: %w", document.ID, err))
+			continue
 		}
 		docLogger.Debug("OCR processing completed")
 
-		err = app.Client.UpdateDocuments(ctx, []DocumentSuggestion{
+		pages, err := GetOcrPageResults(app.Database, document.ID)
+		if err != nil {
+			docLogger.Warnf("Failed to read page results: %v", err)
+		}
+
+		var languages []string
+		var languageFields []CustomFieldValue
+		if ocrLanguageDetection {
+			languages = documentLanguages(pages)
+			docLogger.Infof("Detected languages: %v", languages)
+			if languageFields, err = app.languageCustomFieldValue(ctx, languages); err != nil {
+				docLogger.Warnf("Failed to set the language custom field: %v", err)
+			}
+		}
+
+		provider, model := app.ocrModelOf(document)
+		metadataFields, err := app.ocrMetadataFieldValues(ctx, document.ID, ocrMetadata{
+			Pages:     len(pages),
+			Provider:  provider,
+			Model:     model,
+			Duration:  time.Since(ocrStart),
+			Languages: languages,
+		})
+		if err != nil {
+			docLogger.Warnf("Failed to set the metadata custom fields: %v", err)
+		}
+
+		noteCtx := withNoteDetail(ctx, ocrNoteDetail(len(pages)))
+
+		err = app.Client.UpdateDocuments(noteCtx, []DocumentSuggestion{
 			{
-				ID:               document.ID,
-				OriginalDocument: document,
-				SuggestedContent: ocrContent,
-				RemoveTags:       []string{autoOcrTag},
+				ID:                    document.ID,
+				OriginalDocument:      document,
+				SuggestedContent:      ocrContent,
+				SuggestedCustomFields: append(languageFields, metadataFields...),
+				RemoveTags:            []string{current.AutoOcrTag},
 			},
 		}, app.Database, false)
 		if err != nil {
-			return 0, fmt.Errorf("error updating document %d after OCR: %w", document.ID, err)
+			app.markStage(ctx, document.ID, []string{current.ProcessingFailedTag}, []string{current.OcrInProgressTag, current.AutoOcrTag})
+			app.recordFailure(ctx, document.ID, queueOcr, err)
+			notifyJobFinished(ctx, JobWebhook{Event: jobEventOcrFinished, Source: "auto", Documents: []JobWebhookDocument{documentOutcome(document.ID, err, nil)}})
+			errs = append(errs, fmt.Errorf("error updating document %d after OCR: %w", document.ID, err))
+			continue
+		}
+		autoChanges.record(time.Now())
+		if tags := languageTags(languages); len(tags) > 0 {
+			// Tags of languages that are no longer detected are removed, so tags and custom field agree
+			if err := app.Client.ModifyDocumentTags(ctx, document.ID, tags, staleLanguageTags(document.Tags, tags)); err != nil {
+				docLogger.Warnf("Failed to update language tags: %v", err)
+			}
 		}
 
+		doneTags := []string{current.OcrDoneTag}
+		if pagesNeedReview(pages) {
+			docLogger.Warn("The OCR models disagreed on some pages, flagging the document for review")
+			doneTags = append(doneTags, current.OcrReviewTag)
+		}
+		app.markStage(ctx, document.ID, doneTags, []string{current.OcrInProgressTag, current.ProcessingFailedTag})
+		app.clearFailure(ctx, document.ID, queueOcr)
+		notifyJobFinished(ctx, JobWebhook{Event: jobEventOcrFinished, Source: "auto", Applied: true, Documents: []JobWebhookDocument{
+			documentOutcome(document.ID, nil, ocrChanges(len(pages), ocrContent)),
+		}})
 		docLogger.Info("Successfully processed document OCR")
+		processed++
+		if err := budget.exceeded(); err != nil {
+			return processed, errors.Join(append(errs, err)...)
+		}
 	}
-	return 1, nil
+	return processed, errors.Join(errs...)
+}
+
+// markStage applies the configured processing status tags of a pipeline stage to a document.
+// Unconfigured (empty) tags are ignored; failures are logged since status tags must not break processing.
+func (app *App) markStage(ctx context.Context, documentID int, addTags, removeTags []string) {
+	addTags = splitAndTrim(strings.Join(addTags, ","))
+	removeTags = splitAndTrim(strings.Join(removeTags, ","))
+	if len(addTags) == 0 && len(removeTags) == 0 {
+		return
+	}
+	if err := app.Client.ModifyDocumentTags(ctx, documentID, addTags, removeTags); err != nil {
+		documentLogger(documentID).Warnf("Failed to update status tags: %v", err)
+	}
+}
+
+// statusTags returns the configured processing status tags
+func statusTags() []string {
+	current := settings()
+	return splitAndTrim(strings.Join([]string{current.OcrInProgressTag, current.OcrDoneTag, current.TaggingInProgressTag, current.TaggingDoneTag, current.ProcessingFailedTag}, ","))
 }
 
 // removeTagFromList removes a specific tag from a list of tags
@@ -517,6 +1402,17 @@ func removeTagFromList(tags []string, tagToRemove string) []string {
 	return filteredTags
 }
 
+// splitAndTrim splits a comma-separated list and drops empty entries
+func splitAndTrim(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getLikelyLanguage determines the likely language of the document content
 func getLikelyLanguage() string {
 	likelyLanguage := os.Getenv("LLM_LANGUAGE")
@@ -582,6 +1478,141 @@ func loadTemplates() {
 		log.Fatalf("Failed to parse correspondent template: %v", err)
 	}
 
+	// Load custom field template
+	customFieldTemplatePath := filepath.Join(promptsDir, "custom_field_prompt.tmpl")
+	customFieldTemplateContent, err := os.ReadFile(customFieldTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", customFieldTemplatePath, err)
+		customFieldTemplateContent = []byte(defaultCustomFieldTemplate)
+		if err := os.WriteFile(customFieldTemplatePath, customFieldTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default custom field template to disk: %v", err)
+		}
+	}
+	customFieldTemplate, err = template.New("custom_field").Funcs(sprig.FuncMap()).Parse(string(customFieldTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse custom field template: %v", err)
+	}
+
+	// Load extraction template
+	extractionTemplatePath := filepath.Join(promptsDir, "extraction_prompt.tmpl")
+	extractionTemplateContent, err := os.ReadFile(extractionTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", extractionTemplatePath, err)
+		extractionTemplateContent = []byte(defaultExtractionTemplate)
+		if err := os.WriteFile(extractionTemplatePath, extractionTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default extraction template to disk: %v", err)
+		}
+	}
+	extractionTemplate, err = template.New("extraction").Funcs(sprig.FuncMap()).Parse(string(extractionTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse extraction template: %v", err)
+	}
+
+	// Load report template
+	reportTemplatePath := filepath.Join(promptsDir, "report_prompt.tmpl")
+	reportTemplateContent, err := os.ReadFile(reportTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", reportTemplatePath, err)
+		reportTemplateContent = []byte(defaultReportTemplate)
+		if err := os.WriteFile(reportTemplatePath, reportTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default report template to disk: %v", err)
+		}
+	}
+	reportTemplate, err = template.New("report").Funcs(sprig.FuncMap()).Parse(string(reportTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse report template: %v", err)
+	}
+
+	// Load created date template
+	createdDateTemplatePath := filepath.Join(promptsDir, "created_date_prompt.tmpl")
+	createdDateTemplateContent, err := os.ReadFile(createdDateTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", createdDateTemplatePath, err)
+		createdDateTemplateContent = []byte(defaultCreatedDateTemplate)
+		if err := os.WriteFile(createdDateTemplatePath, createdDateTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default created date template to disk: %v", err)
+		}
+	}
+	createdDateTemplate, err = template.New("created_date").Funcs(sprig.FuncMap()).Parse(string(createdDateTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse created date template: %v", err)
+	}
+
+	// Load summary template
+	summaryTemplatePath := filepath.Join(promptsDir, "summary_prompt.tmpl")
+	summaryTemplateContent, err := os.ReadFile(summaryTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", summaryTemplatePath, err)
+		summaryTemplateContent = []byte(defaultSummaryTemplate)
+		if err := os.WriteFile(summaryTemplatePath, summaryTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default summary template to disk: %v", err)
+		}
+	}
+	summaryTemplate, err = template.New("summary").Funcs(sprig.FuncMap()).Parse(string(summaryTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse summary template: %v", err)
+	}
+
+	// Load classification template
+	classificationTemplatePath := filepath.Join(promptsDir, "classification_prompt.tmpl")
+	classificationTemplateContent, err := os.ReadFile(classificationTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", classificationTemplatePath, err)
+		classificationTemplateContent = []byte(defaultClassificationTemplate)
+		if err := os.WriteFile(classificationTemplatePath, classificationTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default classification template to disk: %v", err)
+		}
+	}
+	categoryTemplate, err = template.New("classification").Funcs(sprig.FuncMap()).Parse(string(classificationTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse classification template: %v", err)
+	}
+
+	// Load due date template
+	dueDateTemplatePath := filepath.Join(promptsDir, "due_date_prompt.tmpl")
+	dueDateTemplateContent, err := os.ReadFile(dueDateTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", dueDateTemplatePath, err)
+		dueDateTemplateContent = []byte(defaultDueDateTemplate)
+		if err := os.WriteFile(dueDateTemplatePath, dueDateTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default due date template to disk: %v", err)
+		}
+	}
+	dueDateTemplate, err = template.New("due_date").Funcs(sprig.FuncMap()).Parse(string(dueDateTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse due date template: %v", err)
+	}
+
+	// Load batched title template
+	batchTitleTemplatePath := filepath.Join(promptsDir, "batch_title_prompt.tmpl")
+	batchTitleTemplateContent, err := os.ReadFile(batchTitleTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", batchTitleTemplatePath, err)
+		batchTitleTemplateContent = []byte(defaultBatchTitleTemplate)
+		if err := os.WriteFile(batchTitleTemplatePath, batchTitleTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default batched title template to disk: %v", err)
+		}
+	}
+	batchTitleTemplate, err = template.New("batch_title").Funcs(sprig.FuncMap()).Parse(string(batchTitleTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse batched title template: %v", err)
+	}
+
+	// Load batched tag template
+	batchTagTemplatePath := filepath.Join(promptsDir, "batch_tag_prompt.tmpl")
+	batchTagTemplateContent, err := os.ReadFile(batchTagTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", batchTagTemplatePath, err)
+		batchTagTemplateContent = []byte(defaultBatchTagTemplate)
+		if err := os.WriteFile(batchTagTemplatePath, batchTagTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default batched tag template to disk: %v", err)
+		}
+	}
+	batchTagTemplate, err = template.New("batch_tag").Funcs(sprig.FuncMap()).Parse(string(batchTagTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse batched tag template: %v", err)
+	}
+
 	// Load OCR template
 	ocrTemplatePath := filepath.Join(promptsDir, "ocr_prompt.tmpl")
 	ocrTemplateContent, err := os.ReadFile(ocrTemplatePath)
@@ -596,52 +1627,244 @@ func loadTemplates() {
 	if err != nil {
 		log.Fatalf("Failed to parse OCR template: %v", err)
 	}
+
+	// Load handwriting OCR template
+	handwritingTemplatePath := filepath.Join(promptsDir, "handwriting_ocr_prompt.tmpl")
+	handwritingTemplateContent, err := os.ReadFile(handwritingTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", handwritingTemplatePath, err)
+		handwritingTemplateContent = []byte(defaultHandwritingOcrPrompt)
+		if err := os.WriteFile(handwritingTemplatePath, handwritingTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default handwriting OCR template to disk: %v", err)
+		}
+	}
+	handwritingTemplate, err = template.New("handwriting_ocr").Funcs(sprig.FuncMap()).Parse(string(handwritingTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse handwriting OCR template: %v", err)
+	}
+
+	// Load translation template
+	translationTemplatePath := filepath.Join(promptsDir, "translation_prompt.tmpl")
+	translationTemplateContent, err := os.ReadFile(translationTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", translationTemplatePath, err)
+		translationTemplateContent = []byte(defaultTranslationTemplate)
+		if err := os.WriteFile(translationTemplatePath, translationTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default translation template to disk: %v", err)
+		}
+	}
+	translationTemplate, err = template.New("translation").Funcs(sprig.FuncMap()).Parse(string(translationTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse translation template: %v", err)
+	}
+
+	// Load tag taxonomy template
+	taxonomyTemplatePath := filepath.Join(promptsDir, "taxonomy_prompt.tmpl")
+	taxonomyTemplateContent, err := os.ReadFile(taxonomyTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", taxonomyTemplatePath, err)
+		taxonomyTemplateContent = []byte(defaultTaxonomyTemplate)
+		if err := os.WriteFile(taxonomyTemplatePath, taxonomyTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default taxonomy template to disk: %v", err)
+		}
+	}
+	taxonomyTemplate, err = template.New("taxonomy").Funcs(sprig.FuncMap()).Parse(string(taxonomyTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse taxonomy template: %v", err)
+	}
+
+	// Load comparison template
+	comparisonTemplatePath := filepath.Join(promptsDir, "comparison_prompt.tmpl")
+	comparisonTemplateContent, err := os.ReadFile(comparisonTemplatePath)
+	if err != nil {
+		log.Errorf("Could not read %s, using default template: %v", comparisonTemplatePath, err)
+		comparisonTemplateContent = []byte(defaultComparisonTemplate)
+		if err := os.WriteFile(comparisonTemplatePath, comparisonTemplateContent, os.ModePerm); err != nil {
+			log.Fatalf("Failed to write default comparison template to disk: %v", err)
+		}
+	}
+	comparisonTemplate, err = template.New("comparison").Funcs(sprig.FuncMap()).Parse(string(comparisonTemplateContent))
+	if err != nil {
+		log.Fatalf("Failed to parse comparison template: %v", err)
+	}
+
+	// Load document type specific overrides
+	promptOverrides, err = loadPromptOverrides(filepath.Join(promptsDir, "overrides"))
+	if err != nil {
+		log.Fatalf("Failed to load prompt overrides: %v", err)
+	}
+
+	// Load the candidate prompts of the shadow mode
+	shadowPrompts, err = loadShadowPrompts(filepath.Join(promptsDir, "shadow"))
+	if err != nil {
+		log.Fatalf("Failed to load shadow prompts: %v", err)
+	}
+
+	// Load few-shot examples generated from existing documents
+	promptExamples, err = loadAllPromptExamples(promptExamplesDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt examples: %v", err)
+	}
 }
 
+// loadPromptOverrides loads the templates in <dir>/<document type>/*.tmpl.
+// A missing directory means there are no overrides.
+func loadPromptOverrides(dir string) (map[string]map[string]*template.Template, error) {
+	overrides := map[string]map[string]*template.Template{}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return overrides, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, entry.Name(), "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			tmpl, err := template.New(filepath.Base(path)).Funcs(sprig.FuncMap()).Parse(string(content))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			documentType := strings.ToLower(entry.Name())
+			if overrides[documentType] == nil {
+				overrides[documentType] = map[string]*template.Template{}
+			}
+			overrides[documentType][filepath.Base(path)] = tmpl
+			log.Infof("Using prompt override %s for document type %s", filepath.Base(path), entry.Name())
+		}
+	}
+
+	return overrides, nil
+}
+
+// promptTemplate returns the shadow prompt of the given template file in shadow runs, the override for a
+// document type, or the fallback. The caller must hold templateMutex.
+func promptTemplate(ctx context.Context, fallback *template.Template, file string, documentType string) *template.Template {
+	if tmpl, ok := shadowPrompts[file]; ok && isShadowRun(ctx) {
+		return tmpl
+	}
+	if tmpl, ok := promptOverrides[strings.ToLower(documentType)][file]; ok && documentType != "" {
+		return tmpl
+	}
+	return fallback
+}
+
+// llmProviderNames are the providers createLLM can create a model for
+var llmProviderNames = []string{"openai", "ollama", "anthropic"}
+
 // createLLM creates the appropriate LLM client based on the provider
-func createLLM() (llms.Model, error) {
-	switch strings.ToLower(llmProvider) {
+func createLLM(provider string, model string) (llms.Model, error) {
+	return withProviderErrors(createProviderLLM(provider, model))
+}
+
+// createProviderLLM creates the client of an LLM provider
+func createProviderLLM(provider string, model string) (llms.Model, error) {
+	httpClient, err := newHTTPClient(llmTimeout, os.Getenv("LLM_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(provider) {
 	case "openai":
 		if openaiAPIKey == "" {
 			return nil, fmt.Errorf("OpenAI API key is not set")
 		}
 		return openai.New(
-			openai.WithModel(llmModel),
+			openai.WithModel(model),
 			openai.WithToken(openaiAPIKey),
+			openai.WithHTTPClient(httpClient),
 		)
 	case "ollama":
-		host := os.Getenv("OLLAMA_HOST")
-		if host == "" {
-			host = "http://127.0.0.1:11434"
+		host := ollamaHost()
+		if ollamaAutoPull {
+			if err := ensureOllamaModel(context.Background(), httpClient, host, model); err != nil {
+				return nil, err
+			}
 		}
 		return ollama.New(
-			ollama.WithModel(llmModel),
+			ollama.WithModel(model),
 			ollama.WithServerURL(host),
+			ollama.WithHTTPClient(httpClient),
+		)
+	case "anthropic":
+		if anthropicAPIKey == "" {
+			return nil, fmt.Errorf("Anthropic API key is not set")
+		}
+		return anthropic.New(
+			anthropic.WithModel(model),
+			anthropic.WithToken(anthropicAPIKey),
+			anthropic.WithBaseURL(anthropicBaseURL()),
+			anthropic.WithHTTPClient(withAnthropicLimits(httpClient)),
 		)
 	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", llmProvider)
+		return nil, fmt.Errorf("unsupported LLM provider: %s", provider)
 	}
 }
 
-func createVisionLLM() (llms.Model, error) {
-	switch strings.ToLower(visionLlmProvider) {
+func createVisionLLM(provider string, model string) (llms.Model, error) {
+	return newVisionLLM(provider, model, ollamaAutoPull)
+}
+
+// newVisionLLM creates a vision model, pulling Ollama models that are not present if pull is set
+func newVisionLLM(provider string, model string, pull bool) (llms.Model, error) {
+	return withProviderErrors(newProviderVisionLLM(provider, model, pull))
+}
+
+// newProviderVisionLLM creates the client of a vision LLM provider, or nil if the provider is not set
+func newProviderVisionLLM(provider string, model string, pull bool) (llms.Model, error) {
+	httpClient, err := newHTTPClient(visionLlmTimeout, os.Getenv("VISION_LLM_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(provider) {
 	case "openai":
 		if openaiAPIKey == "" {
 			return nil, fmt.Errorf("OpenAI API key is not set")
 		}
 		return openai.New(
-			openai.WithModel(visionLlmModel),
+			openai.WithModel(model),
 			openai.WithToken(openaiAPIKey),
+			openai.WithHTTPClient(httpClient),
 		)
 	case "ollama":
-		host := os.Getenv("OLLAMA_HOST")
-		if host == "" {
-			host = "http://127.0.0.1:11434"
+		host := ollamaHost()
+		if pull {
+			if err := ensureOllamaModel(context.Background(), httpClient, host, model); err != nil {
+				return nil, err
+			}
 		}
 		return ollama.New(
-			ollama.WithModel(visionLlmModel),
+			ollama.WithModel(model),
 			ollama.WithServerURL(host),
+			ollama.WithHTTPClient(httpClient),
 		)
+	case "googleai":
+		if googleaiAPIKey == "" {
+			return nil, fmt.Errorf("Google AI API key is not set")
+		}
+		// The Gemini client brings its own gRPC transport, a custom HTTP client would drop the API key.
+		// VISION_LLM_TIMEOUT and VISION_LLM_PROXY do not apply, HTTPS_PROXY does.
+		return googleai.New(context.Background(),
+			googleai.WithAPIKey(googleaiAPIKey),
+			googleai.WithDefaultModel(model),
+		)
+	case "anthropic":
+		if anthropicAPIKey == "" {
+			return nil, fmt.Errorf("Anthropic API key is not set")
+		}
+		return &anthropicVisionLLM{model: model, token: anthropicAPIKey, baseURL: anthropicBaseURL(), httpClient: withAnthropicLimits(httpClient)}, nil
 	default:
 		log.Infoln("Vision LLM not enabled")
 		return nil, nil
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackgroundTasks(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalLLM, originalVisionProvider, originalVisionModel := llmProvider, visionLlmProvider, visionLlmModel
+	defer func() {
+		llmProvider, visionLlmProvider, visionLlmModel = originalLLM, originalVisionProvider, originalVisionModel
+	}()
+	llmProvider, visionLlmProvider, visionLlmModel = "ollama", "ollama", "minicpm-v"
+	setTestSettings(t, func(s *runtimeSettings) {
+		s.AutoTag, s.AutoOcrTag, s.OcrDocumentsPerCycle = "paperless-gpt-auto", "paperless-gpt-ocr-auto", 3
+	})
+
+	var queried []string
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		tag := r.URL.Query().Get("tags__name__iexact")
+		if r.URL.Query().Get("page_size") == "1" {
+			// Backlog samples count the documents of the queues
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"count": 0, "results": []}`))
+			return
+		}
+		queried = append(queried, tag)
+		if tag == settings().AutoOcrTag {
+			assert.Equal(t, "3", r.URL.Query().Get("page_size"))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": []}`))
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	app := &App{Client: env.client, Database: env.db}
+	tasks := app.newBackgroundTasks()
+	require.Len(t, tasks, 2)
+	ocrTask, tagTask := tasks[0], tasks[1]
+	s := &taskScheduler{tasks: tasks}
+	now := time.Now()
+
+	// A failing OCR task backs off without holding up the tagging task
+	assert.Equal(t, minTaskBackoff, s.runCycle(ocrTask, now))
+	assert.Equal(t, 2*minTaskBackoff, s.runCycle(ocrTask, now), "the backoff doubles")
+	assert.Contains(t, ocrTask.status().LastError, "error fetching documents")
+	assert.Equal(t, settings().AutoTagInterval, s.runCycle(tagTask, now))
+	assert.Equal(t, []string{"paperless-gpt-ocr-auto", "paperless-gpt-ocr-auto", "paperless-gpt-auto"}, queried)
+
+	// Waking the tasks resets the backoff
+	ocrTask.wake()
+	ocrTask.wait(time.Hour)
+	assert.Equal(t, minTaskBackoff, s.runCycle(ocrTask, now))
+}
+
+func TestValidateLLMEnvVars(t *testing.T) {
+	originalLLM, originalModel, originalVision := llmProvider, llmModel, visionLlmProvider
+	defer func() { llmProvider, llmModel, visionLlmProvider = originalLLM, originalModel, originalVision }()
+
+	// OCR-only mode needs neither LLM_PROVIDER nor LLM_MODEL
+	llmProvider, llmModel, visionLlmProvider = "", "", "openai"
+	assert.NoError(t, validateLLMEnvVars())
+
+	llmProvider, llmModel, visionLlmProvider = "openai", "", "openai"
+	assert.ErrorContains(t, validateLLMEnvVars(), "LLM_MODEL")
+
+	llmProvider, llmModel, visionLlmProvider = "openai", "gpt-4o", ""
+	assert.NoError(t, validateLLMEnvVars())
+
+	llmProvider, llmModel, visionLlmProvider = "", "", ""
+	assert.ErrorContains(t, validateLLMEnvVars(), "LLM_PROVIDER")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttTimeout bounds connecting to the broker and publishing a message
+const mqttTimeout = 10 * time.Second
+
+// mqttKeepAlive is the keep alive announced to the broker
+const mqttKeepAlive = 60 * time.Second
+
+// errMQTTNotConnected is returned for messages published while the client (re)connects to the broker
+var errMQTTNotConnected = errors.New("not connected to the MQTT broker")
+
+// mqttMessage is a message published with QoS 0
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+// mqttClient publishes with QoS 0 through the Paho client, which connects and reconnects in the background.
+// Messages published while there is no connection are dropped, so a broker outage never blocks processing.
+type mqttClient struct {
+	options *mqtt.ClientOptions
+	client  mqtt.Client
+
+	birth []mqttMessage // Published after every connect, e.g. the online status and discovery configs
+}
+
+// newMQTTClient creates a client from a URL of the form mqtt://[user:password@]host[:port] (mqtts:// for TLS).
+// It connects on start.
+func newMQTTClient(rawURL string, clientID string) (*mqttClient, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT URL: %w", err)
+	}
+	if parsed.Scheme != "mqtt" && parsed.Scheme != "mqtts" {
+		return nil, fmt.Errorf("invalid MQTT URL: scheme must be mqtt or mqtts, got %q", parsed.Scheme)
+	}
+
+	scheme, port := "tcp", "1883"
+	if parsed.Scheme == "mqtts" {
+		scheme, port = "ssl", "8883"
+	}
+	addr := parsed.Host
+	if parsed.Port() == "" {
+		addr = net.JoinHostPort(parsed.Hostname(), port)
+	}
+
+	c := &mqttClient{}
+	c.options = mqtt.NewClientOptions().
+		AddBroker(scheme + "://" + addr).
+		SetClientID(clientID).
+		SetCleanSession(true).
+		SetKeepAlive(mqttKeepAlive).
+		SetConnectTimeout(mqttTimeout).
+		SetWriteTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Warnf("Lost connection to MQTT broker: %v", err)
+		})
+	if parsed.Scheme == "mqtts" {
+		c.options.SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	if parsed.User != nil {
+		c.options.SetUsername(parsed.User.Username())
+		password, _ := parsed.User.Password()
+		c.options.SetPassword(password)
+		registerSecret(password)
+	}
+	return c, nil
+}
+
+// setWill sets the message the broker publishes when the connection is lost. It must be called before start.
+func (c *mqttClient) setWill(message mqttMessage) {
+	c.options.SetBinaryWill(message.topic, message.payload, 0, message.retain)
+}
+
+// start connects to the broker in the background, retrying until it is reachable
+func (c *mqttClient) start() {
+	c.client = mqtt.NewClient(c.options)
+	c.client.Connect()
+}
+
+// onConnect publishes the birth messages after every connect
+func (c *mqttClient) onConnect(client mqtt.Client) {
+	log.Infof("Connected to MQTT broker at %s", c.options.Servers[0].Host)
+	for _, birth := range c.birth {
+		client.Publish(birth.topic, 0, birth.retain, birth.payload)
+	}
+}
+
+// Publish sends a message without waiting for a connection
+func (c *mqttClient) Publish(ctx context.Context, message mqttMessage) error {
+	if c.client == nil || !c.client.IsConnectionOpen() {
+		return errMQTTNotConnected
+	}
+	token := c.client.Publish(message.topic, 0, message.retain, message.payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("error publishing to MQTT: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error publishing to MQTT: %w", ctx.Err())
+	}
+}
+
+// Close disconnects cleanly, so the broker does not publish the will
+func (c *mqttClient) Close() {
+	if c.client != nil {
+		c.client.Disconnect(uint(time.Second / time.Millisecond))
+	}
+}
+
+// mqttPublisher publishes processing events and backlogs below a topic prefix for home automation
+type mqttPublisher struct {
+	client *mqttClient
+	prefix string
+}
+
+// mqttStatus is set up from MQTT_URL, nil if MQTT is not configured
+var mqttStatus *mqttPublisher
+
+// newMQTTPublisher configures the client with the online status as birth and will message, the version and,
+// with a discovery prefix, the Home Assistant discovery configs of the backlog and event sensors, and connects
+func newMQTTPublisher(client *mqttClient, prefix string, discoveryPrefix string) *mqttPublisher {
+	publisher := &mqttPublisher{client: client, prefix: prefix}
+	statusTopic := prefix + "/status"
+	versionPayload, _ := json.Marshal(map[string]string{"version": version})
+	client.setWill(mqttMessage{topic: statusTopic, payload: []byte("offline"), retain: true})
+	client.birth = []mqttMessage{
+		{topic: statusTopic, payload: []byte("online"), retain: true},
+		{topic: prefix + "/version", payload: versionPayload, retain: true},
+	}
+	if discoveryPrefix != "" {
+		client.birth = append(client.birth, homeAssistantDiscovery(prefix, discoveryPrefix)...)
+	}
+	client.start()
+	return publisher
+}
+
+// homeAssistantDiscovery returns the retained configs that let Home Assistant create the sensors
+func homeAssistantDiscovery(prefix string, discoveryPrefix string) []mqttMessage {
+	device := map[string]interface{}{"identifiers": []string{prefix}, "name": "paperless-gpt", "sw_version": version}
+	sensors := []map[string]interface{}{
+		{
+			"name":                "OCR backlog",
+			"object_id":           "ocr_backlog",
+			"state_topic":         prefix + "/backlog",
+			"value_template":      "{{ value_json.ocr }}",
+			"unit_of_measurement": "documents",
+			"state_class":         "measurement",
+		},
+		{
+			"name":                "Tagging backlog",
+			"object_id":           "tagging_backlog",
+			"state_topic":         prefix + "/backlog",
+			"value_template":      "{{ value_json.tagging }}",
+			"unit_of_measurement": "documents",
+			"state_class":         "measurement",
+		},
+		{
+			"name":                  "Last event",
+			"object_id":             "last_event",
+			"state_topic":           prefix + "/events",
+			"value_template":        "{{ value_json.event }}",
+			"json_attributes_topic": prefix + "/events",
+		},
+	}
+
+	var messages []mqttMessage
+	for _, sensor := range sensors {
+		uniqueID := fmt.Sprintf("%s_%s", prefix, sensor["object_id"])
+		sensor["unique_id"] = uniqueID
+		sensor["availability_topic"] = prefix + "/status"
+		sensor["device"] = device
+		payload, _ := json.Marshal(sensor)
+		messages = append(messages, mqttMessage{
+			topic:   fmt.Sprintf("%s/sensor/%s/config", discoveryPrefix, uniqueID),
+			payload: payload,
+			retain:  true,
+		})
+	}
+	return messages
+}
+
+// publishJSON publishes a payload below the prefix. Failures are only logged since MQTT is informational.
+func (publisher *mqttPublisher) publishJSON(ctx context.Context, subtopic string, payload interface{}, retain bool) {
+	if publisher == nil {
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Warnf("Failed to encode MQTT message: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, mqttTimeout)
+	defer cancel()
+	if err := publisher.client.Publish(ctx, mqttMessage{topic: publisher.prefix + "/" + subtopic, payload: body, retain: retain}); err != nil {
+		log.Warnf("Failed to publish to MQTT: %v", err)
+	}
+}
+
+// publishBacklog publishes a backlog sample, retained so dashboards show it right away. In multi-tenant
+// mode every user has an own topic.
+func (publisher *mqttPublisher) publishBacklog(ctx context.Context, sample BacklogSample) {
+	subtopic := "backlog"
+	if sample.Username != "" {
+		subtopic += "/" + sample.Username
+	}
+	publisher.publishJSON(ctx, subtopic, map[string]*int{"ocr": sample.OcrBacklog, "tagging": sample.TaggingBacklog}, true)
+}
+
+// mqttClientID returns the client ID announced to the broker, stable across restarts of the same host
+func mqttClientID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "paperless-gpt"
+	}
+	return "paperless-gpt-" + hostname
+}
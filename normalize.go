@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// contentNormalization selects the cleanup steps applied to document content before it is put into a prompt.
+// It is parsed from CONTENT_NORMALIZATION, e.g. "whitespace,headers" or "all".
+type contentNormalization struct {
+	Whitespace bool // Collapse repeated spaces and blank lines
+	Headers    bool // Strip header and footer lines repeated on every page
+	Garbage    bool // Remove lines that consist mostly of OCR noise
+}
+
+// contentNormalizationSteps will be read from CONTENT_NORMALIZATION
+var contentNormalizationSteps contentNormalization
+
+var (
+	repeatedSpaces   = regexp.MustCompile(`[ \t\x{00A0}]+`)
+	repeatedNewlines = regexp.MustCompile(`\n{3,}`)
+	digits           = regexp.MustCompile(`\d+`)
+
+	// pageNumberLine matches lines holding only a page number, e.g. "3", "- 3 -", "Page 3 of 5" or "Seite 3/5"
+	pageNumberLine = regexp.MustCompile(`(?i)^[\s\-–—|(\[]*((page|seite|pagina|p\.)\s*)?\d{1,3}(\s*(of|von|de|/)\s*\d{1,3})?[\s\-–—|)\]]*$`)
+)
+
+const (
+	// headerFooterLines is the number of lines at the top and bottom of a page checked for repeated headers and footers
+	headerFooterLines = 3
+	// minGarbageAlphanumericRatio is the share of letters and digits below which a line counts as OCR noise
+	minGarbageAlphanumericRatio = 0.4
+)
+
+// parseContentNormalization parses a comma-separated list of normalization steps
+func parseContentNormalization(spec string) (contentNormalization, error) {
+	var normalization contentNormalization
+	for _, step := range splitAndTrim(strings.ToLower(spec)) {
+		switch step {
+		case "whitespace":
+			normalization.Whitespace = true
+		case "headers":
+			normalization.Headers = true
+		case "garbage":
+			normalization.Garbage = true
+		case "all":
+			normalization = contentNormalization{Whitespace: true, Headers: true, Garbage: true}
+		default:
+			return normalization, fmt.Errorf("unknown normalization step %q", step)
+		}
+	}
+	return normalization, nil
+}
+
+// normalizeContent cleans document content according to CONTENT_NORMALIZATION
+func normalizeContent(content string) string {
+	normalization := contentNormalizationSteps
+	if normalization.Headers {
+		content = stripRepeatedHeaders(content)
+	}
+	if normalization.Garbage {
+		content = removeGarbageLines(content)
+	}
+	if normalization.Whitespace {
+		content = collapseWhitespace(content)
+	}
+	return content
+}
+
+// collapseWhitespace collapses runs of spaces to one and more than one blank line to a single blank line
+func collapseWhitespace(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(repeatedSpaces.ReplaceAllString(line, " "))
+	}
+	content = strings.Join(lines, "\n")
+	return strings.TrimSpace(repeatedNewlines.ReplaceAllString(content, "\n\n"))
+}
+
+// stripRepeatedHeaders removes lines near the top or bottom of a page that occur on every page, such as
+// letterheads and "Page 1 of 3" footers. Pages are separated by form feeds as in the content of paperless-ngx;
+// content without page breaks is returned unchanged.
+func stripRepeatedHeaders(content string) string {
+	pages := strings.Split(content, "\f")
+	if len(pages) < 2 {
+		return content
+	}
+	return strings.Join(stripPageHeaders(pages, false), "\f")
+}
+
+// stripPageHeaders removes the lines near the top or bottom of the pages that occur on every page, and with
+// pageNumbers also page numbers on the first or last line of a page. A single page is returned unchanged.
+func stripPageHeaders(pages []string, pageNumbers bool) []string {
+	if len(pages) < 2 {
+		return pages
+	}
+
+	// Count on how many pages each line appears near the edge; numbers are ignored so page numbers match
+	pageLines := make([][]string, len(pages))
+	pageCounts := map[string]int{}
+	for i, page := range pages {
+		pageLines[i] = strings.Split(page, "\n")
+		seen := map[string]bool{}
+		for _, index := range edgeLineIndexes(pageLines[i]) {
+			key := headerKey(pageLines[i][index])
+			if !seen[key] {
+				seen[key] = true
+				pageCounts[key]++
+			}
+		}
+	}
+
+	stripped := make([]string, len(pages))
+	for i, lines := range pageLines {
+		edges := edgeLineIndexes(lines)
+		for position, index := range edges {
+			outermost := position == 0 || position == len(edges)-1
+			if pageCounts[headerKey(lines[index])] == len(pages) || (pageNumbers && outermost && pageNumberLine.MatchString(lines[index])) {
+				lines[index] = ""
+			}
+		}
+		stripped[i] = strings.Join(lines, "\n")
+	}
+	return stripped
+}
+
+// edgeLineIndexes returns the indexes of the first and last non-empty lines of a page
+func edgeLineIndexes(lines []string) []int {
+	indexes := []int{}
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) <= 2*headerFooterLines {
+		return indexes
+	}
+	return append(indexes[:headerFooterLines:headerFooterLines], indexes[len(indexes)-headerFooterLines:]...)
+}
+
+// headerKey identifies a line independent of numbers and spacing
+func headerKey(line string) string {
+	return strings.ToLower(strings.Join(strings.Fields(digits.ReplaceAllString(line, "#")), " "))
+}
+
+// removeGarbageLines drops lines made up mostly of symbols, as produced by OCR of stamps, images or scan borders
+func removeGarbageLines(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !isGarbageLine(line) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// isGarbageLine reports whether a line contains too few letters and digits to carry information
+func isGarbageLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len([]rune(trimmed)) < 3 {
+		return false
+	}
+
+	alphanumeric, total := 0, 0
+	for _, r := range trimmed {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumeric++
+		}
+	}
+	return float64(alphanumeric)/float64(total) < minGarbageAlphanumericRatio
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContentNormalization(t *testing.T) {
+	normalization, err := parseContentNormalization("whitespace, Garbage")
+	require.NoError(t, err)
+	assert.Equal(t, contentNormalization{Whitespace: true, Garbage: true}, normalization)
+
+	normalization, err = parseContentNormalization("all")
+	require.NoError(t, err)
+	assert.Equal(t, contentNormalization{Whitespace: true, Headers: true, Garbage: true}, normalization)
+
+	_, err = parseContentNormalization("spelling")
+	assert.Error(t, err)
+}
+
+func TestNormalizeContent(t *testing.T) {
+	original := contentNormalizationSteps
+	defer func() { contentNormalizationSteps = original }()
+
+	content := "ACME GmbH, Main Street 1\n" +
+		"Invoice   no.  42\n\n\n\n" +
+		"Total:\t 9.99 EUR\n" +
+		"~~~ .,; ||| ~~~\n" +
+		"Page 1 of 2\f" +
+		"ACME GmbH, Main Street 1\n" +
+		"Terms: payable within 14 days\n" +
+		"Page 2 of 2"
+
+	contentNormalizationSteps = contentNormalization{}
+	assert.Equal(t, content, normalizeContent(content))
+
+	contentNormalizationSteps = contentNormalization{Whitespace: true, Headers: true, Garbage: true}
+	assert.Equal(t, "Invoice no. 42\n\nTotal: 9.99 EUR\n\nTerms: payable within 14 days", normalizeContent(content))
+}
+
+func TestStripRepeatedHeaders_SinglePage(t *testing.T) {
+	content := "Page 1\nPage 1\nBody"
+	assert.Equal(t, content, stripRepeatedHeaders(content))
+}
+
+func TestIsGarbageLine(t *testing.T) {
+	assert.True(t, isGarbageLine("~~~ .,; ||| ~~~"))
+	assert.True(t, isGarbageLine("----------"))
+	assert.False(t, isGarbageLine("12.03.2024"))
+	assert.False(t, isGarbageLine("Total: 9.99 EUR"))
+	assert.False(t, isGarbageLine("--"))
+}
+
+func TestStripPageHeaders(t *testing.T) {
+	pages := []string{
+		"ACME GmbH\nInvoice 42\nTotal: 9.99 EUR\n1",
+		"ACME GmbH\nTerms: payable within 14 days\n- 2 -",
+		"ACME GmbH\nThank you\nSeite 3/3",
+	}
+	assert.Equal(t, []string{
+		"\nInvoice 42\nTotal: 9.99 EUR\n",
+		"\nTerms: payable within 14 days\n",
+		"\nThank you\n",
+	}, stripPageHeaders(pages, true))
+
+	// CONTENT_NORMALIZATION only removes lines repeated on every page
+	content := "Invoice 42\n1\fTerms\nThank you"
+	assert.Equal(t, content, stripRepeatedHeaders(content))
+
+	// Numbers inside a page are kept
+	assert.False(t, pageNumberLine.MatchString("Total: 42"))
+	assert.False(t, pageNumberLine.MatchString("2024"))
+	assert.True(t, pageNumberLine.MatchString("Page 2 of 10"))
+}
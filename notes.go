@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// processingNotePrefix marks the notes written by paperless-gpt
+const processingNotePrefix = "[paperless-gpt]"
+
+// noteFieldLabels names the document fields in notes, in the order they are listed
+var noteFieldLabels = []struct {
+	field string
+	label string
+}{
+	{"title", "title"},
+	{"tags", "tags"},
+	{"correspondent", "correspondent"},
+	{"custom_fields", "custom fields"},
+	{"content", "content"},
+}
+
+// noteDetailKey is the context key of a description of how the applied values were produced
+type noteDetailKey struct{}
+
+// withNoteDetail adds a description such as "OCR via ollama (minicpm-v), 12 pages" to the processing notes
+// written for updates made with the returned context
+func withNoteDetail(ctx context.Context, detail string) context.Context {
+	return context.WithValue(ctx, noteDetailKey{}, detail)
+}
+
+// noteDetail returns the description set by withNoteDetail, or an empty string
+func noteDetail(ctx context.Context) string {
+	detail, _ := ctx.Value(noteDetailKey{}).(string)
+	return detail
+}
+
+// ocrNoteDetail describes an OCR run for the processing note
+func ocrNoteDetail(pages int) string {
+	return fmt.Sprintf("OCR via %s (%s), %d pages", visionLlmProvider, visionLlmModel, pages)
+}
+
+// processingNote describes the changed fields of a document, e.g. "[paperless-gpt] Title and tags suggested and applied (2024-06-01)"
+func processingNote(changedFields []string, isUndo bool, detail string, now time.Time) string {
+	labels := []string{}
+	for _, entry := range noteFieldLabels {
+		for _, field := range changedFields {
+			if field == entry.field {
+				labels = append(labels, entry.label)
+				break
+			}
+		}
+	}
+
+	var fields string
+	switch len(labels) {
+	case 0:
+		return ""
+	case 1:
+		fields = labels[0]
+	default:
+		fields = strings.Join(labels[:len(labels)-1], ", ") + " and " + labels[len(labels)-1]
+	}
+
+	var action string
+	if isUndo {
+		action = fmt.Sprintf("Undo restored the previous %s", fields)
+	} else {
+		action = strings.ToUpper(fields[:1]) + fields[1:] + " suggested and applied"
+	}
+	if detail != "" {
+		action = detail + "; " + action
+	}
+	return fmt.Sprintf("%s %s (%s)", processingNotePrefix, action, now.Format("2006-01-02"))
+}
+
+// AddNote adds a note to a document
+func (client *PaperlessClient) AddNote(ctx context.Context, documentID int, note string) error {
+	jsonData, err := json.Marshal(map[string]string{"note": note})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(ctx, "POST", fmt.Sprintf("api/documents/%d/notes/", documentID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error adding note to document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessingNote(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "[paperless-gpt] Title suggested and applied (2024-06-01)",
+		processingNote([]string{"title"}, false, "", now))
+	assert.Equal(t, "[paperless-gpt] Title, tags and correspondent suggested and applied (2024-06-01)",
+		processingNote([]string{"correspondent", "tags", "title"}, false, "", now))
+	assert.Equal(t, "[paperless-gpt] OCR via ollama (minicpm-v), 12 pages; Content suggested and applied (2024-06-01)",
+		processingNote([]string{"content"}, false, "OCR via ollama (minicpm-v), 12 pages", now))
+	assert.Equal(t, "[paperless-gpt] Undo restored the previous tags (2024-06-01)",
+		processingNote([]string{"tags"}, true, "", now))
+	assert.Empty(t, processingNote(nil, false, "", now), "unchanged documents get no note")
+}
+
+func TestUpdateDocuments_ProcessingNote(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	original := processingNotes
+	processingNotes = true
+	defer func() { processingNotes = original }()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/4/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var note string
+	env.setMockResponse("/api/documents/4/notes/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		note = body["note"]
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ctx := withNoteDetail(context.Background(), "OCR via ollama (minicpm-v), 2 pages")
+	err := env.client.UpdateDocuments(ctx, []DocumentSuggestion{{
+		ID:               4,
+		OriginalDocument: Document{ID: 4, Title: "scan.pdf", Content: "old"},
+		SuggestedTitle:   "Invoice",
+		SuggestedContent: "new",
+	}}, env.db, false)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^\[paperless-gpt\] OCR via ollama \(minicpm-v\), 2 pages; Title and content suggested and applied \(\d{4}-\d{2}-\d{2}\)$`, note)
+}
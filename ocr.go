@@ -1,18 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	_ "image/jpeg"
 	"os"
+	"slices"
 	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
-// ProcessDocumentOCR processes a document through OCR and returns the combined text
-func (app *App) ProcessDocumentOCR(ctx context.Context, documentID int) (string, error) {
+// ProcessDocumentOCR processes a document through OCR and returns the combined text. The options of an
+// OCR job can select the pages; the zero options process the first OCR_LIMIT_PAGES pages.
+func (app *App) ProcessDocumentOCR(ctx context.Context, documentID int, options OcrJobOptions) (string, error) {
 	docLogger := documentLogger(documentID)
 	docLogger.Info("Starting OCR processing")
 
-	imagePaths, err := app.Client.DownloadDocumentAsImages(ctx, documentID, limitOcrPages)
+	ctx, err := app.withJobVisionModel(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	imagePaths, err := app.Client.DownloadDocumentAsImages(ctx, documentID, options.pageLimit())
 	defer func() {
 		for _, imagePath := range imagePaths {
 			if err := os.Remove(imagePath); err != nil {
@@ -26,9 +38,46 @@ func (app *App) ProcessDocumentOCR(ctx context.Context, documentID int) (string,
 
 	docLogger.WithField("page_count", len(imagePaths)).Debug("Downloaded document images")
 
+	pages, err := options.selectPages(len(imagePaths))
+	if err != nil {
+		return "", fmt.Errorf("error selecting pages of document %d: %w", documentID, err)
+	}
+
+	documentContext := app.ocrDocumentContext(ctx, documentID, docLogger)
+	// OCR_LIMIT_PAGES may have cut off pages, but the prompt should refer to the real page count
+	documentContext.TotalPages = max(documentContext.TotalPages, len(imagePaths))
+
+	// Results of an earlier run may have more pages than this one. An interrupted job keeps the pages it
+	// completed before and continues after them; pages of other runs, e.g. with other options, are dropped.
+	completed := map[int]OcrPageResult{}
+	job := ocrJobOf(ctx)
+	if job.Resume {
+		records, err := KeepJobOcrPageResults(app.Database, documentID, job.ID)
+		if err != nil {
+			return "", fmt.Errorf("error loading previous page results for document %d: %w", documentID, err)
+		}
+		for _, record := range records {
+			completed[record.PageIndex] = record
+		}
+		docLogger.WithField("completed_pages", len(completed)).Info("Resuming OCR after the last completed page")
+	} else if err := DeleteOcrPageResults(app.Database, documentID); err != nil {
+		return "", fmt.Errorf("error removing previous page results for document %d: %w", documentID, err)
+	}
+
 	var ocrTexts []string
-	for i, imagePath := range imagePaths {
+	pagesDone := 0
+	for _, i := range pages {
+		imagePath := imagePaths[i]
 		pageLogger := docLogger.WithField("page", i+1)
+		if record, ok := completed[i]; ok {
+			pageLogger.Debug("Reusing page completed before the job was interrupted")
+			if !record.Blank {
+				ocrTexts = append(ocrTexts, record.Text)
+			}
+			pagesDone++
+			reportPagesDone(ctx, pagesDone)
+			continue
+		}
 		pageLogger.Debug("Processing page")
 
 		imageContent, err := os.ReadFile(imagePath)
@@ -36,15 +85,160 @@ func (app *App) ProcessDocumentOCR(ctx context.Context, documentID int) (string,
 			return "", fmt.Errorf("error reading image file for document %d, page %d: %w", documentID, i+1, err)
 		}
 
-		ocrText, err := app.doOCRViaLLM(ctx, imageContent, pageLogger)
+		if blankPageVariance > 0 {
+			variance, err := pageVariance(imageContent)
+			if err != nil {
+				pageLogger.WithError(err).Warn("Failed to check page for blankness")
+			} else if variance < blankPageVariance {
+				pageLogger.WithField("variance", variance).Info("Skipping blank page")
+				if err := SaveOcrPageResult(app.Database, job.ID, documentID, i, "", true); err != nil {
+					pageLogger.WithError(err).Warn("Failed to store page result")
+				}
+				pagesDone++
+				reportPagesDone(ctx, pagesDone)
+				continue
+			}
+		}
+
+		pageContext := documentContext
+		pageContext.PageNumber = i + 1
+		transcription, err := app.ocrPage(ctx, imageContent, pageContext, pageLogger)
 		if err != nil {
 			return "", fmt.Errorf("error performing OCR for document %d, page %d: %w", documentID, i+1, err)
 		}
 		pageLogger.Debug("OCR completed for page")
+		usedOcrPages.Add(1)
+
+		ocrText := transcription.Text
+		if err := SaveOcrPageResult(app.Database, job.ID, documentID, i, ocrText, false); err != nil {
+			pageLogger.WithError(err).Warn("Failed to store page result")
+		}
+		if transcription.NeedsReview {
+			if err := SetOcrPageNeedsReview(app.Database, documentID, i); err != nil {
+				pageLogger.WithError(err).Warn("Failed to flag page for review")
+			}
+		}
+		if transcription.Confidence != nil {
+			if err := SetOcrPageConfidence(app.Database, documentID, i, *transcription.Confidence); err != nil {
+				pageLogger.WithError(err).Warn("Failed to store page confidence")
+			}
+		}
+
+		if ocrLanguageDetection {
+			languages, err := app.detectPageLanguages(ctx, ocrText)
+			if err != nil {
+				pageLogger.WithError(err).Warn("Failed to detect page languages")
+			} else if err := SetOcrPageLanguages(app.Database, documentID, i, languages); err != nil {
+				pageLogger.WithError(err).Warn("Failed to store page languages")
+			}
+		}
 
 		ocrTexts = append(ocrTexts, ocrText)
+		pagesDone++
+		reportPagesDone(ctx, pagesDone)
 	}
 
 	docLogger.Info("OCR processing completed successfully")
-	return strings.Join(ocrTexts, "\n\n"), nil
+	return joinPageTexts(ocrTexts), nil
+}
+
+// joinPageTexts joins the OCR text of the pages. With OCR_STRIP_HEADERS, headers and footers repeated on every
+// page and page numbers are dropped first; the page results keep the full text.
+func joinPageTexts(pages []string) string {
+	if !ocrStripHeaders {
+		return strings.Join(pages, "\n\n")
+	}
+	var texts []string
+	for _, page := range stripPageHeaders(pages, true) {
+		if page = strings.TrimSpace(page); page != "" {
+			texts = append(texts, page)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// pageTranscription is the OCR result of a page
+type pageTranscription struct {
+	Text        string
+	NeedsReview bool     // The text should be checked by a human
+	Confidence  *float64 // Recognition confidence (0 to 1), only reported for handwritten pages
+}
+
+// ocrPage transcribes a page image, using the handwriting pipeline for documents tagged with HANDWRITING_TAG
+func (app *App) ocrPage(ctx context.Context, jpegBytes []byte, page ocrPageContext, logger *logrus.Entry) (pageTranscription, error) {
+	if page.Handwritten {
+		return app.ocrHandwrittenPage(ctx, jpegBytes, page, logger)
+	}
+	text, needsReview, err := app.consensusOCR(ctx, jpegBytes, page, logger)
+	return pageTranscription{Text: text, NeedsReview: needsReview}, err
+}
+
+// ocrPageContext describes the page being transcribed, passed to the OCR prompt template
+type ocrPageContext struct {
+	Title         string
+	Correspondent string
+	DocumentType  string
+	PageNumber    int // 1-based
+	TotalPages    int
+	Handwritten   bool     // Document is tagged with HANDWRITING_TAG
+	Languages     []string // ISO 639-1 codes recorded in paperless-ngx with language tags or the language custom field
+}
+
+// ocrDocumentContext fetches the document metadata for the OCR prompt. Without it the prompt is only less
+// specific, so errors are logged and the fields left empty.
+func (app *App) ocrDocumentContext(ctx context.Context, documentID int, logger *logrus.Entry) ocrPageContext {
+	document, err := app.Client.GetDocument(ctx, documentID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to fetch document details for the OCR prompt")
+		return ocrPageContext{}
+	}
+
+	pageContext := ocrPageContext{
+		Title:         document.Title,
+		Correspondent: document.Correspondent,
+		TotalPages:    document.PageCount,
+		Handwritten:   handwritingTag != "" && slices.Contains(document.Tags, handwritingTag),
+	}
+	if pageContext.Languages, err = app.knownDocumentLanguages(ctx, document); err != nil {
+		logger.WithError(err).Warn("Failed to read the document languages for the OCR prompt")
+	}
+	if document.DocumentTypeID != 0 {
+		documentTypes, err := app.Client.GetAllDocumentTypes(ctx)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to fetch document types for the OCR prompt")
+		}
+		for name, id := range documentTypes {
+			if id == document.DocumentTypeID {
+				pageContext.DocumentType = name
+			}
+		}
+	}
+	return pageContext
+}
+
+// pageVariance calculates the variance of the gray values (0-255) of a page image.
+// Blank pages, even when scanned with some noise, have a very low variance compared to pages with text.
+func pageVariance(imageContent []byte) (float64, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageContent))
+	if err != nil {
+		return 0, err
+	}
+
+	var sum, sumSquares float64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			gray := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			sum += gray
+			sumSquares += gray * gray
+		}
+	}
+
+	pixels := float64(bounds.Dx() * bounds.Dy())
+	if pixels == 0 {
+		return 0, nil
+	}
+	mean := sum / pixels
+	return sumSquares/pixels - mean*mean, nil
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ocrCacheTTL is how long OCR responses are reused for identical page images, read from OCR_CACHE_TTL. 0 disables the cache.
+	ocrCacheTTL time.Duration
+
+	// ocrCacheMaxEntries limits the number of cached OCR responses, read from OCR_CACHE_MAX_ENTRIES
+	ocrCacheMaxEntries = 10000
+)
+
+// ocrCacheKey identifies an OCR request. Any change of the image, the provider, the model or the rendered
+// prompt (e.g. an edited ocr_prompt.tmpl) leads to a new provider call.
+func ocrCacheKey(image []byte, provider string, model string, prompt string) string {
+	hash := sha256.New()
+	hash.Write(image)
+	for _, part := range []string{provider, model, prompt} {
+		hash.Write([]byte{0})
+		hash.Write([]byte(part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// noOcrCacheKey is the context key marking OCR requests that must reach the provider
+type noOcrCacheKey struct{}
+
+// withoutOcrCache returns a context in which OCR responses are neither read from nor stored in the cache,
+// e.g. for health checks that have to test the provider
+func withoutOcrCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noOcrCacheKey{}, true)
+}
+
+// ocrCacheEnabled reports whether OCR responses are cached in the context
+func (app *App) ocrCacheEnabled(ctx context.Context) bool {
+	bypass, _ := ctx.Value(noOcrCacheKey{}).(bool)
+	return ocrCacheTTL > 0 && app.Database != nil && !bypass
+}
+
+// cachedOCR returns the cached OCR text for a key, if the cache is enabled and holds a fresh response
+func (app *App) cachedOCR(ctx context.Context, key string) (string, bool) {
+	if !app.ocrCacheEnabled(ctx) {
+		return "", false
+	}
+	entry, err := GetOcrCacheEntry(app.Database, key, time.Now().Add(-ocrCacheTTL))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warnf("Failed to read the OCR cache: %v", err)
+		}
+		return "", false
+	}
+	return entry.Text, true
+}
+
+// cacheOCR stores an OCR response if the cache is enabled
+func (app *App) cacheOCR(ctx context.Context, key string, text string) {
+	if !app.ocrCacheEnabled(ctx) {
+		return
+	}
+	if err := SaveOcrCacheEntry(app.Database, key, text, ocrCacheMaxEntries); err != nil {
+		log.Warnf("Failed to store the OCR response in the cache: %v", err)
+	}
+}
+
+// startOcrCacheCleanup periodically removes expired OCR responses
+func startOcrCacheCleanup(db *gorm.DB) {
+	if ocrCacheTTL <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(min(ocrCacheTTL, time.Hour))
+		defer ticker.Stop()
+		for range ticker.C {
+			removed, err := DeleteOcrCacheEntriesBefore(db, time.Now().Add(-ocrCacheTTL))
+			if err != nil {
+				log.Errorf("Failed to remove expired OCR cache entries: %v", err)
+			} else if removed > 0 {
+				log.Infof("Removed %d expired OCR cache entries", removed)
+			}
+		}
+	}()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOcrCacheKey(t *testing.T) {
+	key := ocrCacheKey([]byte("image"), "ollama", "minicpm-v", "prompt")
+	assert.Equal(t, key, ocrCacheKey([]byte("image"), "ollama", "minicpm-v", "prompt"))
+	assert.NotEqual(t, key, ocrCacheKey([]byte("other image"), "ollama", "minicpm-v", "prompt"))
+	assert.NotEqual(t, key, ocrCacheKey([]byte("image"), "openai", "minicpm-v", "prompt"))
+	assert.NotEqual(t, key, ocrCacheKey([]byte("image"), "ollama", "minicpm-v", "other prompt"))
+	// Parts are separated, so moving text between them changes the key
+	assert.NotEqual(t, ocrCacheKey([]byte("image"), "ollama", "a", "b"), ocrCacheKey([]byte("image"), "ollam", "aa", "b"))
+}
+
+func TestDoOCRViaLLM_Cache(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	db.Where("1 = 1").Delete(&OcrCacheEntry{})
+
+	originalTemplate, originalProvider, originalTTL, originalMax := ocrTemplate, visionLlmProvider, ocrCacheTTL, ocrCacheMaxEntries
+	defer func() {
+		ocrTemplate, visionLlmProvider, ocrCacheTTL, ocrCacheMaxEntries = originalTemplate, originalProvider, originalTTL, originalMax
+	}()
+	ocrTemplate = template.Must(template.New("ocr").Parse("Page {{.PageNumber}}"))
+	visionLlmProvider, ocrCacheTTL, ocrCacheMaxEntries = "ollama", time.Hour, 10
+
+	llm := &scriptedLLM{responses: []string{"Balance: 100 EUR", "Balance: 200 EUR"}}
+	app := &App{VisionLLM: llm, Database: db}
+	logger := logrus.WithField("test", "test")
+
+	text, err := app.doOCRViaLLM(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "Balance: 100 EUR", text)
+
+	// The same page is answered from the cache
+	text, err = app.doOCRViaLLM(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "Balance: 100 EUR", text)
+	assert.Len(t, llm.conversations, 1)
+
+	// A different prompt asks the provider again
+	text, err = app.doOCRViaLLM(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 2}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "Balance: 200 EUR", text)
+	assert.Len(t, llm.conversations, 2)
+
+	// Health checks always ask the provider
+	llm.responses = append(llm.responses, "OK")
+	text, err = app.doOCRViaLLM(withoutOcrCache(context.Background()), providerTestImage(), ocrPageContext{PageNumber: 1}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", text)
+	assert.Len(t, llm.conversations, 3)
+
+	// Expired entries are not used
+	_, err = GetOcrCacheEntry(db, ocrCacheKey(providerTestImage(), "ollama", visionLlmModel, "Page 1"), time.Now().Add(time.Minute))
+	assert.Error(t, err)
+}
+
+func TestSaveOcrCacheEntry_MaxEntries(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	db.Where("1 = 1").Delete(&OcrCacheEntry{})
+
+	require.NoError(t, SaveOcrCacheEntry(db, "old", "old text", 2))
+	require.NoError(t, db.Model(&OcrCacheEntry{}).Where("hash = ?", "old").Update("date_used", "2000-01-01T00:00:00Z").Error)
+	require.NoError(t, SaveOcrCacheEntry(db, "recent", "recent text", 2))
+	require.NoError(t, SaveOcrCacheEntry(db, "new", "new text", 2))
+
+	var hashes []string
+	require.NoError(t, db.Model(&OcrCacheEntry{}).Order("hash").Pluck("hash", &hashes).Error)
+	assert.Equal(t, []string{"new", "recent"}, hashes, "the least recently used entry is removed")
+}
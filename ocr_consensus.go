@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ocrConsensusPrompt asks the LLM to merge two transcriptions of the same page
+const ocrConsensusPrompt = `Two OCR models transcribed the same document page. Their transcriptions differ in some places.
+Compare them and write the most likely correct transcription of the page. Where they disagree, prefer the reading that fits the context, for example correctly spelled words, plausible numbers and dates, and text that only one of them picked up.
+Keep the formatting and layout of the transcriptions. Respond only with the transcription, without any comments.
+
+Transcription A:
+%s
+
+Transcription B:
+%s`
+
+// consensusOCR transcribes a page with the vision LLM. In the consensus OCR mode (OCR_CONSENSUS_PROVIDER) the
+// page is also transcribed by the consensus vision LLM and differing results are reconciled by the LLM. The page
+// needs review if the transcriptions diverge by more than OCR_CONSENSUS_THRESHOLD allows.
+func (app *App) consensusOCR(ctx context.Context, jpegBytes []byte, page ocrPageContext, logger *logrus.Entry) (string, bool, error) {
+	text, err := app.doOCRViaLLM(ctx, jpegBytes, page, logger)
+	if err != nil || app.ConsensusVisionLLM == nil {
+		return text, false, err
+	}
+
+	second, err := app.transcribePage(ctx, app.ConsensusVisionLLM, consensusVisionProvider, consensusVisionModel, jpegBytes, page, logger)
+	if err != nil {
+		// The first transcription is still usable, but nothing confirms it
+		logger.WithError(err).Warn("Consensus OCR model failed, flagging the page for review")
+		return text, true, nil
+	}
+
+	similarity := ocrSimilarity(text, second)
+	needsReview := similarity < ocrConsensusThreshold
+	logger.WithField("similarity", fmt.Sprintf("%.3f", similarity)).Debug("Compared the transcriptions of both OCR models")
+	if similarity == 1 {
+		return text, false, nil
+	}
+
+	if app.LLM == nil {
+		return longerTranscription(text, second), needsReview, nil
+	}
+	reconciled, err := app.reconcileTranscriptions(ctx, text, second)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to reconcile the transcriptions, using the longer one")
+		return longerTranscription(text, second), needsReview, nil
+	}
+	return reconciled, needsReview, nil
+}
+
+// reconcileTranscriptions asks the LLM to merge two differing transcriptions of a page
+func (app *App) reconcileTranscriptions(ctx context.Context, first string, second string) (string, error) {
+	response, err := app.generateValidated(ctx, fmt.Sprintf(ocrConsensusPrompt, first, second), validateTranscription)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stripReasoning(response)), nil
+}
+
+// validateTranscription rejects empty answers, which would drop the text of the page
+func validateTranscription(response string) error {
+	if strings.TrimSpace(stripReasoning(response)) == "" {
+		return fmt.Errorf("expected the transcription of the page, got an empty answer")
+	}
+	return nil
+}
+
+// ocrSimilarity compares two transcriptions between 0 (completely different) and 1 (same text). Case,
+// whitespace, punctuation and markdown formatting are ignored, since only the recognized text matters.
+func ocrSimilarity(a string, b string) float64 {
+	a, b = normalizeLabel(a), normalizeLabel(b)
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// longerTranscription picks the transcription with more text, since vision models rather skip text than invent it
+func longerTranscription(first string, second string) string {
+	if len(normalizeLabel(second)) > len(normalizeLabel(first)) {
+		return second
+	}
+	return first
+}
+
+// pagesNeedReview reports whether any page was flagged for review by the consensus OCR mode
+func pagesNeedReview(pages []OcrPageResult) bool {
+	for _, page := range pages {
+		if page.NeedsReview {
+			return true
+		}
+	}
+	return false
+}
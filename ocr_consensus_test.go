@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestOcrSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, ocrSimilarity("# Invoice\n\nTotal: 100 EUR", "INVOICE total 100 eur"))
+	assert.Equal(t, 1.0, ocrSimilarity("", ""))
+	assert.InDelta(t, 11.0/12, ocrSimilarity("Total 1000 EUR", "Total 1800 EUR"), 0.001)
+	assert.Equal(t, 0.0, ocrSimilarity("abc", ""))
+}
+
+func TestConsensusOCR(t *testing.T) {
+	originalTemplate, originalThreshold := ocrTemplate, ocrConsensusThreshold
+	ocrTemplate = template.Must(template.New("ocr").Parse("Transcribe page {{.PageNumber}}"))
+	ocrConsensusThreshold = 0.95
+	defer func() { ocrTemplate, ocrConsensusThreshold = originalTemplate, originalThreshold }()
+
+	page := ocrPageContext{PageNumber: 1, TotalPages: 1}
+	logger := logrus.WithField("test", "consensus")
+
+	t.Run("single model", func(t *testing.T) {
+		app := &App{VisionLLM: &scriptedLLM{responses: []string{"Total: 100 EUR"}}}
+		text, needsReview, err := app.consensusOCR(context.Background(), providerTestImage(), page, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Total: 100 EUR", text)
+		assert.False(t, needsReview)
+	})
+
+	t.Run("matching transcriptions skip the LLM", func(t *testing.T) {
+		llm := &scriptedLLM{}
+		app := &App{
+			LLM:                llm,
+			VisionLLM:          &scriptedLLM{responses: []string{"**Total:** 100 EUR"}},
+			ConsensusVisionLLM: &scriptedLLM{responses: []string{"Total: 100 EUR"}},
+		}
+		text, needsReview, err := app.consensusOCR(context.Background(), providerTestImage(), page, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "**Total:** 100 EUR", text)
+		assert.False(t, needsReview)
+		assert.Empty(t, llm.conversations)
+	})
+
+	t.Run("diverging transcriptions are reconciled and flagged", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"Total: 1000 EUR"}}
+		app := &App{
+			LLM:                llm,
+			VisionLLM:          &scriptedLLM{responses: []string{"Total: 1000 EUR"}},
+			ConsensusVisionLLM: &scriptedLLM{responses: []string{"Total: 1800 EUR"}},
+		}
+		text, needsReview, err := app.consensusOCR(context.Background(), providerTestImage(), page, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Total: 1000 EUR", text)
+		assert.True(t, needsReview)
+
+		require.Len(t, llm.conversations, 1)
+		prompt := llm.conversations[0][0].Parts[0].(llms.TextContent).Text
+		assert.Contains(t, prompt, "Transcription A:\nTotal: 1000 EUR")
+		assert.Contains(t, prompt, "Transcription B:\nTotal: 1800 EUR")
+	})
+
+	t.Run("without LLM the longer transcription wins", func(t *testing.T) {
+		app := &App{
+			VisionLLM:          &scriptedLLM{responses: []string{"Total: 100 EUR"}},
+			ConsensusVisionLLM: &scriptedLLM{responses: []string{"Invoice 42\nTotal: 100 EUR"}},
+		}
+		text, needsReview, err := app.consensusOCR(context.Background(), providerTestImage(), page, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice 42\nTotal: 100 EUR", text)
+		assert.True(t, needsReview)
+	})
+
+	t.Run("failing consensus model flags the page", func(t *testing.T) {
+		app := &App{
+			VisionLLM:          &scriptedLLM{responses: []string{"Total: 100 EUR"}},
+			ConsensusVisionLLM: &failingLLM{err: errors.New("connection refused")},
+		}
+		text, needsReview, err := app.consensusOCR(context.Background(), providerTestImage(), page, logger)
+		require.NoError(t, err)
+		assert.Equal(t, "Total: 100 EUR", text)
+		assert.True(t, needsReview)
+	})
+}
+
+func TestPagesNeedReview(t *testing.T) {
+	assert.False(t, pagesNeedReview([]OcrPageResult{{PageIndex: 0}, {PageIndex: 1, Blank: true}}))
+	assert.True(t, pagesNeedReview([]OcrPageResult{{PageIndex: 0}, {PageIndex: 1, NeedsReview: true}}))
+}
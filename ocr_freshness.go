@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fresherOcrText returns the combined text of the stored OCR pages if they were processed after the
+// document was last modified in paperless-ngx, e.g. because the suggested content was not applied yet
+func fresherOcrText(pages []OcrPageResult, modified time.Time) (string, bool) {
+	if modified.IsZero() {
+		return "", false
+	}
+	var newest time.Time
+	texts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		if processed, err := time.Parse(time.RFC3339, page.DateAdded); err == nil && processed.After(newest) {
+			newest = processed
+		}
+		if !page.Blank {
+			texts = append(texts, page.Text)
+		}
+	}
+	text := strings.Join(texts, "\n\n")
+	if !newest.After(modified) || strings.TrimSpace(text) == "" {
+		return "", false
+	}
+	return text, true
+}
+
+// fresherOcrContents looks up the documents whose stored OCR text is newer than their content in
+// paperless-ngx, keyed by document ID. Only with PREFER_FRESHER_OCR_TEXT enabled.
+func (app *App) fresherOcrContents(documents []Document, logger *logrus.Entry) map[int]string {
+	if !preferFresherOcrText || app.Database == nil {
+		return nil
+	}
+	contents := map[int]string{}
+	for _, doc := range documents {
+		pages, err := GetOcrPageResults(app.Database, doc.ID)
+		if err != nil {
+			logger.Warnf("Failed to retrieve OCR pages for document %d: %v", doc.ID, err)
+			continue
+		}
+		if text, ok := fresherOcrText(pages, doc.Modified); ok {
+			logger.Infof("Using the local OCR text of document %d, which is newer than its content in paperless-ngx", doc.ID)
+			contents[doc.ID] = text
+		}
+	}
+	return contents
+}
+
+// withContents returns a copy of the documents with their content replaced where given
+func withContents(documents []Document, contents map[int]string) []Document {
+	replaced := make([]Document, len(documents))
+	for i, doc := range documents {
+		if text, ok := contents[doc.ID]; ok {
+			doc.Content = text
+		}
+		replaced[i] = doc
+	}
+	return replaced
+}
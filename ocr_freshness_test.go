@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFresherOcrText(t *testing.T) {
+	modified := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	pages := []OcrPageResult{
+		{PageIndex: 0, Text: "Page one", DateAdded: "2024-06-01T13:00:00Z"},
+		{PageIndex: 1, Blank: true, DateAdded: "2024-06-01T13:00:05Z"},
+		{PageIndex: 2, Text: "Page three", DateAdded: "2024-06-01T13:00:10Z"},
+	}
+
+	text, ok := fresherOcrText(pages, modified)
+	assert.True(t, ok)
+	assert.Equal(t, "Page one\n\nPage three", text)
+
+	_, ok = fresherOcrText(pages, modified.Add(2*time.Hour))
+	assert.False(t, ok, "paperless-ngx content is newer")
+
+	_, ok = fresherOcrText(pages, time.Time{})
+	assert.False(t, ok, "unknown modification date")
+
+	_, ok = fresherOcrText(nil, modified)
+	assert.False(t, ok)
+}
+
+func TestFresherOcrContents(t *testing.T) {
+	original := preferFresherOcrText
+	defer func() { preferFresherOcrText = original }()
+
+	env := newTestEnv(t)
+	defer env.teardown()
+	require.NoError(t, SaveOcrPageResult(env.db, "", 9105, 0, "Fresh OCR text", false))
+	app := &App{Database: env.db}
+	documents := []Document{
+		{ID: 9105, Content: "stale", Modified: time.Now().Add(-time.Hour)},
+		{ID: 9106, Content: "no pages", Modified: time.Now().Add(-time.Hour)},
+	}
+	logger := logrus.WithField("test", "test")
+
+	preferFresherOcrText = false
+	assert.Empty(t, app.fresherOcrContents(documents, logger))
+
+	preferFresherOcrText = true
+	contents := app.fresherOcrContents(documents, logger)
+	assert.Equal(t, map[int]string{9105: "Fresh OCR text"}, contents)
+
+	replaced := withContents(documents, contents)
+	assert.Equal(t, "Fresh OCR text", replaced[0].Content)
+	assert.Equal(t, "no pages", replaced[1].Content)
+	assert.Equal(t, "stale", documents[0].Content, "the documents are not modified")
+}
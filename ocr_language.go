@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// languageDetectionSampleLength is the number of characters of a page sent to the LLM to detect its languages
+const languageDetectionSampleLength = 2000
+
+// languageDetectionPrompt asks the LLM for the languages of an OCR page
+const languageDetectionPrompt = `Which languages is the following text written in? Ignore single foreign words and names.
+Respond only with the ISO 639-1 codes of the languages as a comma-separated list, most used language first, for example "de, en".
+
+Text:
+%s`
+
+// languageCode matches an ISO 639-1 language code
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// detectPageLanguages asks the LLM which languages the OCR text of a page is written in
+func (app *App) detectPageLanguages(ctx context.Context, text string) ([]string, error) {
+	prompt := fmt.Sprintf(languageDetectionPrompt, truncateRunes(text, languageDetectionSampleLength))
+	response, err := app.generateValidated(ctx, prompt, validateLanguageCodes)
+	if err != nil {
+		return nil, err
+	}
+	return parseLanguageCodes(response), nil
+}
+
+// validateLanguageCodes checks that an answer is a comma-separated list of ISO 639-1 codes
+func validateLanguageCodes(response string) error {
+	codes := splitAndTrim(strings.ToLower(strings.Trim(strings.TrimSpace(stripReasoning(response)), `".`)))
+	if len(codes) == 0 {
+		return fmt.Errorf("expected a comma-separated list of ISO 639-1 language codes, got an empty answer")
+	}
+	for _, code := range codes {
+		if !languageCode.MatchString(code) {
+			return fmt.Errorf("expected a comma-separated list of ISO 639-1 language codes such as \"de, en\", got %q", code)
+		}
+	}
+	return nil
+}
+
+// parseLanguageCodes turns a validated answer into a list of unique lower case codes
+func parseLanguageCodes(response string) []string {
+	codes := []string{}
+	for _, code := range splitAndTrim(strings.ToLower(strings.Trim(strings.TrimSpace(stripReasoning(response)), `".`))) {
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// documentLanguages collects the languages of all pages of a document, in order of first appearance
+func documentLanguages(pages []OcrPageResult) []string {
+	languages := []string{}
+	for _, page := range pages {
+		for _, code := range splitAndTrim(page.Languages) {
+			if !slices.Contains(languages, code) {
+				languages = append(languages, code)
+			}
+		}
+	}
+	return languages
+}
+
+// languageCustomFieldValue returns the value for OCR_LANGUAGE_CUSTOM_FIELD, or nil if the field is not configured
+func (app *App) languageCustomFieldValue(ctx context.Context, languages []string) ([]CustomFieldValue, error) {
+	if ocrLanguageCustomField == "" || len(languages) == 0 {
+		return nil, nil
+	}
+	customFields, err := app.Client.GetCustomFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range customFields {
+		if field.Name == ocrLanguageCustomField {
+			return []CustomFieldValue{{Field: field.ID, Value: strings.Join(languages, ", ")}}, nil
+		}
+	}
+	return nil, fmt.Errorf("custom field %s does not exist in paperless-ngx", ocrLanguageCustomField)
+}
+
+// languageTags returns the tags for OCR_LANGUAGE_TAG_PREFIX, e.g. "lang:de"
+func languageTags(languages []string) []string {
+	if ocrLanguageTagPrefix == "" {
+		return nil
+	}
+	tags := make([]string, 0, len(languages))
+	for _, code := range languages {
+		tags = append(tags, ocrLanguageTagPrefix+code)
+	}
+	return tags
+}
+
+// staleLanguageTags returns the language tags of a document for languages that were no longer detected
+func staleLanguageTags(documentTags []string, detected []string) []string {
+	if ocrLanguageTagPrefix == "" {
+		return nil
+	}
+	var stale []string
+	for _, tag := range documentTags {
+		if strings.HasPrefix(tag, ocrLanguageTagPrefix) && !slices.Contains(detected, tag) {
+			stale = append(stale, tag)
+		}
+	}
+	return stale
+}
+
+// knownDocumentLanguages returns the languages paperless-ngx already records for a document, from its tags with
+// OCR_LANGUAGE_TAG_PREFIX and the value of OCR_LANGUAGE_CUSTOM_FIELD, e.g. set by a previous OCR run or by hand
+func (app *App) knownDocumentLanguages(ctx context.Context, document Document) ([]string, error) {
+	var languages []string
+	add := func(code string) {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if languageCode.MatchString(code) && !slices.Contains(languages, code) {
+			languages = append(languages, code)
+		}
+	}
+
+	if ocrLanguageTagPrefix != "" {
+		for _, tag := range document.Tags {
+			if code, ok := strings.CutPrefix(tag, ocrLanguageTagPrefix); ok {
+				add(code)
+			}
+		}
+	}
+
+	if ocrLanguageCustomField != "" && len(document.CustomFields) > 0 {
+		customFields, err := app.Client.GetCustomFields(ctx)
+		if err != nil {
+			return languages, err
+		}
+		for _, field := range customFields {
+			if field.Name != ocrLanguageCustomField {
+				continue
+			}
+			for _, value := range document.CustomFields {
+				if text, ok := value.Value.(string); ok && value.Field == field.ID {
+					for _, code := range splitAndTrim(text) {
+						add(code)
+					}
+				}
+			}
+		}
+	}
+	return languages, nil
+}
+
+// ocrLanguageHint returns the sentence appended to an OCR prompt for the known languages of the document,
+// unless the template places them itself with {{.DocumentLanguages}}
+func ocrLanguageHint(pageTemplate *template.Template, languages []string) string {
+	if len(languages) == 0 || (pageTemplate.Tree != nil && strings.Contains(pageTemplate.Tree.Root.String(), ".DocumentLanguages")) {
+		return ""
+	}
+	return fmt.Sprintf("The document is written in these languages (ISO 639-1 codes): %s.", strings.Join(languages, ", "))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPageLanguages(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{"German and English", "DE, en, de"}}
+	app := &App{LLM: llm}
+
+	languages, err := app.detectPageLanguages(context.Background(), "Sehr geehrte Damen und Herren, please find attached")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de", "en"}, languages)
+	assert.Len(t, llm.conversations, 2)
+}
+
+func TestDocumentLanguages(t *testing.T) {
+	pages := []OcrPageResult{{Languages: "de,en"}, {Blank: true}, {Languages: "fr,de"}}
+	assert.Equal(t, []string{"de", "en", "fr"}, documentLanguages(pages))
+
+	original := ocrLanguageTagPrefix
+	ocrLanguageTagPrefix = "lang:"
+	defer func() { ocrLanguageTagPrefix = original }()
+	assert.Equal(t, []string{"lang:de", "lang:fr"}, languageTags([]string{"de", "fr"}))
+}
+
+func TestKnownDocumentLanguages(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalPrefix, originalField := ocrLanguageTagPrefix, ocrLanguageCustomField
+	defer func() { ocrLanguageTagPrefix, ocrLanguageCustomField = originalPrefix, originalField }()
+	ocrLanguageTagPrefix, ocrLanguageCustomField = "lang:", "Language"
+
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 7, "name": "Language", "data_type": "string"}], "next": null}`))
+	})
+
+	app := &App{Client: env.client}
+	document := Document{
+		Tags:         []string{"lang:de", "Invoice", "lang:german"},
+		CustomFields: []CustomFieldValue{{Field: 7, Value: "DE, fr"}, {Field: 8, Value: "es"}},
+	}
+	languages, err := app.knownDocumentLanguages(context.Background(), document)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de", "fr"}, languages, "only ISO 639-1 codes of the configured tags and field are used")
+
+	// Language tags that were no longer detected are removed on write-back
+	assert.Equal(t, []string{"lang:german"}, staleLanguageTags(document.Tags, []string{"lang:de", "lang:fr"}))
+}
+
+func TestOcrLanguageHint(t *testing.T) {
+	plain := template.Must(template.New("ocr").Parse("Transcribe the page."))
+	assert.Empty(t, ocrLanguageHint(plain, nil))
+	assert.Equal(t, "The document is written in these languages (ISO 639-1 codes): de, en.", ocrLanguageHint(plain, []string{"de", "en"}))
+
+	// Templates placing the languages themselves get no extra sentence
+	custom := template.Must(template.New("ocr").Parse("Transcribe the page{{if .DocumentLanguages}}, written in {{.DocumentLanguages}}{{end}}."))
+	assert.Empty(t, ocrLanguageHint(custom, []string{"de"}))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ocrMetadataFields maps metadata keys to the IDs of the custom fields they are written to, read from
+// OCR_METADATA_FIELDS, e.g. "pages=12,duration=15"
+var ocrMetadataFields map[string]int
+
+// ocrMetadataKeys lists the metadata that can be written to custom fields after background OCR
+var ocrMetadataKeys = []string{"pages", "file_size", "provider", "model", "duration", "language"}
+
+// ocrMetadata is objective metadata about a document and its OCR run that paperless does not record
+type ocrMetadata struct {
+	Pages     int
+	FileSize  int64 // Size of the original file in bytes, 0 if not fetched
+	Provider  string
+	Model     string
+	Duration  time.Duration
+	Languages []string
+}
+
+// parseOcrMetadataFields parses a comma-separated list of key=custom field ID pairs
+func parseOcrMetadataFields(spec string) (map[string]int, error) {
+	fields := map[string]int{}
+	for _, entry := range splitAndTrim(spec) {
+		key, rawID, found := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !found {
+			return nil, fmt.Errorf("expected key=custom field ID, got %q", entry)
+		}
+		if !slices.Contains(ocrMetadataKeys, key) {
+			return nil, fmt.Errorf("unknown metadata %q, expected one of %s", key, strings.Join(ocrMetadataKeys, ", "))
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(rawID))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid custom field ID %q for %s", rawID, key)
+		}
+		fields[key] = id
+	}
+	return fields, nil
+}
+
+// ocrModelOf returns the vision provider and model that transcribed a document
+func (app *App) ocrModelOf(document Document) (string, string) {
+	if app.HandwritingVisionLLM != nil && handwritingTag != "" && slices.Contains(document.Tags, handwritingTag) {
+		return handwritingProvider, handwritingModel
+	}
+	if app.ConsensusVisionLLM != nil {
+		return visionLlmProvider + "+" + consensusVisionProvider, visionLlmModel + "+" + consensusVisionModel
+	}
+	return visionLlmProvider, visionLlmModel
+}
+
+// ocrMetadataFieldValues returns the custom field values for OCR_METADATA_FIELDS and the ocr_ outputs of
+// CUSTOM_FIELD_MAPPING. The values are converted to the data type of each field, with units only in text
+// fields, e.g. 3 in an integer field and "3 pages" in a text field. Metadata that does not fit its field is
+// skipped with a warning.
+func (app *App) ocrMetadataFieldValues(ctx context.Context, documentID int, metadata ocrMetadata) ([]CustomFieldValue, error) {
+	isOcrOutput := func(output string) bool { return strings.HasPrefix(output, "ocr_") }
+	if len(ocrMetadataFields) == 0 && !slices.ContainsFunc(customFieldMapping, func(mapping fieldMapping) bool { return isOcrOutput(mapping.Output) }) {
+		return nil, nil
+	}
+	customFields, err := app.Client.GetCustomFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields of OCR_METADATA_FIELDS first, then the mapped ones
+	var targets []mappedField
+	for _, key := range ocrMetadataKeys {
+		fieldID, ok := ocrMetadataFields[key]
+		if !ok {
+			continue
+		}
+		index := slices.IndexFunc(customFields, func(field CustomField) bool { return field.ID == fieldID })
+		if index < 0 {
+			documentLogger(documentID).Warnf("Custom field %d for %s does not exist in paperless-ngx", fieldID, key)
+			continue
+		}
+		targets = append(targets, mappedField{output: key, field: customFields[index]})
+	}
+	for _, mapped := range resolveFieldMapping(customFields, isOcrOutput, documentLogger(documentID)) {
+		targets = append(targets, mappedField{output: strings.TrimPrefix(mapped.output, "ocr_"), field: mapped.field})
+	}
+
+	if slices.ContainsFunc(targets, func(target mappedField) bool { return target.output == "file_size" }) {
+		if metadata.FileSize, err = app.Client.GetDocumentFileSize(ctx, documentID); err != nil {
+			documentLogger(documentID).Warnf("Failed to fetch the file size: %v", err)
+		}
+	}
+
+	var values []CustomFieldValue
+	for _, target := range targets {
+		if slices.ContainsFunc(values, func(value CustomFieldValue) bool { return value.Field == target.field.ID }) {
+			continue
+		}
+		value, ok := metadataFieldValue(target.output, target.field.DataType, metadata)
+		if !ok {
+			documentLogger(documentID).Warnf("Custom field %d of type %s cannot hold %s", target.field.ID, target.field.DataType, target.output)
+			continue
+		}
+		values = append(values, CustomFieldValue{Field: target.field.ID, Value: value})
+	}
+	return values, nil
+}
+
+// metadataFieldValue converts a metadata value to a custom field data type. It returns false if the value is
+// unknown or does not fit the field.
+func metadataFieldValue(key string, dataType string, metadata ocrMetadata) (interface{}, bool) {
+	var number float64 // Value for integer and float fields
+	var text string    // Value for string fields, with unit
+	switch key {
+	case "pages":
+		number, text = float64(metadata.Pages), fmt.Sprintf("%d pages", metadata.Pages)
+		if metadata.Pages == 1 {
+			text = "1 page"
+		}
+	case "file_size":
+		if metadata.FileSize <= 0 {
+			return nil, false
+		}
+		number, text = float64(metadata.FileSize), formatFileSize(metadata.FileSize)
+	case "duration":
+		number, text = math.Round(metadata.Duration.Seconds()*10)/10, metadata.Duration.Round(time.Second).String()
+	case "provider":
+		text = metadata.Provider
+	case "model":
+		text = metadata.Model
+	case "language":
+		if len(metadata.Languages) == 0 {
+			return nil, false
+		}
+		text = strings.Join(metadata.Languages, ", ")
+	default:
+		return nil, false
+	}
+
+	isNumeric := key == "pages" || key == "file_size" || key == "duration"
+	switch dataType {
+	case "string", "longtext":
+		return text, true
+	case "integer":
+		return int64(math.Round(number)), isNumeric
+	case "float":
+		return number, isNumeric
+	}
+	return nil, false
+}
+
+// formatFileSize formats a size in bytes with a binary unit, e.g. "1.5 MB"
+func formatFileSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 3 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exponent])
+}
+
+// GetDocumentFileSize returns the size of the original file of a document in bytes
+func (client *PaperlessClient) GetDocumentFileSize(ctx context.Context, documentID int) (int64, error) {
+	resp, err := client.Do(ctx, "GET", fmt.Sprintf("api/documents/%d/metadata/", documentID), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("error fetching metadata of document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+	}
+
+	var metadata struct {
+		OriginalSize int64 `json:"original_size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return 0, err
+	}
+	return metadata.OriginalSize, nil
+}
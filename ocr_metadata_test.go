@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOcrMetadataFields(t *testing.T) {
+	fields, err := parseOcrMetadataFields("pages=12, Duration = 15,model=14")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"pages": 12, "duration": 15, "model": 14}, fields)
+
+	for _, spec := range []string{"pages", "colour=3", "pages=abc", "pages=0"} {
+		_, err := parseOcrMetadataFields(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMetadataFieldValue(t *testing.T) {
+	metadata := ocrMetadata{
+		Pages:     3,
+		FileSize:  1572864,
+		Provider:  "ollama",
+		Model:     "minicpm-v",
+		Duration:  83*time.Second + 420*time.Millisecond,
+		Languages: []string{"de", "en"},
+	}
+
+	tests := []struct {
+		key      string
+		dataType string
+		value    interface{}
+		ok       bool
+	}{
+		{"pages", "integer", int64(3), true},
+		{"pages", "string", "3 pages", true},
+		{"file_size", "integer", int64(1572864), true},
+		{"file_size", "string", "1.5 MB", true},
+		{"duration", "float", 83.4, true},
+		{"duration", "integer", int64(83), true},
+		{"duration", "string", "1m23s", true},
+		{"model", "string", "minicpm-v", true},
+		{"language", "string", "de, en", true},
+		{"model", "integer", nil, false},
+		{"pages", "date", nil, false},
+	}
+	for _, tt := range tests {
+		value, ok := metadataFieldValue(tt.key, tt.dataType, metadata)
+		assert.Equal(t, tt.ok, ok, "%s as %s", tt.key, tt.dataType)
+		if tt.ok {
+			assert.Equal(t, tt.value, value, "%s as %s", tt.key, tt.dataType)
+		}
+	}
+
+	_, ok := metadataFieldValue("file_size", "integer", ocrMetadata{})
+	assert.False(t, ok, "unknown file size is skipped")
+	value, _ := metadataFieldValue("pages", "string", ocrMetadata{Pages: 1})
+	assert.Equal(t, "1 page", value)
+}
+
+func TestFormatFileSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatFileSize(512))
+	assert.Equal(t, "2.0 KB", formatFileSize(2048))
+	assert.Equal(t, "3.2 GB", formatFileSize(3435973837))
+}
+
+func TestOcrMetadataFieldValues(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalFields := ocrMetadataFields
+	defer func() { ocrMetadataFields = originalFields }()
+	ocrMetadataFields = map[string]int{"pages": 3, "file_size": 4, "model": 5, "language": 9}
+
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 3, "name": "Pages", "data_type": "integer"},
+			{"id": 4, "name": "File size", "data_type": "string"},
+			{"id": 5, "name": "OCR model", "data_type": "string"}
+		], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/1/metadata/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"original_size": 204800, "original_mime_type": "application/pdf"}`))
+	})
+
+	app := &App{Client: env.client}
+	values, err := app.ocrMetadataFieldValues(context.Background(), 1, ocrMetadata{Pages: 2, Model: "minicpm-v", Languages: []string{"de"}})
+	require.NoError(t, err)
+	// Field 9 does not exist and is skipped
+	assert.Equal(t, []CustomFieldValue{
+		{Field: 3, Value: int64(2)},
+		{Field: 4, Value: "200.0 KB"},
+		{Field: 5, Value: "minicpm-v"},
+	}, values)
+}
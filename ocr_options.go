@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ocrProviderNames are the providers createVisionLLM can create a vision model for
+var ocrProviderNames = []string{"openai", "ollama", "googleai", "anthropic"}
+
+// maxOcrPageNumber bounds page ranges, so a typo cannot render thousands of pages
+const maxOcrPageNumber = 1000
+
+// OcrJobOptions are the options of a single OCR job, sent with POST /api/documents/:id/ocr. Unset options
+// fall back to the environment.
+type OcrJobOptions struct {
+	LimitPages int    `json:"limit_pages,omitempty"` // Overrides OCR_LIMIT_PAGES, 0 keeps it
+	Pages      string `json:"pages,omitempty"`       // Pages to process, e.g. "1-3,5", instead of the first pages
+
+	// Provider and Model select another vision model than VISION_LLM_PROVIDER and VISION_LLM_MODEL
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// validate checks the options before the job is queued
+func (o OcrJobOptions) validate() error {
+	if o.LimitPages < 0 {
+		return errors.New("limit_pages must not be negative")
+	}
+	if o.LimitPages > maxOcrPageNumber {
+		return fmt.Errorf("invalid limit_pages %d, pages are numbered from 1 to %d", o.LimitPages, maxOcrPageNumber)
+	}
+	if o.overridesVisionModel() {
+		if visionLlmProvider == "" {
+			return errors.New("OCR is not enabled, set VISION_LLM_PROVIDER")
+		}
+		provider, model := o.visionModel()
+		if !slices.Contains(ocrProviderNames, provider) {
+			return fmt.Errorf("provider %q cannot be used for OCR, supported are: %s", provider, strings.Join(ocrProviderNames, ", "))
+		}
+		if !slices.Contains(configuredProviders(), provider) {
+			return fmt.Errorf("provider %q is not configured", provider)
+		}
+		if model == "" {
+			return fmt.Errorf("model is required for provider %q, which is not VISION_LLM_PROVIDER", provider)
+		}
+	}
+	if o.Pages == "" {
+		return nil
+	}
+	if o.LimitPages > 0 {
+		return errors.New("limit_pages and pages cannot be combined")
+	}
+	_, err := parsePageRanges(o.Pages)
+	return err
+}
+
+// pageLimit is the number of pages to render: up to the last selected page, LimitPages or OCR_LIMIT_PAGES
+func (o OcrJobOptions) pageLimit() int {
+	if pages, err := parsePageRanges(o.Pages); err == nil && len(pages) > 0 {
+		return pages[len(pages)-1] + 1
+	}
+	if o.LimitPages > 0 {
+		return o.LimitPages
+	}
+	return limitOcrPages
+}
+
+// selectPages returns the indexes of the rendered pages to process
+func (o OcrJobOptions) selectPages(rendered int) ([]int, error) {
+	if o.Pages == "" {
+		pages := make([]int, rendered)
+		for i := range pages {
+			pages[i] = i
+		}
+		return pages, nil
+	}
+	pages, err := parsePageRanges(o.Pages)
+	if err != nil {
+		return nil, err
+	}
+	if last := pages[len(pages)-1]; last >= rendered {
+		return nil, fmt.Errorf("page %d is beyond the %d pages of the document", last+1, rendered)
+	}
+	return pages, nil
+}
+
+// parsePageRanges parses 1-based page numbers and ranges like "1-3,5" into sorted, unique 0-based indexes
+func parsePageRanges(spec string) ([]int, error) {
+	var pages []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid page %q in pages", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+				return nil, fmt.Errorf("invalid page range %q in pages", part)
+			}
+		}
+		if start < 1 || end < start || end > maxOcrPageNumber {
+			return nil, fmt.Errorf("invalid page range %q in pages, pages are numbered from 1 to %d", part, maxOcrPageNumber)
+		}
+		for page := start; page <= end; page++ {
+			pages = append(pages, page-1)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("pages selects no page")
+	}
+	slices.Sort(pages)
+	return slices.Compact(pages), nil
+}
+
+// overridesVisionModel reports whether the job selects its own provider or model
+func (o OcrJobOptions) overridesVisionModel() bool {
+	return o.Provider != "" || o.Model != ""
+}
+
+// visionModel returns the provider and model of the job. A provider other than VISION_LLM_PROVIDER has
+// no default model.
+func (o OcrJobOptions) visionModel() (provider string, model string) {
+	provider = strings.ToLower(o.Provider)
+	if provider == "" {
+		provider = strings.ToLower(visionLlmProvider)
+	}
+	model = o.Model
+	if model == "" && provider == strings.ToLower(visionLlmProvider) {
+		model = visionLlmModel
+	}
+	return provider, model
+}
+
+// maxJobVisionModels bounds the vision models kept for OCR jobs, the oldest one is dropped first
+const maxJobVisionModels = 8
+
+// jobVisionModels caches the vision models created for OCR jobs with their own provider or model
+var jobVisionModels = struct {
+	sync.Mutex
+	models map[string]llms.Model
+	order  []string // Keys in the order the models were created
+}{models: map[string]llms.Model{}}
+
+// cachedJobVisionModel returns the cached vision model of a job, or nil
+func cachedJobVisionModel(key string) llms.Model {
+	jobVisionModels.Lock()
+	defer jobVisionModels.Unlock()
+	return jobVisionModels.models[key]
+}
+
+// cacheJobVisionModel keeps a vision model for later jobs and returns the cached one if another job created
+// it first
+func cacheJobVisionModel(key string, model llms.Model) llms.Model {
+	jobVisionModels.Lock()
+	defer jobVisionModels.Unlock()
+	if cached, ok := jobVisionModels.models[key]; ok {
+		return cached
+	}
+	for len(jobVisionModels.order) >= maxJobVisionModels {
+		delete(jobVisionModels.models, jobVisionModels.order[0])
+		jobVisionModels.order = jobVisionModels.order[1:]
+	}
+	jobVisionModels.models[key] = model
+	jobVisionModels.order = append(jobVisionModels.order, key)
+	return model
+}
+
+// jobProviderLog is the provider log the vision models of OCR jobs are logged to, nil if disabled
+var jobProviderLog *providerLog
+
+// jobVisionModel is a vision model selected by an OCR job
+type jobVisionModel struct {
+	model    llms.Model
+	provider string
+	name     string
+}
+
+// jobVisionModelKey is the context key of the vision model selected by an OCR job
+type jobVisionModelKey struct{}
+
+// withJobVisionModel returns a context in which the pages are transcribed with the vision model of the job
+func (app *App) withJobVisionModel(ctx context.Context, options OcrJobOptions) (context.Context, error) {
+	if !options.overridesVisionModel() {
+		return ctx, nil
+	}
+	provider, name := options.visionModel()
+	if provider == strings.ToLower(visionLlmProvider) && name == visionLlmModel {
+		return ctx, nil
+	}
+
+	key := provider + "/" + name
+	model := cachedJobVisionModel(key)
+	if model == nil {
+		// Created on first use, without holding the cache, as checking the model asks the provider
+		if err := checkJobVisionModel(ctx, provider, name); err != nil {
+			return nil, err
+		}
+		// A job must not download models to the Ollama server, even with OLLAMA_AUTO_PULL
+		created, err := newVisionLLM(provider, name, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vision model %s: %w", key, err)
+		}
+		if created == nil {
+			return nil, fmt.Errorf("provider %q cannot be used for OCR", provider)
+		}
+		if jobProviderLog != nil {
+			created = &loggingLLM{Model: created, log: jobProviderLog, kind: "ocr", provider: provider, model: name}
+		}
+		model = cacheJobVisionModel(key, created)
+	}
+	return context.WithValue(ctx, jobVisionModelKey{}, jobVisionModel{model: model, provider: provider, name: name}), nil
+}
+
+// checkJobVisionModel checks that a job selects a model configured for the provider or offered by it
+func checkJobVisionModel(ctx context.Context, provider string, name string) error {
+	configured := [][2]string{
+		{visionLlmProvider, visionLlmModel},
+		{consensusVisionProvider, consensusVisionModel},
+		{handwritingProvider, handwritingModel},
+		{llmProvider, llmModel},
+		{shadowLlmProvider, shadowLlmModel},
+	}
+	for _, pair := range configured {
+		if strings.EqualFold(pair[0], provider) && pair[1] == name {
+			return nil
+		}
+	}
+
+	models, err := listProviderModels(ctx, provider)
+	if err != nil {
+		return fmt.Errorf("cannot check model %q of provider %q: %w", name, provider, err)
+	}
+	if provider == "ollama" && !strings.Contains(name, ":") {
+		name += ":latest"
+	}
+	if !slices.Contains(models, name) {
+		return fmt.Errorf("provider %q does not offer model %q", provider, name)
+	}
+	return nil
+}
+
+// visionModel returns the vision model pages are transcribed with, the one of the OCR job if it selected one
+func (app *App) visionModel(ctx context.Context) (llms.Model, string, string) {
+	if selected, ok := ctx.Value(jobVisionModelKey{}).(jobVisionModel); ok {
+		return selected.model, selected.provider, selected.name
+	}
+	return app.VisionLLM, visionLlmProvider, visionLlmModel
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	pages, err := parsePageRanges("5, 1-3,2")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 4}, pages)
+
+	for _, spec := range []string{"", ",", "0", "3-1", "a", "1-b", "1-1001"} {
+		_, err := parsePageRanges(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestOcrJobOptions(t *testing.T) {
+	originalLimit := limitOcrPages
+	limitOcrPages = 5
+	defer func() { limitOcrPages = originalLimit }()
+
+	assert.NoError(t, OcrJobOptions{}.validate())
+	assert.NoError(t, OcrJobOptions{Pages: "8-9"}.validate())
+	assert.Error(t, OcrJobOptions{LimitPages: -1}.validate())
+	assert.Error(t, OcrJobOptions{LimitPages: maxOcrPageNumber + 1}.validate())
+	assert.NoError(t, OcrJobOptions{LimitPages: maxOcrPageNumber}.validate())
+	assert.Error(t, OcrJobOptions{LimitPages: 2, Pages: "1"}.validate())
+	assert.Error(t, OcrJobOptions{Pages: "x"}.validate())
+
+	assert.Equal(t, 5, OcrJobOptions{}.pageLimit())
+	assert.Equal(t, 12, OcrJobOptions{LimitPages: 12}.pageLimit())
+	assert.Equal(t, 9, OcrJobOptions{Pages: "2,8-9"}.pageLimit())
+
+	pages, err := OcrJobOptions{}.selectPages(3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, pages)
+
+	pages, err = OcrJobOptions{Pages: "2,8-9"}.selectPages(9)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 7, 8}, pages)
+
+	_, err = OcrJobOptions{Pages: "2,8-9"}.selectPages(8)
+	assert.Error(t, err)
+}
+
+func TestOcrJobVisionModel(t *testing.T) {
+	originalLLMProvider, originalProvider, originalModel := llmProvider, visionLlmProvider, visionLlmModel
+	llmProvider, visionLlmProvider, visionLlmModel = "ollama", "openai", "gpt-4o"
+	defer func() {
+		llmProvider, visionLlmProvider, visionLlmModel = originalLLMProvider, originalProvider, originalModel
+	}()
+
+	assert.NoError(t, OcrJobOptions{Model: "gpt-4o-mini"}.validate())
+	assert.NoError(t, OcrJobOptions{Provider: "Ollama", Model: "minicpm-v"}.validate())
+	assert.Error(t, OcrJobOptions{Provider: "ollama"}.validate())
+	assert.Error(t, OcrJobOptions{Provider: "mistral_ocr", Model: "latest"}.validate())
+
+	llmProvider = "openai"
+	assert.Error(t, OcrJobOptions{Provider: "ollama", Model: "minicpm-v"}.validate(), "ollama is not configured")
+
+	provider, model := OcrJobOptions{Model: "gpt-4o-mini"}.visionModel()
+	assert.Equal(t, "openai", provider)
+	assert.Equal(t, "gpt-4o-mini", model)
+
+	// Without a selection, and for the configured model, the pages are transcribed with VisionLLM
+	app := &App{VisionLLM: &mockLLM{}}
+	ctx, err := app.withJobVisionModel(context.Background(), OcrJobOptions{Provider: "openai", Model: "gpt-4o"})
+	require.NoError(t, err)
+	selected, provider, model := app.visionModel(ctx)
+	assert.Same(t, app.VisionLLM, selected)
+	assert.Equal(t, "openai", provider)
+	assert.Equal(t, "gpt-4o", model)
+
+	// Models created for a job are cached
+	cached := &mockLLM{}
+	assert.Same(t, cached, cacheJobVisionModel("ollama/minicpm-v", cached))
+	assert.Same(t, cached, cacheJobVisionModel("ollama/minicpm-v", &mockLLM{}), "the first created model is kept")
+	defer func() {
+		jobVisionModels.Lock()
+		delete(jobVisionModels.models, "ollama/minicpm-v")
+		jobVisionModels.order = nil
+		jobVisionModels.Unlock()
+	}()
+
+	ctx, err = app.withJobVisionModel(context.Background(), OcrJobOptions{Provider: "ollama", Model: "minicpm-v"})
+	require.NoError(t, err)
+	selected, provider, model = app.visionModel(ctx)
+	assert.Same(t, cached, selected)
+	assert.Equal(t, "ollama", provider)
+	assert.Equal(t, "minicpm-v", model)
+}
+
+func TestCheckJobVisionModel(t *testing.T) {
+	originalLLMProvider, originalLLMModel := llmProvider, llmModel
+	llmProvider, llmModel = "googleai", "gemini-1.5-pro"
+	defer func() { llmProvider, llmModel = originalLLMProvider, originalLLMModel }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Fatalf("unexpected path %s, models must not be pulled", r.URL.Path)
+		}
+		w.Write([]byte(`{"models": [{"name": "minicpm-v:latest"}, {"name": "llava:13b"}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	ctx := context.Background()
+	assert.NoError(t, checkJobVisionModel(ctx, "ollama", "minicpm-v"))
+	assert.NoError(t, checkJobVisionModel(ctx, "ollama", "llava:13b"))
+	assert.ErrorContains(t, checkJobVisionModel(ctx, "ollama", "llava:70b"), "does not offer")
+	assert.NoError(t, checkJobVisionModel(ctx, "googleai", "gemini-1.5-pro"), "configured models are allowed")
+}
+
+func TestJobVisionModelCacheBound(t *testing.T) {
+	defer func() {
+		jobVisionModels.Lock()
+		jobVisionModels.models, jobVisionModels.order = map[string]llms.Model{}, nil
+		jobVisionModels.Unlock()
+	}()
+
+	for i := 0; i <= maxJobVisionModels; i++ {
+		cacheJobVisionModel(fmt.Sprintf("ollama/model-%d", i), &mockLLM{})
+	}
+	assert.Len(t, jobVisionModels.models, maxJobVisionModels)
+	assert.Nil(t, cachedJobVisionModel("ollama/model-0"), "the oldest model is dropped")
+	assert.NotNil(t, cachedJobVisionModel(fmt.Sprintf("ollama/model-%d", maxJobVisionModels)))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/googleai"
+)
+
+func encodeTestPage(t *testing.T, draw func(x, y int) color.Gray) []byte {
+	img := image.NewGray(image.Rect(0, 0, 200, 280))
+	for y := 0; y < 280; y++ {
+		for x := 0; x < 200; x++ {
+			img.SetGray(x, y, draw(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestPageVariance(t *testing.T) {
+	blank := encodeTestPage(t, func(x, y int) color.Gray {
+		// Slight scanner noise on an otherwise white page
+		return color.Gray{Y: uint8(250 + (x*y)%3)}
+	})
+	text := encodeTestPage(t, func(x, y int) color.Gray {
+		// Lines of "text" every 20 pixels
+		if y%20 < 6 && x > 20 && x < 180 {
+			return color.Gray{Y: 10}
+		}
+		return color.Gray{Y: 255}
+	})
+
+	blankVariance, err := pageVariance(blank)
+	require.NoError(t, err)
+	textVariance, err := pageVariance(text)
+	require.NoError(t, err)
+
+	assert.Less(t, blankVariance, 50.0)
+	assert.Greater(t, textVariance, 1000.0)
+
+	_, err = pageVariance([]byte("not an image"))
+	assert.Error(t, err)
+}
+
+func TestDoOCRViaLLM_PageContext(t *testing.T) {
+	originalTemplate, originalProvider := ocrTemplate, visionLlmProvider
+	ocrTemplate = template.Must(template.New("ocr").Parse("Page {{.PageNumber}} of {{.TotalPages}} of a {{.DocumentType}} from {{.Correspondent}}"))
+	visionLlmProvider = "ollama"
+	defer func() { ocrTemplate, visionLlmProvider = originalTemplate, originalProvider }()
+
+	llm := &scriptedLLM{responses: []string{"Balance: 100 EUR"}}
+	app := &App{VisionLLM: llm}
+
+	page := ocrPageContext{Correspondent: "ACME Bank", DocumentType: "Bank Statement", PageNumber: 2, TotalPages: 3}
+	text, err := app.doOCRViaLLM(context.Background(), providerTestImage(), page, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	assert.Equal(t, "Balance: 100 EUR", text)
+
+	parts := llm.conversations[0][0].Parts
+	require.Len(t, parts, 2)
+	assert.Equal(t, "Page 2 of 3 of a Bank Statement from ACME Bank", parts[1].(llms.TextContent).Text)
+}
+
+func TestJoinPageTexts(t *testing.T) {
+	original := ocrStripHeaders
+	defer func() { ocrStripHeaders = original }()
+	pages := []string{"ACME GmbH\nInvoice 42\nPage 1 of 2", "ACME GmbH\nTerms apply\nPage 2 of 2"}
+
+	ocrStripHeaders = false
+	assert.Equal(t, "ACME GmbH\nInvoice 42\nPage 1 of 2\n\nACME GmbH\nTerms apply\nPage 2 of 2", joinPageTexts(pages))
+
+	ocrStripHeaders = true
+	assert.Equal(t, "Invoice 42\n\nTerms apply", joinPageTexts(pages))
+}
+
+func TestCreateVisionLLM_GoogleAI(t *testing.T) {
+	originalKey := googleaiAPIKey
+	defer func() { googleaiAPIKey = originalKey }()
+
+	googleaiAPIKey = ""
+	_, err := createVisionLLM("googleai", "gemini-1.5-flash")
+	assert.Error(t, err)
+
+	googleaiAPIKey = "test-key"
+	model, err := createVisionLLM("GoogleAI", "gemini-1.5-flash")
+	require.NoError(t, err)
+	require.IsType(t, &providerErrorLLM{}, model)
+	assert.IsType(t, &googleai.GoogleAI{}, model.(*providerErrorLLM).Model)
+}
+
+func TestDoOCRViaLLM_GoogleAIBinaryImage(t *testing.T) {
+	originalTemplate, originalProvider := ocrTemplate, visionLlmProvider
+	ocrTemplate = template.Must(template.New("ocr").Parse("Transcribe the page"))
+	visionLlmProvider = "googleai"
+	defer func() { ocrTemplate, visionLlmProvider = originalTemplate, originalProvider }()
+
+	llm := &scriptedLLM{responses: []string{"Dear customer"}}
+	app := &App{VisionLLM: llm}
+
+	_, err := app.doOCRViaLLM(context.Background(), providerTestImage(), ocrPageContext{PageNumber: 1, TotalPages: 1}, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+
+	// Gemini receives the image as inline data rather than as a data URL
+	part, ok := llm.conversations[0][0].Parts[0].(llms.BinaryContent)
+	require.True(t, ok)
+	assert.Equal(t, "image/jpeg", part.MIMEType)
+}
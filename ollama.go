@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ollamaHost returns the URL of the Ollama server from OLLAMA_HOST
+func ollamaHost() string {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		host = "http://127.0.0.1:11434"
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// ensureOllamaModel pulls the model on the Ollama server unless it is already present.
+// Progress is logged in steps of 10% since large models take minutes to download.
+func ensureOllamaModel(ctx context.Context, httpClient *http.Client, host string, model string) error {
+	present, err := ollamaModelPresent(ctx, httpClient, host, model)
+	if err != nil {
+		return err
+	}
+	if present {
+		return nil
+	}
+
+	log.Infof("Ollama model %s is not present, pulling it from %s", model, host)
+	body, _ := json.Marshal(map[string]interface{}{"model": model, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, "POST", host+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The provider timeout is meant for generation requests, not for downloading gigabytes
+	pullClient := *httpClient
+	pullClient.Timeout = 0
+	resp, err := pullClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pulling Ollama model %s: %w", model, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error pulling Ollama model %s: status %d", model, resp.StatusCode)
+	}
+
+	// The pull API streams one JSON object per line until the status is "success"
+	lastLogged := -1
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var progress struct {
+			Status    string `json:"status"`
+			Error     string `json:"error"`
+			Total     int64  `json:"total"`
+			Completed int64  `json:"completed"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			continue
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("error pulling Ollama model %s: %s", model, progress.Error)
+		}
+		if progress.Status == "success" {
+			log.Infof("Pulled Ollama model %s", model)
+			return nil
+		}
+		if progress.Total > 0 {
+			percent := int(progress.Completed * 100 / progress.Total)
+			if percent/10 != lastLogged/10 {
+				lastLogged = percent
+				log.Infof("Pulling Ollama model %s: %s %d%%", model, progress.Status, percent)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error pulling Ollama model %s: %w", model, err)
+	}
+	return fmt.Errorf("error pulling Ollama model %s: download ended before completion", model)
+}
+
+// ollamaModelPresent checks the local models of the Ollama server; a model without tag matches ":latest"
+func ollamaModelPresent(ctx context.Context, httpClient *http.Client, host string, model string) (bool, error) {
+	models, err := listOllamaModels(ctx, httpClient, host)
+	if err != nil {
+		return false, err
+	}
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+	return slices.Contains(models, model), nil
+}
+
+// listOllamaModels returns the names of the models present on the Ollama server
+func listOllamaModels(ctx context.Context, httpClient *http.Client, host string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", host+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error listing Ollama models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error listing Ollama models: status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("error parsing Ollama models: %w", err)
+	}
+	models := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureOllamaModel(t *testing.T) {
+	pulled := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models": [{"name": "llama3:latest"}, {"name": "minicpm-v:8b"}]}`))
+		case "/api/pull":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			pulled = append(pulled, body["model"].(string))
+			w.Write([]byte(`{"status": "pulling manifest"}
+{"status": "pulling 6a0746a1ec1a", "total": 1000, "completed": 500}
+{"status": "pulling 6a0746a1ec1a", "total": 1000, "completed": 1000}
+{"status": "success"}
+`))
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	require.NoError(t, ensureOllamaModel(context.Background(), server.Client(), server.URL, "llama3"))
+	require.NoError(t, ensureOllamaModel(context.Background(), server.Client(), server.URL, "minicpm-v:8b"))
+	assert.Empty(t, pulled)
+
+	require.NoError(t, ensureOllamaModel(context.Background(), server.Client(), server.URL, "qwen2.5:7b"))
+	assert.Equal(t, []string{"qwen2.5:7b"}, pulled)
+}
+
+func TestEnsureOllamaModel_PullError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			w.Write([]byte(`{"models": []}`))
+			return
+		}
+		w.Write([]byte(`{"error": "pull model manifest: file does not exist"}` + "\n"))
+	}))
+	defer server.Close()
+
+	err := ensureOllamaModel(context.Background(), server.Client(), server.URL, "does-not-exist")
+	assert.ErrorContains(t, err, "file does not exist")
+}
+
+func TestListOllamaModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		w.Write([]byte(`{"models": [{"name": "llama3:latest"}, {"name": "minicpm-v:8b"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := listOllamaModels(context.Background(), server.Client(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama3:latest", "minicpm-v:8b"}, models)
+}
@@ -8,12 +8,15 @@ import (
 	"image/jpeg"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gen2brain/go-fitz"
 	"golang.org/x/sync/errgroup"
@@ -66,14 +69,32 @@ func NewPaperlessClient(baseURL, apiToken string) *PaperlessClient {
 	}
 }
 
-// Do method to make requests to the Paperless-NGX API
+// Do method to make requests to the Paperless-NGX API. Answers with 401 or 403 are returned as paperlessAuthError.
 func (client *PaperlessClient) Do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	resp, err := client.DoRaw(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, &paperlessAuthError{StatusCode: resp.StatusCode, Method: method, Path: path}
+	}
+	return resp, nil
+}
+
+// DoRaw makes a request to the Paperless-NGX API like Do, but returns every answer as is, e.g. for proxying
+// a 403 of a user without the permission
+func (client *PaperlessClient) DoRaw(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
 	url := fmt.Sprintf("%s/%s", client.BaseURL, strings.TrimLeft(path, "/"))
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", client.APIToken))
+	token := client.APIToken
+	if t, ok := tenantFromContext(ctx); ok && t.Token != "" {
+		token = t.Token
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
 
 	// Set Content-Type if body is present
 	if body != nil {
@@ -132,6 +153,39 @@ func (client *PaperlessClient) GetAllTags(ctx context.Context) (map[string]int,
 	return tagIDMapping, nil
 }
 
+// GetTags retrieves all tags including their color and document count
+func (client *PaperlessClient) GetTags(ctx context.Context) ([]Tag, error) {
+	tags := []Tag{}
+	path := "api/tags/"
+
+	for path != "" {
+		resp, err := client.Do(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("error fetching tags: %d, %s", resp.StatusCode, string(bodyBytes))
+		}
+
+		var tagsResponse struct {
+			Results []Tag  `json:"results"`
+			Next    string `json:"next"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tagsResponse); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tagsResponse.Results...)
+
+		// Extract relative path from the Next URL
+		path = strings.TrimPrefix(tagsResponse.Next, client.BaseURL+"/")
+	}
+
+	return tags, nil
+}
+
 // GetDocumentsByTags retrieves documents that match the specified tags
 func (client *PaperlessClient) GetDocumentsByTags(ctx context.Context, tags []string, pageSize int) ([]Document, error) {
 	tagQueries := make([]string, len(tags))
@@ -139,7 +193,76 @@ func (client *PaperlessClient) GetDocumentsByTags(ctx context.Context, tags []st
 		tagQueries[i] = fmt.Sprintf("tags__name__iexact=%s", tag)
 	}
 	searchQuery := strings.Join(tagQueries, "&")
-	path := fmt.Sprintf("api/documents/?%s&page_size=%d", urlEncode(searchQuery), pageSize)
+	return client.getDocuments(ctx, fmt.Sprintf("%s&page_size=%d", urlEncode(searchQuery), pageSize))
+}
+
+// GetDocumentsByQuery retrieves documents matching arbitrary paperless-ngx document filter parameters
+func (client *PaperlessClient) GetDocumentsByQuery(ctx context.Context, query url.Values, pageSize int) ([]Document, error) {
+	rawQuery := fmt.Sprintf("page_size=%d", pageSize)
+	if len(query) > 0 {
+		rawQuery = fmt.Sprintf("%s&%s", query.Encode(), rawQuery)
+	}
+	return client.getDocuments(ctx, rawQuery)
+}
+
+// GetDocumentCount returns the number of documents matching the given document filter parameters
+func (client *PaperlessClient) GetDocumentCount(ctx context.Context, query url.Values) (int, error) {
+	rawQuery := "page_size=1"
+	if len(query) > 0 {
+		rawQuery = fmt.Sprintf("%s&%s", query.Encode(), rawQuery)
+	}
+
+	resp, err := client.Do(ctx, "GET", fmt.Sprintf("api/documents/?%s", rawQuery), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("error counting documents: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var documentsResponse struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&documentsResponse); err != nil {
+		return 0, err
+	}
+	return documentsResponse.Count, nil
+}
+
+// GetDocumentIDs returns the IDs of all documents matching the given document filter parameters
+func (client *PaperlessClient) GetDocumentIDs(ctx context.Context, query url.Values) ([]int, error) {
+	rawQuery := "page_size=1"
+	if len(query) > 0 {
+		rawQuery = fmt.Sprintf("%s&%s", query.Encode(), rawQuery)
+	}
+
+	resp, err := client.Do(ctx, "GET", fmt.Sprintf("api/documents/?%s", rawQuery), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching document IDs: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	// The list endpoint returns the IDs of all matching documents besides the first page
+	var documentsResponse struct {
+		All []int `json:"all"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&documentsResponse); err != nil {
+		return nil, err
+	}
+	return documentsResponse.All, nil
+}
+
+// getDocuments fetches the first page of documents for a raw query string and resolves tag and correspondent names
+func (client *PaperlessClient) getDocuments(ctx context.Context, rawQuery string) ([]Document, error) {
+	path := fmt.Sprintf("api/documents/?%s", rawQuery)
 
 	resp, err := client.Do(ctx, "GET", path, nil)
 	if err != nil {
@@ -191,11 +314,19 @@ func (client *PaperlessClient) GetDocumentsByTags(ctx context.Context, tags []st
 		}
 
 		documents = append(documents, Document{
-			ID:            result.ID,
-			Title:         result.Title,
-			Content:       result.Content,
-			Correspondent: correspondentName,
-			Tags:          tagNames,
+			ID:             result.ID,
+			Title:          result.Title,
+			Content:        result.Content,
+			Correspondent:  correspondentName,
+			Tags:           tagNames,
+			CustomFields:   result.CustomFields,
+			DocumentTypeID: optionalID(result.DocumentType),
+			StoragePathID:  optionalID(result.StoragePath),
+			PageCount:      result.PageCount,
+			CreatedDate:    documentCreatedDate(result.Created, result.CreatedDate),
+			Modified:       result.Modified,
+
+			OriginalFileName: result.OriginalFileName,
 		})
 	}
 
@@ -269,11 +400,19 @@ func (client *PaperlessClient) GetDocument(ctx context.Context, documentID int)
 	}
 
 	return Document{
-		ID:            documentResponse.ID,
-		Title:         documentResponse.Title,
-		Content:       documentResponse.Content,
-		Correspondent: correspondentName,
-		Tags:          tagNames,
+		ID:             documentResponse.ID,
+		Title:          documentResponse.Title,
+		Content:        documentResponse.Content,
+		Correspondent:  correspondentName,
+		Tags:           tagNames,
+		CustomFields:   documentResponse.CustomFields,
+		DocumentTypeID: optionalID(documentResponse.DocumentType),
+		StoragePathID:  optionalID(documentResponse.StoragePath),
+		PageCount:      documentResponse.PageCount,
+		CreatedDate:    documentCreatedDate(documentResponse.Created, documentResponse.CreatedDate),
+		Modified:       documentResponse.Modified,
+
+		OriginalFileName: documentResponse.OriginalFileName,
 	}, nil
 }
 
@@ -304,6 +443,8 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 		}
 	}
 
+	tagChanges := map[string]*tagChangeGroup{}
+	var tagChangeOrder []string
 	for _, document := range documents {
 		documentID := document.ID
 
@@ -332,7 +473,7 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 			// We have suggested tags to change
 			originalFields["tags"] = originalTags
 			// remove autoTag to prevent infinite loop - this is required in case of undo
-			tags = removeTagFromList(tags, autoTag)
+			tags = removeTagFromList(tags, settings().AutoTag)
 
 			// remove duplicates
 			slices.Sort(tags)
@@ -349,7 +490,7 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 		for _, tagName := range tags {
 			if tagID, exists := availableTags[tagName]; exists {
 				// Skip the tag that we are filtering
-				if !isUndo && tagName == manualTag {
+				if !isUndo && tagName == settings().ManualTag {
 					continue
 				}
 				newTags = append(newTags, tagID)
@@ -359,13 +500,26 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 		}
 		updatedFields["tags"] = newTags
 
+		// Never apply (or create) a blacklisted correspondent, even if the client sent one
+		if isCorrespondentBlacklisted(document.SuggestedCorrespondent) {
+			log.Warnf("Suggested correspondent '%s' for document %d is blacklisted, skipping.", document.SuggestedCorrespondent, documentID)
+			document.SuggestedCorrespondent = ""
+		}
+
 		// Map suggested correspondent names to IDs
 		if document.SuggestedCorrespondent != "" {
 			if correspondentID, exists := availableCorrespondents[document.SuggestedCorrespondent]; exists {
 				updatedFields["correspondent"] = correspondentID
+			} else if correspondentApproval && !isUndo {
+				// Leave the correspondent unset until a user approves creating it
+				if err := QueuePendingCorrespondent(db, document.SuggestedCorrespondent, tenantUsername(ctx), documentID); err != nil {
+					log.Errorf("Error queueing correspondent %s for approval: %v", document.SuggestedCorrespondent, err)
+					return err
+				}
+				log.Infof("Queued new correspondent %s for approval (document %d)", document.SuggestedCorrespondent, documentID)
 			} else {
 				newCorrespondent := instantiateCorrespondent(document.SuggestedCorrespondent)
-				newCorrespondentID, err := client.CreateCorrespondent(context.Background(), newCorrespondent)
+				newCorrespondentID, err := client.CreateCorrespondent(ctx, newCorrespondent)
 				if err != nil {
 					log.Errorf("Error creating correspondent with name %s: %v\n", document.SuggestedCorrespondent, err)
 					return err
@@ -375,10 +529,43 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 			}
 		}
 
+		// Suggested custom fields are merged into the existing ones since paperless replaces the whole list
+		var originalCustomFieldsJSON, updatedCustomFieldsJSON []byte
+		if document.SuggestedCustomFields != nil {
+			customFields := document.SuggestedCustomFields
+			if !isUndo {
+				customFields = mergeCustomFields(document.OriginalDocument.CustomFields, document.SuggestedCustomFields)
+			}
+			originalCustomFieldsJSON, err = json.Marshal(document.OriginalDocument.CustomFields)
+			if err != nil {
+				log.Errorf("Error marshalling JSON for document %d: %v", documentID, err)
+				return err
+			}
+			updatedCustomFieldsJSON, err = json.Marshal(customFields)
+			if err != nil {
+				log.Errorf("Error marshalling JSON for document %d: %v", documentID, err)
+				return err
+			}
+			originalFields["custom_fields"] = document.OriginalDocument.CustomFields
+			updatedFields["custom_fields"] = customFields
+		}
+
 		suggestedTitle := document.SuggestedTitle
 		if len(suggestedTitle) > 128 {
 			suggestedTitle = suggestedTitle[:128]
 		}
+		if suggestedTitle != "" && titleDedupe != "" && !isUndo && suggestedTitle != document.OriginalDocument.Title {
+			correspondent := document.OriginalDocument.Correspondent
+			if _, ok := updatedFields["correspondent"]; ok {
+				correspondent = document.SuggestedCorrespondent
+			}
+			uniqueTitle, err := client.uniqueTitle(ctx, document.OriginalDocument, suggestedTitle, correspondent)
+			if err != nil {
+				log.Warnf("Could not check title of document %d for duplicates: %v", documentID, err)
+			} else {
+				suggestedTitle = uniqueTitle
+			}
+		}
 		if suggestedTitle != "" {
 			originalFields["title"] = document.OriginalDocument.Title
 			updatedFields["title"] = suggestedTitle
@@ -386,6 +573,17 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 			log.Warnf("No valid title found for document %d, skipping.", documentID)
 		}
 
+		// Suggested created date, a plain date that paperless-ngx places in its own timezone
+		if document.SuggestedCreatedDate != "" {
+			if _, err := time.Parse("2006-01-02", document.SuggestedCreatedDate); err != nil {
+				log.Warnf("Suggested created date '%s' for document %d is not a date as YYYY-MM-DD, skipping.", document.SuggestedCreatedDate, documentID)
+			} else {
+				field := createdDateField()
+				originalFields[field] = document.OriginalDocument.CreatedDate
+				updatedFields[field] = document.SuggestedCreatedDate
+			}
+		}
+
 		// Suggested Content
 		suggestedContent := document.SuggestedContent
 		if suggestedContent != "" {
@@ -395,76 +593,284 @@ func (client *PaperlessClient) UpdateDocuments(ctx context.Context, documents []
 		log.Debugf("Document %d: Original fields: %v", documentID, originalFields)
 		log.Debugf("Document %d: Updated fields: %v Tags: %v", documentID, updatedFields, tags)
 
-		// Marshal updated fields to JSON
-		jsonData, err := json.Marshal(updatedFields)
-		if err != nil {
-			log.Errorf("Error marshalling JSON for document %d: %v", documentID, err)
-			return err
+		update := documentUpdate{
+			document:                 document,
+			originalFields:           originalFields,
+			updatedFields:            updatedFields,
+			tags:                     tags,
+			originalTagsJSON:         string(originalTagsJSON),
+			updatedTagsJSON:          string(updatedTagsJSON),
+			originalCustomFieldsJSON: string(originalCustomFieldsJSON),
+			updatedCustomFieldsJSON:  string(updatedCustomFieldsJSON),
 		}
 
-		// Send the update request using the generic Do method
-		path := fmt.Sprintf("api/documents/%d/", documentID)
-		resp, err := client.Do(ctx, "PATCH", path, bytes.NewBuffer(jsonData))
-		if err != nil {
-			log.Errorf("Error updating document %d: %v", documentID, err)
+		// Documents that only change tags are collected, identical changes are sent as one bulk edit below
+		if len(updatedFields) == 1 {
+			var originalTagIDs []int
+			for _, tagName := range document.OriginalDocument.Tags {
+				if tagID, exists := availableTags[tagName]; exists {
+					originalTagIDs = append(originalTagIDs, tagID)
+				}
+			}
+			add, remove := tagIDDelta(originalTagIDs, newTags)
+			key := fmt.Sprintf("%v-%v", add, remove)
+			group, exists := tagChanges[key]
+			if !exists {
+				group = &tagChangeGroup{add: add, remove: remove}
+				tagChanges[key] = group
+				tagChangeOrder = append(tagChangeOrder, key)
+			}
+			group.updates = append(group.updates, update)
+			continue
+		}
+
+		if err := client.patchDocument(ctx, db, update, isUndo); err != nil {
 			return err
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			log.Errorf("Error updating document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
-			return fmt.Errorf("error updating document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
-		} else {
-			for field, value := range originalFields {
-				log.Printf("Document %d: Updated %s from %v to %v", documentID, field, originalFields[field], value)
-				// Insert the modification record into the database
-				var modificationRecord ModificationHistory
-				if field == "tags" {
-					// Make sure we only store changes where tags are changed - not the same before and after
-					// And we have to use tags, not updatedFields as they are IDs not fields
-					if !hasSameTags(document.OriginalDocument.Tags, tags) {
-						modificationRecord = ModificationHistory{
-							DocumentID:    uint(documentID),
-							ModField:      field,
-							PreviousValue: string(originalTagsJSON),
-							NewValue:      string(updatedTagsJSON),
-						}
-					}
-				} else {
-					// Only store mod if field actually changed
-					if originalFields[field] != updatedFields[field] {
-						modificationRecord = ModificationHistory{
-							DocumentID:    uint(documentID),
-							ModField:      field,
-							PreviousValue: fmt.Sprintf("%v", originalFields[field]),
-							NewValue:      fmt.Sprintf("%v", updatedFields[field]),
-						}
-					}
+	for _, key := range tagChangeOrder {
+		group := tagChanges[key]
+		if len(group.updates) < bulkEditMinDocuments {
+			for _, update := range group.updates {
+				if err := client.patchDocument(ctx, db, update, isUndo); err != nil {
+					return err
 				}
+			}
+			continue
+		}
+
+		documentIDs := make([]int, 0, len(group.updates))
+		for _, update := range group.updates {
+			documentIDs = append(documentIDs, update.document.ID)
+		}
+		if err := client.bulkEditTagIDs(ctx, documentIDs, group.add, group.remove); err != nil {
+			log.Errorf("Error updating tags of documents %v: %v", documentIDs, err)
+			return err
+		}
+		for _, update := range group.updates {
+			if err := client.recordDocumentUpdate(ctx, db, update, isUndo); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// bulkEditMinDocuments is the number of documents sharing the same tag change from which UpdateDocuments
+// sends a single bulk edit instead of one PATCH per document
+const bulkEditMinDocuments = 5
+
+// documentUpdate is the change of a single document prepared by UpdateDocuments
+type documentUpdate struct {
+	document       DocumentSuggestion
+	originalFields map[string]interface{}
+	updatedFields  map[string]interface{}
+	tags           []string
+
+	// JSON of the lists, as stored in the modification history
+	originalTagsJSON, updatedTagsJSON                 string
+	originalCustomFieldsJSON, updatedCustomFieldsJSON string
+}
+
+// tagChangeGroup collects the documents that add and remove the same tags
+type tagChangeGroup struct {
+	add, remove []int
+	updates     []documentUpdate
+}
+
+// tagIDDelta returns the tags to add and to remove to get from the original to the updated tags, sorted
+func tagIDDelta(original, updated []int) (add, remove []int) {
+	add, remove = []int{}, []int{}
+	for _, tagID := range updated {
+		if !slices.Contains(original, tagID) && !slices.Contains(add, tagID) {
+			add = append(add, tagID)
+		}
+	}
+	for _, tagID := range original {
+		if !slices.Contains(updated, tagID) && !slices.Contains(remove, tagID) {
+			remove = append(remove, tagID)
+		}
+	}
+	slices.Sort(add)
+	slices.Sort(remove)
+	return add, remove
+}
+
+// patchDocument sends the change of a single document and records it
+func (client *PaperlessClient) patchDocument(ctx context.Context, db *gorm.DB, update documentUpdate, isUndo bool) error {
+	documentID := update.document.ID
+
+	// Marshal updated fields to JSON
+	jsonData, err := json.Marshal(update.updatedFields)
+	if err != nil {
+		log.Errorf("Error marshalling JSON for document %d: %v", documentID, err)
+		return err
+	}
+
+	// Send the update request using the generic Do method
+	path := fmt.Sprintf("api/documents/%d/", documentID)
+	resp, err := client.Do(ctx, "PATCH", path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Errorf("Error updating document %d: %v", documentID, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		log.Errorf("Error updating document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("error updating document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+	}
+	recordOwnModification(documentID, time.Now())
+	return client.recordDocumentUpdate(ctx, db, update, isUndo)
+}
 
-				// Only store if we have a valid modification record
-				if (modificationRecord != ModificationHistory{}) {
-					err = InsertModification(db, &modificationRecord)
+// recordDocumentUpdate stores the modification history and the processing note of an applied change
+func (client *PaperlessClient) recordDocumentUpdate(ctx context.Context, db *gorm.DB, update documentUpdate, isUndo bool) error {
+	document := update.document
+	documentID := document.ID
+	originalFields, updatedFields := update.originalFields, update.updatedFields
+
+	var changedFields []string
+	for field, value := range originalFields {
+		log.Printf("Document %d: Updated %s from %v to %v", documentID, field, originalFields[field], value)
+		// Insert the modification record into the database
+		var modificationRecord ModificationHistory
+		if field == "tags" {
+			// Make sure we only store changes where tags are changed - not the same before and after
+			// And we have to use tags, not updatedFields as they are IDs not fields
+			if !hasSameTags(document.OriginalDocument.Tags, update.tags) {
+				modificationRecord = ModificationHistory{
+					DocumentID:    uint(documentID),
+					ModField:      field,
+					PreviousValue: update.originalTagsJSON,
+					NewValue:      update.updatedTagsJSON,
 				}
-				if err != nil {
-					log.Errorf("Error inserting modification record for document %d: %v", documentID, err)
-					return err
+			}
+		} else if field == "custom_fields" {
+			if update.originalCustomFieldsJSON != update.updatedCustomFieldsJSON {
+				modificationRecord = ModificationHistory{
+					DocumentID:    uint(documentID),
+					ModField:      field,
+					PreviousValue: update.originalCustomFieldsJSON,
+					NewValue:      update.updatedCustomFieldsJSON,
+				}
+			}
+		} else {
+			// Only store mod if field actually changed
+			if originalFields[field] != updatedFields[field] {
+				modificationRecord = ModificationHistory{
+					DocumentID:    uint(documentID),
+					ModField:      field,
+					PreviousValue: fmt.Sprintf("%v", originalFields[field]),
+					NewValue:      fmt.Sprintf("%v", updatedFields[field]),
 				}
 			}
 		}
 
-		log.Printf("Document %d updated successfully.", documentID)
+		// Only store if we have a valid modification record
+		if (modificationRecord != ModificationHistory{}) {
+			modificationRecord.Username = tenantUsername(ctx)
+			if err := InsertModification(db, &modificationRecord); err != nil {
+				log.Errorf("Error inserting modification record for document %d: %v", documentID, err)
+				return err
+			}
+			changedFields = append(changedFields, field)
+		}
 	}
 
+	if _, ok := updatedFields["correspondent"]; ok && document.SuggestedCorrespondent != document.OriginalDocument.Correspondent {
+		changedFields = append(changedFields, "correspondent")
+	}
+	if note := processingNote(changedFields, isUndo, noteDetail(ctx), time.Now()); processingNotes && note != "" {
+		// The note is informational, so a failure does not fail the update
+		if err := client.AddNote(ctx, documentID, note); err != nil {
+			log.Warnf("Failed to add processing note to document %d: %v", documentID, err)
+		}
+	}
+
+	log.Printf("Document %d updated successfully.", documentID)
 	return nil
 }
 
+// uniqueTitle returns the title with a suffix if another document of the same correspondent already has it.
+// TITLE_DEDUPE selects the suffix: "date" uses the created date of the document, "counter" (and "date" if the
+// dated title is taken as well) appends the first free number starting at 2.
+func (client *PaperlessClient) uniqueTitle(ctx context.Context, document Document, title string, correspondent string) (string, error) {
+	taken, err := client.titleTaken(ctx, document.ID, title, correspondent)
+	if err != nil || !taken {
+		return title, err
+	}
+
+	if titleDedupe == "date" && document.CreatedDate != "" {
+		candidate := titleWithSuffix(title, document.CreatedDate)
+		if taken, err = client.titleTaken(ctx, document.ID, candidate, correspondent); err != nil || !taken {
+			return candidate, err
+		}
+	}
+
+	for counter := 2; counter <= maxTitleCounter; counter++ {
+		candidate := titleWithSuffix(title, strconv.Itoa(counter))
+		if taken, err = client.titleTaken(ctx, document.ID, candidate, correspondent); err != nil || !taken {
+			return candidate, err
+		}
+	}
+	return title, fmt.Errorf("no free title suffix up to %d", maxTitleCounter)
+}
+
+// maxTitleCounter limits the numbered title variants tried by uniqueTitle
+const maxTitleCounter = 100
+
+// titleWithSuffix appends " (suffix)" and shortens the title so the result fits the paperless-ngx limit of 128 characters
+func titleWithSuffix(title string, suffix string) string {
+	suffix = fmt.Sprintf(" (%s)", suffix)
+	if len(title)+len(suffix) > 128 {
+		title = strings.ToValidUTF8(title[:128-len(suffix)], "")
+	}
+	return title + suffix
+}
+
+// titleTaken reports whether a document other than documentID with the same correspondent has the title (case-insensitive)
+func (client *PaperlessClient) titleTaken(ctx context.Context, documentID int, title string, correspondent string) (bool, error) {
+	query := url.Values{}
+	query.Set("title__iexact", title)
+	if correspondent != "" {
+		query.Set("correspondent__name__iexact", correspondent)
+	} else {
+		query.Set("correspondent__isnull", "1")
+	}
+
+	documents, err := client.GetDocumentsByQuery(ctx, query, 2)
+	if err != nil {
+		return false, err
+	}
+	for _, document := range documents {
+		if document.ID != documentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// imageDirKey is the context key for a directory DownloadDocumentAsImages renders into instead of the shared cache
+type imageDirKey struct{}
+
+// withImageDir makes DownloadDocumentAsImages render into dir, e.g. a private temporary directory whose pages
+// can be removed without affecting OCR of the same document
+func withImageDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, imageDirKey{}, dir)
+}
+
 // DownloadDocumentAsImages downloads the PDF file of the specified document and converts it to images
 // If limitPages > 0, only the first N pages will be processed
 func (client *PaperlessClient) DownloadDocumentAsImages(ctx context.Context, documentId int, limitPages int) ([]string, error) {
-	// Create a directory named after the document ID
-	docDir := filepath.Join(client.GetCacheFolder(), fmt.Sprintf("document-%d", documentId))
+	// Create a directory named after the document ID, unless the caller renders into its own directory
+	docDir := filepath.Join(client.GetCacheFolder(), tenantCacheDir(ctx), fmt.Sprintf("document-%d", documentId))
+	if dir, ok := ctx.Value(imageDirKey{}).(string); ok {
+		docDir = dir
+	}
 	if _, err := os.Stat(docDir); os.IsNotExist(err) {
 		err = os.MkdirAll(docDir, 0755)
 		if err != nil {
@@ -503,26 +909,22 @@ func (client *PaperlessClient) DownloadDocumentAsImages(ctx context.Context, doc
 		return nil, fmt.Errorf("error downloading document %d: %d, %s", documentId, resp.StatusCode, string(bodyBytes))
 	}
 
-	pdfData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
+	// Stream the PDF to disk instead of holding large documents in memory
 	tmpFile, err := os.CreateTemp("", "document-*.pdf")
 	if err != nil {
 		return nil, err
 	}
 	defer os.Remove(tmpFile.Name())
 
-	_, err = tmpFile.Write(pdfData)
+	_, err = io.Copy(tmpFile, resp.Body)
+	tmpFile.Close()
 	if err != nil {
 		return nil, err
 	}
-	tmpFile.Close()
 
 	doc, err := fitz.New(tmpFile.Name())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", errPDFCorrupt, err)
 	}
 	defer doc.Close()
 
@@ -533,17 +935,34 @@ func (client *PaperlessClient) DownloadDocumentAsImages(ctx context.Context, doc
 
 	var mu sync.Mutex
 	var g errgroup.Group
+	stats := renderStatsFrom(ctx)
 
 	for n := 0; n < totalPages; n++ {
 		n := n // capture loop variable
 		g.Go(func() error {
 			mu.Lock()
 			// I assume the libmupdf library is not thread-safe
-			img, err := doc.Image(n)
+			bounds, err := doc.Bound(n)
 			mu.Unlock()
+			if err != nil {
+				return fmt.Errorf("%w: page %d: %w", errPDFCorrupt, n+1, err)
+			}
+
+			// Wait until the page image fits into PDF_RENDER_MEMORY_MB, it is held until written to disk
+			reserved, err := renderMemory.acquire(ctx, pageRenderBytes(bounds))
 			if err != nil {
 				return err
 			}
+			defer renderMemory.release(reserved)
+			stats.add(pageRenderBytes(bounds))
+			defer stats.add(-pageRenderBytes(bounds))
+
+			mu.Lock()
+			img, err := doc.Image(n)
+			mu.Unlock()
+			if err != nil {
+				return fmt.Errorf("%w: page %d: %w", errPDFCorrupt, n+1, err)
+			}
 
 			imagePath := filepath.Join(docDir, fmt.Sprintf("page%03d.jpg", n))
 			f, err := os.Create(imagePath)
@@ -596,6 +1015,40 @@ func (client *PaperlessClient) GetCacheFolder() string {
 	return client.CacheFolder
 }
 
+// optionalID converts a nullable ID from the paperless-ngx API into an int, returning 0 for null
+func optionalID(value interface{}) int {
+	if id, ok := value.(float64); ok {
+		return int(id)
+	}
+	return 0
+}
+
+// documentCreatedDate returns the created date of a document as YYYY-MM-DD. Before paperless-ngx 2.16, created
+// is a date and time in the timezone of paperless-ngx and created_date its date; newer versions return created
+// as a date and may drop created_date.
+func documentCreatedDate(created string, createdDate string) string {
+	if createdDate != "" {
+		return createdDate
+	}
+	if _, err := time.Parse("2006-01-02", created); err == nil {
+		return created
+	}
+	if parsed, err := time.Parse(time.RFC3339, created); err == nil {
+		// The offset is the one of paperless-ngx, so the date is taken as written instead of converting it
+		return parsed.Format("2006-01-02")
+	}
+	return ""
+}
+
+// createdDateField returns the field that sets the created date of a document: created since paperless-ngx
+// 2.16, which treats it as a date, and created_date before
+func createdDateField() string {
+	if hasFeature("created_as_date") {
+		return "created"
+	}
+	return "created_date"
+}
+
 // urlEncode encodes a string for safe URL usage
 func urlEncode(s string) string {
 	return strings.ReplaceAll(s, " ", "+")
@@ -683,3 +1136,399 @@ func (client *PaperlessClient) GetAllCorrespondents(ctx context.Context) (map[st
 
 	return correspondentIDMapping, nil
 }
+
+// GetAllDocumentTypes retrieves all document types from the Paperless-NGX API
+func (client *PaperlessClient) GetAllDocumentTypes(ctx context.Context) (map[string]int, error) {
+	documentTypeIDMapping := make(map[string]int)
+	path := "api/document_types/?page_size=9999"
+
+	resp, err := client.Do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching document types: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var documentTypesResponse struct {
+		Results []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&documentTypesResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, documentType := range documentTypesResponse.Results {
+		documentTypeIDMapping[documentType.Name] = documentType.ID
+	}
+
+	return documentTypeIDMapping, nil
+}
+
+// GetStoragePaths retrieves all storage paths from the Paperless-NGX API, keyed by ID
+func (client *PaperlessClient) GetStoragePaths(ctx context.Context) (map[int]StoragePath, error) {
+	resp, err := client.Do(ctx, "GET", "api/storage_paths/?page_size=9999", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching storage paths: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var storagePathsResponse struct {
+		Results []StoragePath `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&storagePathsResponse); err != nil {
+		return nil, err
+	}
+
+	storagePaths := make(map[int]StoragePath, len(storagePathsResponse.Results))
+	for _, storagePath := range storagePathsResponse.Results {
+		storagePaths[storagePath.ID] = storagePath
+	}
+	return storagePaths, nil
+}
+
+// mergeCustomFields overlays the suggested custom field values onto the original ones, keeping the original order
+func mergeCustomFields(original, suggested []CustomFieldValue) []CustomFieldValue {
+	merged := make([]CustomFieldValue, 0, len(original)+len(suggested))
+	suggestedByField := make(map[int]CustomFieldValue, len(suggested))
+	for _, value := range suggested {
+		suggestedByField[value.Field] = value
+	}
+	for _, value := range original {
+		if replacement, exists := suggestedByField[value.Field]; exists {
+			merged = append(merged, replacement)
+			delete(suggestedByField, value.Field)
+		} else {
+			merged = append(merged, value)
+		}
+	}
+	for _, value := range suggested {
+		if _, pending := suggestedByField[value.Field]; pending {
+			merged = append(merged, value)
+			delete(suggestedByField, value.Field)
+		}
+	}
+	return merged
+}
+
+// GetCustomFields retrieves all custom field definitions from the Paperless-NGX API
+func (client *PaperlessClient) GetCustomFields(ctx context.Context) ([]CustomField, error) {
+	path := "api/custom_fields/?page_size=9999"
+
+	resp, err := client.Do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching custom fields: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var customFieldsResponse struct {
+		Results []CustomField `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&customFieldsResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return customFieldsResponse.Results, nil
+}
+
+// CreateTag creates a new tag in Paperless-NGX and returns its ID
+func (client *PaperlessClient) CreateTag(ctx context.Context, name string) (int, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"name":               name,
+		"matching_algorithm": 0,
+		"is_insensitive":     true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.Do(ctx, "POST", "api/tags/", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("error creating tag: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var createdTag struct {
+		ID int `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&createdTag)
+	if err != nil {
+		return 0, err
+	}
+
+	return createdTag.ID, nil
+}
+
+// ModifyDocumentTags adds and removes tags on a single document, creating tags to add that do not exist yet.
+// Unlike UpdateDocuments it reads the current tags from paperless, so concurrent tag changes are preserved.
+func (client *PaperlessClient) ModifyDocumentTags(ctx context.Context, documentID int, addTags, removeTags []string) error {
+	if len(addTags) == 0 && len(removeTags) == 0 {
+		return nil
+	}
+
+	availableTags, err := client.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("api/documents/%d/", documentID)
+	resp, err := client.Do(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error fetching document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+	}
+
+	var documentResponse GetDocumentApiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&documentResponse); err != nil {
+		return err
+	}
+
+	tagIDs := documentResponse.Tags
+	for _, tagName := range removeTags {
+		if tagID, exists := availableTags[tagName]; exists {
+			tagIDs = slices.DeleteFunc(tagIDs, func(id int) bool { return id == tagID })
+		}
+	}
+	for _, tagName := range addTags {
+		tagID, exists := availableTags[tagName]
+		if !exists {
+			tagID, err = client.CreateTag(ctx, tagName)
+			if err != nil {
+				return err
+			}
+			log.Infof("Created tag with name %s and ID %d", tagName, tagID)
+			availableTags[tagName] = tagID
+		}
+		if !slices.Contains(tagIDs, tagID) {
+			tagIDs = append(tagIDs, tagID)
+		}
+	}
+	if tagIDs == nil {
+		tagIDs = []int{}
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{"tags": tagIDs})
+	if err != nil {
+		return err
+	}
+
+	patchResp, err := client.Do(ctx, "PATCH", path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer patchResp.Body.Close()
+
+	if patchResp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(patchResp.Body)
+		return fmt.Errorf("error updating tags of document %d: %d, %s", documentID, patchResp.StatusCode, string(bodyBytes))
+	}
+	recordOwnModification(documentID, time.Now())
+
+	return nil
+}
+
+// BulkEditTags adds and removes tags on many documents with a single bulk_edit request, creating tags to add
+// that do not exist yet. Tags to remove that do not exist are ignored.
+func (client *PaperlessClient) BulkEditTags(ctx context.Context, documentIDs []int, addTags, removeTags []string) error {
+	if len(documentIDs) == 0 || (len(addTags) == 0 && len(removeTags) == 0) {
+		return nil
+	}
+
+	availableTags, err := client.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	addTagIDs := []int{}
+	for _, tagName := range addTags {
+		tagID, exists := availableTags[tagName]
+		if !exists {
+			tagID, err = client.CreateTag(ctx, tagName)
+			if err != nil {
+				return err
+			}
+			log.Infof("Created tag with name %s and ID %d", tagName, tagID)
+			availableTags[tagName] = tagID
+		}
+		addTagIDs = append(addTagIDs, tagID)
+	}
+	removeTagIDs := []int{}
+	for _, tagName := range removeTags {
+		if tagID, exists := availableTags[tagName]; exists {
+			removeTagIDs = append(removeTagIDs, tagID)
+		}
+	}
+	return client.bulkEditTagIDs(ctx, documentIDs, addTagIDs, removeTagIDs)
+}
+
+// bulkEditTagIDs adds and removes tags by ID on many documents with a single bulk_edit request
+func (client *PaperlessClient) bulkEditTagIDs(ctx context.Context, documentIDs []int, addTagIDs, removeTagIDs []int) error {
+	if len(documentIDs) == 0 || (len(addTagIDs) == 0 && len(removeTagIDs) == 0) {
+		return nil
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"documents": documentIDs,
+		"method":    "modify_tags",
+		"parameters": map[string]interface{}{
+			"add_tags":    addTagIDs,
+			"remove_tags": removeTagIDs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(ctx, "POST", "api/documents/bulk_edit/", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error editing tags of %d documents: %d, %s", len(documentIDs), resp.StatusCode, string(bodyBytes))
+	}
+	now := time.Now()
+	for _, documentID := range documentIDs {
+		recordOwnModification(documentID, now)
+	}
+
+	return nil
+}
+
+// savedViewFilterParams maps paperless-ngx saved view filter rule types to document list query parameters.
+// Rules with multiple values of the same type (e.g. several tags) are joined with commas.
+var savedViewFilterParams = map[int]struct {
+	param      string
+	nullParam  string // parameter used when the rule value is null
+	multiValue bool
+}{
+	0:  {param: "title__icontains"},
+	1:  {param: "content__icontains"},
+	2:  {param: "archive_serial_number"},
+	3:  {param: "correspondent__id", nullParam: "correspondent__isnull"},
+	4:  {param: "document_type__id", nullParam: "document_type__isnull"},
+	5:  {param: "is_in_inbox"},
+	6:  {param: "tags__id__all", multiValue: true},
+	7:  {param: "is_tagged"},
+	8:  {param: "created__date__lt"},
+	9:  {param: "created__date__gt"},
+	10: {param: "created__year"},
+	11: {param: "created__month"},
+	12: {param: "created__day"},
+	13: {param: "added__date__lt"},
+	14: {param: "added__date__gt"},
+	15: {param: "modified__date__lt"},
+	16: {param: "modified__date__gt"},
+	17: {param: "tags__id__none", multiValue: true},
+	18: {param: "archive_serial_number__isnull"},
+	19: {param: "title_content"},
+	20: {param: "query"},
+	21: {param: "more_like_id"},
+	22: {param: "tags__id__in", multiValue: true},
+	23: {param: "archive_serial_number__gt"},
+	24: {param: "archive_serial_number__lt"},
+	25: {param: "storage_path__id", nullParam: "storage_path__isnull"},
+	26: {param: "correspondent__id__in", multiValue: true},
+	27: {param: "correspondent__id__none", multiValue: true},
+	28: {param: "document_type__id__in", multiValue: true},
+	29: {param: "document_type__id__none", multiValue: true},
+	30: {param: "storage_path__id__in", multiValue: true},
+	31: {param: "storage_path__id__none", multiValue: true},
+	32: {param: "owner__id", nullParam: "owner__isnull"},
+	33: {param: "owner__id__in", multiValue: true},
+	34: {param: "owner__isnull"},
+	35: {param: "owner__id__none", multiValue: true},
+}
+
+// GetSavedViews retrieves all saved views from the Paperless-NGX API
+func (client *PaperlessClient) GetSavedViews(ctx context.Context) ([]SavedView, error) {
+	path := "api/saved_views/?page_size=9999"
+
+	resp, err := client.Do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching saved views: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var savedViewsResponse struct {
+		Results []SavedView `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&savedViewsResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return savedViewsResponse.Results, nil
+}
+
+// GetDocumentsBySavedView resolves the filter rules of a saved view server-side and retrieves the matching documents
+func (client *PaperlessClient) GetDocumentsBySavedView(ctx context.Context, viewID int, pageSize int) ([]Document, error) {
+	savedViews, err := client.GetSavedViews(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range savedViews {
+		if view.ID == viewID {
+			return client.GetDocumentsByQuery(ctx, savedViewQuery(view), pageSize)
+		}
+	}
+	return nil, fmt.Errorf("saved view %d not found", viewID)
+}
+
+// savedViewQuery translates the filter rules of a saved view into document list query parameters
+func savedViewQuery(view SavedView) url.Values {
+	query := url.Values{}
+	for _, rule := range view.FilterRules {
+		mapping, known := savedViewFilterParams[rule.RuleType]
+		if !known {
+			log.Warnf("Ignoring unsupported filter rule type %d of saved view %d", rule.RuleType, view.ID)
+			continue
+		}
+		if rule.Value == nil {
+			if mapping.nullParam != "" {
+				query.Set(mapping.nullParam, "1")
+			}
+			continue
+		}
+		if mapping.multiValue && query.Has(mapping.param) {
+			query.Set(mapping.param, query.Get(mapping.param)+","+*rule.Value)
+		} else {
+			query.Set(mapping.param, *rule.Value)
+		}
+	}
+	return query
+}
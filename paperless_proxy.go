@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// defaultPaperlessProxyPaths are the read-only paperless API paths the web app may request through
+// /api/paperless/ unless PAPERLESS_PROXY_PATHS is set. "*" matches a single path segment.
+var defaultPaperlessProxyPaths = []string{
+	"documents/*/",
+	"documents/*/thumb/",
+	"documents/*/preview/",
+	"documents/*/metadata/",
+	"tags/",
+	"correspondents/",
+	"document_types/",
+	"custom_fields/",
+}
+
+// proxiedResponseHeaders are the headers of paperless responses passed on to the browser
+var proxiedResponseHeaders = []string{"Content-Type", "Content-Disposition", "Cache-Control", "ETag", "Last-Modified"}
+
+// paperlessProxyPath cleans a requested paperless API path (relative to /api/) and reports whether it may
+// be proxied. Cleaning keeps "documents/1/../../users/" from escaping the allowlist.
+func paperlessProxyPath(requested string) (string, bool) {
+	apiPath := strings.TrimPrefix(path.Clean("/"+requested), "/") + "/"
+	patterns := paperlessProxyPaths
+	if len(patterns) == 0 {
+		patterns = defaultPaperlessProxyPaths
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.Trim(pattern, "/")+"/", apiPath); matched {
+			return apiPath, true
+		}
+	}
+	return apiPath, false
+}
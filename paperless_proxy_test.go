@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaperlessProxyPath(t *testing.T) {
+	originalPaths := paperlessProxyPaths
+	defer func() { paperlessProxyPaths = originalPaths }()
+
+	paperlessProxyPaths = nil
+	for requested, expected := range map[string]string{
+		"/documents/12/thumb/": "documents/12/thumb/",
+		"/documents/12/thumb":  "documents/12/thumb/",
+		"/documents/12/":       "documents/12/",
+		"/tags/":               "tags/",
+	} {
+		apiPath, ok := paperlessProxyPath(requested)
+		assert.True(t, ok, requested)
+		assert.Equal(t, expected, apiPath)
+	}
+	for _, requested := range []string{"/users/", "/documents/12/download/", "/documents/12/../../users/", "/documents/", "/"} {
+		_, ok := paperlessProxyPath(requested)
+		assert.False(t, ok, requested)
+	}
+
+	paperlessProxyPaths = []string{"/saved_views/"}
+	_, ok := paperlessProxyPath("/saved_views/")
+	assert.True(t, ok)
+	_, ok = paperlessProxyPath("/tags/")
+	assert.False(t, ok, "the configured paths replace the defaults")
+}
+
+func TestPaperlessProxyForbidden(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+	env.setMockResponse("/api/saved_views/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"detail": "You do not have permission to perform this action."}`))
+	})
+
+	// Do turns the answer into an error, the proxy passes it on
+	_, err := env.client.Do(context.Background(), "GET", "api/saved_views/", nil)
+	var authErr *paperlessAuthError
+	assert.ErrorAs(t, err, &authErr)
+
+	resp, err := env.client.DoRaw(context.Background(), "GET", "api/saved_views/", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -72,7 +74,7 @@ func InitializeTestDB() (*gorm.DB, error) {
 	}
 
 	// Migrate schema
-	err = db.AutoMigrate(&ModificationHistory{})
+	err = db.AutoMigrate(&ModificationHistory{}, &PendingCorrespondent{}, &DocumentExtraction{}, &Report{}, &BackfillCheckpoint{}, &OcrPageResult{}, &LLMTrace{}, &OcrJobResult{}, &Category{}, &TenantUser{}, &BacklogSample{}, &OcrCacheEntry{}, &SuggestionRecord{}, &ShadowResult{}, &ProcessingFailure{}, &ReportShare{}, &Job{})
 	if err != nil {
 		return nil, err
 	}
@@ -181,23 +183,25 @@ func TestGetDocumentsByTags(t *testing.T) {
 	// Mock data for documents
 	documentsResponse := GetDocumentsApiResponse{
 		Results: []struct {
-			ID                  int           `json:"id"`
-			Correspondent       int           `json:"correspondent"`
-			DocumentType        interface{}   `json:"document_type"`
-			StoragePath         interface{}   `json:"storage_path"`
-			Title               string        `json:"title"`
-			Content             string        `json:"content"`
-			Tags                []int         `json:"tags"`
-			Created             time.Time     `json:"created"`
-			CreatedDate         string        `json:"created_date"`
-			Modified            time.Time     `json:"modified"`
-			Added               time.Time     `json:"added"`
-			ArchiveSerialNumber interface{}   `json:"archive_serial_number"`
-			OriginalFileName    string        `json:"original_file_name"`
-			ArchivedFileName    string        `json:"archived_file_name"`
-			Owner               int           `json:"owner"`
-			UserCanChange       bool          `json:"user_can_change"`
-			Notes               []interface{} `json:"notes"`
+			ID                  int                `json:"id"`
+			Correspondent       int                `json:"correspondent"`
+			DocumentType        interface{}        `json:"document_type"`
+			StoragePath         interface{}        `json:"storage_path"`
+			Title               string             `json:"title"`
+			Content             string             `json:"content"`
+			Tags                []int              `json:"tags"`
+			Created             string             `json:"created"`
+			CreatedDate         string             `json:"created_date"`
+			Modified            time.Time          `json:"modified"`
+			Added               time.Time          `json:"added"`
+			ArchiveSerialNumber interface{}        `json:"archive_serial_number"`
+			OriginalFileName    string             `json:"original_file_name"`
+			ArchivedFileName    string             `json:"archived_file_name"`
+			Owner               int                `json:"owner"`
+			UserCanChange       bool               `json:"user_can_change"`
+			Notes               []interface{}      `json:"notes"`
+			CustomFields        []CustomFieldValue `json:"custom_fields"`
+			PageCount           int                `json:"page_count"`
 			SearchHit           struct {
 				Score          float64 `json:"score"`
 				Highlights     string  `json:"highlights"`
@@ -333,7 +337,7 @@ func TestUpdateDocuments(t *testing.T) {
 	}
 
 	// Set the manual tag
-	manualTag = "manual"
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "manual" })
 
 	// Set mock responses
 	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
@@ -451,3 +455,495 @@ func TestDownloadDocumentAsImages_ManyPages(t *testing.T) {
 		assert.Contains(t, imagePath, "tests/tmp/document-321/page")
 	}
 }
+
+// TestUpdateDocuments_BlacklistedCorrespondent verifies that blacklisted correspondents are never created or applied
+func TestUpdateDocuments_BlacklistedCorrespondent(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalBlackList := correspondentBlackList
+	defer func() { correspondentBlackList = originalBlackList }()
+	correspondentBlackList = []string{"John Doe"}
+
+	documents := []DocumentSuggestion{
+		{
+			ID: 1,
+			OriginalDocument: Document{
+				ID:    1,
+				Title: "Old Title",
+			},
+			SuggestedTitle:         "New Title",
+			SuggestedCorrespondent: "john doe",
+		},
+	}
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method, "blacklisted correspondent must not be created")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "Alpha"}]}`))
+	})
+	env.setMockResponse("/api/documents/1/", func(w http.ResponseWriter, r *http.Request) {
+		var updatedFields map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+		assert.NotContains(t, updatedFields, "correspondent")
+		assert.Equal(t, "New Title", updatedFields["title"])
+		w.WriteHeader(http.StatusOK)
+	})
+
+	err := env.client.UpdateDocuments(context.Background(), documents, env.db, false)
+	require.NoError(t, err)
+}
+
+// TestUpdateDocuments_CorrespondentApproval verifies that new correspondents are queued instead of created in approval mode
+func TestUpdateDocuments_CorrespondentApproval(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalApproval := correspondentApproval
+	defer func() { correspondentApproval = originalApproval }()
+	correspondentApproval = true
+
+	documents := []DocumentSuggestion{
+		{ID: 7, OriginalDocument: Document{ID: 7, Title: "Invoice"}, SuggestedCorrespondent: "Gamma"},
+		{ID: 8, OriginalDocument: Document{ID: 8, Title: "Letter"}, SuggestedCorrespondent: "Gamma"},
+		{ID: 9, OriginalDocument: Document{ID: 9, Title: "Receipt"}, SuggestedCorrespondent: "Alpha"},
+	}
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method, "correspondent must not be created before approval")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "Alpha"}]}`))
+	})
+	for _, document := range documents {
+		expectCorrespondent := document.SuggestedCorrespondent == "Alpha"
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", document.ID), func(w http.ResponseWriter, r *http.Request) {
+			var updatedFields map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+			if expectCorrespondent {
+				assert.Equal(t, float64(1), updatedFields["correspondent"])
+			} else {
+				assert.NotContains(t, updatedFields, "correspondent")
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+
+	err := env.client.UpdateDocuments(context.Background(), documents, env.db, false)
+	require.NoError(t, err)
+
+	pending, err := GetPendingCorrespondents(env.db, "")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "Gamma", pending[0].Name)
+	assert.Equal(t, []int{7, 8}, pending[0].GetDocumentIDs())
+
+	// Queueing the same document again must not duplicate it
+	require.NoError(t, QueuePendingCorrespondent(env.db, "Gamma", "", 7))
+	record, err := GetPendingCorrespondent(env.db, pending[0].ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{7, 8}, record.GetDocumentIDs())
+
+	// Other users neither see nor share the queue
+	_, err = GetPendingCorrespondent(env.db, pending[0].ID, "bob")
+	assert.Error(t, err)
+	require.NoError(t, QueuePendingCorrespondent(env.db, "Gamma", "bob", 12))
+	bobPending, err := GetPendingCorrespondents(env.db, "bob")
+	require.NoError(t, err)
+	require.Len(t, bobPending, 1)
+	assert.Equal(t, []int{12}, bobPending[0].GetDocumentIDs())
+	record, err = GetPendingCorrespondent(env.db, pending[0].ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{7, 8}, record.GetDocumentIDs())
+
+	require.NoError(t, DeletePendingCorrespondent(env.db, record))
+}
+
+// TestApprovePendingCorrespondentBlacklisted tests that a blacklisted name is neither created nor approved
+func TestApprovePendingCorrespondentBlacklisted(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalBlackList := correspondentBlackList
+	defer func() { correspondentBlackList = originalBlackList }()
+	correspondentBlackList = []string{"Spam Inc"}
+
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "no correspondent is created")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	require.NoError(t, QueuePendingCorrespondent(env.db, "Blacklist Gamma", "", 7))
+	pending, err := GetPendingCorrespondents(env.db, "")
+	require.NoError(t, err)
+	var record *PendingCorrespondent
+	for i := range pending {
+		if pending[i].Name == "Blacklist Gamma" {
+			record = &pending[i]
+		}
+	}
+	require.NotNil(t, record)
+	defer DeletePendingCorrespondent(env.db, record)
+
+	app := &App{Client: env.client, Database: env.db}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/pending-correspondents/:id/approve", app.approvePendingCorrespondentHandler)
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/pending-correspondents/%d/approve", record.ID), strings.NewReader(`{"name": "spam inc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	_, err = GetPendingCorrespondent(env.db, record.ID, "")
+	assert.NoError(t, err, "the pending correspondent is kept")
+}
+
+// TestGetCustomFields tests decoding of both legacy and current select option formats
+func TestGetCustomFields(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 1, "name": "Category", "data_type": "select", "extra_data": {"select_options": ["Car", "Home"]}},
+			{"id": 2, "name": "Insurer", "data_type": "select", "extra_data": {"select_options": [{"id": "x1", "label": "AOK"}]}},
+			{"id": 3, "name": "Amount", "data_type": "monetary", "extra_data": {"default_currency": "EUR"}}
+		]}`))
+	})
+
+	fields, err := env.client.GetCustomFields(context.Background())
+	require.NoError(t, err)
+	require.Len(t, fields, 3)
+
+	assert.Equal(t, []SelectOption{{Label: "Car", Value: 0}, {Label: "Home", Value: 1}}, fields[0].ExtraData.SelectOptions)
+	assert.Equal(t, []SelectOption{{Label: "AOK", Value: "x1"}}, fields[1].ExtraData.SelectOptions)
+	assert.Equal(t, "monetary", fields[2].DataType)
+	assert.Empty(t, fields[2].ExtraData.SelectOptions)
+}
+
+// TestUpdateDocuments_CustomFields verifies suggested custom fields are merged into the existing ones
+func TestUpdateDocuments_CustomFields(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	documents := []DocumentSuggestion{
+		{
+			ID: 3,
+			OriginalDocument: Document{
+				ID:           3,
+				CustomFields: []CustomFieldValue{{Field: 1, Value: 0}, {Field: 2, Value: "keep"}},
+			},
+			SuggestedCustomFields: []CustomFieldValue{{Field: 1, Value: 1}, {Field: 5, Value: "x1"}},
+		},
+	}
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/3/", func(w http.ResponseWriter, r *http.Request) {
+		var updatedFields map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"field": float64(1), "value": float64(1)},
+			map[string]interface{}{"field": float64(2), "value": "keep"},
+			map[string]interface{}{"field": float64(5), "value": "x1"},
+		}, updatedFields["custom_fields"])
+		w.WriteHeader(http.StatusOK)
+	})
+
+	err := env.client.UpdateDocuments(context.Background(), documents, env.db, false)
+	require.NoError(t, err)
+}
+
+// TestUpdateDocuments_BulkEdit verifies that identical tag changes of many documents are sent as one bulk edit
+func TestUpdateDocuments_BulkEdit(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "paperless-gpt-auto"}, {"id": 2, "name": "invoice"}], "next": null}`))
+	})
+	var bulkEdits []map[string]interface{}
+	env.setMockResponse("/api/documents/bulk_edit/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bulkEdits = append(bulkEdits, body)
+		w.WriteHeader(http.StatusOK)
+	})
+	var patched []int
+	documents := []DocumentSuggestion{}
+	for id := 1; id <= bulkEditMinDocuments+1; id++ {
+		documents = append(documents, DocumentSuggestion{
+			ID:               id,
+			OriginalDocument: Document{ID: id, Title: "Scan", Tags: []string{"paperless-gpt-auto", "invoice"}},
+			RemoveTags:       []string{"paperless-gpt-auto"},
+		})
+		env.setMockResponse(fmt.Sprintf("/api/documents/%d/", id), func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "PATCH", r.Method)
+			patched = append(patched, id)
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	// A document with a new title is still patched on its own
+	documents[0].SuggestedTitle = "Invoice ACME"
+
+	err := env.client.UpdateDocuments(context.Background(), documents, env.db, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, patched)
+	require.Len(t, bulkEdits, 1)
+	assert.Equal(t, map[string]interface{}{
+		"documents": []interface{}{float64(2), float64(3), float64(4), float64(5), float64(6)},
+		"method":    "modify_tags",
+		"parameters": map[string]interface{}{
+			"add_tags":    []interface{}{},
+			"remove_tags": []interface{}{float64(1)},
+		},
+	}, bulkEdits[0])
+}
+
+// TestUpdateDocuments_BulkEditSmallGroup verifies that a few documents are still patched one by one
+func TestUpdateDocuments_BulkEditSmallGroup(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "paperless-gpt-auto"}, {"id": 2, "name": "invoice"}], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/bulk_edit/", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a bulk edit must not be sent for fewer than bulkEditMinDocuments documents")
+		w.WriteHeader(http.StatusOK)
+	})
+	env.setMockResponse("/api/documents/1/", func(w http.ResponseWriter, r *http.Request) {
+		var updatedFields map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+		assert.Equal(t, map[string]interface{}{"tags": []interface{}{float64(2)}}, updatedFields)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	documents := []DocumentSuggestion{{
+		ID:               1,
+		OriginalDocument: Document{ID: 1, Tags: []string{"paperless-gpt-auto", "invoice"}},
+		RemoveTags:       []string{"paperless-gpt-auto"},
+	}}
+	require.NoError(t, env.client.UpdateDocuments(context.Background(), documents, env.db, false))
+}
+
+func TestTagIDDelta(t *testing.T) {
+	add, remove := tagIDDelta([]int{3, 1, 2}, []int{2, 4, 4})
+	assert.Equal(t, []int{4}, add)
+	assert.Equal(t, []int{1, 3}, remove)
+}
+
+// TestUpdateDocuments_CreatedDate verifies that the created date is written to the field of the paperless-ngx version
+func TestUpdateDocuments_CreatedDate(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalCapabilities := paperlessCapabilities
+	defer func() { paperlessCapabilities = originalCapabilities }()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	for version, field := range map[string]string{"2.15.3": "created_date", "2.16.0": "created"} {
+		paperlessCapabilities = newPaperlessCapabilities(version)
+		var updatedFields map[string]interface{}
+		env.setMockResponse("/api/documents/4/", func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+			w.WriteHeader(http.StatusOK)
+		})
+
+		documents := []DocumentSuggestion{{
+			ID:                   4,
+			OriginalDocument:     Document{ID: 4, CreatedDate: "2024-01-01"},
+			SuggestedCreatedDate: "2024-03-12",
+		}}
+		require.NoError(t, env.client.UpdateDocuments(context.Background(), documents, env.db, false))
+		assert.Equal(t, "2024-03-12", updatedFields[field], version)
+		assert.Len(t, updatedFields, 2, version) // tags and the created date
+	}
+}
+
+func TestDocumentCreatedDate(t *testing.T) {
+	// Before paperless-ngx 2.16
+	assert.Equal(t, "2024-03-12", documentCreatedDate("2024-03-12T00:00:00+01:00", "2024-03-12"))
+	// The date is kept in the timezone of paperless-ngx instead of being converted to UTC
+	assert.Equal(t, "2024-03-12", documentCreatedDate("2024-03-12T00:00:00+01:00", ""))
+	// Since paperless-ngx 2.16
+	assert.Equal(t, "2024-03-12", documentCreatedDate("2024-03-12", ""))
+	assert.Equal(t, "", documentCreatedDate("", ""))
+}
+
+// TestUpdateDocuments_TitleDedupe verifies that a title already used for the same correspondent gets a suffix
+func TestUpdateDocuments_TitleDedupe(t *testing.T) {
+	originalDedupe := titleDedupe
+	titleDedupe = "date"
+	defer func() { titleDedupe = originalDedupe }()
+
+	tests := []struct {
+		name          string
+		existing      []string
+		expectedTitle string
+	}{
+		{"unique title is kept", []string{"Contract"}, "Invoice"},
+		{"date suffix", []string{"Invoice"}, "Invoice (2024-03-12)"},
+		{"counter when dated title is taken", []string{"Invoice", "invoice (2024-03-12)", "Invoice (2)"}, "Invoice (3)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			defer env.teardown()
+
+			env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"results": [], "next": null}`))
+			})
+			env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "ACME", r.URL.Query().Get("correspondent__name__iexact"))
+				results := []map[string]interface{}{}
+				for i, title := range tc.existing {
+					if strings.EqualFold(title, r.URL.Query().Get("title__iexact")) {
+						results = append(results, map[string]interface{}{"id": 10 + i, "title": title, "tags": []int{}})
+					}
+				}
+				w.WriteHeader(http.StatusOK)
+				json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+			})
+
+			var updatedTitle interface{}
+			env.setMockResponse("/api/documents/3/", func(w http.ResponseWriter, r *http.Request) {
+				var updatedFields map[string]interface{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+				updatedTitle = updatedFields["title"]
+				w.WriteHeader(http.StatusOK)
+			})
+
+			documents := []DocumentSuggestion{{
+				ID:               3,
+				OriginalDocument: Document{ID: 3, Title: "scan_0003", Correspondent: "ACME", CreatedDate: "2024-03-12"},
+				SuggestedTitle:   "Invoice",
+			}}
+			err := env.client.UpdateDocuments(context.Background(), documents, env.db, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTitle, updatedTitle)
+		})
+	}
+}
+
+// TestModifyDocumentTags verifies tags are added and removed based on the current document state
+func TestModifyDocumentTags(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "ocr-done", body["name"])
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 9, "name": "ocr-done"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "invoice"}, {"id": 2, "name": "ocr-in-progress"}], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/4/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": 4, "tags": [1, 2]}`))
+			return
+		}
+		assert.Equal(t, "PATCH", r.Method)
+		var updatedFields map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&updatedFields))
+		assert.Equal(t, map[string]interface{}{"tags": []interface{}{float64(1), float64(9)}}, updatedFields)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	err := env.client.ModifyDocumentTags(context.Background(), 4, []string{"ocr-done"}, []string{"ocr-in-progress"})
+	require.NoError(t, err)
+}
+
+// TestGetDocumentsBySavedView verifies saved view filter rules are translated into document query parameters
+func TestGetDocumentsBySavedView(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/saved_views/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 7, "name": "Untagged 2023", "filter_rules": [
+				{"rule_type": 7, "value": "0"},
+				{"rule_type": 10, "value": "2023"},
+				{"rule_type": 22, "value": "1"},
+				{"rule_type": 22, "value": "2"},
+				{"rule_type": 3, "value": null},
+				{"rule_type": 999, "value": "ignored"}
+			]}
+		]}`))
+	})
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "correspondent__isnull=1&created__year=2023&is_tagged=0&tags__id__in=1%2C2&page_size=10", r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "title": "Invoice", "content": "", "tags": [], "correspondent": 0}]}`))
+	})
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	documents, err := env.client.GetDocumentsBySavedView(context.Background(), 7, 10)
+	require.NoError(t, err)
+	require.Len(t, documents, 1)
+	assert.Equal(t, "Invoice", documents[0].Title)
+
+	_, err = env.client.GetDocumentsBySavedView(context.Background(), 8, 10)
+	assert.Error(t, err)
+}
+
+// TestGetTags tests that GetTags returns colors and document counts across pages
+func TestGetTags(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"results": []map[string]interface{}{{"id": 3, "name": "tag3", "color": "#00ff00", "document_count": 0}},
+				"next":    nil,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{
+				{"id": 1, "name": "tag1", "color": "#ff0000", "document_count": 12},
+				{"id": 2, "name": "tag2", "color": "#0000ff", "document_count": 4},
+			},
+			"next": fmt.Sprintf("%s/api/tags/?page=2", env.server.URL),
+		})
+	})
+
+	tags, err := env.client.GetTags(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Tag{
+		{ID: 1, Name: "tag1", Color: "#ff0000", DocumentCount: 12},
+		{ID: 2, Name: "tag2", Color: "#0000ff", DocumentCount: 4},
+		{ID: 3, Name: "tag3", Color: "#00ff00", DocumentCount: 0},
+	}, tags)
+}
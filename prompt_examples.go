@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// promptExamplesDir holds the few-shot examples passed to the prompt templates as {{.Examples}}
+const promptExamplesDir = "prompts/examples"
+
+// promptExampleKinds are the prompts that receive examples, each stored in prompts/examples/<kind>.txt
+var promptExampleKinds = []string{"title", "tags", "correspondent"}
+
+// promptExamples holds the examples per tenant and kind, guarded by templateMutex. Tenants are keyed by
+// tenantCacheDir, so the examples of a multi-tenant user live in prompts/examples/user-<hash>.
+var promptExamples = map[string]map[string]string{}
+
+// tenantPromptExamples returns the examples of the tenant of the context. The caller holds templateMutex.
+func tenantPromptExamples(ctx context.Context) map[string]string {
+	return promptExamples[tenantCacheDir(ctx)]
+}
+
+// exampleExcerptLength is the number of characters of a document's content shown in an example
+const exampleExcerptLength = 400
+
+// exampleCandidates is the number of recently added documents examples are chosen from
+const exampleCandidates = 200
+
+// genericTitle matches titles paperless derives from file names of scanners, e.g. "scan_0001" or "IMG-20240101"
+var genericTitle = regexp.MustCompile(`(?i)^((scan|img|image|doc|document|file|untitled|page)[\s_-]*)?[\d\s_-]*(\.(pdf|jpe?g|png|tiff?))?$`)
+
+// PromptExampleOptions selects the documents examples are generated from
+type PromptExampleOptions struct {
+	Count int    `json:"count"` // Number of examples per prompt, default 5
+	Tag   string `json:"tag"`   // Only use documents with this tag, e.g. one marking reviewed documents
+}
+
+// isWellLabeled reports whether a document is a good example: it has a meaningful title, a correspondent,
+// tags besides the paperless-gpt workflow tags and enough content
+func isWellLabeled(document Document) bool {
+	return !genericTitle.MatchString(strings.TrimSpace(document.Title)) &&
+		document.Correspondent != "" &&
+		len(withoutStatusTags(document.Tags)) > 0 &&
+		len(strings.TrimSpace(document.Content)) >= 100
+}
+
+// selectExampleDocuments picks up to count well-labeled documents, preferring different correspondents so the
+// examples cover the variety of the archive
+func selectExampleDocuments(documents []Document, count int) []Document {
+	var selected, repeated []Document
+	seen := map[string]bool{}
+	for _, document := range documents {
+		if !isWellLabeled(document) {
+			continue
+		}
+		if seen[document.Correspondent] {
+			repeated = append(repeated, document)
+			continue
+		}
+		seen[document.Correspondent] = true
+		selected = append(selected, document)
+	}
+	selected = append(selected, repeated...)
+	return selected[:min(count, len(selected))]
+}
+
+// renderPromptExamples formats the example blocks of the selected documents per kind
+func renderPromptExamples(documents []Document) map[string]string {
+	blocks := map[string][]string{}
+	for i, document := range documents {
+		excerpt := strings.Join(strings.Fields(truncateRunes(normalizeContent(document.Content), exampleExcerptLength)), " ")
+		header := fmt.Sprintf("Example %d:\nContent: %s", i+1, excerpt)
+		blocks["title"] = append(blocks["title"], fmt.Sprintf("%s\nTitle: %s", header, document.Title))
+		blocks["tags"] = append(blocks["tags"], fmt.Sprintf("%s\nTitle: %s\nTags: %s", header, document.Title, strings.Join(withoutStatusTags(document.Tags), ", ")))
+		blocks["correspondent"] = append(blocks["correspondent"], fmt.Sprintf("%s\nCorrespondent: %s", header, document.Correspondent))
+	}
+	examples := map[string]string{}
+	for _, kind := range promptExampleKinds {
+		examples[kind] = strings.Join(blocks[kind], "\n\n")
+	}
+	return examples
+}
+
+// generatePromptExamples samples well-labeled documents from paperless and renders them as prompt examples
+func (app *App) generatePromptExamples(ctx context.Context, options PromptExampleOptions) (map[string]string, int, error) {
+	if options.Count <= 0 {
+		options.Count = 5
+	}
+	query := url.Values{"ordering": {"-added"}, "correspondent__isnull": {"false"}}
+	if options.Tag != "" {
+		query.Set("tags__name__iexact", options.Tag)
+	}
+	documents, err := app.Client.GetDocumentsByQuery(ctx, query, exampleCandidates)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching documents: %w", err)
+	}
+
+	selected := selectExampleDocuments(documents, options.Count)
+	if len(selected) == 0 {
+		return nil, 0, fmt.Errorf("none of the %d most recent documents is well labeled (title, correspondent and tags set)", len(documents))
+	}
+	return renderPromptExamples(selected), len(selected), nil
+}
+
+// savePromptExamples writes the examples of the tenant of the context to disk and makes them available to
+// the tenant's prompts
+func savePromptExamples(ctx context.Context, dir string, examples map[string]string) error {
+	tenantDir := tenantCacheDir(ctx)
+	dir = filepath.Join(dir, tenantDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	templateMutex.Lock()
+	defer templateMutex.Unlock()
+	stored := map[string]string{}
+	for _, kind := range promptExampleKinds {
+		if err := os.WriteFile(filepath.Join(dir, kind+".txt"), []byte(examples[kind]), 0644); err != nil {
+			return err
+		}
+		stored[kind] = examples[kind]
+	}
+	promptExamples[tenantDir] = stored
+	return nil
+}
+
+// loadAllPromptExamples reads the stored examples of the single-tenant setup from dir and those of each
+// multi-tenant user from its user-<hash> subdirectory
+func loadAllPromptExamples(dir string) (map[string]map[string]string, error) {
+	examples, err := loadPromptExamples(dir)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]map[string]string{"": examples}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "user-") {
+			continue
+		}
+		if all[entry.Name()], err = loadPromptExamples(filepath.Join(dir, entry.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
+}
+
+// loadPromptExamples reads the stored examples. Missing files mean there are no examples of that kind.
+func loadPromptExamples(dir string) (map[string]string, error) {
+	examples := map[string]string{}
+	for _, kind := range promptExampleKinds {
+		content, err := os.ReadFile(filepath.Join(dir, kind+".txt"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		examples[kind] = strings.TrimSpace(string(content))
+	}
+	return examples, nil
+}
+
+// runExamplesCommand implements the "examples" command line mode
+func runExamplesCommand(app *App, args []string) error {
+	var options PromptExampleOptions
+	flags := flag.NewFlagSet("examples", flag.ContinueOnError)
+	flags.IntVar(&options.Count, "count", 5, "number of example documents")
+	flags.StringVar(&options.Tag, "tag", "", "only use documents with this tag")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	examples, count, err := app.generatePromptExamples(context.Background(), options)
+	if err != nil {
+		return err
+	}
+	if err := savePromptExamples(context.Background(), promptExamplesDir, examples); err != nil {
+		return err
+	}
+	fmt.Printf("Stored %d examples in %s\n", count, promptExamplesDir)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWellLabeled(t *testing.T) {
+	content := strings.Repeat("Invoice for the delivery of office supplies. ", 5)
+	document := Document{Title: "Invoice ACME 2024-05", Correspondent: "ACME", Tags: []string{"Invoice"}, Content: content}
+	assert.True(t, isWellLabeled(document))
+
+	for _, title := range []string{"scan_0001", "IMG-20240101", "0001.pdf", ""} {
+		generic := document
+		generic.Title = title
+		assert.False(t, isWellLabeled(generic), title)
+	}
+
+	noCorrespondent := document
+	noCorrespondent.Correspondent = ""
+	assert.False(t, isWellLabeled(noCorrespondent))
+
+	onlyStatusTags := document
+	onlyStatusTags.Tags = []string{settings().ManualTag}
+	assert.False(t, isWellLabeled(onlyStatusTags))
+}
+
+func TestGeneratePromptExamples(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	content := strings.Repeat("Thank you for your order. ", 10)
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "name": "Invoice"}, {"id": 2, "name": "reviewed"}], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 3, "name": "ACME"}, {"id": 4, "name": "Globex"}], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "reviewed", r.URL.Query().Get("tags__name__iexact"))
+		assert.Equal(t, "false", r.URL.Query().Get("correspondent__isnull"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 1, "title": "Invoice ACME 2024-05", "correspondent": 3, "tags": [1, 2], "content": "` + content + `"},
+			{"id": 2, "title": "Invoice ACME 2024-04", "correspondent": 3, "tags": [1, 2], "content": "` + content + `"},
+			{"id": 3, "title": "scan_0003", "correspondent": 4, "tags": [1, 2], "content": "` + content + `"},
+			{"id": 4, "title": "Invoice Globex 2024-03", "correspondent": 4, "tags": [1, 2], "content": "` + content + `"}
+		], "next": null}`))
+	})
+
+	app := &App{Client: env.client}
+	examples, count, err := app.generatePromptExamples(context.Background(), PromptExampleOptions{Count: 2, Tag: "reviewed"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Different correspondents are preferred over more recent documents of the same correspondent
+	assert.Contains(t, examples["title"], "Title: Invoice ACME 2024-05")
+	assert.Contains(t, examples["title"], "Title: Invoice Globex 2024-03")
+	assert.NotContains(t, examples["title"], "2024-04")
+	assert.Contains(t, examples["tags"], "Tags: Invoice, reviewed")
+	assert.Contains(t, examples["correspondent"], "Correspondent: Globex")
+}
+
+func TestSaveAndLoadPromptExamples(t *testing.T) {
+	originalExamples := promptExamples
+	defer func() { promptExamples = originalExamples }()
+	promptExamples = map[string]map[string]string{}
+
+	dir := t.TempDir()
+	examples := map[string]string{"title": "Example 1:\nTitle: Invoice", "tags": "Example 1:\nTags: Invoice", "correspondent": ""}
+	require.NoError(t, savePromptExamples(context.Background(), dir, examples))
+	assert.Equal(t, "Example 1:\nTitle: Invoice", tenantPromptExamples(context.Background())["title"])
+
+	loaded, err := loadPromptExamples(dir)
+	require.NoError(t, err)
+	assert.Equal(t, examples, loaded)
+
+	loaded, err = loadPromptExamples(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestPromptExamplesPerTenant(t *testing.T) {
+	originalExamples := promptExamples
+	defer func() { promptExamples = originalExamples }()
+	promptExamples = map[string]map[string]string{}
+
+	dir := t.TempDir()
+	alice := withTenant(context.Background(), "alice", "token-a")
+	bob := withTenant(context.Background(), "bob", "token-b")
+	require.NoError(t, savePromptExamples(alice, dir, map[string]string{"title": "Example 1:\nTitle: Alice's invoice"}))
+
+	assert.Equal(t, "Example 1:\nTitle: Alice's invoice", tenantPromptExamples(alice)["title"])
+	assert.Empty(t, tenantPromptExamples(bob)["title"], "examples are not shared between users")
+	assert.Empty(t, tenantPromptExamples(context.Background())["title"])
+
+	loaded, err := loadAllPromptExamples(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Example 1:\nTitle: Alice's invoice", loaded[tenantCacheDir(alice)]["title"])
+	assert.Empty(t, loaded[""])
+}
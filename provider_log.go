@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// providerLogBackups is the number of rotated provider log files kept next to PROVIDER_LOG_FILE
+const providerLogBackups = 3
+
+// providerLogRedacted replaces secrets in provider log records
+const providerLogRedacted = "[redacted]"
+
+// providerLogSecretPatterns match API keys that may show up in prompts, answers or error messages
+var providerLogSecretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),               // OpenAI and Anthropic style keys
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{30,}`),              // Google API keys
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`), // Authorization headers
+	regexp.MustCompile(`(?i)([?&](?:api_?)?key=)[^&\s"]+`),      // Keys in query strings
+}
+
+// ProviderLogMessage is a message sent to a provider with image data reduced to its type and size
+type ProviderLogMessage struct {
+	Role  string   `json:"role"`
+	Text  string   `json:"text,omitempty"`
+	Parts []string `json:"parts,omitempty"` // Non-text parts, e.g. "image/jpeg, 183212 bytes"
+}
+
+// ProviderLogRecord is a line of PROVIDER_LOG_FILE describing one LLM or OCR request
+type ProviderLogRecord struct {
+	Time             time.Time            `json:"time"`
+	Kind             string               `json:"kind"` // "llm", "ocr", "ocr_consensus", "handwriting" or "shadow"
+	Provider         string               `json:"provider"`
+	Model            string               `json:"model"`
+	DocumentID       int                  `json:"document_id,omitempty"`
+	LatencyMs        int64                `json:"latency_ms"`
+	PromptTokens     int                  `json:"prompt_tokens"`
+	CompletionTokens int                  `json:"completion_tokens"`
+	TokensEstimated  bool                 `json:"tokens_estimated,omitempty"` // The provider reported no usage, the text tokens were counted locally
+	Messages         []ProviderLogMessage `json:"messages"`
+	Response         string               `json:"response,omitempty"`
+	Error            string               `json:"error,omitempty"`
+}
+
+// providerLog appends JSONL records to a file, rotating it once it exceeds maxBytes
+type providerLog struct {
+	sync.Mutex
+	path       string
+	maxBytes   int64
+	redactText bool     // Replace prompts and answers by their length (PROVIDER_LOG_REDACT_TEXT)
+	secrets    []string // Configured credentials, removed wherever they appear
+
+	file *os.File
+	size int64
+}
+
+// newProviderLog opens the provider log. The configured API keys and tokens are collected from the
+// environment so they can be redacted even if a provider echoes them.
+func newProviderLog(path string, maxBytes int64, redactText bool) (*providerLog, error) {
+	pl := &providerLog{path: path, maxBytes: maxBytes, redactText: redactText}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if len(value) >= 8 && isSecretVariable(name) {
+			pl.secrets = append(pl.secrets, value)
+		}
+	}
+	if err := pl.open(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// isSecretVariable reports whether an environment variable holds a credential
+func isSecretVariable(name string) bool {
+	for _, marker := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD"} {
+		if strings.Contains(strings.ToUpper(name), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// open opens the log file for appending and continues with its current size
+func (pl *providerLog) open() error {
+	file, err := os.OpenFile(pl.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open provider log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	pl.file, pl.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file to .1, shifting older backups and dropping the oldest
+func (pl *providerLog) rotate() error {
+	pl.file.Close()
+	for i := providerLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", pl.path, i), fmt.Sprintf("%s.%d", pl.path, i+1))
+	}
+	if err := os.Rename(pl.path, pl.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return pl.open()
+}
+
+// write appends a record, rotating the file first if the record would exceed its size
+func (pl *providerLog) write(record ProviderLogRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	pl.Lock()
+	defer pl.Unlock()
+	if pl.maxBytes > 0 && pl.size > 0 && pl.size+int64(len(line)) > pl.maxBytes {
+		if err := pl.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate provider log: %w", err)
+		}
+	}
+	n, err := pl.file.Write(line)
+	pl.size += int64(n)
+	return err
+}
+
+// redact removes credentials from a text and, with PROVIDER_LOG_REDACT_TEXT, the text itself
+func (pl *providerLog) redact(text string, isContent bool) string {
+	if isContent && pl.redactText {
+		return fmt.Sprintf("[%d characters]", len([]rune(text)))
+	}
+	for _, secret := range pl.secrets {
+		text = strings.ReplaceAll(text, secret, providerLogRedacted)
+	}
+	for _, pattern := range providerLogSecretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if prefix := pattern.FindStringSubmatch(match); len(prefix) > 1 {
+				return prefix[1] + providerLogRedacted
+			}
+			return providerLogRedacted
+		})
+	}
+	return text
+}
+
+// loggingLLM writes a provider log record for every request to the wrapped LLM (PROVIDER_LOG_FILE)
+type loggingLLM struct {
+	llms.Model
+	log      *providerLog
+	kind     string
+	provider string
+	model    string
+}
+
+// GenerateContent forwards to the wrapped LLM and logs the request with its latency and token usage
+func (m *loggingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	start := time.Now()
+	response, err := m.Model.GenerateContent(ctx, messages, options...)
+
+	record := ProviderLogRecord{
+		Time:      start,
+		Kind:      m.kind,
+		Provider:  m.provider,
+		Model:     m.model,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Messages:  make([]ProviderLogMessage, 0, len(messages)),
+	}
+	if documentID, ok := ctx.Value(traceDocumentKey{}).(int); ok {
+		record.DocumentID = documentID
+	}
+
+	var promptText strings.Builder
+	for _, message := range messages {
+		entry := ProviderLogMessage{Role: string(message.Role)}
+		for _, part := range message.Parts {
+			switch p := part.(type) {
+			case llms.TextContent:
+				entry.Text += p.Text
+				promptText.WriteString(p.Text)
+			case llms.BinaryContent:
+				entry.Parts = append(entry.Parts, fmt.Sprintf("%s, %d bytes", p.MIMEType, len(p.Data)))
+			case llms.ImageURLContent:
+				if strings.HasPrefix(p.URL, "data:") {
+					entry.Parts = append(entry.Parts, fmt.Sprintf("image data URL, %d bytes", len(p.URL)))
+				} else {
+					entry.Parts = append(entry.Parts, "image URL "+m.log.redact(p.URL, false))
+				}
+			default:
+				entry.Parts = append(entry.Parts, fmt.Sprintf("%T", part))
+			}
+		}
+		entry.Text = m.log.redact(entry.Text, true)
+		record.Messages = append(record.Messages, entry)
+	}
+
+	var answer string
+	if err != nil {
+		record.Error = m.log.redact(err.Error(), false)
+	} else if len(response.Choices) > 0 {
+		answer = response.Choices[0].Content
+		record.Response = m.log.redact(answer, true)
+		record.PromptTokens, record.CompletionTokens = reportedTokens(response.Choices[0].GenerationInfo)
+	}
+	if record.PromptTokens == 0 && record.CompletionTokens == 0 {
+		record.PromptTokens = llms.CountTokens(m.model, promptText.String())
+		record.CompletionTokens = llms.CountTokens(m.model, answer)
+		record.TokensEstimated = true
+	}
+
+	if logErr := m.log.write(record); logErr != nil {
+		log.Warnf("Failed to write provider log: %v", logErr)
+	}
+	return response, err
+}
+
+// Call forwards to GenerateContent so both entry points are logged
+func (m *loggingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// reportedTokens reads the token usage a provider returned, which langchaingo reports under provider specific keys
+func reportedTokens(info map[string]any) (prompt int, completion int) {
+	read := func(keys ...string) int {
+		for _, key := range keys {
+			switch value := info[key].(type) {
+			case int:
+				return value
+			case int32:
+				return int(value)
+			case int64:
+				return int(value)
+			case float64:
+				return int(value)
+			}
+		}
+		return 0
+	}
+	return read("PromptTokens", "InputTokens", "input_tokens", "prompt_eval_count"),
+		read("CompletionTokens", "OutputTokens", "output_tokens", "eval_count")
+}
+
+// enableProviderLog wraps all configured LLMs so their requests are written to the provider log
+func (app *App) enableProviderLog(pl *providerLog) {
+	wrap := func(model llms.Model, kind, provider, name string) llms.Model {
+		if model == nil {
+			return nil
+		}
+		return &loggingLLM{Model: model, log: pl, kind: kind, provider: provider, model: name}
+	}
+	app.LLM = wrap(app.LLM, "llm", llmProvider, llmModel)
+	app.VisionLLM = wrap(app.VisionLLM, "ocr", visionLlmProvider, visionLlmModel)
+	app.ConsensusVisionLLM = wrap(app.ConsensusVisionLLM, "ocr_consensus", consensusVisionProvider, consensusVisionModel)
+	app.HandwritingVisionLLM = wrap(app.HandwritingVisionLLM, "handwriting", handwritingProvider, handwritingModel)
+	app.ShadowLLM = wrap(app.ShadowLLM, "shadow", shadowLlmProvider, shadowLlmModel)
+	jobProviderLog = pl
+}
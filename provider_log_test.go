@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestProviderLogRedact(t *testing.T) {
+	pl := &providerLog{secrets: []string{"paperless-token-123"}}
+	assert.Equal(t, "token [redacted] used", pl.redact("token paperless-token-123 used", false))
+	assert.Equal(t, "key [redacted]", pl.redact("key sk-abcdefghijklmnopqrstuvwx", false))
+	assert.Equal(t, "Authorization: Bearer [redacted]", pl.redact("Authorization: Bearer abc.def.ghi123", false))
+	assert.Equal(t, "GET /v1/models?key=[redacted]&alt=json", pl.redact("GET /v1/models?key=AIzaSyD-secret&alt=json", false))
+
+	pl.redactText = true
+	assert.Equal(t, "[5 characters]", pl.redact("Käse!", true))
+}
+
+func TestLoggingLLM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.jsonl")
+	pl, err := newProviderLog(path, 0, false)
+	require.NoError(t, err)
+	llm := &loggingLLM{Model: &scriptedLLM{responses: []string{"Invoice ACME"}}, log: pl, kind: "llm", provider: "openai", model: "gpt-4o"}
+
+	_, err = llm.GenerateContent(withTraceDocument(context.Background(), 12), []llms.MessageContent{{
+		Role: llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{
+			llms.TextContent{Text: "Suggest a title"},
+			llms.BinaryContent{MIMEType: "image/jpeg", Data: make([]byte, 2048)},
+		},
+	}})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var record ProviderLogRecord
+	require.NoError(t, json.Unmarshal(content, &record))
+	assert.Equal(t, "openai", record.Provider)
+	assert.Equal(t, 12, record.DocumentID)
+	assert.Equal(t, "Invoice ACME", record.Response)
+	require.Len(t, record.Messages, 1)
+	assert.Equal(t, "Suggest a title", record.Messages[0].Text)
+	assert.Equal(t, []string{"image/jpeg, 2048 bytes"}, record.Messages[0].Parts)
+	assert.True(t, record.TokensEstimated)
+	assert.Positive(t, record.PromptTokens)
+}
+
+func TestProviderLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.jsonl")
+	pl, err := newProviderLog(path, 200, false)
+	require.NoError(t, err)
+
+	record := ProviderLogRecord{Kind: "llm", Response: strings.Repeat("x", 100)}
+	for i := 0; i < 6; i++ {
+		require.NoError(t, pl.write(record))
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		file, err := os.Open(name)
+		require.NoError(t, err, name)
+		lines := 0
+		for scanner := bufio.NewScanner(file); scanner.Scan(); {
+			lines++
+		}
+		file.Close()
+		assert.Equal(t, 1, lines, name)
+	}
+	assert.NoFileExists(t, path+".4")
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ProviderCheck is the result of a test request against a configured LLM provider
+type ProviderCheck struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProviderHealth reports the state of the LLM, unless running in OCR-only mode, and, if OCR is enabled, the vision LLM
+type ProviderHealth struct {
+	LLM *ProviderCheck `json:"llm,omitempty"`
+	OCR *ProviderCheck `json:"ocr,omitempty"`
+}
+
+// providerCheckTimeout is generous because the first request to a local model also loads it into memory
+const providerCheckTimeout = 2 * time.Minute
+
+// checkProviders sends a trivial prompt to the LLM and a tiny test image to the vision LLM. This verifies
+// credentials and model availability and warms up local models before the first real document arrives.
+func (app *App) checkProviders(ctx context.Context) ProviderHealth {
+	ctx, cancel := context.WithTimeout(ctx, providerCheckTimeout)
+	defer cancel()
+
+	var health ProviderHealth
+	if app.LLM != nil {
+		check := runProviderCheck(llmProvider, llmModel, func() error {
+			_, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
+				llms.TextParts(llms.ChatMessageTypeHuman, "Reply with the single word OK."),
+			})
+			return err
+		})
+		health.LLM = &check
+	}
+
+	if app.VisionLLM != nil {
+		check := runProviderCheck(visionLlmProvider, visionLlmModel, func() error {
+			// A cached answer would hide an unreachable provider
+			_, err := app.doOCRViaLLM(withoutOcrCache(ctx), providerTestImage(), ocrPageContext{PageNumber: 1, TotalPages: 1}, log.WithField("check", "ocr"))
+			return err
+		})
+		health.OCR = &check
+	}
+	return health
+}
+
+// runProviderCheck times a test request and turns its error into a hint where possible
+func runProviderCheck(provider, model string, request func() error) ProviderCheck {
+	check := ProviderCheck{Provider: provider, Model: model}
+	start := time.Now()
+	err := request()
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		if strings.EqualFold(provider, "ollama") && strings.Contains(strings.ToLower(check.Error), "not found") {
+			check.Error += fmt.Sprintf(" (is the model pulled? Run: ollama pull %s)", model)
+		}
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+// providerTestImage returns a small white JPEG with a dark bar, enough for a vision model to answer
+func providerTestImage() []byte {
+	img := image.NewGray(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			gray := uint8(255)
+			if y >= 12 && y < 20 && x >= 8 && x < 56 {
+				gray = 0
+			}
+			img.SetGray(x, y, color.Gray{Y: gray})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	return buf.Bytes()
+}
+
+// logProviderHealth checks the providers and logs the result, so misconfiguration shows up at startup
+func (app *App) logProviderHealth() {
+	health := app.checkProviders(context.Background())
+	for name, check := range map[string]*ProviderCheck{"LLM": health.LLM, "OCR": health.OCR} {
+		if check == nil {
+			continue
+		}
+		if check.OK {
+			log.Infof("%s provider %s (%s) is reachable, test request took %d ms", name, check.Provider, check.Model, check.LatencyMs)
+		} else {
+			log.Errorf("%s provider %s (%s) failed its test request: %s", name, check.Provider, check.Model, check.Error)
+		}
+	}
+}
+
+// configuredProviders lists the LLM providers in use by any of the configured models
+func configuredProviders() []string {
+	var providers []string
+	for _, provider := range []string{llmProvider, visionLlmProvider, consensusVisionProvider, handwritingProvider, shadowLlmProvider} {
+		if provider = strings.ToLower(provider); provider != "" && !slices.Contains(providers, provider) {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// openaiBaseURL returns the OpenAI compatible API URL, which the OpenAI client also reads from OPENAI_BASE_URL
+func openaiBaseURL() string {
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// listOpenAIModels returns the IDs of the models available through the OpenAI models API
+func listOpenAIModels(ctx context.Context, httpClient *http.Client, baseURL string, token string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error listing OpenAI models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error listing OpenAI models: status %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAI models: %w", err)
+	}
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+// googleaiBaseURL is the Gemini API URL the Google AI client sends its requests to
+const googleaiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// listGoogleAIModels returns the names of the Gemini API models that can generate content
+func listGoogleAIModels(ctx context.Context, httpClient *http.Client, baseURL string, apiKey string) ([]string, error) {
+	var models []string
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		// The key is sent as a header, so it does not end up in logged URLs
+		req.Header.Set("x-goog-api-key", apiKey)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Google AI models: %w", err)
+		}
+		var list struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error listing Google AI models: status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing Google AI models: %w", err)
+		}
+		for _, m := range list.Models {
+			if slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				models = append(models, strings.TrimPrefix(m.Name, "models/"))
+			}
+		}
+		if list.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// listProviderModels returns the sorted model names a configured provider offers
+func listProviderModels(ctx context.Context, provider string) ([]string, error) {
+	httpClient, err := newHTTPClient(llmTimeout, os.Getenv("LLM_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+
+	var models []string
+	switch provider {
+	case "openai":
+		models, err = listOpenAIModels(ctx, httpClient, openaiBaseURL(), openaiAPIKey)
+	case "ollama":
+		models, err = listOllamaModels(ctx, httpClient, ollamaHost())
+	case "anthropic":
+		models, err = listAnthropicModels(ctx, withAnthropicLimits(httpClient), anthropicBaseURL(), anthropicAPIKey)
+	case "googleai":
+		models, err = listGoogleAIModels(ctx, httpClient, googleaiBaseURL, googleaiAPIKey)
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(models)
+	return models, nil
+}
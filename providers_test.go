@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type failingLLM struct {
+	mockLLM
+	err error
+}
+
+func (m *failingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	return nil, m.err
+}
+
+func TestCheckProviders(t *testing.T) {
+	originalProvider, originalModel := llmProvider, llmModel
+	llmProvider, llmModel = "ollama", "llama3"
+	defer func() { llmProvider, llmModel = originalProvider, originalModel }()
+
+	var err error
+	ocrTemplate, err = template.New("ocr").Parse("Transcribe the image.")
+	require.NoError(t, err)
+
+	app := &App{
+		LLM:       &failingLLM{err: errors.New(`model "llama3" not found, try pulling it first`)},
+		VisionLLM: &scriptedLLM{responses: []string{"-"}},
+	}
+	health := app.checkProviders(context.Background())
+
+	require.NotNil(t, health.LLM)
+	assert.False(t, health.LLM.OK)
+	assert.Equal(t, "llama3", health.LLM.Model)
+	assert.Contains(t, health.LLM.Error, "ollama pull llama3")
+
+	require.NotNil(t, health.OCR)
+	assert.True(t, health.OCR.OK)
+	assert.Empty(t, health.OCR.Error)
+
+	// Without a vision LLM only the LLM is checked
+	app = &App{LLM: &mockLLM{}}
+	health = app.checkProviders(context.Background())
+	require.NotNil(t, health.LLM)
+	assert.True(t, health.LLM.OK)
+	assert.Nil(t, health.OCR)
+
+	// In OCR-only mode only the vision LLM is checked
+	app = &App{VisionLLM: &scriptedLLM{responses: []string{"-"}}}
+	health = app.checkProviders(context.Background())
+	assert.Nil(t, health.LLM)
+	require.NotNil(t, health.OCR)
+	assert.True(t, health.OCR.OK)
+}
+
+func TestListOpenAIModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"object": "list", "data": [{"id": "gpt-4o", "object": "model"}, {"id": "gpt-4o-mini", "object": "model"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := listOpenAIModels(context.Background(), server.Client(), server.URL+"/v1", "sk-test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, models)
+}
+
+func TestListGoogleAIModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		assert.Empty(t, r.URL.Query().Get("key"), "the key is not part of the URL")
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"models": [
+				{"name": "models/gemini-1.5-flash", "supportedGenerationMethods": ["generateContent", "countTokens"]},
+				{"name": "models/text-embedding-004", "supportedGenerationMethods": ["embedContent"]}
+			], "nextPageToken": "next"}`))
+			return
+		}
+		w.Write([]byte(`{"models": [{"name": "models/gemini-1.5-pro", "supportedGenerationMethods": ["generateContent"]}]}`))
+	}))
+	defer server.Close()
+
+	models, err := listGoogleAIModels(context.Background(), server.Client(), server.URL+"/v1beta", "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini-1.5-flash", "gemini-1.5-pro"}, models)
+}
+
+func TestConfiguredProviders(t *testing.T) {
+	originalLLM, originalVision := llmProvider, visionLlmProvider
+	defer func() { llmProvider, visionLlmProvider = originalLLM, originalVision }()
+
+	llmProvider, visionLlmProvider = "OpenAI", "openai"
+	assert.Contains(t, configuredProviders(), "openai")
+	assert.NotContains(t, configuredProviders(), "ollama")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// suggestionRecordRetention is how long suggestions and their outcomes are kept for GET /api/stats/quality
+const suggestionRecordRetention = 365 * 24 * time.Hour
+
+// Outcomes of a reviewed suggestion
+const (
+	outcomeAccepted = "accepted" // Applied as suggested
+	outcomeEdited   = "edited"   // Changed by the user before applying
+	outcomeRejected = "rejected" // The original value was kept
+)
+
+// QualityCounts counts what users did with suggestions
+type QualityCounts struct {
+	Accepted int `json:"accepted"`
+	Edited   int `json:"edited"`
+	Rejected int `json:"rejected"`
+	Pending  int `json:"pending"` // Not reviewed yet
+
+	// AcceptanceRate is the share of reviewed suggestions applied as-is, nil if none were reviewed
+	AcceptanceRate *float64 `json:"acceptance_rate"`
+}
+
+// QualityPoint holds the counts of one model on one day, as returned by GET /api/stats/quality
+type QualityPoint struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Model string `json:"model"`
+	QualityCounts
+}
+
+// QualityStats summarizes the suggestion outcomes of a period
+type QualityStats struct {
+	Fields map[string]*QualityCounts `json:"fields"`
+	Models map[string]*QualityCounts `json:"models"`
+	Daily  []*QualityPoint           `json:"daily"`
+}
+
+// normalizeTagValue turns a tag list into a comparable value, ignoring order and workflow tags
+func normalizeTagValue(tags []string) string {
+	tags = slices.Clone(withoutStatusTags(tags))
+	slices.Sort(tags)
+	return strings.Join(slices.Compact(tags), ", ")
+}
+
+// suggestionRecords returns a record for each suggested field of the suggestions
+func suggestionRecords(suggestions []DocumentSuggestion, username string) []SuggestionRecord {
+	var records []SuggestionRecord
+	for _, suggestion := range suggestions {
+		add := func(field, suggested, original string) {
+			records = append(records, SuggestionRecord{
+				DocumentID: uint(suggestion.ID),
+				Field:      field,
+				Model:      llmModel,
+				Suggested:  suggested,
+				Original:   original,
+				Username:   username,
+			})
+		}
+		if suggestion.SuggestedTitle != "" {
+			add("title", suggestion.SuggestedTitle, suggestion.OriginalDocument.Title)
+		}
+		if len(suggestion.SuggestedTags) > 0 {
+			add("tags", normalizeTagValue(suggestion.SuggestedTags), normalizeTagValue(suggestion.OriginalDocument.Tags))
+		}
+		if suggestion.SuggestedCorrespondent != "" {
+			add("correspondent", suggestion.SuggestedCorrespondent, suggestion.OriginalDocument.Correspondent)
+		}
+	}
+	return records
+}
+
+// appliedValue returns the value of a field the user applied, which is the original value if the field was left empty
+func appliedValue(document DocumentSuggestion, record SuggestionRecord) string {
+	switch record.Field {
+	case "title":
+		if document.SuggestedTitle != "" {
+			return document.SuggestedTitle
+		}
+	case "tags":
+		if len(document.SuggestedTags) > 0 {
+			return normalizeTagValue(document.SuggestedTags)
+		}
+	case "correspondent":
+		if document.SuggestedCorrespondent != "" {
+			return document.SuggestedCorrespondent
+		}
+	}
+	return record.Original
+}
+
+// suggestionOutcome compares the applied value with the suggestion and the original value
+func suggestionOutcome(record SuggestionRecord, applied string) string {
+	switch {
+	case applied == record.Suggested:
+		return outcomeAccepted
+	case applied == record.Original:
+		return outcomeRejected
+	default:
+		return outcomeEdited
+	}
+}
+
+// recordSuggestions stores the suggestions shown to the user in the context for review
+func (app *App) recordSuggestions(ctx context.Context, suggestions []DocumentSuggestion) error {
+	records := suggestionRecords(suggestions, tenantUsername(ctx))
+	if len(records) == 0 {
+		return nil
+	}
+	return InsertSuggestionRecords(app.Database, records, suggestionRecordRetention)
+}
+
+// recordSuggestionOutcomes stores what the user in the context did with the pending suggestions of the
+// updated documents
+func (app *App) recordSuggestionOutcomes(ctx context.Context, documents []DocumentSuggestion) error {
+	for _, document := range documents {
+		records, err := GetPendingSuggestionRecords(app.Database, tenantUsername(ctx), document.ID)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := SetSuggestionOutcome(app.Database, record.ID, suggestionOutcome(record, appliedValue(document, record))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add counts a record
+func (counts *QualityCounts) add(record SuggestionRecord) {
+	switch record.Outcome {
+	case outcomeAccepted:
+		counts.Accepted++
+	case outcomeEdited:
+		counts.Edited++
+	case outcomeRejected:
+		counts.Rejected++
+	default:
+		counts.Pending++
+	}
+	if reviewed := counts.Accepted + counts.Edited + counts.Rejected; reviewed > 0 {
+		rate := float64(counts.Accepted) / float64(reviewed)
+		counts.AcceptanceRate = &rate
+	}
+}
+
+// qualityStats groups records, which must be ordered by creation, per field, per model and per day and model
+func qualityStats(records []SuggestionRecord) QualityStats {
+	stats := QualityStats{Fields: map[string]*QualityCounts{}, Models: map[string]*QualityCounts{}, Daily: []*QualityPoint{}}
+	daily := map[string]*QualityPoint{}
+	for _, record := range records {
+		if stats.Fields[record.Field] == nil {
+			stats.Fields[record.Field] = &QualityCounts{}
+		}
+		stats.Fields[record.Field].add(record)
+		if stats.Models[record.Model] == nil {
+			stats.Models[record.Model] = &QualityCounts{}
+		}
+		stats.Models[record.Model].add(record)
+
+		date := record.DateCreated
+		if created, err := time.Parse(time.RFC3339, record.DateCreated); err == nil {
+			date = created.Format("2006-01-02")
+		}
+		key := date + "|" + record.Model
+		if daily[key] == nil {
+			daily[key] = &QualityPoint{Date: date, Model: record.Model}
+			stats.Daily = append(stats.Daily, daily[key])
+		}
+		daily[key].add(record)
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionOutcome(t *testing.T) {
+	record := SuggestionRecord{Suggested: "Invoice ACME 2024-05", Original: "scan_0001"}
+	assert.Equal(t, outcomeAccepted, suggestionOutcome(record, "Invoice ACME 2024-05"))
+	assert.Equal(t, outcomeRejected, suggestionOutcome(record, "scan_0001"))
+	assert.Equal(t, outcomeEdited, suggestionOutcome(record, "Invoice ACME May 2024"))
+}
+
+func TestNormalizeTagValue(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "paperless-gpt" })
+
+	assert.Equal(t, "Bank, Invoice", normalizeTagValue([]string{"Invoice", "paperless-gpt", "Bank", "Invoice"}))
+}
+
+func TestQualityStats(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+	env.db.Where("1 = 1").Delete(&SuggestionRecord{})
+
+	originalModel := llmModel
+	llmModel = "gpt-4o"
+	defer func() { llmModel = originalModel }()
+
+	app := &App{Database: env.db}
+	ctx := withTenant(context.Background(), "quality-user", "")
+	original := Document{ID: 1, Title: "scan_0001", Tags: []string{"Inbox"}, Correspondent: ""}
+	require.NoError(t, app.recordSuggestions(ctx, []DocumentSuggestion{
+		{ID: 1, OriginalDocument: original, SuggestedTitle: "Invoice ACME", SuggestedTags: []string{"Invoice", "Inbox"}, SuggestedCorrespondent: "ACME"},
+		{ID: 2, OriginalDocument: Document{ID: 2, Title: "scan_0002"}, SuggestedTitle: "Letter"},
+	}))
+
+	// The title is edited, the tags applied in a different order and the correspondent left empty
+	require.NoError(t, app.recordSuggestionOutcomes(ctx, []DocumentSuggestion{
+		{ID: 1, OriginalDocument: original, SuggestedTitle: "Invoice ACME May 2024", SuggestedTags: []string{"Inbox", "Invoice"}},
+	}))
+
+	records, err := GetSuggestionRecords(env.db, "quality-user", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	stats := qualityStats(records)
+
+	assert.Equal(t, 1, stats.Fields["title"].Edited)
+	assert.Equal(t, 1, stats.Fields["title"].Pending, "document 2 was not reviewed")
+	assert.Equal(t, 1, stats.Fields["tags"].Accepted)
+	assert.Equal(t, 1, stats.Fields["correspondent"].Rejected)
+
+	models := stats.Models["gpt-4o"]
+	require.NotNil(t, models)
+	require.NotNil(t, models.AcceptanceRate)
+	assert.InDelta(t, 1.0/3, *models.AcceptanceRate, 0.001)
+
+	require.Len(t, stats.Daily, 1)
+	assert.Equal(t, time.Now().Format("2006-01-02"), stats.Daily[0].Date)
+	assert.Equal(t, 4, stats.Daily[0].Accepted+stats.Daily[0].Edited+stats.Daily[0].Rejected+stats.Daily[0].Pending)
+
+	t.Run("regenerated suggestions replace pending ones", func(t *testing.T) {
+		require.NoError(t, app.recordSuggestions(ctx, []DocumentSuggestion{
+			{ID: 2, OriginalDocument: Document{ID: 2, Title: "scan_0002"}, SuggestedTitle: "Letter from ACME"},
+		}))
+		pending, err := GetPendingSuggestionRecords(env.db, "quality-user", 2)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "Letter from ACME", pending[0].Suggested)
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ownModificationSlack covers the delay between an update by paperless-gpt and the modification time
+// paperless records for it
+const ownModificationSlack = time.Minute
+
+// ownModifications holds the time paperless-gpt last changed each document, since its own
+// changes (e.g. status tags or the OCR text) must not start a quiet period
+var ownModifications = struct {
+	sync.Mutex
+	times map[int]time.Time
+}{times: map[int]time.Time{}}
+
+// recordOwnModification remembers that paperless-gpt changed a document
+func recordOwnModification(documentID int, at time.Time) {
+	current := settings()
+	if current.QuietPeriod <= 0 {
+		return
+	}
+	ownModifications.Lock()
+	defer ownModifications.Unlock()
+	ownModifications.times[documentID] = at
+	for id, modified := range ownModifications.times {
+		if at.Sub(modified) > current.QuietPeriod+ownModificationSlack {
+			delete(ownModifications.times, id)
+		}
+	}
+}
+
+// inQuietPeriod reports whether a document was modified by someone else within the quiet period,
+// and how long processing has to wait
+func inQuietPeriod(document Document, now time.Time) (time.Duration, bool) {
+	current := settings()
+	if current.QuietPeriod <= 0 || document.Modified.IsZero() {
+		return 0, false
+	}
+	wait := document.Modified.Add(current.QuietPeriod).Sub(now)
+	if wait <= 0 {
+		return 0, false
+	}
+
+	ownModifications.Lock()
+	own, ok := ownModifications.times[document.ID]
+	ownModifications.Unlock()
+	if ok && !document.Modified.After(own.Add(ownModificationSlack)) {
+		return 0, false
+	}
+	return wait, true
+}
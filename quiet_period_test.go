@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInQuietPeriod(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.QuietPeriod = 10 * time.Minute })
+
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	wait, quiet := inQuietPeriod(Document{ID: 1, Modified: now.Add(-4 * time.Minute)}, now)
+	assert.True(t, quiet, "edited by a user 4 minutes ago")
+	assert.Equal(t, 6*time.Minute, wait)
+
+	_, quiet = inQuietPeriod(Document{ID: 1, Modified: now.Add(-11 * time.Minute)}, now)
+	assert.False(t, quiet, "quiet period is over")
+
+	_, quiet = inQuietPeriod(Document{ID: 1}, now)
+	assert.False(t, quiet, "modification time unknown")
+
+	// Changes made by paperless-gpt itself, e.g. status tags, do not count
+	recordOwnModification(2, now.Add(-2*time.Minute))
+	_, quiet = inQuietPeriod(Document{ID: 2, Modified: now.Add(-2*time.Minute + time.Second)}, now)
+	assert.False(t, quiet, "own change")
+	_, quiet = inQuietPeriod(Document{ID: 2, Modified: now.Add(-30 * time.Second)}, now)
+	assert.True(t, quiet, "user edit after the own change")
+
+	setTestSettings(t, func(s *runtimeSettings) { s.QuietPeriod = 0 })
+	_, quiet = inQuietPeriod(Document{ID: 1, Modified: now}, now)
+	assert.False(t, quiet, "disabled")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ReaskStats counts how often the answers of a model had to be corrected
+type ReaskStats struct {
+	Model    string `json:"model"`
+	Requests int    `json:"requests"` // Validated LLM requests
+	Reasks   int    `json:"reasks"`   // Requests whose first answer was malformed and re-asked
+	Failures int    `json:"failures"` // Requests whose answer was still malformed after the re-ask
+}
+
+// reaskCorrectionMessage is sent to the LLM together with the format error of its previous answer
+const reaskCorrectionMessage = `Your previous answer could not be processed: %v
+Answer again in exactly the format requested above, without any additional information.`
+
+var (
+	reaskMutex   sync.Mutex
+	reaskMetrics = map[string]*ReaskStats{}
+)
+
+// recordReask updates the re-ask metrics of a model
+func recordReask(model string, reasked, failed bool) {
+	reaskMutex.Lock()
+	defer reaskMutex.Unlock()
+	stats, ok := reaskMetrics[model]
+	if !ok {
+		stats = &ReaskStats{Model: model}
+		reaskMetrics[model] = stats
+	}
+	stats.Requests++
+	if reasked {
+		stats.Reasks++
+	}
+	if failed {
+		stats.Failures++
+	}
+}
+
+// getReaskMetrics returns a snapshot of the re-ask metrics sorted by model
+func getReaskMetrics() []ReaskStats {
+	reaskMutex.Lock()
+	defer reaskMutex.Unlock()
+	metrics := make([]ReaskStats, 0, len(reaskMetrics))
+	for _, stats := range reaskMetrics {
+		metrics = append(metrics, *stats)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Model < metrics[j].Model
+	})
+	return metrics
+}
+
+// llmModelName returns the name of the model behind app.LLM
+func (app *App) llmModelName() string {
+	if app.LLMModel != "" {
+		return app.LLMModel
+	}
+	return llmModel
+}
+
+// generateValidated sends the prompt to the LLM and checks the answer with validate. A malformed answer is
+// re-asked once with the format error before giving up, so a single sloppy answer does not fail the document.
+func (app *App) generateValidated(ctx context.Context, prompt string, validate func(response string) error, options ...llms.CallOption) (string, error) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	}
+	completion, err := app.LLM.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
+	}
+	response := completion.Choices[0].Content
+
+	validationErr := validate(response)
+	if validationErr == nil {
+		recordReask(app.llmModelName(), false, false)
+		return response, nil
+	}
+	log.Warnf("Malformed LLM response, asking again: %v", validationErr)
+
+	messages = append(messages,
+		llms.TextParts(llms.ChatMessageTypeAI, response),
+		llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf(reaskCorrectionMessage, validationErr)),
+	)
+	completion, err = app.LLM.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		recordReask(app.llmModelName(), true, true)
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
+	}
+	response = completion.Choices[0].Content
+
+	if err := validate(response); err != nil {
+		recordReask(app.llmModelName(), true, true)
+		return "", fmt.Errorf("malformed LLM response after re-ask: %w", err)
+	}
+	recordReask(app.llmModelName(), true, false)
+	return response, nil
+}
+
+// maxTagWords is the number of words above which an entry of a tag list is treated as prose
+const maxTagWords = 6
+
+// validateTagList checks that an answer is a comma-separated list of tag names rather than a paragraph
+func validateTagList(response string) error {
+	response = strings.TrimSpace(stripReasoning(response))
+	if strings.Contains(response, "\n") {
+		return fmt.Errorf("expected a single comma-separated line of tags, got multiple lines")
+	}
+	for _, tag := range strings.Split(response, ",") {
+		if len(strings.Fields(tag)) > maxTagWords {
+			return fmt.Errorf("expected a comma-separated list of tag names, got the sentence %q", strings.TrimSpace(tag))
+		}
+	}
+	return nil
+}
+
+// validateExtractedRows checks that an answer parses into rows and that their dates use the YYYY-MM-DD format
+func validateExtractedRows(response string) error {
+	rows, err := parseExtractedRows(response)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		date, ok := row["date"].(string)
+		if !ok || date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("row %d has the invalid date %q, expected an existing date as YYYY-MM-DD", i+1, date)
+		}
+	}
+	return nil
+}
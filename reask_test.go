@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// scriptedLLM answers with the given responses in order and records the conversations it received
+type scriptedLLM struct {
+	mockLLM
+	responses     []string
+	conversations [][]llms.MessageContent
+}
+
+func (m *scriptedLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, opts ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.conversations = append(m.conversations, messages)
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: response}}}, nil
+}
+
+func TestGenerateValidated(t *testing.T) {
+	originalModel := llmModel
+	llmModel = "reask-test-model"
+	reaskMetrics = map[string]*ReaskStats{}
+	defer func() {
+		llmModel = originalModel
+		reaskMetrics = map[string]*ReaskStats{}
+	}()
+
+	t.Run("valid answer is not re-asked", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"Invoice, Bank"}}
+		app := &App{LLM: llm}
+
+		response, err := app.generateValidated(context.Background(), "prompt", validateTagList)
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice, Bank", response)
+		assert.Len(t, llm.conversations, 1)
+	})
+
+	t.Run("malformed answer is re-asked with the format error", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{
+			"This document is clearly an invoice from a bank, so I would suggest the following tags",
+			"Invoice, Bank",
+		}}
+		app := &App{LLM: llm}
+
+		response, err := app.generateValidated(context.Background(), "prompt", validateTagList)
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice, Bank", response)
+
+		require.Len(t, llm.conversations, 2)
+		reask := llm.conversations[1]
+		require.Len(t, reask, 3)
+		assert.Equal(t, llms.ChatMessageTypeAI, reask[1].Role)
+		assert.Contains(t, reask[2].Parts[0].(llms.TextContent).Text, "expected a comma-separated list of tag names")
+	})
+
+	t.Run("answer still malformed after re-ask fails", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{
+			`{"rows": [{"date": "31.02.2024"}]}`,
+			`{"rows": [{"date": "2024-02-31"}]}`,
+		}}
+		app := &App{LLM: llm}
+
+		_, err := app.generateValidated(context.Background(), "prompt", validateExtractedRows)
+		assert.ErrorContains(t, err, "malformed LLM response after re-ask")
+	})
+
+	assert.Equal(t, []ReaskStats{{Model: "reask-test-model", Requests: 3, Reasks: 2, Failures: 1}}, getReaskMetrics())
+}
+
+func TestGenerateValidatedRecordsCalledModel(t *testing.T) {
+	originalModel := llmModel
+	llmModel = "reask-production-model"
+	reaskMetrics = map[string]*ReaskStats{}
+	defer func() {
+		llmModel = originalModel
+		reaskMetrics = map[string]*ReaskStats{}
+	}()
+
+	production := &App{LLM: &scriptedLLM{responses: []string{"Invoice"}}}
+	_, err := production.generateValidated(context.Background(), "prompt", validateTagList)
+	require.NoError(t, err)
+
+	shadow := &App{LLM: &scriptedLLM{responses: []string{"Invoice"}}, LLMModel: "reask-shadow-model"}
+	_, err = shadow.generateValidated(context.Background(), "prompt", validateTagList)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ReaskStats{
+		{Model: "reask-production-model", Requests: 1},
+		{Model: "reask-shadow-model", Requests: 1},
+	}, getReaskMetrics())
+}
+
+func TestValidateExtractedRows(t *testing.T) {
+	assert.NoError(t, validateExtractedRows(`{"rows": [{"date": "2024-03-12"}, {"date": null}]}`))
+	assert.Error(t, validateExtractedRows(`{"rows": [{"date": "12.03.2024"}]}`))
+	assert.Error(t, validateExtractedRows(`Sure! Here is what I found: {"rows": [`))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds connecting to Redis and a single command unless the context has an earlier deadline
+const redisTimeout = 10 * time.Second
+
+// redisTxRetries is how often a transaction is repeated when a watched key changed in between
+const redisTxRetries = 10
+
+// newRedisClient creates a client from a URL of the form redis://[user:password@]host[:port][/db], or
+// rediss:// for TLS
+func newRedisClient(rawURL string) (*redis.Client, error) {
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	registerSecret(options.Password)
+	options.DialTimeout = redisTimeout
+	options.ReadTimeout = redisTimeout
+	options.WriteTimeout = redisTimeout
+	return redis.NewClient(options), nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// jobLeaseDuration is how long a job stays with a worker without a heartbeat before other replicas requeue it
+const jobLeaseDuration = 30 * time.Second
+
+// jobPollTimeout is how long a worker blocks waiting for a job before asking again
+const jobPollTimeout = 5 * time.Second
+
+// redisJobQueue stores OCR jobs in Redis so several paperless-gpt replicas share the queue and the job status.
+//
+// Queued job IDs are kept in a list. A worker atomically moves an ID to the processing list and holds a lease
+// key for it, which it renews while working. Jobs in the processing list whose lease expired belong to a crashed
+// worker and are moved back to the queue by any replica.
+type redisJobQueue struct {
+	client *redis.Client
+	prefix string
+	worker string // Identifies this replica in lease keys
+}
+
+// newRedisJobQueue creates a job queue with keys below the given prefix
+func newRedisJobQueue(client *redis.Client, prefix string) *redisJobQueue {
+	hostname, _ := os.Hostname()
+	return &redisJobQueue{
+		client: client,
+		prefix: prefix,
+		worker: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+func (q *redisJobQueue) jobKey(jobID string) string   { return q.prefix + "job:" + jobID }
+func (q *redisJobQueue) leaseKey(jobID string) string { return q.prefix + "lease:" + jobID }
+func (q *redisJobQueue) indexKey() string             { return q.prefix + "jobs" }
+func (q *redisJobQueue) queueKey() string             { return q.prefix + "queue" }
+func (q *redisJobQueue) processingKey() string        { return q.prefix + "processing" }
+
+// ping checks that Redis is reachable
+func (q *redisJobQueue) ping(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
+}
+
+// save stores a job and adds it to the job index
+func (q *redisJobQueue) save(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := q.client.Set(ctx, q.jobKey(job.ID), data, 0).Err(); err != nil {
+		return err
+	}
+	return q.client.ZAdd(ctx, q.indexKey(), redis.Z{Score: float64(job.CreatedAt.UnixNano()), Member: job.ID}).Err()
+}
+
+// load returns a stored job; ok is false if it does not exist
+func (q *redisJobQueue) load(ctx context.Context, jobID string) (*Job, bool, error) {
+	data, err := q.client.Get(ctx, q.jobKey(jobID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, false, fmt.Errorf("invalid job %s in Redis: %w", jobID, err)
+	}
+	return &job, true, nil
+}
+
+// list returns all jobs, newest first
+func (q *redisJobQueue) list(ctx context.Context) ([]*Job, error) {
+	ids, err := q.client.ZRevRange(ctx, q.indexKey(), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return []*Job{}, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = q.jobKey(id)
+	}
+	values, err := q.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Removed while listing
+		}
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			logger.Warnf("Skipping invalid job %s in Redis: %v", ids[i], err)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// update applies fn to a stored job in a transaction. Besides the worker holding the job, e.g. cancellation and
+// the recovery of other replicas change it, so the job is written only if it was not changed since it was read;
+// otherwise fn is applied again to the new state.
+func (q *redisJobQueue) update(ctx context.Context, jobID string, fn func(job *Job)) error {
+	key := q.jobKey(jobID)
+	apply := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil // Removed in the meantime
+		}
+		if err != nil {
+			return err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return fmt.Errorf("invalid job %s in Redis: %w", jobID, err)
+		}
+		fn(&job)
+		if data, err = json.Marshal(&job); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < redisTxRetries; attempt++ {
+		err := q.client.Watch(ctx, apply, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("job %s kept changing during %d attempts to update it", jobID, redisTxRetries)
+}
+
+// remove deletes a job
+func (q *redisJobQueue) remove(ctx context.Context, jobID string) error {
+	if err := q.client.Del(ctx, q.jobKey(jobID)).Err(); err != nil {
+		return err
+	}
+	return q.client.ZRem(ctx, q.indexKey(), jobID).Err()
+}
+
+// push queues a stored job for the workers of all replicas
+func (q *redisJobQueue) push(ctx context.Context, jobID string) error {
+	return q.client.LPush(ctx, q.queueKey(), jobID).Err()
+}
+
+// queued returns the number of jobs waiting for a worker
+func (q *redisJobQueue) queued(ctx context.Context) (int, error) {
+	count, err := q.client.LLen(ctx, q.queueKey()).Result()
+	return int(count), err
+}
+
+// next waits up to jobPollTimeout for a queued job and takes a lease on it. It returns nil if no job arrived.
+func (q *redisJobQueue) next(ctx context.Context) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, jobPollTimeout+redisTimeout)
+	defer cancel()
+	jobID, err := q.client.BRPopLPush(ctx, q.queueKey(), q.processingKey(), jobPollTimeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := q.renewLease(ctx, jobID); err != nil {
+		return nil, err
+	}
+
+	job, ok, err := q.load(ctx, jobID)
+	if err == nil && !ok {
+		// The job was removed while it was queued
+		err = q.release(ctx, jobID)
+	}
+	return job, err
+}
+
+// renewLease sets or extends the lease of this replica on a job
+func (q *redisJobQueue) renewLease(ctx context.Context, jobID string) error {
+	return q.client.Set(ctx, q.leaseKey(jobID), q.worker, jobLeaseDuration).Err()
+}
+
+// release removes a finished job from the processing list and drops its lease
+func (q *redisJobQueue) release(ctx context.Context, jobID string) error {
+	if err := q.client.LRem(ctx, q.processingKey(), 1, jobID).Err(); err != nil {
+		return err
+	}
+	return q.client.Del(ctx, q.leaseKey(jobID)).Err()
+}
+
+// heartbeat renews the lease on a job until the returned function is called
+func (q *redisJobQueue) heartbeat(jobID string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobLeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := q.renewLease(context.Background(), jobID); err != nil {
+					logger.Errorf("Failed to renew lease on job %s: %v", jobID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// recoverExpired moves jobs whose worker stopped renewing its lease back to the queue and returns their number
+func (q *redisJobQueue) recoverExpired(ctx context.Context, now time.Time) (int, error) {
+	ids, err := q.client.LRange(ctx, q.processingKey(), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, jobID := range ids {
+		leased, err := q.client.Exists(ctx, q.leaseKey(jobID)).Result()
+		if err != nil {
+			return recovered, err
+		}
+		if leased > 0 {
+			continue
+		}
+		job, ok, err := q.load(ctx, jobID)
+		if err != nil {
+			return recovered, err
+		}
+		// A worker that just took the job may not have set its lease yet
+		if ok && now.Sub(job.UpdatedAt) < jobLeaseDuration {
+			continue
+		}
+
+		// Only the replica that removes the entry requeues the job
+		removed, err := q.client.LRem(ctx, q.processingKey(), 1, jobID).Result()
+		if err != nil {
+			return recovered, err
+		}
+		if removed == 0 || !ok {
+			continue
+		}
+		if err := q.update(ctx, jobID, func(job *Job) {
+			job.Status = "pending"
+			job.UpdatedAt = now
+		}); err != nil {
+			return recovered, err
+		}
+		if err := q.push(ctx, jobID); err != nil {
+			return recovered, err
+		}
+		logger.Warnf("Requeued job %s after its worker stopped responding", jobID)
+		recovered++
+	}
+	return recovered, nil
+}
+
+// startWorkers starts workers taking jobs from Redis and a loop recovering jobs of crashed replicas
+func (q *redisJobQueue) startWorkers(app *App, watchdog *jobWatchdog, numWorkers int) {
+	watchdog.start(numWorkers, func(workerID int) {
+		logger.Infof("Worker %d started, sharing jobs through Redis", workerID)
+		for {
+			job, err := q.next(context.Background())
+			if err != nil {
+				logger.Errorf("Worker %d failed to fetch a job from Redis: %v", workerID, err)
+				time.Sleep(jobPollTimeout)
+				continue
+			}
+			if job == nil {
+				continue
+			}
+
+			logger.Infof("Worker %d processing job: %s", workerID, job.ID)
+			stop := q.heartbeat(job.ID)
+			replaced := watchdog.processJob(app, workerID, job)
+			stop()
+			if err := q.release(context.Background(), job.ID); err != nil {
+				logger.Errorf("Failed to release job %s: %v", job.ID, err)
+			}
+			if replaced {
+				logger.Warnf("Worker %d stopped, it was replaced while job %s stalled", workerID, job.ID)
+				return
+			}
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(jobLeaseDuration)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := q.recoverExpired(context.Background(), time.Now()); err != nil {
+				logger.Errorf("Failed to recover jobs of stopped workers: %v", err)
+			}
+		}
+	}()
+}
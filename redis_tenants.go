@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// sharedTenants keeps the registered paperless tokens in Redis with REDIS_URL, so every replica can act for
+// every user. Without Redis the tokens are kept in the local database.
+var sharedTenants *redisTenantStore
+
+// redisTenantStore stores the registered users in a Redis hash, with the encrypted token per username
+type redisTenantStore struct {
+	client *redis.Client
+	key    string
+}
+
+// newRedisTenantStore creates a store for the registered users at the given key
+func newRedisTenantStore(client *redis.Client, key string) *redisTenantStore {
+	return &redisTenantStore{client: client, key: key}
+}
+
+// get returns a registered user, or gorm.ErrRecordNotFound like the local database
+func (s *redisTenantStore) get(ctx context.Context, username string) (*TenantUser, error) {
+	data, err := s.client.HGet(ctx, s.key, username).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user TenantUser
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("invalid user %s in Redis: %w", username, err)
+	}
+	return &user, nil
+}
+
+// list returns all registered users, ordered by name
+func (s *redisTenantStore) list(ctx context.Context) ([]TenantUser, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]TenantUser, 0, len(values))
+	for username, data := range values {
+		var user TenantUser
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			log.Warnf("Skipping invalid user %s in Redis: %v", username, err)
+			continue
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// save encrypts and stores the paperless token of a user, replacing a previously registered token
+func (s *redisTenantStore) save(ctx context.Context, username string, token string) error {
+	encrypted, err := encryptSecret(token)
+	if err != nil {
+		return err
+	}
+	user := TenantUser{Username: username, EncryptedToken: encrypted, DateAdded: time.Now().Format(time.RFC3339)}
+	if existing, err := s.get(ctx, username); err == nil {
+		user.DateAdded = existing.DateAdded
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, username, data).Err()
+}
+
+// remove deletes the registered token of a user, or returns gorm.ErrRecordNotFound
+func (s *redisTenantStore) remove(ctx context.Context, username string) error {
+	removed, err := s.client.HDel(ctx, s.key, username).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// moveLocal moves the users registered in the local database before REDIS_URL was set to Redis and returns
+// their number. Users already registered in Redis keep the token stored there.
+func (s *redisTenantStore) moveLocal(ctx context.Context, db *gorm.DB) (int, error) {
+	users, err := GetTenantUsers(db)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, user := range users {
+		user.ID = 0
+		data, err := json.Marshal(user)
+		if err != nil {
+			return moved, err
+		}
+		added, err := s.client.HSetNX(ctx, s.key, user.Username, data).Result()
+		if err != nil {
+			return moved, err
+		}
+		// Removed locally, so a token deleted through another replica does not come back
+		if err := DeleteTenantUser(db, user.Username); err != nil {
+			return moved, err
+		}
+		if added {
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// rotate re-encrypts all stored tokens that are not encrypted with the current SECRETS_KEY and returns how
+// many were changed
+func (s *redisTenantStore) rotate(ctx context.Context) (int, error) {
+	users, err := s.list(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rotated := 0
+	for _, user := range users {
+		if !secretNeedsRotation(user.EncryptedToken) {
+			continue
+		}
+		token, err := decryptSecret(user.EncryptedToken)
+		if err != nil {
+			return rotated, fmt.Errorf("user %s: %w", user.Username, err)
+		}
+		if err := s.save(ctx, user.Username, token); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
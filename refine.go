@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// refinableFields are the suggestion fields POST /api/documents/:id/refine can regenerate
+var refinableFields = []string{"title", "tags", "correspondent", "created_date"}
+
+// RefineRequest is the body of POST /api/documents/:id/refine
+type RefineRequest struct {
+	Suggestion DocumentSuggestion `json:"suggestion"`       // The previously generated suggestion
+	Feedback   string             `json:"feedback"`         // What is wrong with it, e.g. "the correspondent is the hospital, not the insurer"
+	Fields     []string           `json:"fields,omitempty"` // Fields to regenerate, by default all fields of the suggestion
+}
+
+// validate checks the feedback and the requested fields
+func (req RefineRequest) validate() error {
+	if strings.TrimSpace(req.Feedback) == "" {
+		return errors.New("feedback is required")
+	}
+	for _, field := range req.Fields {
+		if !slices.Contains(refinableFields, field) {
+			return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(refinableFields, ", "))
+		}
+	}
+	return nil
+}
+
+// suggestedFields lists the fields a suggestion contains
+func suggestedFields(suggestion DocumentSuggestion) []string {
+	var fields []string
+	if suggestion.SuggestedTitle != "" {
+		fields = append(fields, "title")
+	}
+	if len(suggestion.SuggestedTags) > 0 {
+		fields = append(fields, "tags")
+	}
+	if suggestion.SuggestedCorrespondent != "" {
+		fields = append(fields, "correspondent")
+	}
+	if suggestion.SuggestedCreatedDate != "" {
+		fields = append(fields, "created_date")
+	}
+	return fields
+}
+
+// refineInstructions tells the LLM what it suggested before and what the user thinks of it
+func refineInstructions(suggestion DocumentSuggestion, feedback string) string {
+	var b strings.Builder
+	b.WriteString("A previous suggestion for this document was reviewed by the user:\n")
+	if suggestion.SuggestedTitle != "" {
+		fmt.Fprintf(&b, "- Title: %s\n", suggestion.SuggestedTitle)
+	}
+	if len(suggestion.SuggestedTags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(suggestion.SuggestedTags, ", "))
+	}
+	if suggestion.SuggestedCorrespondent != "" {
+		fmt.Fprintf(&b, "- Correspondent: %s\n", suggestion.SuggestedCorrespondent)
+	}
+	if suggestion.SuggestedCreatedDate != "" {
+		fmt.Fprintf(&b, "- Created date: %s\n", suggestion.SuggestedCreatedDate)
+	}
+	fmt.Fprintf(&b, "The user's feedback: %s\nTake the feedback into account in your answer.", strings.TrimSpace(feedback))
+	return b.String()
+}
+
+// refineSuggestion regenerates the fields of a suggestion with the user's feedback included in the prompts.
+// Fields that are not regenerated keep their previous value.
+func (app *App) refineSuggestion(ctx context.Context, doc Document, req RefineRequest, logger *logrus.Entry) (DocumentSuggestion, error) {
+	fields := req.Fields
+	if len(fields) == 0 {
+		fields = suggestedFields(req.Suggestion)
+	}
+	if len(fields) == 0 {
+		return DocumentSuggestion{}, errors.New("the suggestion has no fields to refine")
+	}
+
+	instructions := refineInstructions(req.Suggestion, req.Feedback)
+	refined := req.Suggestion
+	refined.ID = doc.ID
+	refined.OriginalDocument = doc
+	for _, field := range fields {
+		value, err := app.suggestField(ctx, doc, field, instructions, logger)
+		if err != nil {
+			return DocumentSuggestion{}, fmt.Errorf("error refining %s: %w", field, err)
+		}
+		switch field {
+		case "title":
+			refined.SuggestedTitle = value.(string)
+		case "tags":
+			refined.SuggestedTags = value.([]string)
+			refined.TagRationales = nil
+		case "correspondent":
+			refined.SuggestedCorrespondent = value.(string)
+			refined.CorrespondentCandidates = nil
+			refined.CorrespondentRationale = ""
+		case "created_date":
+			refined.SuggestedCreatedDate = value.(string)
+		}
+	}
+	return refined, nil
+}
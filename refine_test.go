@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestRefineRequestValidate(t *testing.T) {
+	assert.Error(t, RefineRequest{Feedback: " "}.validate())
+	assert.Error(t, RefineRequest{Feedback: "wrong date", Fields: []string{"owner"}}.validate())
+	assert.NoError(t, RefineRequest{Feedback: "wrong date", Fields: []string{"created_date"}}.validate())
+}
+
+func TestRefineSuggestion(t *testing.T) {
+	originalTemplate := createdDateTemplate
+	createdDateTemplate = template.Must(template.New("created_date").Parse("Date of {{.Title}}: {{.Content}}"))
+	defer func() { createdDateTemplate = originalTemplate }()
+
+	doc := Document{ID: 7, Title: "Invoice", Content: "Invoice dated 12.03.2024, due 01.04.2024"}
+	llm := &scriptedLLM{responses: []string{"2024-03-12"}}
+	app := &App{LLM: llm}
+
+	refined, err := app.refineSuggestion(context.Background(), doc, RefineRequest{
+		Suggestion: DocumentSuggestion{ID: 7, SuggestedCreatedDate: "2024-04-01", SuggestedTitle: "Invoice ACME"},
+		Feedback:   "That is the due date, use the invoice date",
+		Fields:     []string{"created_date"},
+	}, logrus.WithField("test", "test"))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-12", refined.SuggestedCreatedDate)
+	assert.Equal(t, "Invoice ACME", refined.SuggestedTitle, "fields that are not refined are kept")
+	assert.Equal(t, doc, refined.OriginalDocument)
+
+	require.Len(t, llm.conversations, 1)
+	instructions := llm.conversations[0][0].Parts[1].(llms.TextContent).Text
+	assert.Contains(t, instructions, "- Created date: 2024-04-01")
+	assert.Contains(t, instructions, "That is the due date, use the invoice date")
+}
+
+func TestSuggestedFields(t *testing.T) {
+	assert.Equal(t, []string{"tags", "correspondent"}, suggestedFields(DocumentSuggestion{SuggestedTags: []string{"invoice"}, SuggestedCorrespondent: "ACME"}))
+	assert.Empty(t, suggestedFields(DocumentSuggestion{}))
+}
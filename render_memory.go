@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// pdfRenderMemoryMB is the memory budget of all concurrently rendered PDF pages, read from PDF_RENDER_MEMORY_MB. 0 disables the budget.
+var pdfRenderMemoryMB = 0
+
+// pdfRenderDPI is the resolution go-fitz renders pages at
+const pdfRenderDPI = 300.0
+
+// renderBudget limits the memory of the page images held at the same time across all documents
+type renderBudget struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	capacity int64 // 0 means unlimited
+	inUse    int64
+}
+
+// renderMemory is the budget shared by all PDF rendering
+var renderMemory = newRenderBudget(0)
+
+// newRenderBudget creates a budget of capacity bytes, 0 for unlimited
+func newRenderBudget(capacity int64) *renderBudget {
+	budget := &renderBudget{capacity: capacity}
+	budget.cond = sync.NewCond(&budget.mu)
+	return budget
+}
+
+// acquire waits until bytes fit into the budget and reserves them. A page larger than the whole budget is
+// rendered alone. It returns the reserved bytes, which must be passed to release.
+func (budget *renderBudget) acquire(ctx context.Context, bytes int64) (int64, error) {
+	if budget.capacity <= 0 {
+		return 0, nil
+	}
+	bytes = min(bytes, budget.capacity)
+
+	// Wake up the waiters when the context ends so they can give up
+	stop := context.AfterFunc(ctx, func() {
+		budget.mu.Lock()
+		defer budget.mu.Unlock()
+		budget.cond.Broadcast()
+	})
+	defer stop()
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	for budget.inUse+bytes > budget.capacity {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		budget.cond.Wait()
+	}
+	budget.inUse += bytes
+	return bytes, nil
+}
+
+// release returns reserved bytes to the budget
+func (budget *renderBudget) release(bytes int64) {
+	if bytes == 0 {
+		return
+	}
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	budget.inUse -= bytes
+	budget.cond.Broadcast()
+}
+
+// pageRenderBytes estimates the memory of a rendered page image from its bounds in points
+func pageRenderBytes(bounds image.Rectangle) int64 {
+	scale := pdfRenderDPI / 72
+	width := int64(float64(bounds.Dx()) * scale)
+	height := int64(float64(bounds.Dy()) * scale)
+	return width * height * 4 // RGBA
+}
+
+// renderStats records the page image memory a job held at most
+type renderStats struct {
+	mu    sync.Mutex
+	inUse int64
+	peak  int64
+}
+
+type renderStatsKey struct{}
+
+// withRenderStats attaches render statistics to the context, e.g. of an OCR job
+func withRenderStats(ctx context.Context, stats *renderStats) context.Context {
+	return context.WithValue(ctx, renderStatsKey{}, stats)
+}
+
+// renderStatsFrom returns the statistics attached to the context, or nil
+func renderStatsFrom(ctx context.Context) *renderStats {
+	stats, _ := ctx.Value(renderStatsKey{}).(*renderStats)
+	return stats
+}
+
+// add counts page image memory being held (positive) or freed (negative)
+func (stats *renderStats) add(bytes int64) {
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.inUse += bytes
+	stats.peak = max(stats.peak, stats.inUse)
+}
+
+// peakBytes returns the most page image memory held at the same time
+func (stats *renderStats) peakBytes() int64 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return stats.peak
+}
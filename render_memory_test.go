@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderBudget(t *testing.T) {
+	budget := newRenderBudget(100)
+	ctx := context.Background()
+
+	first, err := budget.acquire(ctx, 60)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), first)
+
+	// A second page only fits once the first one is released
+	acquired := make(chan int64)
+	go func() {
+		reserved, err := budget.acquire(ctx, 60)
+		assert.NoError(t, err)
+		acquired <- reserved
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the budget was exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+	budget.release(first)
+	assert.Equal(t, int64(60), <-acquired)
+	budget.release(60)
+
+	huge, err := budget.acquire(ctx, 500)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), huge, "pages larger than the budget are rendered alone")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = budget.acquire(cancelled, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	budget.release(huge)
+
+	unlimited := newRenderBudget(0)
+	reserved, err := unlimited.acquire(ctx, 1<<40)
+	require.NoError(t, err)
+	assert.Zero(t, reserved)
+}
+
+func TestRenderStats(t *testing.T) {
+	// An A4 page is 595x842 points
+	assert.Equal(t, int64(2479*3508*4), pageRenderBytes(image.Rect(0, 0, 595, 842)))
+
+	stats := &renderStats{}
+	stats.add(100)
+	stats.add(50)
+	stats.add(-100)
+	stats.add(80)
+	assert.Equal(t, int64(150), stats.peakBytes())
+
+	var missing *renderStats
+	missing.add(10)
+	assert.Nil(t, renderStatsFrom(context.Background()))
+	assert.Same(t, stats, renderStatsFrom(withRenderStats(context.Background(), stats)))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultReportShareDuration is how long a share link works if no duration is requested
+	defaultReportShareDuration = 7 * 24 * time.Hour
+	// maxReportShareDuration limits how long a share link can work
+	maxReportShareDuration = 90 * 24 * time.Hour
+)
+
+// errReportShareNotFound is returned for unknown, revoked and expired share tokens alike
+var errReportShareNotFound = errors.New("share link not found or expired")
+
+// ReportShareResponse describes a share link, the token is only included when the link is created
+type ReportShareResponse struct {
+	ID          uint   `json:"id"`
+	ReportID    uint   `json:"report_id"`
+	Token       string `json:"token,omitempty"`
+	URL         string `json:"url,omitempty"` // Path of the public page, relative to paperless-gpt
+	ExpiresAt   string `json:"expires_at"`
+	DateCreated string `json:"date_created"`
+}
+
+// hashShareToken returns the hash a share token is stored and looked up by
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateReportShare creates a share link for a report that works for the given duration
+func CreateReportShare(db *gorm.DB, reportID uint, validFor time.Duration) (*ReportShareResponse, error) {
+	if validFor <= 0 || validFor > maxReportShareDuration {
+		return nil, fmt.Errorf("a share link must be valid for more than 0 and at most %d days", int(maxReportShareDuration.Hours()/24))
+	}
+	if err := db.First(&Report{}, reportID).Error; err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	now := time.Now()
+	record := &ReportShare{
+		ReportID:    reportID,
+		TokenHash:   hashShareToken(token),
+		ExpiresAt:   now.Add(validFor).Format(time.RFC3339),
+		DateCreated: now.Format(time.RFC3339),
+	}
+	if err := db.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	response := record.toResponse()
+	response.Token = token
+	response.URL = "/share/reports/" + token
+	return &response, nil
+}
+
+// GetReportShares retrieves the share links of a report that have not expired, newest first
+func GetReportShares(db *gorm.DB, reportID uint) ([]ReportShareResponse, error) {
+	var records []ReportShare
+	result := db.Where("report_id = ?", reportID).Order("id DESC").Find(&records)
+	shares := make([]ReportShareResponse, 0, len(records))
+	for _, record := range records {
+		if !record.expired() {
+			shares = append(shares, record.toResponse())
+		}
+	}
+	return shares, result.Error
+}
+
+// DeleteReportShare revokes a share link of a report
+func DeleteReportShare(db *gorm.DB, reportID uint, shareID uint) error {
+	result := db.Where("report_id = ?", reportID).Delete(&ReportShare{}, shareID)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return errReportShareNotFound
+	}
+	return result.Error
+}
+
+// GetSharedReport retrieves the share link of a token and its report, as long as the link has not expired
+func GetSharedReport(db *gorm.DB, token string) (*Report, *ReportShare, error) {
+	var share ReportShare
+	if err := db.Where("token_hash = ?", hashShareToken(token)).First(&share).Error; err != nil || share.expired() {
+		return nil, nil, errReportShareNotFound
+	}
+	var report Report
+	if err := db.First(&report, share.ReportID).Error; err != nil {
+		return nil, nil, errReportShareNotFound
+	}
+	return &report, &share, nil
+}
+
+// expired reports whether the share link no longer works
+func (record *ReportShare) expired() bool {
+	expires, err := time.Parse(time.RFC3339, record.ExpiresAt)
+	return err != nil || !time.Now().Before(expires)
+}
+
+// toResponse converts a share link into its API representation
+func (record *ReportShare) toResponse() ReportShareResponse {
+	return ReportShareResponse{
+		ID:          record.ID,
+		ReportID:    record.ReportID,
+		ExpiresAt:   record.ExpiresAt,
+		DateCreated: record.DateCreated,
+	}
+}
+
+// reportSharePage renders a report for the public share page. It shows only what the report stores, so
+// neither paperless-ngx nor the rest of paperless-gpt are reachable from it.
+var reportSharePage = template.Must(template.New("report_share").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Archive report {{.PeriodStart}} - {{.PeriodEnd}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.summary { white-space: pre-wrap; line-height: 1.5; }
+table { border-collapse: collapse; }
+td, th { padding: 0.25rem 1rem 0.25rem 0; text-align: left; }
+footer { margin-top: 2rem; color: #777; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<h1>Archive report</h1>
+<p>{{.PeriodStart}} - {{.PeriodEnd}}</p>
+<p class="summary">{{.Summary}}</p>
+<h2>New documents: {{.Statistics.NewDocuments}}</h2>
+{{if .Statistics.TopCorrespondents}}<h2>Top correspondents</h2>
+<table>
+{{range .Statistics.TopCorrespondents}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Statistics.InvoiceTotals}}<h2>Invoice totals</h2>
+<table>
+{{range $currency, $amount := .Statistics.InvoiceTotals}}<tr><td>{{$currency}}</td><td>{{printf "%.2f" $amount}}</td></tr>
+{{end}}</table>
+{{end}}<footer>Generated on {{.DateCreated}}. This link expires on {{.ExpiresAt}}.</footer>
+</body>
+</html>
+`))
+
+// writeReportSharePage renders the public page of a shared report
+func writeReportSharePage(w io.Writer, report *Report, expiresAt string) error {
+	var statistics ReportStatistics
+	if err := json.Unmarshal([]byte(report.Statistics), &statistics); err != nil {
+		return fmt.Errorf("failed to decode report statistics: %w", err)
+	}
+	return reportSharePage.Execute(w, map[string]interface{}{
+		"PeriodStart": formatShareDate(report.PeriodStart),
+		"PeriodEnd":   formatShareDate(report.PeriodEnd),
+		"Summary":     report.Summary,
+		"Statistics":  statistics,
+		"DateCreated": formatShareDate(report.DateCreated),
+		"ExpiresAt":   formatShareDate(expiresAt),
+	})
+}
+
+// formatShareDate shortens an RFC 3339 date to the day for the share page
+func formatShareDate(value string) string {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.Format("2006-01-02")
+	}
+	return value
+}
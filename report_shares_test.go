@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportShares(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	report, err := InsertReport(env.db, "", ReportStatistics{
+		PeriodStart:       "2024-05-01T00:00:00Z",
+		PeriodEnd:         "2024-05-31T00:00:00Z",
+		NewDocuments:      12,
+		TopCorrespondents: []CorrespondentCount{{Name: "Tax <Office>", Count: 4}},
+		InvoiceTotals:     map[string]float64{"EUR": 123.4},
+	}, "Twelve new documents.")
+	require.NoError(t, err)
+
+	_, err = CreateReportShare(env.db, report.ID, 91*24*time.Hour)
+	assert.Error(t, err)
+	_, err = CreateReportShare(env.db, 987654, time.Hour)
+	assert.Error(t, err)
+
+	share, err := CreateReportShare(env.db, report.ID, time.Hour)
+	require.NoError(t, err)
+	assert.NotEmpty(t, share.Token)
+	assert.Equal(t, "/share/reports/"+share.Token, share.URL)
+
+	var stored ReportShare
+	require.NoError(t, env.db.First(&stored, share.ID).Error)
+	assert.NotContains(t, stored.TokenHash, share.Token)
+
+	shared, record, err := GetSharedReport(env.db, share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, report.ID, shared.ID)
+
+	var page bytes.Buffer
+	require.NoError(t, writeReportSharePage(&page, shared, record.ExpiresAt))
+	assert.Contains(t, page.String(), "Twelve new documents.")
+	assert.Contains(t, page.String(), "2024-05-01 - 2024-05-31")
+	assert.Contains(t, page.String(), "Tax &lt;Office&gt;")
+	assert.Contains(t, page.String(), "<td>EUR</td><td>123.40</td>")
+
+	_, _, err = GetSharedReport(env.db, "unknown")
+	assert.ErrorIs(t, err, errReportShareNotFound)
+
+	// Expired links neither work nor are listed
+	require.NoError(t, env.db.Model(&ReportShare{}).Where("id = ?", share.ID).
+		Update("expires_at", time.Now().Add(-time.Minute).Format(time.RFC3339)).Error)
+	_, _, err = GetSharedReport(env.db, share.Token)
+	assert.ErrorIs(t, err, errReportShareNotFound)
+
+	active, err := CreateReportShare(env.db, report.ID, time.Hour)
+	require.NoError(t, err)
+	shares, err := GetReportShares(env.db, report.ID)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, active.ID, shares[0].ID)
+	assert.Empty(t, shares[0].Token)
+
+	// Revoked links stop working
+	assert.ErrorIs(t, DeleteReportShare(env.db, report.ID+1, active.ID), errReportShareNotFound)
+	require.NoError(t, DeleteReportShare(env.db, report.ID, active.ID))
+	_, _, err = GetSharedReport(env.db, active.Token)
+	assert.ErrorIs(t, err, errReportShareNotFound)
+}
+
+func TestReportShareHandlersCheckOwner(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	app := &App{Database: db}
+	report, err := InsertReport(db, "share-owner", ReportStatistics{}, "Shared summary")
+	require.NoError(t, err)
+	share, err := CreateReportShare(db, report.ID, time.Hour)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/reports/:id/shares", app.createReportShareHandler)
+	router.GET("/api/reports/:id/shares", app.getReportSharesHandler)
+	router.DELETE("/api/reports/:id/shares/:share_id", app.deleteReportShareHandler)
+	serve := func(username, method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(withTenant(context.Background(), username, ""))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	sharesPath := fmt.Sprintf("/api/reports/%d/shares", report.ID)
+	sharePath := fmt.Sprintf("%s/%d", sharesPath, share.ID)
+	assert.Equal(t, http.StatusNotFound, serve("share-other", http.MethodPost, sharesPath))
+	assert.Equal(t, http.StatusNotFound, serve("share-other", http.MethodGet, sharesPath))
+	assert.Equal(t, http.StatusNotFound, serve("share-other", http.MethodDelete, sharePath))
+	_, _, err = GetSharedReport(db, share.Token)
+	assert.NoError(t, err, "others cannot revoke the owner's link")
+
+	assert.Equal(t, http.StatusCreated, serve("share-owner", http.MethodPost, sharesPath))
+	assert.Equal(t, http.StatusOK, serve("share-owner", http.MethodGet, sharesPath))
+	assert.Equal(t, http.StatusNoContent, serve("share-owner", http.MethodDelete, sharePath))
+}
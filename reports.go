@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ReportStatistics holds the figures compiled for an archive report
+type ReportStatistics struct {
+	PeriodStart       string               `json:"period_start"`
+	PeriodEnd         string               `json:"period_end"`
+	NewDocuments      int                  `json:"new_documents"`
+	TopCorrespondents []CorrespondentCount `json:"top_correspondents"`
+	InvoiceTotals     map[string]float64   `json:"invoice_totals"` // Summed amounts per currency
+}
+
+// CorrespondentCount is the number of new documents of a correspondent within a report period
+type CorrespondentCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// maxReportCorrespondents limits the correspondents listed in a report
+const maxReportCorrespondents = 5
+
+// startReportScheduler generates a report whenever the configured cron schedule fires
+func startReportScheduler(app *App, schedule *cronSchedule) {
+	go func() {
+		for {
+			next := schedule.next(time.Now())
+			log.Infof("Next archive report scheduled for %s", next.Format(time.RFC1123))
+			time.Sleep(time.Until(next))
+			if !isLeader() {
+				continue
+			}
+
+			if _, err := app.generateReport(context.Background(), app.lastReportEnd(context.Background())); err != nil {
+				log.Errorf("Error generating scheduled archive report: %v", err)
+			}
+		}
+	}()
+}
+
+// lastReportEnd returns the end of the acting user's latest report, or 30 days ago if there is none
+func (app *App) lastReportEnd(ctx context.Context) time.Time {
+	reports, err := GetReports(app.Database, tenantUsername(ctx))
+	if err == nil && len(reports) > 0 {
+		if end, err := time.Parse(time.RFC3339, reports[0].PeriodEnd); err == nil {
+			return end
+		}
+	}
+	return time.Now().AddDate(0, 0, -30)
+}
+
+// generateReport compiles the statistics of all documents added since the given time,
+// lets the LLM write a summary, stores the report and delivers the optional notification
+func (app *App) generateReport(ctx context.Context, since time.Time) (*Report, error) {
+	now := time.Now()
+
+	query := url.Values{}
+	query.Set("added__gt", since.Format(time.RFC3339))
+	documents, err := app.Client.GetDocumentsByQuery(ctx, query, 10000)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching new documents: %w", err)
+	}
+
+	amountFieldID := 0
+	if reportAmountField != "" {
+		fields, err := app.Client.GetCustomFields(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching custom fields: %w", err)
+		}
+		for _, field := range fields {
+			if strings.EqualFold(field.Name, reportAmountField) {
+				amountFieldID = field.ID
+				break
+			}
+		}
+		if amountFieldID == 0 {
+			log.Warnf("Custom field %q for invoice totals not found", reportAmountField)
+		}
+	}
+
+	statistics := compileReportStatistics(documents, amountFieldID)
+	statistics.PeriodStart = since.Format(time.RFC3339)
+	statistics.PeriodEnd = now.Format(time.RFC3339)
+
+	summary, err := app.getReportSummary(ctx, statistics)
+	if err != nil {
+		return nil, fmt.Errorf("error generating report summary: %w", err)
+	}
+
+	report, err := InsertReport(app.Database, tenantUsername(ctx), statistics, summary)
+	if err != nil {
+		return nil, fmt.Errorf("error storing report: %w", err)
+	}
+	log.Infof("Generated archive report %d covering %d new documents", report.ID, statistics.NewDocuments)
+
+	if reportWebhookURL != "" {
+		if err := notifyReport(ctx, report); err != nil {
+			log.Warnf("Failed to deliver report notification: %v", err)
+		}
+	}
+
+	return report, nil
+}
+
+// compileReportStatistics counts documents per correspondent and sums the monetary custom field with the given ID
+func compileReportStatistics(documents []Document, amountFieldID int) ReportStatistics {
+	statistics := ReportStatistics{
+		NewDocuments:      len(documents),
+		TopCorrespondents: []CorrespondentCount{},
+		InvoiceTotals:     map[string]float64{},
+	}
+
+	counts := map[string]int{}
+	for _, document := range documents {
+		if document.Correspondent != "" {
+			counts[document.Correspondent]++
+		}
+		if amountFieldID == 0 {
+			continue
+		}
+		for _, field := range document.CustomFields {
+			if field.Field != amountFieldID {
+				continue
+			}
+			if currency, amount, ok := parseMonetaryValue(field.Value); ok {
+				statistics.InvoiceTotals[currency] += amount
+			}
+		}
+	}
+
+	for name, count := range counts {
+		statistics.TopCorrespondents = append(statistics.TopCorrespondents, CorrespondentCount{Name: name, Count: count})
+	}
+	sort.Slice(statistics.TopCorrespondents, func(i, j int) bool {
+		a, b := statistics.TopCorrespondents[i], statistics.TopCorrespondents[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	if len(statistics.TopCorrespondents) > maxReportCorrespondents {
+		statistics.TopCorrespondents = statistics.TopCorrespondents[:maxReportCorrespondents]
+	}
+
+	return statistics
+}
+
+// parseMonetaryValue parses a paperless-ngx monetary custom field value such as "EUR123.45" or a plain number
+func parseMonetaryValue(value interface{}) (string, float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return "", v, true
+	case string:
+		v = strings.TrimSpace(v)
+		i := 0
+		for i < len(v) && (v[i] < '0' || v[i] > '9') && v[i] != '-' && v[i] != '.' {
+			i++
+		}
+		amount, err := strconv.ParseFloat(v[i:], 64)
+		if err != nil {
+			return "", 0, false
+		}
+		return strings.ToUpper(v[:i]), amount, true
+	default:
+		return "", 0, false
+	}
+}
+
+// getReportSummary asks the LLM for a narrative summary of the report statistics
+func (app *App) getReportSummary(ctx context.Context, statistics ReportStatistics) (string, error) {
+	templateMutex.RLock()
+	defer templateMutex.RUnlock()
+
+	var promptBuffer bytes.Buffer
+	err := reportTemplate.Execute(&promptBuffer, map[string]interface{}{
+		"Language":          getLikelyLanguage(),
+		"PeriodStart":       statistics.PeriodStart,
+		"PeriodEnd":         statistics.PeriodEnd,
+		"NewDocuments":      statistics.NewDocuments,
+		"TopCorrespondents": statistics.TopCorrespondents,
+		"InvoiceTotals":     statistics.InvoiceTotals,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error executing report template: %w", err)
+	}
+
+	prompt := promptBuffer.String()
+	log.Debugf("Report prompt: %s", prompt)
+
+	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
+		{
+			Parts: []llms.ContentPart{
+				llms.TextContent{
+					Text: prompt,
+				},
+			},
+			Role: llms.ChatMessageTypeHuman,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
+	}
+
+	return strings.TrimSpace(stripReasoning(completion.Choices[0].Content)), nil
+}
+
+// notifyReport posts the report as JSON to the configured webhook
+func notifyReport(ctx context.Context, report *Report) error {
+	return postWebhook(ctx, reportWebhookURL, report.toResponse())
+}
+
+// webhookTimeout bounds the delivery of a webhook, so an unresponsive receiver cannot hold up report generation
+const webhookTimeout = 30 * time.Second
+
+// webhookClient delivers report and job webhooks. Without a proxy newHTTPClient cannot fail.
+var webhookClient, _ = newHTTPClient(webhookTimeout, "")
+
+// postWebhook posts a payload as JSON to a webhook URL
+func postWebhook(ctx context.Context, webhookURL string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// cronSchedule is a parsed five-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	daysRestricted, weekdaysRestricted     bool
+}
+
+// parseCronSchedule parses a standard five-field cron expression or one of @hourly, @daily, @weekly, @monthly
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	switch strings.TrimSpace(expression) {
+	case "@hourly":
+		expression = "0 * * * *"
+	case "@daily":
+		expression = "0 0 * * *"
+	case "@weekly":
+		expression = "0 0 * * 0"
+	case "@monthly":
+		expression = "0 0 1 * *"
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %q: %w", field, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be written as 0 or 7
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &cronSchedule{
+		minutes:            sets[0],
+		hours:              sets[1],
+		days:               sets[2],
+		months:             sets[3],
+		weekdays:           sets[4],
+		daysRestricted:     fields[2] != "*",
+		weekdaysRestricted: fields[4] != "*",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps within the given bounds
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			part, step = rangePart, parsed
+		}
+
+		start, end := min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", to)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+// next returns the first time after t that matches the schedule
+func (schedule *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// A matching time exists within four years for every valid expression (e.g. February 29th)
+	for limit := t.AddDate(4, 0, 0); t.Before(limit); t = t.Add(time.Minute) {
+		if !schedule.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if schedule.hours[t.Hour()] && schedule.minutes[t.Minute()] {
+			return t
+		}
+	}
+	return t
+}
+
+// matchesDay applies the cron rule that day-of-month and day-of-week are combined with OR when both are restricted
+func (schedule *cronSchedule) matchesDay(t time.Time) bool {
+	dayMatch := schedule.days[t.Day()]
+	weekdayMatch := schedule.weekdays[int(t.Weekday())]
+	if schedule.daysRestricted && schedule.weekdaysRestricted {
+		return dayMatch || weekdayMatch
+	}
+	return dayMatch && weekdayMatch
+}
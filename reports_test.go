@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   time.Time
+	}{
+		{"Every 15 minutes", "*/15 * * * *", time.Date(2024, time.January, 31, 10, 45, 0, 0, time.UTC)},
+		{"Daily shortcut", "@daily", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"Mondays at 8", "0 8 * * 1", time.Date(2024, time.February, 5, 8, 0, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 8 * * 7", time.Date(2024, time.February, 4, 8, 0, 0, 0, time.UTC)},
+		{"First of month", "0 6 1 * *", time.Date(2024, time.February, 1, 6, 0, 0, 0, time.UTC)},
+		{"Leap day", "0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"Day of month or weekday", "0 9 15 * 5", time.Date(2024, time.February, 2, 9, 0, 0, 0, time.UTC)},
+		{"Range and list", "0 9-11,14 * * *", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tc.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, schedule.next(start))
+		})
+	}
+
+	for _, invalid := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := parseCronSchedule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCompileReportStatistics(t *testing.T) {
+	documents := []Document{
+		{ID: 1, Correspondent: "Amazon", CustomFields: []CustomFieldValue{{Field: 4, Value: "EUR12.50"}}},
+		{ID: 2, Correspondent: "Amazon", CustomFields: []CustomFieldValue{{Field: 4, Value: "EUR7.50"}, {Field: 5, Value: "EUR100"}}},
+		{ID: 3, Correspondent: "Bank", CustomFields: []CustomFieldValue{{Field: 4, Value: "USD3"}}},
+		{ID: 4, CustomFields: []CustomFieldValue{{Field: 4, Value: nil}}},
+	}
+
+	statistics := compileReportStatistics(documents, 4)
+
+	assert.Equal(t, 4, statistics.NewDocuments)
+	assert.Equal(t, []CorrespondentCount{{Name: "Amazon", Count: 2}, {Name: "Bank", Count: 1}}, statistics.TopCorrespondents)
+	assert.Equal(t, map[string]float64{"EUR": 20, "USD": 3}, statistics.InvoiceTotals)
+}
+
+func TestParseMonetaryValue(t *testing.T) {
+	currency, amount, ok := parseMonetaryValue("eur1234.56")
+	assert.True(t, ok)
+	assert.Equal(t, "EUR", currency)
+	assert.Equal(t, 1234.56, amount)
+
+	currency, amount, ok = parseMonetaryValue(42.0)
+	assert.True(t, ok)
+	assert.Equal(t, "", currency)
+	assert.Equal(t, 42.0, amount)
+
+	_, _, ok = parseMonetaryValue("EUR")
+	assert.False(t, ok)
+}
+
+func TestPostWebhook(t *testing.T) {
+	var contentType string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	require.NoError(t, postWebhook(context.Background(), server.URL, map[string]string{"event": "report"}))
+	assert.Equal(t, "application/json", contentType)
+
+	status = http.StatusBadGateway
+	assert.ErrorContains(t, postWebhook(context.Background(), server.URL, nil), "status 502")
+
+	// An unresponsive receiver cannot block forever
+	assert.Equal(t, webhookTimeout, webhookClient.Timeout)
+}
+
+func TestReportsPerUser(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	report, err := InsertReport(db, "alice", ReportStatistics{PeriodEnd: "2024-05-31T00:00:00Z"}, "Summary for alice")
+	require.NoError(t, err)
+
+	reports, err := GetReports(db, "alice")
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	_, err = GetReport(db, report.ID, "alice")
+	assert.NoError(t, err)
+
+	reports, err = GetReports(db, "bob")
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+	_, err = GetReport(db, report.ID, "bob")
+	assert.Error(t, err)
+
+	app := &App{Database: db}
+	end := app.lastReportEnd(withTenant(context.Background(), "alice", ""))
+	assert.Equal(t, "2024-05-31T00:00:00Z", end.UTC().Format(time.RFC3339))
+	assert.True(t, app.lastReportEnd(withTenant(context.Background(), "bob", "")).After(end), "bob's period does not continue alice's reports")
+}
+
+func TestGenerateReportContinuesAfterPeriodEnd(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalTemplate := reportTemplate
+	defer func() { reportTemplate = originalTemplate }()
+	reportTemplate = template.Must(template.New("report").Parse("Summarize {{.NewDocuments}} documents"))
+
+	since := time.Date(2024, time.May, 31, 15, 4, 5, 0, time.UTC)
+	for _, path := range []string{"/api/tags/", "/api/correspondents/", "/api/document_types/", "/api/custom_fields/"} {
+		env.setMockResponse(path, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results": [], "next": null}`))
+		})
+	}
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		// Documents added earlier on the day the previous report ended were already counted
+		assert.Equal(t, "2024-05-31T15:04:05Z", r.URL.Query().Get("added__gt"))
+		assert.Empty(t, r.URL.Query().Get("added__date__gte"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 1, "title": "Invoice", "tags": []}], "next": null}`))
+	})
+
+	app := &App{Client: env.client, Database: env.db, LLM: &scriptedLLM{responses: []string{"One new document."}}}
+	report, err := app.generateReport(withTenant(context.Background(), "report-period-user", ""), since)
+	require.NoError(t, err)
+	assert.Equal(t, "One new document.", report.Summary)
+	assert.Equal(t, "2024-05-31T15:04:05Z", report.PeriodStart)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sandboxDocument is a document of the built-in fake paperless-ngx API
+type sandboxDocument struct {
+	ID            int                `json:"id"`
+	Title         string             `json:"title"`
+	Content       string             `json:"content"`
+	Tags          []int              `json:"tags"`
+	Correspondent *int               `json:"correspondent"`
+	DocumentType  *int               `json:"document_type"`
+	CustomFields  []CustomFieldValue `json:"custom_fields"`
+	PageCount     int                `json:"page_count"`
+	Created       string             `json:"created"`
+	Added         string             `json:"added"`
+	Modified      string             `json:"modified"`
+}
+
+// sandboxItem is a named paperless-ngx object such as a tag or correspondent
+type sandboxItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// sandboxServer is a small in-memory imitation of the paperless-ngx API used by SANDBOX_MODE
+type sandboxServer struct {
+	mutex          sync.Mutex
+	documents      []*sandboxDocument
+	tags           []sandboxItem
+	correspondents []sandboxItem
+}
+
+// newSandboxServer creates a fake paperless-ngx API seeded with sample documents
+func newSandboxServer() *sandboxServer {
+	current := settings()
+	server := &sandboxServer{
+		tags: []sandboxItem{
+			{ID: 1, Name: current.ManualTag},
+			{ID: 2, Name: current.AutoTag},
+			{ID: 3, Name: "Invoice"},
+			{ID: 4, Name: "Insurance"},
+			{ID: 5, Name: "Bank"},
+			{ID: 6, Name: "Contract"},
+			{ID: 7, Name: "Health"},
+		},
+		correspondents: []sandboxItem{
+			{ID: 1, Name: "Amazon"},
+			{ID: 2, Name: "Sparkasse"},
+		},
+	}
+
+	samples := []struct {
+		title   string
+		content string
+		tags    []int
+	}{
+		{"scan_0001", "Amazon EU S.a.r.l.\nInvoice no. 302-1234567\nDate: 2024-03-12\n1x USB-C cable 9.99 EUR\nTotal: 9.99 EUR", []int{1}},
+		{"scan_0002", "Sparkasse Musterstadt\nAccount statement 03/2024\nOpening balance: 1,204.50 EUR\nClosing balance: 1,530.10 EUR", []int{1}},
+		{"scan_0003", "Allianz Versicherungs-AG\nYour car insurance policy KFZ-88123\nAnnual premium: 412.00 EUR\nStart: 2024-04-01", []int{1}},
+		{"scan_0004", "Dr. med. Anna Schmidt\nMedical invoice for treatment on 2024-02-20\nAmount due: 86.40 EUR", []int{}},
+	}
+	for i, sample := range samples {
+		server.documents = append(server.documents, &sandboxDocument{
+			ID:           i + 1,
+			Title:        sample.title,
+			Content:      sample.content,
+			Tags:         sample.tags,
+			CustomFields: []CustomFieldValue{},
+			PageCount:    1,
+			Created:      "2024-03-12T00:00:00Z",
+			Added:        "2024-03-12T00:00:00Z",
+			Modified:     "2024-03-12T00:00:00Z",
+		})
+	}
+	return server
+}
+
+// startSandboxServer serves the fake paperless-ngx API on a random local port and returns its base URL
+func startSandboxServer() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		if err := http.Serve(listener, newSandboxServer().handler()); err != nil {
+			log.Errorf("Sandbox paperless API stopped: %v", err)
+		}
+	}()
+	return fmt.Sprintf("http://%s", listener.Addr().String()), nil
+}
+
+// handler routes the paperless-ngx endpoints used by paperless-gpt
+func (server *sandboxServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/documents/", server.listDocuments)
+	mux.HandleFunc("GET /api/documents/{id}/", server.getDocument)
+	mux.HandleFunc("PATCH /api/documents/{id}/", server.updateDocument)
+	mux.HandleFunc("POST /api/documents/bulk_edit/", server.bulkEditDocuments)
+	mux.HandleFunc("GET /api/documents/{id}/download/", server.downloadDocument)
+	mux.HandleFunc("GET /api/tags/", server.listItems(&server.tags))
+	mux.HandleFunc("POST /api/tags/", server.createItem(&server.tags))
+	mux.HandleFunc("DELETE /api/tags/{id}/", server.deleteItem(&server.tags))
+	mux.HandleFunc("GET /api/correspondents/", server.listItems(&server.correspondents))
+	mux.HandleFunc("POST /api/correspondents/", server.createItem(&server.correspondents))
+	mux.HandleFunc("GET /api/custom_fields/", server.emptyList)
+	mux.HandleFunc("GET /api/document_types/", server.emptyList)
+	mux.HandleFunc("GET /api/saved_views/", server.emptyList)
+	mux.HandleFunc("GET /api/ui_settings/", func(w http.ResponseWriter, r *http.Request) {
+		writeSandboxJSON(w, http.StatusOK, map[string]interface{}{"user": map[string]string{"username": "sandbox"}})
+	})
+	return mux
+}
+
+// listDocuments supports filtering by tag names and pagination
+func (server *sandboxServer) listDocuments(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	query := r.URL.Query()
+	matches := []*sandboxDocument{}
+	for _, document := range server.documents {
+		if server.hasTagNames(document, query["tags__name__iexact"]) {
+			matches = append(matches, document)
+		}
+	}
+
+	pageSize, err := strconv.Atoi(query.Get("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = 25
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*pageSize, len(matches))
+	end := min(start+pageSize, len(matches))
+
+	writeSandboxJSON(w, http.StatusOK, map[string]interface{}{
+		"count":   len(matches),
+		"results": matches[start:end],
+	})
+}
+
+// hasTagNames reports whether a document carries all given tag names (case-insensitive)
+func (server *sandboxServer) hasTagNames(document *sandboxDocument, names []string) bool {
+	for _, name := range names {
+		found := false
+		for _, tag := range server.tags {
+			if strings.EqualFold(tag.Name, name) && containsInt(document.Tags, tag.ID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (server *sandboxServer) getDocument(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	document := server.findDocument(r)
+	if document == nil {
+		writeSandboxJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+		return
+	}
+	writeSandboxJSON(w, http.StatusOK, document)
+}
+
+func (server *sandboxServer) updateDocument(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	document := server.findDocument(r)
+	if document == nil {
+		writeSandboxJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+		return
+	}
+	// Decoding into the stored document only overwrites the fields present in the PATCH body
+	if err := json.NewDecoder(r.Body).Decode(document); err != nil {
+		writeSandboxJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		return
+	}
+	writeSandboxJSON(w, http.StatusOK, document)
+}
+
+// bulkEditDocuments supports the modify_tags method of the paperless-ngx bulk_edit endpoint
+func (server *sandboxServer) bulkEditDocuments(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Documents  []int  `json:"documents"`
+		Method     string `json:"method"`
+		Parameters struct {
+			AddTags    []int `json:"add_tags"`
+			RemoveTags []int `json:"remove_tags"`
+		} `json:"parameters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeSandboxJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		return
+	}
+	if request.Method != "modify_tags" {
+		writeSandboxJSON(w, http.StatusBadRequest, map[string]string{"detail": fmt.Sprintf("Unsupported method %q.", request.Method)})
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, document := range server.documents {
+		if !containsInt(request.Documents, document.ID) {
+			continue
+		}
+		tags := []int{}
+		for _, tagID := range document.Tags {
+			if !containsInt(request.Parameters.RemoveTags, tagID) {
+				tags = append(tags, tagID)
+			}
+		}
+		for _, tagID := range request.Parameters.AddTags {
+			if !containsInt(tags, tagID) {
+				tags = append(tags, tagID)
+			}
+		}
+		document.Tags = tags
+	}
+	writeSandboxJSON(w, http.StatusOK, map[string]string{"result": "OK"})
+}
+
+// downloadDocument returns a generated single page PDF containing the document content
+func (server *sandboxServer) downloadDocument(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	document := server.findDocument(r)
+	server.mutex.Unlock()
+
+	if document == nil {
+		writeSandboxJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.WriteHeader(http.StatusOK)
+	w.Write(sandboxPDF(document.Content))
+}
+
+func (server *sandboxServer) findDocument(r *http.Request) *sandboxDocument {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return nil
+	}
+	for _, document := range server.documents {
+		if document.ID == id {
+			return document
+		}
+	}
+	return nil
+}
+
+func (server *sandboxServer) listItems(items *[]sandboxItem) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		writeSandboxJSON(w, http.StatusOK, map[string]interface{}{
+			"count":   len(*items),
+			"next":    nil,
+			"results": *items,
+		})
+	}
+}
+
+func (server *sandboxServer) createItem(items *[]sandboxItem) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var item sandboxItem
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil || item.Name == "" {
+			writeSandboxJSON(w, http.StatusBadRequest, map[string]string{"detail": "name is required"})
+			return
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		item.ID = 1
+		for _, existing := range *items {
+			item.ID = max(item.ID, existing.ID+1)
+		}
+		*items = append(*items, item)
+		writeSandboxJSON(w, http.StatusCreated, item)
+	}
+}
+
+func (server *sandboxServer) deleteItem(items *[]sandboxItem) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		for i, item := range *items {
+			if item.ID == id {
+				*items = append((*items)[:i], (*items)[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		writeSandboxJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+	}
+}
+
+func (server *sandboxServer) emptyList(w http.ResponseWriter, r *http.Request) {
+	writeSandboxJSON(w, http.StatusOK, map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+}
+
+func writeSandboxJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Version", "sandbox")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxPDF builds a minimal single page PDF that shows the given text line by line
+func sandboxPDF(text string) []byte {
+	var stream bytes.Buffer
+	stream.WriteString("BT /F1 12 Tf 50 800 Td 16 TL\n")
+	for _, line := range strings.Split(text, "\n") {
+		escaped := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line)
+		fmt.Fprintf(&stream, "(%s) Tj T*\n", escaped)
+	}
+	stream.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxServer(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "paperless-gpt" })
+
+	server := httptest.NewServer(newSandboxServer().handler())
+	defer server.Close()
+	client := NewPaperlessClient(server.URL, "sandbox")
+	ctx := context.Background()
+
+	documents, err := client.GetDocumentsByTags(ctx, []string{"PAPERLESS-GPT"}, 25)
+	require.NoError(t, err)
+	require.Len(t, documents, 3)
+	assert.Equal(t, "scan_0001", documents[0].Title)
+	assert.Equal(t, []string{"paperless-gpt"}, documents[0].Tags)
+
+	// Tags created on the fly are stored and applied
+	require.NoError(t, client.ModifyDocumentTags(ctx, 4, []string{"Health", "Doctor"}, nil))
+	document, err := client.GetDocument(ctx, 4)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Health", "Doctor"}, document.Tags)
+	assert.Equal(t, "", document.Correspondent)
+
+	// Tags can be changed on many documents with a single bulk edit
+	require.NoError(t, client.BulkEditTags(ctx, []int{1, 2}, []string{"Invoice"}, []string{"paperless-gpt"}))
+	for _, documentID := range []int{1, 2} {
+		document, err := client.GetDocument(ctx, documentID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Invoice"}, document.Tags)
+	}
+	document, err = client.GetDocument(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"paperless-gpt"}, document.Tags)
+
+	// The generated PDF can be rendered for OCR
+	imagePaths, err := client.DownloadDocumentAsImages(ctx, 4, 0)
+	require.NoError(t, err)
+	require.Len(t, imagePaths, 1)
+	for _, imagePath := range imagePaths {
+		os.Remove(imagePath)
+	}
+}
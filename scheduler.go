@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// minTaskBackoff is the wait after the first failed cycle of a task, doubled with every further failure
+	minTaskBackoff = 10 * time.Second
+	// maxTaskBackoff limits the wait after failed cycles
+	maxTaskBackoff = time.Hour
+)
+
+// errUnknownTask is returned for pause and resume requests of a task that is not scheduled
+var errUnknownTask = errors.New("unknown background task")
+
+// backgroundTask is a loop polling paperless for the documents of one queue
+type backgroundTask struct {
+	name     string
+	interval func() time.Duration // Read before every wait, so a config reload changes it
+	enabled  func() bool
+	process  func(ctx context.Context) (int, error)
+	wakeup   chan struct{}
+
+	mu        sync.Mutex
+	paused    bool
+	running   bool
+	backoff   time.Duration
+	lastRun   time.Time
+	nextRun   time.Time
+	lastError string
+}
+
+// BackgroundTaskStatus describes a background task as returned by GET /api/background
+type BackgroundTaskStatus struct {
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Paused    bool   `json:"paused"`
+	Running   bool   `json:"running"`
+	Interval  string `json:"interval"`
+	LastRun   string `json:"last_run,omitempty"`
+	NextRun   string `json:"next_run,omitempty"`
+	LastError string `json:"last_error,omitempty"` // Error of the last cycle, retried after the backoff
+}
+
+// taskScheduler runs the background tasks, each with its own interval and backoff. Cycles of different tasks
+// do not overlap, so a document in several queues is never processed by two tasks at once.
+type taskScheduler struct {
+	tasks []*backgroundTask
+	cycle sync.Mutex
+}
+
+// scheduler holds the background tasks started by startBackgroundTasks
+var scheduler = &taskScheduler{}
+
+// newBackgroundTasks returns the OCR and tagging tasks. A task whose queue is disabled, e.g. after a config
+// reload, skips its cycles.
+func (app *App) newBackgroundTasks() []*backgroundTask {
+	queue := func(process func(context.Context) (int, error)) func(context.Context) (int, error) {
+		// In multi-tenant mode the documents of every registered user are processed with their own token
+		return func(ctx context.Context) (int, error) {
+			return app.forEachTenant(ctx, func(ctx context.Context) (int, error) {
+				if err := app.sampleBacklog(ctx, time.Now()); err != nil {
+					log.Warnf("Failed to sample the queue backlog: %v", err)
+				}
+				return process(ctx)
+			})
+		}
+	}
+	return []*backgroundTask{
+		newBackgroundTask(queueOcr, func() time.Duration { return settings().AutoOcrInterval }, isOcrEnabled, queue(app.processAutoOcrTagDocuments)),
+		newBackgroundTask(queueTagging, func() time.Duration { return settings().AutoTagInterval }, isLLMEnabled, queue(app.processAutoTagDocuments)),
+	}
+}
+
+// newBackgroundTask creates a task that processes documents every interval while enabled
+func newBackgroundTask(name string, interval func() time.Duration, enabled func() bool, process func(context.Context) (int, error)) *backgroundTask {
+	return &backgroundTask{
+		name:     name,
+		interval: interval,
+		enabled:  enabled,
+		process:  process,
+		wakeup:   make(chan struct{}, 1),
+		backoff:  minTaskBackoff,
+	}
+}
+
+// start runs the tasks and forwards wakeBackgroundProcessing to all of them
+func (s *taskScheduler) start(tasks []*backgroundTask) {
+	s.tasks = tasks
+	for _, task := range tasks {
+		go s.run(task)
+	}
+	go func() {
+		for range backgroundWakeup {
+			for _, task := range tasks {
+				task.wake()
+			}
+		}
+	}()
+}
+
+// run loops over the cycles of a task. Only the leader processes documents.
+func (s *taskScheduler) run(task *backgroundTask) {
+	for {
+		if !isLeader() || task.isPaused() || !task.enabled() {
+			task.wait(jittered(task.interval()))
+			continue
+		}
+		task.wait(s.runCycle(task, time.Now()))
+	}
+}
+
+// runCycle processes one cycle of a task and returns how long to wait before the next one
+func (s *taskScheduler) runCycle(task *backgroundTask, now time.Time) time.Duration {
+	task.mu.Lock()
+	task.running = true
+	task.mu.Unlock()
+
+	s.cycle.Lock()
+	processed, err := task.process(context.Background())
+	s.cycle.Unlock()
+
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	task.running = false
+	task.lastRun = now
+
+	wait := time.Duration(0) // Continue right away while there are documents
+	if err != nil {
+		log.Errorf("Error in background task %s: %v", task.name, err)
+		task.lastError = err.Error()
+		wait = task.backoff
+		task.backoff = min(task.backoff*2, maxTaskBackoff) // Exponential backoff
+		if wait == maxTaskBackoff {
+			log.Warnf("Repeated errors in background task %s detected. Setting backoff to %v", task.name, maxTaskBackoff)
+		}
+	} else {
+		task.lastError = ""
+		task.backoff = minTaskBackoff
+		if processed == 0 {
+			wait = jittered(task.interval())
+		}
+	}
+	task.nextRun = now.Add(wait)
+	return wait
+}
+
+// wait waits for the given duration unless the task is woken up. Waking up also resets the backoff, e.g.
+// re-queued documents are retried right away.
+func (task *backgroundTask) wait(wait time.Duration) {
+	if wait <= 0 {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-task.wakeup:
+		task.mu.Lock()
+		task.backoff = minTaskBackoff
+		task.mu.Unlock()
+	}
+}
+
+// wake starts the next cycle of the task right away
+func (task *backgroundTask) wake() {
+	select {
+	case task.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// isPaused reports whether the task was paused through the API
+func (task *backgroundTask) isPaused() bool {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	return task.paused
+}
+
+// status returns the API representation of the task
+func (task *backgroundTask) status() BackgroundTaskStatus {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	status := BackgroundTaskStatus{
+		Name:      task.name,
+		Enabled:   task.enabled(),
+		Paused:    task.paused,
+		Running:   task.running,
+		Interval:  task.interval().String(),
+		LastError: task.lastError,
+	}
+	if !task.lastRun.IsZero() {
+		status.LastRun = task.lastRun.Format(time.RFC3339)
+	}
+	if !task.nextRun.IsZero() && !task.paused {
+		status.NextRun = task.nextRun.Format(time.RFC3339)
+	}
+	return status
+}
+
+// statuses returns the status of every task
+func (s *taskScheduler) statuses() []BackgroundTaskStatus {
+	statuses := make([]BackgroundTaskStatus, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		statuses = append(statuses, task.status())
+	}
+	return statuses
+}
+
+// setPaused pauses or resumes the named task, or all tasks if the name is empty. A running cycle is
+// finished first. Resumed tasks start their next cycle right away.
+func (s *taskScheduler) setPaused(name string, paused bool) ([]BackgroundTaskStatus, error) {
+	var selected []*backgroundTask
+	for _, task := range s.tasks {
+		if name == "" || task.name == name {
+			selected = append(selected, task)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w %q", errUnknownTask, name)
+	}
+
+	statuses := make([]BackgroundTaskStatus, 0, len(selected))
+	for _, task := range selected {
+		task.mu.Lock()
+		changed := task.paused != paused
+		task.paused = paused
+		task.mu.Unlock()
+		if changed && paused {
+			log.Infof("Background task %s paused", task.name)
+		} else if changed {
+			log.Infof("Background task %s resumed", task.name)
+			task.wake()
+		}
+		statuses = append(statuses, task.status())
+	}
+	return statuses, nil
+}
+
+// jittered adds a random delay of up to POLLING_JITTER to a wait
+func jittered(wait time.Duration) time.Duration {
+	current := settings()
+	if current.PollingJitter <= 0 {
+		return wait
+	}
+	return wait + time.Duration(rand.Int63n(int64(current.PollingJitter)))
+}
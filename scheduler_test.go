@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerPauseResume(t *testing.T) {
+	enabled := func() bool { return true }
+	process := func(context.Context) (int, error) { return 0, nil }
+	s := &taskScheduler{tasks: []*backgroundTask{
+		newBackgroundTask(queueOcr, func() time.Duration { return time.Minute }, enabled, process),
+		newBackgroundTask(queueTagging, func() time.Duration { return 5 * time.Minute }, enabled, process),
+	}}
+
+	statuses, err := s.setPaused(queueOcr, true)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Paused)
+	assert.False(t, s.tasks[1].isPaused(), "other tasks keep running")
+
+	_, err = s.setPaused("unknown", true)
+	assert.ErrorIs(t, err, errUnknownTask)
+
+	// Resuming all tasks wakes up the paused one
+	statuses, err = s.setPaused("", false)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Paused)
+	assert.Len(t, s.tasks[0].wakeup, 1)
+	assert.Empty(t, s.tasks[1].wakeup, "tasks that were not paused are not woken up")
+	assert.Equal(t, "5m0s", statuses[1].Interval)
+}
+
+func TestJittered(t *testing.T) {
+	setTestSettings(t, func(s *runtimeSettings) { s.PollingJitter = 0 })
+	assert.Equal(t, time.Minute, jittered(time.Minute))
+
+	setTestSettings(t, func(s *runtimeSettings) { s.PollingJitter = 10 * time.Second })
+	for i := 0; i < 20; i++ {
+		wait := jittered(time.Minute)
+		assert.GreaterOrEqual(t, wait, time.Minute)
+		assert.Less(t, wait, time.Minute+10*time.Second)
+	}
+}
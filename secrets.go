@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// errNoSecretsKey is returned when a secret has to be encrypted or decrypted without SECRETS_KEY
+var errNoSecretsKey = errors.New("SECRETS_KEY is not set")
+
+// secretFormat prefixes encrypted secrets, followed by the ID of the key they were encrypted with
+const secretFormat = "v1"
+
+// redactedSecret replaces secrets in logs and API responses
+const redactedSecret = "[REDACTED]"
+
+// minRedactedSecretLength keeps short values such as "test" from being redacted everywhere
+const minRedactedSecretLength = 8
+
+// readSecretsKeyFile reads SECRETS_KEY from a key file, e.g. a mounted Docker or Kubernetes secret
+func readSecretsKeyFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(content))
+	if key == "" {
+		return "", fmt.Errorf("key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// secretKeyID identifies a key in encrypted secrets without revealing it
+func secretKeyID(passphrase string) string {
+	key := sha256.Sum256([]byte(passphrase))
+	id := sha256.Sum256(key[:])
+	return hex.EncodeToString(id[:4])
+}
+
+// secretCipher creates the AES-256-GCM cipher for a key. Any passphrase is accepted; it is hashed to the key size.
+func secretCipher(passphrase string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret encrypts a secret with SECRETS_KEY for storage in the local database
+// as "v1:<key id>:<base64 of nonce and ciphertext>"
+func encryptSecret(plaintext string) (string, error) {
+	if secretsKey == "" {
+		return "", errNoSecretsKey
+	}
+	gcm, err := secretCipher(secretsKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	registerSecret(plaintext)
+	sealed := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+	return fmt.Sprintf("%s:%s:%s", secretFormat, secretKeyID(secretsKey), sealed), nil
+}
+
+// decryptSecret decrypts a secret encrypted by encryptSecret with SECRETS_KEY or one of SECRETS_PREVIOUS_KEYS.
+// Secrets stored before key IDs were added are tried with every key.
+func decryptSecret(encrypted string) (string, error) {
+	if secretsKey == "" {
+		return "", errNoSecretsKey
+	}
+	keys := append([]string{secretsKey}, secretsPreviousKeys...)
+
+	sealed := encrypted
+	if parts := strings.SplitN(encrypted, ":", 3); len(parts) == 3 && parts[0] == secretFormat {
+		sealed = parts[2]
+		var matching []string
+		for _, key := range keys {
+			if secretKeyID(key) == parts[1] {
+				matching = append(matching, key)
+			}
+		}
+		if len(matching) == 0 {
+			return "", fmt.Errorf("secret was encrypted with an unknown key %s, add it to SECRETS_PREVIOUS_KEYS", parts[1])
+		}
+		keys = matching
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	for _, key := range keys {
+		gcm, err := secretCipher(key)
+		if err != nil {
+			return "", err
+		}
+		if len(data) < gcm.NonceSize() {
+			return "", errors.New("invalid encrypted secret: too short")
+		}
+		plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+		if err == nil {
+			registerSecret(string(plaintext))
+			return string(plaintext), nil
+		}
+	}
+	return "", errors.New("could not decrypt secret, was SECRETS_KEY changed without listing the old key in SECRETS_PREVIOUS_KEYS?")
+}
+
+// secretNeedsRotation reports whether a stored secret is not encrypted with the current SECRETS_KEY
+func secretNeedsRotation(encrypted string) bool {
+	return !strings.HasPrefix(encrypted, fmt.Sprintf("%s:%s:", secretFormat, secretKeyID(secretsKey)))
+}
+
+// secretRedactor replaces known secrets in text
+var secretRedactor = struct {
+	sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}{secrets: map[string]bool{}, replacer: strings.NewReplacer()}
+
+// registerSecret redacts a value from logs and API responses from now on
+func registerSecret(secret string) {
+	if len(secret) < minRedactedSecretLength {
+		return
+	}
+	secretRedactor.Lock()
+	defer secretRedactor.Unlock()
+	if secretRedactor.secrets[secret] {
+		return
+	}
+	secretRedactor.secrets[secret] = true
+
+	// Longer secrets first, so a secret containing another one is replaced as a whole
+	secrets := make([]string, 0, len(secretRedactor.secrets))
+	for s := range secretRedactor.secrets {
+		secrets = append(secrets, s)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, redactedSecret)
+	}
+	secretRedactor.replacer = strings.NewReplacer(pairs...)
+}
+
+// redactSecrets replaces all registered secrets in a text
+func redactSecrets(text string) string {
+	secretRedactor.RLock()
+	defer secretRedactor.RUnlock()
+	return secretRedactor.replacer.Replace(text)
+}
+
+// redactionHook removes registered secrets from log messages and string fields
+type redactionHook struct{}
+
+// Levels applies the hook to all log levels
+func (redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the entry before it is formatted
+func (redactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = redactSecrets(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = redactSecrets(v)
+		case error:
+			entry.Data[key] = redactSecrets(v.Error())
+		}
+	}
+	return nil
+}
+
+// redactingWriter redacts registered secrets from JSON and text responses
+type redactingWriter struct {
+	gin.ResponseWriter
+}
+
+// Write redacts the body if it is JSON or text and its length was not announced
+func (w *redactingWriter) Write(data []byte) (int, error) {
+	contentType := w.Header().Get("Content-Type")
+	if w.Header().Get("Content-Length") != "" ||
+		!(strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/")) {
+		return w.ResponseWriter.Write(data)
+	}
+	if _, err := w.ResponseWriter.Write([]byte(redactSecrets(string(data)))); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// WriteString redacts the body like Write
+func (w *redactingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// redactResponses is a middleware that keeps registered secrets out of API responses
+func redactResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &redactingWriter{ResponseWriter: c.Writer}
+		c.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	originalKey := secretsKey
+	defer func() { secretsKey = originalKey }()
+
+	secretsKey = "correct horse battery staple"
+	encrypted, err := encryptSecret("paperless-token")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "paperless-token")
+
+	// Every encryption uses a fresh nonce
+	again, err := encryptSecret("paperless-token")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	decrypted, err := decryptSecret(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "paperless-token", decrypted)
+
+	secretsKey = "another key"
+	_, err = decryptSecret(encrypted)
+	assert.Error(t, err)
+
+	secretsKey = ""
+	_, err = encryptSecret("paperless-token")
+	assert.ErrorIs(t, err, errNoSecretsKey)
+}
+
+func TestSecretKeyRotation(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	require.NoError(t, db.Where("1 = 1").Delete(&TenantUser{}).Error)
+
+	originalKey, originalPrevious := secretsKey, secretsPreviousKeys
+	defer func() { secretsKey, secretsPreviousKeys = originalKey, originalPrevious }()
+
+	secretsKey, secretsPreviousKeys = "old key", nil
+	require.NoError(t, SaveTenantUser(db, "alice", "alice-token"))
+
+	// Without the old key the token can no longer be decrypted
+	secretsKey = "new key"
+	_, err = RotateTenantTokens(db)
+	assert.ErrorContains(t, err, "SECRETS_PREVIOUS_KEYS")
+
+	secretsPreviousKeys = []string{"old key"}
+	rotated, err := RotateTenantTokens(db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rotated)
+
+	user, err := GetTenantUser(db, "alice")
+	require.NoError(t, err)
+	assert.False(t, secretNeedsRotation(user.EncryptedToken))
+
+	// Once rotated the old key is not needed anymore
+	secretsPreviousKeys = nil
+	token, err := decryptSecret(user.EncryptedToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice-token", token)
+
+	rotated, err = RotateTenantTokens(db)
+	require.NoError(t, err)
+	assert.Equal(t, 0, rotated)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	registerSecret("short")
+	registerSecret("sk-0123456789")
+	registerSecret("sk-0123456789abcdef")
+
+	assert.Equal(t, "short key, [REDACTED] and [REDACTED]", redactSecrets("short key, sk-0123456789abcdef and sk-0123456789"))
+
+	entry := logrus.WithField("error", errors.New("invalid token sk-0123456789"))
+	entry.Message = "Authorization: Token sk-0123456789abcdef"
+	require.NoError(t, redactionHook{}.Fire(entry))
+	assert.Equal(t, "Authorization: Token [REDACTED]", entry.Message)
+	assert.Equal(t, "invalid token [REDACTED]", entry.Data["error"])
+}
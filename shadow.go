@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// shadowResultRetention is how long shadow comparisons are kept for GET /api/shadow/report
+const shadowResultRetention = 30 * 24 * time.Hour
+
+// shadowReportDifferences is the number of most recent differences listed in a shadow report
+const shadowReportDifferences = 50
+
+// shadowPrompts holds the candidate templates of prompts/shadow/, guarded by templateMutex
+var shadowPrompts = map[string]*template.Template{}
+
+type shadowRunKey struct{}
+
+// withShadowRun marks the context of a shadow run, which renders the candidate prompts
+func withShadowRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowRunKey{}, true)
+}
+
+// isShadowRun reports whether the context belongs to a shadow run
+func isShadowRun(ctx context.Context) bool {
+	shadow, _ := ctx.Value(shadowRunKey{}).(bool)
+	return shadow
+}
+
+// shadowModel returns the model of the shadow runs
+func shadowModel() string {
+	if shadowLlmModel != "" {
+		return shadowLlmModel
+	}
+	return llmModel
+}
+
+// ShadowFieldStats counts how often the shadow configuration agreed with production for a field
+type ShadowFieldStats struct {
+	Compared int `json:"compared"`
+	Matching int `json:"matching"`
+
+	// AgreementRate is the share of matching suggestions, nil if nothing was compared
+	AgreementRate *float64 `json:"agreement_rate"`
+}
+
+// ShadowDifference is a field on which the shadow configuration disagreed with production
+type ShadowDifference struct {
+	DocumentID int    `json:"document_id"`
+	Field      string `json:"field"`
+	Model      string `json:"model"`
+	Production string `json:"production"`
+	Candidate  string `json:"candidate"`
+	Date       string `json:"date"`
+}
+
+// ShadowReport summarizes the shadow comparisons of a period, as returned by GET /api/shadow/report
+type ShadowReport struct {
+	Documents   int                          `json:"documents"`
+	Fields      map[string]*ShadowFieldStats `json:"fields"`
+	Differences []ShadowDifference           `json:"differences"` // Most recent first
+}
+
+// loadShadowPrompts loads the candidate templates in <dir>/*.tmpl. A missing directory means the shadow runs
+// use the live prompts.
+func loadShadowPrompts(dir string) (map[string]*template.Template, error) {
+	prompts := map[string]*template.Template{}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(filepath.Base(path)).Funcs(sprig.FuncMap()).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		prompts[filepath.Base(path)] = tmpl
+		log.Infof("Using shadow prompt %s", filepath.Base(path))
+	}
+	return prompts, nil
+}
+
+// shadowComparisons compares the production and shadow suggestions of the requested fields per document
+func shadowComparisons(request GenerateSuggestionsRequest, production, candidates []DocumentSuggestion, username string) []ShadowResult {
+	byID := make(map[int]DocumentSuggestion, len(candidates))
+	for _, candidate := range candidates {
+		byID[candidate.ID] = candidate
+	}
+
+	var results []ShadowResult
+	for _, live := range production {
+		candidate, ok := byID[live.ID]
+		if !ok {
+			continue
+		}
+		add := func(field, productionValue, candidateValue string) {
+			results = append(results, ShadowResult{
+				DocumentID: uint(live.ID),
+				Field:      field,
+				Model:      shadowModel(),
+				Production: productionValue,
+				Candidate:  candidateValue,
+				Matches:    productionValue == candidateValue,
+				Username:   username,
+			})
+		}
+		if request.GenerateTitles {
+			add("title", live.SuggestedTitle, candidate.SuggestedTitle)
+		}
+		if request.GenerateTags {
+			add("tags", normalizeTagValue(live.SuggestedTags), normalizeTagValue(candidate.SuggestedTags))
+		}
+		if request.GenerateCorrespondents {
+			add("correspondent", live.SuggestedCorrespondent, candidate.SuggestedCorrespondent)
+		}
+	}
+	return results
+}
+
+// runShadow generates the suggestions of the same request with the shadow model and prompts and stores how
+// they differ from production. The shadow suggestions are never applied.
+func (app *App) runShadow(ctx context.Context, request GenerateSuggestionsRequest, production []DocumentSuggestion) error {
+	if app.ShadowLLM == nil || len(production) == 0 {
+		return nil
+	}
+	shadow := &App{Client: app.Client, Database: app.Database, LLM: app.ShadowLLM}
+	if shadowLlmProvider != "" {
+		shadow.LLMModel = shadowLlmModel
+	}
+	candidates, err := shadow.generateDocumentSuggestions(withShadowRun(ctx), request, log.WithField("shadow", true))
+	if err != nil {
+		return fmt.Errorf("error generating shadow suggestions: %w", err)
+	}
+
+	results := shadowComparisons(request, production, candidates, tenantUsername(ctx))
+	if len(results) == 0 {
+		return nil
+	}
+	return InsertShadowResults(app.Database, results, shadowResultRetention)
+}
+
+// startShadowRun runs the shadow configuration in the background so production suggestions are not delayed
+func (app *App) startShadowRun(ctx context.Context, request GenerateSuggestionsRequest, production []DocumentSuggestion) {
+	if app.ShadowLLM == nil {
+		return
+	}
+	go func() {
+		if err := app.runShadow(context.WithoutCancel(ctx), request, production); err != nil {
+			log.Warnf("Shadow run failed: %v", err)
+		}
+	}()
+}
+
+// shadowReport summarizes comparisons ordered newest first
+func shadowReport(results []ShadowResult) ShadowReport {
+	report := ShadowReport{Fields: map[string]*ShadowFieldStats{}, Differences: []ShadowDifference{}}
+	documents := map[uint]bool{}
+	for _, result := range results {
+		documents[result.DocumentID] = true
+		stats := report.Fields[result.Field]
+		if stats == nil {
+			stats = &ShadowFieldStats{}
+			report.Fields[result.Field] = stats
+		}
+		stats.Compared++
+		if result.Matches {
+			stats.Matching++
+		} else if len(report.Differences) < shadowReportDifferences {
+			report.Differences = append(report.Differences, ShadowDifference{
+				DocumentID: int(result.DocumentID),
+				Field:      result.Field,
+				Model:      result.Model,
+				Production: result.Production,
+				Candidate:  result.Candidate,
+				Date:       result.DateCreated,
+			})
+		}
+		rate := float64(stats.Matching) / float64(stats.Compared)
+		stats.AgreementRate = &rate
+	}
+	report.Documents = len(documents)
+	return report
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestRunShadow(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+	env.db.Where("1 = 1").Delete(&ShadowResult{})
+
+	originalShadowPrompts, originalTitleTemplate, originalShadowModel := shadowPrompts, titleTemplate, shadowLlmModel
+	defer func() {
+		shadowPrompts, titleTemplate, shadowLlmModel = originalShadowPrompts, originalTitleTemplate, originalShadowModel
+	}()
+	titleTemplate = template.Must(template.New("title").Parse("Live: {{.Content}}"))
+	shadowPrompts = map[string]*template.Template{
+		"title_prompt.tmpl": template.Must(template.New("title_prompt.tmpl").Parse("Candidate: {{.Content}}")),
+	}
+	shadowLlmModel = "candidate-model"
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+
+	shadowLLM := &scriptedLLM{responses: []string{"ACME invoice", "Letter"}}
+	app := &App{Client: env.client, Database: env.db, ShadowLLM: shadowLLM}
+	request := GenerateSuggestionsRequest{
+		Documents:      []Document{{ID: 1, Title: "scan_0001", Content: "Invoice from ACME"}},
+		GenerateTitles: true,
+	}
+	production := []DocumentSuggestion{{ID: 1, SuggestedTitle: "Invoice ACME"}}
+
+	ctx := withTenant(context.Background(), "shadow-user", "")
+	require.NoError(t, app.runShadow(ctx, request, production))
+	require.Len(t, shadowLLM.conversations, 1)
+	assert.Equal(t, "Candidate: Invoice from ACME", shadowLLM.conversations[0][0].Parts[0].(llms.TextContent).Text)
+
+	// An identical suggestion counts as agreement
+	production[0].SuggestedTitle = "Letter"
+	require.NoError(t, app.runShadow(ctx, request, production))
+
+	results, err := GetShadowResults(env.db, "shadow-user", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	report := shadowReport(results)
+	assert.Equal(t, 1, report.Documents)
+	require.NotNil(t, report.Fields["title"])
+	assert.Equal(t, 2, report.Fields["title"].Compared)
+	assert.Equal(t, 1, report.Fields["title"].Matching)
+	require.Len(t, report.Differences, 1)
+	assert.Equal(t, ShadowDifference{
+		DocumentID: 1,
+		Field:      "title",
+		Model:      "candidate-model",
+		Production: "Invoice ACME",
+		Candidate:  "ACME invoice",
+		Date:       report.Differences[0].Date,
+	}, report.Differences[0])
+}
+
+func TestShadowComparisons(t *testing.T) {
+	request := GenerateSuggestionsRequest{GenerateTags: true, GenerateCorrespondents: true}
+	production := []DocumentSuggestion{
+		{ID: 1, SuggestedTags: []string{"Invoice", "Bank"}, SuggestedCorrespondent: "ACME"},
+		{ID: 2, SuggestedTags: []string{"Letter"}},
+	}
+	candidates := []DocumentSuggestion{{ID: 1, SuggestedTags: []string{"Bank", "Invoice"}, SuggestedCorrespondent: "ACME Corp"}}
+
+	results := shadowComparisons(request, production, candidates, "")
+	require.Len(t, results, 2, "documents without a shadow suggestion are skipped")
+	assert.Equal(t, "tags", results[0].Field)
+	assert.True(t, results[0].Matches, "the order of tags does not matter")
+	assert.Equal(t, "correspondent", results[1].Field)
+	assert.False(t, results[1].Matches)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// storagePathPlaceholder matches the placeholders of storage path templates, both the Jinja form
+// {{ created_year }} of paperless-ngx 2.10 and later and the older {created_year}
+var storagePathPlaceholder = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}|\{([a-z_]+)\}`)
+
+// storagePathUnsafe are the characters paperless-ngx replaces in placeholder values, so a title
+// cannot add directories or invalid file names
+var storagePathUnsafe = strings.NewReplacer("/", "-", `\`, "-", ":", "-", "*", "-", "?", "-", `"`, "-", "<", "-", ">", "-", "|", "-")
+
+// storagePathPreviewer computes the file paths paperless-ngx will store suggested documents under
+type storagePathPreviewer struct {
+	paths         map[int]StoragePath
+	documentTypes map[int]string
+}
+
+// newStoragePathPreviewer fetches the storage paths and document types needed to preview the file paths of
+// the documents. It returns nil if no document has a storage path or paperless-ngx cannot be asked, as the
+// preview is informational only.
+func (app *App) newStoragePathPreviewer(ctx context.Context, documents []Document, logger *logrus.Entry) *storagePathPreviewer {
+	if !slices.ContainsFunc(documents, func(doc Document) bool { return doc.StoragePathID != 0 }) {
+		return nil
+	}
+	paths, err := app.Client.GetStoragePaths(ctx)
+	if err != nil {
+		logger.Warnf("Not previewing storage paths: %v", err)
+		return nil
+	}
+	previewer := &storagePathPreviewer{paths: paths, documentTypes: map[int]string{}}
+	documentTypes, err := app.Client.GetAllDocumentTypes(ctx)
+	if err != nil {
+		logger.Warnf("Not previewing storage paths: %v", err)
+		return nil
+	}
+	for name, id := range documentTypes {
+		previewer.documentTypes[id] = name
+	}
+	return previewer
+}
+
+// preview renders the storage path of a document with the suggested title, correspondent and tags. It returns
+// an empty path if the document has no storage path or its template uses more than the supported placeholders.
+func (p *storagePathPreviewer) preview(doc Document, title, correspondent string, tags []string) (string, error) {
+	if p == nil || doc.StoragePathID == 0 {
+		return "", nil
+	}
+	storagePath, ok := p.paths[doc.StoragePathID]
+	if !ok {
+		return "", fmt.Errorf("storage path %d not found", doc.StoragePathID)
+	}
+	return renderStoragePath(storagePath.Path, storagePathValues(doc, title, correspondent, p.documentTypes[doc.DocumentTypeID], tags))
+}
+
+// storagePathValues returns the placeholder values paperless-ngx fills in from the document. Missing
+// correspondents and document types are rendered as "none", like paperless-ngx does.
+func storagePathValues(doc Document, title, correspondent, documentType string, tags []string) map[string]string {
+	values := map[string]string{
+		"title":         storagePathUnsafe.Replace(title),
+		"correspondent": "none",
+		"document_type": "none",
+		"doc_pk":        fmt.Sprintf("%07d", doc.ID),
+	}
+	if correspondent != "" {
+		values["correspondent"] = storagePathUnsafe.Replace(correspondent)
+	}
+	if documentType != "" {
+		values["document_type"] = storagePathUnsafe.Replace(documentType)
+	}
+
+	sortedTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		sortedTags = append(sortedTags, storagePathUnsafe.Replace(tag))
+	}
+	sort.Strings(sortedTags)
+	values["tag_list"] = strings.Join(sortedTags, ",")
+
+	if created, err := time.Parse("2006-01-02", doc.CreatedDate); err == nil {
+		values["created"] = created.Format("2006-01-02")
+		values["created_year"] = created.Format("2006")
+		values["created_year_short"] = created.Format("06")
+		values["created_month"] = created.Format("01")
+		values["created_month_name"] = created.Format("January")
+		values["created_month_name_short"] = created.Format("Jan")
+		values["created_day"] = created.Format("02")
+	}
+	return values
+}
+
+// renderStoragePath fills in the placeholders of a storage path template and appends the .pdf extension of
+// the archived file. Templates with other placeholders or Jinja logic such as filters and conditions cannot
+// be previewed and return an error.
+func renderStoragePath(pathTemplate string, values map[string]string) (string, error) {
+	var unsupported []string
+	rendered := storagePathPlaceholder.ReplaceAllStringFunc(pathTemplate, func(match string) string {
+		groups := storagePathPlaceholder.FindStringSubmatch(match)
+		name := groups[1] + groups[2]
+		value, ok := values[name]
+		if !ok {
+			unsupported = append(unsupported, name)
+			return match
+		}
+		return value
+	})
+	if len(unsupported) > 0 {
+		return "", fmt.Errorf("placeholders %s cannot be previewed", strings.Join(unsupported, ", "))
+	}
+	if strings.Contains(rendered, "{{") || strings.Contains(rendered, "{%") {
+		return "", errors.New("expressions other than plain placeholders cannot be previewed")
+	}
+
+	rendered = strings.Join(strings.Split(rendered, "\n"), "")
+	rendered = strings.Trim(strings.TrimSpace(rendered), "/")
+	if rendered == "" {
+		return "", fmt.Errorf("the storage path renders to an empty path")
+	}
+	return rendered + ".pdf", nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderStoragePath(t *testing.T) {
+	doc := Document{ID: 42, CreatedDate: "2024-03-07"}
+	values := storagePathValues(doc, "Invoice 03/2024", "", "Invoice", []string{"tax", "inbox"})
+
+	tests := []struct {
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{"{{ created_year }}/{{ correspondent }}/{{ title }}", "2024/none/Invoice 03-2024.pdf", false},
+		{"{created_year}/{created_month_name_short}/{document_type}-{doc_pk}", "2024/Mar/Invoice-0000042.pdf", false},
+		{"/{{created}} {{ tag_list }}\n", "2024-03-07 inbox,tax.pdf", false},
+		{"{{ owner_username }}/{{ title }}", "", true},
+		{"{{ custom_fields|get_cf_value('Amount') }}/{{ title }}", "", true},
+		{"{% if correspondent %}{{ correspondent }}{% endif %}", "", true},
+	}
+	for _, tc := range tests {
+		rendered, err := renderStoragePath(tc.template, values)
+		if tc.wantErr {
+			assert.Error(t, err, tc.template)
+			continue
+		}
+		require.NoError(t, err, tc.template)
+		assert.Equal(t, tc.expected, rendered)
+	}
+}
+
+func TestStoragePathPreviewer(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	env.setMockResponse("/api/storage_paths/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 3, "name": "By correspondent", "path": "{{ correspondent }}/{{ document_type }}/{{ title }}"}]}`))
+	})
+	env.setMockResponse("/api/document_types/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 5, "name": "Contract"}]}`))
+	})
+
+	app := &App{Client: env.client, Database: env.db}
+	logger := logrus.NewEntry(log)
+
+	// Nothing is fetched without storage paths
+	assert.Nil(t, app.newStoragePathPreviewer(context.Background(), []Document{{ID: 1}}, logger))
+
+	documents := []Document{{ID: 1, StoragePathID: 3, DocumentTypeID: 5}, {ID: 2}}
+	previewer := app.newStoragePathPreviewer(context.Background(), documents, logger)
+	require.NotNil(t, previewer)
+
+	path, err := previewer.preview(documents[0], "Rental agreement", "ACME", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ACME/Contract/Rental agreement.pdf", path)
+
+	path, err = previewer.preview(documents[1], "Letter", "ACME", nil)
+	require.NoError(t, err)
+	assert.Empty(t, path)
+}
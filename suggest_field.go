@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// suggestionFields are the fields POST /api/documents/:id/suggest/:field can regenerate
+var suggestionFields = []string{"title", "tags", "correspondent", "created_date", "summary"}
+
+// instructionLLM appends user-provided instructions to the prompt of every request
+type instructionLLM struct {
+	llms.Model
+	instructions string
+}
+
+// GenerateContent appends the instructions to the first message, which holds the rendered prompt
+func (m *instructionLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if len(messages) > 0 {
+		messages = slices.Clone(messages)
+		messages[0].Parts = append(slices.Clone(messages[0].Parts), llms.TextContent{
+			Text: "\n\nAdditional instructions from the user:\n" + m.instructions,
+		})
+	}
+	return m.Model.GenerateContent(ctx, messages, options...)
+}
+
+// Call implements the legacy single prompt interface through GenerateContent
+func (m *instructionLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// suggestField regenerates a single field of a document. Instructions, if any, are appended to the prompt.
+// Tags are returned as a list, all other fields as a string; an empty string means nothing was found.
+func (app *App) suggestField(ctx context.Context, doc Document, field string, instructions string, logger *logrus.Entry) (interface{}, error) {
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		instructed := *app
+		instructed.LLM = &instructionLLM{Model: app.LLM, instructions: instructions}
+		app = &instructed
+	}
+
+	documentTypeNames, err := app.documentTypeNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch document types: %w", err)
+	}
+	documentType := documentTypeNames[doc.DocumentTypeID]
+	content := normalizeContent(doc.Content)
+	ctx = withTraceDocument(ctx, doc.ID)
+
+	switch field {
+	case "title":
+		currentTitle := doc.Title
+		if headers, ok := emailHeadersOf(doc); ok && headers.Subject != "" {
+			currentTitle = headers.Subject
+		}
+		title, err := app.getSuggestedTitle(ctx, content, currentTitle, documentType, logger)
+		if err != nil || !translatesDocumentType(documentType) {
+			return title, err
+		}
+		return app.translate(ctx, "title", title)
+
+	case "tags":
+		availableTagsMap, err := app.Client.GetAllTags(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch available tags: %w", err)
+		}
+		availableTagNames := make([]string, 0, len(availableTagsMap))
+		for tagName := range availableTagsMap {
+			if tagName != settings().ManualTag {
+				availableTagNames = append(availableTagNames, tagName)
+			}
+		}
+		return app.getSuggestedTags(ctx, content, doc.Title, availableTagNames, doc.Tags, documentType, logger)
+
+	case "correspondent":
+		availableCorrespondentsMap, err := app.Client.GetAllCorrespondents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch available correspondents: %w", err)
+		}
+		availableCorrespondentNames := make([]string, 0, len(availableCorrespondentsMap))
+		for correspondentName := range availableCorrespondentsMap {
+			availableCorrespondentNames = append(availableCorrespondentNames, correspondentName)
+		}
+		// Instructions, e.g. feedback on a suggested sender, ask the LLM instead of taking the email headers
+		if headers, ok := emailHeadersOf(doc); ok && instructions == "" {
+			if sender := headers.correspondent(availableCorrespondentNames); sender != "" {
+				return sender, nil
+			}
+		}
+		correspondent, err := app.getSuggestedCorrespondent(ctx, content, doc.Title, availableCorrespondentNames, correspondentBlackList, documentType)
+		if err != nil {
+			return nil, err
+		}
+		if isCorrespondentBlacklisted(correspondent) {
+			logger.Warnf("Suggested correspondent '%s' is blacklisted, discarding it", correspondent)
+			return "", nil
+		}
+		return correspondent, nil
+
+	case "created_date":
+		if headers, ok := emailHeadersOf(doc); ok && headers.Date != "" && instructions == "" {
+			return headers.Date, nil
+		}
+		return app.getSuggestedCreatedDate(ctx, content, doc.Title, documentType, logger)
+
+	case "summary":
+		summary, err := app.getSuggestedSummary(ctx, content, doc.Title, documentType, logger)
+		if err != nil || !translateSummaries || !translatesDocumentType(documentType) {
+			return summary, err
+		}
+		return app.translate(ctx, "summary", summary)
+	}
+	return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(suggestionFields, ", "))
+}
+
+// getSuggestedCreatedDate asks the LLM for the issue date of a document as YYYY-MM-DD
+func (app *App) getSuggestedCreatedDate(ctx context.Context, content string, title string, documentType string, logger *logrus.Entry) (string, error) {
+	templateMutex.RLock()
+	prompt, err := renderContentPrompt(ctx, promptTemplate(ctx, createdDateTemplate, "created_date_prompt.tmpl", documentType), title, content, nil)
+	templateMutex.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	logger.Debugf("Created date suggestion prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, validateCreatedDate)
+	if err != nil {
+		return "", err
+	}
+	response = strings.TrimSpace(stripReasoning(response))
+	if strings.EqualFold(response, "unknown") {
+		return "", nil
+	}
+	return response, nil
+}
+
+// getSuggestedSummary asks the LLM for a short summary of a document
+func (app *App) getSuggestedSummary(ctx context.Context, content string, title string, documentType string, logger *logrus.Entry) (string, error) {
+	templateMutex.RLock()
+	prompt, err := renderContentPrompt(ctx, promptTemplate(ctx, summaryTemplate, "summary_prompt.tmpl", documentType), title, content, nil)
+	templateMutex.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	logger.Debugf("Summary prompt: %s", prompt)
+
+	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
+	}
+	return strings.TrimSpace(stripReasoning(completion.Choices[0].Content)), nil
+}
+
+// renderContentPrompt renders a template that takes Language, Title, Content, Hint and any extra variables,
+// truncating the content to the token limit
+func renderContentPrompt(ctx context.Context, tmpl *template.Template, title string, content string, extra map[string]interface{}) (string, error) {
+	templateData := map[string]interface{}{
+		"Language": getLikelyLanguage(),
+		"Title":    title,
+		"Content":  content,
+		"Hint":     promptHint(ctx),
+	}
+	for key, value := range extra {
+		templateData[key] = value
+	}
+
+	availableTokens, err := getAvailableTokensForContent(tmpl, templateData)
+	if err != nil {
+		return "", fmt.Errorf("error calculating available tokens: %w", err)
+	}
+	truncatedContent, err := truncateContentByTokens(content, availableTokens)
+	if err != nil {
+		return "", fmt.Errorf("error truncating content: %w", err)
+	}
+
+	var promptBuffer bytes.Buffer
+	templateData["Content"] = truncatedContent
+	if err := tmpl.Execute(&promptBuffer, templateData); err != nil {
+		return "", fmt.Errorf("error executing %s template: %w", tmpl.Name(), err)
+	}
+	return promptBuffer.String(), nil
+}
+
+// validateCreatedDate checks that an answer is a single existing date as YYYY-MM-DD or "Unknown"
+func validateCreatedDate(response string) error {
+	response = strings.TrimSpace(stripReasoning(response))
+	if strings.EqualFold(response, "unknown") {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", response); err != nil {
+		return fmt.Errorf("expected a single existing date as YYYY-MM-DD or \"Unknown\", got %q", response)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestSuggestField(t *testing.T) {
+	originalTemplate := createdDateTemplate
+	createdDateTemplate = template.Must(template.New("created_date").Parse("Date of {{.Title}}: {{.Content}}"))
+	defer func() { createdDateTemplate = originalTemplate }()
+
+	doc := Document{ID: 7, Title: "Invoice", Content: "Invoice dated 12.03.2024"}
+
+	t.Run("instructions are appended to the prompt", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"2024-03-12"}}
+		app := &App{LLM: llm}
+
+		value, err := app.suggestField(context.Background(), doc, "created_date", "Use the invoice date", logrus.WithField("test", "test"))
+		require.NoError(t, err)
+		assert.Equal(t, "2024-03-12", value)
+
+		require.Len(t, llm.conversations, 1)
+		parts := llm.conversations[0][0].Parts
+		require.Len(t, parts, 2)
+		assert.Equal(t, "Date of Invoice: Invoice dated 12.03.2024", parts[0].(llms.TextContent).Text)
+		assert.Contains(t, parts[1].(llms.TextContent).Text, "Use the invoice date")
+		// The app's own LLM is not wrapped permanently
+		assert.Same(t, llm, app.LLM)
+	})
+
+	t.Run("unknown date is returned empty", func(t *testing.T) {
+		app := &App{LLM: &scriptedLLM{responses: []string{"Unknown"}}}
+
+		value, err := app.suggestField(context.Background(), doc, "created_date", "", logrus.WithField("test", "test"))
+		require.NoError(t, err)
+		assert.Equal(t, "", value)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		app := &App{LLM: &scriptedLLM{}}
+
+		_, err := app.suggestField(context.Background(), doc, "owner", "", logrus.WithField("test", "test"))
+		assert.ErrorContains(t, err, "unknown field")
+	})
+}
+
+func TestValidateCreatedDate(t *testing.T) {
+	assert.NoError(t, validateCreatedDate("2024-03-12"))
+	assert.NoError(t, validateCreatedDate("Unknown"))
+	assert.Error(t, validateCreatedDate("12.03.2024"))
+	assert.Error(t, validateCreatedDate("The document was issued on 2024-03-12"))
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// batchDocument is a document as listed in a batched prompt
+type batchDocument struct {
+	ID           int
+	Title        string
+	Content      string
+	OriginalTags []string
+}
+
+// batchedSuggestions holds the titles and tags generated for several documents at once, by document ID.
+// Documents missing from a map were not batched or not answered and are handled with one call per document.
+type batchedSuggestions struct {
+	mu     sync.Mutex
+	titles map[int]string
+	tags   map[int][]string
+}
+
+// batchResponse is the structured answer to a batched prompt
+type batchResponse struct {
+	Documents []struct {
+		ID    int      `json:"id"`
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	} `json:"documents"`
+}
+
+// parseBatchResponse parses the JSON answer to a batched prompt
+func parseBatchResponse(response string) (batchResponse, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed batchResponse
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return parsed, fmt.Errorf("error parsing batched suggestions: %w", err)
+	}
+	if len(parsed.Documents) == 0 {
+		return parsed, errors.New("the response contains no documents")
+	}
+	return parsed, nil
+}
+
+// batchableDocuments returns the documents whose prompt is not overridden for their document type,
+// since documents in a batch have to share one template
+func batchableDocuments(documents []Document, documentTypeNames map[int]string, file string) []Document {
+	var batchable []Document
+	for _, doc := range documents {
+		documentType := documentTypeNames[doc.DocumentTypeID]
+		if _, ok := promptOverrides[strings.ToLower(documentType)][file]; ok && documentType != "" {
+			continue
+		}
+		batchable = append(batchable, doc)
+	}
+	return batchable
+}
+
+// renderBatchPrompt renders a batched template, sharing the content token budget equally between the documents
+func renderBatchPrompt(tmpl *template.Template, data map[string]interface{}, documents []batchDocument) (string, error) {
+	empty := make([]batchDocument, len(documents))
+	for i, doc := range documents {
+		empty[i] = doc
+		empty[i].Content = ""
+	}
+	data["Documents"] = empty
+	availableTokens, err := getAvailableTokensForContent(tmpl, data)
+	if err != nil {
+		return "", fmt.Errorf("error calculating available tokens: %w", err)
+	}
+	if availableTokens > 0 {
+		availableTokens /= len(documents)
+	}
+
+	truncated := make([]batchDocument, len(documents))
+	for i, doc := range documents {
+		truncated[i] = doc
+		truncated[i].Content, err = truncateContentByTokens(doc.Content, availableTokens)
+		if err != nil {
+			return "", fmt.Errorf("error truncating content: %w", err)
+		}
+	}
+	data["Documents"] = truncated
+
+	var promptBuffer bytes.Buffer
+	if err := tmpl.Execute(&promptBuffer, data); err != nil {
+		return "", fmt.Errorf("error executing batch template: %w", err)
+	}
+	return promptBuffer.String(), nil
+}
+
+// getBatchedTitles generates the titles of several documents with a single LLM call
+func (app *App) getBatchedTitles(ctx context.Context, documents []batchDocument) (map[int]string, error) {
+	templateMutex.RLock()
+	prompt, err := renderBatchPrompt(batchTitleTemplate, map[string]interface{}{
+		"Language": getLikelyLanguage(),
+		"Hint":     promptHint(ctx),
+	}, documents)
+	templateMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Batched title prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseBatchResponse(response)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseBatchResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[int]string, len(parsed.Documents))
+	for _, entry := range parsed.Documents {
+		if title := strings.TrimSpace(strings.Trim(entry.Title, "\"")); title != "" {
+			titles[entry.ID] = title
+		}
+	}
+	return titles, nil
+}
+
+// getBatchedTags generates the tags of several documents with a single LLM call
+func (app *App) getBatchedTags(ctx context.Context, documents []batchDocument, availableTags []string) (map[int][]string, error) {
+	availableTags = withoutStatusTags(availableTags)
+
+	templateMutex.RLock()
+	data := map[string]interface{}{
+		"Language":      getLikelyLanguage(),
+		"AvailableTags": availableTags,
+		"TagTree":       "",
+		"Hint":          promptHint(ctx),
+	}
+	if tagHierarchySeparator != "" {
+		data["TagTree"] = renderTagTree(availableTags, tagHierarchySeparator)
+	}
+	prompt, err := renderBatchPrompt(batchTagTemplate, data, documents)
+	templateMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Batched tag prompt: %s", prompt)
+
+	response, err := app.generateValidated(ctx, prompt, func(response string) error {
+		_, err := parseBatchResponse(response)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseBatchResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	originalTags := make(map[int][]string, len(documents))
+	for _, doc := range documents {
+		originalTags[doc.ID] = doc.OriginalTags
+	}
+	tags := make(map[int][]string, len(parsed.Documents))
+	for _, entry := range parsed.Documents {
+		original, ok := originalTags[entry.ID]
+		if !ok {
+			continue // The LLM answered for a document that was not asked for
+		}
+		suggested := make([]string, 0, len(entry.Tags))
+		for _, tag := range entry.Tags {
+			suggested = append(suggested, strings.TrimSpace(tag))
+		}
+		tags[entry.ID] = filterSuggestedTags(suggested, availableTags, original)
+	}
+	return tags, nil
+}
+
+// generateBatchedSuggestions generates titles and tags for up to SUGGESTION_BATCH_SIZE documents per LLM call.
+// Batches run in parallel; a failed batch is logged and its documents fall back to one call per document.
+func (app *App) generateBatchedSuggestions(ctx context.Context, request GenerateSuggestionsRequest, documentTypeNames map[int]string, availableTags []string, logger *logrus.Entry) *batchedSuggestions {
+	current := settings()
+	batched := &batchedSuggestions{titles: map[int]string{}, tags: map[int][]string{}}
+	// Shadow prompts replace the per-document templates, so shadow runs using them are not batched
+	if current.SuggestionBatchSize < 2 || (isShadowRun(ctx) && len(shadowPrompts) > 0) {
+		return batched
+	}
+	// Rationales need one structured answer per document, so tags are only batched without them
+	batchTags := request.GenerateTags && !request.IncludeRationale && !suggestionRationale
+	if !request.GenerateTitles && !batchTags {
+		return batched
+	}
+
+	documents := request.Documents
+	if request.GenerateTitles {
+		documents = batchableDocuments(documents, documentTypeNames, "title_prompt.tmpl")
+	}
+	if batchTags {
+		documents = batchableDocuments(documents, documentTypeNames, "tag_prompt.tmpl")
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(documents); start += current.SuggestionBatchSize {
+		end := min(start+current.SuggestionBatchSize, len(documents))
+		batch := make([]batchDocument, 0, end-start)
+		for _, doc := range documents[start:end] {
+			// Like a single title prompt, the prompt of an email shows its subject as the current title
+			title := doc.Title
+			if headers, ok := emailHeadersOf(doc); ok && headers.Subject != "" {
+				title = headers.Subject
+			}
+			batch = append(batch, batchDocument{ID: doc.ID, Title: title, Content: normalizeContent(doc.Content), OriginalTags: doc.Tags})
+		}
+		if len(batch) < 2 {
+			continue // A single document gains nothing from batching
+		}
+
+		wg.Add(1)
+		go func(batch []batchDocument) {
+			defer wg.Done()
+			batchLogger := logger.WithField("documents", len(batch))
+
+			if request.GenerateTitles {
+				titles, err := app.getBatchedTitles(ctx, batch)
+				if err != nil {
+					batchLogger.Warnf("Batched title generation failed, falling back to one call per document: %v", err)
+					return
+				}
+				batched.mu.Lock()
+				for i, doc := range batch {
+					if title, ok := titles[doc.ID]; ok {
+						batched.titles[doc.ID] = title
+						batch[i].Title = title
+					}
+				}
+				batched.mu.Unlock()
+			}
+
+			if batchTags {
+				tags, err := app.getBatchedTags(ctx, batch, availableTags)
+				if err != nil {
+					batchLogger.Warnf("Batched tag generation failed, falling back to one call per document: %v", err)
+					return
+				}
+				batched.mu.Lock()
+				for id, docTags := range tags {
+					batched.tags[id] = docTags
+				}
+				batched.mu.Unlock()
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	logger.Debugf("Generated %d titles and %d tag lists in batches", len(batched.titles), len(batched.tags))
+	return batched
+}
+
+// title returns the batched title of a document, if any
+func (b *batchedSuggestions) title(id int) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	title, ok := b.titles[id]
+	return title, ok
+}
+
+// tagList returns the batched tags of a document, if any
+func (b *batchedSuggestions) tagList(id int) ([]string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tags, ok := b.tags[id]
+	return tags, ok
+}
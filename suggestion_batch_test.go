@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParseBatchResponse(t *testing.T) {
+	parsed, err := parseBatchResponse("```json\n{\"documents\": [{\"id\": 1, \"title\": \"Invoice\"}, {\"id\": 2, \"tags\": [\"Bank\"]}]}\n```")
+	require.NoError(t, err)
+	require.Len(t, parsed.Documents, 2)
+	assert.Equal(t, "Invoice", parsed.Documents[0].Title)
+	assert.Equal(t, []string{"Bank"}, parsed.Documents[1].Tags)
+
+	_, err = parseBatchResponse(`{"documents": []}`)
+	assert.Error(t, err)
+
+	_, err = parseBatchResponse("Invoice")
+	assert.Error(t, err)
+}
+
+func TestGenerateBatchedSuggestions(t *testing.T) {
+	originalRationale := suggestionRationale
+	defer func() { suggestionRationale = originalRationale }()
+	suggestionRationale = false
+	setTestSettings(t, func(s *runtimeSettings) { s.SuggestionBatchSize, s.TokenLimit = 2, 0 })
+
+	var err error
+	batchTitleTemplate, err = template.New("batch_title").Funcs(sprig.FuncMap()).Parse(defaultBatchTitleTemplate)
+	require.NoError(t, err)
+	batchTagTemplate, err = template.New("batch_tag").Funcs(sprig.FuncMap()).Parse(defaultBatchTagTemplate)
+	require.NoError(t, err)
+
+	llm := &scriptedLLM{responses: []string{
+		`{"documents": [{"id": 1, "title": "Electricity Bill"}, {"id": 2, "title": "\"Bank Statement\""}]}`,
+		`{"documents": [{"id": 1, "tags": ["Invoice", "Unknown"]}, {"id": 2, "tags": ["bank"]}, {"id": 9, "tags": ["Bank"]}]}`,
+	}}
+	app := &App{LLM: llm}
+
+	request := GenerateSuggestionsRequest{
+		Documents: []Document{
+			{ID: 1, Title: "scan_001.pdf", Content: "Total due: 80 EUR"},
+			{ID: 2, Title: "scan_002.pdf", Content: "Balance: 1000 EUR", Tags: []string{"Finance"}},
+			{ID: 3, Title: "scan_003.pdf", Content: "A single document in the last batch"},
+		},
+		GenerateTitles: true,
+		GenerateTags:   true,
+	}
+	batched := app.generateBatchedSuggestions(context.Background(), request, map[int]string{}, []string{"Invoice", "Bank", "Finance"}, logrus.WithField("test", "test"))
+
+	assert.Equal(t, map[int]string{1: "Electricity Bill", 2: "Bank Statement"}, batched.titles)
+	assert.Equal(t, map[int][]string{1: {"Invoice"}, 2: {"Finance", "Bank"}}, batched.tags)
+
+	// Both documents are listed in one prompt, and tags are asked for with the batched titles
+	require.Len(t, llm.conversations, 2)
+	titlePrompt := llm.conversations[0][0].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, titlePrompt, "Document 1\nOriginal title: scan_001.pdf")
+	assert.Contains(t, titlePrompt, "Document 2\nOriginal title: scan_002.pdf")
+	tagPrompt := llm.conversations[1][0].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, tagPrompt, "Title: Electricity Bill")
+	assert.Contains(t, tagPrompt, "Title: Bank Statement")
+
+	t.Run("emails are listed with their subject", func(t *testing.T) {
+		original := emailHeaderMetadata
+		defer func() { emailHeaderMetadata = original }()
+		emailHeaderMetadata = true
+
+		llm := &scriptedLLM{responses: []string{`{"documents": [{"id": 4, "title": "ACME Invoice 1001"}, {"id": 5, "title": "Letter"}]}`}}
+		app := &App{LLM: llm}
+		request := GenerateSuggestionsRequest{
+			Documents: []Document{
+				{ID: 4, Title: "invoice", OriginalFileName: "invoice.eml", Content: testEmailContent},
+				{ID: 5, Title: "scan_005.pdf", Content: "Dear Sir or Madam"},
+			},
+			GenerateTitles: true,
+		}
+		app.generateBatchedSuggestions(context.Background(), request, map[int]string{}, nil, logrus.WithField("test", "test"))
+
+		require.Len(t, llm.conversations, 1)
+		titlePrompt := llm.conversations[0][0].Parts[0].(llms.TextContent).Text
+		assert.Contains(t, titlePrompt, "Document 4\nOriginal title: Your invoice 1001")
+		assert.Contains(t, titlePrompt, "Document 5\nOriginal title: scan_005.pdf")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		setTestSettings(t, func(s *runtimeSettings) { s.SuggestionBatchSize = 0 })
+
+		batched := app.generateBatchedSuggestions(context.Background(), request, map[int]string{}, nil, logrus.WithField("test", "test"))
+		assert.Empty(t, batched.titles)
+		assert.Empty(t, batched.tags)
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagNode is a node of the tag tree derived from tag names such as "finance/invoices". Intermediate
+// namespaces that are not tags themselves have no ID.
+type TagNode struct {
+	Name     string     `json:"name"` // Last segment of the path
+	Path     string     `json:"path"` // Full tag name
+	ID       *int       `json:"id,omitempty"`
+	Children []*TagNode `json:"children"`
+}
+
+// buildTagTree arranges tags into a tree by splitting their names at TAG_HIERARCHY_SEPARATOR.
+// Without a separator all tags are returned as roots.
+func buildTagTree(tags map[string]int, separator string) []*TagNode {
+	root := &TagNode{}
+	nodes := map[string]*TagNode{}
+	for _, name := range sortedTagNames(tags) {
+		parent := root
+		segments := []string{name}
+		if separator != "" {
+			segments = strings.Split(name, separator)
+		}
+		for i := range segments {
+			path := strings.Join(segments[:i+1], separator)
+			node, ok := nodes[path]
+			if !ok {
+				node = &TagNode{Name: segments[i], Path: path, Children: []*TagNode{}}
+				nodes[path] = node
+				parent.Children = append(parent.Children, node)
+			}
+			parent = node
+		}
+		id := tags[name]
+		parent.ID = &id
+	}
+	return root.Children
+}
+
+// renderTagTree renders tag names as an indented list of full paths, so the LLM sees the hierarchy
+// but can still answer with exact tag names
+func renderTagTree(tagNames []string, separator string) string {
+	tags := make(map[string]int, len(tagNames))
+	for _, name := range tagNames {
+		tags[name] = 0
+	}
+
+	var builder strings.Builder
+	var render func(nodes []*TagNode, depth int)
+	render = func(nodes []*TagNode, depth int) {
+		for _, node := range nodes {
+			builder.WriteString(strings.Repeat("  ", depth) + node.Path + "\n")
+			render(node.Children, depth+1)
+		}
+	}
+	render(buildTagTree(tags, separator), 0)
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// resolveTagPaths replaces suggested tags that name only the last segment of an existing tag, such as
+// "invoices" for "finance/invoices", with the full tag name. Ambiguous segments are left unchanged and
+// are dropped later because no tag with that name exists.
+func resolveTagPaths(suggestedTags []string, availableTags []string, separator string) []string {
+	if separator == "" {
+		return suggestedTags
+	}
+
+	resolved := make([]string, len(suggestedTags))
+	for i, tag := range suggestedTags {
+		resolved[i] = tag
+		if strings.Contains(tag, separator) || containsFold(availableTags, tag) {
+			continue
+		}
+		matches := []string{}
+		for _, availableTag := range availableTags {
+			segments := strings.Split(availableTag, separator)
+			if strings.EqualFold(segments[len(segments)-1], tag) {
+				matches = append(matches, availableTag)
+			}
+		}
+		if len(matches) == 1 {
+			resolved[i] = matches[0]
+		}
+	}
+	return resolved
+}
+
+// containsFold reports whether list contains value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedTagNames returns the tag names in alphabetical order
+func sortedTagNames(tags map[string]int) []string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
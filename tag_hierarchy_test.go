@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTagTree(t *testing.T) {
+	tags := map[string]int{"finance/invoices": 2, "finance": 1, "finance/tax/2024": 3, "private": 4}
+
+	tree := buildTagTree(tags, "/")
+	require.Len(t, tree, 2)
+
+	finance := tree[0]
+	assert.Equal(t, "finance", finance.Path)
+	require.NotNil(t, finance.ID)
+	assert.Equal(t, 1, *finance.ID)
+	require.Len(t, finance.Children, 2)
+	assert.Equal(t, "invoices", finance.Children[0].Name)
+	assert.Equal(t, "finance/invoices", finance.Children[0].Path)
+
+	// "finance/tax" is only a namespace, not a tag
+	tax := finance.Children[1]
+	assert.Nil(t, tax.ID)
+	require.Len(t, tax.Children, 1)
+	assert.Equal(t, 3, *tax.Children[0].ID)
+
+	assert.Len(t, buildTagTree(tags, ""), 4)
+}
+
+func TestRenderTagTree(t *testing.T) {
+	rendered := renderTagTree([]string{"private", "finance/invoices", "finance/tax/2024"}, "/")
+	assert.Equal(t, "finance\n  finance/invoices\n  finance/tax\n    finance/tax/2024\nprivate", rendered)
+}
+
+func TestResolveTagPaths(t *testing.T) {
+	available := []string{"finance/invoices", "finance/2024", "tax/2024", "Receipts"}
+
+	resolved := resolveTagPaths([]string{"Invoices", "2024", "receipts", "finance/invoices"}, available, "/")
+	assert.Equal(t, []string{"finance/invoices", "2024", "receipts", "finance/invoices"}, resolved)
+
+	assert.Equal(t, []string{"invoices"}, resolveTagPaths([]string{"invoices"}, available, ""))
+}
+
+func TestFilterSuggestedTags_Hierarchy(t *testing.T) {
+	original := tagHierarchySeparator
+	tagHierarchySeparator = "/"
+	defer func() { tagHierarchySeparator = original }()
+
+	// Leaves resolve to their tag, unknown namespaces are dropped
+	filtered := filterSuggestedTags([]string{"invoices", "finance/receipts"}, []string{"finance/invoices", "private"}, nil)
+	assert.Equal(t, []string{"finance/invoices"}, filtered)
+}
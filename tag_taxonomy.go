@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// defaultTaxonomySampleSize is the number of recent documents shown to the LLM with their tags
+	defaultTaxonomySampleSize = 100
+	// maxTaxonomySampleSize keeps the prompt within the context of common models
+	maxTaxonomySampleSize = 500
+)
+
+// TaxonomyOperation is a proposed change of the tag taxonomy. A merge moves the documents of several
+// tags to one tag, a rename moves the documents of one tag to a new name, e.g. a full hierarchical name.
+type TaxonomyOperation struct {
+	Action string   `json:"action"` // "merge" or "rename"
+	From   []string `json:"from"`
+	To     string   `json:"to"`
+	Reason string   `json:"reason,omitempty"`
+
+	// Documents is the number of documents carrying any of the From tags, counted per tag
+	Documents int `json:"documents"`
+	// BulkRequest executes the operation through POST /api/tags/bulk
+	BulkRequest BulkTagRequest `json:"bulk_request"`
+}
+
+// paperlessGptTags are the tags paperless-gpt uses for its own workflow, which are not part of the taxonomy
+func paperlessGptTags() []string {
+	current := settings()
+	var tags []string
+	for _, tag := range []string{current.ManualTag, current.AutoTag, manualOcrTag, current.AutoOcrTag, current.OcrInProgressTag, current.OcrDoneTag, current.TaggingInProgressTag,
+		current.TaggingDoneTag, current.ProcessingFailedTag, current.OcrReviewTag, dueSoonTag, handwritingTag} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// suggestTagTaxonomy samples the most recent tagged documents and asks the LLM for merges and renames of
+// the existing tags. Nothing is changed, every operation comes with the bulk request that executes it.
+func (app *App) suggestTagTaxonomy(ctx context.Context, sampleSize int) ([]TaxonomyOperation, error) {
+	if sampleSize <= 0 {
+		sampleSize = defaultTaxonomySampleSize
+	}
+	sampleSize = min(sampleSize, maxTaxonomySampleSize)
+
+	allTags, err := app.Client.GetTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	workflowTags := paperlessGptTags()
+	tags := make([]Tag, 0, len(allTags))
+	for _, tag := range allTags {
+		if !slices.Contains(workflowTags, tag.Name) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return []TaxonomyOperation{}, nil
+	}
+
+	query := url.Values{"ordering": {"-added"}, "is_tagged": {"true"}}
+	documents, err := app.Client.GetDocumentsByQuery(ctx, query, sampleSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch documents: %w", err)
+	}
+	sample := make([]map[string]interface{}, 0, len(documents))
+	for _, doc := range documents {
+		docTags := slices.DeleteFunc(slices.Clone(doc.Tags), func(tag string) bool { return slices.Contains(workflowTags, tag) })
+		sample = append(sample, map[string]interface{}{"Title": doc.Title, "Tags": docTags})
+	}
+
+	var promptBuffer bytes.Buffer
+	templateMutex.RLock()
+	err = taxonomyTemplate.Execute(&promptBuffer, map[string]interface{}{
+		"Language":  getLikelyLanguage(),
+		"Tags":      tags,
+		"Documents": sample,
+		"Separator": tagHierarchySeparator,
+	})
+	templateMutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("error executing taxonomy template: %w", err)
+	}
+	log.Debugf("Tag taxonomy prompt: %s", promptBuffer.String())
+
+	response, err := app.generateValidated(ctx, promptBuffer.String(), func(response string) error {
+		_, err := parseTaxonomy(response, tags)
+		return err
+	}, llms.WithJSONMode())
+	if err != nil {
+		return nil, err
+	}
+	return parseTaxonomy(response, tags)
+}
+
+// parseTaxonomy parses an answer of the form {"operations": [...]}, resolves the tag names to the existing
+// tags and builds the bulk requests
+func parseTaxonomy(response string, tags []Tag) ([]TaxonomyOperation, error) {
+	response = stripReasoning(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var parsed struct {
+		Operations []TaxonomyOperation `json:"operations"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing taxonomy operations: %w", err)
+	}
+
+	operations := []TaxonomyOperation{}
+	for _, operation := range parsed.Operations {
+		operation.Action = strings.ToLower(strings.TrimSpace(operation.Action))
+		operation.To = strings.TrimSpace(operation.To)
+		if operation.Action != "merge" && operation.Action != "rename" {
+			return nil, fmt.Errorf("unknown action %q, expected \"merge\" or \"rename\"", operation.Action)
+		}
+		if operation.To == "" {
+			return nil, fmt.Errorf("%s of %v has no target tag", operation.Action, operation.From)
+		}
+		if operation.Action == "rename" && len(operation.From) != 1 {
+			return nil, fmt.Errorf("rename to %q must have exactly one tag in \"from\"", operation.To)
+		}
+
+		var from, remove, ids []string
+		documents := 0
+		for _, name := range operation.From {
+			index := slices.IndexFunc(tags, func(tag Tag) bool { return strings.EqualFold(tag.Name, strings.TrimSpace(name)) })
+			if index < 0 {
+				return nil, fmt.Errorf("unknown tag %q, \"from\" may only contain tags from the list", name)
+			}
+			tag := tags[index]
+			if slices.Contains(from, tag.Name) {
+				continue
+			}
+			from = append(from, tag.Name)
+			ids = append(ids, strconv.Itoa(tag.ID))
+			documents += tag.DocumentCount
+			if tag.Name != operation.To {
+				remove = append(remove, tag.Name)
+			}
+		}
+		if len(from) == 0 {
+			return nil, fmt.Errorf("%s to %q has no tags in \"from\"", operation.Action, operation.To)
+		}
+		if len(remove) == 0 {
+			continue // The tag already has the proposed name
+		}
+
+		operation.From = from
+		operation.Documents = documents
+		operation.BulkRequest = BulkTagRequest{
+			AddTags:    []string{operation.To},
+			RemoveTags: remove,
+			Filter:     map[string]string{"tags__id__in": strings.Join(ids, ",")},
+		}
+		operations = append(operations, operation)
+	}
+	return operations, nil
+}
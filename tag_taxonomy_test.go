@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestParseTaxonomy(t *testing.T) {
+	tags := []Tag{
+		{ID: 1, Name: "Invoice", DocumentCount: 10},
+		{ID: 2, Name: "invoices", DocumentCount: 3},
+		{ID: 3, Name: "Bills", DocumentCount: 2},
+		{ID: 4, Name: "Car", DocumentCount: 5},
+	}
+
+	operations, err := parseTaxonomy("```json\n"+`{"operations": [
+		{"action": "Merge", "from": ["invoice", "Invoices", "bills"], "to": "Invoice", "reason": "Same meaning"},
+		{"action": "rename", "from": ["Car"], "to": "Vehicles/Car"},
+		{"action": "rename", "from": ["car"], "to": "Car"}
+	]}`+"\n```", tags)
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+
+	assert.Equal(t, "merge", operations[0].Action)
+	assert.Equal(t, []string{"Invoice", "invoices", "Bills"}, operations[0].From)
+	assert.Equal(t, 15, operations[0].Documents)
+	assert.Equal(t, BulkTagRequest{
+		AddTags:    []string{"Invoice"},
+		RemoveTags: []string{"invoices", "Bills"},
+		Filter:     map[string]string{"tags__id__in": "1,2,3"},
+	}, operations[0].BulkRequest)
+
+	assert.Equal(t, 5, operations[1].Documents)
+	assert.Equal(t, BulkTagRequest{
+		AddTags:    []string{"Vehicles/Car"},
+		RemoveTags: []string{"Car"},
+		Filter:     map[string]string{"tags__id__in": "4"},
+	}, operations[1].BulkRequest)
+
+	for _, response := range []string{
+		`not json`,
+		`{"operations": [{"action": "delete", "from": ["Car"], "to": "Car"}]}`,
+		`{"operations": [{"action": "merge", "from": ["Car"], "to": " "}]}`,
+		`{"operations": [{"action": "rename", "from": ["Car", "Bills"], "to": "Other"}]}`,
+		`{"operations": [{"action": "merge", "from": ["Boat"], "to": "Vehicles"}]}`,
+		`{"operations": [{"action": "merge", "from": [], "to": "Vehicles"}]}`,
+	} {
+		_, err := parseTaxonomy(response, tags)
+		assert.Error(t, err, response)
+	}
+}
+
+func TestSuggestTagTaxonomy(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	setTestSettings(t, func(s *runtimeSettings) { s.ManualTag = "paperless-gpt" })
+	originalTemplate := taxonomyTemplate
+	taxonomyTemplate = template.Must(template.New("taxonomy").Funcs(sprig.FuncMap()).Parse(defaultTaxonomyTemplate))
+	defer func() { taxonomyTemplate = originalTemplate }()
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 1, "name": "Invoice", "document_count": 10},
+			{"id": 2, "name": "invoices", "document_count": 3},
+			{"id": 5, "name": "paperless-gpt", "document_count": 1}
+		], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/documents/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "-added", r.URL.Query().Get("ordering"))
+		assert.Equal(t, "true", r.URL.Query().Get("is_tagged"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [
+			{"id": 7, "title": "Electricity bill", "tags": [2, 5]},
+			{"id": 8, "title": "Phone invoice", "tags": [1]}
+		]}`))
+	})
+
+	llm := &scriptedLLM{responses: []string{
+		`{"operations": [{"action": "merge", "from": ["Invoice", "Rechnung"], "to": "Invoice"}]}`,
+		`{"operations": [{"action": "merge", "from": ["Invoice", "invoices"], "to": "Invoice", "reason": "Plural"}]}`,
+	}}
+	app := &App{Client: env.client, Database: env.db, LLM: llm}
+
+	operations, err := app.suggestTagTaxonomy(context.Background(), 0)
+	require.NoError(t, err)
+	require.Len(t, operations, 1)
+	assert.Equal(t, []string{"invoices"}, operations[0].BulkRequest.RemoveTags)
+	assert.Equal(t, 13, operations[0].Documents)
+
+	// The workflow tag is neither offered as a tag nor shown on the sample documents
+	require.Len(t, llm.conversations, 2)
+	prompt := llm.conversations[0][0].Parts[0].(llms.TextContent).Text
+	assert.Contains(t, prompt, "- invoices (3 documents)")
+	assert.Contains(t, prompt, "- Electricity bill: invoices\n")
+	assert.False(t, strings.Contains(prompt, "paperless-gpt"))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantKey is the context key for the paperless user requests are made for
+type tenantKey struct{}
+
+// tenant is the paperless user and token requests are made for in multi-tenant mode
+type tenant struct {
+	Username string
+	Token    string
+}
+
+// withTenant makes all paperless requests with the returned context use the token of the given user
+func withTenant(ctx context.Context, username string, token string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant{Username: username, Token: token})
+}
+
+// tenantFromContext returns the user set by withTenant, if any
+func tenantFromContext(ctx context.Context) (tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(tenant)
+	return t, ok
+}
+
+// tenantUsername returns the name of the user set by withTenant, or an empty string
+func tenantUsername(ctx context.Context) string {
+	t, _ := tenantFromContext(ctx)
+	return t.Username
+}
+
+// tenantCacheDir returns a cache subdirectory per user so downloaded pages are never shared between users
+func tenantCacheDir(ctx context.Context) string {
+	username := tenantUsername(ctx)
+	if username == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(username))
+	return "user-" + hex.EncodeToString(sum[:8])
+}
+
+// tenantContext loads the registered token of a user into the context. An empty username returns the context unchanged.
+func (app *App) tenantContext(ctx context.Context, username string) (context.Context, error) {
+	if username == "" {
+		return ctx, nil
+	}
+	user, err := app.tenantUser(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("no paperless token registered for user %s: %w", username, err)
+	}
+	token, err := decryptSecret(user.EncryptedToken)
+	if err != nil {
+		return nil, err
+	}
+	return withTenant(ctx, username, token), nil
+}
+
+// tenantUser returns a registered user from Redis if the tokens are shared, otherwise from the local database
+func (app *App) tenantUser(ctx context.Context, username string) (*TenantUser, error) {
+	if sharedTenants != nil {
+		return sharedTenants.get(ctx, username)
+	}
+	return GetTenantUser(app.Database, username)
+}
+
+// tenantUsers returns all registered users, ordered by name
+func (app *App) tenantUsers(ctx context.Context) ([]TenantUser, error) {
+	if sharedTenants != nil {
+		return sharedTenants.list(ctx)
+	}
+	return GetTenantUsers(app.Database)
+}
+
+// saveTenantUser registers the paperless token of a user
+func (app *App) saveTenantUser(ctx context.Context, username string, token string) error {
+	if sharedTenants != nil {
+		return sharedTenants.save(ctx, username, token)
+	}
+	return SaveTenantUser(app.Database, username, token)
+}
+
+// deleteTenantUser removes the registered token of a user, or returns gorm.ErrRecordNotFound
+func (app *App) deleteTenantUser(ctx context.Context, username string) error {
+	if sharedTenants != nil {
+		return sharedTenants.remove(ctx, username)
+	}
+	return DeleteTenantUser(app.Database, username)
+}
+
+// forEachTenant runs fn with the token of every registered user in multi-tenant mode, or once with the
+// global token otherwise. The processed counts are summed and the first error is returned after all users ran.
+func (app *App) forEachTenant(ctx context.Context, fn func(ctx context.Context) (int, error)) (int, error) {
+	if !multiTenant {
+		return fn(ctx)
+	}
+
+	users, err := app.tenantUsers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching users: %w", err)
+	}
+
+	total := 0
+	var firstErr error
+	for _, user := range users {
+		userCtx, err := app.tenantContext(ctx, user.Username)
+		if err == nil {
+			var count int
+			count, err = fn(userCtx)
+			total += count
+		}
+		if err != nil {
+			log.WithField("user", user.Username).Errorf("Error processing documents: %v", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("user %s: %w", user.Username, err)
+			}
+		}
+	}
+	return total, firstErr
+}
+
+// tenantMiddleware reads the acting user from AUTH_USER_HEADER, which must be set by an authenticating reverse proxy.
+// With requireToken, requests of users without a registered paperless token are rejected; otherwise only the name is set.
+func (app *App) tenantMiddleware(requireToken bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := strings.TrimSpace(c.GetHeader(authUserHeader))
+		if username == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Missing %s header", authUserHeader)})
+			return
+		}
+
+		ctx := withTenant(c.Request.Context(), username, "")
+		if requireToken {
+			var err error
+			ctx, err = app.tenantContext(c.Request.Context(), username)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No paperless token registered, set it with PUT /api/tenant/token"})
+					return
+				}
+				log.WithField("user", username).Errorf("Failed to load paperless token: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load paperless token"})
+				return
+			}
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// requireAdmin rejects changes to settings shared by all users unless the acting user is listed in ADMIN_USERS.
+// Without multi-tenant mode everyone reaching paperless-gpt is an admin.
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if multiTenant && !slices.Contains(adminUsers, tenantUsername(c.Request.Context())) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only users listed in ADMIN_USERS can change settings shared by all users"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUsername returns the name of the paperless user the token in the context (or the client token) belongs to
+func (client *PaperlessClient) currentUsername(ctx context.Context) (string, error) {
+	resp, err := client.Do(ctx, "GET", "api/ui_settings/", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("error fetching user: %d, %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var settings struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		return "", err
+	}
+	return settings.User.Username, nil
+}
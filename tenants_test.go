@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTenantToken(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	originalKey := secretsKey
+	secretsKey = "test key"
+	defer func() { secretsKey = originalKey }()
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	}))
+	defer server.Close()
+	client := NewPaperlessClient(server.URL, "global-token")
+
+	app := &App{Client: client, Database: db}
+	require.NoError(t, SaveTenantUser(db, "alice", "alice-token"))
+	require.NoError(t, SaveTenantUser(db, "alice", "alice-new-token"))
+
+	users, err := GetTenantUsers(db)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.NotContains(t, users[0].EncryptedToken, "alice")
+
+	// Requests made for a user use their token, all others the global one
+	ctx, err := app.tenantContext(context.Background(), "alice")
+	require.NoError(t, err)
+	_, err = client.GetAllTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Token alice-new-token", authorization)
+
+	_, err = client.GetAllTags(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Token global-token", authorization)
+
+	_, err = app.tenantContext(context.Background(), "bob")
+	assert.Error(t, err)
+}
+
+func TestGetDocumentHandlerUsesTenantToken(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	originalKey, originalMultiTenant, originalHeader := secretsKey, multiTenant, authUserHeader
+	secretsKey, multiTenant, authUserHeader = "test key", true, "Remote-User"
+	defer func() { secretsKey, multiTenant, authUserHeader = originalKey, originalMultiTenant, originalHeader }()
+
+	var mu sync.Mutex
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/api/documents/") {
+			w.Write([]byte(`{"id": 7, "title": "Invoice"}`))
+			return
+		}
+		w.Write([]byte(`{"results": [], "next": null}`))
+	}))
+	defer server.Close()
+
+	app := &App{Client: NewPaperlessClient(server.URL, "global-token"), Database: db}
+	require.NoError(t, SaveTenantUser(db, "handler-alice", "handler-alice-token"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/documents/:id", app.tenantMiddleware(true), app.getDocumentHandler())
+	req := httptest.NewRequest(http.MethodGet, "/api/documents/7", nil)
+	req.Header.Set("Remote-User", "handler-alice")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NotEmpty(t, authorizations)
+	for _, authorization := range authorizations {
+		assert.Equal(t, "Token handler-alice-token", authorization)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	originalMultiTenant, originalAdmins := multiTenant, adminUsers
+	defer func() { multiTenant, adminUsers = originalMultiTenant, originalAdmins }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/config/reload", requireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	serve := func(username string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/config/reload", nil)
+		if username != "" {
+			req = req.WithContext(withTenant(context.Background(), username, ""))
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	multiTenant, adminUsers = false, nil
+	assert.Equal(t, http.StatusOK, serve(""))
+
+	multiTenant = true
+	assert.Equal(t, http.StatusForbidden, serve("alice"), "nobody is an admin without ADMIN_USERS")
+
+	adminUsers = []string{"admin"}
+	assert.Equal(t, http.StatusForbidden, serve("alice"))
+	assert.Equal(t, http.StatusOK, serve("admin"))
+}
+
+func TestForEachTenant(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	originalKey, originalMultiTenant := secretsKey, multiTenant
+	secretsKey, multiTenant = "test key", true
+	defer func() { secretsKey, multiTenant = originalKey, originalMultiTenant }()
+
+	// The in-memory test database is shared between tests
+	require.NoError(t, db.Where("1 = 1").Delete(&TenantUser{}).Error)
+	require.NoError(t, SaveTenantUser(db, "bob", "bob-token"))
+	require.NoError(t, SaveTenantUser(db, "alice", "alice-token"))
+
+	app := &App{Database: db}
+	var tokens []string
+	count, err := app.forEachTenant(context.Background(), func(ctx context.Context) (int, error) {
+		user, _ := tenantFromContext(ctx)
+		tokens = append(tokens, user.Token)
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, []string{"alice-token", "bob-token"}, tokens)
+}
+
+func TestSharedTenants(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	require.NoError(t, db.Where("1 = 1").Delete(&TenantUser{}).Error)
+
+	originalKey, originalShared := secretsKey, sharedTenants
+	secretsKey = "test key"
+	defer func() { secretsKey, sharedTenants = originalKey, originalShared }()
+
+	// A token registered before Redis was configured moves to Redis
+	require.NoError(t, SaveTenantUser(db, "alice", "alice-token"))
+	_, client := newTestRedis(t)
+	sharedTenants = newRedisTenantStore(client, "test:tenants")
+	moved, err := sharedTenants.moveLocal(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	local, err := GetTenantUsers(db)
+	require.NoError(t, err)
+	assert.Empty(t, local)
+
+	// Another replica with its own database acts for users registered on any replica
+	replica := &App{Database: db}
+	require.NoError(t, replica.saveTenantUser(context.Background(), "bob", "bob-token"))
+	users, err := replica.tenantUsers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Username)
+	assert.NotContains(t, users[1].EncryptedToken, "bob-token")
+
+	ctx, err := replica.tenantContext(context.Background(), "alice")
+	require.NoError(t, err)
+	user, _ := tenantFromContext(ctx)
+	assert.Equal(t, "alice-token", user.Token)
+
+	require.NoError(t, replica.deleteTenantUser(context.Background(), "alice"))
+	assert.ErrorIs(t, replica.deleteTenantUser(context.Background(), "alice"), gorm.ErrRecordNotFound)
+	_, err = replica.tenantUser(context.Background(), "alice")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestGetPaginatedModificationsForUser(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+
+	require.NoError(t, InsertModification(db, &ModificationHistory{DocumentID: 1, ModField: "title", Username: "history-alice"}))
+	require.NoError(t, InsertModification(db, &ModificationHistory{DocumentID: 2, ModField: "title", Username: "history-bob"}))
+
+	records, total, err := GetPaginatedModifications(db, 1, 10, "history-alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint(1), records[0].DocumentID)
+
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// thumbnailCacheSize is the number of thumbnails kept in memory, read from THUMBNAIL_CACHE_SIZE. 0 disables the cache.
+	thumbnailCacheSize = 500
+
+	// thumbnailCacheTTL is how long a cached thumbnail is served before it is fetched again, read from THUMBNAIL_CACHE_TTL. 0 keeps thumbnails until they are evicted.
+	thumbnailCacheTTL = 24 * time.Hour
+)
+
+// renderedThumbnailWidth is the width of thumbnails rendered from the first page when paperless has none
+const renderedThumbnailWidth = 400
+
+// thumbnail is a document thumbnail image
+type thumbnail struct {
+	Data        []byte
+	ContentType string
+	fetched     time.Time
+}
+
+// thumbnailCache keeps the most recently used thumbnails in memory
+type thumbnailCache struct {
+	mu      sync.Mutex
+	order   *list.List // Least recently used at the back
+	entries map[string]*list.Element
+}
+
+// thumbnailCacheEntry is an element of the cache order
+type thumbnailCacheEntry struct {
+	key       string
+	thumbnail thumbnail
+}
+
+// thumbnails caches the thumbnails served by GET /api/documents/:id/thumbnail
+var thumbnails = newThumbnailCache()
+
+// newThumbnailCache creates an empty thumbnail cache
+func newThumbnailCache() *thumbnailCache {
+	return &thumbnailCache{order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// thumbnailKey identifies the thumbnail of a document for the user in the context, since tenants may see
+// different documents
+func thumbnailKey(ctx context.Context, documentID int) string {
+	return fmt.Sprintf("%s/%d", tenantUsername(ctx), documentID)
+}
+
+// get returns a cached thumbnail that has not expired
+func (cache *thumbnailCache) get(key string, now time.Time) (thumbnail, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return thumbnail{}, false
+	}
+	entry := element.Value.(*thumbnailCacheEntry)
+	if thumbnailCacheTTL > 0 && now.Sub(entry.thumbnail.fetched) > thumbnailCacheTTL {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return thumbnail{}, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.thumbnail, true
+}
+
+// put stores a thumbnail and evicts the least recently used ones above THUMBNAIL_CACHE_SIZE
+func (cache *thumbnailCache) put(key string, thumb thumbnail) {
+	if thumbnailCacheSize <= 0 {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*thumbnailCacheEntry).thumbnail = thumb
+		cache.order.MoveToFront(element)
+	} else {
+		cache.entries[key] = cache.order.PushFront(&thumbnailCacheEntry{key: key, thumbnail: thumb})
+	}
+	for cache.order.Len() > thumbnailCacheSize {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*thumbnailCacheEntry).key)
+	}
+}
+
+// documentThumbnail returns the thumbnail of a document from the cache, from paperless or, if paperless has
+// none, rendered from the first page
+func (app *App) documentThumbnail(ctx context.Context, documentID int) (thumbnail, error) {
+	key := thumbnailKey(ctx, documentID)
+	if thumb, ok := thumbnails.get(key, time.Now()); ok {
+		return thumb, nil
+	}
+
+	thumb, err := app.Client.GetThumbnail(ctx, documentID)
+	if err != nil {
+		documentLogger(documentID).Debugf("Rendering the thumbnail locally: %v", err)
+		if thumb, err = app.renderThumbnail(ctx, documentID); err != nil {
+			return thumbnail{}, err
+		}
+	}
+	thumb.fetched = time.Now()
+	thumbnails.put(key, thumb)
+	return thumb, nil
+}
+
+// renderThumbnail renders a thumbnail from the first page of a document. The page is rendered into a private
+// directory, as the image cache of the document belongs to OCR.
+func (app *App) renderThumbnail(ctx context.Context, documentID int) (thumbnail, error) {
+	dir, err := os.MkdirTemp("", "thumbnail-*")
+	if err != nil {
+		return thumbnail{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	imagePaths, err := app.Client.DownloadDocumentAsImages(withImageDir(ctx, dir), documentID, 1)
+	if err != nil {
+		return thumbnail{}, err
+	}
+	if len(imagePaths) == 0 {
+		return thumbnail{}, fmt.Errorf("document %d has no pages", documentID)
+	}
+
+	imageContent, err := os.ReadFile(imagePaths[0])
+	if err != nil {
+		return thumbnail{}, err
+	}
+	page, _, err := image.Decode(bytes.NewReader(imageContent))
+	if err != nil {
+		return thumbnail{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleToWidth(page, renderedThumbnailWidth), &jpeg.Options{Quality: 80}); err != nil {
+		return thumbnail{}, err
+	}
+	return thumbnail{Data: buf.Bytes(), ContentType: "image/jpeg"}, nil
+}
+
+// scaleToWidth shrinks an image to the given width, keeping the aspect ratio. Each target pixel averages
+// the source pixels it covers, which keeps text lines visible instead of dropping them.
+func scaleToWidth(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() <= width {
+		return src
+	}
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			var r, g, b, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, _ := src.At(sx, sy).RGBA()
+					r, g, b, count = r+uint64(pr), g+uint64(pg), b+uint64(pb), count+1
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count >> 8)
+			dst.Pix[offset+1] = uint8(g / count >> 8)
+			dst.Pix[offset+2] = uint8(b / count >> 8)
+			dst.Pix[offset+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// GetThumbnail downloads the thumbnail paperless generated for a document
+func (client *PaperlessClient) GetThumbnail(ctx context.Context, documentID int) (thumbnail, error) {
+	resp, err := client.Do(ctx, "GET", fmt.Sprintf("api/documents/%d/thumb/", documentID), nil)
+	if err != nil {
+		return thumbnail{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return thumbnail{}, fmt.Errorf("error fetching thumbnail of document %d: %d, %s", documentID, resp.StatusCode, string(bodyBytes))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return thumbnail{}, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return thumbnail{Data: data, ContentType: contentType}, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailCache(t *testing.T) {
+	originalSize, originalTTL := thumbnailCacheSize, thumbnailCacheTTL
+	defer func() { thumbnailCacheSize, thumbnailCacheTTL = originalSize, originalTTL }()
+	thumbnailCacheSize, thumbnailCacheTTL = 2, time.Hour
+
+	cache := newThumbnailCache()
+	now := time.Now()
+	cache.put("a", thumbnail{Data: []byte("a"), fetched: now})
+	cache.put("b", thumbnail{Data: []byte("b"), fetched: now})
+
+	// Using "a" makes "b" the least recently used thumbnail
+	_, ok := cache.get("a", now)
+	assert.True(t, ok)
+	cache.put("c", thumbnail{Data: []byte("c"), fetched: now})
+	_, ok = cache.get("b", now)
+	assert.False(t, ok)
+
+	thumb, ok := cache.get("c", now)
+	require.True(t, ok)
+	assert.Equal(t, []byte("c"), thumb.Data)
+
+	_, ok = cache.get("a", now.Add(2*time.Hour))
+	assert.False(t, ok, "expired thumbnails are fetched again")
+}
+
+func TestDocumentThumbnail(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	requests := 0
+	env.setMockResponse("/api/documents/7/thumb/", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/webp")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("webp-data"))
+	})
+
+	app := &App{Client: env.client}
+	for i := 0; i < 2; i++ {
+		thumb, err := app.documentThumbnail(context.Background(), 7)
+		require.NoError(t, err)
+		assert.Equal(t, "image/webp", thumb.ContentType)
+		assert.Equal(t, []byte("webp-data"), thumb.Data)
+	}
+	assert.Equal(t, 1, requests, "the second request is served from the cache")
+
+	_, ok := thumbnails.get(thumbnailKey(withTenant(context.Background(), "alice", "token"), 7), time.Now())
+	assert.False(t, ok, "thumbnails are cached per user")
+}
+
+func TestScaleToWidth(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 1000, 1400))
+	for y := 0; y < 1400; y++ {
+		for x := 0; x < 1000; x++ {
+			src.SetGray(x, y, color.Gray{Y: 200})
+		}
+	}
+
+	scaled := scaleToWidth(src, 400)
+	assert.Equal(t, image.Rect(0, 0, 400, 560), scaled.Bounds())
+	r, g, b, _ := scaled.At(10, 10).RGBA()
+	assert.Equal(t, []uint32{200, 200, 200}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	small := image.NewGray(image.Rect(0, 0, 100, 100))
+	assert.Same(t, small, scaleToWidth(small, 400))
+}
@@ -11,7 +11,8 @@ import (
 // getAvailableTokensForContent calculates how many tokens are available for content
 // by rendering the template with empty content and counting tokens
 func getAvailableTokensForContent(tmpl *template.Template, data map[string]interface{}) (int, error) {
-	if tokenLimit <= 0 {
+	current := settings()
+	if current.TokenLimit <= 0 {
 		return -1, nil // No limit when disabled
 	}
 
@@ -25,13 +26,13 @@ func getAvailableTokensForContent(tmpl *template.Template, data map[string]inter
 	// Execute template with empty content
 	var promptBuffer bytes.Buffer
 	if err := tmpl.Execute(&promptBuffer, templateData); err != nil {
-		return 0, fmt.Errorf("error executing template: %v", err)
+		return 0, fmt.Errorf("error executing template: %w", err)
 	}
 
 	// Count tokens in prompt template
 	promptTokens, err := getTokenCount(promptBuffer.String())
 	if err != nil {
-		return 0, fmt.Errorf("error counting tokens in prompt: %v", err)
+		return 0, fmt.Errorf("error counting tokens in prompt: %w", err)
 	}
 	log.Debugf("Prompt template uses %d tokens", promptTokens)
 
@@ -39,9 +40,9 @@ func getAvailableTokensForContent(tmpl *template.Template, data map[string]inter
 	promptTokens += 10
 
 	// Calculate available tokens for content
-	availableTokens := tokenLimit - promptTokens
+	availableTokens := current.TokenLimit - promptTokens
 	if availableTokens < 0 {
-		return 0, fmt.Errorf("prompt template exceeds token limit")
+		return 0, errTokenLimitExceeded
 	}
 	return availableTokens, nil
 }
@@ -54,12 +55,12 @@ func getTokenCount(content string) (int, error) {
 // This implementation uses a binary search on runes to find the longest prefix whose token count is within the limit.
 // If availableTokens is 0 or negative, the original content is returned.
 func truncateContentByTokens(content string, availableTokens int) (string, error) {
-	if availableTokens < 0 || tokenLimit <= 0 {
+	if availableTokens < 0 || settings().TokenLimit <= 0 {
 		return content, nil
 	}
 	totalTokens, err := getTokenCount(content)
 	if err != nil {
-		return "", fmt.Errorf("error counting tokens: %v", err)
+		return "", fmt.Errorf("error counting tokens: %w", err)
 	}
 	if totalTokens <= availableTokens {
 		return content, nil
@@ -76,7 +77,7 @@ func truncateContentByTokens(content string, availableTokens int) (string, error
 		substr := string(runes[:mid])
 		count, err := getTokenCount(substr)
 		if err != nil {
-			return "", fmt.Errorf("error counting tokens in substring: %v", err)
+			return "", fmt.Errorf("error counting tokens in substring: %w", err)
 		}
 		if count <= availableTokens {
 			validCut = mid
@@ -90,7 +91,7 @@ func truncateContentByTokens(content string, availableTokens int) (string, error
 	// Final verification
 	finalTokens, err := getTokenCount(truncated)
 	if err != nil {
-		return "", fmt.Errorf("error counting tokens in final truncated content: %v", err)
+		return "", fmt.Errorf("error counting tokens in final truncated content: %w", err)
 	}
 	if finalTokens > availableTokens {
 		return "", fmt.Errorf("truncated content still exceeds the available token limit")
@@ -13,16 +13,18 @@ import (
 	"github.com/tmc/langchaingo/textsplitter"
 )
 
-// resetTokenLimit parses TOKEN_LIMIT from environment and sets the tokenLimit variable
+// resetTokenLimit parses TOKEN_LIMIT from environment and sets the token limit setting
 func resetTokenLimit() {
-	// Reset tokenLimit
-	tokenLimit = 0
+	updated := *settings()
+	// Reset the token limit
+	updated.TokenLimit = 0
 	// Parse from environment
 	if limit := os.Getenv("TOKEN_LIMIT"); limit != "" {
 		if parsed, err := strconv.Atoi(limit); err == nil {
-			tokenLimit = parsed
+			updated.TokenLimit = parsed
 		}
 	}
+	currentSettings.Store(&updated)
 }
 
 func TestTokenLimit(t *testing.T) {
@@ -65,7 +67,7 @@ func TestTokenLimit(t *testing.T) {
 			// Set tokenLimit based on environment
 			resetTokenLimit()
 
-			assert.Equal(t, tc.wantLimit, tokenLimit)
+			assert.Equal(t, tc.wantLimit, settings().TokenLimit)
 		})
 	}
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"gorm.io/gorm"
+)
+
+// traceDocumentKey is the context key for the document an LLM request belongs to
+type traceDocumentKey struct{}
+
+// withTraceDocument marks all LLM requests made with the returned context as belonging to the document
+func withTraceDocument(ctx context.Context, documentID int) context.Context {
+	return context.WithValue(ctx, traceDocumentKey{}, documentID)
+}
+
+// tracingLLM records the rendered prompt and raw answer of every LLM request made for a document (LLM_TRACES)
+type tracingLLM struct {
+	llms.Model
+	db *gorm.DB
+}
+
+// GenerateContent forwards to the wrapped LLM and stores a trace if the context names a document
+func (m *tracingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	response, err := m.Model.GenerateContent(ctx, messages, options...)
+
+	documentID, ok := ctx.Value(traceDocumentKey{}).(int)
+	if !ok {
+		return response, err
+	}
+
+	// Keep the whole conversation, which includes re-asks after malformed answers
+	var prompt strings.Builder
+	for i, message := range messages {
+		if i > 0 {
+			prompt.WriteString("\n\n--- " + string(message.Role) + " ---\n")
+		}
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				prompt.WriteString(text.Text)
+			}
+		}
+	}
+	answer := ""
+	if err != nil {
+		answer = "error: " + err.Error()
+	} else if len(response.Choices) > 0 {
+		answer = response.Choices[0].Content
+	}
+
+	trace := &LLMTrace{
+		DocumentID: uint(documentID),
+		Model:      llmModel,
+		Prompt:     truncateTrace(prompt.String()),
+		Response:   truncateTrace(answer),
+	}
+	if traceErr := InsertLLMTrace(m.db, trace, time.Duration(llmTraceRetentionDays)*24*time.Hour); traceErr != nil {
+		documentLogger(documentID).Warnf("Failed to store LLM trace: %v", traceErr)
+	}
+	return response, err
+}
+
+// Call forwards to GenerateContent so both entry points are traced
+func (m *tracingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// truncateTrace limits a trace text to LLM_TRACE_MAX_BYTES without splitting a character
+func truncateTrace(text string) string {
+	if llmTraceMaxBytes <= 0 || len(text) <= llmTraceMaxBytes {
+		return text
+	}
+	return strings.ToValidUTF8(text[:llmTraceMaxBytes], "") + "\n[truncated]"
+}
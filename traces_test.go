@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestTracingLLM(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer db.Where("1 = 1").Delete(&LLMTrace{})
+
+	llm := &tracingLLM{Model: &cannedLLM{response: "Invoice from ACME"}, db: db}
+
+	// Requests without document are not traced
+	_, err = llms.GenerateFromSinglePrompt(context.Background(), llm, "untraced prompt")
+	require.NoError(t, err)
+
+	ctx := withTraceDocument(context.Background(), 12)
+	_, err = llms.GenerateFromSinglePrompt(ctx, llm, "Suggest a title for: ACME invoice")
+	require.NoError(t, err)
+
+	traces, err := GetLLMTraces(db, 12)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	assert.Equal(t, "Suggest a title for: ACME invoice", traces[0].Prompt)
+	assert.Equal(t, "Invoice from ACME", traces[0].Response)
+
+	var total int64
+	require.NoError(t, db.Model(&LLMTrace{}).Count(&total).Error)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestInsertLLMTrace_Retention(t *testing.T) {
+	db, err := InitializeTestDB()
+	require.NoError(t, err)
+	defer db.Where("1 = 1").Delete(&LLMTrace{})
+
+	old := &LLMTrace{DocumentID: 3, Model: "m", DateCreated: time.Now().Add(-48 * time.Hour).Format(time.RFC3339)}
+	require.NoError(t, db.Create(old).Error)
+
+	require.NoError(t, InsertLLMTrace(db, &LLMTrace{DocumentID: 3, Model: "m"}, 24*time.Hour))
+
+	traces, err := GetLLMTraces(db, 3)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	assert.NotEqual(t, old.ID, traces[0].ID)
+}
+
+func TestTruncateTrace(t *testing.T) {
+	original := llmTraceMaxBytes
+	defer func() { llmTraceMaxBytes = original }()
+
+	llmTraceMaxBytes = 5
+	assert.Equal(t, "abc", truncateTrace("abc"))
+	// The cut falls into the two byte "ü" which is dropped instead of split
+	assert.Equal(t, "abcd\n[truncated]", truncateTrace("abcdü"+strings.Repeat("x", 10)))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// translatesDocumentType reports whether suggestions for a document type are translated into LLM_LANGUAGE,
+// as selected by TITLE_TRANSLATION_DOCUMENT_TYPES ("*" for all document types)
+func translatesDocumentType(documentType string) bool {
+	if slices.Contains(titleTranslationTypes, "*") {
+		return true
+	}
+	return documentType != "" && slices.ContainsFunc(titleTranslationTypes, func(name string) bool {
+		return strings.EqualFold(name, documentType)
+	})
+}
+
+// titleTranslationNeedsDocumentTypes reports whether the document type names must be known to decide about translations
+func titleTranslationNeedsDocumentTypes() bool {
+	return len(titleTranslationTypes) > 0 && !slices.Contains(titleTranslationTypes, "*")
+}
+
+// translate asks the LLM to translate a title or summary into LLM_LANGUAGE. Text already in that language
+// comes back unchanged.
+func (app *App) translate(ctx context.Context, kind string, text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+
+	templateMutex.RLock()
+	var promptBuffer bytes.Buffer
+	err := translationTemplate.Execute(&promptBuffer, map[string]interface{}{
+		"Language": getLikelyLanguage(),
+		"Kind":     kind,
+		"Text":     text,
+	})
+	templateMutex.RUnlock()
+	if err != nil {
+		return "", fmt.Errorf("error executing translation template: %w", err)
+	}
+	log.Debugf("Translation prompt: %s", promptBuffer.String())
+
+	completion, err := app.LLM.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, promptBuffer.String()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting response from LLM: %w", err)
+	}
+	translated := strings.TrimSpace(strings.Trim(strings.TrimSpace(stripReasoning(completion.Choices[0].Content)), "\""))
+	if translated == "" {
+		return text, nil
+	}
+	return translated, nil
+}
+
+// originalTitleField returns the custom field of ORIGINAL_TITLE_CUSTOM_FIELD, nil if it is not configured
+func (app *App) originalTitleField(ctx context.Context) (*CustomField, error) {
+	if originalTitleCustomField == "" {
+		return nil, nil
+	}
+	customFields, err := app.Client.GetCustomFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range customFields {
+		if field.Name == originalTitleCustomField {
+			return &field, nil
+		}
+	}
+	return nil, fmt.Errorf("custom field %s does not exist in paperless-ngx", originalTitleCustomField)
+}
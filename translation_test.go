@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestTranslatesDocumentType(t *testing.T) {
+	originalTypes := titleTranslationTypes
+	defer func() { titleTranslationTypes = originalTypes }()
+
+	titleTranslationTypes = nil
+	assert.False(t, translatesDocumentType("Invoice"))
+
+	titleTranslationTypes = []string{"Invoice", "Contract"}
+	assert.True(t, translatesDocumentType("invoice"))
+	assert.False(t, translatesDocumentType("Letter"))
+	assert.False(t, translatesDocumentType(""))
+	assert.True(t, titleTranslationNeedsDocumentTypes())
+
+	titleTranslationTypes = []string{"*"}
+	assert.True(t, translatesDocumentType(""))
+	assert.False(t, titleTranslationNeedsDocumentTypes())
+}
+
+func TestGenerateSuggestionsTranslatesTitle(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.teardown()
+
+	originalTypes, originalField := titleTranslationTypes, originalTitleCustomField
+	originalTitleTemplate, originalTranslationTemplate := titleTemplate, translationTemplate
+	defer func() {
+		titleTranslationTypes, originalTitleCustomField = originalTypes, originalField
+		titleTemplate, translationTemplate = originalTitleTemplate, originalTranslationTemplate
+	}()
+	setTestSettings(t, func(s *runtimeSettings) { s.TokenLimit = 0 })
+	titleTranslationTypes = []string{"Invoice"}
+	originalTitleCustomField = "Original title"
+	titleTemplate = template.Must(template.New("title").Parse("Title: {{.Content}}"))
+	translationTemplate = template.Must(template.New("translation").Parse("Translate the {{.Kind}} into {{.Language}}: {{.Text}}"))
+
+	env.setMockResponse("/api/tags/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/correspondents/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [], "next": null}`))
+	})
+	env.setMockResponse("/api/document_types/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 3, "name": "Invoice"}, {"id": 4, "name": "Letter"}], "next": null}`))
+	})
+	env.setMockResponse("/api/custom_fields/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results": [{"id": 8, "name": "Original title", "data_type": "string"}], "next": null}`))
+	})
+
+	t.Run("title of a selected document type is translated", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"Rechnung ACME", "\"ACME invoice\""}}
+		app := &App{Client: env.client, Database: env.db, LLM: llm}
+		request := GenerateSuggestionsRequest{
+			Documents:      []Document{{ID: 1, Title: "scan_0001", Content: "Rechnung von ACME", DocumentTypeID: 3}},
+			GenerateTitles: true,
+		}
+
+		suggestions, err := app.generateDocumentSuggestions(context.Background(), request, logrus.WithField("test", "test"))
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, "ACME invoice", suggestions[0].SuggestedTitle)
+		assert.Equal(t, []CustomFieldValue{{Field: 8, Value: "Rechnung ACME"}}, suggestions[0].SuggestedCustomFields)
+
+		require.Len(t, llm.conversations, 2)
+		assert.Equal(t, "Translate the title into English: Rechnung ACME", llm.conversations[1][0].Parts[0].(llms.TextContent).Text)
+	})
+
+	t.Run("title already in the target language keeps no original", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"ACME invoice", "ACME invoice"}}
+		app := &App{Client: env.client, Database: env.db, LLM: llm}
+		request := GenerateSuggestionsRequest{
+			Documents:      []Document{{ID: 1, Title: "scan_0001", Content: "Invoice from ACME", DocumentTypeID: 3}},
+			GenerateTitles: true,
+		}
+
+		suggestions, err := app.generateDocumentSuggestions(context.Background(), request, logrus.WithField("test", "test"))
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, "ACME invoice", suggestions[0].SuggestedTitle)
+		assert.Empty(t, suggestions[0].SuggestedCustomFields)
+	})
+
+	t.Run("other document types are not translated", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"Brief von ACME"}}
+		app := &App{Client: env.client, Database: env.db, LLM: llm}
+		request := GenerateSuggestionsRequest{
+			Documents:      []Document{{ID: 2, Title: "scan_0002", Content: "Brief von ACME", DocumentTypeID: 4}},
+			GenerateTitles: true,
+		}
+
+		suggestions, err := app.generateDocumentSuggestions(context.Background(), request, logrus.WithField("test", "test"))
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, "Brief von ACME", suggestions[0].SuggestedTitle)
+		assert.Len(t, llm.conversations, 1)
+	})
+}
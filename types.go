@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -10,23 +11,25 @@ type GetDocumentsApiResponse struct {
 	Previous interface{} `json:"previous"`
 	All      []int       `json:"all"`
 	Results  []struct {
-		ID                  int           `json:"id"`
-		Correspondent       int           `json:"correspondent"`
-		DocumentType        interface{}   `json:"document_type"`
-		StoragePath         interface{}   `json:"storage_path"`
-		Title               string        `json:"title"`
-		Content             string        `json:"content"`
-		Tags                []int         `json:"tags"`
-		Created             time.Time     `json:"created"`
-		CreatedDate         string        `json:"created_date"`
-		Modified            time.Time     `json:"modified"`
-		Added               time.Time     `json:"added"`
-		ArchiveSerialNumber interface{}   `json:"archive_serial_number"`
-		OriginalFileName    string        `json:"original_file_name"`
-		ArchivedFileName    string        `json:"archived_file_name"`
-		Owner               int           `json:"owner"`
-		UserCanChange       bool          `json:"user_can_change"`
-		Notes               []interface{} `json:"notes"`
+		ID                  int                `json:"id"`
+		Correspondent       int                `json:"correspondent"`
+		DocumentType        interface{}        `json:"document_type"`
+		StoragePath         interface{}        `json:"storage_path"`
+		Title               string             `json:"title"`
+		Content             string             `json:"content"`
+		Tags                []int              `json:"tags"`
+		Created             string             `json:"created"`      // Date and time before paperless-ngx 2.16, a date since
+		CreatedDate         string             `json:"created_date"` // Deprecated since paperless-ngx 2.16
+		Modified            time.Time          `json:"modified"`
+		Added               time.Time          `json:"added"`
+		ArchiveSerialNumber interface{}        `json:"archive_serial_number"`
+		OriginalFileName    string             `json:"original_file_name"`
+		ArchivedFileName    string             `json:"archived_file_name"`
+		Owner               int                `json:"owner"`
+		UserCanChange       bool               `json:"user_can_change"`
+		Notes               []interface{}      `json:"notes"`
+		CustomFields        []CustomFieldValue `json:"custom_fields"`
+		PageCount           int                `json:"page_count"`
 		SearchHit           struct {
 			Score          float64 `json:"score"`
 			Highlights     string  `json:"highlights"`
@@ -37,33 +40,53 @@ type GetDocumentsApiResponse struct {
 }
 
 type GetDocumentApiResponse struct {
-	ID                  int           `json:"id"`
-	Correspondent       int           `json:"correspondent"`
-	DocumentType        interface{}   `json:"document_type"`
-	StoragePath         interface{}   `json:"storage_path"`
-	Title               string        `json:"title"`
-	Content             string        `json:"content"`
-	Tags                []int         `json:"tags"`
-	Created             time.Time     `json:"created"`
-	CreatedDate         string        `json:"created_date"`
-	Modified            time.Time     `json:"modified"`
-	Added               time.Time     `json:"added"`
-	ArchiveSerialNumber interface{}   `json:"archive_serial_number"`
-	OriginalFileName    string        `json:"original_file_name"`
-	ArchivedFileName    string        `json:"archived_file_name"`
-	Owner               int           `json:"owner"`
-	UserCanChange       bool          `json:"user_can_change"`
-	Notes               []interface{} `json:"notes"`
+	ID                  int                `json:"id"`
+	Correspondent       int                `json:"correspondent"`
+	DocumentType        interface{}        `json:"document_type"`
+	StoragePath         interface{}        `json:"storage_path"`
+	Title               string             `json:"title"`
+	Content             string             `json:"content"`
+	Tags                []int              `json:"tags"`
+	Created             string             `json:"created"`      // Date and time before paperless-ngx 2.16, a date since
+	CreatedDate         string             `json:"created_date"` // Deprecated since paperless-ngx 2.16
+	Modified            time.Time          `json:"modified"`
+	Added               time.Time          `json:"added"`
+	ArchiveSerialNumber interface{}        `json:"archive_serial_number"`
+	OriginalFileName    string             `json:"original_file_name"`
+	ArchivedFileName    string             `json:"archived_file_name"`
+	Owner               int                `json:"owner"`
+	UserCanChange       bool               `json:"user_can_change"`
+	Notes               []interface{}      `json:"notes"`
+	CustomFields        []CustomFieldValue `json:"custom_fields"`
+	PageCount           int                `json:"page_count"`
+}
+
+// SavedView is a saved document view from paperless-ngx
+type SavedView struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	FilterRules []struct {
+		RuleType int     `json:"rule_type"`
+		Value    *string `json:"value"`
+	} `json:"filter_rules"`
 }
 
 // Document is a stripped down version of the document object from paperless-ngx.
 // Response payload for /documents endpoint and part of request payload for /generate-suggestions endpoint
 type Document struct {
-	ID            int      `json:"id"`
-	Title         string   `json:"title"`
-	Content       string   `json:"content"`
-	Tags          []string `json:"tags"`
-	Correspondent string   `json:"correspondent"`
+	ID             int                `json:"id"`
+	Title          string             `json:"title"`
+	Content        string             `json:"content"`
+	Tags           []string           `json:"tags"`
+	Correspondent  string             `json:"correspondent"`
+	CustomFields   []CustomFieldValue `json:"custom_fields,omitempty"`
+	DocumentTypeID int                `json:"document_type_id,omitempty"` // 0 if the document has no document type
+	StoragePathID  int                `json:"storage_path_id,omitempty"`  // 0 if the document has no storage path
+	PageCount      int                `json:"page_count,omitempty"`       // 0 if unknown (paperless-ngx before 2.x)
+	CreatedDate    string             `json:"created_date,omitempty"`     // YYYY-MM-DD
+	Modified       time.Time          `json:"modified"`                   // Last modification in paperless
+
+	OriginalFileName string `json:"original_file_name,omitempty"` // Name of the consumed file, e.g. "invoice.eml"
 }
 
 // GenerateSuggestionsRequest is the request payload for generating suggestions for /generate-suggestions endpoint
@@ -72,6 +95,18 @@ type GenerateSuggestionsRequest struct {
 	GenerateTitles         bool       `json:"generate_titles,omitempty"`
 	GenerateTags           bool       `json:"generate_tags,omitempty"`
 	GenerateCorrespondents bool       `json:"generate_correspondents,omitempty"`
+	GenerateCustomFields   bool       `json:"generate_custom_fields,omitempty"`
+
+	// Hint is free text about the documents, e.g. "utility bill from 2021", passed to all prompt templates as Hint
+	Hint string `json:"hint,omitempty"`
+
+	// IncludeRationale asks the LLM for a short reason per suggested tag and correspondent
+	IncludeRationale bool `json:"include_rationale,omitempty"`
+
+	// ViewID selects the documents through a paperless-ngx saved view instead of listing them explicitly
+	ViewID int `json:"view_id,omitempty"`
+	// Limit caps the number of documents resolved from ViewID. Default: 25
+	Limit int `json:"limit,omitempty"`
 }
 
 // DocumentSuggestion is the response payload for /generate-suggestions endpoint and the request payload for /update-documents endpoint (as an array)
@@ -82,7 +117,37 @@ type DocumentSuggestion struct {
 	SuggestedTags          []string `json:"suggested_tags,omitempty"`
 	SuggestedContent       string   `json:"suggested_content,omitempty"`
 	SuggestedCorrespondent string   `json:"suggested_correspondent,omitempty"`
+	SuggestedCreatedDate   string   `json:"suggested_created_date,omitempty"` // YYYY-MM-DD
 	RemoveTags             []string `json:"remove_tags,omitempty"`
+
+	// SuggestedCustomFields are merged into the document's existing custom fields on update
+	SuggestedCustomFields []CustomFieldValue `json:"suggested_custom_fields,omitempty"`
+
+	// CorrespondentCandidates holds the ranked alternatives when CORRESPONDENT_CANDIDATES is enabled
+	CorrespondentCandidates []CorrespondentCandidate `json:"correspondent_candidates,omitempty"`
+
+	// TagRationales and CorrespondentRationale explain the suggestions when a rationale was requested
+	TagRationales          map[string]string `json:"tag_rationales,omitempty"`
+	CorrespondentRationale string            `json:"correspondent_rationale,omitempty"`
+
+	// StoragePathPreview is the file path paperless-ngx will produce from the document's storage path and the suggestions
+	StoragePathPreview string `json:"storage_path_preview,omitempty"`
+}
+
+// StoragePath is a paperless-ngx storage path, whose Path is a template for the file names of its documents
+type StoragePath struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// maxCorrespondentCandidates is the number of correspondent candidates kept per suggestion
+const maxCorrespondentCandidates = 3
+
+// CorrespondentCandidate is a correspondent proposed by the LLM together with its confidence (0-1)
+type CorrespondentCandidate struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
 }
 
 type Correspondent struct {
@@ -102,3 +167,71 @@ type Correspondent struct {
 		} `json:"change"`
 	} `json:"set_permissions"`
 }
+
+// Tag is a tag in paperless-ngx with the details shown by the frontend
+type Tag struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Color         string `json:"color"`
+	DocumentCount int    `json:"document_count"`
+}
+
+// CustomFieldValue is the value of a custom field on a document, as used by the paperless-ngx API
+type CustomFieldValue struct {
+	Field int         `json:"field"`
+	Value interface{} `json:"value"`
+}
+
+// CustomField is a custom field definition in paperless-ngx
+type CustomField struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	DataType  string `json:"data_type"`
+	ExtraData struct {
+		SelectOptions []SelectOption `json:"select_options"`
+	} `json:"extra_data"`
+}
+
+// SelectOption is a single option of a select custom field
+type SelectOption struct {
+	Label string
+	// Value is what paperless-ngx stores for this option: the option index before
+	// paperless-ngx 2.15 and the option ID afterwards
+	Value interface{}
+}
+
+// UnmarshalJSON accepts both the legacy string options and the newer {"id", "label"} objects
+func (option *SelectOption) UnmarshalJSON(data []byte) error {
+	var label string
+	if err := json.Unmarshal(data, &label); err == nil {
+		option.Label = label
+		return nil
+	}
+
+	var object struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	option.Label = object.Label
+	option.Value = object.ID
+	return nil
+}
+
+// UnmarshalJSON assigns the index as value to legacy options, which paperless-ngx references by position
+func (field *CustomField) UnmarshalJSON(data []byte) error {
+	type customFieldAlias CustomField
+	var alias customFieldAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	for i := range alias.ExtraData.SelectOptions {
+		if alias.ExtraData.SelectOptions[i].Value == nil {
+			alias.ExtraData.SelectOptions[i].Value = i
+		}
+	}
+	*field = CustomField(alias)
+	return nil
+}
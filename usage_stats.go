@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// usageStatisticsInterval is how often the usage report is sent to USAGE_STATISTICS_URL
+const usageStatisticsInterval = 24 * time.Hour
+
+// UsageCounts counts the documents of one kind of processing
+type UsageCounts struct {
+	Completed int     `json:"completed"`
+	Failed    int     `json:"failed"`
+	ErrorRate float64 `json:"error_rate"` // Failed share of all processed documents, 0 without documents
+}
+
+// UsageReport holds the anonymous usage statistics. It deliberately contains only counts and configuration
+// names: no document ids, titles, contents, tags, URLs, tokens or anything else taken from paperless-ngx.
+type UsageReport struct {
+	Enabled   bool                    `json:"enabled"`
+	Version   string                  `json:"version,omitempty"`
+	Platform  string                  `json:"platform,omitempty"`
+	Since     *time.Time              `json:"since,omitempty"` // Start of the counting, the statistics are not persisted
+	Providers map[string]string       `json:"providers,omitempty"`
+	Documents map[string]*UsageCounts `json:"documents,omitempty"` // Keyed by "ocr" and "suggestions"
+}
+
+// usageStats aggregates the usage statistics in memory, only with USAGE_STATISTICS enabled
+var usageStats = struct {
+	sync.Mutex
+	since     time.Time
+	documents map[string]*UsageCounts
+}{since: time.Now(), documents: map[string]*UsageCounts{}}
+
+// usageKinds maps the job events to the kinds of processing in the usage report
+var usageKinds = map[string]string{
+	jobEventOcrFinished:         "ocr",
+	jobEventSuggestionsFinished: "suggestions",
+}
+
+// recordUsage counts the documents of a finished job, keeping only their status
+func recordUsage(webhook JobWebhook) {
+	kind, ok := usageKinds[webhook.Event]
+	if !usageStatistics || !ok {
+		return
+	}
+	usageStats.Lock()
+	defer usageStats.Unlock()
+	counts := usageStats.documents[kind]
+	if counts == nil {
+		counts = &UsageCounts{}
+		usageStats.documents[kind] = counts
+	}
+	for _, document := range webhook.Documents {
+		if document.Status == "failed" {
+			counts.Failed++
+		} else {
+			counts.Completed++
+		}
+	}
+}
+
+// usageReport returns the current usage statistics, or only the disabled flag without USAGE_STATISTICS
+func usageReport() UsageReport {
+	if !usageStatistics {
+		return UsageReport{}
+	}
+	usageStats.Lock()
+	defer usageStats.Unlock()
+
+	since := usageStats.since
+	report := UsageReport{
+		Enabled:   true,
+		Version:   version,
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Since:     &since,
+		Providers: map[string]string{},
+		Documents: map[string]*UsageCounts{},
+	}
+	if llmProvider != "" {
+		report.Providers["llm"] = llmProvider
+	}
+	if visionLlmProvider != "" {
+		report.Providers["vision"] = visionLlmProvider
+	}
+	for kind, counts := range usageStats.documents {
+		c := *counts
+		if total := c.Completed + c.Failed; total > 0 {
+			c.ErrorRate = float64(c.Failed) / float64(total)
+		}
+		report.Documents[kind] = &c
+	}
+	return report
+}
+
+// sendUsageReports posts the usage report to USAGE_STATISTICS_URL once a day until the context ends
+func sendUsageReports(ctx context.Context) {
+	ticker := time.NewTicker(usageStatisticsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := postWebhook(ctx, usageStatisticsURL, usageReport()); err != nil {
+				log.Warnf("Failed to send the usage statistics: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageReport(t *testing.T) {
+	originalEnabled, originalProvider := usageStatistics, llmProvider
+	defer func() {
+		usageStatistics, llmProvider = originalEnabled, originalProvider
+		usageStats.documents = map[string]*UsageCounts{}
+	}()
+	usageStats.documents = map[string]*UsageCounts{}
+	llmProvider = "ollama"
+
+	webhook := JobWebhook{
+		Event: jobEventOcrFinished,
+		Documents: []JobWebhookDocument{
+			documentOutcome(1, nil, ocrChanges(1, "secret contents")),
+			{DocumentID: 2, Status: "failed", Error: "timeout"},
+			documentOutcome(3, nil, nil),
+			documentOutcome(4, nil, nil),
+		},
+	}
+
+	// Nothing is counted or reported unless enabled
+	usageStatistics = false
+	recordUsage(webhook)
+	assert.Equal(t, UsageReport{}, usageReport())
+
+	usageStatistics = true
+	recordUsage(webhook)
+	report := usageReport()
+	assert.True(t, report.Enabled)
+	assert.Equal(t, "ollama", report.Providers["llm"])
+	require.Contains(t, report.Documents, "ocr")
+	assert.Equal(t, UsageCounts{Completed: 3, Failed: 1, ErrorRate: 0.25}, *report.Documents["ocr"])
+	assert.NotContains(t, report.Documents, "suggestions")
+}
@@ -1,7 +1,129 @@
 package main
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
 var (
 	version   = "devVersion"
 	buildDate = "devBuildDate"
 	commit    = "devCommit"
 )
+
+// latestReleaseURL is the GitHub API endpoint queried by the opt-in update check
+var latestReleaseURL = "https://api.github.com/repos/icereed/paperless-gpt/releases/latest"
+
+// updateCheckInterval is how long the result of an update check is reused
+const updateCheckInterval = 6 * time.Hour
+
+// VersionInfo is the response of GET /api/version
+type VersionInfo struct {
+	Version          string      `json:"version"`
+	Commit           string      `json:"commit"`
+	BuildDate        string      `json:"build_date"`
+	GoVersion        string      `json:"go_version"`
+	Platform         string      `json:"platform"`
+	PaperlessVersion string      `json:"paperless_version"` // Empty if the version could not be detected
+	Update           *UpdateInfo `json:"update,omitempty"`  // Only set when UPDATE_CHECK is enabled
+}
+
+// UpdateInfo is the result of checking GitHub for a newer release
+type UpdateInfo struct {
+	LatestVersion string `json:"latest_version,omitempty"`
+	Available     bool   `json:"available"`
+	URL           string `json:"url,omitempty"`   // Release notes of the latest release
+	Error         string `json:"error,omitempty"` // Set if GitHub could not be reached
+	CheckedAt     string `json:"checked_at"`
+}
+
+// updateCache holds the last update check so GitHub is not queried on every request
+var updateCache = struct {
+	sync.Mutex
+	info      *UpdateInfo
+	checkedAt time.Time
+}{}
+
+// versionInfo collects the build information and the detected paperless-ngx version
+func versionInfo() VersionInfo {
+	return VersionInfo{
+		Version:          version,
+		Commit:           commit,
+		BuildDate:        buildDate,
+		GoVersion:        runtime.Version(),
+		Platform:         fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		PaperlessVersion: paperlessCapabilities.Version,
+	}
+}
+
+// checkForUpdate compares the running version with the latest GitHub release, reusing a recent result
+func checkForUpdate(ctx context.Context) *UpdateInfo {
+	updateCache.Lock()
+	defer updateCache.Unlock()
+	if updateCache.info != nil && time.Since(updateCache.checkedAt) < updateCheckInterval {
+		return updateCache.info
+	}
+
+	info := &UpdateInfo{CheckedAt: time.Now().Format(time.RFC3339)}
+	latest, url, err := fetchLatestRelease(ctx)
+	if err != nil {
+		log.Warnf("Update check failed: %v", err)
+		info.Error = err.Error()
+		// Failed checks are not cached so the next request tries again
+		return info
+	}
+	info.LatestVersion, info.URL = latest, url
+	info.Available = isNewerVersion(latest, version)
+
+	updateCache.info, updateCache.checkedAt = info, time.Now()
+	return info
+}
+
+// fetchLatestRelease returns the tag and URL of the latest paperless-gpt release on GitHub
+func fetchLatestRelease(ctx context.Context) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", latestReleaseURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("GitHub responded with status %d", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", "", err
+	}
+	return release.TagName, release.HTMLURL, nil
+}
+
+// isNewerVersion reports whether latest is a newer release than current. Development builds are never outdated.
+func isNewerVersion(latest, current string) bool {
+	latestParsed, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	currentParsed, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	return compareVersions(latestParsed, currentParsed) > 0
+}
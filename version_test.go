@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	assert.True(t, isNewerVersion("v0.15.0", "0.14.2"))
+	assert.False(t, isNewerVersion("v0.14.2", "v0.14.2"))
+	assert.False(t, isNewerVersion("v0.13.0", "v0.14.2"))
+	assert.False(t, isNewerVersion("v1.0.0", "devVersion"))
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"tag_name": "v0.15.0", "html_url": "https://github.com/icereed/paperless-gpt/releases/tag/v0.15.0"}`))
+	}))
+	defer server.Close()
+
+	originalURL, originalVersion := latestReleaseURL, version
+	latestReleaseURL, version = server.URL, "v0.14.2"
+	defer func() {
+		latestReleaseURL, version = originalURL, originalVersion
+		updateCache.info = nil
+	}()
+
+	info := checkForUpdate(context.Background())
+	require.Empty(t, info.Error)
+	assert.True(t, info.Available)
+	assert.Equal(t, "v0.15.0", info.LatestVersion)
+
+	// The result is cached
+	checkForUpdate(context.Background())
+	assert.Equal(t, 1, requests)
+}
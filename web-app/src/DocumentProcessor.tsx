@@ -28,6 +28,14 @@ export interface DocumentSuggestion {
   suggested_tags?: string[];
   suggested_content?: string;
   suggested_correspondent?: string;
+  correspondent_candidates?: CorrespondentCandidate[];
+  tag_rationales?: Record<string, string>;
+  correspondent_rationale?: string;
+}
+
+export interface CorrespondentCandidate {
+  name: string;
+  confidence: number;
 }
 
 export interface TagOption {
@@ -0,0 +1,163 @@
+import React, { useEffect, useState } from 'react';
+
+interface PendingCorrespondent {
+  id: number;
+  name: string;
+  document_ids: number[];
+  date_added: string;
+}
+
+const PendingCorrespondents: React.FC = () => {
+  const [pending, setPending] = useState<PendingCorrespondent[]>([]);
+  const [names, setNames] = useState<Record<number, string>>({});
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+  const [paperlessUrl, setPaperlessUrl] = useState<string>('');
+
+  // Get Paperless URL
+  useEffect(() => {
+    const fetchUrl = async () => {
+      try {
+        const response = await fetch('/api/paperless-url');
+        if (!response.ok) {
+          throw new Error('Failed to fetch public URL');
+        }
+        const { url } = await response.json();
+        setPaperlessUrl(url);
+      } catch (err) {
+        console.error('Error fetching Paperless URL:', err);
+      }
+    };
+
+    fetchUrl();
+  }, []);
+
+  useEffect(() => {
+    fetchPending();
+  }, []);
+
+  const fetchPending = async () => {
+    setLoading(true);
+    try {
+      const response = await fetch('/api/pending-correspondents');
+      if (!response.ok) {
+        throw new Error('Failed to fetch pending correspondents');
+      }
+      const data: PendingCorrespondent[] = await response.json();
+      setPending(data);
+      setNames(Object.fromEntries(data.map((item) => [item.id, item.name])));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Unknown error occurred');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleApprove = async (id: number) => {
+    try {
+      const response = await fetch(`/api/pending-correspondents/${id}/approve`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name: names[id] }),
+      });
+      if (!response.ok) {
+        throw new Error('Failed to approve correspondent');
+      }
+      setPending((items) => items.filter((item) => item.id !== id));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to approve correspondent');
+    }
+  };
+
+  const handleReject = async (id: number) => {
+    try {
+      const response = await fetch(`/api/pending-correspondents/${id}`, {
+        method: 'DELETE',
+      });
+      if (!response.ok) {
+        throw new Error('Failed to reject correspondent');
+      }
+      setPending((items) => items.filter((item) => item.id !== id));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to reject correspondent');
+    }
+  };
+
+  if (loading) {
+    return (
+      <div className="flex justify-center items-center min-h-screen">
+        <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-blue-500" />
+      </div>
+    );
+  }
+
+  if (error) {
+    return (
+      <div className="text-red-500 dark:text-red-400 p-4 text-center">
+        Error: {error}
+      </div>
+    );
+  }
+
+  return (
+    <div className="container mx-auto px-4 py-8">
+      <h1 className="text-2xl font-bold text-gray-800 dark:text-gray-200">
+        Pending Correspondents
+      </h1>
+      <div className="mb-6 text-sm text-gray-500 dark:text-gray-400">
+        New correspondents suggested by the LLM are only created after approval.
+      </div>
+      {pending.length === 0 ? (
+        <p className="text-gray-500 dark:text-gray-400 text-center">
+          No correspondents awaiting approval
+        </p>
+      ) : (
+        <div className="grid gap-4 md:grid-cols-1 lg:grid-cols-1 mb-6">
+          {pending.map((item) => (
+            <div
+              key={item.id}
+              className="bg-white dark:bg-gray-800 shadow-lg rounded-md p-4 flex flex-col gap-3"
+            >
+              <input
+                type="text"
+                value={names[item.id] ?? item.name}
+                onChange={(e) => setNames((prev) => ({ ...prev, [item.id]: e.target.value }))}
+                className="w-full border border-gray-300 dark:border-gray-600 rounded px-2 py-1 focus:outline-none focus:ring-2 focus:ring-blue-500 dark:bg-gray-700 dark:text-gray-200"
+              />
+              <div className="text-sm text-gray-600 dark:text-gray-400">
+                Documents:{' '}
+                {item.document_ids.map((documentId) => (
+                  <a
+                    key={documentId}
+                    href={`${paperlessUrl}/documents/${documentId}/details`}
+                    target="_blank"
+                    rel="noopener noreferrer"
+                    className="mr-2 text-blue-500 hover:underline"
+                  >
+                    #{documentId}
+                  </a>
+                ))}
+              </div>
+              <div className="flex space-x-2">
+                <button
+                  onClick={() => handleApprove(item.id)}
+                  className="px-3 py-1 rounded-md bg-blue-500 text-white hover:bg-blue-600 dark:bg-blue-600 dark:hover:bg-blue-700"
+                >
+                  Approve
+                </button>
+                <button
+                  onClick={() => handleReject(item.id)}
+                  className="px-3 py-1 rounded-md bg-gray-200 text-gray-700 hover:bg-gray-300 dark:bg-gray-700 dark:text-gray-200 dark:hover:bg-gray-600"
+                >
+                  Reject
+                </button>
+              </div>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default PendingCorrespondents;
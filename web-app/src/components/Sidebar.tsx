@@ -1,4 +1,4 @@
-import { mdiHistory, mdiHomeOutline, mdiTextBoxSearchOutline } from "@mdi/js";
+import { mdiAccountClockOutline, mdiHistory, mdiHomeOutline, mdiTextBoxSearchOutline } from "@mdi/js";
 import { Icon } from "@mdi/react";
 import axios from "axios";
 import React, { useCallback, useEffect, useState } from "react";
@@ -42,6 +42,12 @@ const Sidebar: React.FC<SidebarProps> = ({ onSelectPage }) => {
   const menuItems = [
     { name: "home", path: "/", icon: mdiHomeOutline, title: "Home" },
     { name: "history", path: "/history", icon: mdiHistory, title: "History" },
+    {
+      name: "pending-correspondents",
+      path: "/pending-correspondents",
+      icon: mdiAccountClockOutline,
+      title: "Correspondents",
+    },
   ];
 
   // If OCR is enabled, add the OCR menu item
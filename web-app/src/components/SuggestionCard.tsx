@@ -106,6 +106,15 @@ const SuggestionCard: React.FC<SuggestionCardProps> = ({
               highlight: "react-tags__highlight dark:bg-gray-800",
             }}
           />
+          {suggestion.tag_rationales && (
+            <ul className="mt-2 text-xs text-gray-500 dark:text-gray-400">
+              {Object.entries(suggestion.tag_rationales).map(([tag, reason]) => (
+                <li key={tag}>
+                  <span className="font-medium">{tag}:</span> {reason}
+                </li>
+              ))}
+            </ul>
+          )}
         </div>
         <div className="mt-4">
           <label className="block text-sm font-medium text-gray-700 dark:text-gray-300">
@@ -117,7 +126,22 @@ const SuggestionCard: React.FC<SuggestionCardProps> = ({
             onChange={(e) => onCorrespondentChange(suggestion.id, e.target.value)}
             className="w-full border border-gray-300 dark:border-gray-600 rounded px-2 py-1 mt-2 focus:outline-none focus:ring-2 focus:ring-blue-500 dark:bg-gray-700 dark:text-gray-200"
             placeholder="Correspondent"
+            list={`correspondent-candidates-${suggestion.id}`}
           />
+          {suggestion.correspondent_candidates && (
+            <datalist id={`correspondent-candidates-${suggestion.id}`}>
+              {suggestion.correspondent_candidates.map((candidate) => (
+                <option key={candidate.name} value={candidate.name}>
+                  {`${candidate.name} (${Math.round(candidate.confidence * 100)}%)`}
+                </option>
+              ))}
+            </datalist>
+          )}
+          {suggestion.correspondent_rationale && (
+            <p className="mt-2 text-xs text-gray-500 dark:text-gray-400">
+              {suggestion.correspondent_rationale}
+            </p>
+          )}
         </div>
       </div>
     </div>